@@ -0,0 +1,64 @@
+package alertevents
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	LATCH_TTL = 24 * time.Hour // Forget alerts we haven't heard about in this long
+)
+
+// Alertmanager sends every firing alert again each repeat_interval, and keeps
+// resolved ones in the payload while the rest of their group fires. Those
+// aren't new transitions, so we remember the last status we saw for each
+// alert and only let through the ones that changed.
+type Latch struct {
+	statuses map[string]*latchEntry // By fingerprint
+	lock     sync.Mutex
+}
+
+type latchEntry struct {
+	Status   string
+	LastSeen time.Time
+}
+
+func NewLatch() *Latch {
+	return &Latch{
+		statuses: make(map[string]*latchEntry),
+	}
+}
+
+// Record the alert and say whether its status differs from last time
+func (l *Latch) ShouldAccept(alert *Alert) bool {
+	now := time.Now().UTC()
+	fingerprint := fingerprintFor(alert)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.expire(now)
+
+	entry, ok := l.statuses[fingerprint]
+	if !ok {
+		l.statuses[fingerprint] = &latchEntry{Status: alert.Status, LastSeen: now}
+		return true
+	}
+
+	entry.LastSeen = now
+	if entry.Status == alert.Status {
+		return false
+	}
+
+	entry.Status = alert.Status
+	return true
+}
+
+// Drop the alerts that haven't been sent in a while. Must hold the lock.
+func (l *Latch) expire(now time.Time) {
+	for fingerprint, entry := range l.statuses {
+		if now.Sub(entry.LastSeen) > LATCH_TTL {
+			delete(l.statuses, fingerprint)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package alertevents
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Latch(t *testing.T) {
+	Convey("Latch", t, func() {
+		latch := NewLatch()
+
+		firing := &Alert{Status: "firing", Fingerprint: "deadbeef"}
+		resolved := &Alert{Status: "resolved", Fingerprint: "deadbeef"}
+
+		Convey("Accepts an alert the first time", func() {
+			So(latch.ShouldAccept(firing), ShouldBeTrue)
+		})
+
+		Convey("Drops re-sends that don't change the status", func() {
+			latch.ShouldAccept(firing)
+			So(latch.ShouldAccept(firing), ShouldBeFalse)
+
+			So(latch.ShouldAccept(resolved), ShouldBeTrue)
+			So(latch.ShouldAccept(resolved), ShouldBeFalse)
+
+			So(latch.ShouldAccept(firing), ShouldBeTrue)
+		})
+
+		Convey("Tracks each alert on its own", func() {
+			latch.ShouldAccept(firing)
+			So(latch.ShouldAccept(&Alert{Status: "firing", Fingerprint: "cafebabe"}), ShouldBeTrue)
+		})
+
+		Convey("Forgets alerts it hasn't heard about in a while", func() {
+			latch.ShouldAccept(firing)
+			latch.statuses["deadbeef"].LastSeen = time.Now().UTC().Add(-LATCH_TTL - time.Minute)
+
+			So(latch.ShouldAccept(firing), ShouldBeTrue)
+		})
+	})
+}
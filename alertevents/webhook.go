@@ -0,0 +1,127 @@
+package alertevents
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+)
+
+const (
+	ALERTMANAGER_HOSTNAME = "alertmanager" // Stands in for the Sidecar host
+)
+
+// The parts of an Alertmanager webhook payload we care about
+type Payload struct {
+	Version string  `json:"version"`
+	Status  string  `json:"status"`
+	Alerts  []Alert `json:"alerts"`
+}
+
+type Alert struct {
+	Status       string            `json:"status"` // "firing" or "resolved"
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Return the first of the labels that is set
+func firstLabel(labels map[string]string, names ...string) string {
+	for _, name := range names {
+		if labels[name] != "" {
+			return labels[name]
+		}
+	}
+	return ""
+}
+
+// Older Alertmanagers don't send a fingerprint, so make one from the labels
+func fingerprintFor(alert *Alert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+
+	names := make([]string, 0, len(alert.Labels))
+	for name := range alert.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha1.New()
+	for _, name := range names {
+		hash.Write([]byte(name + "\xff" + alert.Labels[name] + "\xff"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// Convert an alert into the event Sidecar would have sent if the alerting
+// thing were a service: firing alerts are Unhealthy and resolved ones are
+// Alive again. The service is named from the "service" or "job" label, and
+// the alert name goes in the image tag so it shows up as the version.
+func EventFromAlert(alert *Alert, defaultCluster string) catalog.StateChangedEvent {
+	alertName := firstLabel(alert.Labels, "alertname")
+	if alertName == "" {
+		alertName = "unknown"
+	}
+
+	status, previous, when := service.UNHEALTHY, service.ALIVE, alert.StartsAt
+	if alert.Status == "resolved" {
+		status, previous, when = service.ALIVE, service.UNHEALTHY, alert.EndsAt
+	}
+
+	if when.IsZero() {
+		when = time.Now().UTC()
+	}
+
+	clusterName := firstLabel(alert.Labels, "cluster")
+	if clusterName == "" {
+		clusterName = defaultCluster
+	}
+
+	svc := service.Service{
+		ID:       fingerprintFor(alert),
+		Name:     firstLabel(alert.Labels, "service", "job", "alertname"),
+		Image:    "alertmanager:" + alertName,
+		Created:  alert.StartsAt,
+		Hostname: firstLabel(alert.Labels, "instance", "hostname", "host"),
+		Updated:  when,
+		Status:   status,
+	}
+
+	if svc.Name == "" {
+		svc.Name = alertName
+	}
+
+	if svc.Hostname == "" {
+		svc.Hostname = ALERTMANAGER_HOSTNAME
+	}
+
+	return catalog.StateChangedEvent{
+		State: catalog.ServicesState{
+			ClusterName: clusterName,
+			Hostname:    ALERTMANAGER_HOSTNAME,
+		},
+		ChangeEvent: catalog.ChangeEvent{
+			Service:        svc,
+			PreviousStatus: previous,
+			Time:           when,
+		},
+	}
+}
+
+// Convert every alert in a webhook payload
+func EventsFromPayload(payload *Payload, defaultCluster string) []catalog.StateChangedEvent {
+	events := make([]catalog.StateChangedEvent, 0, len(payload.Alerts))
+	for i := range payload.Alerts {
+		events = append(events, EventFromAlert(&payload.Alerts[i], defaultCluster))
+	}
+
+	return events
+}
@@ -0,0 +1,68 @@
+package alertevents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_EventFromAlert(t *testing.T) {
+	Convey("EventFromAlert()", t, func() {
+		startsAt := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+		endsAt := startsAt.Add(time.Hour)
+
+		alert := &Alert{
+			Status: "firing",
+			Labels: map[string]string{
+				"alertname": "HighLatency",
+				"job":       "verdun",
+				"instance":  "meuse:8080",
+			},
+			StartsAt:    startsAt,
+			EndsAt:      endsAt,
+			Fingerprint: "deadbeef",
+		}
+
+		Convey("Turns firing alerts into unhealthy services", func() {
+			evt := EventFromAlert(alert, "france")
+			svc := evt.ChangeEvent.Service
+
+			So(svc.Name, ShouldEqual, "verdun")
+			So(svc.ID, ShouldEqual, "deadbeef")
+			So(svc.Hostname, ShouldEqual, "meuse:8080")
+			So(svc.Image, ShouldEqual, "alertmanager:HighLatency")
+			So(svc.Status, ShouldEqual, service.UNHEALTHY)
+			So(evt.ChangeEvent.PreviousStatus, ShouldEqual, service.ALIVE)
+			So(evt.ChangeEvent.Time, ShouldResemble, startsAt)
+			So(evt.State.ClusterName, ShouldEqual, "france")
+		})
+
+		Convey("Turns resolved alerts into recoveries", func() {
+			alert.Status = "resolved"
+			evt := EventFromAlert(alert, "france")
+
+			So(evt.ChangeEvent.Service.Status, ShouldEqual, service.ALIVE)
+			So(evt.ChangeEvent.PreviousStatus, ShouldEqual, service.UNHEALTHY)
+			So(evt.ChangeEvent.Time, ShouldResemble, endsAt)
+		})
+
+		Convey("Prefers the cluster label and falls back on the alert name", func() {
+			alert.Labels = map[string]string{"alertname": "DiskFull", "cluster": "belgium"}
+			evt := EventFromAlert(alert, "france")
+
+			So(evt.State.ClusterName, ShouldEqual, "belgium")
+			So(evt.ChangeEvent.Service.Name, ShouldEqual, "DiskFull")
+			So(evt.ChangeEvent.Service.Hostname, ShouldEqual, ALERTMANAGER_HOSTNAME)
+		})
+
+		Convey("Makes a stable fingerprint when there isn't one", func() {
+			alert.Fingerprint = ""
+			first := EventFromAlert(alert, "france").ChangeEvent.Service.ID
+
+			alert.Status = "resolved"
+			So(EventFromAlert(alert, "france").ChangeEvent.Service.ID, ShouldEqual, first)
+		})
+	})
+}
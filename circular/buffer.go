@@ -3,7 +3,6 @@ package circular
 import (
 	"container/ring"
 
-	"github.com/newrelic/sidecar/catalog"
 	"github.com/nitro/superside/datatypes"
 )
 
@@ -27,29 +26,99 @@ func (b *SvcEventsBuffer) All() []datatypes.Notification {
 			return
 		}
 
-		event := evt.(catalog.StateChangedEvent)
-		changeHistory = append(changeHistory, *datatypes.NotificationFromEvent(&event))
+		changeHistory = append(changeHistory, evt.(datatypes.Notification))
 	})
 
 	return changeHistory
 }
 
-func (b *SvcEventsBuffer) AllRaw() []catalog.StateChangedEvent {
-	var changeHistory []catalog.StateChangedEvent
-	b.changes.Do(func(evt interface{}) { // Start from oldest node
-		if evt == nil {
+// How many notifications the buffer holds before it drops the oldest
+func (b *SvcEventsBuffer) Capacity() int {
+	return b.changes.Len()
+}
+
+// How many notifications are in the buffer
+func (b *SvcEventsBuffer) Count() int {
+	count := 0
+	b.changes.Do(func(evt interface{}) {
+		if evt != nil {
+			count += 1
+		}
+	})
+
+	return count
+}
+
+// Find a notification by ID. Returns nil if it has fallen out of the buffer.
+func (b *SvcEventsBuffer) Get(id string) *datatypes.Notification {
+	var found *datatypes.Notification
+	b.changes.Do(func(evt interface{}) {
+		if evt == nil || found != nil {
 			return
 		}
-		event := evt.(catalog.StateChangedEvent)
-		changeHistory = append(changeHistory, event)
+
+		notice := evt.(datatypes.Notification)
+		if notice.ID == id {
+			found = &notice
+		}
 	})
 
-	return changeHistory
+	return found
 }
 
-func (b *SvcEventsBuffer) Insert(evt catalog.StateChangedEvent) {
-	b.changes.Value = evt
+// Modify the notification with the given ID in place. Returns false if we
+// don't have it.
+func (b *SvcEventsBuffer) Update(id string, fn func(*datatypes.Notification)) bool {
+	node := b.changes
+	for i := 0; i < node.Len(); i++ {
+		if node.Value != nil {
+			notice := node.Value.(datatypes.Notification)
+			if notice.ID == id {
+				fn(&notice)
+				node.Value = notice
+				return true
+			}
+		}
+		node = node.Next()
+	}
+
+	return false
+}
+
+// Insert a notification, returning the one it pushed out of the buffer, if any
+func (b *SvcEventsBuffer) Insert(notice *datatypes.Notification) *datatypes.Notification {
+	var evicted *datatypes.Notification
+	if b.changes.Value != nil {
+		old := b.changes.Value.(datatypes.Notification)
+		evicted = &old
+	}
+
+	b.changes.Value = *notice
 	b.changes = b.changes.Next()
+
+	return evicted
+}
+
+// Drop the notifications keep() turns down, leaving the rest in order.
+// Returns the ones it dropped.
+func (b *SvcEventsBuffer) Retain(keep func(*datatypes.Notification) bool) []datatypes.Notification {
+	var dropped []datatypes.Notification
+	kept := ring.New(b.changes.Len())
+	next := kept
+
+	for _, notice := range b.All() {
+		if !keep(&notice) {
+			dropped = append(dropped, notice)
+			continue
+		}
+		next.Value = notice
+		next = next.Next()
+	}
+
+	// Carry on inserting after the newest one we kept
+	b.changes = next
+
+	return dropped
 }
 
 // A Ring buffer for Deployments
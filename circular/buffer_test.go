@@ -19,22 +19,95 @@ func Test_SvcEventsBuffer(t *testing.T) {
 			State:       catalog.ServicesState{ClusterName: "awesome-cluster"},
 		}
 
+		notice := datatypes.NotificationFromEvent(&evt)
+
 		Convey("Inserts new values", func() {
 			for i := 0; i < 10; i++ {
-				buffer.Insert(evt)
+				buffer.Insert(notice)
 			}
 
 			all := buffer.All()
-			So(&all[0], ShouldResemble, datatypes.NotificationFromEvent(&evt))
+			So(&all[0], ShouldResemble, notice)
 		})
 
 		Convey("Inserts more than the size", func() {
 			for i := 0; i < 20; i++ {
-				buffer.Insert(evt)
+				buffer.Insert(notice)
 			}
 
 			all := buffer.All()
-			So(&all[0], ShouldResemble, datatypes.NotificationFromEvent(&evt))
+			So(&all[0], ShouldResemble, notice)
+		})
+	})
+}
+
+func Test_SvcEventsBufferLookups(t *testing.T) {
+	Convey("Finding and updating notifications by ID", t, func() {
+		buffer := NewSvcEventsBuffer(10)
+
+		for _, id := range []string{"joffre", "foch", "petain"} {
+			buffer.Insert(&datatypes.Notification{ID: id, ClusterName: "france"})
+		}
+
+		Convey("Gets a notification by ID", func() {
+			So(buffer.Get("foch").ID, ShouldEqual, "foch")
+			So(buffer.Get("lyautey"), ShouldBeNil)
+		})
+
+		Convey("Updates a notification in place", func() {
+			ok := buffer.Update("foch", func(notice *datatypes.Notification) {
+				notice.ClusterName = "belgium"
+			})
+
+			So(ok, ShouldBeTrue)
+			So(buffer.Get("foch").ClusterName, ShouldEqual, "belgium")
+			So(buffer.Get("joffre").ClusterName, ShouldEqual, "france")
+		})
+
+		Convey("Returns the notification pushed out of a full buffer", func() {
+			small := NewSvcEventsBuffer(2)
+
+			So(small.Insert(&datatypes.Notification{ID: "joffre"}), ShouldBeNil)
+			So(small.Insert(&datatypes.Notification{ID: "foch"}), ShouldBeNil)
+			So(small.Insert(&datatypes.Notification{ID: "petain"}).ID, ShouldEqual, "joffre")
+		})
+
+		Convey("Reports when there's nothing to update", func() {
+			So(buffer.Update("lyautey", func(*datatypes.Notification) {}), ShouldBeFalse)
+		})
+	})
+}
+
+func Test_SvcEventsBufferRetain(t *testing.T) {
+	Convey("Dropping notifications from the buffer", t, func() {
+		buffer := NewSvcEventsBuffer(5)
+
+		for _, id := range []string{"joffre", "foch", "petain", "nivelle"} {
+			buffer.Insert(&datatypes.Notification{ID: id})
+		}
+
+		dropped := buffer.Retain(func(notice *datatypes.Notification) bool {
+			return notice.ID != "foch" && notice.ID != "nivelle"
+		})
+
+		Convey("Returns the ones it dropped", func() {
+			So(len(dropped), ShouldEqual, 2)
+			So(dropped[0].ID, ShouldEqual, "foch")
+		})
+
+		Convey("Counts what's left", func() {
+			So(buffer.Count(), ShouldEqual, 2)
+			So(buffer.Capacity(), ShouldEqual, 5)
+		})
+
+		Convey("Keeps the rest in order and carries on after them", func() {
+			buffer.Insert(&datatypes.Notification{ID: "haig"})
+
+			all := buffer.All()
+			So(len(all), ShouldEqual, 3)
+			So(all[0].ID, ShouldEqual, "joffre")
+			So(all[1].ID, ShouldEqual, "petain")
+			So(all[2].ID, ShouldEqual, "haig")
 		})
 	})
 }
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_TIMEOUT     = 10 * time.Second
+	DEFAULT_MIN_BACKOFF = 1 * time.Second // Doubled after each failed reconnect
+	DEFAULT_MAX_BACKOFF = 1 * time.Minute
+	IDEMPOTENCY_HEADER  = "Idempotency-Key"
+)
+
+// The update acknowledgement wasn't signed with our key, or not for what we
+// sent, so something between us and superside may have tampered with it
+var ErrBadSignature = errors.New("superside: update acknowledgement has a bad signature")
+
+// An error response from the API. Code is one of the server's ERR_ codes,
+// e.g. "invalid_filter".
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("superside: %d %s: %s (request %s)", e.Status, e.Code, e.Message, e.RequestID)
+}
+
+// Narrows down the events from GetState() and StreamNotifications(). They
+// work like the ?transition=, ?region= and ?severity= query parameters.
+type Filters struct {
+	Transitions []string // e.g. "Alive->Unhealthy", "*->Tombstone"
+	Region      string
+	Severity    string // "info", "warning" or "critical", matching that or worse
+	Limit       int    // Only the newest this many, for GetState(). 0 for all of them.
+}
+
+func (f Filters) values() url.Values {
+	values := url.Values{}
+	for _, transition := range f.Transitions {
+		values.Add("transition", transition)
+	}
+	if f.Region != "" {
+		values.Set("region", f.Region)
+	}
+	if f.Severity != "" {
+		values.Set("severity", f.Severity)
+	}
+
+	return values
+}
+
+// A Superside API client. The fields can be changed before it's used.
+type Client struct {
+	BaseURL    string // e.g. "http://superside:7779"
+	HTTP       *http.Client
+	Dialer     *websocket.Dialer
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTP:       &http.Client{Timeout: DEFAULT_TIMEOUT},
+		Dialer:     &websocket.Dialer{HandshakeTimeout: DEFAULT_TIMEOUT},
+		MinBackoff: DEFAULT_MIN_BACKOFF,
+		MaxBackoff: DEFAULT_MAX_BACKOFF,
+	}
+}
+
+// Send a Sidecar state change, the way Sidecar itself does
+func (c *Client) PostUpdate(ctx context.Context, evt catalog.StateChangedEvent) error {
+	return c.PostUpdateOnce(ctx, "", evt)
+}
+
+// Send a Sidecar state change with an idempotency key, so sending it again
+// after a timeout or error can't record it twice. Empty key means none.
+func (c *Client) PostUpdateOnce(ctx context.Context, key string, evt catalog.StateChangedEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	return c.postUpdate(ctx, key, body, nil)
+}
+
+// Send a Sidecar state change to a superside with acknowledgements turned
+// on, checking that the acknowledgement was signed with ackKey for exactly
+// what we sent. Returns ErrBadSignature if it wasn't.
+func (c *Client) PostUpdateVerified(ctx context.Context, ackKey string, evt catalog.StateChangedEvent) (*datatypes.UpdateAck, error) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	var ack datatypes.UpdateAck
+	err = c.postUpdate(ctx, "", body, &ack)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ack.Verify([]byte(ackKey), body) {
+		return nil, ErrBadSignature
+	}
+
+	return &ack, nil
+}
+
+func (c *Client) postUpdate(ctx context.Context, key string, body []byte, result interface{}) error {
+	req, err := http.NewRequest("POST", c.BaseURL+"/api/update", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IDEMPOTENCY_HEADER, key)
+	}
+
+	return c.do(ctx, req, result)
+}
+
+// Fetch the stored events, oldest first
+func (c *Client) GetState(ctx context.Context, filters Filters) ([]datatypes.Notification, error) {
+	path := "/api/state/services"
+	values := filters.values()
+	if filters.Limit > 0 {
+		values.Set("limit", strconv.Itoa(filters.Limit))
+	}
+	if query := values.Encode(); query != "" {
+		path += "?" + query
+	}
+
+	var events []datatypes.Notification
+	err := c.get(ctx, path, &events)
+	return events, err
+}
+
+// Fetch the services that are flapping right now
+func (c *Client) GetFlapping(ctx context.Context) ([]datatypes.FlapStatus, error) {
+	var flapping []datatypes.FlapStatus
+	err := c.get(ctx, "/api/state/flapping", &flapping)
+	return flapping, err
+}
+
+// Fetch when each cluster was last heard from
+func (c *Client) GetClusters(ctx context.Context) ([]datatypes.ClusterLastSeen, error) {
+	var clusters []datatypes.ClusterLastSeen
+	err := c.get(ctx, "/api/v1/clusters/last-seen", &clusters)
+	return clusters, err
+}
+
+// Fetch what a cluster's services look like right now
+func (c *Client) GetCluster(ctx context.Context, clusterName string) (*datatypes.ClusterView, error) {
+	var view datatypes.ClusterView
+	err := c.get(ctx, "/api/v1/clusters/"+url.PathEscape(clusterName)+"/current", &view)
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequest("GET", c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	return c.do(ctx, req, result)
+}
+
+// Send the request, decoding the response into result if there is one, or
+// into an *Error if the API didn't like it
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) error {
+	resp, err := c.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiError := &Error{Status: resp.StatusCode}
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, apiError) != nil || apiError.Code == "" {
+			apiError.Message = strings.TrimSpace(string(data))
+		}
+		return apiError
+	}
+
+	if result == nil {
+		io.Copy(ioutil.Discard, resp.Body) // So the connection is reused
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
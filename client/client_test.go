@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/server"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func failure(svcName string) catalog.StateChangedEvent {
+	return catalog.StateChangedEvent{
+		State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+		ChangeEvent: catalog.ChangeEvent{
+			Service:        service.Service{ID: svcName, Name: svcName, Hostname: "meuse", Status: service.UNHEALTHY},
+			PreviousStatus: service.ALIVE,
+			Time:           time.Now().UTC(),
+		},
+	}
+}
+
+func Test_Client(t *testing.T) {
+	Convey("The client", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		superside := httptest.NewServer(server.New(state, server.WithUIPath("")).Handler())
+		defer superside.Close()
+
+		client := New(superside.URL + "/")
+		ctx := context.Background()
+
+		Convey("Posts updates and fetches the state", func() {
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+
+			So(client.PostUpdate(ctx, failure("verdun")), ShouldBeNil)
+			somme := failure("somme")
+			So(client.PostUpdateOnce(ctx, "somme-1916", somme), ShouldBeNil)
+			So(client.PostUpdateOnce(ctx, "somme-1916", somme), ShouldBeNil) // A retry
+			nextEvent(listener)
+			nextEvent(listener)
+
+			events, err := client.GetState(ctx, Filters{Transitions: []string{"Alive->Unhealthy"}})
+			So(err, ShouldBeNil)
+			So(len(events), ShouldEqual, 2)
+			So(events[0].Event.Service.Name, ShouldEqual, "verdun")
+		})
+
+		Convey("Checks signed update acknowledgements", func() {
+			signed := httptest.NewServer(server.New(state, server.WithUIPath(""), server.WithUpdateAcks("joffre")).Handler())
+			defer signed.Close()
+			client := New(signed.URL)
+
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+
+			ack, err := client.PostUpdateVerified(ctx, "joffre", failure("verdun"))
+			So(err, ShouldBeNil)
+			So(nextEvent(listener).ID, ShouldEqual, ack.EventID)
+
+			_, err = client.PostUpdateVerified(ctx, "falkenhayn", failure("verdun"))
+			So(err, ShouldEqual, ErrBadSignature)
+		})
+
+		Convey("Returns API errors with their code", func() {
+			_, err := client.GetState(ctx, Filters{Transitions: []string{"Sideways->Up"}})
+
+			apiError, ok := err.(*Error)
+			So(ok, ShouldBeTrue)
+			So(apiError.Status, ShouldEqual, http.StatusBadRequest)
+			So(apiError.Code, ShouldEqual, "invalid_filter")
+			So(apiError.RequestID, ShouldNotBeEmpty)
+		})
+
+		Convey("Streams notifications", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			notices := client.StreamNotifications(ctx, Filters{})
+			time.Sleep(50 * time.Millisecond) // Let it connect
+
+			client.PostUpdate(ctx, failure("verdun"))
+			notice := <-notices
+			So(notice.Event.Service.Name, ShouldEqual, "verdun")
+
+			cancel()
+			for range notices {
+			}
+		})
+	})
+}
+
+// The next service event, skipping the incident notices the failures open
+func nextEvent(notices <-chan *datatypes.Notification) *datatypes.Notification {
+	for notice := range notices {
+		if notice.Event != nil {
+			return notice
+		}
+	}
+	return nil
+}
+
+func Test_StreamReconnects(t *testing.T) {
+	Convey("Streaming reconnects and catches up", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+
+		// Keep hold of the connections so we can drop them
+		var lock sync.Mutex
+		var conns []net.Conn
+		superside := httptest.NewUnstartedServer(server.New(state, server.WithUIPath("")).Handler())
+		superside.Config.ConnState = func(conn net.Conn, connState http.ConnState) {
+			if connState == http.StateNew {
+				lock.Lock()
+				conns = append(conns, conn)
+				lock.Unlock()
+			}
+		}
+		superside.Start()
+		defer superside.Close()
+
+		client := New(superside.URL)
+		client.MinBackoff = 200 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		notices := client.StreamNotifications(ctx, Filters{})
+		time.Sleep(50 * time.Millisecond) // Let it connect
+
+		state.EnqueueUpdate(failure("verdun"))
+		So(nextEvent(notices).Event.Service.Name, ShouldEqual, "verdun")
+
+		lock.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		lock.Unlock()
+
+		// Happens while we're disconnected, so has to come from the replay
+		state.EnqueueUpdate(failure("somme"))
+		So(nextEvent(notices).Event.Service.Name, ShouldEqual, "somme")
+
+		// And only once
+		state.EnqueueUpdate(failure("marne"))
+		So(nextEvent(notices).Event.Service.Name, ShouldEqual, "marne")
+	})
+}
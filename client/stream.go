@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Speaking the server's /listen command protocol lets us ask it to replay
+// what we missed while reconnecting
+const (
+	LISTEN_SUBPROTOCOL = "superside.v1"
+	STREAM_BUFFER_SIZE = 100
+	REMEMBERED_IDS     = 1000 // For skipping events the replay and stream both send
+)
+
+type listenFrame struct {
+	Type string
+	Ref  string
+	Data json.RawMessage
+}
+
+type listenCommand struct {
+	Command string
+	Since   string `json:",omitempty"`
+}
+
+// Stream notifications from /listen until the context is done, when the
+// channel is closed. Dropped connections are retried with backoff, and the
+// server replays the stored events we missed in between, if it still has
+// them. Heartbeats and deployments aren't passed on.
+func (c *Client) StreamNotifications(ctx context.Context, filters Filters) <-chan *datatypes.Notification {
+	notices := make(chan *datatypes.Notification, STREAM_BUFFER_SIZE)
+
+	go func() {
+		defer close(notices)
+
+		seen := newRecentIDs(REMEMBERED_IDS)
+		backoff := c.MinBackoff
+
+		for {
+			connected, err := c.stream(ctx, filters, seen, notices)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				backoff = c.MinBackoff
+			}
+			log.Warnf("Lost the Superside stream, reconnecting in %s: %s", backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > c.MaxBackoff {
+				backoff = c.MaxBackoff
+			}
+		}
+	}()
+
+	return notices
+}
+
+// Run one connection until it fails, reporting whether it got going at all
+func (c *Client) stream(ctx context.Context, filters Filters, seen *recentIDs, notices chan<- *datatypes.Notification) (bool, error) {
+	endpoint := "ws" + strings.TrimPrefix(c.BaseURL, "http") + "/listen"
+	if query := filters.values().Encode(); query != "" {
+		endpoint += "?" + query
+	}
+
+	dialer := *c.Dialer
+	dialer.Subprotocols = []string{LISTEN_SUBPROTOCOL}
+	conn, resp, err := dialer.Dial(endpoint, http.Header{})
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Unblock the read below when we're told to stop
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if seen.lastStored != "" {
+		err = conn.WriteJSON(listenCommand{Command: "replay", Since: seen.lastStored})
+		if err != nil {
+			return true, err
+		}
+	}
+
+	for {
+		var frame listenFrame
+		err := conn.ReadJSON(&frame)
+		if err != nil {
+			return true, err
+		}
+
+		switch frame.Type {
+		case "event":
+			var notice datatypes.Notification
+			if json.Unmarshal(frame.Data, &notice) != nil || seen.contains(notice.ID) {
+				continue
+			}
+			seen.add(notice.ID)
+			if notice.Type == datatypes.SERVICE_EVENT_NOTICE {
+				seen.lastStored = notice.ID
+			}
+
+			select {
+			case notices <- &notice:
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
+
+		case "error":
+			// Usually that the events we'd replay have gone, which we
+			// can't do anything about
+			log.Warnf("Superside stream error: %s", frame.Data)
+		}
+	}
+}
+
+// The IDs of the last few events we've passed on, so replays and the live
+// stream don't send anything twice
+type recentIDs struct {
+	ids        map[string]bool
+	order      []string
+	size       int
+	lastStored string // The last service event, which the server can replay from
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{ids: make(map[string]bool, size), size: size}
+}
+
+func (r *recentIDs) add(id string) {
+	r.ids[id] = true
+	r.order = append(r.order, id)
+	if len(r.order) > r.size {
+		delete(r.ids, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *recentIDs) contains(id string) bool {
+	return r.ids[id]
+}
@@ -0,0 +1,179 @@
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	SPEC_VERSION      = "1.0"
+	STRUCTURED_TYPE   = "application/cloudevents+json"
+	NOTIFICATION_TYPE = "com.nitro.superside.notification" // Data is a Notification
+	STATE_CHANGE_TYPE = "com.nitro.sidecar.state_changed"  // Data is a Sidecar StateChangedEvent
+	DEFAULT_SOURCE    = "/superside"
+)
+
+// A CloudEvents 1.0 event (https://github.com/cloudevents/spec) with JSON data
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// Wrap a notification, with the cluster/service as the subject
+func FromNotification(notice *datatypes.Notification, source string) (*Event, error) {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := notice.ClusterName
+	if notice.Event != nil {
+		subject += "/" + notice.Event.Service.Name
+	}
+
+	when := notice.ReceivedAt
+	if notice.Event != nil && !notice.Event.Time.IsZero() {
+		when = notice.Event.Time
+	}
+
+	return &Event{
+		SpecVersion:     SPEC_VERSION,
+		ID:              notice.ID,
+		Source:          source,
+		Type:            NOTIFICATION_TYPE,
+		Subject:         subject,
+		Time:            when.UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}, nil
+}
+
+func (e *Event) Validate() error {
+	if e.SpecVersion != SPEC_VERSION {
+		return fmt.Errorf("Unsupported specversion '%s'", e.SpecVersion)
+	}
+
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return errors.New("CloudEvents need an id, source and type")
+	}
+
+	return nil
+}
+
+// Turn the event's data back into a Sidecar event we can ingest
+func (e *Event) StateChangedEvent() (*catalog.StateChangedEvent, error) {
+	switch e.Type {
+	case STATE_CHANGE_TYPE:
+		var evt catalog.StateChangedEvent
+		err := json.Unmarshal(e.Data, &evt)
+		return &evt, err
+
+	case NOTIFICATION_TYPE:
+		var notice datatypes.Notification
+		err := json.Unmarshal(e.Data, &notice)
+		if err != nil {
+			return nil, err
+		}
+		if notice.Event == nil {
+			return nil, errors.New("Only service event notifications can be ingested")
+		}
+
+		return &catalog.StateChangedEvent{
+			State: catalog.ServicesState{
+				ClusterName: notice.ClusterName,
+				Hostname:    notice.Event.Service.Hostname,
+			},
+			ChangeEvent: *notice.Event,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported event type '%s'", e.Type)
+}
+
+// Add the event to a request. In binary mode the attributes go in ce-
+// headers and the body is just the data; otherwise the whole event is the
+// body.
+func (e *Event) Write(req *http.Request, binary bool) error {
+	if !binary {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		setBody(req, body)
+		req.Header.Set("Content-Type", STRUCTURED_TYPE)
+		return nil
+	}
+
+	req.Header.Set("ce-specversion", e.SpecVersion)
+	req.Header.Set("ce-id", e.ID)
+	req.Header.Set("ce-source", e.Source)
+	req.Header.Set("ce-type", e.Type)
+	if e.Subject != "" {
+		req.Header.Set("ce-subject", e.Subject)
+	}
+	if !e.Time.IsZero() {
+		req.Header.Set("ce-time", e.Time.Format(time.RFC3339Nano))
+	}
+
+	setBody(req, e.Data)
+	req.Header.Set("Content-Type", e.DataContentType)
+	return nil
+}
+
+func setBody(req *http.Request, body []byte) {
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+}
+
+// Read an event from a request in either structured or binary mode
+func FromRequest(req *http.Request) (*Event, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	var event Event
+	if mediaType == STRUCTURED_TYPE {
+		err = json.Unmarshal(body, &event)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		event = Event{
+			SpecVersion:     req.Header.Get("ce-specversion"),
+			ID:              req.Header.Get("ce-id"),
+			Source:          req.Header.Get("ce-source"),
+			Type:            req.Header.Get("ce-type"),
+			Subject:         req.Header.Get("ce-subject"),
+			DataContentType: mediaType,
+			Data:            body,
+		}
+
+		if when := req.Header.Get("ce-time"); when != "" {
+			event.Time, err = time.Parse(time.RFC3339Nano, when)
+			if err != nil {
+				return nil, fmt.Errorf("Bad ce-time: %s", err.Error())
+			}
+		}
+	}
+
+	return &event, event.Validate()
+}
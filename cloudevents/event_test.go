@@ -0,0 +1,92 @@
+package cloudevents
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Event(t *testing.T) {
+	Convey("CloudEvents", t, func() {
+		baseTime := time.Date(1916, 2, 21, 7, 0, 0, 0, time.UTC)
+
+		notice := &datatypes.Notification{
+			ID:          "joffre",
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "db", Hostname: "verdun", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           baseTime,
+			},
+		}
+
+		event, err := FromNotification(notice, DEFAULT_SOURCE)
+		So(err, ShouldBeNil)
+
+		Convey("Wrap notifications", func() {
+			So(event.ID, ShouldEqual, "joffre")
+			So(event.Type, ShouldEqual, NOTIFICATION_TYPE)
+			So(event.Subject, ShouldEqual, "france/db")
+			So(event.Time, ShouldResemble, baseTime)
+			So(event.Validate(), ShouldBeNil)
+		})
+
+		for _, binary := range []bool{false, true} {
+			mode := map[bool]string{false: "structured", true: "binary"}[binary]
+
+			Convey("Round trip through "+mode+" mode", func() {
+				req := httptest.NewRequest("POST", "/", nil)
+				So(event.Write(req, binary), ShouldBeNil)
+
+				received, err := FromRequest(req)
+				So(err, ShouldBeNil)
+				So(received.ID, ShouldEqual, "joffre")
+				So(received.Subject, ShouldEqual, "france/db")
+				So(received.Time, ShouldResemble, baseTime)
+
+				evt, err := received.StateChangedEvent()
+				So(err, ShouldBeNil)
+				So(evt.State.ClusterName, ShouldEqual, "france")
+				So(evt.State.Hostname, ShouldEqual, "verdun")
+				So(evt.ChangeEvent.Service.Status, ShouldEqual, service.UNHEALTHY)
+			})
+		}
+
+		Convey("Accept Sidecar state changes in binary mode", func() {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(
+				`{"State": {"ClusterName": "belgium"}, "ChangeEvent": {"Service": {"Name": "queue"}}}`,
+			))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("ce-specversion", "1.0")
+			req.Header.Set("ce-id", "foch")
+			req.Header.Set("ce-source", "/sidecar")
+			req.Header.Set("ce-type", STATE_CHANGE_TYPE)
+
+			received, err := FromRequest(req)
+			So(err, ShouldBeNil)
+
+			evt, err := received.StateChangedEvent()
+			So(err, ShouldBeNil)
+			So(evt.State.ClusterName, ShouldEqual, "belgium")
+			So(evt.ChangeEvent.Service.Name, ShouldEqual, "queue")
+		})
+
+		Convey("Reject events missing attributes or of unknown types", func() {
+			req := httptest.NewRequest("POST", "/", strings.NewReader("{}"))
+			req.Header.Set("ce-specversion", "1.0")
+			_, err := FromRequest(req)
+			So(err, ShouldNotBeNil)
+
+			event.Type = "com.example.other"
+			_, err = event.StateChangedEvent()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
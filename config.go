@@ -1,27 +1,321 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/digest"
+	"github.com/nitro/superside/hooks"
+	"github.com/nitro/superside/logging"
+	"github.com/nitro/superside/notify"
+	"github.com/nitro/superside/server"
+	"github.com/nitro/superside/sinks"
+	"github.com/nitro/superside/tracker"
 )
 
 type Config struct {
-	Superside *ApiConfig       `toml:"superside"`
+	Superside    *ApiConfig          `toml:"superside"`
+	Logging      *LoggingConfig      `toml:"logging"`
+	Websocket    *WebsocketConfig    `toml:"websocket"`
+	Docker       *DockerConfig       `toml:"docker"`
+	Flapping     *FlappingConfig     `toml:"flapping"`
+	Correlation  *CorrelationConfig  `toml:"correlation"`
+	Watchdog     *WatchdogConfig     `toml:"watchdog"`
+	Anomaly      *AnomalyConfig      `toml:"anomaly"`
+	Coalesce     *CoalesceConfig     `toml:"coalesce"`
+	ClockSkew    *ClockSkewConfig    `toml:"clock_skew"`
+	Heartbeat    *HeartbeatConfig    `toml:"heartbeat"`
+	Retry        *RetryConfig        `toml:"retry"`
+	Retention    *RetentionConfig    `toml:"retention"`
+	Slack        *SlackConfig        `toml:"slack"`
+	Elastic      *ElasticConfig      `toml:"elasticsearch"`
+	Influx       *InfluxConfig       `toml:"influxdb"`
+	ClickHouse   *ClickHouseConfig   `toml:"clickhouse"`
+	Grafana      *GrafanaConfig      `toml:"grafana"`
+	Github       *GithubConfig       `toml:"github"`
+	Alertmanager *AlertmanagerConfig `toml:"alertmanager"`
+	Dependencies map[string][]string `toml:"dependencies"`    // Service => services it depends on
+	Regions      map[string][]string `toml:"regions"`         // Region => clusters in it
+	Aliases      map[string]string   `toml:"cluster_aliases"` // Sidecar cluster name => name to use
+	Ingest       *IngestConfig       `toml:"ingest"`
+	Snapshots    *SnapshotConfig     `toml:"snapshots"`
+	Backfill     *BackfillConfig     `toml:"backfill"`
+	Idempotency  *IdempotencyConfig  `toml:"idempotency"`
+	Readiness    *ReadinessConfig    `toml:"readiness"`
+	StatusPage   *StatusPageConfig   `toml:"status_page"`
+	Hooks        []*HookConfig       `toml:"hook"`           // Lua scripts run on each event, in order
+	Severities   []*SeverityConfig   `toml:"severity"`       // Classification rules, the first match wins
+	Labels       ServiceLabels       `toml:"service_labels"` // Service => metadata for its notifications
+	Links        ServiceLinks        `toml:"service_links"`  // Service => runbook, dashboard etc. URLs
+	Notifiers    []notify.Settings   `toml:"notifier"`       // Any number of [[notifier]] sections
+	Digests      []*DigestConfig     `toml:"digest"`
 }
 
 type ApiConfig struct {
 	BindIP       string `toml:"bind_ip"`
 	BindPort     int    `toml:"bind_port"`
-	LoggingLevel string `toml:"logging_level"`
+	LoggingLevel string `toml:"logging_level"` // Deprecated, use [logging] level
+	AdminToken   string `toml:"admin_token"`   // Bearer token for /admin and the subscriptions API
+	AckKey       string `toml:"ack_key"`       // Signs /api/update acknowledgements, off when unset
+	Timezone     string `toml:"timezone"`      // For times in the UI and digests, e.g. "Europe/Paris", UTC when unset
+	BasePath     string `toml:"base_path"`     // URL prefix when mounted behind a proxy, e.g. "/superside"
+	location     *time.Location
+}
+
+// Settings for the websocket endpoints. Browsers may only open websockets
+// from the origin the UI is served from, or the ones listed here.
+type WebsocketConfig struct {
+	ReadBuffer     int      `toml:"read_buffer"`     // Bytes
+	WriteBuffer    int      `toml:"write_buffer"`    // Bytes
+	AllowedOrigins []string `toml:"allowed_origins"` // e.g. "https://dash.example.com", or "*" for any
+}
+
+// Where and how we log. The level, format and file can also be set on the
+// command line, which wins over the config file.
+type LoggingConfig struct {
+	Level      string `toml:"level"`       // "debug", "info", "warn" or "error"
+	Format     string `toml:"format"`      // "text" or "json"
+	File       string `toml:"file"`        // Log here instead of stderr
+	MaxSizeMB  int    `toml:"max_size_mb"` // Rotate the file when it gets this big
+	MaxAge     string `toml:"max_age"`     // Rotate the file when it's this old, e.g. "24h"
+	MaxBackups int    `toml:"max_backups"` // Rotated files to keep, all of them when unset
+	Syslog     string `toml:"syslog"`      // Also send to syslog, e.g. "local" or "udp://logs:514"
+	Facility   string `toml:"syslog_facility"`
+	Journald   bool   `toml:"journald"` // Also send to the systemd journal
+	maxAge     time.Duration
+}
+
+// Settings for synthesizing events from a local Docker daemon, for hosts
+// that aren't running Sidecar.
+type DockerConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	Endpoint    string `toml:"endpoint"`
+	ClusterName string `toml:"cluster_name"`
+}
+
+// Allow and deny lists for events, as shell globs, e.g. deny_clusters = ["ci-*"]
+type IngestConfig struct {
+	AllowClusters []string `toml:"allow_clusters"`
+	DenyClusters  []string `toml:"deny_clusters"`
+	AllowServices []string `toml:"allow_services"`
+	DenyServices  []string `toml:"deny_services"`
+}
+
+// A scheduled activity report, sent to notifiers by name
+type DigestConfig struct {
+	Name      string   `toml:"name"`
+	Schedule  string   `toml:"schedule"` // Cron-style, e.g. "0 9 * * 1" or "@daily"
+	Timezone  string   `toml:"timezone"`
+	Top       int      `toml:"top"` // How many flapping services and outages to list
+	Notifiers []string `toml:"notifiers"`
+	schedule  *digest.Schedule
+	location  *time.Location
+}
+
+// Label => value for each service, e.g. its owner and tier
+type ServiceLabels map[string]map[string]string
+
+// Name => URL for each service, e.g. its runbook and dashboard
+type ServiceLinks map[string]map[string]string
+
+// Gives the notifications matching a match expression a severity, e.g.
+// level = "critical", match = 'instances_alive == 0 && "tier=1" in labels'
+type SeverityConfig struct {
+	Level string `toml:"level"` // "info", "warning" or "critical"
+	Match string `toml:"match"`
+	rule  *tracker.SeverityRule
+}
+
+// A Lua processing hook, either inline or in a file
+type HookConfig struct {
+	Name    string `toml:"name"`
+	File    string `toml:"file"`
+	Script  string `toml:"script"`
+	Timeout string `toml:"timeout"` // e.g. "250ms"
+	timeout time.Duration
+}
+
+// Settings for keeping copies of the full Sidecar state
+type SnapshotConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	Depth    int    `toml:"depth"`    // How many to keep per cluster
+	Interval string `toml:"interval"` // How far apart to keep them, e.g. "1m"
+	Rebase   int    `toml:"rebase"`   // Keep every Nth whole, the rest as diffs
+	interval time.Duration
+}
+
+// Another superside to fetch recent history from on startup, so a fresh
+// replica isn't blind until events come in
+type BackfillConfig struct {
+	Peer    string `toml:"peer"`    // e.g. "http://superside-1:7779", off when unset
+	Timeout string `toml:"timeout"` // e.g. "30s"
+	timeout time.Duration
+}
+
+// How many Idempotency-Keys on /api/update to remember, for how long, and
+// whether to keep them across restarts (only with --persist)
+type IdempotencyConfig struct {
+	Size    int    `toml:"size"`
+	Ttl     string `toml:"ttl"` // e.g. "24h"
+	Persist bool   `toml:"persist"`
+	ttl     time.Duration
+}
+
+// What /readyz says when a sink like Elasticsearch isn't taking writes
+type ReadinessConfig struct {
+	BrokenSink string `toml:"broken_sink"` // "degraded" (still ready) or "not_ready"
+}
+
+// The public status page. Only the services listed are shown on it, and
+// none turns it off.
+type StatusPageConfig struct {
+	Title    string   `toml:"title"`
+	Services []string `toml:"services"`
+	MaxAge   string   `toml:"max_age"` // How long browsers and CDNs can cache it
+	maxAge   time.Duration
+}
+
+// How far apart transitions in a cluster can be and still share a
+// correlation ID. "0s" turns correlation IDs off.
+type CorrelationConfig struct {
+	Window string `toml:"window"` // e.g. "2m"
+	window time.Duration
+}
+
+// Settings for deciding when a service is flapping
+type FlappingConfig struct {
+	Threshold int    `toml:"threshold"` // Transitions allowed inside the window
+	Window    string `toml:"window"`    // e.g. "10m"
+	window    time.Duration
+}
+
+// Settings for noticing when a cluster stops sending updates
+type WatchdogConfig struct {
+	SilentAfter string `toml:"silent_after"` // e.g. "15m", off when unset
+	silentAfter time.Duration
+}
+
+// Settings for noticing when a cluster changes much faster than usual
+type AnomalyConfig struct {
+	Factor    float64 `toml:"factor"`     // e.g. 5 times the usual rate, off when unset
+	Window    string  `toml:"window"`     // e.g. "1m"
+	MinEvents int     `toml:"min_events"` // Fewer transitions than this are never anomalous
+	window    time.Duration
+}
+
+// Settings for grouping events that arrive together into one notification
+type CoalesceConfig struct {
+	Window  string `toml:"window"`   // e.g. "2s", off when unset
+	MaxSize int    `toml:"max_size"` // Events in a burst before it goes out anyway
+	window  time.Duration
+}
+
+// Settings for distrusting event timestamps from hosts with bad clocks
+type ClockSkewConfig struct {
+	MaxSkew string `toml:"max_skew"` // e.g. "10m", off when unset
+	maxSkew time.Duration
+}
+
+// Settings for the periodic heartbeat sent to listeners and notifiers
+type HeartbeatConfig struct {
+	Interval string `toml:"interval"` // e.g. "1m", off when unset
+	interval time.Duration
+}
+
+// Settings for retrying failed notifier deliveries before giving up on
+// them and moving them to the dead-letter store
+type RetryConfig struct {
+	MaxAttempts int    `toml:"max_attempts"`
+	Backoff     string `toml:"backoff"`     // Before the first retry, then doubled, e.g. "30s"
+	MaxBackoff  string `toml:"max_backoff"` // e.g. "1h"
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// How long stored events are kept, and when they're compacted down to the
+// last transition for each service instance
+type RetentionConfig struct {
+	CompactAfter string `toml:"compact_after"` // e.g. "24h", off when unset
+	TTL          string `toml:"ttl"`           // e.g. "720h", off when unset
+	compactAfter time.Duration
+	ttl          time.Duration
+}
+
+// Settings for sending alerts to a Slack incoming webhook
+type SlackConfig struct {
+	WebhookUrl     string   `toml:"webhook_url"`
+	Channel        string   `toml:"channel"`
+	Username       string   `toml:"username"`
+	DampenFlapping *bool    `toml:"dampen_flapping"` // Defaults to true
+	Regions        []string `toml:"regions"`         // Only alert for these regions
+	RepeatInterval string   `toml:"repeat_interval"` // Re-send unacked failures, e.g. "30m"
+	Template       string   `toml:"template"`        // text/template for the message
+	repeatInterval time.Duration
+}
+
+// Settings for indexing notifications into Elasticsearch or OpenSearch
+type ElasticConfig struct {
+	Url           string `toml:"url"`
+	Index         string `toml:"index"`
+	BatchSize     int    `toml:"batch_size"`
+	FlushInterval string `toml:"flush_interval"` // e.g. "5s"
+	flushInterval time.Duration
+}
+
+// Settings for writing status changes to InfluxDB
+type InfluxConfig struct {
+	Url           string `toml:"url"`
+	Database      string `toml:"database"`
+	Token         string `toml:"token"`
+	BatchSize     int    `toml:"batch_size"`
+	FlushInterval string `toml:"flush_interval"` // e.g. "5s"
+	flushInterval time.Duration
+}
+
+// Settings for inserting events into ClickHouse for analytics. See
+// sinks.CLICKHOUSE_SCHEMA for the table layout.
+type ClickHouseConfig struct {
+	Url           string `toml:"url"` // HTTP interface, e.g. "http://localhost:8123"
+	Table         string `toml:"table"`
+	User          string `toml:"user"`
+	Password      string `toml:"password"`
+	CreateTable   bool   `toml:"create_table"`
+	BatchSize     int    `toml:"batch_size"`
+	FlushInterval string `toml:"flush_interval"` // e.g. "5s"
+	flushInterval time.Duration
+}
+
+// Settings for creating Grafana annotations
+type GrafanaConfig struct {
+	Url         string   `toml:"url"`
+	ApiKey      string   `toml:"api_key"`
+	Transitions []string `toml:"transitions"` // e.g. ["Alive->Unhealthy", "Tombstone"]
+	Deployments *bool    `toml:"deployments"` // Defaults to true
+}
+
+// Settings for reporting deploy markers' outcomes to GitHub Deployments
+type GithubConfig struct {
+	Token   string `toml:"token"`
+	ApiUrl  string `toml:"api_url"` // For GitHub Enterprise
+	Timeout string `toml:"timeout"` // How long a deploy has to become healthy, e.g. "15m"
+	timeout time.Duration
+}
+
+// Settings for sending alerts to Prometheus Alertmanager
+type AlertmanagerConfig struct {
+	Url          string            `toml:"url"`
+	GeneratorUrl string            `toml:"generator_url"` // Link back to this Superside
+	Labels       map[string]string `toml:"labels"`        // Added to every alert
 }
 
 func parseConfig(path string) *Config {
 	var config Config
 	_, err := toml.DecodeFile(path, &config)
 	if err != nil {
-		log.Error("Failed to parse config file: %s", err.Error())
+		log.Errorf("Failed to parse config file: %s", err.Error())
 		os.Exit(1)
 	}
 
@@ -39,22 +333,496 @@ func parseConfig(path string) *Config {
 		config.Superside.BindPort = 7779
 	}
 
-	configureLoggingLevel(config.Superside.LoggingLevel)
+	config.Superside.location, err = time.LoadLocation(config.Superside.Timezone)
+	if err != nil {
+		log.Errorf("Invalid timezone: %s", err.Error())
+		os.Exit(1)
+	}
+
+	if config.Logging == nil {
+		config.Logging = &LoggingConfig{}
+	}
+
+	if config.Logging.Level == "" {
+		config.Logging.Level = config.Superside.LoggingLevel
+	}
+
+	if config.Logging.Format == "" {
+		config.Logging.Format = "text"
+	}
+
+	if config.Logging.MaxSizeMB == 0 {
+		config.Logging.MaxSizeMB = logging.DEFAULT_MAX_SIZE_MB
+	}
+
+	if config.Logging.Facility == "" {
+		config.Logging.Facility = "daemon"
+	}
+
+	if config.Logging.MaxAge != "" {
+		config.Logging.maxAge, err = time.ParseDuration(config.Logging.MaxAge)
+		if err != nil {
+			log.Errorf("Invalid logging max_age: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Websocket == nil {
+		config.Websocket = &WebsocketConfig{}
+	}
+
+	if config.Websocket.ReadBuffer == 0 {
+		config.Websocket.ReadBuffer = server.DEFAULT_WS_READ_BUFFER
+	}
+
+	if config.Websocket.WriteBuffer == 0 {
+		config.Websocket.WriteBuffer = server.DEFAULT_WS_WRITE_BUFFER
+	}
+
+	if config.Docker == nil {
+		config.Docker = &DockerConfig{}
+	}
+
+	if config.Docker.Endpoint == "" {
+		config.Docker.Endpoint = "unix:///var/run/docker.sock"
+	}
+
+	if config.Docker.ClusterName == "" {
+		config.Docker.ClusterName = "default"
+	}
+
+	if config.Ingest == nil {
+		config.Ingest = &IngestConfig{}
+	}
+
+	for i, hook := range config.Hooks {
+		if hook.Name == "" {
+			hook.Name = fmt.Sprintf("hook-%d", i+1)
+		}
+
+		if (hook.File == "") == (hook.Script == "") {
+			log.Errorf("Hook %s needs either a file or a script", hook.Name)
+			os.Exit(1)
+		}
+
+		hook.timeout = hooks.HOOK_TIMEOUT
+		if hook.Timeout != "" {
+			hook.timeout, err = time.ParseDuration(hook.Timeout)
+			if err != nil {
+				log.Errorf("Invalid timeout for hook %s: %s", hook.Name, err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	for _, severity := range config.Severities {
+		severity.rule, err = tracker.NewSeverityRule(severity.Level, severity.Match)
+		if err != nil {
+			log.Errorf("Invalid severity rule: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	for _, report := range config.Digests {
+		if report.Name == "" {
+			report.Name = "Superside digest"
+		}
+
+		if report.Top == 0 {
+			report.Top = digest.DEFAULT_TOP
+		}
+
+		report.schedule, err = digest.ParseSchedule(report.Schedule)
+		if err != nil {
+			log.Errorf("Invalid digest schedule: %s", err.Error())
+			os.Exit(1)
+		}
+
+		// Digests show times in the display timezone unless they have their own
+		report.location = config.Superside.location
+		if report.Timezone != "" {
+			report.location, err = time.LoadLocation(report.Timezone)
+			if err != nil {
+				log.Errorf("Invalid digest timezone: %s", err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	if config.Snapshots == nil {
+		config.Snapshots = &SnapshotConfig{}
+	}
+
+	if config.Snapshots.Interval != "" {
+		config.Snapshots.interval, err = time.ParseDuration(config.Snapshots.Interval)
+		if err != nil {
+			log.Errorf("Invalid snapshot interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Snapshots.Rebase < 0 {
+		log.Errorf("Invalid snapshot rebase: %d", config.Snapshots.Rebase)
+		os.Exit(1)
+	}
+
+	if config.Backfill == nil {
+		config.Backfill = &BackfillConfig{}
+	}
+
+	config.Backfill.timeout = server.DEFAULT_PEER_TIMEOUT
+	if config.Backfill.Timeout != "" {
+		config.Backfill.timeout, err = time.ParseDuration(config.Backfill.Timeout)
+		if err != nil {
+			log.Errorf("Invalid backfill timeout: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Idempotency == nil {
+		config.Idempotency = &IdempotencyConfig{}
+	}
+
+	if config.Idempotency.Size == 0 {
+		config.Idempotency.Size = server.DEFAULT_IDEMPOTENCY_SIZE
+	}
+
+	config.Idempotency.ttl = server.DEFAULT_IDEMPOTENCY_TTL
+	if config.Idempotency.Ttl != "" {
+		config.Idempotency.ttl, err = time.ParseDuration(config.Idempotency.Ttl)
+		if err != nil {
+			log.Errorf("Invalid idempotency ttl: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Readiness == nil {
+		config.Readiness = &ReadinessConfig{}
+	}
+
+	switch config.Readiness.BrokenSink {
+	case "":
+		config.Readiness.BrokenSink = server.BROKEN_SINK_DEGRADED
+	case server.BROKEN_SINK_DEGRADED, server.BROKEN_SINK_NOT_READY:
+	default:
+		log.Errorf("Invalid readiness broken_sink '%s', expected '%s' or '%s'",
+			config.Readiness.BrokenSink, server.BROKEN_SINK_DEGRADED, server.BROKEN_SINK_NOT_READY)
+		os.Exit(1)
+	}
+
+	if config.StatusPage == nil {
+		config.StatusPage = &StatusPageConfig{}
+	}
+
+	config.StatusPage.maxAge = server.DEFAULT_STATUS_MAX_AGE
+	if config.StatusPage.MaxAge != "" {
+		config.StatusPage.maxAge, err = time.ParseDuration(config.StatusPage.MaxAge)
+		if err != nil {
+			log.Errorf("Invalid status page max_age: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Flapping == nil {
+		config.Flapping = &FlappingConfig{}
+	}
+
+	if config.Flapping.Threshold == 0 {
+		config.Flapping.Threshold = tracker.DEFAULT_FLAP_THRESHOLD
+	}
+
+	config.Flapping.window = tracker.DEFAULT_FLAP_WINDOW
+	if config.Flapping.Window != "" {
+		config.Flapping.window, err = time.ParseDuration(config.Flapping.Window)
+		if err != nil {
+			log.Errorf("Invalid flapping window: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Correlation == nil {
+		config.Correlation = &CorrelationConfig{}
+	}
+
+	config.Correlation.window = tracker.DEFAULT_CORRELATION_WINDOW
+	if config.Correlation.Window != "" {
+		config.Correlation.window, err = time.ParseDuration(config.Correlation.Window)
+		if err != nil {
+			log.Errorf("Invalid correlation window: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Watchdog == nil {
+		config.Watchdog = &WatchdogConfig{}
+	}
+
+	if config.Watchdog.SilentAfter != "" {
+		config.Watchdog.silentAfter, err = time.ParseDuration(config.Watchdog.SilentAfter)
+		if err != nil {
+			log.Errorf("Invalid watchdog silent_after: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Anomaly == nil {
+		config.Anomaly = &AnomalyConfig{}
+	}
+
+	if config.Anomaly.Factor < 0 {
+		log.Errorf("Invalid anomaly factor: %v", config.Anomaly.Factor)
+		os.Exit(1)
+	}
+
+	if config.Anomaly.MinEvents == 0 {
+		config.Anomaly.MinEvents = tracker.DEFAULT_ANOMALY_MIN_EVENTS
+	}
+
+	config.Anomaly.window = tracker.DEFAULT_ANOMALY_WINDOW
+	if config.Anomaly.Window != "" {
+		config.Anomaly.window, err = time.ParseDuration(config.Anomaly.Window)
+		if err != nil || config.Anomaly.window <= 0 {
+			log.Errorf("Invalid anomaly window: %s", config.Anomaly.Window)
+			os.Exit(1)
+		}
+	}
+
+	if config.Coalesce == nil {
+		config.Coalesce = &CoalesceConfig{}
+	}
+
+	if config.Coalesce.MaxSize == 0 {
+		config.Coalesce.MaxSize = tracker.DEFAULT_COALESCE_MAX_SIZE
+	}
+
+	if config.Coalesce.Window != "" {
+		config.Coalesce.window, err = time.ParseDuration(config.Coalesce.Window)
+		if err != nil {
+			log.Errorf("Invalid coalesce window: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.ClockSkew == nil {
+		config.ClockSkew = &ClockSkewConfig{}
+	}
+
+	if config.ClockSkew.MaxSkew != "" {
+		config.ClockSkew.maxSkew, err = time.ParseDuration(config.ClockSkew.MaxSkew)
+		if err != nil {
+			log.Errorf("Invalid clock_skew max_skew: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Heartbeat == nil {
+		config.Heartbeat = &HeartbeatConfig{}
+	}
+
+	if config.Heartbeat.Interval != "" {
+		config.Heartbeat.interval, err = time.ParseDuration(config.Heartbeat.Interval)
+		if err != nil {
+			log.Errorf("Invalid heartbeat interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Retention == nil {
+		config.Retention = &RetentionConfig{}
+	}
+
+	if config.Retention.CompactAfter != "" {
+		config.Retention.compactAfter, err = time.ParseDuration(config.Retention.CompactAfter)
+		if err != nil {
+			log.Errorf("Invalid retention compact_after: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Retention.TTL != "" {
+		config.Retention.ttl, err = time.ParseDuration(config.Retention.TTL)
+		if err != nil {
+			log.Errorf("Invalid retention ttl: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Retry == nil {
+		config.Retry = &RetryConfig{}
+	}
+
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry.MaxAttempts = notify.DEFAULT_RETRY_ATTEMPTS
+	}
+
+	config.Retry.backoff = notify.DEFAULT_RETRY_BACKOFF
+	if config.Retry.Backoff != "" {
+		config.Retry.backoff, err = time.ParseDuration(config.Retry.Backoff)
+		if err != nil {
+			log.Errorf("Invalid retry backoff: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	config.Retry.maxBackoff = notify.DEFAULT_MAX_BACKOFF
+	if config.Retry.MaxBackoff != "" {
+		config.Retry.maxBackoff, err = time.ParseDuration(config.Retry.MaxBackoff)
+		if err != nil {
+			log.Errorf("Invalid retry max_backoff: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Slack == nil {
+		config.Slack = &SlackConfig{}
+	}
+
+	if config.Slack.Username == "" {
+		config.Slack.Username = "superside"
+	}
+
+	if config.Slack.DampenFlapping == nil {
+		dampen := true
+		config.Slack.DampenFlapping = &dampen
+	}
+
+	if config.Slack.RepeatInterval != "" {
+		config.Slack.repeatInterval, err = time.ParseDuration(config.Slack.RepeatInterval)
+		if err != nil {
+			log.Errorf("Invalid Slack repeat interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Elastic == nil {
+		config.Elastic = &ElasticConfig{}
+	}
+
+	if config.Elastic.FlushInterval != "" {
+		config.Elastic.flushInterval, err = time.ParseDuration(config.Elastic.FlushInterval)
+		if err != nil {
+			log.Errorf("Invalid Elasticsearch flush interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Influx == nil {
+		config.Influx = &InfluxConfig{}
+	}
+
+	if config.Influx.Database == "" {
+		config.Influx.Database = "superside"
+	}
+
+	if config.Influx.FlushInterval != "" {
+		config.Influx.flushInterval, err = time.ParseDuration(config.Influx.FlushInterval)
+		if err != nil {
+			log.Errorf("Invalid InfluxDB flush interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.ClickHouse == nil {
+		config.ClickHouse = &ClickHouseConfig{}
+	}
+
+	if config.ClickHouse.FlushInterval != "" {
+		config.ClickHouse.flushInterval, err = time.ParseDuration(config.ClickHouse.FlushInterval)
+		if err != nil {
+			log.Errorf("Invalid ClickHouse flush interval: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Grafana == nil {
+		config.Grafana = &GrafanaConfig{}
+	}
+
+	if len(config.Grafana.Transitions) == 0 {
+		config.Grafana.Transitions = []string{"Unhealthy"}
+	}
+
+	if config.Grafana.Deployments == nil {
+		annotate := true
+		config.Grafana.Deployments = &annotate
+	}
+
+	if config.Github == nil {
+		config.Github = &GithubConfig{}
+	}
+
+	if config.Github.ApiUrl == "" {
+		config.Github.ApiUrl = sinks.GITHUB_API_URL
+	}
+
+	config.Github.timeout = sinks.DEFAULT_GITHUB_TIMEOUT
+	if config.Github.Timeout != "" {
+		config.Github.timeout, err = time.ParseDuration(config.Github.Timeout)
+		if err != nil {
+			log.Errorf("Invalid GitHub timeout: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.Alertmanager == nil {
+		config.Alertmanager = &AlertmanagerConfig{}
+	}
 
 	return &config
 }
 
-func configureLoggingLevel(level string) {
-	switch {
-	case len(level) == 0:
-		log.SetLevel(log.InfoLevel)
-	case level == "info":
-		log.SetLevel(log.InfoLevel)
-	case level == "warn":
-		log.SetLevel(log.WarnLevel)
-	case level == "error":
-		log.SetLevel(log.ErrorLevel)
-	case level == "debug":
-		log.SetLevel(log.DebugLevel)
+// Set up logrus from the logging config. Exits on settings that don't make
+// sense, as we'd otherwise be logging somewhere nobody is looking.
+func configureLogging(config *LoggingConfig) {
+	level := log.InfoLevel
+	if config.Level != "" {
+		var err error
+		level, err = log.ParseLevel(config.Level)
+		if err != nil {
+			log.Errorf("Invalid logging level: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+	log.SetLevel(level)
+
+	switch config.Format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.Errorf("Invalid logging format '%s', expected 'text' or 'json'", config.Format)
+		os.Exit(1)
+	}
+
+	if config.Syslog != "" {
+		hook, err := logging.NewSyslogHook(config.Syslog, config.Facility)
+		if err != nil {
+			log.Errorf("Unable to log to syslog: %s", err.Error())
+			os.Exit(1)
+		}
+		log.AddHook(hook)
+	}
+
+	if config.Journald {
+		hook, err := logging.NewJournaldHook()
+		if err != nil {
+			log.Errorf("Unable to log to journald: %s", err.Error())
+			os.Exit(1)
+		}
+		log.AddHook(hook)
+	}
+
+	if config.File == "" {
+		return
+	}
+
+	file, err := logging.NewRotatingFile(
+		config.File, int64(config.MaxSizeMB)*1024*1024, config.maxAge, config.MaxBackups,
+	)
+	if err != nil {
+		log.Errorf("Unable to open log file: %s", err.Error())
+		os.Exit(1)
 	}
+	log.SetOutput(file)
 }
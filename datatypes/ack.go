@@ -0,0 +1,54 @@
+package datatypes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// What /api/update answers with when acknowledgements are turned on: the
+// ID the event will be stored under, and a signature over that and the body
+// we received, so the sender can prove the update got to us intact and
+// spot a collector in the middle that isn't us.
+type UpdateAck struct {
+	Message    string
+	EventID    string
+	ReceivedAt time.Time
+	BodySHA256 string // Hex encoded, of the request body as we received it
+	Signature  string // Hex encoded HMAC-SHA256 of the fields above
+}
+
+func NewUpdateAck(eventID string, receivedAt time.Time, body []byte, key []byte) *UpdateAck {
+	digest := sha256.Sum256(body)
+	ack := &UpdateAck{
+		Message:    "OK",
+		EventID:    eventID,
+		ReceivedAt: receivedAt,
+		BodySHA256: hex.EncodeToString(digest[:]),
+	}
+	ack.Signature = ack.sign(key)
+
+	return ack
+}
+
+// Everything the signature covers, one per line
+func (a *UpdateAck) sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join([]string{
+		a.EventID, a.ReceivedAt.UTC().Format(time.RFC3339Nano), a.BodySHA256,
+	}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Was this acknowledgement signed with the key, for exactly this body?
+func (a *UpdateAck) Verify(key []byte, body []byte) bool {
+	digest := sha256.Sum256(body)
+	if !hmac.Equal([]byte(a.BodySHA256), []byte(hex.EncodeToString(digest[:]))) {
+		return false
+	}
+
+	return hmac.Equal([]byte(a.Signature), []byte(a.sign(key)))
+}
@@ -0,0 +1,30 @@
+package datatypes
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_UpdateAck(t *testing.T) {
+	Convey("Update acknowledgements", t, func() {
+		body := []byte(`{"ChangeEvent": {}}`)
+		ack := NewUpdateAck("verdun", time.Date(1916, time.February, 21, 7, 0, 0, 0, time.UTC), body, []byte("joffre"))
+
+		Convey("Verify with the key and body they were signed for", func() {
+			So(ack.Message, ShouldEqual, "OK")
+			So(ack.Verify([]byte("joffre"), body), ShouldBeTrue)
+		})
+
+		Convey("Don't verify with another key or body", func() {
+			So(ack.Verify([]byte("falkenhayn"), body), ShouldBeFalse)
+			So(ack.Verify([]byte("joffre"), []byte(`{}`)), ShouldBeFalse)
+		})
+
+		Convey("Don't verify once they're tampered with", func() {
+			ack.EventID = "somme"
+			So(ack.Verify([]byte("joffre"), body), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,35 @@
+package datatypes
+
+import (
+	"time"
+)
+
+// What the last-seen endpoint reports for each cluster
+type ClusterLastSeen struct {
+	ClusterName    string
+	LastSeen       time.Time
+	EventsLastHour int
+	Silent         bool
+}
+
+// The last thing we heard about one instance of a service
+type InstanceView struct {
+	ID         string
+	Hostname   string
+	Image      string
+	Status     string
+	LastChange time.Time
+}
+
+type ServiceView struct {
+	Name      string
+	Instances []InstanceView
+}
+
+// What we think a cluster looks like right now, folded from its events
+type ClusterView struct {
+	ClusterName string
+	Hosts       []string
+	Services    []ServiceView
+	LastChange  time.Time
+}
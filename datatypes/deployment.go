@@ -1,7 +1,6 @@
 package datatypes
 
 import (
-	"strings"
 	"time"
 
 	"github.com/satori/go.uuid"
@@ -20,6 +19,10 @@ type Deployment struct {
 	Image       string
 	ClusterName string
 	Hostnames   []string
+	Actor       string `json:",omitempty"` // Markers only
+	Source      string `json:",omitempty"` // MARKER_SOURCE, or empty when inferred
+	GithubRepo  string `json:",omitempty"` // Markers only, "owner/repo"
+	GithubID    int64  `json:",omitempty"` // Markers only, the GitHub Deployment's ID
 }
 
 func (d *Deployment) Matches(other *Deployment) bool {
@@ -56,13 +59,14 @@ func DeploymentFromNotification(notice *Notification) *Deployment {
 	}
 
 	svc := evt.Service
+	_, version := ParseImage(svc.Image)
 
 	return &Deployment{
 		ID:          uuid.NewV4().String(),
 		Name:        svc.Name,
 		StartTime:   evt.Time,
 		EndTime:     evt.Time,
-		Version:     strings.Split(evt.Service.Image, ":")[1],
+		Version:     version,
 		Image:       evt.Service.Image,
 		ClusterName: notice.ClusterName,
 		Hostnames:   []string{evt.Service.Hostname},
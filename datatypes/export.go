@@ -0,0 +1,58 @@
+package datatypes
+
+import (
+	"strings"
+	"time"
+)
+
+// Column names for the flattened form of a notification used in exports
+var CSV_HEADER = []string{
+	"ID", "Type", "Time", "ReceivedAt", "Cluster", "Service", "ServiceID",
+	"Hostname", "Image", "PreviousStatus", "Status", "Flapping", "Suppressed",
+	"AckedBy", "Resolved", "Annotations",
+}
+
+func formatTime(when time.Time) string {
+	if when.IsZero() {
+		return ""
+	}
+	return when.UTC().Format(time.RFC3339)
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// Flatten a notification into one row matching CSV_HEADER. Annotations are
+// joined together with "; ".
+func (n *Notification) CSVRecord() []string {
+	var evtTime, name, id, hostname, image, previous, status string
+	if n.Event != nil {
+		svc := n.Event.Service
+		evtTime = formatTime(n.Event.Time)
+		name, id, hostname, image = svc.Name, svc.ID, svc.Hostname, svc.Image
+		previous = StatusString(n.Event.PreviousStatus)
+		status = StatusString(svc.Status)
+	}
+
+	var ackedBy string
+	var resolved bool
+	if n.Ack != nil {
+		ackedBy, resolved = n.Ack.User, n.Ack.Resolved
+	}
+
+	notes := make([]string, 0, len(n.Annotations))
+	for _, annotation := range n.Annotations {
+		notes = append(notes, annotation.Text)
+	}
+
+	return []string{
+		n.ID, n.Type, evtTime, formatTime(n.ReceivedAt), n.ClusterName, name, id,
+		hostname, image, previous, status, formatBool(n.Flapping),
+		formatBool(n.Suppressed), ackedBy, formatBool(resolved),
+		strings.Join(notes, "; "),
+	}
+}
@@ -0,0 +1,177 @@
+package datatypes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/newrelic/sidecar/service"
+)
+
+const (
+	ANY_STATUS = -1
+)
+
+type transitionMatch struct {
+	from int
+	to   int
+}
+
+// Matches service events by the kind of status change they represent, e.g.
+// only Alive->Unhealthy, or anything that ended in a Tombstone. An empty
+// filter matches everything. Notifications that aren't service events
+// (flapping notices, acks) are never filtered out.
+type TransitionFilter struct {
+	matches []transitionMatch
+}
+
+// Look up a status by name, ignoring case. "*" or "" means any status.
+func ParseStatus(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "*" {
+		return ANY_STATUS, nil
+	}
+
+	for _, status := range []int{service.ALIVE, service.TOMBSTONE, service.UNHEALTHY, service.UNKNOWN, DRAINING} {
+		if strings.EqualFold(name, StatusString(status)) {
+			return status, nil
+		}
+	}
+
+	return ANY_STATUS, fmt.Errorf("Unknown status '%s'", name)
+}
+
+// Build a filter from specs like "Alive->Unhealthy", "ALIVE→UNHEALTHY",
+// "*->Tombstone" or just "Tombstone" (meaning anything that changed to it).
+// Each spec may also be a comma-separated list.
+func ParseTransitionFilter(specs []string) (*TransitionFilter, error) {
+	filter := &TransitionFilter{}
+
+	for _, spec := range specs {
+		for _, part := range strings.Split(spec, ",") {
+			part = strings.TrimSpace(strings.Replace(part, "→", "->", -1))
+			if part == "" {
+				continue
+			}
+
+			from, to := "*", part
+			if pieces := strings.SplitN(part, "->", 2); len(pieces) == 2 {
+				from, to = pieces[0], pieces[1]
+			}
+
+			fromStatus, err := ParseStatus(from)
+			if err != nil {
+				return nil, err
+			}
+
+			toStatus, err := ParseStatus(to)
+			if err != nil {
+				return nil, err
+			}
+
+			filter.matches = append(filter.matches, transitionMatch{fromStatus, toStatus})
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *TransitionFilter) Matches(notice *Notification) bool {
+	if f == nil || len(f.matches) == 0 {
+		return true
+	}
+
+	if notice.Type != SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return true
+	}
+
+	for _, match := range f.matches {
+		if (match.from == ANY_STATUS || match.from == notice.Event.PreviousStatus) &&
+			(match.to == ANY_STATUS || match.to == notice.Event.Service.Status) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The filters supported by the state endpoints and websocket subscriptions
+type EventFilter struct {
+	Transitions *TransitionFilter
+	Region      string
+	Severity    string // The least severe notifications to match
+}
+
+// Build a filter from query parameters: "transition" (repeatable, see
+// ParseTransitionFilter), "region" and "severity", which matches that
+// severity or worse
+func ParseEventFilter(query url.Values) (*EventFilter, error) {
+	transitions, err := ParseTransitionFilter(query["transition"])
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &EventFilter{Transitions: transitions, Region: query.Get("region")}
+	if query.Get("severity") != "" {
+		filter.Severity, err = ParseSeverity(query.Get("severity"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *EventFilter) Matches(notice *Notification) bool {
+	if f == nil {
+		return true
+	}
+
+	// A burst matches if anything in it does
+	if notice.Type == BURST_NOTICE {
+		for _, member := range notice.Burst {
+			if f.Matches(member) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if f.Region != "" && notice.Region != f.Region {
+		return false
+	}
+
+	if f.Severity != "" && !notice.SeverityAtLeast(f.Severity) {
+		return false
+	}
+
+	return f.Transitions.Matches(notice)
+}
+
+// Return only the notifications that match the filter
+func (f *EventFilter) Filter(notices []Notification) []Notification {
+	filtered := make([]Notification, 0, len(notices))
+	for i := range notices {
+		if f.Matches(&notices[i]) {
+			filtered = append(filtered, notices[i])
+		}
+	}
+
+	return filtered
+}
+
+// Return only the notifications that match the filter
+func (f *TransitionFilter) Filter(notices []Notification) []Notification {
+	if f == nil || len(f.matches) == 0 {
+		return notices
+	}
+
+	filtered := make([]Notification, 0, len(notices))
+	for i := range notices {
+		if f.Matches(&notices[i]) {
+			filtered = append(filtered, notices[i])
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,109 @@
+package datatypes
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TransitionFilter(t *testing.T) {
+	Convey("TransitionFilter", t, func() {
+		change := func(from int, to int) *Notification {
+			return &Notification{
+				Type: SERVICE_EVENT_NOTICE,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: "verdun", Status: to},
+					PreviousStatus: from,
+				},
+			}
+		}
+
+		failed := change(service.ALIVE, service.UNHEALTHY)
+		recovered := change(service.UNHEALTHY, service.ALIVE)
+		died := change(service.ALIVE, service.TOMBSTONE)
+
+		Convey("Matches specific transitions in either arrow style", func() {
+			for _, spec := range []string{"Alive->Unhealthy", "ALIVE→UNHEALTHY"} {
+				filter, err := ParseTransitionFilter([]string{spec})
+				So(err, ShouldBeNil)
+				So(filter.Matches(failed), ShouldBeTrue)
+				So(filter.Matches(recovered), ShouldBeFalse)
+			}
+		})
+
+		Convey("Treats a bare status as the state changed to", func() {
+			filter, _ := ParseTransitionFilter([]string{"tombstone"})
+			So(filter.Matches(died), ShouldBeTrue)
+			So(filter.Matches(failed), ShouldBeFalse)
+		})
+
+		Convey("Knows about draining", func() {
+			filter, err := ParseTransitionFilter([]string{"Alive->Draining"})
+			So(err, ShouldBeNil)
+			So(filter.Matches(change(service.ALIVE, DRAINING)), ShouldBeTrue)
+			So(filter.Matches(died), ShouldBeFalse)
+		})
+
+		Convey("Accepts several transitions", func() {
+			filter, _ := ParseTransitionFilter([]string{"Alive->Unhealthy,*->Tombstone"})
+			notices := filter.Filter([]Notification{*failed, *recovered, *died})
+			So(len(notices), ShouldEqual, 2)
+			So(notices[1].Event.Service.Status, ShouldEqual, service.TOMBSTONE)
+		})
+
+		Convey("Lets everything through when empty", func() {
+			filter, _ := ParseTransitionFilter(nil)
+			So(filter.Matches(recovered), ShouldBeTrue)
+		})
+
+		Convey("Doesn't filter other kinds of notice", func() {
+			filter, _ := ParseTransitionFilter([]string{"Tombstone"})
+			So(filter.Matches(&Notification{Type: FLAPPING_NOTICE}), ShouldBeTrue)
+		})
+
+		Convey("Combines with a region in an EventFilter", func() {
+			filter, err := ParseEventFilter(url.Values{
+				"transition": {"Unhealthy"}, "region": {"western-front"},
+			})
+			So(err, ShouldBeNil)
+
+			So(filter.Matches(failed), ShouldBeFalse)
+			failed.Region = "western-front"
+			So(filter.Matches(failed), ShouldBeTrue)
+			recovered.Region = "western-front"
+			So(filter.Matches(recovered), ShouldBeFalse)
+		})
+
+		Convey("Matches a severity or worse in an EventFilter", func() {
+			filter, err := ParseEventFilter(url.Values{"severity": {"Warning"}})
+			So(err, ShouldBeNil)
+
+			So(filter.Matches(failed), ShouldBeFalse) // Not classified
+			failed.Severity = SEVERITY_WARNING
+			So(filter.Matches(failed), ShouldBeTrue)
+			failed.Severity = SEVERITY_CRITICAL
+			So(filter.Matches(failed), ShouldBeTrue)
+			recovered.Severity = SEVERITY_INFO
+			So(filter.Matches(recovered), ShouldBeFalse)
+
+			_, err = ParseEventFilter(url.Values{"severity": {"apocalyptic"}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Matches a burst if anything in it matches", func() {
+			filter, err := ParseEventFilter(url.Values{"transition": {"Unhealthy"}})
+			So(err, ShouldBeNil)
+
+			So(filter.Matches(&Notification{Type: BURST_NOTICE, Burst: []*Notification{recovered}}), ShouldBeFalse)
+			So(filter.Matches(&Notification{Type: BURST_NOTICE, Burst: []*Notification{recovered, failed}}), ShouldBeTrue)
+		})
+
+		Convey("Rejects unknown statuses", func() {
+			_, err := ParseTransitionFilter([]string{"Alive->Zombie"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
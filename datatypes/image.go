@@ -0,0 +1,34 @@
+package datatypes
+
+import (
+	"strings"
+	"time"
+)
+
+// Split a container image like "registry:5000/team/api:1.2.3" or
+// "api@sha256:abcd" into the repository and the version. Images without a
+// tag are "latest", as far as Docker is concerned.
+func ParseImage(image string) (string, string) {
+	if at := strings.Index(image, "@"); at >= 0 {
+		return image[:at], image[at+1:]
+	}
+
+	// A colon before the last slash is a registry port, not a tag
+	colon := strings.LastIndex(image, ":")
+	if colon < 0 || colon < strings.LastIndex(image, "/") {
+		return image, "latest"
+	}
+
+	return image[:colon], image[colon+1:]
+}
+
+// A service starting to run a version it wasn't running before
+type VersionChange struct {
+	ClusterName     string
+	Service         string
+	Hostname        string
+	Image           string
+	Version         string
+	PreviousVersion string
+	Time            time.Time
+}
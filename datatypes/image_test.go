@@ -0,0 +1,36 @@
+package datatypes
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseImage(t *testing.T) {
+	Convey("ParseImage()", t, func() {
+		parse := func(image string) []string {
+			repository, version := ParseImage(image)
+			return []string{repository, version}
+		}
+
+		Convey("Splits off the tag", func() {
+			So(parse("artillery:1.2.3"), ShouldResemble, []string{"artillery", "1.2.3"})
+			So(parse("army/artillery:1.2.3"), ShouldResemble, []string{"army/artillery", "1.2.3"})
+		})
+
+		Convey("Doesn't mistake a registry port for a tag", func() {
+			So(parse("registry:5000/army/artillery:1.2"), ShouldResemble,
+				[]string{"registry:5000/army/artillery", "1.2"})
+			So(parse("registry:5000/army/artillery"), ShouldResemble,
+				[]string{"registry:5000/army/artillery", "latest"})
+		})
+
+		Convey("Treats untagged images as latest", func() {
+			So(parse("artillery"), ShouldResemble, []string{"artillery", "latest"})
+		})
+
+		Convey("Uses the digest when there is one", func() {
+			So(parse("artillery@sha256:abcd"), ShouldResemble, []string{"artillery", "sha256:abcd"})
+		})
+	})
+}
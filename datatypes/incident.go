@@ -0,0 +1,33 @@
+package datatypes
+
+import (
+	"time"
+)
+
+const (
+	INCIDENT_OPEN         = "open"
+	INCIDENT_ACKNOWLEDGED = "acknowledged" // Someone is on it
+	INCIDENT_RESOLVED     = "resolved"     // Everything recovered, or someone said so
+)
+
+// A group of correlated failures in a cluster, which is what a human wants
+// to hear about rather than each event in it. It stays open until someone
+// acknowledges it and is resolved once all of its instances recover, or
+// when someone resolves it by hand.
+type Incident struct {
+	ID          string // The CorrelationID of the burst that opened it
+	ClusterName string
+	Status      string // One of the INCIDENT_ statuses
+	Severity    string // The worst of its events
+	Services    []string
+	EventIDs    []string
+	Unhealthy   int // Instances still unhealthy
+	OpenedAt    time.Time
+	UpdatedAt   time.Time
+	ResolvedAt  time.Time        `json:",omitempty"`
+	Ack         *Acknowledgement `json:",omitempty"`
+}
+
+func (i *Incident) IsResolved() bool {
+	return i.Status == INCIDENT_RESOLVED
+}
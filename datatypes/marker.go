@@ -0,0 +1,56 @@
+package datatypes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	MARKER_SOURCE = "marker" // Posted by a CI pipeline rather than inferred
+)
+
+// A deploy or release announced by a CI pipeline, e.g.
+// {"Service": "api", "Version": "1.2.3", "Actor": "jenkins"}
+type Marker struct {
+	Service     string
+	Version     string
+	Actor       string    `json:",omitempty"` // Who or what did the deploy
+	ClusterName string    `json:",omitempty"` // Empty for every cluster
+	Image       string    `json:",omitempty"`
+	Time        time.Time // Defaults to when we receive it
+	GithubRepo  string    `json:",omitempty"` // "owner/repo", to report back to GitHub
+	GithubID    int64     `json:",omitempty"` // The GitHub Deployment's ID
+}
+
+func (m *Marker) Validate() error {
+	if m.Service == "" || m.Version == "" {
+		return errors.New("A marker needs a Service and a Version")
+	}
+
+	if (m.GithubRepo == "") != (m.GithubID == 0) {
+		return errors.New("A marker needs both GithubRepo and GithubID, or neither")
+	}
+
+	return nil
+}
+
+// Turn the marker into a Deployment so it shows up alongside the ones we
+// work out from Sidecar's events
+func (m *Marker) Deployment() *Deployment {
+	return &Deployment{
+		ID:          uuid.NewV4().String(),
+		Name:        m.Service,
+		StartTime:   m.Time,
+		EndTime:     m.Time,
+		Version:     m.Version,
+		Image:       m.Image,
+		ClusterName: m.ClusterName,
+		Hostnames:   []string{},
+		Actor:       m.Actor,
+		Source:      MARKER_SOURCE,
+		GithubRepo:  m.GithubRepo,
+		GithubID:    m.GithubID,
+	}
+}
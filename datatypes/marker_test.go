@@ -0,0 +1,36 @@
+package datatypes
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Marker(t *testing.T) {
+	Convey("Marker", t, func() {
+		marker := &Marker{
+			Service: "artillery", Version: "1.2.3", Actor: "jenkins",
+			Time: time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC),
+		}
+
+		Convey("Needs a service and a version", func() {
+			So(marker.Validate(), ShouldBeNil)
+			So((&Marker{Service: "artillery"}).Validate(), ShouldNotBeNil)
+			So((&Marker{Version: "1.2.3"}).Validate(), ShouldNotBeNil)
+			So((&Marker{Service: "artillery", Version: "1.2.3", GithubID: 1}).Validate(), ShouldNotBeNil)
+		})
+
+		Convey("Becomes a deployment", func() {
+			deploy := marker.Deployment()
+
+			So(deploy.ID, ShouldNotBeEmpty)
+			So(deploy.Name, ShouldEqual, "artillery")
+			So(deploy.Version, ShouldEqual, "1.2.3")
+			So(deploy.Actor, ShouldEqual, "jenkins")
+			So(deploy.Source, ShouldEqual, MARKER_SOURCE)
+			So(deploy.StartTime, ShouldResemble, marker.Time)
+			So(deploy.EndTime, ShouldResemble, marker.Time)
+		})
+	})
+}
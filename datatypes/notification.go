@@ -1,17 +1,242 @@
 package datatypes
 
 import (
+	"sort"
+	"time"
+
 	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	SERVICE_EVENT_NOTICE   = "ServiceEvent"
+	FLAPPING_NOTICE        = "Flapping"
+	STABILIZED_NOTICE      = "Stabilized"     // A flapping service has settled down
+	ACK_NOTICE             = "Acknowledged"   // Someone acked or resolved a failure
+	DIGEST_NOTICE          = "Digest"         // Notifications held back during quiet hours
+	REPORT_NOTICE          = "Report"         // A scheduled summary of activity
+	CLUSTER_SILENT_NOTICE  = "ClusterSilent"  // A cluster stopped sending us updates
+	CLUSTER_RESUMED_NOTICE = "ClusterResumed" // A silent cluster is back
+	HEARTBEAT_NOTICE       = "Heartbeat"      // Sent periodically to show the pipeline works
+	DEPLOY_NOTICE          = "Deploy"         // A service started running a new version
+	INCIDENT_NOTICE        = "Incident"       // An incident opened, was acknowledged or resolved
+	ANOMALY_NOTICE         = "Anomaly"        // A cluster is changing much faster than usual
+	BURST_NOTICE           = "Burst"          // Events that arrived together, coalesced into one
+	CLOCK_SKEW_NOTICE      = "ClockSkew"      // A host's event timestamps are too far off to trust
+
+	ALERTMANAGER_SOURCE = "alertmanager" // Converted from an Alertmanager webhook
+	CLOUDEVENTS_SOURCE  = "cloudevents"  // A superside notification received as a CloudEvent
+	SUPERSIDE_SOURCE    = "superside"    // Generated by superside itself
+	INJECTED_SOURCE     = "injected"     // Made up through the admin API for testing
+
+	// Newer Sidecars mark services DRAINING while their host is taken out for
+	// maintenance. The Sidecar we build against predates it.
+	DRAINING = service.UNKNOWN + 1
 )
 
 type Notification struct {
-	Event       *catalog.ChangeEvent
+	ID                  string
+	Type                string
+	Event               *catalog.ChangeEvent
+	ClusterName         string
+	OriginalClusterName string            `json:",omitempty"` // What Sidecar called the cluster, if aliased
+	Region              string            `json:",omitempty"` // The region the cluster is in, if any
+	PossibleImpact      []string          `json:",omitempty"` // Dependent services that may be affected
+	Flapping            bool              `json:",omitempty"` // Is this service currently flapping?
+	Flap                *FlapStatus       `json:",omitempty"` // FLAPPING_ and STABILIZED_NOTICEs only
+	Suppressed          bool              `json:",omitempty"` // Matched a silence, so nobody gets paged
+	Draining            bool              `json:",omitempty"` // The host is draining for maintenance, so nobody gets paged
+	SilenceID           string            `json:",omitempty"`
+	CorrelationID       string            `json:",omitempty"` // Shared by a burst of transitions in the cluster
+	Severity            string            `json:",omitempty"` // One of the SEVERITY_ levels, set by the Classifier
+	Labels              map[string]string `json:",omitempty"` // The service's metadata, e.g. owner or tier
+	Links               map[string]string `json:",omitempty"` // The service's runbook, dashboards etc., name => URL
+	ReceivedAt          time.Time         // When superside received the event
+	IngestLatency       time.Duration     // ReceivedAt minus the event's own timestamp
+	TimeOffset          string            `json:",omitempty"` // The event's UTC offset when it arrived, e.g. "+02:00", if not UTC
+	ClockSkew           time.Duration     `json:",omitempty"` // How far off the event's timestamp was, if too far to use it
+	Annotations         []Annotation      `json:",omitempty"`
+	Ack                 *Acknowledgement  `json:",omitempty"`
+	Source              string            `json:",omitempty"` // Where it came from, if not Sidecar
+	Escalated           bool              `json:",omitempty"` // Unresolved for too long, sent to the next route
+	Digest              []*Notification   `json:",omitempty"` // DIGEST_NOTICEs only
+	Report              *DigestReport     `json:",omitempty"` // REPORT_NOTICEs only
+	Stale               *StaleCluster     `json:",omitempty"` // CLUSTER_SILENT_ and CLUSTER_RESUMED_NOTICEs only
+	Heartbeat           *Heartbeat        `json:",omitempty"` // HEARTBEAT_NOTICEs only
+	Deploy              *VersionChange    `json:",omitempty"` // DEPLOY_NOTICEs only
+	Incident            *Incident         `json:",omitempty"` // INCIDENT_NOTICEs only
+	Anomaly             *Anomaly          `json:",omitempty"` // ANOMALY_NOTICEs only
+	Burst               []*Notification   `json:",omitempty"` // BURST_NOTICEs only
+	SkewedHost          *SkewedHost       `json:",omitempty"` // CLOCK_SKEW_NOTICEs only
+}
+
+// Records who picked up a failure and whether they consider it resolved
+type Acknowledgement struct {
+	User     string
+	Note     string
+	Time     time.Time
+	Resolved bool
+}
+
+// Like service.StatusString, but knows about DRAINING
+func StatusString(status int) string {
+	if status == DRAINING {
+		return "Draining"
+	}
+
+	return service.StatusString(status)
+}
+
+// Is this an event that someone should look at and acknowledge?
+func (n *Notification) IsFailure() bool {
+	return n.Type == SERVICE_EVENT_NOTICE && n.Event != nil &&
+		n.Event.Service.Status == service.UNHEALTHY
+}
+
+// Should this jump the queue during an event storm? Whatever was classified
+// as critical does, the rest is routine. Silenced and draining events never
+// page anyone, so they wait with the rest, and so does a burst of them.
+func (n *Notification) IsCritical() bool {
+	if n.Suppressed || n.Draining {
+		return false
+	}
+
+	if n.Type == BURST_NOTICE {
+		for _, notice := range n.Burst {
+			if notice.IsCritical() {
+				return true
+			}
+		}
+		return false
+	}
+
+	return n.SeverityAtLeast(SEVERITY_CRITICAL)
+}
+
+// Put the event's timestamps in UTC, whatever the host that sent it was
+// set to, keeping the offset it came with in TimeOffset
+func (n *Notification) NormalizeTimes() {
+	if n.Event == nil {
+		return
+	}
+
+	if _, offset := n.Event.Time.Zone(); offset != 0 {
+		n.TimeOffset = n.Event.Time.Format("-07:00")
+	}
+	n.Event.Time = n.Event.Time.UTC()
+	n.Event.Service.Created = n.Event.Service.Created.UTC()
+	n.Event.Service.Updated = n.Event.Service.Updated.UTC()
+}
+
+// The events in a BURST_NOTICE, or just this one for anything else
+func (n *Notification) Unpack() []*Notification {
+	if n.Type == BURST_NOTICE {
+		return n.Burst
+	}
+
+	return []*Notification{n}
+}
+
+// The service's labels as sorted "key=value" strings
+func (n *Notification) LabelPairs() []string {
+	return sortedPairs(n.Labels)
+}
+
+// The links as sorted "name=url" strings
+func (n *Notification) LinkPairs() []string {
+	return sortedPairs(n.Links)
+}
+
+func sortedPairs(values map[string]string) []string {
+	pairs := make([]string, 0, len(values))
+	for key, value := range values {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
+
+// Describe the status change, e.g. "Alive->Unhealthy"
+func (n *Notification) Transition() string {
+	if n.Event == nil {
+		return ""
+	}
+
+	return StatusString(n.Event.PreviousStatus) + "->" +
+		StatusString(n.Event.Service.Status)
+}
+
+// A note attached to an event by a human or some automation
+type Annotation struct {
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+// Describes a service that is bouncing between healthy and unhealthy
+type FlapStatus struct {
+	ClusterName    string
+	Service        string
+	Transitions    int
+	Window         time.Duration
+	Since          time.Time
+	LastTransition time.Time
+}
+
+// Describes a cluster that has gone quiet for longer than we expect
+type StaleCluster struct {
+	ClusterName string
+	LastSeen    time.Time
+	Timeout     time.Duration
+}
+
+// Describes a window in which a cluster had many more transitions than
+// usual, e.g. a storm of tombstones
+type Anomaly struct {
+	ClusterName string
+	Transitions int
+	Baseline    float64 // The usual transitions per Window
+	Factor      float64
+	Window      time.Duration
+	Statuses    map[string]int // New status => transitions
+}
+
+// The status most of the transitions went to
+func (a *Anomaly) MostlyStatus() string {
+	var most string
+	for status, count := range a.Statuses {
+		if count > a.Statuses[most] || (count == a.Statuses[most] && status < most) {
+			most = status
+		}
+	}
+
+	return most
+}
+
+// Describes a host whose event timestamps are too far from when we
+// received them, most likely because its clock is wrong
+type SkewedHost struct {
 	ClusterName string
+	Hostname    string
+	Skew        time.Duration // How far ahead of our clock it is, negative if behind
+	MaxSkew     time.Duration
+}
+
+// Numbered so that consumers can tell when they've missed one
+type Heartbeat struct {
+	Sequence int64
+	Interval time.Duration
 }
 
 func NotificationFromEvent(evt *catalog.StateChangedEvent) *Notification {
+	change := evt.ChangeEvent // Don't hang on to the whole StateChangedEvent
+
 	return &Notification{
-		Event: &evt.ChangeEvent,
+		ID:          uuid.NewV4().String(),
+		Type:        SERVICE_EVENT_NOTICE,
+		Event:       &change,
 		ClusterName: evt.State.ClusterName,
 	}
 }
@@ -2,8 +2,10 @@ package datatypes
 
 import (
 	"testing"
+	"time"
 
 	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -22,3 +24,122 @@ func Test_NotificationFromEvent(t *testing.T) {
 		So(notice.ClusterName, ShouldEqual, evt.State.ClusterName)
 	})
 }
+
+func Test_NormalizeTimes(t *testing.T) {
+	Convey("NormalizeTimes()", t, func() {
+		paris := time.FixedZone("CET", 3600)
+		when := time.Date(1916, time.February, 21, 8, 15, 0, 0, paris)
+		notice := &Notification{Event: &catalog.ChangeEvent{
+			Service: service.Service{Created: when, Updated: when},
+			Time:    when,
+		}}
+
+		Convey("Puts the timestamps in UTC, keeping the original offset", func() {
+			notice.NormalizeTimes()
+
+			So(notice.Event.Time.Location(), ShouldEqual, time.UTC)
+			So(notice.Event.Time.Hour(), ShouldEqual, 7)
+			So(notice.Event.Time.Equal(when), ShouldBeTrue)
+			So(notice.Event.Service.Updated.Location(), ShouldEqual, time.UTC)
+			So(notice.Event.Service.Created.Location(), ShouldEqual, time.UTC)
+			So(notice.TimeOffset, ShouldEqual, "+01:00")
+		})
+
+		Convey("Leaves the offset off for events already in UTC", func() {
+			notice.Event.Time = when.UTC()
+			notice.NormalizeTimes()
+
+			So(notice.TimeOffset, ShouldBeEmpty)
+		})
+
+		Convey("Leaves notifications without an event alone", func() {
+			heartbeat := &Notification{Type: HEARTBEAT_NOTICE}
+			heartbeat.NormalizeTimes()
+			So(heartbeat.TimeOffset, ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_CSVRecord(t *testing.T) {
+	Convey("CSVRecord() flattens a notification", t, func() {
+		when := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+		notice := &Notification{
+			ID:          "joffre",
+			Type:        SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{
+					ID: "deadbeef", Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY,
+				},
+				PreviousStatus: service.ALIVE,
+				Time:           when,
+			},
+			Ack:         &Acknowledgement{User: "petain", Resolved: true},
+			Annotations: []Annotation{{Text: "ils ne passeront pas"}, {Text: "holding"}},
+		}
+
+		record := notice.CSVRecord()
+
+		So(len(record), ShouldEqual, len(CSV_HEADER))
+		So(record[2], ShouldEqual, "1916-02-21T07:15:00Z")
+		So(record[3], ShouldEqual, "")
+		So(record[9], ShouldEqual, "Alive")
+		So(record[10], ShouldEqual, "Unhealthy")
+		So(record[13], ShouldEqual, "petain")
+		So(record[14], ShouldEqual, "true")
+		So(record[15], ShouldEqual, "ils ne passeront pas; holding")
+	})
+
+	Convey("CSVRecord() copes with notifications without an event", t, func() {
+		So(len((&Notification{Type: FLAPPING_NOTICE}).CSVRecord()), ShouldEqual, len(CSV_HEADER))
+	})
+}
+
+func Test_IsCritical(t *testing.T) {
+	Convey("IsCritical()", t, func() {
+		change := func(previous int, status int, severity string) *Notification {
+			return &Notification{
+				Type:     SERVICE_EVENT_NOTICE,
+				Severity: severity,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: "somme", Status: status},
+					PreviousStatus: previous,
+				},
+			}
+		}
+
+		Convey("Picks out whatever was classified as critical", func() {
+			So(change(service.ALIVE, service.UNHEALTHY, SEVERITY_CRITICAL).IsCritical(), ShouldBeTrue)
+			So(change(service.ALIVE, service.TOMBSTONE, SEVERITY_CRITICAL).IsCritical(), ShouldBeTrue)
+			So(change(service.ALIVE, service.UNHEALTHY, SEVERITY_WARNING).IsCritical(), ShouldBeFalse)
+			So(change(service.ALIVE, service.TOMBSTONE, SEVERITY_INFO).IsCritical(), ShouldBeFalse)
+			So((&Notification{Type: CLUSTER_SILENT_NOTICE, Severity: SEVERITY_CRITICAL}).IsCritical(), ShouldBeTrue)
+		})
+
+		Convey("Leaves unclassified notifications routine", func() {
+			So(change(service.ALIVE, service.UNHEALTHY, "").IsCritical(), ShouldBeFalse)
+			So((&Notification{Type: CLUSTER_SILENT_NOTICE}).IsCritical(), ShouldBeFalse)
+		})
+
+		Convey("Picks out bursts with anything critical in them", func() {
+			recovered := change(service.UNHEALTHY, service.ALIVE, SEVERITY_INFO)
+			burst := &Notification{Type: BURST_NOTICE, Burst: []*Notification{recovered}}
+			So(burst.IsCritical(), ShouldBeFalse)
+
+			burst.Burst = append(burst.Burst, change(service.ALIVE, service.UNHEALTHY, SEVERITY_CRITICAL))
+			So(burst.IsCritical(), ShouldBeTrue)
+			So(burst.Unpack(), ShouldResemble, burst.Burst)
+			So(recovered.Unpack(), ShouldResemble, []*Notification{recovered})
+		})
+
+		Convey("Leaves silenced and draining events routine", func() {
+			silenced := change(service.ALIVE, service.UNHEALTHY, SEVERITY_CRITICAL)
+			silenced.Suppressed = true
+			So(silenced.IsCritical(), ShouldBeFalse)
+
+			draining := change(service.ALIVE, service.TOMBSTONE, SEVERITY_CRITICAL)
+			draining.Draining = true
+			So(draining.IsCritical(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package datatypes
+
+import (
+	"time"
+)
+
+// A summary of what happened between Start and End, sent out as a
+// REPORT_NOTICE on a schedule
+type DigestReport struct {
+	Name           string
+	Start          time.Time
+	End            time.Time
+	Transitions    map[string]int // Cluster => transitions seen
+	TopFlapping    []ServiceCount // Services that started flapping most often
+	LongestOutages []Outage
+}
+
+type ServiceCount struct {
+	ClusterName string
+	Service     string
+	Count       int
+}
+
+// A service instance being unhealthy, from Start for Duration. Ongoing
+// outages hadn't ended by the end of the report.
+type Outage struct {
+	ClusterName string
+	Service     string
+	Hostname    string
+	Start       time.Time
+	Duration    time.Duration
+	Ongoing     bool
+}
+
+// The total transitions across all clusters
+func (r *DigestReport) TotalTransitions() int {
+	total := 0
+	for _, count := range r.Transitions {
+		total += count
+	}
+	return total
+}
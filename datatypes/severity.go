@@ -0,0 +1,42 @@
+package datatypes
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SEVERITY_INFO     = "info"
+	SEVERITY_WARNING  = "warning"
+	SEVERITY_CRITICAL = "critical"
+)
+
+// Worse severities rank higher. Unclassified notifications rank lowest.
+var severityRanks = map[string]int{
+	SEVERITY_INFO:     1,
+	SEVERITY_WARNING:  2,
+	SEVERITY_CRITICAL: 3,
+}
+
+// Look up a severity by name, ignoring case
+func ParseSeverity(name string) (string, error) {
+	severity := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("Unknown severity '%s', expected info, warning or critical", name)
+	}
+
+	return severity, nil
+}
+
+// Is the notification at least as bad as this severity?
+func (n *Notification) SeverityAtLeast(severity string) bool {
+	return severityRanks[n.Severity] >= severityRanks[severity]
+}
+
+// Whichever of the two severities is worse
+func WorseSeverity(a string, b string) string {
+	if severityRanks[b] > severityRanks[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,52 @@
+package datatypes
+
+import (
+	"errors"
+	"time"
+)
+
+// A Silence suppresses alerts for any notification that matches all of its
+// non-empty matchers until it expires. Silenced notifications are still
+// recorded, they just don't page anyone.
+type Silence struct {
+	ID          string
+	ClusterName string
+	Service     string
+	Hostname    string
+	Comment     string
+	CreatedBy   string
+	StartsAt    time.Time
+	ExpiresAt   time.Time
+}
+
+func (s *Silence) Validate() error {
+	if s.ClusterName == "" && s.Service == "" && s.Hostname == "" {
+		return errors.New("A silence needs at least one of ClusterName, Service or Hostname")
+	}
+
+	if s.ExpiresAt.IsZero() {
+		return errors.New("A silence needs an expiry")
+	}
+
+	if !s.StartsAt.IsZero() && !s.ExpiresAt.After(s.StartsAt) {
+		return errors.New("A silence must expire after it starts")
+	}
+
+	return nil
+}
+
+func (s *Silence) IsActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.ExpiresAt)
+}
+
+func (s *Silence) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// Does this silence cover the given cluster, service and host? Empty
+// matchers match anything.
+func (s *Silence) Matches(clusterName string, svcName string, hostname string) bool {
+	return (s.ClusterName == "" || s.ClusterName == clusterName) &&
+		(s.Service == "" || s.Service == svcName) &&
+		(s.Hostname == "" || s.Hostname == hostname)
+}
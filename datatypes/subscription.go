@@ -0,0 +1,28 @@
+package datatypes
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// An external system that wants notifications POSTed to its Url. Match is
+// an optional match expression (see the match package) that limits which
+// notifications it gets.
+type Subscription struct {
+	ID        string
+	Url       string
+	Match     string `json:",omitempty"`
+	Comment   string `json:",omitempty"`
+	CreatedBy string `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+func (s *Subscription) Validate() error {
+	parsed, err := url.Parse(s.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("A subscription needs an http or https Url")
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package digest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+)
+
+const (
+	DEFAULT_TOP = 5
+)
+
+// Watches the notifications go by and, on a schedule, sends a report of
+// the period's activity to the named notifiers: transitions per cluster,
+// the services that flapped most and the longest outages.
+type Generator struct {
+	Name      string
+	Schedule  *Schedule
+	Location  *time.Location
+	Top       int      // How many flapping services and outages to list
+	Notifiers []string // Names of registered notifiers to send it to
+	Registry  *notify.Registry
+
+	start       time.Time
+	transitions map[string]int
+	flapping    map[string]*datatypes.ServiceCount
+	outages     []datatypes.Outage
+	open        map[string]*datatypes.Outage // Instance => outage in progress
+}
+
+func NewGenerator(name string, schedule *Schedule, notifiers ...string) *Generator {
+	generator := &Generator{
+		Name:      name,
+		Schedule:  schedule,
+		Location:  time.UTC,
+		Top:       DEFAULT_TOP,
+		Notifiers: notifiers,
+		Registry:  notify.DefaultRegistry,
+		open:      make(map[string]*datatypes.Outage, 10),
+	}
+	generator.reset(time.Now().UTC())
+
+	return generator
+}
+
+func (g *Generator) reset(now time.Time) {
+	g.start = now
+	g.transitions = make(map[string]int, 10)
+	g.flapping = make(map[string]*datatypes.ServiceCount, 10)
+	g.outages = nil
+}
+
+// Add a notification to the current period
+func (g *Generator) Record(notice *datatypes.Notification) {
+	switch notice.Type {
+	case datatypes.FLAPPING_NOTICE:
+		key := notice.ClusterName + "/" + notice.Flap.Service
+		if _, ok := g.flapping[key]; !ok {
+			g.flapping[key] = &datatypes.ServiceCount{
+				ClusterName: notice.ClusterName, Service: notice.Flap.Service,
+			}
+		}
+		g.flapping[key].Count += 1
+
+	case datatypes.SERVICE_EVENT_NOTICE:
+		svc := notice.Event.Service
+		if notice.Event.PreviousStatus != svc.Status {
+			g.transitions[notice.ClusterName] += 1
+		}
+
+		key := notice.ClusterName + "/" + svc.Hostname + "/" + svc.ID
+		outage, open := g.open[key]
+
+		switch {
+		case svc.Status == service.UNHEALTHY && !open:
+			g.open[key] = &datatypes.Outage{
+				ClusterName: notice.ClusterName,
+				Service:     svc.Name,
+				Hostname:    svc.Hostname,
+				Start:       notice.Event.Time,
+			}
+		case svc.Status != service.UNHEALTHY && open:
+			delete(g.open, key)
+			outage.Duration = notice.Event.Time.Sub(outage.Start)
+			g.outages = g.longest(append(g.outages, *outage))
+		}
+	}
+}
+
+// Sort outages longest first and keep the top few
+func (g *Generator) longest(outages []datatypes.Outage) []datatypes.Outage {
+	sort.Slice(outages, func(i, j int) bool {
+		return outages[i].Duration > outages[j].Duration
+	})
+
+	if len(outages) > g.Top {
+		outages = outages[:g.Top]
+	}
+	return outages
+}
+
+// Summarize the period up to now and start a new one
+func (g *Generator) Report(now time.Time) *datatypes.DigestReport {
+	report := &datatypes.DigestReport{
+		Name:        g.Name,
+		Start:       g.start.In(g.Location),
+		End:         now.In(g.Location),
+		Transitions: g.transitions,
+	}
+
+	for _, count := range g.flapping {
+		report.TopFlapping = append(report.TopFlapping, *count)
+	}
+	sort.Slice(report.TopFlapping, func(i, j int) bool {
+		if report.TopFlapping[i].Count == report.TopFlapping[j].Count {
+			return report.TopFlapping[i].Service < report.TopFlapping[j].Service
+		}
+		return report.TopFlapping[i].Count > report.TopFlapping[j].Count
+	})
+	if len(report.TopFlapping) > g.Top {
+		report.TopFlapping = report.TopFlapping[:g.Top]
+	}
+
+	outages := append([]datatypes.Outage{}, g.outages...)
+	for _, outage := range g.open {
+		ongoing := *outage
+		ongoing.Duration = now.Sub(outage.Start)
+		ongoing.Ongoing = true
+		outages = append(outages, ongoing)
+	}
+	report.LongestOutages = g.longest(outages)
+
+	g.reset(now)
+	return report
+}
+
+func (g *Generator) send(report *datatypes.DigestReport) {
+	notice := &datatypes.Notification{
+		Type:       datatypes.REPORT_NOTICE,
+		ReceivedAt: report.End,
+		Report:     report,
+	}
+
+	for _, name := range g.Notifiers {
+		notifier := g.Registry.Get(name)
+		if notifier == nil {
+			log.Errorf("Can't send %s to unknown notifier '%s'", g.Name, name)
+			continue
+		}
+
+		err := notifier.Deliver(context.Background(), notice)
+		if err != nil {
+			log.Errorf("Unable to send %s via %s: %s", g.Name, name, err.Error())
+		}
+	}
+}
+
+// Loop over the notifications until the channel is closed, sending a
+// report whenever the schedule says to
+func (g *Generator) Run(notices chan *datatypes.Notification) {
+	for {
+		now := time.Now().In(g.Location)
+		next := g.Schedule.Next(now)
+		if next.IsZero() {
+			log.Errorf("Schedule '%s' for %s never fires", g.Schedule.Spec, g.Name)
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+
+	waiting:
+		for {
+			select {
+			case notice, ok := <-notices:
+				if !ok {
+					timer.Stop()
+					return
+				}
+				g.Record(notice)
+
+			case <-timer.C:
+				g.send(g.Report(time.Now().UTC()))
+				break waiting
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type capturingNotifier struct {
+	notices []*datatypes.Notification
+}
+
+func (c *capturingNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	c.notices = append(c.notices, notice)
+	return nil
+}
+
+func (c *capturingNotifier) Name() string  { return "pigeon" }
+func (c *capturingNotifier) Healthy() bool { return true }
+
+func Test_Generator(t *testing.T) {
+	Convey("Digest reports", t, func() {
+		schedule, _ := ParseSchedule("@daily")
+		generator := NewGenerator("Daily digest", schedule, "pigeon")
+		generator.Top = 2
+		baseTime := time.Date(1916, 2, 21, 7, 0, 0, 0, time.UTC)
+		generator.reset(baseTime)
+
+		event := func(cluster string, hostname string, status int, previous int, when time.Time) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: cluster,
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{
+						ID: hostname + "-db", Name: "db", Hostname: hostname, Status: status,
+					},
+					PreviousStatus: previous,
+					Time:           when,
+				},
+			}
+		}
+
+		flap := func(svcName string) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type: datatypes.FLAPPING_NOTICE, ClusterName: "france",
+				Flap: &datatypes.FlapStatus{Service: svcName},
+			}
+		}
+
+		for _, hostname := range []string{"verdun", "ypres", "somme"} {
+			generator.Record(event("france", hostname, service.UNHEALTHY, service.ALIVE, baseTime))
+		}
+		generator.Record(event("france", "verdun", service.ALIVE, service.UNHEALTHY, baseTime.Add(2*time.Hour)))
+		generator.Record(event("france", "ypres", service.ALIVE, service.UNHEALTHY, baseTime.Add(time.Hour)))
+		generator.Record(event("belgium", "liege", service.ALIVE, service.ALIVE, baseTime))
+		generator.Record(flap("db"))
+		generator.Record(flap("queue"))
+		generator.Record(flap("queue"))
+
+		Convey("Count transitions per cluster", func() {
+			report := generator.Report(baseTime.Add(3 * time.Hour))
+			So(report.Transitions, ShouldResemble, map[string]int{"france": 5})
+			So(report.TotalTransitions(), ShouldEqual, 5)
+		})
+
+		Convey("List the services that flapped most", func() {
+			report := generator.Report(baseTime.Add(3 * time.Hour))
+			So(report.TopFlapping, ShouldResemble, []datatypes.ServiceCount{
+				{ClusterName: "france", Service: "queue", Count: 2},
+				{ClusterName: "france", Service: "db", Count: 1},
+			})
+		})
+
+		Convey("List the longest outages, including ongoing ones", func() {
+			report := generator.Report(baseTime.Add(3 * time.Hour))
+			So(len(report.LongestOutages), ShouldEqual, 2)
+			So(report.LongestOutages[0].Hostname, ShouldEqual, "somme")
+			So(report.LongestOutages[0].Duration, ShouldEqual, 3*time.Hour)
+			So(report.LongestOutages[0].Ongoing, ShouldBeTrue)
+			So(report.LongestOutages[1].Hostname, ShouldEqual, "verdun")
+			So(report.LongestOutages[1].Duration, ShouldEqual, 2*time.Hour)
+		})
+
+		Convey("Start over after each report", func() {
+			generator.Report(baseTime.Add(3 * time.Hour))
+			report := generator.Report(baseTime.Add(4 * time.Hour))
+
+			So(report.Start, ShouldResemble, baseTime.Add(3*time.Hour))
+			So(report.TotalTransitions(), ShouldEqual, 0)
+			So(report.TopFlapping, ShouldBeEmpty)
+			So(report.LongestOutages[0].Hostname, ShouldEqual, "somme")
+		})
+
+		Convey("Send the report to the named notifiers", func() {
+			pigeon := &capturingNotifier{}
+			generator.Registry = &notify.Registry{}
+			generator.Registry.Register(pigeon)
+
+			generator.send(generator.Report(baseTime.Add(3 * time.Hour)))
+
+			So(len(pigeon.notices), ShouldEqual, 1)
+			So(pigeon.notices[0].Type, ShouldEqual, datatypes.REPORT_NOTICE)
+			So(notify.MessageFor(pigeon.notices[0]), ShouldStartWith,
+				"Daily digest: 5 transitions from 1916-02-21 07:00 to 1916-02-21 10:00\n  france: 5 transitions\nMost flapping:")
+		})
+	})
+}
@@ -0,0 +1,146 @@
+package digest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var scheduleShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// A cron-style schedule: minute, hour, day of month, month and day of week,
+// each a "*", a number, a range like "1-5", a list like "1,15" or any of
+// those with a step like "*/15". As in cron, when both day fields are
+// restricted a day matching either one will do.
+type Schedule struct {
+	Spec     string
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	anyDay   bool // Day of month was "*"
+	anyWeek  bool // Day of week was "*"
+}
+
+func parseField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool, max-min+1)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if pieces := strings.SplitN(part, "/", 2); len(pieces) == 2 {
+			var err error
+			step, err = strconv.Atoi(pieces[1])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("Bad step in '%s'", part)
+			}
+			part = pieces[0]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("Bad value '%s'", part)
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("Bad range '%s'", part)
+				}
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func ParseSchedule(spec string) (*Schedule, error) {
+	expanded := spec
+	if shortcut, ok := scheduleShortcuts[spec]; ok {
+		expanded = shortcut
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Schedule '%s' needs 5 fields or a shortcut like @daily", spec)
+	}
+
+	schedule := &Schedule{Spec: spec, anyDay: fields[2] == "*", anyWeek: fields[4] == "*"}
+
+	var err error
+	for i, target := range []*map[int]bool{
+		&schedule.minutes, &schedule.hours, &schedule.days, &schedule.months, &schedule.weekdays,
+	} {
+		bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}[i]
+		*target, err = parseField(fields[i], bounds[0], bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("Schedule '%s': %s", spec, err.Error())
+		}
+	}
+
+	// Both 0 and 7 mean Sunday
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+
+	return schedule, nil
+}
+
+func (s *Schedule) dayMatches(when time.Time) bool {
+	dayOk := s.days[when.Day()]
+	weekOk := s.weekdays[int(when.Weekday())]
+
+	switch {
+	case s.anyDay && s.anyWeek:
+		return true
+	case s.anyDay:
+		return weekOk
+	case s.anyWeek:
+		return dayOk
+	}
+	return dayOk || weekOk
+}
+
+// The first time the schedule fires after the given time, in its location.
+// Returns the zero time if it never does, e.g. for February 30th.
+func (s *Schedule) Next(after time.Time) time.Time {
+	when := after.Truncate(time.Minute).Add(time.Minute)
+	limit := when.AddDate(5, 0, 0)
+
+	for when.Before(limit) {
+		switch {
+		case !s.months[int(when.Month())]:
+			when = time.Date(when.Year(), when.Month()+1, 1, 0, 0, 0, 0, when.Location())
+		case !s.dayMatches(when):
+			when = time.Date(when.Year(), when.Month(), when.Day()+1, 0, 0, 0, 0, when.Location())
+		case !s.hours[when.Hour()]:
+			when = time.Date(when.Year(), when.Month(), when.Day(), when.Hour()+1, 0, 0, 0, when.Location())
+		case !s.minutes[when.Minute()]:
+			when = when.Add(time.Minute)
+		default:
+			return when
+		}
+	}
+
+	return time.Time{}
+}
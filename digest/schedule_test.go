@@ -0,0 +1,50 @@
+package digest
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Schedule(t *testing.T) {
+	Convey("Cron-style schedules", t, func() {
+		// A Monday
+		baseTime := time.Date(2016, 2, 22, 10, 17, 30, 0, time.UTC)
+
+		next := func(spec string) time.Time {
+			schedule, err := ParseSchedule(spec)
+			So(err, ShouldBeNil)
+			return schedule.Next(baseTime)
+		}
+
+		Convey("Understand the shortcuts", func() {
+			So(next("@hourly"), ShouldResemble, time.Date(2016, 2, 22, 11, 0, 0, 0, time.UTC))
+			So(next("@daily"), ShouldResemble, time.Date(2016, 2, 23, 0, 0, 0, 0, time.UTC))
+			So(next("@weekly"), ShouldResemble, time.Date(2016, 2, 28, 0, 0, 0, 0, time.UTC))
+			So(next("@monthly"), ShouldResemble, time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("Handle steps, ranges and lists", func() {
+			So(next("*/15 * * * *"), ShouldResemble, time.Date(2016, 2, 22, 10, 30, 0, 0, time.UTC))
+			So(next("0 9 * * 1-5"), ShouldResemble, time.Date(2016, 2, 23, 9, 0, 0, 0, time.UTC))
+			So(next("30 8,17 * * *"), ShouldResemble, time.Date(2016, 2, 22, 17, 30, 0, 0, time.UTC))
+			So(next("0 9 * * 7"), ShouldResemble, time.Date(2016, 2, 28, 9, 0, 0, 0, time.UTC))
+		})
+
+		Convey("Fire on either day field when both are set", func() {
+			So(next("0 0 1 * 3"), ShouldResemble, time.Date(2016, 2, 24, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("Return zero for schedules that never fire", func() {
+			So(next("0 0 30 2 *").IsZero(), ShouldBeTrue)
+		})
+
+		Convey("Reject bad specs", func() {
+			for _, spec := range []string{"", "@fortnightly", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+				_, err := ParseSchedule(spec)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package dockerevents
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+)
+
+const (
+	EVENT_BUFFER_SIZE = 100
+	RECONNECT_DELAY   = 5 * time.Second
+)
+
+// For hosts that aren't running Sidecar, we can watch the Docker daemon's
+// event stream directly and synthesize the same StateChangedEvents that
+// Sidecar would have POSTed to us. Only the container lifecycle events that
+// map cleanly to Sidecar statuses are considered.
+type Watcher struct {
+	ClusterName string
+	Hostname    string
+	client      *docker.Client
+	enqueue     func(catalog.StateChangedEvent)
+	statuses    map[string]int // Container ID => last status we reported
+}
+
+// Returns a Watcher connected to the Docker daemon at endpoint. Synthesized
+// events are handed to the enqueue function, usually Tracker.EnqueueUpdate.
+func NewWatcher(endpoint string, clusterName string,
+	enqueue func(catalog.StateChangedEvent)) (*Watcher, error) {
+
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		ClusterName: clusterName,
+		Hostname:    hostname,
+		client:      client,
+		enqueue:     enqueue,
+		statuses:    make(map[string]int, 20),
+	}, nil
+}
+
+// Loop forever, converting Docker events into service changes. If we lose
+// the event stream we'll wait a bit and then re-subscribe.
+func (w *Watcher) Run() {
+	for {
+		events := make(chan *docker.APIEvents, EVENT_BUFFER_SIZE)
+
+		err := w.client.AddEventListener(events)
+		if err != nil {
+			log.Errorf("Unable to subscribe to Docker events: %s", err.Error())
+			time.Sleep(RECONNECT_DELAY)
+			continue
+		}
+
+		for evt := range events {
+			if evt == docker.EOFEvent {
+				log.Warn("Lost the Docker event stream, reconnecting")
+				break
+			}
+
+			change, ok := w.changeFromEvent(evt)
+			if !ok {
+				continue
+			}
+
+			w.enqueue(*change)
+		}
+
+		w.client.RemoveEventListener(events)
+		time.Sleep(RECONNECT_DELAY)
+	}
+}
+
+// Map a Docker event status onto a Sidecar service status. The boolean is
+// false for events we don't care about.
+func statusForEvent(status string) (int, bool) {
+	switch strings.TrimSpace(status) {
+	case "start":
+		return service.ALIVE, true
+	case "die":
+		return service.TOMBSTONE, true
+	case "health_status: healthy":
+		return service.ALIVE, true
+	case "health_status: unhealthy":
+		return service.UNHEALTHY, true
+	}
+
+	return service.UNKNOWN, false
+}
+
+// Build a StateChangedEvent from a Docker container event. We rely on the
+// attributes Docker attaches to the event rather than inspecting the
+// container, since it is usually gone by the time we see a "die".
+func (w *Watcher) changeFromEvent(evt *docker.APIEvents) (*catalog.StateChangedEvent, bool) {
+	if evt.Type != "" && evt.Type != "container" {
+		return nil, false
+	}
+
+	id := evt.Actor.ID
+	if id == "" {
+		id = evt.ID
+	}
+	if len(id) > 12 {
+		id = id[0:12] // Use short IDs like Sidecar does
+	}
+
+	// Containers that are removed won't be coming back with the same ID
+	if evt.Status == "destroy" {
+		delete(w.statuses, id)
+		return nil, false
+	}
+
+	status, ok := statusForEvent(evt.Status)
+	if !ok {
+		return nil, false
+	}
+
+	previousStatus, ok := w.statuses[id]
+	if !ok {
+		previousStatus = service.UNKNOWN
+	}
+	w.statuses[id] = status
+
+	changeTime := time.Unix(evt.Time, 0).UTC()
+	if evt.TimeNano != 0 {
+		changeTime = time.Unix(0, evt.TimeNano).UTC()
+	}
+
+	attrs := evt.Actor.Attributes
+
+	image := attrs["image"]
+	if image == "" {
+		image = evt.From
+	}
+	// Deployment tracking expects an image:version pair
+	if !strings.Contains(image, ":") {
+		image = image + ":latest"
+	}
+
+	name := attrs["ServiceName"]
+	if name == "" {
+		name = strings.TrimPrefix(attrs["name"], "/")
+	}
+
+	proxyMode := attrs["ProxyMode"]
+	if proxyMode == "" {
+		proxyMode = "http"
+	}
+
+	svc := service.Service{
+		ID:        id,
+		Name:      name,
+		Image:     image,
+		Created:   changeTime,
+		Hostname:  w.Hostname,
+		Updated:   changeTime,
+		ProxyMode: proxyMode,
+		Status:    status,
+	}
+
+	return &catalog.StateChangedEvent{
+		State: catalog.ServicesState{
+			ClusterName: w.ClusterName,
+			Hostname:    w.Hostname,
+			LastChanged: changeTime,
+		},
+		ChangeEvent: catalog.ChangeEvent{
+			Service:        svc,
+			PreviousStatus: previousStatus,
+			Time:           changeTime,
+		},
+	}, true
+}
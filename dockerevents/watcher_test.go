@@ -0,0 +1,88 @@
+package dockerevents
+
+import (
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_changeFromEvent(t *testing.T) {
+	Convey("changeFromEvent()", t, func() {
+		watcher := &Watcher{
+			ClusterName: "france",
+			Hostname:    "joffre",
+			statuses:    make(map[string]int),
+		}
+
+		evt := &docker.APIEvents{
+			Type:   "container",
+			Status: "start",
+			Time:   1473000000,
+			Actor: docker.APIActor{
+				ID: "deadbeef0123456789",
+				Attributes: map[string]string{
+					"image": "awesome-svc:0.2",
+					"name":  "awesome-svc",
+				},
+			},
+		}
+
+		Convey("Builds a StateChangedEvent for a container start", func() {
+			change, ok := watcher.changeFromEvent(evt)
+
+			So(ok, ShouldBeTrue)
+			So(change.State.ClusterName, ShouldEqual, "france")
+			So(change.State.Hostname, ShouldEqual, "joffre")
+			So(change.ChangeEvent.Service.ID, ShouldEqual, "deadbeef0123")
+			So(change.ChangeEvent.Service.Name, ShouldEqual, "awesome-svc")
+			So(change.ChangeEvent.Service.Status, ShouldEqual, service.ALIVE)
+			So(change.ChangeEvent.PreviousStatus, ShouldEqual, service.UNKNOWN)
+		})
+
+		Convey("Tracks the previous status of a container", func() {
+			watcher.changeFromEvent(evt)
+			evt.Status = "health_status: unhealthy"
+			change, ok := watcher.changeFromEvent(evt)
+
+			So(ok, ShouldBeTrue)
+			So(change.ChangeEvent.Service.Status, ShouldEqual, service.UNHEALTHY)
+			So(change.ChangeEvent.PreviousStatus, ShouldEqual, service.ALIVE)
+		})
+
+		Convey("Treats a dying container as a tombstone", func() {
+			evt.Status = "die"
+			change, _ := watcher.changeFromEvent(evt)
+
+			So(change.ChangeEvent.Service.Status, ShouldEqual, service.TOMBSTONE)
+		})
+
+		Convey("Forgets containers that have been destroyed", func() {
+			watcher.changeFromEvent(evt)
+			evt.Status = "destroy"
+			_, ok := watcher.changeFromEvent(evt)
+
+			So(ok, ShouldBeFalse)
+			So(watcher.statuses, ShouldBeEmpty)
+		})
+
+		Convey("Adds a tag to untagged images", func() {
+			evt.Actor.Attributes["image"] = "nginx"
+			change, _ := watcher.changeFromEvent(evt)
+
+			So(change.ChangeEvent.Service.Image, ShouldEqual, "nginx:latest")
+		})
+
+		Convey("Ignores events we don't care about", func() {
+			evt.Status = "attach"
+			_, ok := watcher.changeFromEvent(evt)
+			So(ok, ShouldBeFalse)
+
+			evt.Status = "start"
+			evt.Type = "network"
+			_, ok = watcher.changeFromEvent(evt)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
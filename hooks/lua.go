@@ -0,0 +1,205 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+	"github.com/yuin/gopher-lua"
+)
+
+const (
+	HOOK_FUNCTION = "process"
+	HOOK_TIMEOUT  = 250 * time.Millisecond
+)
+
+// A Lua script that sees each notification before we store it or tell
+// anyone about it. The script defines a process(event) function, which can
+// change the event's cluster, hostname, service and image fields in place,
+// and returns false to drop the event:
+//
+//	function process(event)
+//	  if event.service == "healthcheck" then return false end
+//	  event.service = string.gsub(event.service, "^legacy%-", "")
+//	end
+//
+// Scripts that fail or run too long leave the event as it was.
+type LuaHook struct {
+	Name    string
+	Timeout time.Duration
+	state   *lua.LState
+	lock    sync.Mutex
+}
+
+func NewLuaHook(name string, script string) (*LuaHook, error) {
+	state := newSandbox()
+
+	err := state.DoString(script)
+	if err != nil {
+		state.Close()
+		return nil, fmt.Errorf("Hook %s: %s", name, err.Error())
+	}
+
+	if state.GetGlobal(HOOK_FUNCTION).Type() != lua.LTFunction {
+		state.Close()
+		return nil, fmt.Errorf("Hook %s doesn't define a %s(event) function", name, HOOK_FUNCTION)
+	}
+
+	return &LuaHook{Name: name, Timeout: HOOK_TIMEOUT, state: state}, nil
+}
+
+func NewLuaHookFromFile(name string, filename string) (*LuaHook, error) {
+	script, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Hook %s: %s", name, err.Error())
+	}
+
+	return NewLuaHook(name, string(script))
+}
+
+// A Lua state without access to files, processes or modules
+func newSandbox() *lua.LState {
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+
+	for name, open := range map[string]lua.LGFunction{
+		lua.BaseLibName:   lua.OpenBase,
+		lua.StringLibName: lua.OpenString,
+		lua.TabLibName:    lua.OpenTable,
+		lua.MathLibName:   lua.OpenMath,
+	} {
+		state.Push(state.NewFunction(open))
+		state.Push(lua.LString(name))
+		state.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring"} {
+		state.SetGlobal(name, lua.LNil)
+	}
+
+	return state
+}
+
+func (h *LuaHook) tableFor(notice *datatypes.Notification) *lua.LTable {
+	event := h.state.NewTable()
+	event.RawSetString("id", lua.LString(notice.ID))
+	event.RawSetString("type", lua.LString(notice.Type))
+	event.RawSetString("cluster", lua.LString(notice.ClusterName))
+	event.RawSetString("original_cluster", lua.LString(notice.OriginalClusterName))
+	event.RawSetString("source", lua.LString(notice.Source))
+
+	if notice.Event != nil {
+		svc := &notice.Event.Service
+		event.RawSetString("service", lua.LString(svc.Name))
+		event.RawSetString("service_id", lua.LString(svc.ID))
+		event.RawSetString("hostname", lua.LString(svc.Hostname))
+		event.RawSetString("image", lua.LString(svc.Image))
+		event.RawSetString("status", lua.LString(datatypes.StatusString(svc.Status)))
+		event.RawSetString("previous_status", lua.LString(datatypes.StatusString(notice.Event.PreviousStatus)))
+		event.RawSetString("time", lua.LNumber(notice.Event.Time.Unix()))
+	}
+
+	return event
+}
+
+func setString(table *lua.LTable, key string, field *string) {
+	if value, ok := table.RawGetString(key).(lua.LString); ok {
+		*field = string(value)
+	}
+}
+
+// Copy back whatever the script is allowed to change
+func applyTable(event *lua.LTable, notice *datatypes.Notification) {
+	setString(event, "cluster", &notice.ClusterName)
+
+	if notice.Event != nil {
+		svc := &notice.Event.Service
+		setString(event, "service", &svc.Name)
+		setString(event, "hostname", &svc.Hostname)
+		setString(event, "image", &svc.Image)
+	}
+}
+
+// Run the script on a notification. Returns false if it should be dropped.
+func (h *LuaHook) Process(notice *datatypes.Notification) (bool, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	h.state.SetContext(ctx)
+	defer h.state.RemoveContext()
+
+	event := h.tableFor(notice)
+
+	err := h.state.CallByParam(lua.P{
+		Fn:      h.state.GetGlobal(HOOK_FUNCTION),
+		NRet:    1,
+		Protect: true,
+	}, event)
+	if err != nil {
+		return true, err
+	}
+
+	result := h.state.Get(-1)
+	h.state.Pop(1)
+
+	if result == lua.LFalse {
+		return false, nil
+	}
+
+	applyTable(event, notice)
+	return true, nil
+}
+
+func (h *LuaHook) Close() {
+	h.lock.Lock()
+	h.state.Close()
+	h.lock.Unlock()
+}
+
+// Hooks run in the order they were configured
+type Chain struct {
+	Hooks   []*LuaHook
+	Dropped *metrics.CounterVec
+	Errors  *metrics.CounterVec
+}
+
+func NewChain(hooks ...*LuaHook) *Chain {
+	return &Chain{
+		Hooks: hooks,
+		Dropped: metrics.NewCounterVec(
+			"superside_hook_dropped_events_total",
+			"Events dropped by processing hooks",
+			"hook",
+		),
+		Errors: metrics.NewCounterVec(
+			"superside_hook_errors_total",
+			"Processing hook scripts that failed",
+			"hook",
+		),
+	}
+}
+
+// Run every hook on the notification, stopping at the first that drops it
+func (c *Chain) Process(notice *datatypes.Notification) bool {
+	for _, hook := range c.Hooks {
+		keep, err := hook.Process(notice)
+		if err != nil {
+			log.Warnf("Hook %s failed: %s", hook.Name, err.Error())
+			c.Errors.Inc(hook.Name)
+			continue
+		}
+
+		if !keep {
+			c.Dropped.Inc(hook.Name)
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+package hooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const renameScript = `
+function process(event)
+  if event.service == "healthcheck" then
+    return false
+  end
+
+  event.service = string.gsub(event.service, "^legacy%-", "")
+  event.status = "Alive" -- Not ours to change
+end
+`
+
+func Test_LuaHook(t *testing.T) {
+	Convey("Lua processing hooks", t, func() {
+		notice := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{
+					Name: "legacy-db", Hostname: "verdun", Status: service.UNHEALTHY,
+				},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Now().UTC(),
+			},
+		}
+
+		hook, err := NewLuaHook("rename", renameScript)
+		So(err, ShouldBeNil)
+		defer hook.Close()
+
+		chain := NewChain(hook)
+
+		Convey("Rewrites the fields the script changes", func() {
+			So(chain.Process(notice), ShouldBeTrue)
+			So(notice.Event.Service.Name, ShouldEqual, "db")
+			So(notice.Event.Service.Status, ShouldEqual, service.UNHEALTHY)
+			So(notice.Event.Service.Hostname, ShouldEqual, "verdun")
+		})
+
+		Convey("Drops events when the script returns false", func() {
+			notice.Event.Service.Name = "healthcheck"
+			So(chain.Process(notice), ShouldBeFalse)
+			So(chain.Dropped.Get("rename"), ShouldEqual, 1)
+		})
+
+		Convey("Keeps the event as it was when the script fails", func() {
+			broken, err := NewLuaHook("broken", "function process(event) error('boom') end")
+			So(err, ShouldBeNil)
+
+			chain.Hooks = []*LuaHook{broken}
+			So(chain.Process(notice), ShouldBeTrue)
+			So(notice.Event.Service.Name, ShouldEqual, "legacy-db")
+			So(chain.Errors.Get("broken"), ShouldEqual, 1)
+		})
+
+		Convey("Gives up on scripts that run too long", func() {
+			slow, err := NewLuaHook("slow", "function process(event) while true do end end")
+			So(err, ShouldBeNil)
+			slow.Timeout = 10 * time.Millisecond
+
+			keep, err := slow.Process(notice)
+			So(keep, ShouldBeTrue)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Rejects scripts without a process function", func() {
+			_, err := NewLuaHook("empty", "x = 1")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Doesn't let scripts touch the filesystem", func() {
+			_, err := NewLuaHook("sneaky", "dofile('/etc/passwd')")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
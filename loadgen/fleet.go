@@ -0,0 +1,118 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+)
+
+// How often each kind of event comes up, out of 100. The rest are services
+// going unhealthy or recovering, which is most of what Sidecar sends.
+const (
+	DEPLOY_PERCENT    = 5 // An instance replaced by one on a new version
+	TOMBSTONE_PERCENT = 2 // An instance going away for good, then coming back
+)
+
+// A made-up set of clusters, each with services spread over its hosts, whose
+// instances change state like real ones do
+type Fleet struct {
+	clusters []*cluster
+	random   *rand.Rand
+}
+
+type cluster struct {
+	name      string
+	reporter  string // The one host the cluster latch lets through
+	instances []*service.Service
+	versions  map[string]int // Service => current version
+}
+
+func NewFleet(clusters int, services int, hosts int, seed int64) *Fleet {
+	fleet := &Fleet{random: rand.New(rand.NewSource(seed))}
+	now := time.Now().UTC()
+
+	for c := 0; c < clusters; c++ {
+		cl := &cluster{
+			name:     fmt.Sprintf("loadgen-%d", c),
+			reporter: fmt.Sprintf("loadgen-%d-host-0", c),
+			versions: make(map[string]int, services),
+		}
+
+		for s := 0; s < services; s++ {
+			name := fmt.Sprintf("svc-%d", s)
+			cl.versions[name] = 1
+
+			// Each service runs on a couple of the hosts
+			for replica := 0; replica < 2 && replica < hosts; replica++ {
+				cl.instances = append(cl.instances, &service.Service{
+					ID:       fmt.Sprintf("%012x", fleet.random.Int63()),
+					Name:     name,
+					Image:    fmt.Sprintf("registry/%s:1", name),
+					Hostname: fmt.Sprintf("loadgen-%d-host-%d", c, (s+replica)%hosts),
+					Created:  now,
+					Updated:  now,
+					Status:   service.ALIVE,
+				})
+			}
+		}
+
+		fleet.clusters = append(fleet.clusters, cl)
+	}
+
+	return fleet
+}
+
+// Move a random instance on to its next state and describe it the way
+// Sidecar would. Not safe to call from more than one goroutine.
+func (f *Fleet) Next(now time.Time) catalog.StateChangedEvent {
+	cl := f.clusters[f.random.Intn(len(f.clusters))]
+	index := f.random.Intn(len(cl.instances))
+	svc := cl.instances[index]
+	previous := svc.Status
+
+	roll := f.random.Intn(100)
+	switch {
+	case svc.Status == service.TOMBSTONE:
+		// Bring it back as a fresh instance
+		replacement := *svc
+		replacement.ID = fmt.Sprintf("%012x", f.random.Int63())
+		replacement.Created = now
+		replacement.Status = service.ALIVE
+		svc = &replacement
+		cl.instances[index] = svc
+		previous = service.UNKNOWN // Sidecar hasn't seen it before
+
+	case roll < DEPLOY_PERCENT:
+		cl.versions[svc.Name] += 1
+		replacement := *svc
+		replacement.ID = fmt.Sprintf("%012x", f.random.Int63())
+		replacement.Image = fmt.Sprintf("registry/%s:%d", svc.Name, cl.versions[svc.Name])
+		replacement.Created = now
+		replacement.Status = service.ALIVE
+		svc = &replacement
+		cl.instances[index] = svc
+		previous = service.UNKNOWN // Sidecar hasn't seen it before
+
+	case roll < DEPLOY_PERCENT+TOMBSTONE_PERCENT:
+		svc.Status = service.TOMBSTONE
+
+	case svc.Status == service.ALIVE:
+		svc.Status = service.UNHEALTHY
+
+	default:
+		svc.Status = service.ALIVE
+	}
+	svc.Updated = now
+
+	return catalog.StateChangedEvent{
+		State: catalog.ServicesState{ClusterName: cl.name, Hostname: cl.reporter},
+		ChangeEvent: catalog.ChangeEvent{
+			Service:        *svc,
+			PreviousStatus: previous,
+			Time:           now,
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Fleet(t *testing.T) {
+	Convey("A made-up fleet", t, func() {
+		fleet := NewFleet(3, 4, 2, 1)
+		now := time.Now().UTC()
+
+		Convey("Reports through one host per cluster", func() {
+			reporters := make(map[string]string)
+			for i := 0; i < 200; i++ {
+				evt := fleet.Next(now)
+				So(evt.State.ClusterName, ShouldNotBeEmpty)
+				So(evt.State.Hostname, ShouldNotBeEmpty)
+
+				if reporter, ok := reporters[evt.State.ClusterName]; ok {
+					So(evt.State.Hostname, ShouldEqual, reporter)
+				}
+				reporters[evt.State.ClusterName] = evt.State.Hostname
+			}
+			So(len(reporters), ShouldEqual, 3)
+		})
+
+		Convey("Changes the status of each instance it picks", func() {
+			statuses := make(map[int]bool)
+			for i := 0; i < 500; i++ {
+				evt := fleet.Next(now)
+				So(evt.ChangeEvent.Service.Status, ShouldNotEqual, evt.ChangeEvent.PreviousStatus)
+				So(evt.ChangeEvent.Service.Updated, ShouldResemble, now)
+				statuses[evt.ChangeEvent.Service.Status] = true
+			}
+
+			So(statuses[service.ALIVE], ShouldBeTrue)
+			So(statuses[service.UNHEALTHY], ShouldBeTrue)
+			So(statuses[service.TOMBSTONE], ShouldBeTrue)
+		})
+
+		Convey("Is the same every time for a seed", func() {
+			other := NewFleet(3, 4, 2, 1)
+			for i := 0; i < 50; i++ {
+				So(other.Next(now).ChangeEvent.Service.ID, ShouldEqual, fleet.Next(now).ChangeEvent.Service.ID)
+			}
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_SERVICES = 20 // Per cluster
+	DEFAULT_HOSTS    = 5  // Per cluster
+	DEFAULT_WORKERS  = 16
+	SCHEDULE_TICK    = 10 * time.Millisecond
+	HTTP_TIMEOUT     = 10 * time.Second
+	REPORT_INTERVAL  = 5 * time.Second
+)
+
+// Parse a rate like "500/s", "1000/m" or just "500" (per second) into
+// events per second
+func ParseRate(rate string) (float64, error) {
+	count, unit := rate, "s"
+	if slash := strings.Index(rate, "/"); slash >= 0 {
+		count, unit = rate[:slash], rate[slash+1:]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("Invalid rate '%s'", rate)
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "s":
+		return value, nil
+	case "m":
+		return value / 60, nil
+	case "h":
+		return value / 3600, nil
+	}
+
+	return 0, fmt.Errorf("Invalid rate unit in '%s', expected s, m or h", rate)
+}
+
+// What happened during a run. Skipped events are ones we couldn't send on
+// time because every worker was still waiting on the target.
+type Stats struct {
+	Sent    uint64
+	Failed  uint64
+	Skipped uint64
+	Latency time.Duration // Mean, of the ones that were sent
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("sent %d, failed %d, skipped %d, mean latency %s",
+		s.Sent, s.Failed, s.Skipped, s.Latency,
+	)
+}
+
+// Posts Sidecar events from a Fleet to a superside's /api/update at a
+// steady Rate, spread over Workers connections
+type Generator struct {
+	sent         uint64 // Accessed atomically, so kept 64-bit aligned up here
+	failed       uint64
+	skipped      uint64
+	totalLatency int64  // Nanoseconds
+	Target       string // e.g. "http://localhost:7779"
+	Rate         float64
+	Fleet        *Fleet
+	Workers      int
+	client       *http.Client
+}
+
+func NewGenerator(target string, rate float64, fleet *Fleet) *Generator {
+	return &Generator{
+		Target:  strings.TrimRight(target, "/"),
+		Rate:    rate,
+		Fleet:   fleet,
+		Workers: DEFAULT_WORKERS,
+	}
+}
+
+// Send events until the context is done, then wait for the ones in flight
+func (g *Generator) Run(ctx context.Context) Stats {
+	g.client = &http.Client{
+		Timeout:   HTTP_TIMEOUT,
+		Transport: &http.Transport{MaxIdleConnsPerHost: g.Workers},
+	}
+	jobs := make(chan []byte, g.Workers)
+
+	var workers sync.WaitGroup
+	for i := 0; i < g.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for body := range jobs {
+				g.send(body)
+			}
+		}()
+	}
+
+	g.schedule(ctx, jobs)
+	close(jobs)
+	workers.Wait()
+
+	return g.Stats()
+}
+
+// Hand out as many events as the rate says are due by now, every tick
+func (g *Generator) schedule(ctx context.Context, jobs chan<- []byte) {
+	ticker := time.NewTicker(SCHEDULE_TICK)
+	defer ticker.Stop()
+
+	started := time.Now()
+	var scheduled uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := uint64(g.Rate * now.Sub(started).Seconds())
+			for ; scheduled < due; scheduled++ {
+				body, _ := json.Marshal(g.Fleet.Next(now.UTC()))
+
+				select {
+				case jobs <- body:
+				default:
+					atomic.AddUint64(&g.skipped, 1)
+				}
+			}
+		}
+	}
+}
+
+func (g *Generator) send(body []byte) {
+	started := time.Now()
+
+	resp, err := g.client.Post(g.Target+"/api/update", "application/json", bytes.NewReader(body))
+	if err != nil {
+		atomic.AddUint64(&g.failed, 1)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body) // So the connection is reused
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		atomic.AddUint64(&g.failed, 1)
+		return
+	}
+
+	atomic.AddUint64(&g.sent, 1)
+	atomic.AddInt64(&g.totalLatency, int64(time.Since(started)))
+}
+
+// How the run is going so far
+func (g *Generator) Stats() Stats {
+	stats := Stats{
+		Sent:    atomic.LoadUint64(&g.sent),
+		Failed:  atomic.LoadUint64(&g.failed),
+		Skipped: atomic.LoadUint64(&g.skipped),
+	}
+
+	if stats.Sent > 0 {
+		stats.Latency = time.Duration(atomic.LoadInt64(&g.totalLatency) / int64(stats.Sent))
+	}
+
+	return stats
+}
@@ -0,0 +1,75 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseRate(t *testing.T) {
+	Convey("Parsing rates", t, func() {
+		Convey("Takes per second, minute and hour", func() {
+			rate, err := ParseRate("500/s")
+			So(err, ShouldBeNil)
+			So(rate, ShouldEqual, 500)
+
+			rate, _ = ParseRate("120/m")
+			So(rate, ShouldEqual, 2)
+
+			rate, _ = ParseRate("7200/h")
+			So(rate, ShouldEqual, 2)
+		})
+
+		Convey("Defaults to per second", func() {
+			rate, err := ParseRate("25")
+			So(err, ShouldBeNil)
+			So(rate, ShouldEqual, 25)
+		})
+
+		Convey("Rejects nonsense", func() {
+			for _, rate := range []string{"", "fast", "-5/s", "0", "500/d"} {
+				_, err := ParseRate(rate)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func Test_Generator(t *testing.T) {
+	Convey("Generating load", t, func() {
+		var received, bad int64
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var evt catalog.StateChangedEvent
+			if r.URL.Path != "/api/update" || json.NewDecoder(r.Body).Decode(&evt) != nil {
+				atomic.AddInt64(&bad, 1)
+			}
+			atomic.AddInt64(&received, 1)
+		}))
+		defer target.Close()
+
+		generator := NewGenerator(target.URL+"/", 200, NewFleet(2, 3, 2, 1))
+		generator.Workers = 2
+
+		ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+		defer cancel()
+		stats := generator.Run(ctx)
+
+		Convey("Posts updates to the target", func() {
+			So(stats.Sent, ShouldBeGreaterThan, 0)
+			So(stats.Failed, ShouldEqual, 0)
+			So(atomic.LoadInt64(&bad), ShouldEqual, 0)
+			So(uint64(atomic.LoadInt64(&received)), ShouldEqual, stats.Sent)
+		})
+
+		Convey("Keeps roughly to the rate", func() {
+			So(stats.Sent+stats.Skipped, ShouldBeLessThanOrEqualTo, 60)
+		})
+	})
+}
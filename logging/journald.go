@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	JOURNALD_SOCKET = "/run/systemd/journal/socket"
+)
+
+// A logrus hook sending entries to the systemd journal over its native
+// protocol, so fields arrive as journal fields that can be matched with
+// journalctl rather than squashed into the message. Entries too big for
+// one datagram (usually over ~200KB) fail rather than being split.
+type JournaldHook struct {
+	Socket     string
+	Identifier string // SYSLOG_IDENTIFIER on the entries
+	conn       *net.UnixConn
+	lock       sync.Mutex
+}
+
+func NewJournaldHook() (*JournaldHook, error) {
+	return newJournaldHook(JOURNALD_SOCKET)
+}
+
+func newJournaldHook(socket string) (*JournaldHook, error) {
+	hook := &JournaldHook{
+		Socket:     socket,
+		Identifier: filepath.Base(os.Args[0]),
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	hook.conn = conn
+
+	return hook, nil
+}
+
+func (h *JournaldHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Journal field names are upper case letters, digits and underscores, and
+// can't start with an underscore, which is kept for trusted fields
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+
+	return strings.TrimLeft(name, "_")
+}
+
+// Add one field in the native format. Values with newlines in them are
+// sent length-prefixed instead of with an equals sign.
+func writeJournalField(buf *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+
+	buf.WriteString(name + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+func (h *JournaldHook) format(entry *log.Entry) []byte {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", entry.Message)
+	writeJournalField(&buf, "PRIORITY", fmt.Sprintf("%d", syslogSeverities[entry.Level]))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", h.Identifier)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := journalFieldName(key)
+		if name == "" || name == "MESSAGE" || name == "PRIORITY" || name == "SYSLOG_IDENTIFIER" {
+			continue
+		}
+		writeJournalField(&buf, name, fmt.Sprintf("%v", entry.Data[key]))
+	}
+
+	return buf.Bytes()
+}
+
+func (h *JournaldHook) Fire(entry *log.Entry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	_, err := h.conn.Write(h.format(entry))
+	return err
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_JournaldHook(t *testing.T) {
+	Convey("A JournaldHook", t, func() {
+		entry := &log.Entry{
+			Level:   log.ErrorLevel,
+			Message: "Wire uncut",
+			Data:    log.Fields{"sector": "somme", "_hidden": "yes", "message": "clash"},
+		}
+
+		Convey("Sends entries as journal fields", func() {
+			dir, _ := ioutil.TempDir("", "journald")
+			defer os.RemoveAll(dir)
+
+			socket := filepath.Join(dir, "socket")
+			listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+			So(err, ShouldBeNil)
+			defer listener.Close()
+
+			hook, err := newJournaldHook(socket)
+			So(err, ShouldBeNil)
+			hook.Identifier = "superside"
+			So(hook.Fire(entry), ShouldBeNil)
+
+			buf := make([]byte, 1024)
+			listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, err := listener.Read(buf)
+			So(err, ShouldBeNil)
+			So(string(buf[:n]), ShouldEqual,
+				"MESSAGE=Wire uncut\nPRIORITY=3\nSYSLOG_IDENTIFIER=superside\nHIDDEN=yes\nSECTOR=somme\n",
+			)
+		})
+
+		Convey("Length-prefixes values with newlines in them", func() {
+			entry.Message = "Wire\nuncut"
+			entry.Data = log.Fields{}
+			hook := &JournaldHook{Identifier: "superside"}
+
+			So(string(hook.format(entry)), ShouldStartWith,
+				"MESSAGE\n\x0a\x00\x00\x00\x00\x00\x00\x00Wire\nuncut\nPRIORITY=3\n",
+			)
+		})
+	})
+}
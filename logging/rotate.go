@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BACKUP_TIME_FORMAT  = "20060102T150405.000"
+	DEFAULT_MAX_SIZE_MB = 100
+)
+
+// A log file that moves itself aside and starts again once it gets bigger
+// than MaxSize bytes or older than MaxAge, keeping the newest MaxBackups of
+// the old ones. Old files are named like superside.log.20170102T150405.000.
+// A zero limit turns that limit off.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+	lock       sync.Mutex
+}
+
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	rotating := &RotatingFile{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+	}
+
+	err := rotating.open(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return rotating, nil
+}
+
+// Open the log file, carrying on from the end of an existing one. Its age
+// counts from when it was last modified, as that's all we can tell.
+func (r *RotatingFile) open(now time.Time) error {
+	err := os.MkdirAll(filepath.Dir(r.Path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = now
+	if r.size > 0 {
+		r.openedAt = info.ModTime()
+	}
+
+	return nil
+}
+
+func (r *RotatingFile) shouldRotate(next int, now time.Time) bool {
+	if r.size == 0 {
+		return false // Never leave an empty file behind
+	}
+
+	if r.MaxSize > 0 && r.size+int64(next) > r.MaxSize {
+		return true
+	}
+
+	return r.MaxAge > 0 && now.Sub(r.openedAt) >= r.MaxAge
+}
+
+func (r *RotatingFile) Write(data []byte) (int, error) {
+	return r.write(data, time.Now())
+}
+
+func (r *RotatingFile) write(data []byte, now time.Time) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.shouldRotate(len(data), now) {
+		err := r.rotate(now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := r.file.Write(data)
+	r.size += int64(written)
+
+	return written, err
+}
+
+// Move the current file aside, start a new one, and clear out old backups
+func (r *RotatingFile) rotate(now time.Time) error {
+	err := r.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(r.Path, r.Path+"."+now.UTC().Format(BACKUP_TIME_FORMAT))
+	if err != nil {
+		return err
+	}
+
+	err = r.open(now)
+	if err != nil {
+		return err
+	}
+
+	r.prune()
+	return nil
+}
+
+// The backups we've made, oldest first. The timestamps sort by name.
+func (r *RotatingFile) backups() []string {
+	matches, _ := filepath.Glob(r.Path + ".*")
+
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		_, err := time.Parse(BACKUP_TIME_FORMAT, strings.TrimPrefix(match, r.Path+"."))
+		if err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	sort.Strings(backups)
+	return backups
+}
+
+func (r *RotatingFile) prune() {
+	if r.MaxBackups <= 0 {
+		return
+	}
+
+	backups := r.backups()
+	for len(backups) > r.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.file.Close()
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_RotatingFile(t *testing.T) {
+	Convey("A RotatingFile", t, func() {
+		dir, _ := ioutil.TempDir("", "logging")
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "logs", "superside.log")
+		now := time.Date(1916, 7, 1, 7, 30, 0, 0, time.UTC)
+
+		rotating, err := NewRotatingFile(path, 10, 0, 2)
+		So(err, ShouldBeNil)
+		defer rotating.Close()
+
+		contents := func(file string) string {
+			data, _ := ioutil.ReadFile(file)
+			return string(data)
+		}
+
+		Convey("Writes to the file until it gets too big", func() {
+			rotating.write([]byte("somme\n"), now)
+			rotating.write([]byte("ypres\n"), now.Add(time.Second))
+
+			So(contents(path), ShouldEqual, "ypres\n")
+			So(rotating.backups(), ShouldResemble, []string{path + ".19160701T073001.000"})
+			So(contents(rotating.backups()[0]), ShouldEqual, "somme\n")
+		})
+
+		Convey("Keeps only the newest backups", func() {
+			for i, line := range []string{"marne\n", "somme\n", "ypres\n", "arras\n"} {
+				rotating.write([]byte(line), now.Add(time.Duration(i)*time.Second))
+			}
+
+			backups := rotating.backups()
+			So(len(backups), ShouldEqual, 2)
+			So(contents(backups[0]), ShouldEqual, "somme\n")
+			So(contents(backups[1]), ShouldEqual, "ypres\n")
+			So(contents(path), ShouldEqual, "arras\n")
+		})
+
+		Convey("Rotates once the file is too old", func() {
+			rotating.MaxSize = 0
+			rotating.MaxAge = time.Hour
+			rotating.openedAt = now
+
+			rotating.write([]byte("somme\n"), now)
+			rotating.write([]byte("ypres\n"), now.Add(30*time.Minute))
+			So(rotating.backups(), ShouldBeEmpty)
+
+			rotating.write([]byte("arras\n"), now.Add(time.Hour))
+			So(len(rotating.backups()), ShouldEqual, 1)
+			So(contents(path), ShouldEqual, "arras\n")
+		})
+
+		Convey("Carries on from an existing file", func() {
+			rotating.write([]byte("somme\n"), now)
+			rotating.Close()
+
+			reopened, err := NewRotatingFile(path, 100, 0, 0)
+			So(err, ShouldBeNil)
+			defer reopened.Close()
+
+			reopened.write([]byte("ypres\n"), now)
+			So(contents(path), ShouldEqual, "somme\nypres\n")
+		})
+	})
+}
@@ -0,0 +1,216 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	SYSLOG_VERSION       = 1
+	SYSLOG_DIAL_TIMEOUT  = 5 * time.Second
+	SYSLOG_WRITE_TIMEOUT = 5 * time.Second
+	SYSLOG_BUFFER_SIZE   = 1000 // Entries waiting to be sent before we drop them
+)
+
+// The usual places a local syslog daemon listens
+var SYSLOG_LOCAL_SOCKETS = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Syslog facility codes by name
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities for each logrus level
+var syslogSeverities = map[log.Level]int{
+	log.PanicLevel: 0, // Emergency
+	log.FatalLevel: 2, // Critical
+	log.ErrorLevel: 3,
+	log.WarnLevel:  4,
+	log.InfoLevel:  6,
+	log.DebugLevel: 7,
+}
+
+// A logrus hook sending entries to syslog as RFC5424 messages, either to
+// the local daemon or to a remote one over UDP or TCP. TCP messages are
+// octet-counted as per RFC6587. The connection is re-made when a write
+// fails, so a restarted daemon doesn't lose us for good.
+//
+// Entries are sent from a goroutine of our own, so a slow or stalled daemon
+// never holds up the code doing the logging. If more than SYSLOG_BUFFER_SIZE
+// of them are waiting, new ones are dropped and counted, and the count is
+// sent once the daemon catches up.
+type SyslogHook struct {
+	Network  string // "udp", "tcp", "unixgram" or "unix"
+	Address  string
+	Facility int
+	AppName  string
+	hostname string
+	conn     net.Conn
+	messages chan string
+	dropped  uint64 // Accessed atomically
+}
+
+// Set up a hook for a syslog URL like udp://logs:514, tcp://logs:601,
+// unix:///dev/log, or just "local" to find the local daemon's socket
+func NewSyslogHook(syslogUrl string, facility string) (*SyslogHook, error) {
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("Unknown syslog facility '%s'", facility)
+	}
+
+	hook := &SyslogHook{
+		Facility: code,
+		AppName:  filepath.Base(os.Args[0]),
+		messages: make(chan string, SYSLOG_BUFFER_SIZE),
+	}
+
+	hook.hostname, _ = os.Hostname()
+	if hook.hostname == "" {
+		hook.hostname = "-"
+	}
+
+	if syslogUrl == "local" {
+		for _, socket := range SYSLOG_LOCAL_SOCKETS {
+			if _, err := os.Stat(socket); err == nil {
+				hook.Network, hook.Address = "unixgram", socket
+				break
+			}
+		}
+		if hook.Address == "" {
+			return nil, errors.New("Can't find a local syslog socket")
+		}
+	} else {
+		parsed, err := url.Parse(syslogUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		switch parsed.Scheme {
+		case "udp", "tcp":
+			hook.Network, hook.Address = parsed.Scheme, parsed.Host
+		case "unix", "unixgram":
+			hook.Network, hook.Address = parsed.Scheme, parsed.Path
+		default:
+			return nil, fmt.Errorf("Unsupported syslog URL '%s'", syslogUrl)
+		}
+	}
+
+	// Fail at startup rather than on the first log line
+	err := hook.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	go hook.run()
+
+	return hook, nil
+}
+
+func (h *SyslogHook) connect() error {
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+	}
+
+	conn, err := net.DialTimeout(h.Network, h.Address, SYSLOG_DIAL_TIMEOUT)
+	if err != nil {
+		return err
+	}
+
+	h.conn = conn
+	return nil
+}
+
+func (h *SyslogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Render an entry as an RFC5424 message. Fields go on the end of the
+// message like the text formatter does, rather than in structured data,
+// which needs an IANA enterprise number.
+func (h *SyslogHook) format(entry *log.Entry) string {
+	message := entry.Message
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		message += fmt.Sprintf(" %s=%v", key, entry.Data[key])
+	}
+
+	return fmt.Sprintf("<%d>%d %s %s %s %d - - %s",
+		h.Facility*8+syslogSeverities[entry.Level], SYSLOG_VERSION,
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		h.hostname, h.AppName, os.Getpid(), message,
+	)
+}
+
+// Frame a message for the kind of connection we have
+func (h *SyslogHook) frame(message string) string {
+	switch h.Network {
+	case "tcp":
+		return fmt.Sprintf("%d %s", len(message), message)
+	case "unix":
+		return message + "\n" // Stream sockets on local daemons split on newlines
+	}
+
+	return message
+}
+
+// Queue the entry for sending, or drop it if too many are waiting
+func (h *SyslogHook) Fire(entry *log.Entry) error {
+	select {
+	case h.messages <- h.frame(h.format(entry)):
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+
+	return nil
+}
+
+// Send the queued messages, owning the connection from here on
+func (h *SyslogHook) run() {
+	for message := range h.messages {
+		if dropped := atomic.SwapUint64(&h.dropped, 0); dropped > 0 {
+			h.write(h.frame(h.format(&log.Entry{
+				Time:    time.Now().UTC(),
+				Level:   log.WarnLevel,
+				Message: fmt.Sprintf("Dropped %d log entries while syslog was falling behind", dropped),
+			})))
+		}
+
+		h.write(message)
+	}
+}
+
+func (h *SyslogHook) write(message string) {
+	if h.conn != nil {
+		h.conn.SetWriteDeadline(time.Now().Add(SYSLOG_WRITE_TIMEOUT))
+		if _, err := h.conn.Write([]byte(message)); err == nil {
+			return
+		}
+	}
+
+	// Have one more go on a fresh connection, then give up on this one
+	if h.connect() != nil {
+		return
+	}
+
+	h.conn.SetWriteDeadline(time.Now().Add(SYSLOG_WRITE_TIMEOUT))
+	h.conn.Write([]byte(message))
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SyslogHook(t *testing.T) {
+	Convey("A SyslogHook", t, func() {
+		entry := &log.Entry{
+			Time:    time.Date(1916, 7, 1, 7, 30, 0, 0, time.UTC),
+			Level:   log.WarnLevel,
+			Message: "Zero hour",
+			Data:    log.Fields{"sector": "somme", "attempt": 1},
+		}
+
+		Convey("Formats entries as RFC5424", func() {
+			hook := &SyslogHook{Facility: syslogFacilities["local0"], AppName: "superside", hostname: "albert"}
+
+			So(hook.format(entry), ShouldEqual,
+				"<132>1 1916-07-01T07:30:00.000000Z albert superside "+
+					strconv.Itoa(os.Getpid())+" - - Zero hour attempt=1 sector=somme",
+			)
+		})
+
+		Convey("Sends them to a remote daemon over UDP", func() {
+			listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer listener.Close()
+
+			hook, err := NewSyslogHook("udp://"+listener.LocalAddr().String(), "daemon")
+			So(err, ShouldBeNil)
+			So(hook.Fire(entry), ShouldBeNil)
+
+			buf := make([]byte, 1024)
+			listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := listener.ReadFrom(buf)
+			So(err, ShouldBeNil)
+			So(string(buf[:n]), ShouldStartWith, "<28>1 ")
+			So(string(buf[:n]), ShouldEndWith, "Zero hour attempt=1 sector=somme")
+		})
+
+		Convey("Drops entries rather than wait on a stalled daemon", func() {
+			hook := &SyslogHook{Network: "udp", messages: make(chan string, 1)}
+
+			So(hook.Fire(entry), ShouldBeNil)
+			So(hook.Fire(entry), ShouldBeNil)
+			So(len(hook.messages), ShouldEqual, 1)
+			So(hook.dropped, ShouldEqual, 1)
+		})
+
+		Convey("Rejects settings it can't use", func() {
+			_, err := NewSyslogHook("udp://localhost:514", "local9")
+			So(err.Error(), ShouldContainSubstring, "local9")
+
+			_, err = NewSyslogHook("http://localhost:514", "daemon")
+			So(err.Error(), ShouldContainSubstring, "Unsupported")
+		})
+	})
+}
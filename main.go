@@ -1,40 +1,489 @@
 package main
 
 import (
-	"gopkg.in/alecthomas/kingpin.v1"
+	"context"
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/client"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/digest"
+	"github.com/nitro/superside/dockerevents"
+	"github.com/nitro/superside/hooks"
+	"github.com/nitro/superside/loadgen"
+	"github.com/nitro/superside/metrics"
+	"github.com/nitro/superside/notify"
+	"github.com/nitro/superside/server"
+	"github.com/nitro/superside/sinks"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/top"
 	"github.com/nitro/superside/tracker"
-	"github.com/nitro/superside/persistence"
+	"gopkg.in/alecthomas/kingpin.v1"
 )
 
 type CliOpts struct {
 	ConfigFile *string
 	Persist    *bool
+	LogLevel   *string
+	LogFormat  *string
+	LogFile    *string
+	Command    string
+	Loadgen    LoadgenOpts
+	Top        TopOpts
 }
 
-var state *tracker.Tracker
+// Options for the loadgen command
+type LoadgenOpts struct {
+	Target   *string
+	Rate     *string
+	Clusters *int
+	Services *int
+	Hosts    *int
+	Workers  *int
+	Duration *time.Duration
+}
+
+// Options for the top command
+type TopOpts struct {
+	Target  *string
+	Refresh *time.Duration
+}
 
 func parseCommandLine() *CliOpts {
 	var opts CliOpts
 	opts.ConfigFile = kingpin.Flag("config-file", "The config file to use").Short('f').Default("superside.toml").String()
 	opts.Persist = kingpin.Flag("persist", "Do we persist and load data from the store?").Short('p').Default("true").Bool()
-	kingpin.Parse()
+	opts.LogLevel = kingpin.Flag("log-level", "debug, info, warn or error, overriding the config file").String()
+	opts.LogFormat = kingpin.Flag("log-format", "text or json, overriding the config file").String()
+	opts.LogFile = kingpin.Flag("log-file", "Log to this file instead of stderr, overriding the config file").String()
+
+	loadgenCmd := kingpin.Command("loadgen", "Send realistic Sidecar traffic to a superside, for benchmarking")
+	opts.Loadgen.Target = loadgenCmd.Flag("target", "The superside to send to").Default("http://localhost:7779").String()
+	opts.Loadgen.Rate = loadgenCmd.Flag("rate", "Events to send, e.g. 500/s or 1000/m").Default("100/s").String()
+	opts.Loadgen.Clusters = loadgenCmd.Flag("clusters", "How many clusters to simulate").Default("10").Int()
+	opts.Loadgen.Services = loadgenCmd.Flag("services", "Services per cluster").Default(strconv.Itoa(loadgen.DEFAULT_SERVICES)).Int()
+	opts.Loadgen.Hosts = loadgenCmd.Flag("hosts", "Hosts per cluster").Default(strconv.Itoa(loadgen.DEFAULT_HOSTS)).Int()
+	opts.Loadgen.Workers = loadgenCmd.Flag("workers", "Requests to have in flight at once").Default(strconv.Itoa(loadgen.DEFAULT_WORKERS)).Int()
+	opts.Loadgen.Duration = loadgenCmd.Flag("duration", "How long to run for, until interrupted when unset").Duration()
+
+	topCmd := kingpin.Command("top", "Watch a running superside from the terminal")
+	opts.Top.Target = topCmd.Flag("target", "The superside to watch").Default("http://localhost:7779").String()
+	opts.Top.Refresh = topCmd.Flag("refresh", "How often to update the clusters and flapping services").Default(top.DEFAULT_REFRESH.String()).Duration()
+
+	// Running without a command runs the server, so don't insist on one
+	opts.Command = kingpin.MustParse(kingpin.CommandLine.Parse(os.Args[1:]))
 	return &opts
 }
 
+// Send made-up Sidecar events to a superside until we're told to stop or
+// the duration is up, reporting on how it's keeping up as we go
+func runLoadgen(opts *LoadgenOpts) {
+	rate, err := loadgen.ParseRate(*opts.Rate)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *opts.Clusters < 1 || *opts.Services < 1 || *opts.Hosts < 1 || *opts.Workers < 1 {
+		log.Fatal("Clusters, services, hosts and workers must all be at least 1")
+	}
+
+	fleet := loadgen.NewFleet(*opts.Clusters, *opts.Services, *opts.Hosts, time.Now().UnixNano())
+	generator := loadgen.NewGenerator(*opts.Target, rate, fleet)
+	generator.Workers = *opts.Workers
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if *opts.Duration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), *opts.Duration)
+	}
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(loadgen.REPORT_INTERVAL):
+				log.Infof("So far: %s", generator.Stats())
+			}
+		}
+	}()
+
+	log.Infof("Sending %.1f events/s to %s", rate, *opts.Target)
+	log.Infof("Done: %s", generator.Run(ctx))
+}
+
+// Show a live dashboard of a superside until the user quits
+func runTop(opts *TopOpts) {
+	// Anything logged would scribble over the screen
+	log.SetOutput(ioutil.Discard)
+
+	dashboard := top.NewDashboard(client.New(*opts.Target))
+	dashboard.Refresh = *opts.Refresh
+
+	// Bubble Tea quits on Ctrl-C, q or a signal by itself
+	err := dashboard.Run(context.Background(), os.Stdin, os.Stdout)
+	if err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Unable to run the dashboard: %s", err.Error())
+	}
+}
+
+// Load the Lua hooks from the config, in order
+func buildHooks(configs []*HookConfig) *hooks.Chain {
+	chain := hooks.NewChain()
+
+	for _, config := range configs {
+		var hook *hooks.LuaHook
+		var err error
+
+		if config.File != "" {
+			hook, err = hooks.NewLuaHookFromFile(config.Name, config.File)
+		} else {
+			hook, err = hooks.NewLuaHook(config.Name, config.Script)
+		}
+
+		if err != nil {
+			log.Fatalf("Unable to load hook: %s", err.Error())
+		}
+
+		hook.Timeout = config.timeout
+		chain.Hooks = append(chain.Hooks, hook)
+	}
+
+	return chain
+}
+
 func main() {
 	opts := parseCommandLine()
+	switch opts.Command {
+	case "loadgen":
+		runLoadgen(&opts.Loadgen)
+		return
+	case "top":
+		runTop(&opts.Top)
+		return
+	}
+
 	config := parseConfig(*opts.ConfigFile)
 
-	var store persistence.Store
+	if *opts.LogLevel != "" {
+		config.Logging.Level = *opts.LogLevel
+	}
+	if *opts.LogFormat != "" {
+		config.Logging.Format = *opts.LogFormat
+	}
+	if *opts.LogFile != "" {
+		config.Logging.File = *opts.LogFile
+	}
+	configureLogging(config.Logging)
+
+	var dataStore store.Store
 	if *opts.Persist {
-		store = persistence.NewFileStore("data/")
+		dataStore = store.NewFileStore("data/")
 	} else {
-		store = &persistence.NoopStore{}
+		dataStore = &store.NoopStore{}
+	}
+
+	state := tracker.NewTracker(tracker.INITIAL_RING_SIZE, dataStore)
+	state.Dependencies = tracker.NewDependencyMap(config.Dependencies)
+	state.Regions = tracker.NewRegionMap(config.Regions)
+	state.ClusterAliases = config.Aliases
+	state.IngestFilter.AllowClusters = config.Ingest.AllowClusters
+	state.IngestFilter.DenyClusters = config.Ingest.DenyClusters
+	state.IngestFilter.AllowServices = config.Ingest.AllowServices
+	state.IngestFilter.DenyServices = config.Ingest.DenyServices
+	if len(config.Hooks) > 0 {
+		state.Hooks = buildHooks(config.Hooks)
+		metrics.Register(state.Hooks.Dropped)
+		metrics.Register(state.Hooks.Errors)
+	}
+	if config.Snapshots.Enabled {
+		state.Snapshots = tracker.NewSnapshotStore(
+			config.Snapshots.Depth, config.Snapshots.interval,
+			config.Snapshots.Rebase,
+		)
+	}
+	state.FlapDetector = tracker.NewFlapDetector(
+		config.Flapping.Threshold, config.Flapping.window,
+	)
+	state.Correlator.Window = config.Correlation.window
+	for _, severity := range config.Severities {
+		state.Classifier.Rules = append(state.Classifier.Rules, severity.rule)
+	}
+	state.Labels = tracker.NewServiceLabels(config.Labels)
+	state.Links = tracker.NewServiceLinks(config.Links)
+	state.Watchdog.Timeout = config.Watchdog.silentAfter
+	state.Anomalies.Factor = config.Anomaly.Factor
+	state.Anomalies.Window = config.Anomaly.window
+	state.Anomalies.MinEvents = config.Anomaly.MinEvents
+	state.Coalescer.Window = config.Coalesce.window
+	state.Coalescer.MaxSize = config.Coalesce.MaxSize
+	state.ClockSkew.MaxSkew = config.ClockSkew.maxSkew
+	state.HeartbeatInterval = config.Heartbeat.interval
+	state.Compactor.CompactAfter = config.Retention.compactAfter
+	state.Compactor.TTL = config.Retention.ttl
+	metrics.Register(state.StateDurations.Histograms)
+	metrics.Register(state.IngestLatency)
+	metrics.Register(state.EventCounts)
+	metrics.Register(state.ClockSkew.Events)
+	metrics.Register(state.ClockSkew.Offsets)
+	metrics.Register(state.IngestFilter.Discarded)
+	metrics.Register(notify.Deliveries)
+	metrics.Register(notify.CircuitOpen)
+	metrics.Register(sinks.SinkHealthy)
+	metrics.Register(sinks.SinkLastSuccess)
+	metrics.Register(server.Panics)
+
+	if config.Backfill.Peer != "" {
+		backfill(state, config.Backfill.Peer, config.Backfill.timeout)
 	}
 
-	state = tracker.NewTracker(tracker.INITIAL_RING_SIZE, store)
+	expvar.Publish("tracker", expvar.Func(func() interface{} {
+		return state.Vars()
+	}))
 	go state.ProcessUpdates()
 	go state.ManagePersistence()
 
-	serveHttp(config.Superside.BindIP, config.Superside.BindPort, state)
+	retries := notify.NewRetryQueue(dataStore, notify.DefaultRegistry)
+	retries.MaxAttempts = config.Retry.MaxAttempts
+	retries.Backoff = config.Retry.backoff
+	retries.MaxBackoff = config.Retry.maxBackoff
+	if *opts.Persist {
+		err := retries.Load()
+		if err != nil {
+			log.Errorf("Unable to load the retry queue: %s", err.Error())
+		}
+	}
+	go retries.Run()
+
+	if config.Slack.WebhookUrl != "" {
+		slack := notify.NewSlackNotifier(
+			config.Slack.WebhookUrl, config.Slack.Channel, config.Slack.Username,
+		)
+		if config.Slack.Template != "" {
+			var err error
+			slack.Template, err = notify.ParseTemplate("slack", config.Slack.Template)
+			if err != nil {
+				log.Fatalf("Invalid Slack template: %s", err.Error())
+			}
+		}
+		dispatcher := notify.NewDispatcher(
+			*config.Slack.DampenFlapping, notify.DefaultRegistry.Register(slack),
+		)
+		dispatcher.RepeatInterval = config.Slack.repeatInterval
+		dispatcher.Regions = config.Slack.Regions
+		dispatcher.Retries = retries
+		lanes := state.GetPriorityListener()
+		go dispatcher.RunLanes(lanes.Critical, lanes.Routine)
+	}
+
+	for _, settings := range config.Notifiers {
+		dispatcher, err := notify.NewDispatcherFromSettings(settings, notify.DefaultRegistry)
+		if err != nil {
+			log.Fatalf("Invalid notifier config: %s", err.Error())
+		}
+		dispatcher.Retries = retries
+		lanes := state.GetPriorityListener()
+		go dispatcher.RunLanes(lanes.Critical, lanes.Routine)
+	}
+
+	for _, report := range config.Digests {
+		generator := digest.NewGenerator(report.Name, report.schedule, report.Notifiers...)
+		generator.Location = report.location
+		generator.Top = report.Top
+		go generator.Run(state.GetStorageListener())
+	}
+
+	if config.Alertmanager.Url != "" {
+		am := notify.NewAlertmanager(config.Alertmanager.Url, config.Alertmanager.Labels)
+		am.GeneratorURL = config.Alertmanager.GeneratorUrl
+		go am.Run(state.GetStorageListener())
+	}
+
+	var sinkList []sinks.Sink
+
+	if config.Elastic.Url != "" {
+		elastic := sinks.NewElasticsearchSink(config.Elastic.Url, config.Elastic.Index)
+		err := elastic.EnsureIndex()
+		if err != nil {
+			log.Errorf("Unable to set up Elasticsearch index: %s", err.Error())
+		}
+		batcher := sinks.NewBatcher("Elasticsearch",
+			config.Elastic.BatchSize, config.Elastic.flushInterval, elastic.Write,
+		)
+		batcher.Log = notify.DefaultRegistry.Log
+		go batcher.Run(state.GetStorageListener())
+		sinkList = append(sinkList, batcher)
+	}
+
+	if config.Influx.Url != "" {
+		influx := sinks.NewInfluxSink(
+			config.Influx.Url, config.Influx.Database, config.Influx.Token,
+		)
+		batcher := sinks.NewBatcher("InfluxDB",
+			config.Influx.BatchSize, config.Influx.flushInterval, influx.Write,
+		)
+		batcher.Log = notify.DefaultRegistry.Log
+		go batcher.Run(state.GetStorageListener())
+		sinkList = append(sinkList, batcher)
+	}
+
+	if config.ClickHouse.Url != "" {
+		clickhouse := sinks.NewClickHouseSink(config.ClickHouse.Url,
+			config.ClickHouse.Table, config.ClickHouse.User, config.ClickHouse.Password,
+		)
+		if config.ClickHouse.CreateTable {
+			err := clickhouse.EnsureTable()
+			if err != nil {
+				log.Errorf("Unable to create ClickHouse table: %s", err.Error())
+			}
+		}
+		batcher := sinks.NewBatcher("ClickHouse",
+			config.ClickHouse.BatchSize, config.ClickHouse.flushInterval, clickhouse.Write,
+		)
+		batcher.Async = true
+		batcher.Log = notify.DefaultRegistry.Log
+		go batcher.Run(state.GetStorageListener())
+		sinkList = append(sinkList, batcher)
+	}
+
+	if config.Grafana.Url != "" {
+		filter, err := datatypes.ParseTransitionFilter(config.Grafana.Transitions)
+		if err != nil {
+			log.Fatalf("Invalid Grafana transitions: %s", err.Error())
+		}
+		grafana := sinks.NewGrafanaSink(config.Grafana.Url, config.Grafana.ApiKey, filter)
+		grafana.Deployments = *config.Grafana.Deployments
+		go grafana.Run(state.GetStorageListener(), state.GetDeploymentListener())
+		sinkList = append(sinkList, grafana)
+	}
+
+	if config.Github.Token != "" {
+		github := sinks.NewGithubDeployments(config.Github.Token)
+		github.ApiUrl = config.Github.ApiUrl
+		github.Timeout = config.Github.timeout
+		github.State = state.ClusterViews
+		go github.Run(state.GetStorageListener(), state.GetDeploymentListener())
+		sinkList = append(sinkList, github)
+	}
+
+	if config.Docker.Enabled {
+		watcher, err := dockerevents.NewWatcher(
+			config.Docker.Endpoint, config.Docker.ClusterName, state.EnqueueUpdate,
+		)
+		if err != nil {
+			log.Fatalf("Unable to connect to Docker: %s", err.Error())
+		}
+		go watcher.Run()
+	}
+
+	subscriptions := notify.NewSubscriptions(dataStore)
+	if *opts.Persist {
+		err := subscriptions.Load()
+		if err != nil {
+			log.Errorf("Unable to load subscriptions: %s", err.Error())
+		}
+	}
+	go subscriptions.Run(state.GetSvcEventsListener())
+
+	var keyStore store.Store
+	if config.Idempotency.Persist {
+		keyStore = dataStore
+	}
+	idempotency := server.NewIdempotencyCache(config.Idempotency.Size, config.Idempotency.ttl, keyStore)
+	err := idempotency.Load(time.Now().UTC())
+	if err != nil {
+		log.Errorf("Unable to load idempotency keys: %s", err.Error())
+	}
+	go idempotency.ManagePersistence()
+
+	srv := server.New(state,
+		server.WithListenAddress(config.Superside.BindIP, config.Superside.BindPort),
+		server.WithWebsocket(
+			config.Websocket.ReadBuffer, config.Websocket.WriteBuffer, config.Websocket.AllowedOrigins,
+		),
+		server.WithSubscriptions(subscriptions),
+		server.WithNotifiers(notify.DefaultRegistry),
+		server.WithRetryQueue(retries),
+		server.WithAdminToken(config.Superside.AdminToken),
+		server.WithUpdateAcks(config.Superside.AckKey),
+		server.WithDisplayTimezone(config.Superside.location),
+		server.WithBasePath(config.Superside.BasePath),
+		server.WithIdempotency(idempotency),
+		server.WithSinks(config.Readiness.BrokenSink, sinkList...),
+		server.WithStatusPage(
+			config.StatusPage.Title, config.StatusPage.Services, config.StatusPage.maxAge,
+		),
+	)
+	go handleRestarts(srv, state, idempotency)
+
+	err = srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		select {} // handleRestarts is draining and will exit for us
+	}
+	if err != nil {
+		log.Fatalf("Can't start http server: %s", err.Error())
+	}
+}
+
+// Catch up on the history a peer has. Not being able to is no reason not
+// to start, we'll just know less for a while.
+func backfill(state *tracker.Tracker, peerUrl string, timeout time.Duration) {
+	notices, err := server.FetchPeerEvents(peerUrl, timeout)
+	if err != nil {
+		log.Warnf("Unable to backfill from %s: %s", peerUrl, err.Error())
+		return
+	}
+
+	log.Infof("Backfilled %d events from %s", state.Backfill(notices), peerUrl)
+}
+
+// On SIGUSR2, start a new copy of ourselves on the same socket and bow out
+// once it's serving. State is persisted first so the new process loads it.
+// Before that, maintenance mode turns updates away, so none are acknowledged
+// and then lost, and stops the persistence loop, so it can't overwrite what
+// the new process stores.
+func handleRestarts(srv *server.Server, state *tracker.Tracker, idempotency *server.IdempotencyCache) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+
+	for range signals {
+		log.Info("Got SIGUSR2, restarting")
+		previous := srv.Maintenance()
+		srv.SetMaintenance(true, "Restarting")
+		if !state.WaitForUpdates(server.SHUTDOWN_TIMEOUT) {
+			log.Warn("Timed out processing the updates already taken, some may not be persisted")
+		}
+		state.Persist()
+		idempotency.Save()
+
+		err := srv.Restart()
+		if err != nil {
+			log.Errorf("Restart failed, carrying on: %s", err.Error())
+			srv.SetMaintenance(previous.Enabled, previous.Reason)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), server.SHUTDOWN_TIMEOUT)
+		err = srv.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			log.Warnf("Not everything drained before shutting down: %s", err.Error())
+		}
+
+		os.Exit(0)
+	}
 }
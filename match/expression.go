@@ -0,0 +1,572 @@
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Match expressions use a small subset of CEL (https://github.com/google/cel-spec):
+//
+//	cluster.startsWith("prod-") && status == UNHEALTHY
+//	service in ["db", "queue"] || region != "europe"
+//	!(hostname.matches("^ci-[0-9]+$"))
+//
+// The whole grammar, loosest binding first:
+//
+//	expr     = and { "||" and }
+//	and      = unary { "&&" unary }
+//	unary    = "!" unary | relation
+//	relation = member [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) member ]
+//	member   = primary { "." method "(" expr ")" }
+//	method   = "startsWith" | "endsWith" | "contains" | "matches"
+//	primary  = string | number | constant | variable | "size" "(" expr ")"
+//	         | "[" [ expr { "," expr } ] "]" | "(" expr ")"
+//
+// Strings are in single or double quotes, with \n, \t and backslash quoting
+// anything else. Numbers are decimals, and all compare as floats. The
+// constants are true, false and the names in constants below. The variables
+// are the fields from the notification listed in Variables(), plus any
+// passed to CompileWith(). The methods work on strings, matches() taking an
+// RE2 regular expression, and size() on strings and lists.
+//
+// Where it differs from CEL: there's no arithmetic, ?:, maps, field access
+// or macros like has() and exists(). A relation can't be chained, and "!"
+// negates the whole of it, so !a == b means !(a == b). == and != between
+// values of different types are false and true rather than errors.
+type Expression struct {
+	Source string
+	root   node
+}
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+// The status and severity names, so expressions can say status == UNHEALTHY
+// or severity == CRITICAL
+var constants = map[string]interface{}{
+	"true":      true,
+	"false":     false,
+	"ALIVE":     datatypes.StatusString(service.ALIVE),
+	"TOMBSTONE": datatypes.StatusString(service.TOMBSTONE),
+	"UNHEALTHY": datatypes.StatusString(service.UNHEALTHY),
+	"UNKNOWN":   datatypes.StatusString(service.UNKNOWN),
+	"DRAINING":  datatypes.StatusString(datatypes.DRAINING),
+	"INFO":      datatypes.SEVERITY_INFO,
+	"WARNING":   datatypes.SEVERITY_WARNING,
+	"CRITICAL":  datatypes.SEVERITY_CRITICAL,
+}
+
+// What an expression can see of a notification
+func Variables(notice *datatypes.Notification) map[string]interface{} {
+	vars := map[string]interface{}{
+		"id":               notice.ID,
+		"type":             notice.Type,
+		"cluster":          notice.ClusterName,
+		"original_cluster": notice.OriginalClusterName,
+		"region":           notice.Region,
+		"source":           notice.Source,
+		"flapping":         notice.Flapping,
+		"suppressed":       notice.Suppressed,
+		"severity":         notice.Severity,
+		"labels":           labelList(notice),
+		"service":          "",
+		"service_id":       "",
+		"hostname":         "",
+		"image":            "",
+		"status":           "",
+		"previous_status":  "",
+	}
+
+	if notice.Event != nil {
+		svc := &notice.Event.Service
+		vars["service"] = svc.Name
+		vars["service_id"] = svc.ID
+		vars["hostname"] = svc.Hostname
+		vars["image"] = svc.Image
+		vars["status"] = datatypes.StatusString(svc.Status)
+		vars["previous_status"] = datatypes.StatusString(notice.Event.PreviousStatus)
+	}
+
+	return vars
+}
+
+var knownVariables = Variables(&datatypes.Notification{})
+
+// So expressions can say "team=payments" in labels
+func labelList(notice *datatypes.Notification) []interface{} {
+	pairs := notice.LabelPairs()
+	list := make([]interface{}, 0, len(pairs))
+	for _, pair := range pairs {
+		list = append(list, pair)
+	}
+
+	return list
+}
+
+func Compile(source string) (*Expression, error) {
+	return CompileWith(source)
+}
+
+// Like Compile, but the expression may also use these variables, which
+// have to be passed to MatchesWith()
+func CompileWith(source string, extra ...string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, extra: make(map[string]bool, len(extra))}
+	for _, name := range extra {
+		p.extra[name] = true
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != TOKEN_EOF {
+		return nil, fmt.Errorf("Unexpected '%s' at position %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expression{Source: source, root: root}, nil
+}
+
+// Evaluate the expression against a notification. It has to come out as a
+// boolean.
+func (e *Expression) Matches(notice *datatypes.Notification) (bool, error) {
+	return e.MatchesWith(notice, nil)
+}
+
+// Evaluate the expression with extra variables alongside the notification's
+func (e *Expression) MatchesWith(notice *datatypes.Notification, extra map[string]interface{}) (bool, error) {
+	vars := Variables(notice)
+	for name, value := range extra {
+		vars[name] = value
+	}
+
+	result, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	matched, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("Expression '%s' isn't true or false", e.Source)
+	}
+
+	return matched, nil
+}
+
+// Recursive descent, loosest binding first
+type parser struct {
+	tokens []token
+	pos    int
+	extra  map[string]bool // Variables allowed on top of the notification's
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != TOKEN_EOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) accept(operator string) bool {
+	if tok := p.peek(); tok.kind == TOKEN_OPERATOR && tok.text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(operator string) error {
+	if !p.accept(operator) {
+		tok := p.peek()
+		return fmt.Errorf("Expected '%s' at position %d", operator, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right node
+		right, err = p.parseAnd()
+		left = &logicalNode{"||", left, right}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right node
+		right, err = p.parseUnary()
+		left = &logicalNode{"&&", left, right}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		return &notNode{operand}, err
+	}
+	return p.parseRelation()
+}
+
+func (p *parser) parseRelation() (node, error) {
+	left, err := p.parseMember()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	isRelation := tok.kind == TOKEN_OPERATOR && strings.Contains(" == != < <= > >= ", " "+tok.text+" ")
+	if !isRelation && !(tok.kind == TOKEN_IDENT && tok.text == "in") {
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseMember()
+	if err != nil {
+		return nil, err
+	}
+
+	return &compareNode{tok.text, left, right}, nil
+}
+
+func (p *parser) parseMember() (node, error) {
+	target, err := p.parsePrimary()
+	for err == nil && p.accept(".") {
+		name := p.next()
+		if name.kind != TOKEN_IDENT {
+			return nil, fmt.Errorf("Expected a method name at position %d", name.pos)
+		}
+
+		var args []node
+		args, err = p.parseArgs("(", ")")
+		if err == nil {
+			target, err = newMethodNode(name.text, target, args)
+		}
+	}
+	return target, err
+}
+
+func (p *parser) parseArgs(open string, close string) ([]node, error) {
+	if err := p.expect(open); err != nil {
+		return nil, err
+	}
+
+	var args []node
+	for !p.accept(close) {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+
+	switch tok.kind {
+	case TOKEN_STRING, TOKEN_NUMBER:
+		p.next()
+		return &literalNode{tok.value}, nil
+
+	case TOKEN_IDENT:
+		p.next()
+		if value, ok := constants[tok.text]; ok {
+			return &literalNode{value}, nil
+		}
+		if tok.text == "size" {
+			args, err := p.parseArgs("(", ")")
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != 1 {
+				return nil, fmt.Errorf("size() takes one argument")
+			}
+			return &sizeNode{args[0]}, nil
+		}
+		if _, ok := knownVariables[tok.text]; !ok && !p.extra[tok.text] {
+			return nil, fmt.Errorf("Unknown variable '%s' at position %d", tok.text, tok.pos)
+		}
+		return &variableNode{tok.text}, nil
+
+	case TOKEN_OPERATOR:
+		if tok.text == "[" {
+			items, err := p.parseArgs("[", "]")
+			return &listNode{items}, err
+		}
+		if p.accept("(") {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+
+	if tok.kind == TOKEN_EOF {
+		return nil, fmt.Errorf("Unexpected end of expression")
+	}
+	return nil, fmt.Errorf("Unexpected '%s' at position %d", tok.text, tok.pos)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return vars[n.name], nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(vars map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func evalBool(n node, vars map[string]interface{}) (bool, error) {
+	value, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("Expected true or false, got %#v", value)
+	}
+	return result, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(vars map[string]interface{}) (interface{}, error) {
+	value, err := evalBool(n.operand, vars)
+	return !value, err
+}
+
+type logicalNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *logicalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(n.left, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit
+	if (n.operator == "&&" && !left) || (n.operator == "||" && left) {
+		return left, nil
+	}
+
+	return evalBool(n.right, vars)
+}
+
+type compareNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *compareNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("The right side of 'in' must be a list")
+		}
+		for _, item := range list {
+			if item == left {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return order(n.operator, left, right)
+}
+
+// <, <=, > and >= for numbers and strings
+func order(operator string, left interface{}, right interface{}) (interface{}, error) {
+	var cmp int
+
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("Can't compare %#v with %#v", left, right)
+		}
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("Can't compare %#v with %#v", left, right)
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("Can't compare %#v with %#v", left, right)
+	}
+
+	switch operator {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+type sizeNode struct {
+	arg node
+}
+
+func (n *sizeNode) eval(vars map[string]interface{}) (interface{}, error) {
+	value, err := n.arg.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return float64(len(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, fmt.Errorf("Can't take the size of %#v", value)
+}
+
+type methodNode struct {
+	name   string
+	target node
+	arg    node
+	regex  *regexp.Regexp // Pre-compiled for matches() with a literal
+}
+
+func newMethodNode(name string, target node, args []node) (node, error) {
+	switch name {
+	case "startsWith", "endsWith", "contains", "matches":
+	default:
+		return nil, fmt.Errorf("Unknown method '%s'", name)
+	}
+
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s() takes one argument", name)
+	}
+
+	method := &methodNode{name: name, target: target, arg: args[0]}
+
+	if literal, ok := args[0].(*literalNode); ok && name == "matches" {
+		pattern, ok := literal.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches() needs a string")
+		}
+
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Bad regular expression: %s", err.Error())
+		}
+		method.regex = regex
+	}
+
+	return method, nil
+}
+
+func (n *methodNode) eval(vars map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	arg, err := n.arg.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	str, ok := target.(string)
+	argStr, argOk := arg.(string)
+	if !ok || !argOk {
+		return nil, fmt.Errorf("%s() works on strings", n.name)
+	}
+
+	switch n.name {
+	case "startsWith":
+		return strings.HasPrefix(str, argStr), nil
+	case "endsWith":
+		return strings.HasSuffix(str, argStr), nil
+	case "contains":
+		return strings.Contains(str, argStr), nil
+	}
+
+	regex := n.regex
+	if regex == nil {
+		regex, err = regexp.Compile(argStr)
+		if err != nil {
+			return nil, fmt.Errorf("Bad regular expression: %s", err.Error())
+		}
+	}
+	return regex.MatchString(str), nil
+}
@@ -0,0 +1,89 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Expression(t *testing.T) {
+	Convey("Match expressions", t, func() {
+		notice := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "prod-france",
+			Region:      "europe",
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{
+					Name: "db", Hostname: "verdun-12", Status: service.UNHEALTHY,
+				},
+				PreviousStatus: service.ALIVE,
+			},
+		}
+
+		matches := func(source string) bool {
+			expr, err := Compile(source)
+			So(err, ShouldBeNil)
+
+			matched, err := expr.Matches(notice)
+			So(err, ShouldBeNil)
+			return matched
+		}
+
+		Convey("Compares fields", func() {
+			So(matches(`status == UNHEALTHY && previous_status == "Alive"`), ShouldBeTrue)
+			So(matches(`cluster != 'prod-france'`), ShouldBeFalse)
+			So(matches(`service < "queue" && size(hostname) >= 9`), ShouldBeTrue)
+		})
+
+		Convey("Handles lists, methods and grouping", func() {
+			So(matches(`service in ["db", "queue"]`), ShouldBeTrue)
+			So(matches(`cluster.startsWith("prod-") && !(region == "asia")`), ShouldBeTrue)
+			So(matches(`hostname.matches("^verdun-[0-9]+$") || false`), ShouldBeTrue)
+			So(matches(`image.contains("nginx") || cluster.endsWith("belgium")`), ShouldBeFalse)
+		})
+
+		Convey("Short circuits", func() {
+			So(matches(`false && size(1) == 1`), ShouldBeFalse)
+		})
+
+		Convey("Rejects bad expressions", func() {
+			for _, source := range []string{
+				`cluster ==`, `general == "joffre"`, `cluster.shout("x")`,
+				`"unterminated`, `hostname.matches("[")`, `(status == ALIVE`, `cluster # 1`,
+			} {
+				_, err := Compile(source)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Reports expressions that aren't true or false", func() {
+			expr, err := Compile(`cluster`)
+			So(err, ShouldBeNil)
+
+			_, err = expr.Matches(notice)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Takes extra variables when asked to", func() {
+			_, err := Compile(`instances_alive < 2`)
+			So(err, ShouldNotBeNil)
+
+			expr, err := CompileWith(`instances_alive < 2 && "tier=1" in labels`, "instances_alive", "labels")
+			So(err, ShouldBeNil)
+
+			matched, err := expr.MatchesWith(notice, map[string]interface{}{
+				"instances_alive": float64(1), "labels": []interface{}{"tier=1"},
+			})
+			So(err, ShouldBeNil)
+			So(matched, ShouldBeTrue)
+		})
+
+		Convey("Knows the severities", func() {
+			notice.Severity = "critical"
+			So(matches(`severity == CRITICAL`), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	TOKEN_EOF = iota
+	TOKEN_IDENT
+	TOKEN_STRING
+	TOKEN_NUMBER
+	TOKEN_OPERATOR
+)
+
+type token struct {
+	kind  int
+	text  string
+	value interface{} // Parsed strings and numbers
+	pos   int
+}
+
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", ".",
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(input); {
+		char := rune(input[pos])
+
+		switch {
+		case unicode.IsSpace(char):
+			pos++
+
+		case char == '"' || char == '\'':
+			text, value, err := lexString(input[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("%s at position %d", err.Error(), pos)
+			}
+			tokens = append(tokens, token{TOKEN_STRING, text, value, pos})
+			pos += len(text)
+
+		case unicode.IsDigit(char):
+			end := pos
+			for end < len(input) && (unicode.IsDigit(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+			value, err := strconv.ParseFloat(input[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Bad number '%s' at position %d", input[pos:end], pos)
+			}
+			tokens = append(tokens, token{TOKEN_NUMBER, input[pos:end], value, pos})
+			pos = end
+
+		case unicode.IsLetter(char) || char == '_':
+			end := pos
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) ||
+				unicode.IsDigit(rune(input[end])) || input[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{TOKEN_IDENT, input[pos:end], nil, pos})
+			pos = end
+
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(input[pos:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("Unexpected '%c' at position %d", char, pos)
+			}
+			tokens = append(tokens, token{TOKEN_OPERATOR, operator, nil, pos})
+			pos += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: TOKEN_EOF, pos: len(input)}), nil
+}
+
+// Read a quoted string, returning the raw text and the unquoted value
+func lexString(input string) (string, string, error) {
+	quote := input[0]
+	var value strings.Builder
+
+	for i := 1; i < len(input); i++ {
+		switch input[i] {
+		case quote:
+			return input[:i+1], value.String(), nil
+		case '\\':
+			i++
+			if i >= len(input) {
+				break
+			}
+			switch input[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(input[i])
+			}
+		default:
+			value.WriteByte(input[i])
+		}
+	}
+
+	return "", "", fmt.Errorf("Unterminated string")
+}
@@ -0,0 +1,370 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A small, dependency-free set of metric types that can render themselves in
+// the Prometheus text exposition format. We only need labelled counters,
+// gauges and histograms, so that's all there is.
+
+type Collector interface {
+	WritePrometheus(w io.Writer)
+}
+
+type Registry struct {
+	collectors []Collector
+	lock       sync.Mutex
+}
+
+var DefaultRegistry = &Registry{}
+
+func (r *Registry) Register(collector Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, collector)
+}
+
+func (r *Registry) WritePrometheus(w io.Writer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, collector := range r.collectors {
+		collector.WritePrometheus(w)
+	}
+}
+
+// Serves all the registered metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(response http.ResponseWriter, req *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WritePrometheus(response)
+}
+
+func Register(collector Collector) {
+	DefaultRegistry.Register(collector)
+}
+
+// Join label values into a map key. The separator can't appear in a label.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extra[i], strconv.Quote(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A set of counters partitioned by label values
+type CounterVec struct {
+	Name       string
+	Help       string
+	LabelNames []string
+	values     map[string]float64
+	labels     map[string][]string
+	lock       sync.RWMutex
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		Name:       name,
+		Help:       help,
+		LabelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := labelKey(labelValues)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.values[key] += value
+	c.labels[key] = labelValues
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.values[labelKey(labelValues)]
+}
+
+// A point-in-time copy of one labelled counter
+type CounterSnapshot struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Return copies of all the counters, ordered by label values
+func (c *CounterVec) Snapshots() []CounterSnapshot {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	snaps := make([]CounterSnapshot, 0, len(c.values))
+	for _, key := range sortedKeys(c.labels) {
+		snap := CounterSnapshot{Labels: make(map[string]string, len(c.LabelNames)), Value: c.values[key]}
+		for i, name := range c.LabelNames {
+			snap.Labels[name] = c.labels[key][i]
+		}
+		snaps = append(snaps, snap)
+	}
+
+	return snaps
+}
+
+// Add the snapshots back on, e.g. to carry the totals over a restart
+func (c *CounterVec) Restore(snaps []CounterSnapshot) {
+	for _, snap := range snaps {
+		c.Add(snap.Value, labelValues(c.LabelNames, snap.Labels)...)
+	}
+}
+
+// The values of the named labels, in order
+func labelValues(names []string, labels map[string]string) []string {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, labels[name])
+	}
+	return values
+}
+
+func (c *CounterVec) WritePrometheus(w io.Writer) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.Name, c.Help, c.Name)
+	for _, key := range sortedKeys(c.labels) {
+		fmt.Fprintf(w, "%s%s %s\n",
+			c.Name, formatLabels(c.LabelNames, c.labels[key]), formatFloat(c.values[key]),
+		)
+	}
+}
+
+// A set of gauges partitioned by label values
+type GaugeVec struct {
+	CounterVec
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{*NewCounterVec(name, help, labelNames...)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	key := labelKey(labelValues)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.values[key] = value
+	g.labels[key] = labelValues
+}
+
+func (g *GaugeVec) WritePrometheus(w io.Writer) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.Name, g.Help, g.Name)
+	for _, key := range sortedKeys(g.labels) {
+		fmt.Fprintf(w, "%s%s %s\n",
+			g.Name, formatLabels(g.LabelNames, g.labels[key]), formatFloat(g.values[key]),
+		)
+	}
+}
+
+// A point-in-time copy of one labelled histogram
+type HistogramSnapshot struct {
+	Labels  map[string]string
+	Count   uint64
+	Sum     float64
+	Buckets map[string]uint64 // Upper bound => cumulative count
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// A set of histograms with the same bucket bounds, partitioned by label values
+type HistogramVec struct {
+	Name       string
+	Help       string
+	LabelNames []string
+	Buckets    []float64 // Upper bounds, sorted ascending
+	histograms map[string]*histogram
+	lock       sync.RWMutex
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{
+		Name:       name,
+		Help:       help,
+		LabelNames: labelNames,
+		Buckets:    sorted,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := labelKey(labelValues)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{labels: labelValues, counts: make([]uint64, len(h.Buckets))}
+		h.histograms[key] = hist
+	}
+
+	for i, bound := range h.Buckets {
+		if value <= bound {
+			hist.counts[i] += 1
+			break
+		}
+	}
+	hist.count += 1
+	hist.sum += value
+}
+
+func (h *HistogramVec) snapshot(hist *histogram) HistogramSnapshot {
+	snap := HistogramSnapshot{
+		Labels:  make(map[string]string, len(h.LabelNames)),
+		Count:   hist.count,
+		Sum:     hist.sum,
+		Buckets: make(map[string]uint64, len(h.Buckets)+1),
+	}
+
+	for i, name := range h.LabelNames {
+		snap.Labels[name] = hist.labels[i]
+	}
+
+	var cumulative uint64
+	for i, bound := range h.Buckets {
+		cumulative += hist.counts[i]
+		snap.Buckets[formatFloat(bound)] = cumulative
+	}
+	snap.Buckets["+Inf"] = hist.count
+
+	return snap
+}
+
+// Return copies of all the histograms, ordered by label values
+func (h *HistogramVec) Snapshots() []HistogramSnapshot {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	snaps := make([]HistogramSnapshot, 0, len(keys))
+	for _, key := range keys {
+		snaps = append(snaps, h.snapshot(h.histograms[key]))
+	}
+
+	return snaps
+}
+
+// Add the snapshots back on, e.g. to carry the totals over a restart.
+// Observations in buckets we no longer have only count towards +Inf.
+func (h *HistogramVec) Restore(snaps []HistogramSnapshot) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, snap := range snaps {
+		values := labelValues(h.LabelNames, snap.Labels)
+		key := labelKey(values)
+
+		hist, ok := h.histograms[key]
+		if !ok {
+			hist = &histogram{labels: values, counts: make([]uint64, len(h.Buckets))}
+			h.histograms[key] = hist
+		}
+
+		var previous uint64
+		for i, bound := range h.Buckets {
+			cumulative, ok := snap.Buckets[formatFloat(bound)]
+			if !ok || cumulative < previous {
+				continue
+			}
+			hist.counts[i] += cumulative - previous
+			previous = cumulative
+		}
+		hist.count += snap.Count
+		hist.sum += snap.Sum
+	}
+}
+
+func (h *HistogramVec) WritePrometheus(w io.Writer) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.Name, h.Help, h.Name)
+
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.histograms[key]
+
+		var cumulative uint64
+		for i, bound := range h.Buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n",
+				h.Name, formatLabels(h.LabelNames, hist.labels, "le", formatFloat(bound)), cumulative,
+			)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n",
+			h.Name, formatLabels(h.LabelNames, hist.labels, "le", "+Inf"), hist.count,
+		)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.Name, formatLabels(h.LabelNames, hist.labels), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.Name, formatLabels(h.LabelNames, hist.labels), hist.count)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_HistogramVec(t *testing.T) {
+	Convey("HistogramVec", t, func() {
+		hist := NewHistogramVec("test_seconds", "A test histogram", []float64{10, 1}, "service")
+
+		hist.Observe(0.5, "db")
+		hist.Observe(5, "db")
+		hist.Observe(50, "db")
+
+		Convey("Keeps cumulative bucket counts", func() {
+			snaps := hist.Snapshots()
+
+			So(len(snaps), ShouldEqual, 1)
+			So(snaps[0].Labels["service"], ShouldEqual, "db")
+			So(snaps[0].Count, ShouldEqual, 3)
+			So(snaps[0].Sum, ShouldEqual, 55.5)
+			So(snaps[0].Buckets["1"], ShouldEqual, 1)
+			So(snaps[0].Buckets["10"], ShouldEqual, 2)
+			So(snaps[0].Buckets["+Inf"], ShouldEqual, 3)
+		})
+
+		Convey("Renders the Prometheus text format", func() {
+			var buf bytes.Buffer
+			hist.WritePrometheus(&buf)
+
+			So(buf.String(), ShouldContainSubstring, "# TYPE test_seconds histogram\n")
+			So(buf.String(), ShouldContainSubstring, `test_seconds_bucket{service="db",le="10"} 2`)
+			So(buf.String(), ShouldContainSubstring, `test_seconds_count{service="db"} 3`)
+		})
+
+		Convey("Adds restored snapshots on", func() {
+			restarted := NewHistogramVec("test_seconds", "A test histogram", []float64{10, 1}, "service")
+			restarted.Observe(0.5, "db")
+			restarted.Restore(hist.Snapshots())
+
+			snaps := restarted.Snapshots()
+			So(len(snaps), ShouldEqual, 1)
+			So(snaps[0].Count, ShouldEqual, 4)
+			So(snaps[0].Sum, ShouldEqual, 56)
+			So(snaps[0].Buckets["1"], ShouldEqual, 2)
+			So(snaps[0].Buckets["10"], ShouldEqual, 3)
+			So(snaps[0].Buckets["+Inf"], ShouldEqual, 4)
+		})
+	})
+}
+
+func Test_CounterVec(t *testing.T) {
+	Convey("CounterVec counts per label and renders them", t, func() {
+		counter := NewCounterVec("test_total", "A test counter", "cluster")
+		counter.Inc("france")
+		counter.Add(2, "france")
+		counter.Inc("belgium")
+
+		So(counter.Get("france"), ShouldEqual, 3)
+
+		var buf bytes.Buffer
+		counter.WritePrometheus(&buf)
+		So(buf.String(), ShouldEqual, "# HELP test_total A test counter\n# TYPE test_total counter\n"+
+			"test_total{cluster=\"belgium\"} 1\ntest_total{cluster=\"france\"} 3\n")
+
+		Convey("and carries them over a restart", func() {
+			restarted := NewCounterVec("test_total", "A test counter", "cluster")
+			restarted.Inc("france")
+			restarted.Restore(counter.Snapshots())
+
+			So(restarted.Get("france"), ShouldEqual, 4)
+			So(restarted.Get("belgium"), ShouldEqual, 1)
+			So(restarted.Snapshots()[0], ShouldResemble, CounterSnapshot{Labels: map[string]string{"cluster": "belgium"}, Value: 1})
+		})
+	})
+}
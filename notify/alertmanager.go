@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	// Alertmanager resolves alerts it hasn't heard about in resolve_timeout
+	// (5m by default), so firing alerts are re-sent more often than that
+	AM_RESEND_INTERVAL = 1 * time.Minute
+)
+
+// An alert in the Alertmanager v2 API format
+type amAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Sends unhealthy services, flapping services and silent clusters to
+// Alertmanager as alerts, and resolves them when they recover, so routing, grouping and
+// silencing can all be handled there. Silenced notifications are skipped, as
+// are ones that came from Alertmanager in the first place.
+type Alertmanager struct {
+	Url          string
+	Labels       map[string]string // Added to every alert, e.g. severity
+	GeneratorURL string            // Link back to the Superside UI
+	firing       map[string]*amAlert
+	client       *http.Client
+}
+
+func NewAlertmanager(amUrl string, labels map[string]string) *Alertmanager {
+	return &Alertmanager{
+		Url:    strings.TrimRight(amUrl, "/"),
+		Labels: labels,
+		firing: make(map[string]*amAlert, 10),
+		client: &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (a *Alertmanager) newAlert(name string, notice *datatypes.Notification,
+	svcName string, startsAt time.Time) *amAlert {
+
+	labels := map[string]string{
+		"alertname": name,
+		"cluster":   notice.ClusterName,
+		"service":   svcName,
+	}
+	for key, value := range a.Labels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+
+	// Not labels, or the alert wouldn't match when it's resolved later
+	annotations := map[string]string{"summary": MessageFor(notice)}
+	if notice.CorrelationID != "" {
+		annotations["correlation_id"] = notice.CorrelationID
+	}
+	for key, value := range notice.Labels {
+		if _, ok := annotations[key]; !ok {
+			annotations[key] = value
+		}
+	}
+	for name, url := range notice.Links {
+		annotations[name+"_url"] = url // e.g. runbook_url, which Alertmanager UIs link to
+	}
+
+	return &amAlert{
+		Labels:       labels,
+		Annotations:  annotations,
+		StartsAt:     startsAt,
+		GeneratorURL: a.GeneratorURL,
+	}
+}
+
+// Work out which alerts to send for a notification, updating the set of
+// firing alerts along the way
+func (a *Alertmanager) alertsFor(notice *datatypes.Notification) []*amAlert {
+	if notice.Suppressed || notice.Source == datatypes.ALERTMANAGER_SOURCE {
+		return nil
+	}
+
+	var key string
+	var alert *amAlert
+	var firing bool
+	var when time.Time
+
+	switch notice.Type {
+	case datatypes.FLAPPING_NOTICE, datatypes.STABILIZED_NOTICE:
+		if notice.Flap == nil {
+			return nil
+		}
+		key = "flapping/" + notice.ClusterName + "/" + notice.Flap.Service
+		alert = a.newAlert("ServiceFlapping", notice, notice.Flap.Service, notice.Flap.Since)
+		firing = notice.Type == datatypes.FLAPPING_NOTICE
+		when = notice.Flap.LastTransition
+
+	case datatypes.CLUSTER_SILENT_NOTICE, datatypes.CLUSTER_RESUMED_NOTICE:
+		if notice.Stale == nil {
+			return nil
+		}
+		key = "silent/" + notice.ClusterName
+		alert = a.newAlert("ClusterSilent", notice, "", notice.Stale.LastSeen)
+		firing = notice.Type == datatypes.CLUSTER_SILENT_NOTICE
+		when = time.Now().UTC()
+
+	case datatypes.SERVICE_EVENT_NOTICE:
+		if notice.Event == nil {
+			return nil
+		}
+		svc := notice.Event.Service
+		key = "unhealthy/" + instanceKey(notice)
+		alert = a.newAlert("ServiceUnhealthy", notice, svc.Name, notice.Event.Time)
+		alert.Labels["hostname"] = svc.Hostname
+		alert.Labels["instance"] = svc.ID
+		alert.Labels["image"] = svc.Image
+		firing = svc.Status == service.UNHEALTHY && !notice.Draining // Still resolves
+		when = notice.Event.Time
+
+	default:
+		return nil
+	}
+
+	if firing {
+		if existing, ok := a.firing[key]; ok {
+			alert.StartsAt = existing.StartsAt
+		}
+		a.firing[key] = alert
+		return []*amAlert{alert}
+	}
+
+	// Resolve the original alert, if there was one
+	existing, ok := a.firing[key]
+	if !ok {
+		return nil
+	}
+	delete(a.firing, key)
+
+	existing.EndsAt = &when
+	return []*amAlert{existing}
+}
+
+// Every alert that is still firing, for re-sending
+func (a *Alertmanager) firingAlerts() []*amAlert {
+	alerts := make([]*amAlert, 0, len(a.firing))
+	for _, alert := range a.firing {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func (a *Alertmanager) send(alerts []*amAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(a.Url+"/api/v2/alerts", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Alertmanager returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Loop over the notifications until the channel is closed
+func (a *Alertmanager) Run(notices chan *datatypes.Notification) {
+	ticker := time.NewTicker(AM_RESEND_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		var alerts []*amAlert
+
+		select {
+		case notice, ok := <-notices:
+			if !ok {
+				return
+			}
+			alerts = a.alertsFor(notice)
+
+		case <-ticker.C:
+			alerts = a.firingAlerts()
+		}
+
+		if len(alerts) == 0 {
+			continue
+		}
+
+		err := a.send(alerts)
+		if err != nil {
+			log.Errorf("Unable to send alerts to Alertmanager: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Alertmanager(t *testing.T) {
+	Convey("Alertmanager", t, func() {
+		failedAt := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+		recoveredAt := failedAt.Add(time.Hour)
+
+		change := func(status int, when time.Time) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{
+						ID: "deadbeef", Name: "verdun", Hostname: "meuse", Status: status,
+					},
+					Time: when,
+				},
+			}
+		}
+
+		am := NewAlertmanager("http://alertmanager/", map[string]string{"severity": "page"})
+
+		Convey("Fires an alert for unhealthy services", func() {
+			alerts := am.alertsFor(change(service.UNHEALTHY, failedAt))
+
+			So(len(alerts), ShouldEqual, 1)
+			So(alerts[0].Labels["alertname"], ShouldEqual, "ServiceUnhealthy")
+			So(alerts[0].Labels["service"], ShouldEqual, "verdun")
+			So(alerts[0].Labels["severity"], ShouldEqual, "page")
+			So(alerts[0].StartsAt, ShouldResemble, failedAt)
+			So(alerts[0].EndsAt, ShouldBeNil)
+			So(len(am.firingAlerts()), ShouldEqual, 1)
+		})
+
+		Convey("Carries the service's labels as annotations", func() {
+			notice := change(service.UNHEALTHY, failedAt)
+			notice.Labels = map[string]string{"team": "infantry", "summary": "not this"}
+			alerts := am.alertsFor(notice)
+
+			So(alerts[0].Annotations["team"], ShouldEqual, "infantry")
+			So(alerts[0].Annotations["summary"], ShouldStartWith, "[france] verdun")
+		})
+
+		Convey("Carries the service's links as _url annotations", func() {
+			notice := change(service.UNHEALTHY, failedAt)
+			notice.Links = map[string]string{"runbook": "https://wiki/verdun"}
+			alerts := am.alertsFor(notice)
+
+			So(alerts[0].Annotations["runbook_url"], ShouldEqual, "https://wiki/verdun")
+			So(alerts[0].Labels, ShouldNotContainKey, "runbook_url")
+		})
+
+		Convey("Resolves the alert when the service recovers", func() {
+			am.alertsFor(change(service.UNHEALTHY, failedAt))
+			alerts := am.alertsFor(change(service.ALIVE, recoveredAt))
+
+			So(len(alerts), ShouldEqual, 1)
+			So(alerts[0].StartsAt, ShouldResemble, failedAt)
+			So(*alerts[0].EndsAt, ShouldResemble, recoveredAt)
+			So(am.firingAlerts(), ShouldBeEmpty)
+		})
+
+		Convey("Sends nothing for recoveries it never alerted on", func() {
+			So(am.alertsFor(change(service.ALIVE, recoveredAt)), ShouldBeEmpty)
+		})
+
+		Convey("Skips silenced notifications", func() {
+			notice := change(service.UNHEALTHY, failedAt)
+			notice.Suppressed = true
+			So(am.alertsFor(notice), ShouldBeEmpty)
+		})
+
+		Convey("Doesn't send Alertmanager's own alerts back to it", func() {
+			notice := change(service.UNHEALTHY, failedAt)
+			notice.Source = datatypes.ALERTMANAGER_SOURCE
+			So(am.alertsFor(notice), ShouldBeEmpty)
+		})
+
+		Convey("Fires and resolves flapping alerts", func() {
+			flap := &datatypes.FlapStatus{
+				ClusterName: "france", Service: "verdun", Since: failedAt, LastTransition: recoveredAt,
+			}
+
+			fired := am.alertsFor(&datatypes.Notification{
+				Type: datatypes.FLAPPING_NOTICE, ClusterName: "france", Flap: flap,
+			})
+			So(fired[0].Labels["alertname"], ShouldEqual, "ServiceFlapping")
+
+			resolved := am.alertsFor(&datatypes.Notification{
+				Type: datatypes.STABILIZED_NOTICE, ClusterName: "france", Flap: flap,
+			})
+			So(*resolved[0].EndsAt, ShouldResemble, recoveredAt)
+		})
+
+		Convey("Fires and resolves silent cluster alerts", func() {
+			stale := &datatypes.StaleCluster{ClusterName: "france", LastSeen: failedAt, Timeout: time.Hour}
+
+			fired := am.alertsFor(&datatypes.Notification{
+				Type: datatypes.CLUSTER_SILENT_NOTICE, ClusterName: "france", Stale: stale,
+			})
+			So(fired[0].Labels["alertname"], ShouldEqual, "ClusterSilent")
+			So(fired[0].StartsAt, ShouldResemble, failedAt)
+
+			resolved := am.alertsFor(&datatypes.Notification{
+				Type: datatypes.CLUSTER_RESUMED_NOTICE, ClusterName: "france", Stale: stale,
+			})
+			So(resolved[0].EndsAt, ShouldNotBeNil)
+			So(am.firingAlerts(), ShouldBeEmpty)
+		})
+
+		Convey("Posts alerts to the v2 API", func() {
+			var path string
+			var received []map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				json.NewDecoder(r.Body).Decode(&received)
+			}))
+			defer server.Close()
+
+			am.Url = server.URL
+			So(am.send(am.alertsFor(change(service.UNHEALTHY, failedAt))), ShouldBeNil)
+			So(path, ShouldEqual, "/api/v2/alerts")
+			So(received[0]["startsAt"], ShouldEqual, "1916-02-21T07:15:00Z")
+		})
+	})
+}
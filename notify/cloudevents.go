@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nitro/superside/cloudevents"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Sends notifications as CloudEvents, either as structured JSON or in
+// binary mode with the attributes in headers. Notifications that arrived as
+// CloudEvents aren't sent back out, so two supersides can't loop.
+type CloudEventsNotifier struct {
+	alwaysHealthy
+	Url    string
+	Source string
+	Binary bool
+	client *http.Client
+}
+
+func init() {
+	RegisterFactory("cloudevents", func(settings Settings) (Notifier, error) {
+		if settings.String("url") == "" {
+			return nil, errors.New("url is required")
+		}
+
+		notifier := NewCloudEventsNotifier(settings.String("url"))
+		if source := settings.String("source"); source != "" {
+			notifier.Source = source
+		}
+
+		switch settings.String("mode") {
+		case "", "structured":
+		case "binary":
+			notifier.Binary = true
+		default:
+			return nil, errors.New("mode must be 'structured' or 'binary'")
+		}
+
+		return notifier, nil
+	})
+}
+
+func NewCloudEventsNotifier(url string) *CloudEventsNotifier {
+	return &CloudEventsNotifier{
+		Url:    url,
+		Source: cloudevents.DEFAULT_SOURCE,
+		client: &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (c *CloudEventsNotifier) Name() string {
+	return "cloudevents"
+}
+
+func (c *CloudEventsNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	if notice.Source == datatypes.CLOUDEVENTS_SOURCE {
+		return nil
+	}
+
+	event, err := cloudevents.FromNotification(notice, c.Source)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.Url, nil)
+	if err != nil {
+		return err
+	}
+
+	err = event.Write(req, c.Binary)
+	if err != nil {
+		return err
+	}
+
+	return doRequest(ctx, c.client, req, "CloudEvents receiver")
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nitro/superside/cloudevents"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_CloudEventsNotifier(t *testing.T) {
+	Convey("CloudEvents notifier", t, func() {
+		var received *cloudevents.Event
+		var contentType string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			received, _ = cloudevents.FromRequest(r)
+		}))
+		defer server.Close()
+
+		notifier := NewCloudEventsNotifier(server.URL)
+		notice := &datatypes.Notification{ID: "joffre", ClusterName: "france"}
+
+		Convey("Sends structured events", func() {
+			So(notifier.Notify(context.Background(), notice), ShouldBeNil)
+			So(contentType, ShouldEqual, cloudevents.STRUCTURED_TYPE)
+			So(received.ID, ShouldEqual, "joffre")
+		})
+
+		Convey("Sends binary events", func() {
+			notifier.Binary = true
+			So(notifier.Notify(context.Background(), notice), ShouldBeNil)
+			So(contentType, ShouldEqual, "application/json")
+			So(received.Source, ShouldEqual, cloudevents.DEFAULT_SOURCE)
+		})
+
+		Convey("Doesn't send back events that came in as CloudEvents", func() {
+			notice.Source = datatypes.CLOUDEVENTS_SOURCE
+			So(notifier.Notify(context.Background(), notice), ShouldBeNil)
+			So(received, ShouldBeNil)
+		})
+
+		Convey("Only knows two modes", func() {
+			_, err := NewNotifier(Settings{"type": "cloudevents", "url": server.URL, "mode": "smoke-signals"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DELIVERY_LOG_SIZE = 5000 // Notifications we keep delivery records for
+
+	// How delivering a notification went overall, see FinalStatus()
+	DELIVERY_NONE      = "none"
+	DELIVERY_DELIVERED = "delivered"
+	DELIVERY_PARTIAL   = "partial"
+	DELIVERY_FAILED    = "failed"
+)
+
+// What happened when a notifier or sink tried to deliver a notification.
+// Retries from the retry queue get a record of their own.
+type DeliveryRecord struct {
+	Notifier string // Or the name of the sink
+	Sink     bool   `json:",omitempty"`
+	Time     time.Time
+	Attempts int // 0 when the circuit breaker skipped it
+	Success  bool
+	Error    string `json:",omitempty"`
+}
+
+// The record with any URLs taken out of the error, for showing publicly
+func (r DeliveryRecord) Redacted() DeliveryRecord {
+	r.Error = RedactURLs(r.Error)
+	return r
+}
+
+// Remembers how the delivery of recent notifications went, by notification
+// ID, so the API can show where an event was sent. The oldest notifications
+// are forgotten once there are more than Size of them.
+type DeliveryLog struct {
+	Size    int
+	records map[string][]DeliveryRecord
+	order   []string // Notification IDs, oldest first
+	lock    sync.RWMutex
+}
+
+func NewDeliveryLog(size int) *DeliveryLog {
+	return &DeliveryLog{
+		Size:    size,
+		records: make(map[string][]DeliveryRecord, size),
+	}
+}
+
+func (l *DeliveryLog) Record(noticeID string, record DeliveryRecord) {
+	if noticeID == "" {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.records[noticeID]; !ok {
+		l.order = append(l.order, noticeID)
+	}
+	l.records[noticeID] = append(l.records[noticeID], record)
+
+	for len(l.order) > l.Size {
+		delete(l.records, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+// The delivery records for a notification, oldest first
+func (l *DeliveryLog) For(noticeID string) []DeliveryRecord {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return append([]DeliveryRecord{}, l.records[noticeID]...)
+}
+
+// Sum up the records: DELIVERY_DELIVERED if the last try of every notifier
+// and sink worked, DELIVERY_FAILED if none did and DELIVERY_PARTIAL in
+// between. DELIVERY_NONE if nothing tried at all.
+func FinalStatus(records []DeliveryRecord) string {
+	if len(records) == 0 {
+		return DELIVERY_NONE
+	}
+
+	// Later records win, so a successful retry makes up for a failure
+	last := make(map[string]bool, len(records))
+	for _, record := range records {
+		last[record.Notifier] = record.Success
+	}
+
+	succeeded := 0
+	for _, success := range last {
+		if success {
+			succeeded += 1
+		}
+	}
+
+	switch succeeded {
+	case len(last):
+		return DELIVERY_DELIVERED
+	case 0:
+		return DELIVERY_FAILED
+	default:
+		return DELIVERY_PARTIAL
+	}
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DeliveryLog(t *testing.T) {
+	Convey("The delivery log", t, func() {
+		log := NewDeliveryLog(2)
+
+		Convey("Keeps the records for each notification", func() {
+			log.Record("joffre", DeliveryRecord{Notifier: "slack", Success: true})
+			log.Record("joffre", DeliveryRecord{Notifier: "jira", Error: "boom"})
+
+			So(log.For("joffre"), ShouldResemble, []DeliveryRecord{
+				{Notifier: "slack", Success: true},
+				{Notifier: "jira", Error: "boom"},
+			})
+			So(log.For("foch"), ShouldBeEmpty)
+		})
+
+		Convey("Forgets the oldest notifications", func() {
+			log.Record("joffre", DeliveryRecord{Notifier: "slack"})
+			log.Record("foch", DeliveryRecord{Notifier: "slack"})
+			log.Record("petain", DeliveryRecord{Notifier: "slack"})
+
+			So(log.For("joffre"), ShouldBeEmpty)
+			So(len(log.For("petain")), ShouldEqual, 1)
+		})
+
+		Convey("Is filled in by registered notifiers", func() {
+			registry := &Registry{Log: log}
+			flaky := &flakyNotifier{failures: 1}
+			managed := registry.Register(flaky)
+			managed.Attempts = 1
+
+			managed.Deliver(context.Background(), &datatypes.Notification{ID: "nivelle"})
+			managed.Deliver(context.Background(), &datatypes.Notification{ID: "nivelle"})
+
+			records := log.For("nivelle")
+			So(len(records), ShouldEqual, 2)
+			So(records[0].Error, ShouldEqual, "carrier pigeon shot down")
+			So(records[1].Success, ShouldBeTrue)
+			So(records[1].Notifier, ShouldEqual, "pigeon")
+			So(records[1].Attempts, ShouldEqual, 1)
+		})
+
+		Convey("Counts the attempts each delivery took", func() {
+			registry := &Registry{Log: log}
+			managed := registry.Register(&flakyNotifier{failures: 2})
+			managed.Backoff = 0
+
+			managed.Deliver(context.Background(), &datatypes.Notification{ID: "nivelle"})
+
+			So(log.For("nivelle")[0].Attempts, ShouldEqual, 3)
+			So(log.For("nivelle")[0].Success, ShouldBeTrue)
+		})
+	})
+}
+
+func Test_FinalStatus(t *testing.T) {
+	Convey("The final delivery status", t, func() {
+		So(FinalStatus(nil), ShouldEqual, DELIVERY_NONE)
+
+		So(FinalStatus([]DeliveryRecord{
+			{Notifier: "slack", Success: true},
+			{Notifier: "jira", Success: true},
+		}), ShouldEqual, DELIVERY_DELIVERED)
+
+		So(FinalStatus([]DeliveryRecord{
+			{Notifier: "slack", Success: true},
+			{Notifier: "jira"},
+		}), ShouldEqual, DELIVERY_PARTIAL)
+
+		So(FinalStatus([]DeliveryRecord{{Notifier: "jira"}}), ShouldEqual, DELIVERY_FAILED)
+
+		Convey("Counts a successful retry", func() {
+			So(FinalStatus([]DeliveryRecord{
+				{Notifier: "jira"},
+				{Notifier: "jira", Success: true},
+			}), ShouldEqual, DELIVERY_DELIVERED)
+		})
+	})
+}
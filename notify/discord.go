@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DISCORD_DESCRIPTION_LIMIT = 4096
+)
+
+// Embed sidebar colours for each severity
+var discordColors = map[string]int{
+	SEVERITY_CRITICAL: 0xe01e5a,
+	SEVERITY_WARNING:  0xecb22e,
+	SEVERITY_OK:       0x2eb67d,
+	SEVERITY_INFO:     0x5865f2,
+}
+
+// Posts alerts to a Discord webhook as embeds, with the message as the
+// description and the details as inline fields. The message comes from
+// MessageFor() unless there's a Template. Routes pick their clusters with
+// the dispatcher's clusters setting.
+type DiscordNotifier struct {
+	alwaysHealthy
+	WebhookUrl string
+	Username   string
+	AvatarUrl  string    // Optional
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+type discordMessage struct {
+	Username  string         `json:"username,omitempty"`
+	AvatarUrl string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func init() {
+	RegisterFactory("discord", func(settings Settings) (Notifier, error) {
+		if settings.String("webhook_url") == "" {
+			return nil, errors.New("webhook_url is required")
+		}
+
+		username := settings.String("username")
+		if username == "" {
+			username = "superside"
+		}
+
+		discord := NewDiscordNotifier(settings.String("webhook_url"), username)
+		discord.AvatarUrl = settings.String("avatar_url")
+
+		var err error
+		discord.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return discord, nil
+	})
+}
+
+func NewDiscordNotifier(webhookUrl string, username string) *DiscordNotifier {
+	return &DiscordNotifier{
+		WebhookUrl: webhookUrl,
+		Username:   username,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (d *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+func (d *DiscordNotifier) embed(notice *datatypes.Notification) (*discordEmbed, error) {
+	text, err := renderText(notice, d.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discord rejects the whole message if a description is too long,
+	// which long digests can be
+	if len(text) > DISCORD_DESCRIPTION_LIMIT {
+		text = text[:DISCORD_DESCRIPTION_LIMIT-3] + "..."
+	}
+
+	embed := &discordEmbed{
+		Description: text,
+		Color:       discordColors[SeverityOf(notice)],
+	}
+
+	for _, field := range fieldsFor(notice) {
+		if field.Value == "" {
+			continue // Discord won't take empty field values
+		}
+		embed.Fields = append(embed.Fields, discordField{Name: field.Name, Value: field.Value, Inline: true})
+	}
+
+	if !notice.ReceivedAt.IsZero() {
+		embed.Timestamp = notice.ReceivedAt.UTC().Format(time.RFC3339)
+	}
+
+	return embed, nil
+}
+
+func (d *DiscordNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	embed, err := d.embed(notice)
+	if err != nil {
+		return err
+	}
+
+	message := discordMessage{
+		Username:  d.Username,
+		AvatarUrl: d.AvatarUrl,
+		Embeds:    []discordEmbed{*embed},
+	}
+
+	// 204 normally, 200 when the webhook URL has ?wait=true
+	return postJSON(ctx, d.client, d.WebhookUrl, message, "Discord webhook", http.StatusNoContent, http.StatusOK)
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DiscordNotifier(t *testing.T) {
+	Convey("Discord notifier", t, func() {
+		var received discordMessage
+		endpoint := newFakeEndpoint(&received, http.StatusNoContent)
+		defer endpoint.Close()
+
+		discord := NewDiscordNotifier(endpoint.URL, "superside")
+		notice := failureNotice()
+		notice.ReceivedAt = time.Date(1916, 2, 21, 7, 15, 0, 0, time.UTC)
+
+		Convey("Posts an embed", func() {
+			So(discord.Notify(context.Background(), notice), ShouldBeNil)
+
+			So(received.Username, ShouldEqual, "superside")
+			So(len(received.Embeds), ShouldEqual, 1)
+
+			embed := received.Embeds[0]
+			So(embed.Description, ShouldEqual, MessageFor(notice))
+			So(embed.Color, ShouldEqual, discordColors[SEVERITY_CRITICAL])
+			So(embed.Timestamp, ShouldEqual, "1916-02-21T07:15:00Z")
+			So(embed.Fields[0], ShouldResemble, discordField{Name: "Cluster", Value: "france", Inline: true})
+		})
+
+		Convey("Leaves out empty fields", func() {
+			notice.Event.Service.Image = ""
+			So(discord.Notify(context.Background(), notice), ShouldBeNil)
+
+			for _, field := range received.Embeds[0].Fields {
+				So(field.Name, ShouldNotEqual, "Image")
+			}
+		})
+
+		Convey("Truncates long messages", func() {
+			discord.Template, _ = ParseTemplate("test", strings.Repeat("mud ", 2000))
+			So(discord.Notify(context.Background(), notice), ShouldBeNil)
+
+			So(len(received.Embeds[0].Description), ShouldEqual, DISCORD_DESCRIPTION_LIMIT)
+			So(received.Embeds[0].Description, ShouldEndWith, "...")
+		})
+
+		Convey("Reports errors from Discord", func() {
+			endpoint.Status = http.StatusTooManyRequests
+			So(discord.Notify(context.Background(), notice), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,507 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+)
+
+const (
+	REPEAT_CHECK_INTERVAL = 30 * time.Second
+)
+
+// Reads notifications from the tracker and sends the interesting ones on to
+// the configured notifiers. When DampenFlapping is set, transitions for a
+// flapping service are swallowed and only the flapping summary and the
+// eventual recovery notice get through.
+//
+// If RepeatInterval is set, failures are re-sent at that interval until
+// someone acknowledges them or the service instance recovers. If Regions or
+// Clusters are set, only notifications from those regions or clusters are
+// sent. If Match is set, only notifications it matches are sent. Throttle
+// limits how often we go on about the same service, and Quiet holds low
+// severity notifications back overnight.
+//
+// Owners routes the notifications for services with one of those "owner"
+// labels here, e.g. the payments team's to #payments-alerts. A dispatcher
+// with Unowned set is the default route, getting the notifications for
+// services without an owner or whose owner has no route.
+//
+// If EscalateAfter is set, failures nobody has acknowledged or fixed by then
+// are also sent to the notifier named by EscalateTo. Heartbeats are only sent
+// when Heartbeats is set, and skip quiet hours and throttling. Deploys are
+// only sent when Deploys is set, and incidents when Incidents is set. Bursts
+// of events are only sent as one notification when Coalesce is set, and
+// one at a time otherwise. Service events from hosts that are draining
+// for maintenance are never sent. Deliveries that fail go to Retries, if set,
+// which finds the notifier again by name, so notifiers of the same type
+// need their own names.
+//
+// While running, each notifier delivers from a queue of its own, so a slow
+// notifier never holds up reading from the tracker. When a queue is full,
+// the notification goes to Retries instead.
+type Dispatcher struct {
+	Notifiers      []*Managed
+	DampenFlapping bool
+	RepeatInterval time.Duration
+	Regions        []string
+	Clusters       []string
+	Match          *match.Expression
+	Owners         []string
+	Unowned        bool
+	Throttle       *Throttle   // Optional
+	Quiet          *QuietHours // Optional
+	EscalateAfter  time.Duration
+	EscalateTo     string
+	Heartbeats     bool
+	Deploys        bool
+	Incidents      bool
+	Coalesce       bool
+	Retries        *RetryQueue // Optional
+	registry       *Registry
+	open           map[string]*openAlert // Event ID => unacknowledged failure
+	queues         []*deliveryQueue      // One per notifier while running
+	workers        sync.WaitGroup
+}
+
+type openAlert struct {
+	notice    *datatypes.Notification
+	opened    time.Time
+	lastSent  time.Time
+	escalated bool
+}
+
+func NewDispatcher(dampenFlapping bool, notifiers ...*Managed) *Dispatcher {
+	return &Dispatcher{
+		Notifiers:      notifiers,
+		DampenFlapping: dampenFlapping,
+		registry:       DefaultRegistry,
+		open:           make(map[string]*openAlert, 5),
+	}
+}
+
+// Should we alert anyone about this notification?
+func (d *Dispatcher) ShouldAlert(notice *datatypes.Notification) bool {
+	if notice.Suppressed || !d.inRegions(notice) || !d.inClusters(notice) ||
+		!d.owned(notice) || !d.matches(notice) {
+		return false
+	}
+
+	switch notice.Type {
+	case datatypes.FLAPPING_NOTICE, datatypes.STABILIZED_NOTICE,
+		datatypes.CLUSTER_SILENT_NOTICE, datatypes.CLUSTER_RESUMED_NOTICE,
+		datatypes.ANOMALY_NOTICE, datatypes.CLOCK_SKEW_NOTICE:
+		return true
+	case datatypes.HEARTBEAT_NOTICE:
+		return d.Heartbeats
+	case datatypes.DEPLOY_NOTICE:
+		return d.Deploys
+	case datatypes.INCIDENT_NOTICE:
+		return d.Incidents
+	case datatypes.SERVICE_EVENT_NOTICE:
+		if notice.Draining || notice.Event.PreviousStatus == notice.Event.Service.Status {
+			return false
+		}
+		return !(d.DampenFlapping && notice.Flapping)
+	}
+
+	return false
+}
+
+func (d *Dispatcher) inRegions(notice *datatypes.Notification) bool {
+	if len(d.Regions) == 0 {
+		return true
+	}
+
+	for _, region := range d.Regions {
+		if notice.Region == region {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Dispatcher) inClusters(notice *datatypes.Notification) bool {
+	if len(d.Clusters) == 0 {
+		return true
+	}
+
+	for _, clusterName := range d.Clusters {
+		if notice.ClusterName == clusterName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Dispatcher) matches(notice *datatypes.Notification) bool {
+	if d.Match == nil {
+		return true
+	}
+
+	matched, err := d.Match.Matches(notice)
+	if err != nil {
+		log.Warnf("Unable to evaluate match '%s': %s", d.Match.Source, err.Error())
+		return false
+	}
+
+	return matched
+}
+
+func instanceKey(notice *datatypes.Notification) string {
+	svc := notice.Event.Service
+	return notice.ClusterName + "/" + svc.Hostname + "/" + svc.ID
+}
+
+// Keep track of the failures that are still waiting on someone. Acks and
+// any other status for the same instance close them out.
+func (d *Dispatcher) trackOpenAlerts(notice *datatypes.Notification, now time.Time) {
+	if notice.Event == nil {
+		return
+	}
+
+	switch notice.Type {
+	case datatypes.ACK_NOTICE:
+		delete(d.open, notice.ID)
+	case datatypes.SERVICE_EVENT_NOTICE:
+		if notice.Event.Service.Status == service.UNHEALTHY {
+			if (d.RepeatInterval > 0 || d.EscalateAfter > 0) && d.ShouldAlert(notice) {
+				d.open[notice.ID] = &openAlert{notice: notice, opened: now, lastSent: now}
+			}
+			return
+		}
+
+		// Critical notifications jump the queue, so a failure can get here
+		// before the recovery that came ahead of it. That one doesn't count.
+		key := instanceKey(notice)
+		for id, alert := range d.open {
+			if instanceKey(alert.notice) == key && !alert.notice.Event.Time.After(notice.Event.Time) {
+				delete(d.open, id)
+			}
+		}
+	}
+}
+
+// Return the open alerts that are due to be sent again
+func (d *Dispatcher) dueForRepeat(now time.Time) []*datatypes.Notification {
+	if d.RepeatInterval == 0 {
+		return nil
+	}
+
+	var due []*datatypes.Notification
+	for _, alert := range d.open {
+		if now.Sub(alert.lastSent) >= d.RepeatInterval {
+			alert.lastSent = now
+			due = append(due, alert.notice)
+		}
+	}
+
+	return due
+}
+
+// Return the open alerts that have been open long enough to escalate.
+// Each one is only escalated once.
+func (d *Dispatcher) dueForEscalation(now time.Time) []*datatypes.Notification {
+	if d.EscalateAfter == 0 {
+		return nil
+	}
+
+	var due []*datatypes.Notification
+	for _, alert := range d.open {
+		if !alert.escalated && now.Sub(alert.opened) >= d.EscalateAfter {
+			alert.escalated = true
+			due = append(due, alert.notice)
+		}
+	}
+
+	return due
+}
+
+func (d *Dispatcher) escalate(notice *datatypes.Notification) {
+	target := d.registry.Get(d.EscalateTo)
+	if target == nil {
+		log.Errorf("Can't escalate to unknown notifier '%s'", d.EscalateTo)
+		return
+	}
+
+	escalated := *notice
+	escalated.Escalated = true
+
+	err := target.Deliver(context.Background(), &escalated)
+	if err != nil {
+		log.Errorf("Unable to escalate via %s: %s", target.Name(), err.Error())
+		d.retryLater(target, &escalated, err)
+	}
+}
+
+// Hand a failed delivery to the retry queue, if we have one
+func (d *Dispatcher) retryLater(notifier *Managed, notice *datatypes.Notification, err error) {
+	if d.Retries != nil {
+		d.Retries.Add(notifier.Name(), notice, err, time.Now().UTC())
+	}
+}
+
+// Build a dispatcher for one [[notifier]] from the config, registering the
+// notifier with the registry. Besides the notifier's own settings, it takes
+// dampen_flapping, repeat_interval, regions, clusters, owners, unowned,
+// match, throttle, dedup, quiet_hours, quiet_timezone, quiet_hold,
+// escalate_after, escalate_to, heartbeats, deploys, incidents, coalesce,
+// break_after and break_cooldown.
+func NewDispatcherFromSettings(settings Settings, registry *Registry) (*Dispatcher, error) {
+	notifier, err := NewNotifier(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	repeatInterval, err := settings.Duration("repeat_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	cooldown, err := settings.Duration("break_cooldown")
+	if err != nil {
+		return nil, err
+	}
+
+	managed := registry.Register(notifier)
+	managed.BreakAfter = settings.Int("break_after", DEFAULT_BREAK_AFTER)
+	if cooldown > 0 {
+		managed.Cooldown = cooldown
+	}
+
+	dispatcher := NewDispatcher(settings.Bool("dampen_flapping", true), managed)
+	dispatcher.RepeatInterval = repeatInterval
+	dispatcher.Regions = settings.Strings("regions")
+	dispatcher.Clusters = settings.Strings("clusters")
+	dispatcher.Owners = settings.Strings("owners")
+	dispatcher.Unowned = settings.Bool("unowned", false)
+	dispatcher.Heartbeats = settings.Bool("heartbeats", false)
+	dispatcher.Deploys = settings.Bool("deploys", false)
+	dispatcher.Incidents = settings.Bool("incidents", false)
+	dispatcher.Coalesce = settings.Bool("coalesce", false)
+	dispatcher.registry = registry
+	registry.claimOwners(dispatcher.Owners...)
+
+	throttle, err := settings.Duration("throttle")
+	if err != nil {
+		return nil, err
+	}
+
+	if throttle > 0 || settings.Bool("dedup", false) {
+		dispatcher.Throttle = NewThrottle(throttle, settings.Bool("dedup", false))
+	}
+
+	if source := settings.String("match"); source != "" {
+		dispatcher.Match, err = match.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid match: %s", err.Error())
+		}
+	}
+
+	if spec := settings.String("quiet_hours"); spec != "" {
+		dispatcher.Quiet, err = ParseQuietHours(spec, settings.String("quiet_timezone"))
+		if err != nil {
+			return nil, err
+		}
+
+		if source := settings.String("quiet_hold"); source != "" {
+			dispatcher.Quiet.Hold, err = match.Compile(source)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid quiet_hold: %s", err.Error())
+			}
+		}
+	}
+
+	dispatcher.EscalateAfter, err = settings.Duration("escalate_after")
+	if err != nil {
+		return nil, err
+	}
+	dispatcher.EscalateTo = settings.String("escalate_to")
+	if dispatcher.EscalateAfter > 0 && dispatcher.EscalateTo == "" {
+		return nil, errors.New("escalate_after needs escalate_to")
+	}
+
+	return dispatcher, nil
+}
+
+// Queue the notification for each notifier, or deliver it right here if
+// we're not running
+func (d *Dispatcher) send(notice *datatypes.Notification) {
+	if d.queues == nil {
+		for _, notifier := range d.Notifiers {
+			d.deliver(notifier, notice)
+		}
+		return
+	}
+
+	for _, queue := range d.queues {
+		err := queue.add(notice)
+		if err != nil {
+			log.Errorf("Unable to queue notification for %s: %s", queue.notifier.Name(), err.Error())
+			d.retryLater(queue.notifier, notice, err)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(notifier *Managed, notice *datatypes.Notification) {
+	err := notifier.Deliver(context.Background(), notice)
+	if err == ErrCircuitOpen {
+		// Already logged when the breaker opened
+		log.Debugf("Skipped notification via %s: %s", notifier.Name(), err.Error())
+	} else if err != nil {
+		log.Errorf("Unable to send notification via %s: %s", notifier.Name(), err.Error())
+	}
+
+	if err != nil {
+		d.retryLater(notifier, notice, err)
+	} else if d.Retries != nil {
+		d.Retries.Supersede(notifier.Name(), notice)
+	}
+}
+
+// Start a delivery worker for each notifier
+func (d *Dispatcher) startWorkers() {
+	d.queues = make([]*deliveryQueue, 0, len(d.Notifiers))
+	for _, notifier := range d.Notifiers {
+		queue := newDeliveryQueue(notifier)
+		d.queues = append(d.queues, queue)
+
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+			queue.run(d.deliver)
+		}()
+	}
+}
+
+// Wait for the workers to deliver what's queued, then stop them
+func (d *Dispatcher) stopWorkers() {
+	for _, queue := range d.queues {
+		queue.close()
+	}
+	d.workers.Wait()
+	d.queues = nil
+}
+
+func (d *Dispatcher) handle(notice *datatypes.Notification) {
+	if notice.Type == datatypes.BURST_NOTICE {
+		d.handleBurst(notice)
+		return
+	}
+
+	now := time.Now().UTC()
+	d.trackOpenAlerts(notice, now)
+	if !d.ShouldAlert(notice) {
+		return
+	}
+
+	if notice.Type == datatypes.HEARTBEAT_NOTICE ||
+		(!d.Quiet.Defer(notice, now) && d.Throttle.Allow(notice, now)) {
+		d.send(notice)
+	}
+}
+
+// Send what we would have sent of each event in the burst, together
+func (d *Dispatcher) handleBurst(burst *datatypes.Notification) {
+	if !d.Coalesce {
+		for _, notice := range burst.Burst {
+			d.handle(notice)
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	var sending []*datatypes.Notification
+	for _, notice := range burst.Burst {
+		d.trackOpenAlerts(notice, now)
+		if d.ShouldAlert(notice) && !d.Quiet.Defer(notice, now) && d.Throttle.Allow(notice, now) {
+			sending = append(sending, notice)
+		}
+	}
+
+	switch len(sending) {
+	case 0:
+		return
+	case 1:
+		d.send(sending[0])
+		return
+	}
+
+	// Only as bad as what's left of it
+	narrowed := *burst
+	narrowed.Burst = sending
+	narrowed.Severity = ""
+	for _, notice := range sending {
+		narrowed.Severity = datatypes.WorseSeverity(narrowed.Severity, notice.Severity)
+	}
+	d.send(&narrowed)
+}
+
+// Loop over the notifications until the channel is closed
+func (d *Dispatcher) Run(notices chan *datatypes.Notification) {
+	d.RunLanes(nil, notices)
+}
+
+// Loop over the notifications until the routine lane is closed, always
+// sending whatever is waiting in the critical lane first. During an event
+// storm that keeps failures from queueing up behind a pile of recoveries.
+// Returns once what was queued has been delivered.
+func (d *Dispatcher) RunLanes(critical chan *datatypes.Notification, routine chan *datatypes.Notification) {
+	ticker := time.NewTicker(REPEAT_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	d.startWorkers()
+	defer d.stopWorkers()
+
+	for {
+		select {
+		case notice, ok := <-critical:
+			if !ok {
+				critical = nil
+				continue
+			}
+			d.handle(notice)
+			continue
+		default:
+		}
+
+		select {
+		case notice, ok := <-critical:
+			if !ok {
+				critical = nil
+				continue
+			}
+			d.handle(notice)
+
+		case notice, ok := <-routine:
+			if !ok {
+				return
+			}
+			d.handle(notice)
+
+		case <-ticker.C:
+			now := time.Now().UTC()
+
+			if digest := d.Quiet.Flush(now); digest != nil {
+				d.send(digest)
+			}
+
+			for _, notice := range d.dueForRepeat(now) {
+				d.send(notice)
+			}
+
+			for _, notice := range d.dueForEscalation(now) {
+				go d.escalate(notice) // Not on our time, it's another notifier
+			}
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ShouldAlert(t *testing.T) {
+	Convey("ShouldAlert()", t, func() {
+		dispatcher := NewDispatcher(true)
+
+		notice := &datatypes.Notification{
+			Type: datatypes.SERVICE_EVENT_NOTICE,
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "db", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Now().UTC(),
+			},
+			ClusterName: "france",
+		}
+
+		flap := &datatypes.FlapStatus{ClusterName: "france", Service: "db", Transitions: 6}
+
+		Convey("Alerts on status transitions", func() {
+			So(dispatcher.ShouldAlert(notice), ShouldBeTrue)
+		})
+
+		Convey("Doesn't alert when the status didn't change", func() {
+			notice.Event.PreviousStatus = service.UNHEALTHY
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+		})
+
+		Convey("Suppresses transitions for flapping services", func() {
+			notice.Flapping = true
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+		})
+
+		Convey("Doesn't alert on silenced notifications", func() {
+			notice.Suppressed = true
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+		})
+
+		Convey("Doesn't alert on hosts draining for maintenance", func() {
+			notice.Draining = true
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+		})
+
+		Convey("Passes transitions for flapping services when not dampening", func() {
+			dispatcher.DampenFlapping = false
+			notice.Flapping = true
+			So(dispatcher.ShouldAlert(notice), ShouldBeTrue)
+		})
+
+		Convey("Only alerts for the configured regions", func() {
+			dispatcher.Regions = []string{"western-front"}
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+
+			notice.Region = "western-front"
+			So(dispatcher.ShouldAlert(notice), ShouldBeTrue)
+		})
+
+		Convey("Only alerts for the configured clusters", func() {
+			dispatcher.Clusters = []string{"belgium", "italy"}
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+
+			notice.ClusterName = "belgium"
+			So(dispatcher.ShouldAlert(notice), ShouldBeTrue)
+		})
+
+		Convey("Only alerts for notifications the match expression accepts", func() {
+			dispatcher.Match, _ = match.Compile(`cluster == "belgium"`)
+			So(dispatcher.ShouldAlert(notice), ShouldBeFalse)
+
+			dispatcher.Match, _ = match.Compile(`cluster == "france" && status == UNHEALTHY`)
+			So(dispatcher.ShouldAlert(notice), ShouldBeTrue)
+		})
+
+		Convey("Alerts on flapping and stabilized summaries", func() {
+			So(dispatcher.ShouldAlert(&datatypes.Notification{
+				Type: datatypes.FLAPPING_NOTICE, Flap: flap,
+			}), ShouldBeTrue)
+
+			So(dispatcher.ShouldAlert(&datatypes.Notification{
+				Type: datatypes.STABILIZED_NOTICE, Flap: flap,
+			}), ShouldBeTrue)
+		})
+
+		Convey("Only sends heartbeats when asked to", func() {
+			heartbeat := &datatypes.Notification{
+				Type: datatypes.HEARTBEAT_NOTICE, Heartbeat: &datatypes.Heartbeat{Sequence: 1},
+			}
+
+			So(dispatcher.ShouldAlert(heartbeat), ShouldBeFalse)
+			dispatcher.Heartbeats = true
+			So(dispatcher.ShouldAlert(heartbeat), ShouldBeTrue)
+		})
+
+		Convey("Only sends deploys when asked to", func() {
+			deploy := &datatypes.Notification{
+				Type: datatypes.DEPLOY_NOTICE, Deploy: &datatypes.VersionChange{Service: "db"},
+			}
+
+			So(dispatcher.ShouldAlert(deploy), ShouldBeFalse)
+			dispatcher.Deploys = true
+			So(dispatcher.ShouldAlert(deploy), ShouldBeTrue)
+		})
+
+		Convey("Only sends incidents when asked to", func() {
+			incident := &datatypes.Notification{
+				Type:        datatypes.INCIDENT_NOTICE,
+				ClusterName: "france",
+				Severity:    datatypes.SEVERITY_CRITICAL,
+				Incident: &datatypes.Incident{
+					ID: "albert", Status: datatypes.INCIDENT_OPEN, Severity: datatypes.SEVERITY_CRITICAL,
+					Services: []string{"db", "web"},
+				},
+			}
+
+			So(dispatcher.ShouldAlert(incident), ShouldBeFalse)
+			dispatcher.Incidents = true
+			So(dispatcher.ShouldAlert(incident), ShouldBeTrue)
+			So(MessageFor(incident), ShouldEqual, "[france] incident opened: db, web unhealthy (critical)")
+			So(SeverityOf(incident), ShouldEqual, SEVERITY_CRITICAL)
+
+			incident.Incident.Status = datatypes.INCIDENT_RESOLVED
+			incident.Severity = datatypes.SEVERITY_INFO
+			So(MessageFor(incident), ShouldEqual, "[france] incident resolved, db, web recovered")
+			So(SeverityOf(incident), ShouldEqual, SEVERITY_OK)
+		})
+
+		Convey("Alerts on hosts with bad clocks", func() {
+			skewed := &datatypes.Notification{
+				Type:        datatypes.CLOCK_SKEW_NOTICE,
+				ClusterName: "france",
+				Severity:    datatypes.SEVERITY_WARNING,
+				SkewedHost:  &datatypes.SkewedHost{ClusterName: "france", Hostname: "meuse", Skew: -2 * time.Hour},
+			}
+
+			So(dispatcher.ShouldAlert(skewed), ShouldBeTrue)
+			So(MessageFor(skewed), ShouldEqual,
+				"[france] clock on meuse is off by -2h0m0s, using receipt times for its events")
+			So(SeverityOf(skewed), ShouldEqual, SEVERITY_WARNING)
+		})
+
+		Convey("Alerts on anomalies", func() {
+			anomaly := &datatypes.Notification{
+				Type:        datatypes.ANOMALY_NOTICE,
+				ClusterName: "france",
+				Severity:    datatypes.SEVERITY_WARNING,
+				Anomaly: &datatypes.Anomaly{
+					ClusterName: "france", Transitions: 120, Baseline: 15, Factor: 5, Window: time.Minute,
+					Statuses: map[string]int{"Tombstone": 100, "Alive": 20},
+				},
+			}
+
+			So(dispatcher.ShouldAlert(anomaly), ShouldBeTrue)
+			So(MessageFor(anomaly), ShouldEqual,
+				"[france] unusual rate of change: 120 transitions in 1m0s, usually 15.0 (mostly Tombstone)")
+			So(SeverityOf(anomaly), ShouldEqual, SEVERITY_WARNING)
+		})
+
+		Convey("Alerts on silent and resumed clusters", func() {
+			stale := &datatypes.StaleCluster{ClusterName: "france", Timeout: 15 * time.Minute}
+
+			So(dispatcher.ShouldAlert(&datatypes.Notification{
+				Type: datatypes.CLUSTER_SILENT_NOTICE, ClusterName: "france", Stale: stale,
+			}), ShouldBeTrue)
+
+			So(dispatcher.ShouldAlert(&datatypes.Notification{
+				Type: datatypes.CLUSTER_RESUMED_NOTICE, ClusterName: "france", Stale: stale,
+			}), ShouldBeTrue)
+		})
+	})
+}
+
+func Test_MessageFor(t *testing.T) {
+	Convey("MessageFor() summarizes flapping services", t, func() {
+		notice := &datatypes.Notification{
+			Type:        datatypes.FLAPPING_NOTICE,
+			ClusterName: "france",
+			Flap: &datatypes.FlapStatus{
+				Service: "db", Transitions: 6, Window: 10 * time.Minute,
+			},
+		}
+
+		So(MessageFor(notice), ShouldEqual, "[france] service db is flapping (6 transitions in 10m0s)")
+	})
+
+	Convey("MessageFor() summarizes silent clusters", t, func() {
+		notice := &datatypes.Notification{
+			Type:        datatypes.CLUSTER_SILENT_NOTICE,
+			ClusterName: "france",
+			Stale: &datatypes.StaleCluster{
+				ClusterName: "france",
+				LastSeen:    time.Date(1916, 2, 21, 7, 15, 0, 0, time.UTC),
+				Timeout:     15 * time.Minute,
+			},
+		}
+
+		So(MessageFor(notice), ShouldEqual,
+			"[france] cluster has been silent since 1916-02-21T07:15:00Z (more than 15m0s)")
+	})
+}
+
+func Test_SeverityOf(t *testing.T) {
+	Convey("SeverityOf()", t, func() {
+		change := func(status int, severity string) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:     datatypes.SERVICE_EVENT_NOTICE,
+				Severity: severity,
+				Event:    &catalog.ChangeEvent{Service: service.Service{Status: status}},
+			}
+		}
+
+		Convey("Goes by the classified severity", func() {
+			So(SeverityOf(change(service.UNHEALTHY, SEVERITY_CRITICAL)), ShouldEqual, SEVERITY_CRITICAL)
+			So(SeverityOf(change(service.UNHEALTHY, SEVERITY_WARNING)), ShouldEqual, SEVERITY_WARNING)
+			So(SeverityOf(change(service.TOMBSTONE, SEVERITY_CRITICAL)), ShouldEqual, SEVERITY_CRITICAL)
+			So(SeverityOf(change(service.TOMBSTONE, SEVERITY_INFO)), ShouldEqual, SEVERITY_INFO)
+			So(SeverityOf(&datatypes.Notification{Type: datatypes.REPORT_NOTICE}), ShouldEqual, SEVERITY_INFO)
+		})
+
+		Convey("Calls info about recoveries ok", func() {
+			So(SeverityOf(change(service.ALIVE, SEVERITY_INFO)), ShouldEqual, SEVERITY_OK)
+			So(SeverityOf(&datatypes.Notification{Type: datatypes.CLUSTER_RESUMED_NOTICE}), ShouldEqual, SEVERITY_OK)
+			So(SeverityOf(&datatypes.Notification{
+				Type:  datatypes.BURST_NOTICE,
+				Burst: []*datatypes.Notification{change(service.ALIVE, SEVERITY_INFO), change(service.TOMBSTONE, SEVERITY_INFO)},
+			}), ShouldEqual, SEVERITY_INFO)
+		})
+	})
+}
+
+func Test_RepeatingAlerts(t *testing.T) {
+	Convey("Repeating unacknowledged failures", t, func() {
+		dispatcher := NewDispatcher(true)
+		dispatcher.RepeatInterval = 10 * time.Minute
+		baseTime := time.Now().UTC()
+
+		failure := &datatypes.Notification{
+			ID:   "joffre",
+			Type: datatypes.SERVICE_EVENT_NOTICE,
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{
+					ID: "deadbeef0123", Name: "db", Hostname: "verdun", Status: service.UNHEALTHY,
+				},
+				PreviousStatus: service.ALIVE,
+			},
+			ClusterName: "france",
+		}
+
+		dispatcher.trackOpenAlerts(failure, baseTime)
+
+		Convey("Repeats failures once the interval has passed", func() {
+			So(dispatcher.dueForRepeat(baseTime.Add(time.Minute)), ShouldBeEmpty)
+			So(dispatcher.dueForRepeat(baseTime.Add(11*time.Minute)), ShouldResemble,
+				[]*datatypes.Notification{failure})
+		})
+
+		Convey("Stops repeating once acknowledged", func() {
+			ack := *failure
+			ack.Type = datatypes.ACK_NOTICE
+			dispatcher.trackOpenAlerts(&ack, baseTime)
+
+			So(dispatcher.dueForRepeat(baseTime.Add(11*time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("Stops repeating once the instance recovers", func() {
+			recovered := *failure
+			recovered.ID = "foch"
+			recovered.Event = &catalog.ChangeEvent{
+				Service:        failure.Event.Service,
+				PreviousStatus: service.UNHEALTHY,
+			}
+			recovered.Event.Service.Status = service.ALIVE
+			dispatcher.trackOpenAlerts(&recovered, baseTime)
+
+			So(dispatcher.dueForRepeat(baseTime.Add(11*time.Minute)), ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_Escalation(t *testing.T) {
+	Convey("Escalating sustained failures", t, func() {
+		registry := &Registry{}
+		pigeon := &flakyNotifier{}
+		registry.Register(pigeon)
+
+		dispatcher := NewDispatcher(true)
+		dispatcher.registry = registry
+		dispatcher.EscalateAfter = 15 * time.Minute
+		dispatcher.EscalateTo = "pigeon"
+		baseTime := time.Now().UTC()
+
+		failure := &datatypes.Notification{
+			ID:   "joffre",
+			Type: datatypes.SERVICE_EVENT_NOTICE,
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{ID: "deadbeef0123", Name: "db", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+			},
+			ClusterName: "france",
+		}
+
+		dispatcher.trackOpenAlerts(failure, baseTime)
+
+		Convey("Escalates once the delay has passed, only once", func() {
+			So(dispatcher.dueForEscalation(baseTime.Add(time.Minute)), ShouldBeEmpty)
+			So(dispatcher.dueForEscalation(baseTime.Add(16*time.Minute)), ShouldResemble,
+				[]*datatypes.Notification{failure})
+			So(dispatcher.dueForEscalation(baseTime.Add(30*time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("Doesn't repeat just because it escalates", func() {
+			So(dispatcher.dueForRepeat(baseTime.Add(time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("Sends a marked copy to the escalation notifier", func() {
+			dispatcher.escalate(failure)
+			So(pigeon.calls, ShouldEqual, 1)
+			So(failure.Escalated, ShouldBeFalse)
+		})
+	})
+}
+
+// Remembers what it was asked to send, in order
+type recordingNotifier struct {
+	sent []*datatypes.Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	r.sent = append(r.sent, notice)
+	return nil
+}
+
+func (r *recordingNotifier) Name() string  { return "gramophone" }
+func (r *recordingNotifier) Healthy() bool { return true }
+
+// Records like a recordingNotifier, but only once it's released
+type stalledNotifier struct {
+	recordingNotifier
+	release chan struct{}
+}
+
+func (s *stalledNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	<-s.release
+	return s.recordingNotifier.Notify(ctx, notice)
+}
+
+func Test_PriorityLanes(t *testing.T) {
+	Convey("Running with priority lanes", t, func() {
+		recorder := &recordingNotifier{}
+		dispatcher := NewDispatcher(true, NewManaged(recorder))
+		baseTime := time.Now().UTC()
+
+		change := func(id string, previous int, status int, at time.Time) *datatypes.Notification {
+			return &datatypes.Notification{
+				ID:   id,
+				Type: datatypes.SERVICE_EVENT_NOTICE,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{ID: "deadbeef0123", Name: "db", Hostname: "verdun", Status: status},
+					PreviousStatus: previous,
+					Time:           at,
+				},
+				ClusterName: "france",
+			}
+		}
+
+		Convey("Sends what's in the critical lane first", func() {
+			critical := make(chan *datatypes.Notification, 5)
+			routine := make(chan *datatypes.Notification, 5)
+
+			for i := 0; i < 3; i++ {
+				routine <- change("recovery", service.UNHEALTHY, service.ALIVE, baseTime)
+			}
+			failure := change("failure", service.ALIVE, service.UNHEALTHY, baseTime)
+			critical <- failure
+			close(routine)
+
+			dispatcher.RunLanes(critical, routine)
+
+			So(len(recorder.sent), ShouldEqual, 4)
+			So(recorder.sent[0], ShouldEqual, failure)
+		})
+
+		Convey("Drops a recovery older than a failure that jumped ahead of it", func() {
+			critical := make(chan *datatypes.Notification, 5)
+			routine := make(chan *datatypes.Notification, 5)
+
+			routine <- change("stale", service.UNHEALTHY, service.ALIVE, baseTime.Add(-time.Second))
+			failure := change("failure", service.ALIVE, service.UNHEALTHY, baseTime)
+			failure.Severity = datatypes.SEVERITY_CRITICAL
+			critical <- failure
+			recovery := change("recovery", service.UNHEALTHY, service.ALIVE, baseTime.Add(time.Second))
+			routine <- recovery
+			close(routine)
+
+			dispatcher.RunLanes(critical, routine)
+
+			So(recorder.sent, ShouldResemble, []*datatypes.Notification{failure, recovery})
+		})
+
+		Convey("Keeps reading while a notifier is slow", func() {
+			stalled := &stalledNotifier{release: make(chan struct{})}
+			dispatcher.Notifiers = append(dispatcher.Notifiers, NewManaged(stalled))
+
+			routine := make(chan *datatypes.Notification)
+			done := make(chan struct{})
+			go func() {
+				dispatcher.RunLanes(nil, routine)
+				close(done)
+			}()
+
+			// Unbuffered, so each of these waits for the dispatcher to read it
+			for i := 0; i < 5; i++ {
+				routine <- change("recovery", service.UNHEALTHY, service.ALIVE, baseTime)
+			}
+			close(routine)
+
+			close(stalled.release)
+			<-done
+			So(len(recorder.sent), ShouldEqual, 5)
+			So(len(stalled.sent), ShouldEqual, 5)
+		})
+
+		Convey("Doesn't let an older recovery close a failure that jumped ahead of it", func() {
+			dispatcher.RepeatInterval = 10 * time.Minute
+			failure := change("failure", service.ALIVE, service.UNHEALTHY, baseTime)
+			dispatcher.trackOpenAlerts(failure, baseTime)
+
+			dispatcher.trackOpenAlerts(change("stale", service.UNHEALTHY, service.ALIVE, baseTime.Add(-time.Second)), baseTime)
+			So(dispatcher.open, ShouldContainKey, "failure")
+
+			dispatcher.trackOpenAlerts(change("recovery", service.UNHEALTHY, service.ALIVE, baseTime.Add(time.Second)), baseTime)
+			So(dispatcher.open, ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_Bursts(t *testing.T) {
+	Convey("Handling a burst", t, func() {
+		recorder := &recordingNotifier{}
+		dispatcher := NewDispatcher(true, NewManaged(recorder))
+
+		change := func(name string, previous int, status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Severity:    datatypes.SEVERITY_INFO,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: name, Status: status},
+					PreviousStatus: previous,
+				},
+			}
+		}
+		failed := change("somme", service.ALIVE, service.UNHEALTHY)
+		failed.Severity = datatypes.SEVERITY_CRITICAL
+		recovered := change("verdun", service.UNHEALTHY, service.ALIVE)
+		unchanged := change("marne", service.ALIVE, service.ALIVE)
+		burst := &datatypes.Notification{
+			Type:        datatypes.BURST_NOTICE,
+			ClusterName: "france",
+			Burst:       []*datatypes.Notification{failed, unchanged, recovered},
+		}
+
+		Convey("Sends the events one at a time by default", func() {
+			dispatcher.handle(burst)
+			So(recorder.sent, ShouldResemble, []*datatypes.Notification{failed, recovered})
+		})
+
+		Convey("Sends what it would have sent of it together when coalescing", func() {
+			dispatcher.Coalesce = true
+			dispatcher.handle(burst)
+
+			So(len(recorder.sent), ShouldEqual, 1)
+			So(recorder.sent[0].Burst, ShouldResemble, []*datatypes.Notification{failed, recovered})
+			So(SeverityOf(recorder.sent[0]), ShouldEqual, SEVERITY_CRITICAL)
+			So(MessageFor(recorder.sent[0]), ShouldStartWith, "[france] 2 events in a burst\n[france] somme")
+			So(len(burst.Burst), ShouldEqual, 3)
+		})
+
+		Convey("Sends a lone event on its own", func() {
+			dispatcher.Coalesce = true
+			burst.Burst = []*datatypes.Notification{unchanged, failed}
+			dispatcher.handle(burst)
+
+			So(recorder.sent, ShouldResemble, []*datatypes.Notification{failed})
+		})
+	})
+}
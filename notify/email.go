@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Sends notifications as plain text email through an SMTP server. The body
+// comes from MessageFor() unless there's a Template.
+type EmailNotifier struct {
+	alwaysHealthy
+	Server   string // host:port
+	From     string
+	To       []string
+	Username string // Optional, for PLAIN auth
+	Password string
+	Template *Template // Optional
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func init() {
+	RegisterFactory("email", func(settings Settings) (Notifier, error) {
+		if settings.String("smtp_server") == "" || settings.String("from") == "" ||
+			len(settings.Strings("to")) == 0 {
+			return nil, errors.New("smtp_server, from and to are required")
+		}
+
+		email := NewEmailNotifier(
+			settings.String("smtp_server"), settings.String("from"), settings.Strings("to")...,
+		)
+		email.Username = settings.String("username")
+		email.Password = settings.String("password")
+
+		var err error
+		email.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return email, nil
+	})
+}
+
+func NewEmailNotifier(server string, from string, to ...string) *EmailNotifier {
+	return &EmailNotifier{Server: server, From: from, To: to, sendMail: smtp.SendMail}
+}
+
+func (e *EmailNotifier) Name() string {
+	return "email"
+}
+
+func subjectFor(notice *datatypes.Notification) string {
+	switch notice.Type {
+	case datatypes.REPORT_NOTICE:
+		return "[superside] " + notice.Report.Name
+	case datatypes.DIGEST_NOTICE:
+		return "[superside] Quiet hours digest"
+	}
+
+	// The first line of the message is a summary already
+	return "[superside] " + strings.SplitN(MessageFor(notice), "\n", 2)[0]
+}
+
+func (e *EmailNotifier) message(notice *datatypes.Notification) ([]byte, error) {
+	body, err := renderText(notice, e.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subjectFor(notice))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+
+	return msg.Bytes(), nil
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	msg, err := e.message(notice)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, _ := net.SplitHostPort(e.Server)
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	// net/smtp doesn't take a context, so just don't start if we're too late
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return e.sendMail(e.Server, auth, e.From, e.To, msg)
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"net/smtp"
+	"testing"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_EmailNotifier(t *testing.T) {
+	Convey("Email notifier", t, func() {
+		var sentTo []string
+		var sent string
+
+		email := NewEmailNotifier("smtp.example.com:25", "superside@example.com", "ops@example.com")
+		email.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+			sentTo = to
+			sent = string(msg)
+			return nil
+		}
+
+		notice := &datatypes.Notification{
+			Type:        datatypes.FLAPPING_NOTICE,
+			ClusterName: "france",
+			Flap:        &datatypes.FlapStatus{Service: "db", Transitions: 6},
+		}
+
+		Convey("Sends the message with a summary subject", func() {
+			So(email.Notify(context.Background(), notice), ShouldBeNil)
+			So(sentTo, ShouldResemble, []string{"ops@example.com"})
+			So(sent, ShouldContainSubstring, "Subject: [superside] [france] service db is flapping")
+			So(sent, ShouldContainSubstring, "\r\n\r\n[france] service db is flapping")
+		})
+
+		Convey("Uses the template for the body", func() {
+			email.Template, _ = ParseTemplate("email", "Look at {{ .Flap.Service }}\nin {{ .ClusterName }}")
+
+			So(email.Notify(context.Background(), notice), ShouldBeNil)
+			So(sent, ShouldEndWith, "\r\n\r\nLook at db\r\nin france")
+		})
+
+		Convey("Needs somewhere to send it", func() {
+			_, err := NewNotifier(Settings{"type": "email", "smtp_server": "smtp.example.com:25"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Stands in for a chat webhook or API in the notifier tests. Each JSON body
+// is decoded into received, when that isn't nil, and the answer is Status.
+type fakeEndpoint struct {
+	*httptest.Server
+	Status   int
+	Requests []*http.Request // Forms already parsed
+	Bodies   [][]byte
+	received interface{}
+}
+
+func newFakeEndpoint(received interface{}, status int) *fakeEndpoint {
+	endpoint := &fakeEndpoint{Status: status, received: received}
+	endpoint.Server = httptest.NewServer(http.HandlerFunc(endpoint.handle))
+	return endpoint
+}
+
+func (e *fakeEndpoint) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		r.PostForm, _ = url.ParseQuery(string(body))
+	}
+
+	e.Requests = append(e.Requests, r)
+	e.Bodies = append(e.Bodies, body)
+	if e.received != nil {
+		json.Unmarshal(body, e.received)
+	}
+
+	w.WriteHeader(e.Status)
+}
+
+// The paths requested, in order
+func (e *fakeEndpoint) Paths() []string {
+	paths := make([]string, 0, len(e.Requests))
+	for _, req := range e.Requests {
+		paths = append(paths, req.URL.Path)
+	}
+	return paths
+}
+
+// verdun on meuse, in france, going down
+func failureNotice() *datatypes.Notification {
+	return &datatypes.Notification{
+		Type:        datatypes.SERVICE_EVENT_NOTICE,
+		ClusterName: "france",
+		Severity:    datatypes.SEVERITY_CRITICAL,
+		Event: &catalog.ChangeEvent{
+			Service: service.Service{
+				ID: "1", Name: "verdun", Hostname: "meuse", Image: "verdun:1916", Status: service.UNHEALTHY,
+			},
+			PreviousStatus: service.ALIVE,
+		},
+	}
+}
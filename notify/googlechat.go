@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Posts alerts to a Google Chat space's incoming webhook. The text comes
+// from MessageFor() unless there's a Template. With Threaded set, the
+// notifications for each service, or for each cluster if they aren't about a
+// service, go into a thread of their own.
+type GoogleChatNotifier struct {
+	alwaysHealthy
+	WebhookUrl string
+	Threaded   bool
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+type googleChatMessage struct {
+	Text   string            `json:"text"`
+	Thread *googleChatThread `json:"thread,omitempty"`
+}
+
+type googleChatThread struct {
+	ThreadKey string `json:"threadKey"`
+}
+
+func init() {
+	RegisterFactory("googlechat", func(settings Settings) (Notifier, error) {
+		if settings.String("webhook_url") == "" {
+			return nil, errors.New("webhook_url is required")
+		}
+
+		chat := NewGoogleChatNotifier(settings.String("webhook_url"))
+		chat.Threaded = settings.Bool("threaded", false)
+
+		var err error
+		chat.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return chat, nil
+	})
+}
+
+func NewGoogleChatNotifier(webhookUrl string) *GoogleChatNotifier {
+	return &GoogleChatNotifier{
+		WebhookUrl: webhookUrl,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (g *GoogleChatNotifier) Name() string {
+	return "googlechat"
+}
+
+// Where to post. Threads need asking for, or Google Chat ignores the key.
+func (g *GoogleChatNotifier) postUrl(notice *datatypes.Notification) (string, *googleChatThread, error) {
+	key := serviceKey(notice)
+	if !g.Threaded || key == "" {
+		return g.WebhookUrl, nil, nil
+	}
+
+	parsed, err := url.Parse(g.WebhookUrl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := parsed.Query()
+	query.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String(), &googleChatThread{ThreadKey: key}, nil
+}
+
+func (g *GoogleChatNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	text, err := renderText(notice, g.Template)
+	if err != nil {
+		return err
+	}
+
+	postUrl, thread, err := g.postUrl(notice)
+	if err != nil {
+		return err
+	}
+
+	message := googleChatMessage{Text: text, Thread: thread}
+	return postJSON(ctx, g.client, postUrl, message, "Google Chat webhook", http.StatusOK)
+}
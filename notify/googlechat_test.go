@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GoogleChatNotifier(t *testing.T) {
+	Convey("Google Chat notifier", t, func() {
+		var received googleChatMessage
+		endpoint := newFakeEndpoint(&received, http.StatusOK)
+		defer endpoint.Close()
+
+		chat := NewGoogleChatNotifier(endpoint.URL + "/v1/spaces/WAR/messages?key=k&token=t")
+		notice := failureNotice()
+
+		query := func() string {
+			return endpoint.Requests[len(endpoint.Requests)-1].URL.RawQuery
+		}
+
+		Convey("Posts the message", func() {
+			So(chat.Notify(context.Background(), notice), ShouldBeNil)
+
+			So(received.Text, ShouldEqual, MessageFor(notice))
+			So(received.Thread, ShouldBeNil)
+			So(query(), ShouldEqual, "key=k&token=t")
+		})
+
+		Convey("Threads the notifications for each service", func() {
+			chat.Threaded = true
+			So(chat.Notify(context.Background(), notice), ShouldBeNil)
+
+			So(received.Thread.ThreadKey, ShouldEqual, "france/verdun")
+			So(query(), ShouldContainSubstring, "messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+			So(query(), ShouldContainSubstring, "token=t")
+		})
+
+		Convey("Reports errors from Google Chat", func() {
+			endpoint.Status = http.StatusBadRequest
+			So(chat.Notify(context.Background(), notice), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Embedded by the notifiers with nothing of their own to go wrong between
+// deliveries. Managed keeps track of how the deliveries themselves go.
+type alwaysHealthy struct{}
+
+func (alwaysHealthy) Healthy() bool {
+	return true
+}
+
+// The notification as text, from the template when there is one, otherwise
+// from MessageFor()
+func renderText(notice *datatypes.Notification, tmpl *Template) (string, error) {
+	if tmpl == nil {
+		return MessageFor(notice), nil
+	}
+
+	return tmpl.Render(notice)
+}
+
+// POST the body as JSON. Fails unless the answer has one of the okStatus
+// codes, naming what we were talking to, e.g. "Slack webhook returned
+// 404 Not Found".
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, what string, okStatus ...int) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return doRequest(ctx, client, req, what, okStatus...)
+}
+
+// Make the request, failing unless the answer has one of the okStatus
+// codes, or any 2xx code when there aren't any
+func doRequest(ctx context.Context, client *http.Client, req *http.Request, what string, okStatus ...int) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !statusOK(resp.StatusCode, okStatus) {
+		return fmt.Errorf("%s returned %s", what, resp.Status)
+	}
+
+	return nil
+}
+
+func statusOK(status int, okStatus []int) bool {
+	if len(okStatus) == 0 {
+		return status >= 200 && status <= 299
+	}
+
+	for _, ok := range okStatus {
+		if status == ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,316 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_JIRA_THRESHOLD        = 10 * time.Minute
+	DEFAULT_JIRA_ISSUE_TYPE       = "Bug"
+	DEFAULT_JIRA_CLOSE_TRANSITION = "Done"
+	DEFAULT_JIRA_RETRY_INTERVAL   = time.Minute // Between tries at opening an issue
+)
+
+// Where a cluster's issues are filed
+type JiraProject struct {
+	Project   string
+	IssueType string
+}
+
+// Opens a Jira issue when a service has had unhealthy instances for longer
+// than Threshold, then comments on it and closes it when they've all
+// recovered. Issues go to the project in Projects for the cluster, or to
+// Default. Recoveries that the dispatcher doesn't pass on, e.g. because of
+// flap dampening, leave the issue open, so routes to Jira usually set
+// dampen_flapping = false.
+//
+// If the issue can't be opened we try again every RetryInterval for as long
+// as the outage goes on. If it can't be closed, the recovery fails like any
+// other delivery and closing it is tried again when it's retried.
+type JiraNotifier struct {
+	alwaysHealthy
+	Url             string
+	Username        string
+	Token           string
+	Threshold       time.Duration
+	Default         JiraProject
+	Projects        map[string]JiraProject // Cluster => project
+	CloseTransition string                 // The workflow transition that closes an issue
+	RetryInterval   time.Duration
+	outages         map[string]*jiraOutage // "cluster/service" => outage
+	lock            sync.Mutex
+	client          *http.Client
+}
+
+// A service with unhealthy instances, and its issue once we've opened one
+type jiraOutage struct {
+	first     *datatypes.Notification
+	unhealthy map[string]bool // Instance keys
+	timer     *time.Timer
+	issue     string
+}
+
+func init() {
+	RegisterFactory("jira", func(settings Settings) (Notifier, error) {
+		if settings.String("url") == "" || settings.String("project") == "" {
+			return nil, errors.New("url and project are required")
+		}
+
+		jira := NewJiraNotifier(settings.String("url"), settings.String("project"))
+		jira.Username = settings.String("username")
+		jira.Token = settings.String("token")
+		if issueType := settings.String("issue_type"); issueType != "" {
+			jira.Default.IssueType = issueType
+		}
+		if transition := settings.String("close_transition"); transition != "" {
+			jira.CloseTransition = transition
+		}
+
+		threshold, err := settings.Duration("threshold")
+		if err != nil {
+			return nil, err
+		}
+		if threshold > 0 {
+			jira.Threshold = threshold
+		}
+
+		clusters := settings.Section("clusters")
+		for clusterName := range clusters {
+			project := jira.Default
+			if name := clusters.Section(clusterName).String("project"); name != "" {
+				project.Project = name
+			}
+			if issueType := clusters.Section(clusterName).String("issue_type"); issueType != "" {
+				project.IssueType = issueType
+			}
+			jira.Projects[clusterName] = project
+		}
+
+		return jira, nil
+	})
+}
+
+func NewJiraNotifier(jiraUrl string, project string) *JiraNotifier {
+	return &JiraNotifier{
+		Url:             strings.TrimRight(jiraUrl, "/"),
+		Threshold:       DEFAULT_JIRA_THRESHOLD,
+		Default:         JiraProject{Project: project, IssueType: DEFAULT_JIRA_ISSUE_TYPE},
+		Projects:        make(map[string]JiraProject, 5),
+		CloseTransition: DEFAULT_JIRA_CLOSE_TRANSITION,
+		RetryInterval:   DEFAULT_JIRA_RETRY_INTERVAL,
+		outages:         make(map[string]*jiraOutage, 10),
+		client:          &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (j *JiraNotifier) Name() string {
+	return "jira"
+}
+
+func (j *JiraNotifier) projectFor(clusterName string) JiraProject {
+	if project, ok := j.Projects[clusterName]; ok {
+		return project
+	}
+	return j.Default
+}
+
+// Keep track of which instances of each service are unhealthy. The timer
+// opens the issue if the outage is still going when it fires.
+func (j *JiraNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return nil
+	}
+
+	key := serviceKey(notice)
+	instance := instanceKey(notice)
+
+	j.lock.Lock()
+	outage := j.outages[key]
+
+	if notice.Event.Service.Status == service.UNHEALTHY {
+		if outage == nil {
+			outage = &jiraOutage{first: notice, unhealthy: make(map[string]bool, 5)}
+			outage.timer = time.AfterFunc(j.Threshold, func() { j.open(key, outage) })
+			j.outages[key] = outage
+		}
+		outage.unhealthy[instance] = true
+		j.lock.Unlock()
+		return nil
+	}
+
+	if outage == nil {
+		j.lock.Unlock()
+		return nil
+	}
+
+	delete(outage.unhealthy, instance)
+	if len(outage.unhealthy) > 0 {
+		j.lock.Unlock()
+		return nil
+	}
+
+	outage.timer.Stop()
+	issue := outage.issue
+	if issue == "" {
+		delete(j.outages, key)
+		j.lock.Unlock()
+		return nil
+	}
+	j.lock.Unlock()
+
+	// Keep the outage until the issue is closed, so a retry can close it
+	err := j.resolve(ctx, issue, notice)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	if j.outages[key] == outage && len(outage.unhealthy) == 0 {
+		delete(j.outages, key)
+	}
+	j.lock.Unlock()
+
+	return nil
+}
+
+// Open the issue for an outage that has gone on too long. If it ended while
+// we were at it, close the issue again straight away. If it can't be opened,
+// try again in a while.
+func (j *JiraNotifier) open(key string, outage *jiraOutage) {
+	j.lock.Lock()
+	ongoing := j.outages[key] == outage
+	j.lock.Unlock()
+
+	if !ongoing {
+		return
+	}
+
+	issue, err := j.createIssue(context.Background(), outage.first)
+	if err != nil {
+		log.Errorf("Unable to open Jira issue for %s, trying again in %s: %s",
+			key, j.RetryInterval, err.Error())
+
+		j.lock.Lock()
+		if j.outages[key] == outage {
+			outage.timer = time.AfterFunc(j.RetryInterval, func() { j.open(key, outage) })
+		}
+		j.lock.Unlock()
+		return
+	}
+
+	j.lock.Lock()
+	outage.issue = issue
+	ongoing = j.outages[key] == outage
+	j.lock.Unlock()
+
+	if !ongoing {
+		err = j.resolve(context.Background(), issue, outage.first)
+		if err != nil {
+			log.Errorf("Unable to close Jira issue %s: %s", issue, err.Error())
+		}
+	}
+}
+
+func (j *JiraNotifier) createIssue(ctx context.Context, notice *datatypes.Notification) (string, error) {
+	project := j.projectFor(notice.ClusterName)
+
+	request := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":   map[string]string{"key": project.Project},
+			"issuetype": map[string]string{"name": project.IssueType},
+			"summary": fmt.Sprintf("[%s] %s has been unhealthy for more than %s",
+				notice.ClusterName, notice.Event.Service.Name, j.Threshold),
+			"description": MessageFor(notice),
+			"labels":      []string{"superside"},
+		},
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	err := j.call(ctx, "POST", "/rest/api/2/issue", request, &created)
+	return created.Key, err
+}
+
+// Comment on the issue and move it through the closing transition
+func (j *JiraNotifier) resolve(ctx context.Context, issue string, notice *datatypes.Notification) error {
+	comment := map[string]string{"body": "Recovered: " + MessageFor(notice)}
+	err := j.call(ctx, "POST", "/rest/api/2/issue/"+issue+"/comment", comment, nil)
+	if err != nil {
+		return err
+	}
+
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	err = j.call(ctx, "GET", "/rest/api/2/issue/"+issue+"/transitions", nil, &available)
+	if err != nil {
+		return err
+	}
+
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, j.CloseTransition) {
+			request := map[string]interface{}{"transition": map[string]string{"id": transition.ID}}
+			return j.call(ctx, "POST", "/rest/api/2/issue/"+issue+"/transitions", request, nil)
+		}
+	}
+
+	return fmt.Errorf("Jira issue %s has no '%s' transition", issue, j.CloseTransition)
+}
+
+// Make a Jira API request, decoding the response into result if there is one
+func (j *JiraNotifier) call(ctx context.Context, method string, path string,
+	body interface{}, result interface{}) error {
+
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, j.Url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Jira Cloud wants an email and API token, Data Center a personal token
+	if j.Username != "" {
+		req.SetBasicAuth(j.Username, j.Token)
+	} else if j.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.Token)
+	}
+
+	resp, err := j.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Jira returned %s", resp.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
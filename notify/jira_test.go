@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_JiraNotifier(t *testing.T) {
+	Convey("Jira notifier", t, func() {
+		var requests []string
+		var created map[string]interface{}
+		failures := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			switch r.URL.Path {
+			case "/rest/api/2/issue":
+				json.NewDecoder(r.Body).Decode(&created)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id": "1", "key": "WAR-1916"}`))
+			case "/rest/api/2/issue/WAR-1916/transitions":
+				if r.Method == "GET" {
+					w.Write([]byte(`{"transitions": [{"id": "11", "name": "Start"}, {"id": "31", "name": "Done"}]}`))
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusCreated)
+			}
+		}))
+		defer server.Close()
+
+		jira := NewJiraNotifier(server.URL, "WAR")
+		jira.Threshold = time.Hour
+		jira.Projects["belgium"] = JiraProject{Project: "YPRES", IssueType: "Incident"}
+
+		change := func(id string, status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{ID: id, Name: "verdun", Hostname: "meuse", Status: status},
+					PreviousStatus: service.ALIVE,
+				},
+			}
+		}
+
+		Convey("Opens an issue for a sustained outage and closes it on recovery", func() {
+			So(jira.Notify(context.Background(), change("1", service.UNHEALTHY)), ShouldBeNil)
+			So(jira.Notify(context.Background(), change("2", service.UNHEALTHY)), ShouldBeNil)
+
+			// What the timer does once the threshold has passed
+			jira.open("france/verdun", jira.outages["france/verdun"])
+
+			fields := created["fields"].(map[string]interface{})
+			So(fields["project"], ShouldResemble, map[string]interface{}{"key": "WAR"})
+			So(fields["issuetype"], ShouldResemble, map[string]interface{}{"name": "Bug"})
+			So(fields["summary"], ShouldEqual, "[france] verdun has been unhealthy for more than 1h0m0s")
+
+			So(jira.Notify(context.Background(), change("1", service.ALIVE)), ShouldBeNil)
+			So(jira.Notify(context.Background(), change("2", service.TOMBSTONE)), ShouldBeNil)
+
+			So(requests, ShouldResemble, []string{
+				"POST /rest/api/2/issue",
+				"POST /rest/api/2/issue/WAR-1916/comment",
+				"GET /rest/api/2/issue/WAR-1916/transitions",
+				"POST /rest/api/2/issue/WAR-1916/transitions",
+			})
+		})
+
+		Convey("Tries again when it can't open the issue", func() {
+			jira.Notify(context.Background(), change("1", service.UNHEALTHY))
+			outage := jira.outages["france/verdun"]
+
+			failures = 1
+			jira.open("france/verdun", outage)
+			So(outage.issue, ShouldBeEmpty)
+			So(outage.timer.Stop(), ShouldBeTrue) // Rescheduled
+
+			jira.open("france/verdun", outage)
+			So(outage.issue, ShouldEqual, "WAR-1916")
+		})
+
+		Convey("Keeps the outage until its issue is closed", func() {
+			jira.Notify(context.Background(), change("1", service.UNHEALTHY))
+			jira.open("france/verdun", jira.outages["france/verdun"])
+
+			failures = 1
+			So(jira.Notify(context.Background(), change("1", service.ALIVE)), ShouldNotBeNil)
+			So(jira.outages, ShouldContainKey, "france/verdun")
+
+			// The retry
+			So(jira.Notify(context.Background(), change("1", service.ALIVE)), ShouldBeNil)
+			So(jira.outages, ShouldBeEmpty)
+			So(requests[len(requests)-1], ShouldEqual, "POST /rest/api/2/issue/WAR-1916/transitions")
+		})
+
+		Convey("Doesn't open an issue for a short outage", func() {
+			jira.Notify(context.Background(), change("1", service.UNHEALTHY))
+			jira.Notify(context.Background(), change("1", service.ALIVE))
+
+			So(jira.outages, ShouldBeEmpty)
+			So(requests, ShouldBeEmpty)
+		})
+
+		Convey("Files issues in the cluster's project", func() {
+			So(jira.projectFor("belgium"), ShouldResemble, JiraProject{Project: "YPRES", IssueType: "Incident"})
+			So(jira.projectFor("france"), ShouldResemble, jira.Default)
+		})
+
+		Convey("Reads per-cluster projects from its settings", func() {
+			notifier, err := NewNotifier(Settings{
+				"type": "jira", "url": server.URL, "project": "WAR", "threshold": "30m",
+				"clusters": map[string]interface{}{
+					"belgium": map[string]interface{}{"project": "YPRES"},
+				},
+			})
+
+			So(err, ShouldBeNil)
+			configured := notifier.(*JiraNotifier)
+			So(configured.Threshold, ShouldEqual, 30*time.Minute)
+			So(configured.projectFor("belgium"), ShouldResemble, JiraProject{Project: "YPRES", IssueType: "Bug"})
+		})
+	})
+}
@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+)
+
+const (
+	DELIVERY_ATTEMPTS   = 3
+	DELIVERY_BACKOFF    = 1 * time.Second // Doubled after each failed attempt
+	DEFAULT_BREAK_AFTER = 5               // Failed deliveries in a row
+	DEFAULT_COOLDOWN    = 5 * time.Minute
+)
+
+// Returned instead of trying a notifier whose circuit breaker is open
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+// Counts every delivery attempt by notifier and result
+var Deliveries = metrics.NewCounterVec(
+	"superside_notifier_deliveries_total",
+	"Attempts to deliver a notification, by notifier and result",
+	"notifier", "result",
+)
+
+// 1 while a notifier's circuit breaker is open, 0 otherwise
+var CircuitOpen = metrics.NewGaugeVec(
+	"superside_notifier_circuit_open",
+	"Whether the notifier's circuit breaker is open and deliveries are being skipped",
+	"notifier",
+)
+
+// A snapshot of how a notifier has been doing
+type DeliveryStatus struct {
+	Name                string
+	Healthy             bool
+	LastSuccess         time.Time `json:",omitempty"`
+	LastError           string    `json:",omitempty"`
+	ConsecutiveFailures int
+	CircuitOpen         bool
+	OpenUntil           time.Time `json:",omitempty"`
+}
+
+// The status with any URLs taken out of the last error, for showing publicly
+func (s DeliveryStatus) Redacted() DeliveryStatus {
+	s.LastError = RedactURLs(s.LastError)
+	return s
+}
+
+// Wraps a Notifier with the plumbing every notifier needs: retries with
+// backoff, a timeout per attempt, metrics, and tracking whether deliveries
+// are working.
+//
+// After BreakAfter failed deliveries in a row the circuit breaker opens and
+// we stop calling the notifier for Cooldown, so a dead webhook doesn't hold
+// up every notification with retries. Once the cooldown is over the next
+// delivery is tried as normal, and either closes the circuit or opens it
+// again. A BreakAfter of 0 turns the breaker off.
+//
+// The outcome of each delivery goes in the Log, if there is one.
+type Managed struct {
+	Notifier
+	Attempts            int
+	Backoff             time.Duration
+	Timeout             time.Duration
+	BreakAfter          int
+	Cooldown            time.Duration
+	Log                 *DeliveryLog // Optional
+	openUntil           time.Time    // Zero while the circuit is closed
+	lastSuccess         time.Time
+	lastError           error
+	consecutiveFailures int
+	lock                sync.RWMutex
+}
+
+func NewManaged(notifier Notifier) *Managed {
+	return &Managed{
+		Notifier:   notifier,
+		Attempts:   DELIVERY_ATTEMPTS,
+		Backoff:    DELIVERY_BACKOFF,
+		Timeout:    HTTP_TIMEOUT,
+		BreakAfter: DEFAULT_BREAK_AFTER,
+		Cooldown:   DEFAULT_COOLDOWN,
+	}
+}
+
+func (m *Managed) attempt(ctx context.Context, notice *datatypes.Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Notifier.Notify(ctx, notice)
+}
+
+func (m *Managed) record(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now().UTC()
+
+	if err != nil {
+		m.lastError = err
+		m.consecutiveFailures += 1
+
+		if m.BreakAfter > 0 && m.consecutiveFailures >= m.BreakAfter {
+			if m.openUntil.IsZero() {
+				log.Warnf("Circuit breaker for %s opened after %d failed deliveries, pausing for %s",
+					m.Name(), m.consecutiveFailures, m.Cooldown)
+			}
+			m.openUntil = now.Add(m.Cooldown)
+			CircuitOpen.Set(1, m.Name())
+		}
+		return
+	}
+
+	if !m.openUntil.IsZero() {
+		log.Infof("Circuit breaker for %s closed, deliveries are working again", m.Name())
+		m.openUntil = time.Time{}
+		CircuitOpen.Set(0, m.Name())
+	}
+
+	m.lastSuccess = now
+	m.consecutiveFailures = 0
+}
+
+// Are we skipping deliveries at the moment?
+func (m *Managed) circuitOpen(now time.Time) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return now.Before(m.openUntil)
+}
+
+// Try to deliver the notification, retrying with backoff. Returns the last
+// error if every attempt failed or the context was cancelled.
+func (m *Managed) Deliver(ctx context.Context, notice *datatypes.Notification) error {
+	attempts, err := m.deliver(ctx, notice)
+
+	if m.Log != nil {
+		record := DeliveryRecord{
+			Notifier: m.Name(),
+			Time:     time.Now().UTC(),
+			Attempts: attempts,
+			Success:  err == nil,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		m.Log.Record(notice.ID, record)
+	}
+
+	return err
+}
+
+// Returns how many attempts it made, which is 0 if the circuit was open
+func (m *Managed) deliver(ctx context.Context, notice *datatypes.Notification) (int, error) {
+	if m.circuitOpen(time.Now().UTC()) {
+		Deliveries.Inc(m.Name(), "skipped")
+		return 0, ErrCircuitOpen
+	}
+
+	var err error
+	backoff := m.Backoff
+
+	for i := 0; i < m.Attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				m.record(ctx.Err())
+				return i, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = m.attempt(ctx, notice)
+		if err == nil {
+			Deliveries.Inc(m.Name(), "success")
+			m.record(nil)
+			return i + 1, nil
+		}
+
+		Deliveries.Inc(m.Name(), "failure")
+	}
+
+	m.record(err)
+	return m.Attempts, err
+}
+
+// Healthy if the notifier says so and the last delivery worked
+func (m *Managed) Healthy() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.consecutiveFailures == 0 && m.Notifier.Healthy()
+}
+
+func (m *Managed) Status() DeliveryStatus {
+	healthy := m.Healthy()
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	status := DeliveryStatus{
+		Name:                m.Name(),
+		Healthy:             healthy,
+		LastSuccess:         m.lastSuccess,
+		ConsecutiveFailures: m.consecutiveFailures,
+	}
+
+	if time.Now().UTC().Before(m.openUntil) {
+		status.CircuitOpen = true
+		status.OpenUntil = m.openUntil
+	}
+
+	if m.lastError != nil {
+		status.LastError = m.lastError.Error()
+	}
+
+	return status
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Attachment sidebar colours for each severity
+var mattermostColors = map[string]string{
+	SEVERITY_CRITICAL: "#e01e5a",
+	SEVERITY_WARNING:  "#ecb22e",
+	SEVERITY_OK:       "#2eb67d",
+	SEVERITY_INFO:     "#439fe0",
+}
+
+// Posts alerts to a Mattermost incoming webhook as a coloured attachment,
+// with the details as short fields. The text comes from MessageFor() unless
+// there's a Template.
+type MattermostNotifier struct {
+	alwaysHealthy
+	WebhookUrl string
+	Channel    string
+	Username   string
+	IconUrl    string    // Optional
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+type mattermostMessage struct {
+	Channel     string                 `json:"channel,omitempty"`
+	Username    string                 `json:"username,omitempty"`
+	IconUrl     string                 `json:"icon_url,omitempty"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+type mattermostAttachment struct {
+	Fallback string            `json:"fallback"`
+	Color    string            `json:"color"`
+	Text     string            `json:"text"`
+	Fields   []mattermostField `json:"fields,omitempty"`
+}
+
+type mattermostField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func init() {
+	RegisterFactory("mattermost", func(settings Settings) (Notifier, error) {
+		if settings.String("webhook_url") == "" {
+			return nil, errors.New("webhook_url is required")
+		}
+
+		username := settings.String("username")
+		if username == "" {
+			username = "superside"
+		}
+
+		mattermost := NewMattermostNotifier(
+			settings.String("webhook_url"), settings.String("channel"), username,
+		)
+		mattermost.IconUrl = settings.String("icon_url")
+
+		var err error
+		mattermost.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return mattermost, nil
+	})
+}
+
+func NewMattermostNotifier(webhookUrl string, channel string, username string) *MattermostNotifier {
+	return &MattermostNotifier{
+		WebhookUrl: webhookUrl,
+		Channel:    channel,
+		Username:   username,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (m *MattermostNotifier) Name() string {
+	return "mattermost"
+}
+
+func (m *MattermostNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	text, err := renderText(notice, m.Template)
+	if err != nil {
+		return err
+	}
+
+	attachment := mattermostAttachment{
+		Fallback: text,
+		Color:    mattermostColors[SeverityOf(notice)],
+		Text:     text,
+	}
+	for _, field := range fieldsFor(notice) {
+		attachment.Fields = append(attachment.Fields,
+			mattermostField{Title: field.Name, Value: field.Value, Short: true},
+		)
+	}
+
+	message := mattermostMessage{
+		Channel:     m.Channel,
+		Username:    m.Username,
+		IconUrl:     m.IconUrl,
+		Attachments: []mattermostAttachment{attachment},
+	}
+
+	return postJSON(ctx, m.client, m.WebhookUrl, message, "Mattermost webhook", http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MattermostNotifier(t *testing.T) {
+	Convey("Mattermost notifier", t, func() {
+		var received mattermostMessage
+		endpoint := newFakeEndpoint(&received, http.StatusOK)
+		defer endpoint.Close()
+
+		mattermost := NewMattermostNotifier(endpoint.URL, "town-square", "superside")
+		notice := failureNotice()
+
+		Convey("Posts a coloured attachment", func() {
+			So(mattermost.Notify(context.Background(), notice), ShouldBeNil)
+
+			So(received.Channel, ShouldEqual, "town-square")
+			So(received.Username, ShouldEqual, "superside")
+
+			attachment := received.Attachments[0]
+			So(attachment.Text, ShouldEqual, MessageFor(notice))
+			So(attachment.Color, ShouldEqual, mattermostColors[SEVERITY_CRITICAL])
+			So(attachment.Fields[0], ShouldResemble, mattermostField{Title: "Cluster", Value: "france", Short: true})
+		})
+
+		Convey("Reports errors from Mattermost", func() {
+			endpoint.Status = http.StatusForbidden
+			So(mattermost.Notify(context.Background(), notice), ShouldNotBeNil)
+		})
+	})
+}
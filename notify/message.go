@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Render a human readable summary of a notification, one line per event
+func MessageFor(notice *datatypes.Notification) string {
+	switch notice.Type {
+	case datatypes.FLAPPING_NOTICE:
+		return fmt.Sprintf("[%s] service %s is flapping (%d transitions in %s)",
+			notice.ClusterName, notice.Flap.Service, notice.Flap.Transitions, notice.Flap.Window,
+		)
+	case datatypes.DIGEST_NOTICE:
+		lines := make([]string, 0, len(notice.Digest)+1)
+		lines = append(lines, fmt.Sprintf("%d notifications during quiet hours:", len(notice.Digest)))
+		for _, held := range notice.Digest {
+			lines = append(lines, MessageFor(held))
+		}
+		return strings.Join(lines, "\n")
+	case datatypes.REPORT_NOTICE:
+		return reportMessage(notice.Report)
+	case datatypes.CLOCK_SKEW_NOTICE:
+		return fmt.Sprintf("[%s] clock on %s is off by %s, using receipt times for its events",
+			notice.ClusterName, notice.SkewedHost.Hostname, notice.SkewedHost.Skew,
+		)
+	case datatypes.BURST_NOTICE:
+		lines := make([]string, 0, len(notice.Burst)+1)
+		lines = append(lines, fmt.Sprintf("[%s] %d events in a burst", notice.ClusterName, len(notice.Burst)))
+		for _, member := range notice.Burst {
+			lines = append(lines, MessageFor(member))
+		}
+		return strings.Join(lines, "\n")
+	case datatypes.CLUSTER_SILENT_NOTICE:
+		return fmt.Sprintf("[%s] cluster has been silent since %s (more than %s)",
+			notice.ClusterName, notice.Stale.LastSeen.Format(time.RFC3339), notice.Stale.Timeout,
+		)
+	case datatypes.CLUSTER_RESUMED_NOTICE:
+		return fmt.Sprintf("[%s] cluster is sending updates again", notice.ClusterName)
+	case datatypes.DEPLOY_NOTICE:
+		return fmt.Sprintf("[%s] %s deployed %s (was %s) on %s",
+			notice.ClusterName, notice.Deploy.Service, notice.Deploy.Version,
+			notice.Deploy.PreviousVersion, notice.Deploy.Hostname,
+		)
+	case datatypes.HEARTBEAT_NOTICE:
+		return fmt.Sprintf("superside heartbeat #%d", notice.Heartbeat.Sequence)
+	case datatypes.STABILIZED_NOTICE:
+		return fmt.Sprintf("[%s] service %s has stopped flapping",
+			notice.ClusterName, notice.Flap.Service,
+		)
+	case datatypes.INCIDENT_NOTICE:
+		return incidentMessage(notice.ClusterName, notice.Incident)
+	case datatypes.ANOMALY_NOTICE:
+		return fmt.Sprintf("[%s] unusual rate of change: %d transitions in %s, usually %.1f (mostly %s)",
+			notice.ClusterName, notice.Anomaly.Transitions, notice.Anomaly.Window,
+			notice.Anomaly.Baseline, notice.Anomaly.MostlyStatus(),
+		)
+	}
+
+	prefix := ""
+	if notice.Escalated {
+		prefix = "ESCALATED: "
+	}
+
+	svc := notice.Event.Service
+	return prefix + fmt.Sprintf("[%s] %s (%s) on %s went from %s to %s",
+		notice.ClusterName, svc.Name, svc.Image, svc.Hostname,
+		datatypes.StatusString(notice.Event.PreviousStatus), datatypes.StatusString(svc.Status),
+	)
+}
+
+const (
+	SEVERITY_CRITICAL = datatypes.SEVERITY_CRITICAL
+	SEVERITY_WARNING  = datatypes.SEVERITY_WARNING
+	SEVERITY_INFO     = datatypes.SEVERITY_INFO
+	SEVERITY_OK       = "ok" // Only for colouring info notifications about recoveries
+)
+
+// How bad a notification is, for notifiers that colour code their messages.
+// This is the severity the tracker classified it with, except that info
+// notifications about things getting better come back as SEVERITY_OK.
+func SeverityOf(notice *datatypes.Notification) string {
+	switch {
+	case notice.SeverityAtLeast(SEVERITY_CRITICAL):
+		return SEVERITY_CRITICAL
+	case notice.SeverityAtLeast(SEVERITY_WARNING):
+		return SEVERITY_WARNING
+	case isRecovery(notice):
+		return SEVERITY_OK
+	}
+
+	return SEVERITY_INFO
+}
+
+// Is this news of something coming back? A burst is if all of it is.
+func isRecovery(notice *datatypes.Notification) bool {
+	switch notice.Type {
+	case datatypes.BURST_NOTICE:
+		for _, member := range notice.Burst {
+			if !isRecovery(member) {
+				return false
+			}
+		}
+		return len(notice.Burst) > 0
+	case datatypes.STABILIZED_NOTICE, datatypes.CLUSTER_RESUMED_NOTICE:
+		return true
+	case datatypes.INCIDENT_NOTICE:
+		return notice.Incident != nil && notice.Incident.IsResolved()
+	case datatypes.SERVICE_EVENT_NOTICE:
+		return notice.Event != nil && notice.Event.Service.Status == service.ALIVE
+	}
+
+	return false
+}
+
+// A name and value pair for notifiers that lay the details out as a table
+type messageField struct {
+	Name  string
+	Value string
+}
+
+// The details of a notification worth showing beside the message
+func fieldsFor(notice *datatypes.Notification) []messageField {
+	fields := []messageField{{"Cluster", notice.ClusterName}}
+	if notice.Region != "" {
+		fields = append(fields, messageField{"Region", notice.Region})
+	}
+	if notice.CorrelationID != "" {
+		fields = append(fields, messageField{"Correlation", notice.CorrelationID})
+	}
+	for _, key := range sortedLabelKeys(notice.Labels) {
+		fields = append(fields, messageField{key, notice.Labels[key]})
+	}
+	for _, name := range sortedLabelKeys(notice.Links) {
+		fields = append(fields, messageField{name, notice.Links[name]})
+	}
+
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return fields
+	}
+
+	svc := notice.Event.Service
+	return append(fields,
+		messageField{"Service", svc.Name},
+		messageField{"Host", svc.Hostname},
+		messageField{"Image", svc.Image},
+		messageField{"Transition", notice.Transition()},
+	)
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func reportMessage(report *datatypes.DigestReport) string {
+	lines := []string{fmt.Sprintf("%s: %d transitions from %s to %s",
+		report.Name, report.TotalTransitions(),
+		report.Start.Format("2006-01-02 15:04"), report.End.Format("2006-01-02 15:04"),
+	)}
+
+	clusters := make([]string, 0, len(report.Transitions))
+	for cluster := range report.Transitions {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		lines = append(lines, fmt.Sprintf("  %s: %d transitions", cluster, report.Transitions[cluster]))
+	}
+
+	if len(report.TopFlapping) > 0 {
+		lines = append(lines, "Most flapping:")
+	}
+	for _, flapping := range report.TopFlapping {
+		lines = append(lines, fmt.Sprintf("  [%s] %s flapped %d times",
+			flapping.ClusterName, flapping.Service, flapping.Count,
+		))
+	}
+
+	if len(report.LongestOutages) > 0 {
+		lines = append(lines, "Longest outages:")
+	}
+	for _, outage := range report.LongestOutages {
+		ongoing := ""
+		if outage.Ongoing {
+			ongoing = " (ongoing)"
+		}
+		lines = append(lines, fmt.Sprintf("  [%s] %s on %s for %s%s",
+			outage.ClusterName, outage.Service, outage.Hostname, outage.Duration, ongoing,
+		))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// e.g. "[france] incident opened: verdun, somme unhealthy (critical)"
+func incidentMessage(clusterName string, incident *datatypes.Incident) string {
+	services := strings.Join(incident.Services, ", ")
+
+	switch incident.Status {
+	case datatypes.INCIDENT_RESOLVED:
+		if incident.Ack != nil && incident.Ack.Resolved {
+			return fmt.Sprintf("[%s] incident resolved by %s: %s", clusterName, incident.Ack.User, services)
+		}
+		return fmt.Sprintf("[%s] incident resolved, %s recovered", clusterName, services)
+	case datatypes.INCIDENT_ACKNOWLEDGED:
+		return fmt.Sprintf("[%s] incident acknowledged by %s: %s", clusterName, incident.Ack.User, services)
+	}
+
+	return fmt.Sprintf("[%s] incident opened: %s unhealthy (%s)", clusterName, services, incident.Severity)
+}
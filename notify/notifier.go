@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Anything that can deliver a notification somewhere. Implementations only
+// need to make one attempt; retries, metrics and health tracking are handled
+// by wrapping them in a Managed notifier.
+type Notifier interface {
+	Notify(ctx context.Context, notice *datatypes.Notification) error
+	Name() string
+	Healthy() bool
+}
+
+// The settings for one notifier from a [[notifier]] section of the config.
+// "type" picks the factory and "name" optionally overrides its name.
+type Settings map[string]interface{}
+
+func (s Settings) String(key string) string {
+	value, _ := s[key].(string)
+	return value
+}
+
+func (s Settings) Bool(key string, defaultValue bool) bool {
+	value, ok := s[key].(bool)
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+// TOML integers come through as int64
+func (s Settings) Int(key string, defaultValue int) int {
+	switch value := s[key].(type) {
+	case int64:
+		return int(value)
+	case int:
+		return value
+	}
+	return defaultValue
+}
+
+func (s Settings) Strings(key string) []string {
+	switch values := s[key].(type) {
+	case []string:
+		return values
+	case []interface{}:
+		strs := make([]string, 0, len(values))
+		for _, value := range values {
+			if str, ok := value.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// A nested table, e.g. [notifier.clusters] or an inline { ... }
+func (s Settings) Section(key string) Settings {
+	switch section := s[key].(type) {
+	case Settings:
+		return section
+	case map[string]interface{}:
+		return Settings(section)
+	}
+	return Settings{}
+}
+
+func (s Settings) Duration(key string) (time.Duration, error) {
+	if s.String(key) == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s.String(key))
+}
+
+// Builds a notifier from its settings
+type Factory func(settings Settings) (Notifier, error)
+
+var (
+	factories     = make(map[string]Factory, 10)
+	factoriesLock sync.RWMutex
+)
+
+// Make a new type of notifier available to the config. Call it from an
+// init() function.
+func RegisterFactory(kind string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	factories[kind] = factory
+}
+
+// The notifier types that can be configured
+func Types() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	kinds := make([]string, 0, len(factories))
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+type namedNotifier struct {
+	Notifier
+	name string
+}
+
+func (n *namedNotifier) Name() string {
+	return n.name
+}
+
+// Build a notifier of the type named in the settings
+func NewNotifier(settings Settings) (Notifier, error) {
+	kind := settings.String("type")
+
+	factoriesLock.RLock()
+	factory, ok := factories[kind]
+	factoriesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown notifier type '%s'", kind)
+	}
+
+	notifier, err := factory(settings)
+	if err != nil {
+		return nil, fmt.Errorf("Can't configure %s notifier: %s", kind, err.Error())
+	}
+
+	if name := settings.String("name"); name != "" {
+		return &namedNotifier{notifier, name}, nil
+	}
+
+	return notifier, nil
+}
+
+// Keeps track of every notifier in use, so their health can be reported.
+// If there's a Log, the notifiers record their deliveries in it.
+type Registry struct {
+	Log     *DeliveryLog // Optional
+	managed []*Managed
+	owners  map[string]bool // Owners with a route of their own
+	lock    sync.RWMutex
+}
+
+var DefaultRegistry = &Registry{Log: NewDeliveryLog(DEFAULT_DELIVERY_LOG_SIZE)}
+
+// Wrap a notifier for delivery and keep track of it
+func (r *Registry) Register(notifier Notifier) *Managed {
+	managed := NewManaged(notifier)
+	managed.Log = r.Log
+
+	r.lock.Lock()
+	r.managed = append(r.managed, managed)
+	r.lock.Unlock()
+
+	return managed
+}
+
+// Find a notifier by name, or nil if there isn't one
+func (r *Registry) Get(name string) *Managed {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, managed := range r.managed {
+		if managed.Name() == name {
+			return managed
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) All() []*Managed {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return append([]*Managed{}, r.managed...)
+}
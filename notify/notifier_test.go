@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Fails the first `failures` times it's called
+type flakyNotifier struct {
+	failures int
+	calls    int
+}
+
+func (f *flakyNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	f.calls += 1
+	if f.calls <= f.failures {
+		return errors.New("carrier pigeon shot down")
+	}
+	return nil
+}
+
+func (f *flakyNotifier) Name() string  { return "pigeon" }
+func (f *flakyNotifier) Healthy() bool { return true }
+
+func Test_NotifierFactories(t *testing.T) {
+	Convey("Building notifiers from settings", t, func() {
+		RegisterFactory("pigeon", func(settings Settings) (Notifier, error) {
+			return &flakyNotifier{}, nil
+		})
+
+		Convey("Uses the factory for the type", func() {
+			notifier, err := NewNotifier(Settings{"type": "pigeon"})
+			So(err, ShouldBeNil)
+			So(notifier.Name(), ShouldEqual, "pigeon")
+			So(Types(), ShouldContain, "slack")
+		})
+
+		Convey("Lets the config rename it", func() {
+			notifier, _ := NewNotifier(Settings{"type": "pigeon", "name": "cher-ami"})
+			So(notifier.Name(), ShouldEqual, "cher-ami")
+		})
+
+		Convey("Rejects unknown types and bad settings", func() {
+			_, err := NewNotifier(Settings{"type": "semaphore"})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewNotifier(Settings{"type": "slack"})
+			So(err.Error(), ShouldContainSubstring, "webhook_url")
+		})
+
+		Convey("Builds a dispatcher with the routing settings", func() {
+			registry := &Registry{}
+			dispatcher, err := NewDispatcherFromSettings(Settings{
+				"type":            "pigeon",
+				"dampen_flapping": false,
+				"repeat_interval": "30m",
+				"regions":         []interface{}{"western-front"},
+				"clusters":        []interface{}{"france"},
+				"match":           `status == UNHEALTHY`,
+				"throttle":        "5m",
+				"dedup":           true,
+				"quiet_hours":     "22:00-07:00",
+				"escalate_after":  "15m",
+				"escalate_to":     "pigeon",
+				"break_after":     int64(10),
+				"break_cooldown":  "1m",
+			}, registry)
+
+			So(err, ShouldBeNil)
+			So(dispatcher.DampenFlapping, ShouldBeFalse)
+			So(dispatcher.RepeatInterval, ShouldEqual, 30*time.Minute)
+			So(dispatcher.Regions, ShouldResemble, []string{"western-front"})
+			So(dispatcher.Clusters, ShouldResemble, []string{"france"})
+			So(dispatcher.Match.Source, ShouldEqual, "status == UNHEALTHY")
+			So(dispatcher.Throttle.Interval, ShouldEqual, 5*time.Minute)
+			So(dispatcher.Throttle.Dedup, ShouldBeTrue)
+			So(dispatcher.Quiet.Start, ShouldEqual, 22*time.Hour)
+			So(dispatcher.EscalateAfter, ShouldEqual, 15*time.Minute)
+			So(dispatcher.EscalateTo, ShouldEqual, "pigeon")
+			So(len(registry.All()), ShouldEqual, 1)
+			So(registry.All()[0].BreakAfter, ShouldEqual, 10)
+			So(registry.All()[0].Cooldown, ShouldEqual, time.Minute)
+		})
+
+		Convey("Won't escalate without somewhere to escalate to", func() {
+			_, err := NewDispatcherFromSettings(Settings{
+				"type": "pigeon", "escalate_after": "15m",
+			}, &Registry{})
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Rejects bad match expressions", func() {
+			_, err := NewDispatcherFromSettings(Settings{
+				"type": "pigeon", "match": "status ==",
+			}, &Registry{})
+
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func Test_Managed(t *testing.T) {
+	Convey("Managed notifiers", t, func() {
+		flaky := &flakyNotifier{}
+		managed := NewManaged(flaky)
+		managed.Backoff = time.Millisecond
+
+		notice := &datatypes.Notification{}
+
+		Convey("Retry until delivery works", func() {
+			flaky.failures = 2
+
+			So(managed.Deliver(context.Background(), notice), ShouldBeNil)
+			So(flaky.calls, ShouldEqual, 3)
+			So(managed.Healthy(), ShouldBeTrue)
+			So(Deliveries.Get("pigeon", "failure"), ShouldBeGreaterThanOrEqualTo, 2)
+		})
+
+		Convey("Give up and turn unhealthy after too many failures", func() {
+			flaky.failures = 5
+
+			So(managed.Deliver(context.Background(), notice), ShouldNotBeNil)
+			So(flaky.calls, ShouldEqual, DELIVERY_ATTEMPTS)
+			So(managed.Healthy(), ShouldBeFalse)
+			So(managed.Status().LastError, ShouldEqual, "carrier pigeon shot down")
+
+			Convey("and recover after the next success", func() {
+				So(managed.Deliver(context.Background(), notice), ShouldBeNil)
+				So(managed.Healthy(), ShouldBeTrue)
+				So(managed.Status().ConsecutiveFailures, ShouldEqual, 0)
+			})
+		})
+
+		Convey("Open the circuit breaker after too many failed deliveries", func() {
+			managed.Attempts = 1
+			managed.BreakAfter = 2
+			flaky.failures = 100
+
+			managed.Deliver(context.Background(), notice)
+			So(managed.Status().CircuitOpen, ShouldBeFalse)
+			managed.Deliver(context.Background(), notice)
+			So(managed.Status().CircuitOpen, ShouldBeTrue)
+
+			So(managed.Deliver(context.Background(), notice), ShouldEqual, ErrCircuitOpen)
+			So(flaky.calls, ShouldEqual, 2)
+			So(Deliveries.Get("pigeon", "skipped"), ShouldBeGreaterThanOrEqualTo, 1)
+
+			Convey("and close it when a delivery works after the cooldown", func() {
+				flaky.failures = 0
+				managed.openUntil = time.Now().UTC().Add(-time.Second)
+
+				So(managed.Deliver(context.Background(), notice), ShouldBeNil)
+				So(managed.Status().CircuitOpen, ShouldBeFalse)
+				So(managed.Healthy(), ShouldBeTrue)
+			})
+
+			Convey("or open it again when that delivery fails", func() {
+				managed.openUntil = time.Now().UTC().Add(-time.Second)
+
+				So(managed.Deliver(context.Background(), notice), ShouldNotEqual, ErrCircuitOpen)
+				So(flaky.calls, ShouldEqual, 3)
+				So(managed.Status().CircuitOpen, ShouldBeTrue)
+			})
+		})
+
+		Convey("Stop retrying when the context is cancelled", func() {
+			flaky.failures = 5
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			So(managed.Deliver(ctx, notice), ShouldEqual, context.Canceled)
+			So(flaky.calls, ShouldEqual, 1)
+		})
+	})
+}
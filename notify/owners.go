@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	OWNER_LABEL = "owner" // The service label naming the team that owns it
+)
+
+// Note that these owners have a route of their own
+func (r *Registry) claimOwners(owners ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.owners == nil {
+		r.owners = make(map[string]bool, len(owners))
+	}
+	for _, owner := range owners {
+		r.owners[owner] = true
+	}
+}
+
+// Does some dispatcher route this owner's notifications?
+func (r *Registry) routesOwner(owner string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.owners[owner]
+}
+
+// Is this notification ours to send, going by who owns the service? With
+// neither Owners nor Unowned set, everything is. Heartbeats always are,
+// since they check the route itself works.
+func (d *Dispatcher) owned(notice *datatypes.Notification) bool {
+	if (len(d.Owners) == 0 && !d.Unowned) || notice.Type == datatypes.HEARTBEAT_NOTICE {
+		return true
+	}
+
+	owner := notice.Labels[OWNER_LABEL]
+	for _, ours := range d.Owners {
+		if owner == ours {
+			return true
+		}
+	}
+
+	return d.Unowned && (owner == "" || !d.registry.routesOwner(owner))
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_OwnerRouting(t *testing.T) {
+	Convey("Routing by owner", t, func() {
+		registry := &Registry{}
+		route := func(settings Settings) *Dispatcher {
+			settings["type"] = "pigeon"
+			dispatcher, err := NewDispatcherFromSettings(settings, registry)
+			So(err, ShouldBeNil)
+			return dispatcher
+		}
+
+		payments := route(Settings{"owners": []interface{}{"payments"}})
+		fallback := route(Settings{"unowned": true})
+		everything := route(Settings{})
+
+		failure := func(owner string) *datatypes.Notification {
+			notice := &datatypes.Notification{
+				Type: datatypes.SERVICE_EVENT_NOTICE,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: "ledger", Status: service.UNHEALTHY},
+					PreviousStatus: service.ALIVE,
+				},
+			}
+			if owner != "" {
+				notice.Labels = map[string]string{OWNER_LABEL: owner}
+			}
+			return notice
+		}
+
+		Convey("Sends a team's notifications to its route", func() {
+			So(payments.ShouldAlert(failure("payments")), ShouldBeTrue)
+			So(fallback.ShouldAlert(failure("payments")), ShouldBeFalse)
+		})
+
+		Convey("Sends the rest to the default route", func() {
+			So(payments.ShouldAlert(failure("")), ShouldBeFalse)
+			So(fallback.ShouldAlert(failure("")), ShouldBeTrue)
+
+			// Owned, but by a team without a route
+			So(payments.ShouldAlert(failure("search")), ShouldBeFalse)
+			So(fallback.ShouldAlert(failure("search")), ShouldBeTrue)
+
+			silent := &datatypes.Notification{Type: datatypes.CLUSTER_SILENT_NOTICE}
+			So(fallback.ShouldAlert(silent), ShouldBeTrue)
+		})
+
+		Convey("Leaves routes without owners alone", func() {
+			So(everything.ShouldAlert(failure("payments")), ShouldBeTrue)
+			So(everything.ShouldAlert(failure("")), ShouldBeTrue)
+		})
+
+		Convey("Still sends heartbeats everywhere that wants them", func() {
+			payments.Heartbeats = true
+			So(payments.ShouldAlert(&datatypes.Notification{Type: datatypes.HEARTBEAT_NOTICE}), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,248 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	// Set in a plugin's environment so it can tell it was started by us
+	PLUGIN_COOKIE_KEY   = "SUPERSIDE_PLUGIN"
+	PLUGIN_COOKIE_VALUE = "d4d0b4e2-notifier-v1"
+
+	PLUGIN_NAME_TIMEOUT = 5 * time.Second // How long a plugin has to tell us its name
+)
+
+// Out-of-process notifiers. A plugin is any executable that speaks JSON-RPC
+// on its stdin and stdout; Go plugins just wrap a Notifier in ServePlugin().
+// We start it on first use and restart it if it dies.
+//
+//	[[notifier]]
+//	type = "plugin"
+//	path = "/usr/local/bin/superside-pagerduty"
+//	args = ["--service-key", "..."]
+//
+// The protocol is JSON-RPC 1.0, as spoken by Go's net/rpc/jsonrpc, so a
+// plugin can be written in anything that reads and writes JSON:
+//
+//   - The plugin is started with SUPERSIDE_PLUGIN=d4d0b4e2-notifier-v1 in its
+//     environment. Its stderr goes to ours, stdout is only for responses.
+//   - Requests come in on stdin as a stream of JSON objects, one per line:
+//     {"method": "Plugin.Notify", "params": [{"Notice": {...}}], "id": 1}
+//     The notice is a Notification as served by /api/v1/events/:id.
+//     {"method": "Plugin.Name", "params": [{}], "id": 2}
+//   - Each gets one response on stdout with the same id, in any order, as
+//     several can be in flight at once:
+//     {"id": 1, "result": {}, "error": null}
+//     {"id": 2, "result": "pagerduty", "error": null}
+//     A failed delivery sets "error" to a message and "result" to null, and
+//     is retried like any other notifier's.
+//   - Plugin.Name is only asked once. A plugin that doesn't answer within
+//     5 seconds is known by its path, unless it has a "name" in the config.
+//   - The plugin exits when stdin is closed.
+
+// The RPC service a plugin exposes, as "Plugin.Notify" and "Plugin.Name"
+type PluginRPC struct {
+	notifier Notifier
+}
+
+type PluginNotifyArgs struct {
+	Notice *datatypes.Notification
+}
+
+type PluginEmpty struct{}
+
+func (p *PluginRPC) Notify(args *PluginNotifyArgs, reply *PluginEmpty) error {
+	return p.notifier.Notify(context.Background(), args.Notice)
+}
+
+func (p *PluginRPC) Name(args *PluginEmpty, reply *string) error {
+	*reply = p.notifier.Name()
+	return nil
+}
+
+func servePluginConn(notifier Notifier, conn io.ReadWriteCloser) {
+	server := rpc.NewServer()
+	server.RegisterName("Plugin", &PluginRPC{notifier})
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+type stdioConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (s *stdioConn) Close() error {
+	return nil
+}
+
+// Called from a plugin's main() to serve a Notifier to Superside over stdin
+// and stdout. Blocks until Superside goes away.
+func ServePlugin(notifier Notifier) error {
+	if os.Getenv(PLUGIN_COOKIE_KEY) != PLUGIN_COOKIE_VALUE {
+		return errors.New("This is a Superside plugin and can't be run directly")
+	}
+
+	servePluginConn(notifier, &stdioConn{os.Stdin, os.Stdout})
+	return nil
+}
+
+// A notifier that forwards to a plugin process
+type PluginNotifier struct {
+	Path        string
+	Args        []string
+	NameTimeout time.Duration
+	name        string
+	nameOnce    sync.Once
+	cmd         *exec.Cmd
+	client      *rpc.Client
+	lock        sync.Mutex
+}
+
+func init() {
+	RegisterFactory("plugin", func(settings Settings) (Notifier, error) {
+		if settings.String("path") == "" {
+			return nil, errors.New("path is required")
+		}
+
+		return NewPluginNotifier(settings.String("path"), settings.Strings("args")...), nil
+	})
+}
+
+func NewPluginNotifier(path string, args ...string) *PluginNotifier {
+	return &PluginNotifier{Path: path, Args: args, NameTimeout: PLUGIN_NAME_TIMEOUT}
+}
+
+type processConn struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (p *processConn) Close() error {
+	p.WriteCloser.Close()
+	return p.ReadCloser.Close()
+}
+
+// Start the plugin process if it isn't running. Call with the lock held.
+func (p *PluginNotifier) start() error {
+	if p.client != nil {
+		return nil
+	}
+
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Env = append(os.Environ(), PLUGIN_COOKIE_KEY+"="+PLUGIN_COOKIE_VALUE)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Started notifier plugin %s (pid %d)", p.Path, cmd.Process.Pid)
+
+	p.cmd = cmd
+	p.client = jsonrpc.NewClient(&processConn{stdout, stdin})
+
+	// Reap it when it exits so we know to start it again
+	go func(cmd *exec.Cmd, client *rpc.Client) {
+		err := cmd.Wait()
+		log.Warnf("Notifier plugin %s exited: %v", p.Path, err)
+
+		p.lock.Lock()
+		if p.client == client {
+			p.client = nil
+			p.cmd = nil
+		}
+		p.lock.Unlock()
+
+		client.Close()
+	}(cmd, p.client)
+
+	return nil
+}
+
+func (p *PluginNotifier) getClient() (*rpc.Client, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := p.start()
+	return p.client, err
+}
+
+func (p *PluginNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	client, err := p.getClient()
+	if err != nil {
+		return err
+	}
+
+	// Sending blocks too if the plugin stops reading, so don't wait on it
+	done := make(chan *rpc.Call, 1)
+	go client.Go("Plugin.Notify", &PluginNotifyArgs{notice}, &PluginEmpty{}, done)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case call := <-done:
+		return call.Error
+	}
+}
+
+// The name the plugin gives itself, or its path if it can't be asked. It's
+// only asked the once, since we're called for every delivery and a hung
+// plugin mustn't hold them all up.
+func (p *PluginNotifier) Name() string {
+	p.nameOnce.Do(func() {
+		p.name = p.askName()
+	})
+
+	return p.name
+}
+
+func (p *PluginNotifier) askName() string {
+	client, err := p.getClient()
+	if err != nil {
+		log.Warnf("Can't ask notifier plugin %s for its name: %s", p.Path, err.Error())
+		return p.Path
+	}
+
+	var name string
+	done := make(chan *rpc.Call, 1)
+	go client.Go("Plugin.Name", &PluginEmpty{}, &name, done)
+
+	select {
+	case call := <-done:
+		if call.Error != nil || name == "" {
+			return p.Path
+		}
+		return name
+	case <-time.After(p.NameTimeout):
+		log.Warnf("Notifier plugin %s didn't give its name in %s", p.Path, p.NameTimeout)
+		return p.Path
+	}
+}
+
+// Healthy if the plugin process is running, or can be started
+func (p *PluginNotifier) Healthy() bool {
+	_, err := p.getClient()
+	return err == nil
+}
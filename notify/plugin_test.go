@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PluginNotifier(t *testing.T) {
+	Convey("Notifier plugins", t, func() {
+		flaky := &flakyNotifier{failures: 1}
+
+		pluginSide, supersideSide := net.Pipe()
+		go servePluginConn(flaky, pluginSide)
+
+		plugin := NewPluginNotifier("/usr/local/bin/superside-pigeon")
+		plugin.client = jsonrpc.NewClient(supersideSide)
+		defer plugin.client.Close()
+
+		Convey("Asks the plugin for its name", func() {
+			So(plugin.Name(), ShouldEqual, "pigeon")
+		})
+
+		Convey("Forwards notifications and errors", func() {
+			notice := &datatypes.Notification{ID: "joffre"}
+
+			err := plugin.Notify(context.Background(), notice)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "carrier pigeon shot down")
+
+			So(plugin.Notify(context.Background(), notice), ShouldBeNil)
+			So(flaky.calls, ShouldEqual, 2)
+		})
+
+		Convey("Doesn't wait forever for a plugin's name", func() {
+			_, hung := net.Pipe()
+			plugin := NewPluginNotifier("/usr/local/bin/superside-hung")
+			plugin.NameTimeout = 10 * time.Millisecond
+			plugin.client = jsonrpc.NewClient(hung)
+			defer plugin.client.Close()
+
+			So(plugin.Name(), ShouldEqual, "/usr/local/bin/superside-hung")
+			So(plugin.Name(), ShouldEqual, "/usr/local/bin/superside-hung")
+		})
+
+		Convey("Won't serve when not started by Superside", func() {
+			So(ServePlugin(flaky), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DELIVERY_QUEUE_SIZE = 500 // Notifications that can wait on each notifier, per lane
+)
+
+var ErrQueueFull = errors.New("Too many notifications waiting on this notifier")
+
+// The notifications waiting on one notifier. Each notifier delivers from a
+// queue of its own, so a slow one holds up neither the dispatcher nor the
+// other notifiers. Like the dispatcher, it sends what's critical first.
+type deliveryQueue struct {
+	notifier     *Managed
+	critical     chan *datatypes.Notification
+	routine      chan *datatypes.Notification
+	lastCritical map[string]time.Time // Instance => newest critical event sent, only touched by run()
+}
+
+func newDeliveryQueue(notifier *Managed) *deliveryQueue {
+	return &deliveryQueue{
+		notifier:     notifier,
+		critical:     make(chan *datatypes.Notification, DELIVERY_QUEUE_SIZE),
+		routine:      make(chan *datatypes.Notification, DELIVERY_QUEUE_SIZE),
+		lastCritical: make(map[string]time.Time),
+	}
+}
+
+// Queue a notification without waiting, or return ErrQueueFull
+func (q *deliveryQueue) add(notice *datatypes.Notification) error {
+	lane := q.routine
+	if notice.IsCritical() {
+		lane = q.critical
+	}
+
+	select {
+	case lane <- notice:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Hand each notification to deliver until the queue is closed and empty
+func (q *deliveryQueue) run(deliver func(*Managed, *datatypes.Notification)) {
+	critical, routine := q.critical, q.routine
+
+	for critical != nil || routine != nil {
+		select {
+		case notice, ok := <-critical:
+			if !ok {
+				critical = nil
+				continue
+			}
+			q.send(deliver, notice)
+			continue
+		default:
+		}
+
+		select {
+		case notice, ok := <-critical:
+			if !ok {
+				critical = nil
+				continue
+			}
+			q.send(deliver, notice)
+
+		case notice, ok := <-routine:
+			if !ok {
+				routine = nil
+				continue
+			}
+			q.send(deliver, notice)
+		}
+	}
+}
+
+// Deliver a notification unless it's out of date. Critical notices jump
+// ahead of routine ones, so a recovery can turn up after a newer failure
+// for the same instance has gone out. Sending it would leave the channel
+// saying the instance is fine, so it's dropped.
+func (q *deliveryQueue) send(deliver func(*Managed, *datatypes.Notification), notice *datatypes.Notification) {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		deliver(q.notifier, notice)
+		return
+	}
+
+	key := instanceKey(notice)
+	latest, ok := q.lastCritical[key]
+
+	if notice.IsCritical() {
+		if !ok || notice.Event.Time.After(latest) {
+			q.lastCritical[key] = notice.Event.Time
+		}
+	} else if ok {
+		if notice.Event.Time.Before(latest) {
+			log.Infof("Not sending an older notice for %s via %s, a newer critical one went out", key, q.notifier.Name())
+			return
+		}
+		// Caught up, so there's nothing more to compare against
+		delete(q.lastCritical, key)
+	}
+
+	deliver(q.notifier, notice)
+}
+
+func (q *deliveryQueue) close() {
+	close(q.critical)
+	close(q.routine)
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+)
+
+const (
+	MAX_HELD_NOTICES = 500
+)
+
+// A daily window, e.g. "22:00-07:00", in which low severity notifications
+// are held back and then sent as a single digest once it's over. Failures,
+// flapping and silent clusters are never held, unless Hold says otherwise:
+// when set, it decides which notifications are held instead.
+type QuietHours struct {
+	Start    time.Duration // Since midnight
+	End      time.Duration
+	Location *time.Location
+	Hold     *match.Expression // Optional
+	held     []*datatypes.Notification
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("Bad time '%s', expected e.g. 22:00", clock)
+	}
+
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// Parse a spec like "22:00-07:00" in the named time zone. An empty zone
+// means UTC.
+func ParseQuietHours(spec string, zone string) (*QuietHours, error) {
+	pieces := strings.SplitN(spec, "-", 2)
+	if len(pieces) != 2 {
+		return nil, fmt.Errorf("Bad quiet hours '%s', expected e.g. 22:00-07:00", spec)
+	}
+
+	start, err := parseClock(pieces[0])
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseClock(pieces[1])
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuietHours{Start: start, End: end, Location: location}, nil
+}
+
+// Are we inside the window? It may wrap around midnight.
+func (q *QuietHours) Active(now time.Time) bool {
+	local := now.In(q.Location)
+	sinceMidnight := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+
+	if q.Start <= q.End {
+		return sinceMidnight >= q.Start && sinceMidnight < q.End
+	}
+
+	return sinceMidnight >= q.Start || sinceMidnight < q.End
+}
+
+func (q *QuietHours) lowSeverity(notice *datatypes.Notification) bool {
+	if q.Hold == nil {
+		openIncident := notice.Incident != nil && !notice.Incident.IsResolved()
+		return !notice.IsFailure() && notice.Type != datatypes.FLAPPING_NOTICE &&
+			notice.Type != datatypes.CLUSTER_SILENT_NOTICE && notice.Type != datatypes.ANOMALY_NOTICE &&
+			!openIncident
+	}
+
+	held, _ := q.Hold.Matches(notice)
+	return held
+}
+
+// Hold on to the notification if it can wait until morning. Returns true
+// if it was held.
+func (q *QuietHours) Defer(notice *datatypes.Notification, now time.Time) bool {
+	if q == nil || !q.Active(now) || !q.lowSeverity(notice) {
+		return false
+	}
+
+	q.held = append(q.held, notice)
+	if len(q.held) > MAX_HELD_NOTICES {
+		q.held = q.held[len(q.held)-MAX_HELD_NOTICES:]
+	}
+
+	return true
+}
+
+// Once quiet hours are over, return everything that was held as one digest
+func (q *QuietHours) Flush(now time.Time) *datatypes.Notification {
+	if q == nil || q.Active(now) || len(q.held) == 0 {
+		return nil
+	}
+
+	digest := &datatypes.Notification{
+		Type:       datatypes.DIGEST_NOTICE,
+		ReceivedAt: now,
+		Digest:     q.held,
+	}
+	q.held = nil
+
+	return digest
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_QuietHours(t *testing.T) {
+	Convey("Quiet hours", t, func() {
+		quiet, err := ParseQuietHours("22:00-07:00", "")
+		So(err, ShouldBeNil)
+
+		night := time.Date(1916, 2, 21, 23, 30, 0, 0, time.UTC)
+		morning := time.Date(1916, 2, 22, 8, 0, 0, 0, time.UTC)
+
+		recovery := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "db", Status: service.ALIVE},
+				PreviousStatus: service.UNHEALTHY,
+			},
+		}
+		failure := *recovery
+		failure.Event = &catalog.ChangeEvent{
+			Service:        service.Service{Name: "db", Status: service.UNHEALTHY},
+			PreviousStatus: service.ALIVE,
+		}
+
+		Convey("Wrap around midnight", func() {
+			So(quiet.Active(night), ShouldBeTrue)
+			So(quiet.Active(night.Add(7*time.Hour)), ShouldBeTrue)
+			So(quiet.Active(morning), ShouldBeFalse)
+		})
+
+		Convey("Work in other time zones", func() {
+			paris, err := ParseQuietHours("22:00-07:00", "Europe/Paris")
+			So(err, ShouldBeNil)
+			So(paris.Active(time.Date(2016, 2, 21, 21, 30, 0, 0, time.UTC)), ShouldBeTrue)
+		})
+
+		Convey("Hold low severity notifications and send them later as a digest", func() {
+			So(quiet.Defer(recovery, night), ShouldBeTrue)
+			So(quiet.Defer(&failure, night), ShouldBeFalse)
+			So(quiet.Defer(recovery, morning), ShouldBeFalse)
+
+			So(quiet.Flush(night), ShouldBeNil)
+
+			digest := quiet.Flush(morning)
+			So(digest.Type, ShouldEqual, datatypes.DIGEST_NOTICE)
+			So(digest.Digest, ShouldResemble, []*datatypes.Notification{recovery})
+			So(MessageFor(digest), ShouldEqual,
+				"1 notifications during quiet hours:\n[france] db () on  went from Unhealthy to Alive")
+
+			So(quiet.Flush(morning), ShouldBeNil)
+		})
+
+		Convey("Let a match expression decide what to hold", func() {
+			quiet.Hold, _ = match.Compile(`cluster == "france"`)
+			So(quiet.Defer(&failure, night), ShouldBeTrue)
+		})
+
+		Convey("Reject bad specs", func() {
+			_, err := ParseQuietHours("22:00", "")
+			So(err, ShouldNotBeNil)
+
+			_, err = ParseQuietHours("25:00-07:00", "")
+			So(err, ShouldNotBeNil)
+
+			_, err = ParseQuietHours("22:00-07:00", "Western/Front")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// Cut any URLs in an error message down to their scheme and host. Webhook
+// URLs for Slack, Discord, Teams and friends have the secret in the path,
+// and HTTP client errors quote the whole URL.
+func RedactURLs(message string) string {
+	return urlPattern.ReplaceAllStringFunc(message, func(match string) string {
+		// Punctuation at the end is more likely the sentence than the URL
+		trimmed := strings.TrimRight(match, ":;,.)")
+		trailer := match[len(trimmed):]
+
+		parsed, err := url.Parse(trimmed)
+		if err != nil || parsed.Host == "" {
+			return "[redacted]" + trailer
+		}
+
+		if parsed.User == nil && (parsed.Path == "" || parsed.Path == "/") &&
+			parsed.RawQuery == "" && parsed.Fragment == "" {
+			return match
+		}
+
+		return parsed.Scheme + "://" + parsed.Host + "/[redacted]" + trailer
+	})
+}
@@ -0,0 +1,326 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	RETRY_QUEUE_KEY        = "SupersideRetryQueue"
+	DEAD_LETTERS_KEY       = "SupersideDeadLetters"
+	RETRY_CHECK_INTERVAL   = 10 * time.Second
+	DEFAULT_RETRY_ATTEMPTS = 10
+	DEFAULT_RETRY_BACKOFF  = 30 * time.Second // Doubled after each failed retry
+	DEFAULT_MAX_BACKOFF    = 1 * time.Hour
+)
+
+// A delivery that failed and is waiting to be tried again, or that has run
+// out of attempts and is sitting in the dead-letter store
+type RetryEntry struct {
+	ID          string
+	Notifier    string // Name in the registry
+	Notice      *datatypes.Notification
+	Attempts    int // Retries so far, not counting the original delivery
+	NextAttempt time.Time
+	LastError   string
+	FailedAt    time.Time // When the original delivery failed
+}
+
+// Keeps hold of failed deliveries and retries them with backoff until they
+// work or MaxAttempts is used up, at which point they move to the
+// dead-letter store. Both are saved to the store whenever they change, so
+// retries carry on across restarts. Dead letters stay until someone
+// re-drives or discards them.
+//
+// A service event is dropped from the queue once a newer event for the same
+// instance has been queued or delivered via the same notifier, so that a
+// failure isn't re-sent after its recovery got through.
+type RetryQueue struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	store       store.Store
+	registry    *Registry
+	pending     map[string]*RetryEntry
+	dead        map[string]*RetryEntry
+	lock        sync.Mutex
+}
+
+func NewRetryQueue(store store.Store, registry *Registry) *RetryQueue {
+	return &RetryQueue{
+		MaxAttempts: DEFAULT_RETRY_ATTEMPTS,
+		Backoff:     DEFAULT_RETRY_BACKOFF,
+		MaxBackoff:  DEFAULT_MAX_BACKOFF,
+		store:       store,
+		registry:    registry,
+		pending:     make(map[string]*RetryEntry, 10),
+		dead:        make(map[string]*RetryEntry, 10),
+	}
+}
+
+// How long to wait before the next retry of an entry
+func (q *RetryQueue) backoffFor(attempts int) time.Duration {
+	backoff := q.Backoff
+	for i := 0; i < attempts && backoff < q.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > q.MaxBackoff {
+		return q.MaxBackoff
+	}
+	return backoff
+}
+
+// Queue a failed delivery for another go
+func (q *RetryQueue) Add(notifier string, notice *datatypes.Notification, err error, now time.Time) {
+	entry := &RetryEntry{
+		ID:          uuid.NewV4().String(),
+		Notifier:    notifier,
+		Notice:      notice,
+		NextAttempt: now.Add(q.backoffFor(0)),
+		LastError:   err.Error(),
+		FailedAt:    now,
+	}
+
+	q.lock.Lock()
+	q.supersede(notifier, notice)
+	q.pending[entry.ID] = entry
+	q.lock.Unlock()
+
+	q.persist()
+}
+
+// Drop the retries that a notice delivered via the notifier has made out of
+// date: those of older service events for the same instance
+func (q *RetryQueue) Supersede(notifier string, notice *datatypes.Notification) {
+	q.lock.Lock()
+	dropped := q.supersede(notifier, notice)
+	q.lock.Unlock()
+
+	if dropped {
+		q.persist()
+	}
+}
+
+// Must hold the lock. Returns true if it dropped anything.
+func (q *RetryQueue) supersede(notifier string, notice *datatypes.Notification) bool {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return false
+	}
+
+	key := instanceKey(notice)
+	dropped := false
+	for id, entry := range q.pending {
+		older := entry.Notice
+		if entry.Notifier != notifier || older.Type != datatypes.SERVICE_EVENT_NOTICE || older.Event == nil {
+			continue
+		}
+
+		if instanceKey(older) == key && older.Event.Time.Before(notice.Event.Time) {
+			log.Infof("Dropping delivery %s via %s, a newer event for %s has replaced it", id, notifier, key)
+			delete(q.pending, id)
+			dropped = true
+		}
+	}
+
+	return dropped
+}
+
+// The entries due for a retry. They stay on the queue, and in the store,
+// until the result is recorded.
+func (q *RetryQueue) due(now time.Time) []*RetryEntry {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var due []*RetryEntry
+	for _, entry := range q.pending {
+		if !now.Before(entry.NextAttempt) {
+			due = append(due, entry)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].FailedAt.Before(due[j].FailedAt)
+	})
+
+	return due
+}
+
+// Record the result of a retry. Failures wait a bit longer for the next
+// one, or move to the dead letters if that was their last go.
+func (q *RetryQueue) record(entry *RetryEntry, err error, now time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if err == nil {
+		delete(q.pending, entry.ID)
+		return
+	}
+
+	entry.Attempts += 1
+	entry.LastError = err.Error()
+
+	if entry.Attempts >= q.MaxAttempts {
+		log.Warnf("Giving up on delivery %s via %s after %d retries: %s",
+			entry.ID, entry.Notifier, entry.Attempts, entry.LastError)
+		delete(q.pending, entry.ID)
+		q.dead[entry.ID] = entry
+		return
+	}
+
+	entry.NextAttempt = now.Add(q.backoffFor(entry.Attempts))
+}
+
+// Try each entry that's due once
+func (q *RetryQueue) retry(now time.Time) {
+	due := q.due(now)
+	if len(due) == 0 {
+		return
+	}
+
+	for _, entry := range due {
+		notifier := q.registry.Get(entry.Notifier)
+		if notifier == nil {
+			// Probably removed from the config since
+			log.Warnf("Dropping delivery %s for unknown notifier '%s'", entry.ID, entry.Notifier)
+			q.record(entry, nil, now)
+			continue
+		}
+
+		q.record(entry, notifier.Deliver(context.Background(), entry.Notice), now)
+	}
+
+	q.persist()
+}
+
+func sortedEntries(entries map[string]*RetryEntry) []RetryEntry {
+	sorted := make([]RetryEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, *entry)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FailedAt.Before(sorted[j].FailedAt)
+	})
+
+	return sorted
+}
+
+// The deliveries waiting for a retry, oldest first
+func (q *RetryQueue) Pending() []RetryEntry {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return sortedEntries(q.pending)
+}
+
+// The deliveries we gave up on, oldest first
+func (q *RetryQueue) DeadLetters() []RetryEntry {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return sortedEntries(q.dead)
+}
+
+// Move dead letters back onto the queue with a fresh set of attempts, to be
+// retried straight away. An empty id re-drives all of them. Returns how many
+// were moved.
+func (q *RetryQueue) Redrive(id string, now time.Time) int {
+	q.lock.Lock()
+	moved := 0
+	for entryId, entry := range q.dead {
+		if id != "" && entryId != id {
+			continue
+		}
+		delete(q.dead, entryId)
+		entry.Attempts = 0
+		entry.NextAttempt = now
+		q.pending[entryId] = entry
+		moved += 1
+	}
+	q.lock.Unlock()
+
+	if moved > 0 {
+		q.persist()
+	}
+	return moved
+}
+
+// Throw away a dead letter for good
+func (q *RetryQueue) Discard(id string) bool {
+	q.lock.Lock()
+	_, ok := q.dead[id]
+	delete(q.dead, id)
+	q.lock.Unlock()
+
+	if ok {
+		q.persist()
+	}
+	return ok
+}
+
+func (q *RetryQueue) persist() {
+	q.save(RETRY_QUEUE_KEY, q.Pending())
+	q.save(DEAD_LETTERS_KEY, q.DeadLetters())
+}
+
+func (q *RetryQueue) save(key string, entries []RetryEntry) {
+	data, err := json.Marshal(entries)
+	if err == nil {
+		err = q.store.StoreBlob(key, data)
+	}
+
+	if err != nil {
+		log.Errorf("Unable to save %s: %s", key, err.Error())
+	}
+}
+
+// Restore the queue and the dead letters saved in the store
+func (q *RetryQueue) Load() error {
+	err := q.load(RETRY_QUEUE_KEY, q.pending)
+	if err != nil {
+		return err
+	}
+
+	return q.load(DEAD_LETTERS_KEY, q.dead)
+}
+
+func (q *RetryQueue) load(key string, into map[string]*RetryEntry) error {
+	data, err := q.store.GetBlob(key)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	var saved []*RetryEntry
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, entry := range saved {
+		into[entry.ID] = entry
+	}
+
+	return nil
+}
+
+// Retry whatever is due every RETRY_CHECK_INTERVAL. Never returns.
+func (q *RetryQueue) Run() {
+	ticker := time.NewTicker(RETRY_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		q.retry(time.Now().UTC())
+	}
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_RetryQueue(t *testing.T) {
+	Convey("The retry queue", t, func() {
+		dir, _ := ioutil.TempDir("", "retries")
+		defer os.RemoveAll(dir)
+		dataStore := store.NewFileStore(dir)
+
+		flaky := &flakyNotifier{failures: 100}
+		registry := &Registry{}
+		managed := registry.Register(flaky)
+		managed.Attempts = 1
+		managed.BreakAfter = 0
+
+		queue := NewRetryQueue(dataStore, registry)
+		queue.MaxAttempts = 3
+		queue.Backoff = time.Minute
+
+		now := time.Date(1916, 7, 1, 7, 30, 0, 0, time.UTC)
+		notice := &datatypes.Notification{ID: "somme", ClusterName: "france"}
+		queue.Add("pigeon", notice, errors.New("carrier pigeon shot down"), now)
+
+		Convey("Waits for the backoff before retrying", func() {
+			queue.retry(now.Add(30 * time.Second))
+			So(flaky.calls, ShouldEqual, 0)
+
+			queue.retry(now.Add(time.Minute))
+			So(flaky.calls, ShouldEqual, 1)
+			So(queue.Pending()[0].NextAttempt, ShouldResemble, now.Add(3*time.Minute))
+		})
+
+		Convey("Drops deliveries that work", func() {
+			flaky.failures = 0
+			queue.retry(now.Add(time.Minute))
+
+			So(queue.Pending(), ShouldBeEmpty)
+			So(queue.DeadLetters(), ShouldBeEmpty)
+		})
+
+		Convey("Moves deliveries out of retries to the dead letters", func() {
+			queue.retry(now.Add(time.Hour))
+			queue.retry(now.Add(2 * time.Hour))
+			queue.retry(now.Add(3 * time.Hour))
+
+			So(flaky.calls, ShouldEqual, 3)
+			So(queue.Pending(), ShouldBeEmpty)
+			So(len(queue.DeadLetters()), ShouldEqual, 1)
+			So(queue.DeadLetters()[0].Notice.ID, ShouldEqual, "somme")
+
+			Convey("which can be re-driven", func() {
+				So(queue.Redrive("", now), ShouldEqual, 1)
+				So(queue.DeadLetters(), ShouldBeEmpty)
+
+				flaky.failures = 0
+				queue.retry(now)
+				So(flaky.calls, ShouldEqual, 4)
+				So(queue.Pending(), ShouldBeEmpty)
+			})
+
+			Convey("or discarded", func() {
+				So(queue.Discard(queue.DeadLetters()[0].ID), ShouldBeTrue)
+				So(queue.DeadLetters(), ShouldBeEmpty)
+				So(queue.Discard("nope"), ShouldBeFalse)
+			})
+		})
+
+		Convey("Drops service events once a newer one for the instance gets through", func() {
+			change := func(id string, status int, at time.Time) *datatypes.Notification {
+				return &datatypes.Notification{
+					ID:          id,
+					Type:        datatypes.SERVICE_EVENT_NOTICE,
+					ClusterName: "france",
+					Event: &catalog.ChangeEvent{
+						Service: service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: status},
+						Time:    at,
+					},
+				}
+			}
+
+			failure := change("failure", service.UNHEALTHY, now)
+			queue.Add("pigeon", failure, errors.New("carrier pigeon shot down"), now)
+			So(len(queue.Pending()), ShouldEqual, 2)
+
+			// A different notifier's delivery doesn't count
+			queue.Supersede("semaphore", change("recovery", service.ALIVE, now.Add(time.Minute)))
+			So(len(queue.Pending()), ShouldEqual, 2)
+
+			queue.Supersede("pigeon", change("recovery", service.ALIVE, now.Add(time.Minute)))
+			So(len(queue.Pending()), ShouldEqual, 1)
+			So(queue.Pending()[0].Notice.ID, ShouldEqual, "somme")
+
+			Convey("or is queued itself", func() {
+				queue.Add("pigeon", failure, errors.New("carrier pigeon shot down"), now)
+				queue.Add("pigeon", change("recovery", service.ALIVE, now.Add(time.Minute)), errors.New("lost"), now)
+				So(len(queue.Pending()), ShouldEqual, 2)
+				for _, entry := range queue.Pending() {
+					So(entry.Notice.ID, ShouldNotEqual, "failure")
+				}
+			})
+		})
+
+		Convey("Survives a restart", func() {
+			restored := NewRetryQueue(dataStore, registry)
+			So(restored.Load(), ShouldBeNil)
+
+			So(len(restored.Pending()), ShouldEqual, 1)
+			So(restored.Pending()[0].Notice.ID, ShouldEqual, "somme")
+			So(restored.Pending()[0].LastError, ShouldEqual, "carrier pigeon shot down")
+		})
+
+		Convey("Caps the backoff", func() {
+			queue.MaxBackoff = 5 * time.Minute
+			So(queue.backoffFor(1), ShouldEqual, 2*time.Minute)
+			So(queue.backoffFor(10), ShouldEqual, 5*time.Minute)
+		})
+	})
+}
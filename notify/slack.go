@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	HTTP_TIMEOUT = 10 * time.Second
+)
+
+// Posts alerts to a Slack incoming webhook. The message text comes from
+// MessageFor(), followed by the service's links, unless there's a Template.
+type SlackNotifier struct {
+	alwaysHealthy
+	WebhookUrl string
+	Channel    string
+	Username   string
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func init() {
+	RegisterFactory("slack", func(settings Settings) (Notifier, error) {
+		if settings.String("webhook_url") == "" {
+			return nil, errors.New("webhook_url is required")
+		}
+
+		username := settings.String("username")
+		if username == "" {
+			username = "superside"
+		}
+
+		slack := NewSlackNotifier(
+			settings.String("webhook_url"), settings.String("channel"), username,
+		)
+
+		var err error
+		slack.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return slack, nil
+	})
+}
+
+func NewSlackNotifier(webhookUrl string, channel string, username string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookUrl: webhookUrl,
+		Channel:    channel,
+		Username:   username,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (s *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	text, err := renderText(notice, s.Template)
+	if err != nil {
+		return err
+	}
+	if s.Template == nil {
+		text += slackLinks(notice.Links)
+	}
+
+	message := slackMessage{
+		Text:     text,
+		Channel:  s.Channel,
+		Username: s.Username,
+	}
+
+	return postJSON(ctx, s.client, s.WebhookUrl, message, "Slack webhook", http.StatusOK)
+}
+
+// e.g. "\n<https://wiki/db|runbook> | <https://grafana/db|dashboard>", or
+// nothing when there are no links
+func slackLinks(links map[string]string) string {
+	if len(links) == 0 {
+		return ""
+	}
+
+	formatted := make([]string, 0, len(links))
+	for _, name := range sortedLabelKeys(links) {
+		formatted = append(formatted, "<"+links[name]+"|"+name+">")
+	}
+
+	return "\n" + strings.Join(formatted, " | ")
+}
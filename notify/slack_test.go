@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SlackNotifier(t *testing.T) {
+	Convey("Slack notifier", t, func() {
+		var posted slackMessage
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&posted)
+		}))
+		defer server.Close()
+
+		slack := NewSlackNotifier(server.URL, "#ops", "superside")
+		notice := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "db", Hostname: "verdun", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+			},
+		}
+
+		Convey("Posts the message", func() {
+			So(slack.Notify(context.Background(), notice), ShouldBeNil)
+			So(posted.Text, ShouldEqual, MessageFor(notice))
+			So(posted.Channel, ShouldEqual, "#ops")
+		})
+
+		Convey("Links to the service's runbook and dashboards", func() {
+			notice.Links = map[string]string{"runbook": "https://wiki/db", "dashboard": "https://grafana/db"}
+			So(slack.Notify(context.Background(), notice), ShouldBeNil)
+			So(posted.Text, ShouldEqual,
+				MessageFor(notice)+"\n<https://grafana/db|dashboard> | <https://wiki/db|runbook>",
+			)
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+	"github.com/nitro/superside/store"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	SUBSCRIPTIONS_KEY        = "SupersideSubscriptions"
+	SUBSCRIPTION_QUEUE_DEPTH = 100
+)
+
+// Webhook subscriptions added at runtime through the API. Each one gets its
+// own queue and delivery goroutine, so a slow subscriber only holds itself
+// up. Subscriptions are saved to the store whenever they change.
+type Subscriptions struct {
+	store       store.Store
+	subscribers map[string]*subscriber
+	lock        sync.RWMutex
+}
+
+type subscriber struct {
+	subscription datatypes.Subscription
+	match        *match.Expression // Optional
+	notifier     *Managed
+	queue        chan *datatypes.Notification
+}
+
+func NewSubscriptions(store store.Store) *Subscriptions {
+	return &Subscriptions{
+		store:       store,
+		subscribers: make(map[string]*subscriber, 10),
+	}
+}
+
+func newSubscriber(subscription datatypes.Subscription) (*subscriber, error) {
+	err := subscription.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &subscriber{
+		subscription: subscription,
+		notifier:     NewManaged(&namedNotifier{NewWebhookNotifier(subscription.Url), "subscription-" + subscription.ID}),
+		queue:        make(chan *datatypes.Notification, SUBSCRIPTION_QUEUE_DEPTH),
+	}
+
+	if subscription.Match != "" {
+		sub.match, err = match.Compile(subscription.Match)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Match: %s", err.Error())
+		}
+	}
+
+	go sub.deliver()
+
+	return sub, nil
+}
+
+func (s *subscriber) deliver() {
+	for notice := range s.queue {
+		err := s.notifier.Deliver(context.Background(), notice)
+		if err != nil {
+			log.Warnf("Unable to deliver to subscription %s: %s", s.subscription.ID, err.Error())
+		}
+	}
+}
+
+func (s *subscriber) wants(notice *datatypes.Notification) bool {
+	if s.match == nil {
+		return true
+	}
+
+	matched, _ := s.match.Matches(notice)
+	return matched
+}
+
+// Start delivering to a new subscription
+func (s *Subscriptions) Add(subscription datatypes.Subscription) (*datatypes.Subscription, error) {
+	subscription.ID = uuid.NewV4().String()
+	subscription.CreatedAt = time.Now().UTC()
+
+	sub, err := newSubscriber(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	s.subscribers[subscription.ID] = sub
+	s.lock.Unlock()
+
+	s.persist()
+	return &subscription, nil
+}
+
+func (s *Subscriptions) Remove(id string) bool {
+	s.lock.Lock()
+	sub, ok := s.subscribers[id]
+	if ok {
+		delete(s.subscribers, id)
+		close(sub.queue)
+	}
+	s.lock.Unlock()
+
+	if ok {
+		s.persist()
+	}
+	return ok
+}
+
+// Every subscription, oldest first
+func (s *Subscriptions) All() []datatypes.Subscription {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	all := make([]datatypes.Subscription, 0, len(s.subscribers))
+	for _, sub := range s.subscribers {
+		all = append(all, sub.subscription)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.Before(all[j].CreatedAt)
+	})
+
+	return all
+}
+
+func (s *Subscriptions) persist() {
+	data, err := json.Marshal(s.All())
+	if err == nil {
+		err = s.store.StoreBlob(SUBSCRIPTIONS_KEY, data)
+	}
+
+	if err != nil {
+		log.Errorf("Unable to save subscriptions: %s", err.Error())
+	}
+}
+
+// Restore the subscriptions saved in the store
+func (s *Subscriptions) Load() error {
+	data, err := s.store.GetBlob(SUBSCRIPTIONS_KEY)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	var saved []datatypes.Subscription
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, subscription := range saved {
+		sub, err := newSubscriber(subscription)
+		if err != nil {
+			log.Warnf("Skipping saved subscription %s: %s", subscription.ID, err.Error())
+			continue
+		}
+		s.subscribers[subscription.ID] = sub
+	}
+
+	return nil
+}
+
+// Queue a notification for every subscription that wants it. Drops it for
+// subscribers that are too far behind.
+func (s *Subscriptions) Publish(notice *datatypes.Notification) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, sub := range s.subscribers {
+		if !sub.wants(notice) {
+			continue
+		}
+
+		select {
+		case sub.queue <- notice:
+		default:
+			log.Warnf("Subscription %s is too far behind, dropping notification", sub.subscription.ID)
+		}
+	}
+}
+
+// Loop over the notifications until the channel is closed
+func (s *Subscriptions) Run(notices chan *datatypes.Notification) {
+	for notice := range notices {
+		s.Publish(notice)
+	}
+}
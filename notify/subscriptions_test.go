@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Subscriptions(t *testing.T) {
+	Convey("Webhook subscriptions", t, func() {
+		received := make(chan string, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			received <- string(body)
+		}))
+		defer server.Close()
+
+		dir, _ := ioutil.TempDir("", "subscriptions")
+		defer os.RemoveAll(dir)
+		dataStore := store.NewFileStore(dir)
+
+		subscriptions := NewSubscriptions(dataStore)
+
+		Convey("Deliver the notifications the subscriber wants", func() {
+			_, err := subscriptions.Add(datatypes.Subscription{Url: server.URL, Match: `cluster == "france"`})
+			So(err, ShouldBeNil)
+
+			subscriptions.Publish(&datatypes.Notification{ID: "foch", ClusterName: "belgium"})
+			subscriptions.Publish(&datatypes.Notification{ID: "joffre", ClusterName: "france"})
+
+			select {
+			case body := <-received:
+				So(body, ShouldContainSubstring, `"ID":"joffre"`)
+			case <-time.After(time.Second):
+				So("timed out", ShouldBeEmpty)
+			}
+		})
+
+		Convey("Reject bad subscriptions", func() {
+			_, err := subscriptions.Add(datatypes.Subscription{Url: "ftp://example.com"})
+			So(err, ShouldNotBeNil)
+
+			_, err = subscriptions.Add(datatypes.Subscription{Url: server.URL, Match: "cluster =="})
+			So(err, ShouldNotBeNil)
+
+			So(subscriptions.All(), ShouldBeEmpty)
+		})
+
+		Convey("Survive a restart", func() {
+			added, _ := subscriptions.Add(datatypes.Subscription{Url: server.URL, Comment: "western front"})
+
+			restored := NewSubscriptions(dataStore)
+			So(restored.Load(), ShouldBeNil)
+			So(len(restored.All()), ShouldEqual, 1)
+			So(restored.All()[0].ID, ShouldEqual, added.ID)
+			So(restored.All()[0].Comment, ShouldEqual, "western front")
+		})
+
+		Convey("Can be removed", func() {
+			added, _ := subscriptions.Add(datatypes.Subscription{Url: server.URL})
+
+			So(subscriptions.Remove(added.ID), ShouldBeTrue)
+			So(subscriptions.Remove(added.ID), ShouldBeFalse)
+			So(subscriptions.All(), ShouldBeEmpty)
+		})
+	})
+}
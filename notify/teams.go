@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	ADAPTIVE_CARD_CONTENT_TYPE = "application/vnd.microsoft.card.adaptive"
+	ADAPTIVE_CARD_SCHEMA       = "http://adaptivecards.io/schemas/adaptive-card.json"
+	ADAPTIVE_CARD_VERSION      = "1.4"
+)
+
+// Adaptive Card text colours for each severity
+var teamsColors = map[string]string{
+	SEVERITY_CRITICAL: "Attention",
+	SEVERITY_WARNING:  "Warning",
+	SEVERITY_OK:       "Good",
+	SEVERITY_INFO:     "Default",
+}
+
+// Posts alerts to a Microsoft Teams incoming webhook as Adaptive Cards, with
+// the message as a coloured heading over a table of the details. The heading
+// comes from MessageFor() unless there's a Template.
+type TeamsNotifier struct {
+	alwaysHealthy
+	WebhookUrl string
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+}
+
+func init() {
+	RegisterFactory("teams", func(settings Settings) (Notifier, error) {
+		if settings.String("webhook_url") == "" {
+			return nil, errors.New("webhook_url is required")
+		}
+
+		teams := NewTeamsNotifier(settings.String("webhook_url"))
+
+		var err error
+		teams.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return teams, nil
+	})
+}
+
+func NewTeamsNotifier(webhookUrl string) *TeamsNotifier {
+	return &TeamsNotifier{
+		WebhookUrl: webhookUrl,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (t *TeamsNotifier) Name() string {
+	return "teams"
+}
+
+func (t *TeamsNotifier) card(notice *datatypes.Notification) (*teamsMessage, error) {
+	text, err := renderText(notice, t.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	facts := make([]map[string]string, 0, 6)
+	for _, field := range fieldsFor(notice) {
+		facts = append(facts, map[string]string{"title": field.Name, "value": field.Value})
+	}
+
+	return &teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: ADAPTIVE_CARD_CONTENT_TYPE,
+			Content: adaptiveCard{
+				Schema:  ADAPTIVE_CARD_SCHEMA,
+				Type:    "AdaptiveCard",
+				Version: ADAPTIVE_CARD_VERSION,
+				Body: []map[string]interface{}{
+					{
+						"type":   "TextBlock",
+						"text":   text,
+						"weight": "Bolder",
+						"color":  teamsColors[SeverityOf(notice)],
+						"wrap":   true,
+					},
+					{"type": "FactSet", "facts": facts},
+				},
+			},
+		}},
+	}, nil
+}
+
+func (t *TeamsNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	card, err := t.card(notice)
+	if err != nil {
+		return err
+	}
+
+	// Power Automate workflows answer 202, the old connectors 200
+	return postJSON(ctx, t.client, t.WebhookUrl, card, "Teams webhook", http.StatusOK, http.StatusAccepted)
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TeamsNotifier(t *testing.T) {
+	Convey("Teams notifier", t, func() {
+		var received map[string]interface{}
+		endpoint := newFakeEndpoint(&received, http.StatusOK)
+		defer endpoint.Close()
+
+		teams := NewTeamsNotifier(endpoint.URL)
+		notice := failureNotice()
+
+		card := func() map[string]interface{} {
+			attachment := received["attachments"].([]interface{})[0].(map[string]interface{})
+			So(attachment["contentType"], ShouldEqual, ADAPTIVE_CARD_CONTENT_TYPE)
+			return attachment["content"].(map[string]interface{})
+		}
+
+		Convey("Posts an Adaptive Card", func() {
+			So(teams.Notify(context.Background(), notice), ShouldBeNil)
+
+			body := card()["body"].([]interface{})
+			heading := body[0].(map[string]interface{})
+			So(heading["text"], ShouldEqual, MessageFor(notice))
+			So(heading["color"], ShouldEqual, "Attention")
+
+			facts := body[1].(map[string]interface{})["facts"].([]interface{})
+			So(facts[0], ShouldResemble, map[string]interface{}{"title": "Cluster", "value": "france"})
+			So(facts[len(facts)-1], ShouldResemble,
+				map[string]interface{}{"title": "Transition", "value": "Alive->Unhealthy"})
+		})
+
+		Convey("Uses the template for the heading", func() {
+			teams.Template, _ = ParseTemplate("test", "{{ .ClusterName }} is in trouble")
+
+			So(teams.Notify(context.Background(), notice), ShouldBeNil)
+			heading := card()["body"].([]interface{})[0].(map[string]interface{})
+			So(heading["text"], ShouldEqual, "france is in trouble")
+		})
+
+		Convey("Accepts workflow webhooks", func() {
+			endpoint.Status = http.StatusAccepted
+			So(teams.Notify(context.Background(), notice), ShouldBeNil)
+		})
+
+		Convey("Reports errors from Teams", func() {
+			endpoint.Status = http.StatusBadRequest
+			So(teams.Notify(context.Background(), notice), ShouldNotBeNil)
+		})
+	})
+}
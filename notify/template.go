@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Helpers available to notification templates, on top of the fields of
+// the Notification itself, e.g.
+//
+//	{"text": {{ message . | json }}, "status": "{{ status .Event.Service.Status }}"}
+//
+// link is safe where the service might have no such link, which .Links
+// isn't with missingkey=error:
+//
+//	{{ with link . "runbook" }}Runbook: {{ . }}{{ end }}
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+	"status":  datatypes.StatusString,
+	"message": MessageFor,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"join":    strings.Join,
+	"link": func(notice *datatypes.Notification, name string) string {
+		return notice.Links[name]
+	},
+}
+
+// A text/template rendered against a Notification
+type Template struct {
+	tmpl *template.Template
+}
+
+func ParseTemplate(name string, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{tmpl}, nil
+}
+
+// Load a template from either the "template" or the "template_file"
+// setting. Returns nil if there's neither.
+func TemplateFromSettings(settings Settings) (*Template, error) {
+	text := settings.String("template")
+
+	if filename := settings.String("template_file"); filename != "" {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		text = string(contents)
+	}
+
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := ParseTemplate(settings.String("type"), text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid template: %s", err.Error())
+	}
+
+	return tmpl, nil
+}
+
+func (t *Template) Render(notice *datatypes.Notification) (string, error) {
+	var buf bytes.Buffer
+
+	err := t.tmpl.Execute(&buf, notice)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Template(t *testing.T) {
+	Convey("Notification templates", t, func() {
+		notice := &datatypes.Notification{
+			ID:          "joffre",
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{
+					Name: "db", Image: "db:1", Hostname: "verdun", Status: service.UNHEALTHY,
+				},
+				PreviousStatus: service.ALIVE,
+			},
+		}
+
+		Convey("Render the notification's fields and helpers", func() {
+			tmpl, err := ParseTemplate("test",
+				`{"id": {{ json .ID }}, "text": {{ message . | json }}, `+
+					`"went": "{{ .Transition }}", "was": "{{ status .Event.PreviousStatus | upper }}"}`,
+			)
+			So(err, ShouldBeNil)
+
+			body, err := tmpl.Render(notice)
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual,
+				`{"id": "joffre", "text": "[france] db (db:1) on verdun went from Alive to Unhealthy", `+
+					`"went": "Alive->Unhealthy", "was": "ALIVE"}`,
+			)
+		})
+
+		Convey("Look up links, whether or not the service has them", func() {
+			tmpl, err := ParseTemplate("test", `{{ with link . "runbook" }}Runbook: {{ . }}{{ else }}None{{ end }}`)
+			So(err, ShouldBeNil)
+
+			body, err := tmpl.Render(notice)
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "None")
+
+			notice.Links = map[string]string{"runbook": "https://wiki/db"}
+			body, err = tmpl.Render(notice)
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "Runbook: https://wiki/db")
+		})
+
+		Convey("Reject templates that don't parse", func() {
+			_, err := ParseTemplate("test", "{{ .ID ")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Come from the settings, if there is one", func() {
+			tmpl, err := TemplateFromSettings(Settings{"template": "{{ .ClusterName }}"})
+			So(err, ShouldBeNil)
+
+			body, _ := tmpl.Render(notice)
+			So(body, ShouldEqual, "france")
+
+			tmpl, err = TemplateFromSettings(Settings{})
+			So(err, ShouldBeNil)
+			So(tmpl, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEDUP_WINDOW = 24 * time.Hour // How long Dedup remembers what it last sent
+)
+
+// Limits how often a route talks about the same service. With an Interval,
+// at most one notification per service per cluster gets through in that
+// time, except that recoveries always do, so nobody is left thinking it's
+// still broken. With Dedup, a notification identical to the last one sent
+// for the service within DEDUP_WINDOW is dropped.
+type Throttle struct {
+	Interval time.Duration
+	Dedup    bool
+	lastSent map[string]time.Time
+	lastSig  map[string]sentSignature
+}
+
+type sentSignature struct {
+	signature string
+	at        time.Time
+}
+
+func NewThrottle(interval time.Duration, dedup bool) *Throttle {
+	return &Throttle{
+		Interval: interval,
+		Dedup:    dedup,
+		lastSent: make(map[string]time.Time, 50),
+		lastSig:  make(map[string]sentSignature, 50),
+	}
+}
+
+func serviceKey(notice *datatypes.Notification) string {
+	if notice.Incident != nil {
+		return notice.ClusterName + "/" + notice.Incident.ID
+	}
+	if notice.Flap != nil {
+		return notice.ClusterName + "/" + notice.Flap.Service
+	}
+	if notice.SkewedHost != nil {
+		return notice.ClusterName + "/" + notice.SkewedHost.Hostname
+	}
+	if notice.Event != nil {
+		return notice.ClusterName + "/" + notice.Event.Service.Name
+	}
+	return notice.ClusterName
+}
+
+// What makes two notifications the same as far as a human cares
+func signature(notice *datatypes.Notification) string {
+	if notice.Incident != nil {
+		return notice.Type + "/" + notice.Incident.Status
+	}
+	if notice.Event == nil {
+		return notice.Type
+	}
+	return notice.Type + "/" + instanceKey(notice) + "/" + notice.Transition()
+}
+
+// Should this notification go out? Records it as sent if so. Safe to call
+// on a nil Throttle, which allows everything.
+func (t *Throttle) Allow(notice *datatypes.Notification, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	key := serviceKey(notice)
+	sig := signature(notice)
+	t.prune(now)
+
+	if last, ok := t.lastSig[key]; t.Dedup && ok && last.signature == sig {
+		return false
+	}
+
+	if t.Interval > 0 {
+		last, ok := t.lastSent[key]
+		if ok && now.Sub(last) < t.Interval && !isRecovery(notice) {
+			return false
+		}
+		t.lastSent[key] = now
+	}
+
+	if t.Dedup {
+		t.lastSig[key] = sentSignature{signature: sig, at: now}
+	}
+	return true
+}
+
+// Forget services we haven't sent anything about for a whole interval, and
+// what we sent longer ago than the dedup window
+func (t *Throttle) prune(now time.Time) {
+	for key, last := range t.lastSent {
+		if now.Sub(last) >= t.Interval {
+			delete(t.lastSent, key)
+		}
+	}
+
+	for key, last := range t.lastSig {
+		if now.Sub(last.at) >= DEDUP_WINDOW {
+			delete(t.lastSig, key)
+		}
+	}
+}
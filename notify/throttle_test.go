@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Throttle(t *testing.T) {
+	Convey("Throttling notifications", t, func() {
+		baseTime := time.Now().UTC()
+
+		noticeFor := func(hostname string, status int, previous int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{ID: "deadbeef0123", Name: "db", Hostname: hostname, Status: status},
+					PreviousStatus: previous,
+				},
+			}
+		}
+
+		failure := noticeFor("verdun", service.UNHEALTHY, service.ALIVE)
+		recovery := noticeFor("verdun", service.ALIVE, service.UNHEALTHY)
+
+		Convey("Allows one notification per service per interval", func() {
+			throttle := NewThrottle(5*time.Minute, false)
+
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+			So(throttle.Allow(noticeFor("ypres", service.UNHEALTHY, service.ALIVE), baseTime.Add(time.Minute)), ShouldBeFalse)
+			So(throttle.Allow(noticeFor("verdun", service.UNHEALTHY, service.ALIVE), baseTime.Add(6*time.Minute)), ShouldBeTrue)
+		})
+
+		Convey("Always lets recoveries through", func() {
+			throttle := NewThrottle(5*time.Minute, false)
+
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+			So(throttle.Allow(recovery, baseTime.Add(time.Minute)), ShouldBeTrue)
+			So(throttle.Allow(failure, baseTime.Add(2*time.Minute)), ShouldBeFalse)
+		})
+
+		Convey("Forgets what it sent once it no longer matters", func() {
+			throttle := NewThrottle(5*time.Minute, true)
+
+			other := noticeFor("verdun", service.UNHEALTHY, service.ALIVE)
+			other.Event.Service.Name = "queue"
+
+			throttle.Allow(failure, baseTime)
+			throttle.Allow(other, baseTime.Add(10*time.Minute))
+			So(throttle.lastSent, ShouldNotContainKey, "france/db")
+			So(throttle.lastSig, ShouldContainKey, "france/db")
+
+			So(throttle.Allow(other, baseTime.Add(DEDUP_WINDOW)), ShouldBeFalse)
+			So(throttle.lastSig, ShouldNotContainKey, "france/db")
+			So(throttle.Allow(failure, baseTime.Add(DEDUP_WINDOW)), ShouldBeTrue)
+		})
+
+		Convey("Throttles each service separately", func() {
+			throttle := NewThrottle(5*time.Minute, false)
+			other := noticeFor("verdun", service.UNHEALTHY, service.ALIVE)
+			other.Event.Service.Name = "queue"
+
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+			So(throttle.Allow(other, baseTime), ShouldBeTrue)
+		})
+
+		Convey("Drops identical consecutive notifications", func() {
+			throttle := NewThrottle(0, true)
+
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+			So(throttle.Allow(failure, baseTime.Add(time.Hour)), ShouldBeFalse)
+			So(throttle.Allow(recovery, baseTime.Add(time.Hour)), ShouldBeTrue)
+			So(throttle.Allow(failure, baseTime.Add(time.Hour)), ShouldBeTrue)
+			So(throttle.Allow(noticeFor("ypres", service.UNHEALTHY, service.ALIVE), baseTime), ShouldBeTrue)
+		})
+
+		Convey("Allows everything when there's no throttle", func() {
+			var throttle *Throttle
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+			So(throttle.Allow(failure, baseTime), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	TWILIO_API_URL       = "https://api.twilio.com"
+	TWILIO_BODY_LIMIT    = 1600
+	DEFAULT_SMS_SEVERITY = datatypes.SEVERITY_CRITICAL
+)
+
+// Texts alerts to on-call phones through Twilio, so they still get through
+// when chat is down. Only notifications classified at least as bad as the
+// Severity are sent, which is just critical ones unless configured
+// otherwise, so people get woken up for a cluster going dark but not for
+// every blip.
+type TwilioNotifier struct {
+	alwaysHealthy
+	ApiUrl     string
+	AccountSid string
+	AuthToken  string
+	From       string
+	To         []string
+	Severity   string
+	Template   *Template // Optional
+	client     *http.Client
+}
+
+func init() {
+	RegisterFactory("twilio", func(settings Settings) (Notifier, error) {
+		if settings.String("account_sid") == "" || settings.String("auth_token") == "" {
+			return nil, errors.New("account_sid and auth_token are required")
+		}
+
+		if settings.String("from") == "" || len(settings.Strings("to")) == 0 {
+			return nil, errors.New("from and to are required")
+		}
+
+		twilio := NewTwilioNotifier(
+			settings.String("account_sid"), settings.String("auth_token"),
+			settings.String("from"), settings.Strings("to"),
+		)
+
+		var err error
+		twilio.Severity, err = smsSeverity(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		twilio.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return twilio, nil
+	})
+}
+
+func NewTwilioNotifier(accountSid string, authToken string, from string, to []string) *TwilioNotifier {
+	return &TwilioNotifier{
+		ApiUrl:     TWILIO_API_URL,
+		AccountSid: accountSid,
+		AuthToken:  authToken,
+		From:       from,
+		To:         to,
+		Severity:   DEFAULT_SMS_SEVERITY,
+		client:     &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (t *TwilioNotifier) Name() string {
+	return "twilio"
+}
+
+// The least severe notification to text about. Older configs list the
+// wanted severities instead, which means the least severe of them.
+func smsSeverity(settings Settings) (string, error) {
+	names := settings.Strings("severities")
+	if settings.String("severity") != "" {
+		names = []string{settings.String("severity")}
+	}
+
+	if len(names) == 0 {
+		return DEFAULT_SMS_SEVERITY, nil
+	}
+
+	least := ""
+	for _, name := range names {
+		severity, err := datatypes.ParseSeverity(name)
+		if err != nil {
+			return "", err
+		}
+
+		if least == "" || datatypes.WorseSeverity(least, severity) == least {
+			least = severity
+		}
+	}
+
+	return least, nil
+}
+
+func (t *TwilioNotifier) wants(notice *datatypes.Notification) bool {
+	return notice.SeverityAtLeast(t.Severity)
+}
+
+// Send one message to each number. Any failure fails the lot, and the retry
+// goes to everyone again, because a duplicate text beats a missing one.
+func (t *TwilioNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	if !t.wants(notice) {
+		return nil
+	}
+
+	text, err := renderText(notice, t.Template)
+	if err != nil {
+		return err
+	}
+
+	if len(text) > TWILIO_BODY_LIMIT {
+		text = text[:TWILIO_BODY_LIMIT-3] + "..."
+	}
+
+	for _, to := range t.To {
+		err := t.send(ctx, to, text)
+		if err != nil {
+			return fmt.Errorf("Unable to text %s: %s", to, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (t *TwilioNotifier) send(ctx context.Context, to string, text string) error {
+	form := url.Values{}
+	form.Set("From", t.From)
+	form.Set("To", to)
+	form.Set("Body", text)
+
+	messagesUrl := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json",
+		strings.TrimRight(t.ApiUrl, "/"), url.PathEscape(t.AccountSid),
+	)
+
+	req, err := http.NewRequest("POST", messagesUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSid, t.AuthToken)
+
+	return doRequest(ctx, t.client, req, "Twilio", http.StatusCreated)
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TwilioNotifier(t *testing.T) {
+	Convey("Twilio notifier", t, func() {
+		endpoint := newFakeEndpoint(nil, http.StatusCreated)
+		defer endpoint.Close()
+
+		twilio := NewTwilioNotifier("AC1916", "secret", "+15550001916", []string{"+15550000001", "+15550000002"})
+		twilio.ApiUrl = endpoint.URL
+
+		silent := &datatypes.Notification{
+			Type:        datatypes.CLUSTER_SILENT_NOTICE,
+			ClusterName: "france",
+			Severity:    datatypes.SEVERITY_CRITICAL,
+			Stale:       &datatypes.StaleCluster{ClusterName: "france"},
+		}
+
+		unknown := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Severity:    datatypes.SEVERITY_WARNING,
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "verdun", Status: service.UNKNOWN},
+				PreviousStatus: service.ALIVE,
+			},
+		}
+
+		Convey("Texts everyone about critical notifications", func() {
+			So(twilio.Notify(context.Background(), silent), ShouldBeNil)
+
+			So(endpoint.Paths(), ShouldResemble, []string{
+				"/2010-04-01/Accounts/AC1916/Messages.json",
+				"/2010-04-01/Accounts/AC1916/Messages.json",
+			})
+			So(endpoint.Requests[0].PostForm.Get("To"), ShouldEqual, "+15550000001")
+			So(endpoint.Requests[1].PostForm.Get("To"), ShouldEqual, "+15550000002")
+			So(endpoint.Requests[0].PostForm.Get("Body"), ShouldEqual, MessageFor(silent))
+
+			username, password, _ := endpoint.Requests[0].BasicAuth()
+			So(username, ShouldEqual, "AC1916")
+			So(password, ShouldEqual, "secret")
+		})
+
+		Convey("Skips notifications that aren't severe enough", func() {
+			So(twilio.Notify(context.Background(), unknown), ShouldBeNil)
+			So(endpoint.Paths(), ShouldBeEmpty)
+
+			twilio.Severity = SEVERITY_WARNING
+			So(twilio.Notify(context.Background(), unknown), ShouldBeNil)
+			So(len(endpoint.Requests), ShouldEqual, 2)
+		})
+
+		Convey("Goes by the classified severity, not the kind of notification", func() {
+			unknown.Severity = datatypes.SEVERITY_CRITICAL
+			So(twilio.Notify(context.Background(), unknown), ShouldBeNil)
+			So(len(endpoint.Requests), ShouldEqual, 2)
+		})
+
+		Convey("Reads its severity from the settings", func() {
+			settings := Settings{
+				"type": "twilio", "account_sid": "AC1916", "auth_token": "secret",
+				"from": "+15550001916", "to": []interface{}{"+15550000001"},
+				"severity": "Warning",
+			}
+
+			notifier, err := NewNotifier(settings)
+			So(err, ShouldBeNil)
+			So(notifier.(*TwilioNotifier).Severity, ShouldEqual, SEVERITY_WARNING)
+
+			delete(settings, "severity")
+			settings["severities"] = []interface{}{"critical", "warning"}
+			notifier, err = NewNotifier(settings)
+			So(err, ShouldBeNil)
+			So(notifier.(*TwilioNotifier).Severity, ShouldEqual, SEVERITY_WARNING)
+
+			settings["severity"] = "dire"
+			_, err = NewNotifier(settings)
+			So(err, ShouldNotBeNil)
+
+			_, err = NewNotifier(Settings{"type": "twilio", "account_sid": "AC1916", "auth_token": "secret"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
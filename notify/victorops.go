@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	VICTOROPS_API_URL = "https://alert.victorops.com/integrations/generic/20131114/alert"
+)
+
+// An alert in the Splunk On-Call REST endpoint's format
+type victorOpsAlert struct {
+	MessageType       string `json:"message_type"` // CRITICAL, WARNING or RECOVERY
+	EntityID          string `json:"entity_id"`
+	EntityDisplayName string `json:"entity_display_name"`
+	StateMessage      string `json:"state_message"`
+	StateStartTime    int64  `json:"state_start_time,omitempty"`
+	MonitoringTool    string `json:"monitoring_tool"`
+	Cluster           string `json:"cluster"`
+	Service           string `json:"service,omitempty"`
+	Hostname          string `json:"hostname,omitempty"`
+}
+
+// Raises Splunk On-Call (VictorOps) incidents for unhealthy services,
+// flapping services and silent clusters, and sends the recovery when they
+// get better. Each cluster's alerts go to its routing key in RoutingKeys,
+// or to RoutingKey. Like the Jira notifier, it only hears about recoveries
+// the dispatcher passes on, so routes to it usually set
+// dampen_flapping = false.
+type VictorOpsNotifier struct {
+	alwaysHealthy
+	ApiUrl      string
+	ApiKey      string
+	RoutingKey  string
+	RoutingKeys map[string]string // Cluster => routing key
+	firing      map[string]string // Entity ID => routing key it went to
+	lock        sync.Mutex
+	client      *http.Client
+}
+
+func init() {
+	RegisterFactory("victorops", func(settings Settings) (Notifier, error) {
+		if settings.String("api_key") == "" || settings.String("routing_key") == "" {
+			return nil, errors.New("api_key and routing_key are required")
+		}
+
+		victorOps := NewVictorOpsNotifier(settings.String("api_key"), settings.String("routing_key"))
+		if apiUrl := settings.String("api_url"); apiUrl != "" {
+			victorOps.ApiUrl = apiUrl
+		}
+
+		routingKeys := settings.Section("routing_keys")
+		for clusterName := range routingKeys {
+			if key := routingKeys.String(clusterName); key != "" {
+				victorOps.RoutingKeys[clusterName] = key
+			}
+		}
+
+		return victorOps, nil
+	})
+}
+
+func NewVictorOpsNotifier(apiKey string, routingKey string) *VictorOpsNotifier {
+	return &VictorOpsNotifier{
+		ApiUrl:      VICTOROPS_API_URL,
+		ApiKey:      apiKey,
+		RoutingKey:  routingKey,
+		RoutingKeys: make(map[string]string, 5),
+		firing:      make(map[string]string, 10),
+		client:      &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (v *VictorOpsNotifier) Name() string {
+	return "victorops"
+}
+
+func (v *VictorOpsNotifier) routingKeyFor(clusterName string) string {
+	if key, ok := v.RoutingKeys[clusterName]; ok {
+		return key
+	}
+	return v.RoutingKey
+}
+
+// Work out the alert for a notification, or nil if it doesn't concern us
+func (v *VictorOpsNotifier) alertFor(notice *datatypes.Notification) *victorOpsAlert {
+	alert := &victorOpsAlert{
+		StateMessage:   MessageFor(notice),
+		MonitoringTool: "superside",
+		Cluster:        notice.ClusterName,
+	}
+
+	var firing bool
+	var since time.Time
+
+	switch notice.Type {
+	case datatypes.FLAPPING_NOTICE, datatypes.STABILIZED_NOTICE:
+		if notice.Flap == nil {
+			return nil
+		}
+		alert.EntityID = "flapping/" + notice.ClusterName + "/" + notice.Flap.Service
+		alert.EntityDisplayName = fmt.Sprintf("[%s] %s is flapping", notice.ClusterName, notice.Flap.Service)
+		alert.Service = notice.Flap.Service
+		alert.MessageType = "WARNING"
+		firing = notice.Type == datatypes.FLAPPING_NOTICE
+		since = notice.Flap.Since
+
+	case datatypes.CLUSTER_SILENT_NOTICE, datatypes.CLUSTER_RESUMED_NOTICE:
+		alert.EntityID = "silent/" + notice.ClusterName
+		alert.EntityDisplayName = fmt.Sprintf("[%s] cluster is silent", notice.ClusterName)
+		alert.MessageType = "CRITICAL"
+		firing = notice.Type == datatypes.CLUSTER_SILENT_NOTICE
+		if notice.Stale != nil {
+			since = notice.Stale.LastSeen
+		}
+
+	case datatypes.SERVICE_EVENT_NOTICE:
+		if notice.Event == nil {
+			return nil
+		}
+		svc := notice.Event.Service
+		alert.EntityID = "unhealthy/" + instanceKey(notice)
+		alert.EntityDisplayName = fmt.Sprintf("[%s] %s on %s is unhealthy",
+			notice.ClusterName, svc.Name, svc.Hostname,
+		)
+		alert.Service = svc.Name
+		alert.Hostname = svc.Hostname
+		alert.MessageType = "CRITICAL"
+		firing = svc.Status == service.UNHEALTHY
+		since = notice.Event.Time
+
+	default:
+		return nil
+	}
+
+	if !since.IsZero() {
+		alert.StateStartTime = since.Unix()
+	}
+
+	if !firing {
+		alert.MessageType = "RECOVERY"
+	}
+
+	return alert
+}
+
+// Send the alert for a notification. Recoveries go to the routing key the
+// incident was raised on, and only if we raised one. We forget the incident
+// once the recovery is through, so retries still send it.
+func (v *VictorOpsNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	alert := v.alertFor(notice)
+	if alert == nil {
+		return nil
+	}
+
+	v.lock.Lock()
+	routingKey, ok := v.firing[alert.EntityID]
+	if !ok && alert.MessageType != "RECOVERY" {
+		routingKey = v.routingKeyFor(notice.ClusterName)
+		v.firing[alert.EntityID] = routingKey
+	}
+	v.lock.Unlock()
+
+	if !ok && alert.MessageType == "RECOVERY" {
+		return nil
+	}
+
+	err := v.post(ctx, routingKey, alert)
+	if err != nil || alert.MessageType != "RECOVERY" {
+		return err
+	}
+
+	v.lock.Lock()
+	if v.firing[alert.EntityID] == routingKey {
+		delete(v.firing, alert.EntityID)
+	}
+	v.lock.Unlock()
+
+	return nil
+}
+
+func (v *VictorOpsNotifier) post(ctx context.Context, routingKey string, alert *victorOpsAlert) error {
+	alertUrl := strings.TrimRight(v.ApiUrl, "/") + "/" + v.ApiKey + "/" + routingKey
+	return postJSON(ctx, v.client, alertUrl, alert, "Splunk On-Call", http.StatusOK)
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_VictorOpsNotifier(t *testing.T) {
+	Convey("Splunk On-Call notifier", t, func() {
+		endpoint := newFakeEndpoint(nil, http.StatusOK)
+		defer endpoint.Close()
+
+		victorOps := NewVictorOpsNotifier("api-key", "default")
+		victorOps.ApiUrl = endpoint.URL
+		victorOps.RoutingKeys["belgium"] = "ypres"
+
+		change := func(clusterName string, status int) *datatypes.Notification {
+			notice := failureNotice()
+			notice.ClusterName = clusterName
+			notice.Event.Service.Status = status
+			return notice
+		}
+
+		alert := func(i int) victorOpsAlert {
+			var alert victorOpsAlert
+			json.Unmarshal(endpoint.Bodies[i], &alert)
+			return alert
+		}
+
+		Convey("Raises an incident and recovers it", func() {
+			So(victorOps.Notify(context.Background(), change("france", service.UNHEALTHY)), ShouldBeNil)
+			So(victorOps.Notify(context.Background(), change("france", service.ALIVE)), ShouldBeNil)
+
+			So(endpoint.Paths(), ShouldResemble, []string{"/api-key/default", "/api-key/default"})
+			So(alert(0).MessageType, ShouldEqual, "CRITICAL")
+			So(alert(0).EntityID, ShouldEqual, "unhealthy/france/meuse/1")
+			So(alert(1).MessageType, ShouldEqual, "RECOVERY")
+			So(alert(1).EntityID, ShouldEqual, alert(0).EntityID)
+		})
+
+		Convey("Routes each cluster to its routing key", func() {
+			So(victorOps.Notify(context.Background(), change("belgium", service.UNHEALTHY)), ShouldBeNil)
+			So(endpoint.Paths(), ShouldResemble, []string{"/api-key/ypres"})
+		})
+
+		Convey("Doesn't recover incidents it never raised", func() {
+			So(victorOps.Notify(context.Background(), change("france", service.ALIVE)), ShouldBeNil)
+			So(endpoint.Paths(), ShouldBeEmpty)
+		})
+
+		Convey("Keeps the incident when a recovery fails, so it can be retried", func() {
+			victorOps.Notify(context.Background(), change("france", service.UNHEALTHY))
+
+			endpoint.Status = http.StatusInternalServerError
+			So(victorOps.Notify(context.Background(), change("france", service.ALIVE)), ShouldNotBeNil)
+
+			endpoint.Status = http.StatusOK
+			So(victorOps.Notify(context.Background(), change("france", service.ALIVE)), ShouldBeNil)
+			So(len(endpoint.Requests), ShouldEqual, 3)
+			So(victorOps.firing, ShouldBeEmpty)
+		})
+
+		Convey("Reads the routing keys from its settings", func() {
+			notifier, err := NewNotifier(Settings{
+				"type": "victorops", "api_key": "api-key", "routing_key": "default",
+				"routing_keys": map[string]interface{}{"belgium": "ypres"},
+			})
+
+			So(err, ShouldBeNil)
+			So(notifier.(*VictorOpsNotifier).routingKeyFor("belgium"), ShouldEqual, "ypres")
+			So(notifier.(*VictorOpsNotifier).routingKeyFor("france"), ShouldEqual, "default")
+		})
+	})
+}
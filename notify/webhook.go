@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// POSTs notifications to any URL. The body is the Notification as JSON,
+// unless there's a Template, in which case it's whatever that renders.
+type WebhookNotifier struct {
+	alwaysHealthy
+	Url         string
+	ContentType string
+	Template    *Template // Optional
+	client      *http.Client
+}
+
+func init() {
+	RegisterFactory("webhook", func(settings Settings) (Notifier, error) {
+		if settings.String("url") == "" {
+			return nil, errors.New("url is required")
+		}
+
+		webhook := NewWebhookNotifier(settings.String("url"))
+		if contentType := settings.String("content_type"); contentType != "" {
+			webhook.ContentType = contentType
+		}
+
+		var err error
+		webhook.Template, err = TemplateFromSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		return webhook, nil
+	})
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		Url:         url,
+		ContentType: "application/json",
+		client:      &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) body(notice *datatypes.Notification) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(notice)
+	}
+
+	rendered, err := w.Template.Render(notice)
+	return []byte(rendered), err
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, notice *datatypes.Notification) error {
+	body, err := w.body(notice)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+
+	return doRequest(ctx, w.client, req, "Webhook")
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_WebhookNotifier(t *testing.T) {
+	Convey("Webhook notifier", t, func() {
+		var body, contentType string
+		status := http.StatusOK
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, _ := ioutil.ReadAll(r.Body)
+			body = string(raw)
+			contentType = r.Header.Get("Content-Type")
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		webhook := NewWebhookNotifier(server.URL)
+		notice := &datatypes.Notification{ID: "joffre", ClusterName: "france"}
+
+		Convey("Posts the notification as JSON", func() {
+			So(webhook.Notify(context.Background(), notice), ShouldBeNil)
+			So(body, ShouldContainSubstring, `"ID":"joffre"`)
+			So(contentType, ShouldEqual, "application/json")
+		})
+
+		Convey("Includes the service's links", func() {
+			notice.Links = map[string]string{"runbook": "https://wiki/verdun"}
+			So(webhook.Notify(context.Background(), notice), ShouldBeNil)
+			So(body, ShouldContainSubstring, `"Links":{"runbook":"https://wiki/verdun"}`)
+		})
+
+		Convey("Posts whatever the template renders", func() {
+			webhook.Template, _ = ParseTemplate("test", "cluster={{ .ClusterName }}")
+			webhook.ContentType = "text/plain"
+
+			So(webhook.Notify(context.Background(), notice), ShouldBeNil)
+			So(body, ShouldEqual, "cluster=france")
+			So(contentType, ShouldEqual, "text/plain")
+		})
+
+		Convey("Reports errors from the receiver", func() {
+			status = http.StatusBadGateway
+			So(webhook.Notify(context.Background(), notice), ShouldNotBeNil)
+		})
+	})
+}
@@ -13,6 +13,15 @@
     angular.module('superside.views', []);
     angular.module('superside.filters', []);
 
+    // The UI lives at <base path>/ui/, so work out the base path from where
+    // we were loaded. Lets superside sit under a prefix behind a proxy.
+    angular.module('superside.services')
+        .constant('basePath', (function () {
+            var path = window.location.pathname;
+            var index = path.lastIndexOf('/ui/');
+            return index >= 0 ? path.substring(0, index) : '';
+        })());
+
     angular.module('superside')
         .config(['$locationProvider', '$routeProvider', function ($locationProvider, $routeProvider) {
             $locationProvider.hashPrefix('!');
@@ -85,6 +85,10 @@
 				cleanServiceEvent.StatusCode = service.Status;
                 cleanServiceEvent.Time = incident.Event.Time;
                 cleanServiceEvent.Hostnames = [service.Hostname];
+                cleanServiceEvent.Annotations = incident.Annotations || [];
+                cleanServiceEvent.ID = incident.ID;
+                cleanServiceEvent.Ack = incident.Ack;
+                cleanServiceEvent.Severity = incident.Severity;
             } else {
                 cleanServiceEvent.Type = 'Deployment';
                 cleanServiceEvent.ClusterName = incident.ClusterName;
@@ -4,13 +4,14 @@
 	angular.module('superside.services')
 		.factory('stateService', stateService);
 
-	stateService.$inject = ['$http', '$filter'];
+	stateService.$inject = ['$http', '$filter', 'basePath'];
 
-	function stateService($http, $filter) {
+	function stateService($http, $filter, basePath) {
 		var services = {};
 		var deployments = {};
 		var events = [];
         var clusters = {};
+        var display = { Timezone: 'UTC', Offset: 'UTC' };
 
         var addDeployment = function(deploy) {
 			deployments[name] = deployments[name] || {};
@@ -38,14 +39,26 @@
 			services: services,
 			deployments: deployments,
             clusters: clusters,
+            display: display,
 
 			addDeployment: addDeployment,
             addClusterName: addClusterName,
 
 			run: function() {
+				// Show times in the configured timezone rather than UTC
 				$http({
 					method: 'GET',
-					url: '/api/state/services',
+					url: basePath + '/api/v1/ui/settings',
+					dataType: 'json'
+				}).then(function(response) {
+					angular.extend(display, response.data);
+				}, function (error) {
+					console.log('ERROR: ' + error);
+				});
+
+				$http({
+					method: 'GET',
+					url: basePath + '/api/state/services',
 					dataType: 'json'
 				}).then(function(response) {
 
@@ -57,7 +70,7 @@
 
 					$http({
 						method: 'GET',
-						url: '/api/state/deployments',
+						url: basePath + '/api/state/deployments',
 						dataType: 'json'
 					}).then(function(response) {
 						_.each(response.data, function(service) {
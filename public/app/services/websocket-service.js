@@ -4,9 +4,9 @@
     angular.module('superside.services')
         .factory('websocketService', websocketService);
 
-    websocketService.$inject = ['stateService', '$filter'];
+    websocketService.$inject = ['stateService', '$filter', 'basePath'];
 
-    function websocketService(stateService, $filter) {
+    function websocketService(stateService, $filter, basePath) {
 
         var socket = null;
 
@@ -14,7 +14,8 @@
 
             setupSocket: function(options) {
                 // Create a new WebSocket.
-                var wsUrl = 'ws://' + window.location.hostname + ':7779/listen';
+                var scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+                var wsUrl = scheme + window.location.host + basePath + '/listen';
                 socket = new WebSocket(wsUrl);
 
                 // Handle any errors that occur.
@@ -40,6 +41,23 @@
                     var message = event.data;
                     var evt = angular.fromJson(message);
 
+                    // Acks update an event we already have
+                    if (evt.Type == 'Acknowledged') {
+                        _.each(stateService.events, function(existing) {
+                            if (existing.ID == evt.Data.ID) {
+                                existing.Ack = evt.Data.Ack;
+                            }
+                        });
+                    }
+
+                    // Only service events and deployments go in the timeline
+                    if (evt.Type != 'ServiceEvent' && evt.Type != 'Deployment') {
+                        if (typeof options.onMessage === 'function') {
+                            options.onMessage(message);
+                        }
+                        return;
+                    }
+
                     var filteredEvent = $filter('uiEvent')(evt.Data);
                     stateService.events.push(filteredEvent);
                     stateService.addClusterName(filteredEvent);
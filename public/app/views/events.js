@@ -13,6 +13,7 @@
 		self.deployments = stateService.deployments;
         self.services = stateService.services;
         self.clusters = stateService.clusters;
+        self.display = stateService.display;
         self.filters = {
             events : {
                 cluster: '',
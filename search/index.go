@@ -0,0 +1,117 @@
+package search
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A small in-memory inverted index mapping tokens to document IDs. Documents
+// are arbitrary bits of text identified by a string ID. A query matches a
+// document when every token in the query is present in it.
+type Index struct {
+	postings map[string]map[string]struct{} // Token => set of IDs
+	docs     map[string]map[string]struct{} // ID => set of tokens
+	lock     sync.RWMutex
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]struct{}, 500),
+		docs:     make(map[string]map[string]struct{}, 500),
+	}
+}
+
+// Split text into lowercased alphanumeric tokens. "api-gateway:1.2" yields
+// "api", "gateway", "1" and "2", and we also keep the whole lowercased text so
+// exact matches on things like hostnames work.
+func Tokenize(text string) []string {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil
+	}
+
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(tokens) != 1 || tokens[0] != text {
+		tokens = append(tokens, text)
+	}
+
+	return tokens
+}
+
+// Add some text to the document with this ID. Can be called more than once
+// for the same ID to add more text.
+func (i *Index) Add(id string, texts ...string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	tokens, ok := i.docs[id]
+	if !ok {
+		tokens = make(map[string]struct{}, 10)
+		i.docs[id] = tokens
+	}
+
+	for _, text := range texts {
+		for _, token := range Tokenize(text) {
+			tokens[token] = struct{}{}
+
+			ids, ok := i.postings[token]
+			if !ok {
+				ids = make(map[string]struct{}, 1)
+				i.postings[token] = ids
+			}
+			ids[id] = struct{}{}
+		}
+	}
+}
+
+// Remove a document from the index entirely
+func (i *Index) Remove(id string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for token := range i.docs[id] {
+		delete(i.postings[token], id)
+		if len(i.postings[token]) == 0 {
+			delete(i.postings, token)
+		}
+	}
+
+	delete(i.docs, id)
+}
+
+// Return the set of document IDs matching every token in the query. The query
+// is tokenized the same way as documents, minus the whole-text token, so
+// "api-gateway" finds anything containing both "api" and "gateway".
+func (i *Index) Search(query string) map[string]struct{} {
+	tokens := Tokenize(query)
+	if len(tokens) > 1 {
+		tokens = tokens[:len(tokens)-1] // Drop the whole-query token
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	results := make(map[string]struct{})
+	if len(tokens) == 0 {
+		return results
+	}
+
+	for id := range i.postings[tokens[0]] {
+		results[id] = struct{}{}
+	}
+
+	for _, token := range tokens[1:] {
+		ids := i.postings[token]
+		for id := range results {
+			if _, ok := ids[id]; !ok {
+				delete(results, id)
+			}
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Index(t *testing.T) {
+	Convey("Index", t, func() {
+		index := NewIndex()
+		index.Add("joffre", "api-gateway", "verdun.example.com", "api-gateway:1.2")
+		index.Add("foch", "billing-api", "marne.example.com", "billing-api:0.3")
+
+		Convey("Tokenizes on punctuation and keeps the whole text", func() {
+			So(Tokenize("API-Gateway:1.2"), ShouldResemble,
+				[]string{"api", "gateway", "1", "2", "api-gateway:1.2"})
+			So(Tokenize("db"), ShouldResemble, []string{"db"})
+		})
+
+		Convey("Finds documents containing every token", func() {
+			So(index.Search("api-gateway"), ShouldContainKey, "joffre")
+			So(index.Search("api-gateway"), ShouldNotContainKey, "foch")
+			So(len(index.Search("api")), ShouldEqual, 2)
+		})
+
+		Convey("Matches exact hostnames", func() {
+			So(index.Search("marne.example.com"), ShouldContainKey, "foch")
+		})
+
+		Convey("Picks up text added later", func() {
+			index.Add("foch", "known issue")
+			So(index.Search("Known Issue"), ShouldContainKey, "foch")
+		})
+
+		Convey("Forgets removed documents", func() {
+			index.Remove("joffre")
+			So(index.Search("gateway"), ShouldBeEmpty)
+			So(index.postings, ShouldNotContainKey, "gateway")
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	ASSET_HASH_LENGTH   = 10 // Hex digits of the content hash in asset names
+	ASSET_CACHE_CONTROL = "public, max-age=31536000, immutable"
+	INDEX_CACHE_CONTROL = "no-cache" // Always check, it names the current assets
+)
+
+// Local references in the index's script and link tags
+var assetReference = regexp.MustCompile(`((?:src|href)=")([^"]+)(")`)
+
+// The UI files with their content hashes in their names, so browsers can
+// keep them until they change. The index is rewritten to point at the
+// hashed names, and stays loadable as-is by a plain file server.
+type assetIndex struct {
+	hashed map[string]string // Real path => fingerprinted path
+	real   map[string]string // Fingerprinted path => real path
+	index  []byte            // The rewritten index.html, nil if there isn't one
+	etag   string            // Changes whenever any asset does
+}
+
+// The name with the hash before the extension, e.g. "app.1a2b3c4d5e.js"
+func fingerprinted(name string, content []byte) string {
+	hash := fmt.Sprintf("%x", sha1.Sum(content))[:ASSET_HASH_LENGTH]
+	ext := path.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Hash every file under the UI path. Done once, at startup.
+func loadAssets(root string) (*assetIndex, error) {
+	assets := &assetIndex{
+		hashed: make(map[string]string),
+		real:   make(map[string]string),
+	}
+
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel == "index.html" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		name := fingerprinted(rel, content)
+		assets.hashed[rel] = name
+		assets.real[name] = rel
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(root, "index.html"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if index != nil {
+		assets.index = assetReference.ReplaceAllFunc(index, func(match []byte) []byte {
+			parts := assetReference.FindSubmatch(match)
+			if name, ok := assets.hashed[string(parts[2])]; ok {
+				return bytes.Join([][]byte{parts[1], []byte(name), parts[3]}, nil)
+			}
+			return match
+		})
+		assets.etag = fmt.Sprintf(`"%x"`, sha1.Sum(assets.index))
+	}
+
+	return assets, nil
+}
+
+// Serve the UI: the index always revalidated, fingerprinted files cached
+// for good, and anything else, like the view templates, revalidated
+func (s *Server) assetsHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	name := strings.TrimPrefix(params.ByName("filepath"), "/")
+
+	if (name == "" || name == "index.html") && s.assets.index != nil {
+		response.Header().Set("Cache-Control", INDEX_CACHE_CONTROL)
+		response.Header().Set("ETag", s.assets.etag)
+		http.ServeContent(response, req, "index.html", time.Time{}, bytes.NewReader(s.assets.index))
+		return
+	}
+
+	if real, ok := s.assets.real[name]; ok {
+		response.Header().Set("Cache-Control", ASSET_CACHE_CONTROL)
+		name = real
+	} else {
+		response.Header().Set("Cache-Control", INDEX_CACHE_CONTROL)
+	}
+
+	req.URL.Path = "/" + name
+	http.FileServer(http.Dir(s.UIPath)).ServeHTTP(response, req)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Assets(t *testing.T) {
+	Convey("Serving the UI", t, func() {
+		dir, err := ioutil.TempDir("", "superside-ui")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		os.Mkdir(filepath.Join(dir, "views"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, "index.html"),
+			[]byte(`<link href="style.css"><script src="app.js"></script><a href="#">Home</a>`), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("angular.module('superside', [])"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "views", "events.html"), []byte("<div></div>"), 0644)
+
+		server := New(tracker.NewTracker(10, &store.NoopStore{}), WithUIPath(dir))
+		appJs := fingerprinted("app.js", []byte("angular.module('superside', [])"))
+
+		get := func(path string, headers ...string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", path, nil)
+			for i := 0; i+1 < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Convey("Points the index at the fingerprinted names", func() {
+			recorder := get("/ui/")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Cache-Control"), ShouldEqual, INDEX_CACHE_CONTROL)
+			So(recorder.Body.String(), ShouldContainSubstring, `src="`+appJs+`"`)
+			So(recorder.Body.String(), ShouldContainSubstring, `href="style.`)
+			So(recorder.Body.String(), ShouldContainSubstring, `href="#"`)
+			So(get("/ui/index.html").Body.String(), ShouldEqual, recorder.Body.String())
+
+			etag := recorder.Header().Get("ETag")
+			So(etag, ShouldNotBeEmpty)
+			So(get("/ui/", "If-None-Match", etag).Code, ShouldEqual, http.StatusNotModified)
+		})
+
+		Convey("Serves fingerprinted files to keep for good", func() {
+			So(strings.Count(appJs, "."), ShouldEqual, 2)
+
+			recorder := get("/ui/" + appJs)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Cache-Control"), ShouldEqual, ASSET_CACHE_CONTROL)
+			So(recorder.Body.String(), ShouldEqual, "angular.module('superside', [])")
+		})
+
+		Convey("Serves other files to check each time", func() {
+			recorder := get("/ui/views/events.html")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Cache-Control"), ShouldEqual, INDEX_CACHE_CONTROL)
+		})
+
+		Convey("Doesn't serve stale fingerprints", func() {
+			So(get("/ui/app.0123456789.js").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
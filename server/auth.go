@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/notify"
+)
+
+// Only let requests with "Authorization: Bearer <admin token>" through. With
+// no admin token configured, the endpoint is turned off altogether rather
+// than left open.
+func (s *Server) requireAdmin(handle httprouter.Handle) httprouter.Handle {
+	return func(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if s.adminToken == "" {
+			writeError(response, req, http.StatusNotFound, ERR_NOT_ENABLED, "No admin token is configured")
+			return
+		}
+
+		if !s.isAdmin(req) {
+			response.Header().Set("WWW-Authenticate", `Bearer realm="superside"`)
+			writeError(response, req, http.StatusUnauthorized, ERR_UNAUTHORIZED, "Expected the admin token as a Bearer token")
+			return
+		}
+
+		handle(response, req, params)
+	}
+}
+
+// Whether the request carries the admin token. Public endpoints use this to
+// decide how much detail to show.
+func (s *Server) isAdmin(req *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+
+	header := req.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	return token != header && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// The delivery records for an event. Errors quote webhook URLs, which often
+// have the secret in them, so only admins get to see them whole.
+func (s *Server) deliveriesFor(req *http.Request, noticeID string) []notify.DeliveryRecord {
+	records := []notify.DeliveryRecord{}
+	if s.notifiers == nil || s.notifiers.Log == nil {
+		return records
+	}
+
+	records = s.notifiers.Log.For(noticeID)
+	if !s.isAdmin(req) {
+		for i := range records {
+			records[i] = records[i].Redacted()
+		}
+	}
+
+	return records
+}
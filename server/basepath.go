@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Serve everything under this URL prefix, e.g. "/superside" when mounted
+// there behind a reverse proxy
+func WithBasePath(path string) Option {
+	return func(s *Server) {
+		s.basePath = ""
+		if trimmed := strings.Trim(path, "/"); trimmed != "" {
+			s.basePath = "/" + trimmed
+		}
+	}
+}
+
+// Puts the base path back on the redirects the handlers send, which only
+// know about the paths without it. Passes through flushing and hijacking
+// for the streaming and websocket endpoints.
+type basePathWriter struct {
+	http.ResponseWriter
+	basePath string
+}
+
+func (w *basePathWriter) WriteHeader(status int) {
+	location := w.Header().Get("Location")
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		w.Header().Set("Location", w.basePath+location)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *basePathWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *basePathWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Connection can't be hijacked")
+	}
+
+	return hijacker.Hijack()
+}
+
+// Take the base path off requests before routing them. Proxies that strip
+// it themselves work too, as do health checks that skip the proxy.
+func (s *Server) withBasePath(handler http.Handler) http.Handler {
+	if s.basePath == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == s.basePath || strings.HasPrefix(req.URL.Path, s.basePath+"/") {
+			stripped := *req.URL
+			stripped.Path = "/" + strings.TrimLeft(strings.TrimPrefix(req.URL.Path, s.basePath), "/")
+			stripped.RawPath = ""
+
+			req = req.WithContext(req.Context())
+			req.URL = &stripped
+		}
+
+		handler.ServeHTTP(&basePathWriter{ResponseWriter: response, basePath: s.basePath}, req)
+	})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_BasePath(t *testing.T) {
+	Convey("Serving under a base path", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		server := New(state, WithUIPath(""), WithBasePath("/superside/"))
+
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+
+		Convey("Normalizes the path", func() {
+			So(server.basePath, ShouldEqual, "/superside")
+			So(New(state, WithBasePath("/")).basePath, ShouldEqual, "")
+			So(New(state, WithBasePath("superside")).basePath, ShouldEqual, "/superside")
+		})
+
+		Convey("Routes requests with the prefix", func() {
+			So(get("/superside/health").Code, ShouldEqual, http.StatusOK)
+			So(get("/superside/api/state/services").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Still routes requests a proxy already stripped", func() {
+			So(get("/health").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Puts the prefix on redirects", func() {
+			recorder := get("/superside/")
+			So(recorder.Code, ShouldEqual, http.StatusMovedPermanently)
+			So(recorder.Header().Get("Location"), ShouldEqual, "/superside/ui/")
+
+			recorder = get("/superside")
+			So(recorder.Header().Get("Location"), ShouldEqual, "/superside/ui/")
+		})
+
+		Convey("Doesn't match paths that only start the same", func() {
+			So(get("/supersidekick/health").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
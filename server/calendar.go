@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	ICS_TIME_FORMAT = "20060102T150405Z"
+	ICS_LINE_LENGTH = 75 // Octets, longer lines are folded
+)
+
+// Escapes TEXT values per RFC 5545
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// Returns the outage windows as an iCalendar feed, so they can be overlaid
+// on team calendars. Supports ?cluster= and ?min=, the shortest unhealthy
+// period that counts, e.g. "10m".
+func (s *Server) incidentsIcsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	minDuration := tracker.DEFAULT_OUTAGE_MIN_DURATION
+	if min := query.Get("min"); min != "" {
+		var err error
+		minDuration, err = time.ParseDuration(min)
+		if err != nil || minDuration < 0 {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Invalid min duration: "+min)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	outages := s.tracker.GetOutageWindows(query.Get("cluster"), minDuration, now)
+
+	response.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	response.Header().Set("Content-Disposition", `inline; filename="incidents.ics"`)
+	response.Write(outagesCalendar(outages, now))
+}
+
+// Render the outage windows as a VCALENDAR with one VEVENT each
+func outagesCalendar(outages []tracker.OutageWindow, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(name string, value string) {
+		writeIcsLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Nitro//Superside//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", "Superside incidents")
+
+	for _, outage := range outages {
+		summary := fmt.Sprintf("[%s] %s unhealthy", outage.ClusterName, outage.Service)
+		if outage.Ongoing {
+			summary += " (ongoing)"
+		}
+
+		description := fmt.Sprintf("%s in %s was unhealthy for %s on %s",
+			outage.Service, outage.ClusterName,
+			outage.Duration().Truncate(time.Second), strings.Join(outage.Hostnames, ", "),
+		)
+
+		line("BEGIN", "VEVENT")
+		// Stable across fetches, so calendars update rather than duplicate
+		line("UID", fmt.Sprintf("%s-%s-%d@superside",
+			outage.ClusterName, outage.Service, outage.Start.Unix(),
+		))
+		line("DTSTAMP", now.UTC().Format(ICS_TIME_FORMAT))
+		line("DTSTART", outage.Start.UTC().Format(ICS_TIME_FORMAT))
+		line("DTEND", outage.End.UTC().Format(ICS_TIME_FORMAT))
+		line("SUMMARY", icsEscaper.Replace(summary))
+		line("DESCRIPTION", icsEscaper.Replace(description))
+		line("CATEGORIES", icsEscaper.Replace(outage.ClusterName))
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// Write a content line, folding it onto continuation lines that start with
+// a space when it's too long. Doesn't split UTF-8 characters.
+func writeIcsLine(buf *bytes.Buffer, text string) {
+	limit := ICS_LINE_LENGTH
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && text[cut]&0xC0 == 0x80 {
+			cut -= 1
+		}
+		buf.WriteString(text[:cut] + "\r\n ")
+		text = text[cut:]
+		limit = ICS_LINE_LENGTH - 1 // Allowing for the leading space
+	}
+	buf.WriteString(text + "\r\n")
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IncidentsIcs(t *testing.T) {
+	Convey("The incidents calendar", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		failedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		listener := state.GetSvcEventsListener()
+		for i, status := range []int{service.UNHEALTHY, service.ALIVE} {
+			state.EnqueueUpdate(catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service:        service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: status},
+					PreviousStatus: service.ALIVE,
+					Time:           failedAt.Add(time.Duration(i) * 20 * time.Minute),
+				},
+			})
+			for notice := <-listener; notice.Type == datatypes.INCIDENT_NOTICE; notice = <-listener {
+				// The failure opened an incident, its event is what we're waiting for
+			}
+		}
+		state.RemoveSvcEventsListener(listener)
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("Has an event for each sustained outage", func() {
+			recorder := get("/api/v1/incidents.ics")
+			body := recorder.Body.String()
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldStartWith, "text/calendar")
+			So(body, ShouldStartWith, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n")
+			So(body, ShouldContainSubstring, "SUMMARY:[france] verdun unhealthy\r\n")
+			So(body, ShouldContainSubstring, "DTSTART:"+failedAt.Format(ICS_TIME_FORMAT)+"\r\n")
+			So(body, ShouldContainSubstring, "DTEND:"+failedAt.Add(20*time.Minute).Format(ICS_TIME_FORMAT)+"\r\n")
+			So(body, ShouldEndWith, "END:VCALENDAR\r\n")
+		})
+
+		Convey("Leaves out outages shorter than ?min=", func() {
+			So(get("/api/v1/incidents.ics?min=30m").Body.String(), ShouldNotContainSubstring, "BEGIN:VEVENT")
+			So(get("/api/v1/incidents.ics?min=never").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Folds long lines", func() {
+			var buf bytes.Buffer
+			writeIcsLine(&buf, "DESCRIPTION:"+strings.Repeat("é", 100))
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+			So(len(lines), ShouldEqual, 3)
+			for _, line := range lines {
+				So(len(line), ShouldBeLessThanOrEqualTo, ICS_LINE_LENGTH)
+			}
+			So(strings.Replace(buf.String(), "\r\n ", "", -1), ShouldEqual, "DESCRIPTION:"+strings.Repeat("é", 100)+"\r\n")
+		})
+	})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// How the UI should show times
+type ApiDisplaySettings struct {
+	Timezone string // e.g. "Europe/Paris"
+	Offset   string // The timezone's offset from UTC right now, e.g. "+0200"
+}
+
+// Show times in the UI in this timezone. Timestamps in the API are always
+// UTC.
+func WithDisplayTimezone(location *time.Location) Option {
+	return func(s *Server) {
+		if location != nil {
+			s.timezone = location
+		}
+	}
+}
+
+func (s *Server) displaySettings(now time.Time) ApiDisplaySettings {
+	return ApiDisplaySettings{
+		Timezone: s.timezone.String(),
+		Offset:   now.In(s.timezone).Format("-0700"),
+	}
+}
+
+// Tells the UI how to show times
+func (s *Server) displaySettingsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+
+	writeNegotiated(response, req, s.displaySettings(time.Now()))
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DisplaySettings(t *testing.T) {
+	Convey("The display settings", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+
+		Convey("Default to UTC", func() {
+			recorder := httptest.NewRecorder()
+			New(state, WithUIPath("")).Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/ui/settings", nil))
+
+			var settings ApiDisplaySettings
+			json.Unmarshal(recorder.Body.Bytes(), &settings)
+			So(recorder.Code, ShouldEqual, 200)
+			So(settings, ShouldResemble, ApiDisplaySettings{Timezone: "UTC", Offset: "+0000"})
+		})
+
+		Convey("Give the configured timezone and its offset right now", func() {
+			server := New(state, WithUIPath(""), WithDisplayTimezone(time.FixedZone("CET", 3600)))
+
+			settings := server.displaySettings(time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC))
+			So(settings, ShouldResemble, ApiDisplaySettings{Timezone: "CET", Offset: "+0100"})
+		})
+	})
+}
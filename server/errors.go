@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	REQUEST_ID_HEADER     = "X-Request-Id"
+	MAX_REQUEST_ID_LENGTH = 128 // Longer ones from clients are replaced
+)
+
+// The machine-readable codes in error responses. Clients should switch on
+// these rather than the message, which is for people and may change.
+const (
+	ERR_BAD_REQUEST    = "bad_request"    // Missing or invalid parameters
+	ERR_INVALID_BODY   = "invalid_body"   // The body didn't parse
+	ERR_INVALID_FILTER = "invalid_filter" // A transition or region filter didn't parse
+	ERR_UNAUTHORIZED   = "unauthorized"   // Missing or wrong credentials
+	ERR_FORBIDDEN      = "forbidden"
+	ERR_NOT_FOUND      = "not_found"
+	ERR_NOT_ENABLED    = "not_enabled" // The feature isn't configured
+	ERR_CONFLICT       = "conflict"
+	ERR_KEY_REUSED     = "idempotency_key_reused" // Same Idempotency-Key, different request
+	ERR_GONE           = "gone"
+	ERR_NOT_ACCEPTABLE = "not_acceptable"
+	ERR_INTERNAL       = "internal_error"
+	ERR_MAINTENANCE    = "maintenance" // Not taking updates for now, try again later
+)
+
+// The body of every error response from the API. The RequestID is also in
+// the X-Request-Id header and the logs, to tie a report back to them.
+type ApiError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+type requestIDKey struct{}
+
+// Give every request an ID, keeping one passed in by a proxy or client, and
+// hand it back in the response headers
+func withRequestIDs(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(REQUEST_ID_HEADER)
+		if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+			id = uuid.NewV4().String()
+		}
+
+		response.Header().Set(REQUEST_ID_HEADER, id)
+		handler.ServeHTTP(response, req.WithContext(
+			context.WithValue(req.Context(), requestIDKey{}, id),
+		))
+	})
+}
+
+// The ID withRequestIDs gave the request, or "" if it didn't come through it
+func requestIDFor(req *http.Request) string {
+	id, _ := req.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// Write an ApiError with this status. Details are optional and anything
+// that marshals, usually the underlying error's text.
+func writeError(response http.ResponseWriter, req *http.Request, status int, code string, message string, details ...interface{}) {
+	apiError := ApiError{
+		Code:      code,
+		Message:   message,
+		RequestID: requestIDFor(req),
+	}
+
+	switch len(details) {
+	case 0:
+	case 1:
+		apiError.Details = details[0]
+	default:
+		apiError.Details = details
+	}
+
+	body, _ := json.Marshal(apiError)
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	response.Write(body)
+}
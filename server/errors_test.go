@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Errors(t *testing.T) {
+	Convey("Error responses", t, func() {
+		var seen string
+		handler := withRequestIDs(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			seen = requestIDFor(req)
+			writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such trench", "somme", "ypres")
+		}))
+
+		Convey("Carry the code, message, details and request ID", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			So(seen, ShouldNotBeEmpty)
+			So(recorder.Header().Get(REQUEST_ID_HEADER), ShouldEqual, seen)
+
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+			So(apiError, ShouldResemble, ApiError{
+				Code:      ERR_NOT_FOUND,
+				Message:   "No such trench",
+				Details:   []interface{}{"somme", "ypres"},
+				RequestID: seen,
+			})
+		})
+
+		Convey("Keep a request ID the client sent, within reason", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(REQUEST_ID_HEADER, "verdun")
+			handler.ServeHTTP(recorder, req)
+			So(seen, ShouldEqual, "verdun")
+
+			req.Header.Set(REQUEST_ID_HEADER, strings.Repeat("x", MAX_REQUEST_ID_LENGTH+1))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			So(len(seen), ShouldBeLessThanOrEqualTo, MAX_REQUEST_ID_LENGTH)
+		})
+	})
+}
@@ -0,0 +1,437 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_GRAPHQL_LIMIT = 100
+	MAX_GRAPHQL_LIMIT     = 1000
+)
+
+// The body of a GraphQL request, or the first message on a subscription
+// websocket
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Resolve a field from the Notification being resolved
+func noticeField(get func(*datatypes.Notification) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		notice, ok := p.Source.(*datatypes.Notification)
+		if !ok {
+			return nil, nil
+		}
+		return get(notice), nil
+	}
+}
+
+// Resolve a field from the service in the Notification being resolved
+func serviceField(get func(*service.Service) interface{}) graphql.FieldResolveFn {
+	return noticeField(func(notice *datatypes.Notification) interface{} {
+		if notice.Event == nil {
+			return nil
+		}
+		return get(&notice.Event.Service)
+	})
+}
+
+// Resolve a field of some other struct
+func sourceField(get func(interface{}) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source), nil
+	}
+}
+
+// The arguments for narrowing down events, on queries and subscriptions
+var eventArgs = graphql.FieldConfigArgument{
+	"cluster":    &graphql.ArgumentConfig{Type: graphql.String},
+	"service":    &graphql.ArgumentConfig{Type: graphql.String},
+	"status":     &graphql.ArgumentConfig{Type: graphql.String},
+	"region":     &graphql.ArgumentConfig{Type: graphql.String},
+	"transition": &graphql.ArgumentConfig{Type: graphql.String, Description: `e.g. "Alive->Unhealthy"`},
+	"severity":   &graphql.ArgumentConfig{Type: graphql.String, Description: `"info", "warning" or "critical", matching that or worse`},
+}
+
+// Build a predicate from the event arguments
+func eventMatcher(args map[string]interface{}) (func(*datatypes.Notification) bool, error) {
+	var transitions []string
+	if transition, ok := args["transition"].(string); ok {
+		transitions = append(transitions, transition)
+	}
+
+	filter, err := datatypes.ParseTransitionFilter(transitions)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, _ := args["cluster"].(string)
+	svcName, _ := args["service"].(string)
+	status, _ := args["status"].(string)
+	region, _ := args["region"].(string)
+
+	severity, _ := args["severity"].(string)
+	if severity != "" {
+		severity, err = datatypes.ParseSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(notice *datatypes.Notification) bool {
+		if (cluster != "" && notice.ClusterName != cluster) ||
+			(region != "" && notice.Region != region) ||
+			(severity != "" && !notice.SeverityAtLeast(severity)) ||
+			!filter.Matches(notice) {
+			return false
+		}
+
+		if notice.Event == nil {
+			return svcName == "" && status == ""
+		}
+
+		return (svcName == "" || notice.Event.Service.Name == svcName) &&
+			(status == "" || datatypes.StatusString(notice.Event.Service.Status) == status)
+	}, nil
+}
+
+func (s *Server) buildSchema() (graphql.Schema, error) {
+	annotationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Annotation",
+		Fields: graphql.Fields{
+			"author": {Type: graphql.String, Resolve: sourceField(func(a interface{}) interface{} { return a.(datatypes.Annotation).Author })},
+			"text":   {Type: graphql.String, Resolve: sourceField(func(a interface{}) interface{} { return a.(datatypes.Annotation).Text })},
+			"time":   {Type: graphql.DateTime, Resolve: sourceField(func(a interface{}) interface{} { return a.(datatypes.Annotation).Time })},
+		},
+	})
+
+	ackType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Acknowledgement",
+		Fields: graphql.Fields{
+			"user":     {Type: graphql.String, Resolve: sourceField(func(a interface{}) interface{} { return a.(*datatypes.Acknowledgement).User })},
+			"note":     {Type: graphql.String, Resolve: sourceField(func(a interface{}) interface{} { return a.(*datatypes.Acknowledgement).Note })},
+			"time":     {Type: graphql.DateTime, Resolve: sourceField(func(a interface{}) interface{} { return a.(*datatypes.Acknowledgement).Time })},
+			"resolved": {Type: graphql.Boolean, Resolve: sourceField(func(a interface{}) interface{} { return a.(*datatypes.Acknowledgement).Resolved })},
+		},
+	})
+
+	eventType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Event",
+		Fields: graphql.Fields{
+			"id":              {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.ID })},
+			"type":            {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Type })},
+			"cluster":         {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.ClusterName })},
+			"originalCluster": {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.OriginalClusterName })},
+			"region":          {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Region })},
+			"source":          {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Source })},
+			"transition":      {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Transition() })},
+			"flapping":        {Type: graphql.Boolean, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Flapping })},
+			"suppressed":      {Type: graphql.Boolean, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Suppressed })},
+			"correlationId":   {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.CorrelationID })},
+			"severity":        {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Severity })},
+			"labels":          {Type: graphql.NewList(graphql.String), Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.LabelPairs() })},
+			"links":           {Type: graphql.NewList(graphql.String), Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.LinkPairs() })},
+			"receivedAt":      {Type: graphql.DateTime, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.ReceivedAt })},
+			"possibleImpact":  {Type: graphql.NewList(graphql.String), Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.PossibleImpact })},
+			"annotations":     {Type: graphql.NewList(annotationType), Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Annotations })},
+			"ack":             {Type: ackType, Resolve: noticeField(func(n *datatypes.Notification) interface{} { return n.Ack })},
+			"service":         {Type: graphql.String, Resolve: serviceField(func(svc *service.Service) interface{} { return svc.Name })},
+			"serviceId":       {Type: graphql.String, Resolve: serviceField(func(svc *service.Service) interface{} { return svc.ID })},
+			"hostname":        {Type: graphql.String, Resolve: serviceField(func(svc *service.Service) interface{} { return svc.Hostname })},
+			"image":           {Type: graphql.String, Resolve: serviceField(func(svc *service.Service) interface{} { return svc.Image })},
+			"status":          {Type: graphql.String, Resolve: serviceField(func(svc *service.Service) interface{} { return datatypes.StatusString(svc.Status) })},
+			"previousStatus": {Type: graphql.String, Resolve: noticeField(func(n *datatypes.Notification) interface{} {
+				if n.Event == nil {
+					return nil
+				}
+				return datatypes.StatusString(n.Event.PreviousStatus)
+			})},
+			"time": {Type: graphql.DateTime, Resolve: noticeField(func(n *datatypes.Notification) interface{} {
+				if n.Event == nil {
+					return nil
+				}
+				return n.Event.Time
+			})},
+		},
+	})
+
+	instanceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Instance",
+		Fields: graphql.Fields{
+			"id":         {Type: graphql.String, Resolve: sourceField(func(i interface{}) interface{} { return i.(datatypes.InstanceView).ID })},
+			"hostname":   {Type: graphql.String, Resolve: sourceField(func(i interface{}) interface{} { return i.(datatypes.InstanceView).Hostname })},
+			"image":      {Type: graphql.String, Resolve: sourceField(func(i interface{}) interface{} { return i.(datatypes.InstanceView).Image })},
+			"status":     {Type: graphql.String, Resolve: sourceField(func(i interface{}) interface{} { return i.(datatypes.InstanceView).Status })},
+			"lastChange": {Type: graphql.DateTime, Resolve: sourceField(func(i interface{}) interface{} { return i.(datatypes.InstanceView).LastChange })},
+		},
+	})
+
+	serviceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Service",
+		Fields: graphql.Fields{
+			"name":      {Type: graphql.String, Resolve: sourceField(func(v interface{}) interface{} { return v.(datatypes.ServiceView).Name })},
+			"instances": {Type: graphql.NewList(instanceType), Resolve: sourceField(func(v interface{}) interface{} { return v.(datatypes.ServiceView).Instances })},
+		},
+	})
+
+	events := &graphql.Field{
+		Type: graphql.NewList(eventType),
+		Args: graphql.FieldConfigArgument{
+			"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: DEFAULT_GRAPHQL_LIMIT},
+		},
+		Description: "The most recent events, newest first, up to 1000 of them",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			args := p.Args
+			// Nested under a cluster, only that cluster's events
+			if view, ok := p.Source.(*datatypes.ClusterView); ok {
+				args = map[string]interface{}{"cluster": view.ClusterName}
+				for key, value := range p.Args {
+					args[key] = value
+				}
+			}
+
+			matches, err := eventMatcher(args)
+			if err != nil {
+				return nil, err
+			}
+
+			limit, _ := p.Args["limit"].(int)
+			if limit < 1 || limit > MAX_GRAPHQL_LIMIT {
+				return nil, fmt.Errorf("limit must be between 1 and %d", MAX_GRAPHQL_LIMIT)
+			}
+
+			all := s.tracker.GetSvcEventsList()
+			found := make([]*datatypes.Notification, 0, limit)
+			for i := len(all) - 1; i >= 0 && len(found) < limit; i-- {
+				if matches(&all[i]) {
+					found = append(found, &all[i])
+				}
+			}
+
+			return found, nil
+		},
+	}
+	for name, arg := range eventArgs {
+		events.Args[name] = arg
+	}
+
+	clusterType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Cluster",
+		Fields: graphql.Fields{
+			"name":       {Type: graphql.String, Resolve: sourceField(func(v interface{}) interface{} { return v.(*datatypes.ClusterView).ClusterName })},
+			"hosts":      {Type: graphql.NewList(graphql.String), Resolve: sourceField(func(v interface{}) interface{} { return v.(*datatypes.ClusterView).Hosts })},
+			"lastChange": {Type: graphql.DateTime, Resolve: sourceField(func(v interface{}) interface{} { return v.(*datatypes.ClusterView).LastChange })},
+			"region": {Type: graphql.String, Resolve: sourceField(func(v interface{}) interface{} {
+				return s.tracker.Regions.RegionOf(v.(*datatypes.ClusterView).ClusterName)
+			})},
+			"services": {
+				Type: graphql.NewList(serviceType),
+				Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.String}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name, _ := p.Args["name"].(string)
+					var services []datatypes.ServiceView
+					for _, svc := range p.Source.(*datatypes.ClusterView).Services {
+						if name == "" || svc.Name == name {
+							services = append(services, svc)
+						}
+					}
+					return services, nil
+				},
+			},
+			"events": events,
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"events": events,
+			"clusters": {
+				Type: graphql.NewList(clusterType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var views []*datatypes.ClusterView
+					for _, name := range s.tracker.GetClusterNames() {
+						if view := s.tracker.GetClusterView(name); view != nil {
+							views = append(views, view)
+						}
+					}
+					return views, nil
+				},
+			},
+			"cluster": {
+				Type: clusterType,
+				Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if view := s.tracker.GetClusterView(p.Args["name"].(string)); view != nil {
+						return view, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"events": {
+				Type:        eventType,
+				Args:        eventArgs,
+				Description: "Events as they happen",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					matches, err := eventMatcher(p.Args)
+					if err != nil {
+						return nil, err
+					}
+
+					listener := s.tracker.GetSvcEventsListener()
+					results := make(chan interface{})
+
+					go func() {
+						defer close(results)
+						defer s.tracker.RemoveSvcEventsListener(listener)
+
+						for {
+							select {
+							case <-p.Context.Done():
+								return
+							case notice := <-listener:
+								// Subscribers get events, so bursts come one at a time
+								for _, member := range notice.Unpack() {
+									if !matches(member) {
+										continue
+									}
+									select {
+									case results <- member:
+									case <-p.Context.Done():
+										return
+									}
+								}
+							}
+						}
+					}()
+
+					return results, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        query,
+		Subscription: subscription,
+	})
+}
+
+// Runs GraphQL queries, from either a JSON POST body or ?query= on a GET
+func (s *Server) graphqlHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var request graphqlRequest
+	if req.Method == "GET" {
+		request.Query = req.URL.Query().Get("query")
+		request.OperationName = req.URL.Query().Get("operationName")
+	} else {
+		err := json.NewDecoder(req.Body).Decode(&request)
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON GraphQL request", err.Error())
+			return
+		}
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        req.Context(),
+	})
+
+	message, _ := json.Marshal(result)
+	response.Write(message)
+}
+
+// Runs a GraphQL subscription over a websocket. The client sends the query
+// as the first message, the same as a POST body, and gets a result message
+// for each matching event until it goes away.
+func (s *Server) graphqlSubscriptionHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	s.sockets.Add(1)
+	defer s.sockets.Done()
+
+	var request graphqlRequest
+	err = conn.ReadJSON(&request)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Wind the subscription up if we're shutting down
+	go func() {
+		select {
+		case <-s.draining:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Notice when the client hangs up
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	results := graphql.Subscribe(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
+	})
+
+	for result := range results {
+		message, _ := json.Marshal(result)
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			cancel()
+			break
+		}
+	}
+
+	// Let the subscription wind down rather than leave it blocked on us
+	for range results {
+	}
+
+	select {
+	case <-s.draining:
+		goingAway(conn)
+	default:
+	}
+}
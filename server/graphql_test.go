@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type graphqlResponse struct {
+	Data   map[string]interface{}
+	Errors []map[string]interface{}
+}
+
+func Test_GraphQL(t *testing.T) {
+	Convey("The GraphQL API", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		state.Regions = tracker.NewRegionMap(map[string][]string{"western-front": {"france"}})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		stateChange := func(svcName string, status int) catalog.StateChangedEvent {
+			return catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: "france", Hostname: "joffre"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service: service.Service{
+						ID: svcName + "-1", Name: svcName, Hostname: "joffre",
+						Image: svcName + ":1", Status: status,
+					},
+					PreviousStatus: service.ALIVE,
+					Time:           time.Now().UTC(),
+				},
+			}
+		}
+
+		listener := state.GetSvcEventsListener()
+		state.EnqueueUpdate(stateChange("artillery", service.UNHEALTHY))
+		state.EnqueueUpdate(stateChange("cavalry", service.TOMBSTONE))
+		<-listener
+		<-listener
+		state.RemoveSvcEventsListener(listener)
+
+		query := func(body string) graphqlResponse {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+			server.Handler().ServeHTTP(recorder, req)
+
+			var response graphqlResponse
+			json.Unmarshal(recorder.Body.Bytes(), &response)
+			return response
+		}
+
+		Convey("Returns the most recent events first", func() {
+			response := query(`{"query": "{ events { service status region } }"}`)
+
+			So(response.Errors, ShouldBeEmpty)
+			events := response.Data["events"].([]interface{})
+			So(len(events), ShouldEqual, 2)
+			So(events[0], ShouldResemble, map[string]interface{}{
+				"service": "cavalry", "status": "Tombstone", "region": "western-front",
+			})
+		})
+
+		Convey("Filters events", func() {
+			response := query(`{
+				"query": "query($status: String) { events(status: $status) { service } }",
+				"variables": {"status": "Unhealthy"}
+			}`)
+
+			events := response.Data["events"].([]interface{})
+			So(len(events), ShouldEqual, 1)
+			So(events[0].(map[string]interface{})["service"], ShouldEqual, "artillery")
+
+			response = query(`{"query": "{ events(transition: \"Alive->Tombstone\", limit: 5) { service } }"}`)
+			So(len(response.Data["events"].([]interface{})), ShouldEqual, 1)
+		})
+
+		Convey("Reports bad filters", func() {
+			response := query(`{"query": "{ events(transition: \"Alive->Zombie\") { service } }"}`)
+			So(response.Errors, ShouldNotBeEmpty)
+		})
+
+		Convey("Rejects limits out of range", func() {
+			for _, limit := range []string{"-1", "0", "1001"} {
+				response := query(`{"query": "{ events(limit: ` + limit + `) { service } }"}`)
+				So(response.Errors, ShouldNotBeEmpty)
+				So(response.Errors[0]["message"], ShouldContainSubstring, "limit must be between 1 and 1000")
+			}
+		})
+
+		Convey("Nests services and events under clusters", func() {
+			response := query(`{"query": "{ clusters { name region services(name: \"artillery\") { name instances { hostname status } } events(limit: 1) { service } } }"}`)
+
+			So(response.Errors, ShouldBeEmpty)
+			clusters := response.Data["clusters"].([]interface{})
+			So(len(clusters), ShouldEqual, 1)
+
+			cluster := clusters[0].(map[string]interface{})
+			So(cluster["name"], ShouldEqual, "france")
+			So(cluster["region"], ShouldEqual, "western-front")
+			So(cluster["services"], ShouldResemble, []interface{}{
+				map[string]interface{}{
+					"name": "artillery",
+					"instances": []interface{}{
+						map[string]interface{}{"hostname": "joffre", "status": "Unhealthy"},
+					},
+				},
+			})
+			So(len(cluster["events"].([]interface{})), ShouldEqual, 1)
+		})
+
+		Convey("Returns null for clusters we don't know", func() {
+			response := query(`{"query": "{ cluster(name: \"belgium\") { name } }"}`)
+			So(response.Data["cluster"], ShouldBeNil)
+		})
+
+		Convey("Takes queries on GET requests", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ cluster(name: \"france\") { name } }"), nil)
+			server.Handler().ServeHTTP(recorder, req)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldEqual, `{"data":{"cluster":{"name":"france"}}}`)
+		})
+
+		Convey("Streams events to subscribers", func() {
+			httpServer := httptest.NewServer(server.Handler())
+			defer httpServer.Close()
+
+			wsUrl := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/graphql/subscriptions"
+			conn, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			err = conn.WriteJSON(graphqlRequest{
+				Query: `subscription { events(service: "infantry") { service status } }`,
+			})
+			So(err, ShouldBeNil)
+
+			// Keep sending until the subscription is set up to hear it
+			received := make(chan graphqlResponse)
+			go func() {
+				var response graphqlResponse
+				conn.ReadJSON(&response)
+				received <- response
+			}()
+
+			var response graphqlResponse
+		Sending:
+			for {
+				state.EnqueueUpdate(stateChange("cavalry", service.ALIVE))
+				state.EnqueueUpdate(stateChange("infantry", service.UNHEALTHY))
+				select {
+				case response = <-received:
+					break Sending
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+
+			So(response.Data["events"], ShouldResemble, map[string]interface{}{
+				"service": "infantry", "status": "Unhealthy",
+			})
+		})
+	})
+}
@@ -0,0 +1,1105 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/nitro/superside/alertevents"
+	"github.com/nitro/superside/cloudevents"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+	"github.com/nitro/superside/notify"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	NDJSON_FLUSH_EVERY = 100 // Events written between flushes when streaming
+)
+
+// One event along with where it was sent
+type ApiEvent struct {
+	datatypes.Notification
+	Deliveries []notify.DeliveryRecord
+}
+
+type ApiDeliveries struct {
+	EventID    string
+	Status     string // One of the notify.DELIVERY_ statuses
+	Deliveries []notify.DeliveryRecord
+}
+
+type ApiMessage struct {
+	Message string
+}
+
+type ApiStatus struct {
+	Message        string
+	ClusterLatches *tracker.ClusterEventsLatch
+	Notifiers      []notify.DeliveryStatus `json:",omitempty"`
+}
+
+// The health check endpoint. Notifier errors can quote webhook URLs, so
+// they're only shown whole with the admin token.
+func (s *Server) healthHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	//errors := make([]string, 0)
+
+	status := ApiStatus{
+		Message:        "Healthy!",
+		ClusterLatches: s.tracker.EventsLatch,
+	}
+
+	if s.notifiers != nil {
+		admin := s.isAdmin(req)
+		for _, managed := range s.notifiers.All() {
+			notifierStatus := managed.Status()
+			if !admin {
+				notifierStatus = notifierStatus.Redacted()
+			}
+			status.Notifiers = append(status.Notifiers, notifierStatus)
+		}
+	}
+
+	message, _ := json.Marshal(status)
+
+	response.Write(message)
+}
+
+// Build a filter from any "transition" and "region" query parameters
+func eventFilterFor(req *http.Request) (*datatypes.EventFilter, error) {
+	return datatypes.ParseEventFilter(req.URL.Query())
+}
+
+// Returns the currently stored state as JSON, or whatever else out of
+// MEDIA_TYPES the Accept header asks for. Can be narrowed to particular
+// status changes with e.g. ?transition=Alive->Unhealthy and to one region
+// with ?region=. Pollers can pass ?since=<event id> to get only
+// the events stored after that one, and ?limit=N for just the newest N.
+func (s *Server) servicesHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	filter, err := eventFilterFor(req)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_FILTER, err.Error())
+		return
+	}
+
+	lastModified := s.tracker.LastModified()
+	events := s.tracker.GetSvcEventsList()
+
+	if since := req.URL.Query().Get("since"); since != "" {
+		var ok bool
+		events, ok = eventsSince(events, since)
+		if !ok {
+			writeError(response, req, http.StatusGone, ERR_GONE,
+				"Event '"+since+"' is no longer stored, fetch the full state",
+			)
+			return
+		}
+	}
+
+	events = filter.Filter(events)
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		count, err := strconv.Atoi(limit)
+		if err != nil || count < 1 {
+			writeError(response, req, http.StatusBadRequest, ERR_INVALID_FILTER,
+				"Invalid limit '"+limit+"', expected a positive number",
+			)
+			return
+		}
+		events = newestEvents(events, count)
+	}
+
+	message, ok := negotiatedBody(response, req, events)
+	if ok {
+		writeConditional(response, req, message, lastModified)
+	}
+}
+
+// The last count events, which are the newest as they're stored oldest first
+func newestEvents(events []datatypes.Notification, count int) []datatypes.Notification {
+	if len(events) <= count {
+		return events
+	}
+
+	return events[len(events)-count:]
+}
+
+// The events stored after the one with this ID, or false if it has been
+// evicted or compacted away and the caller may have missed some. Later
+// changes to earlier events, like annotations, don't show up here.
+func eventsSince(events []datatypes.Notification, id string) ([]datatypes.Notification, bool) {
+	for i := range events {
+		if events[i].ID == id {
+			return events[i+1:], true
+		}
+	}
+
+	return nil, false
+}
+
+// Write the body with an ETag and Last-Modified, or just a 304 when the
+// client's copy is still current. Dashboards poll the state every few
+// seconds and it rarely changes in between.
+func writeConditional(response http.ResponseWriter, req *http.Request, body []byte, lastModified time.Time) {
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	response.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		response.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(req, etag, lastModified) {
+		response.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	response.Write(body)
+}
+
+// Does the request already have this version? If-None-Match wins when both
+// are sent, as the ETag also catches changes within the same second.
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// Returns the stored events as CSV, honoring the same filters as the JSON
+// state endpoint
+func (s *Server) servicesCsvHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+
+	filter, err := eventFilterFor(req)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_FILTER, err.Error())
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/csv")
+	response.Header().Set("Content-Disposition", `attachment; filename="state.csv"`)
+
+	writer := csv.NewWriter(response)
+	writer.Write(datatypes.CSV_HEADER)
+	for _, notice := range filter.Filter(s.tracker.GetSvcEventsList()) {
+		writer.Write(notice.CSVRecord())
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		log.Warnf("Error writing CSV: %s", err.Error())
+	}
+}
+
+// Streams the stored events as newline-delimited JSON, one event per line,
+// rather than marshaling one big array. Honors the same filters as the JSON
+// state endpoint.
+func (s *Server) servicesNdjsonHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+
+	filter, err := eventFilterFor(req)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_FILTER, err.Error())
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/x-ndjson")
+
+	flusher, canFlush := response.(http.Flusher)
+	encoder := json.NewEncoder(response) // Encode() adds the newline for us
+
+	for i, notice := range filter.Filter(s.tracker.GetSvcEventsList()) {
+		if err := encoder.Encode(notice); err != nil {
+			log.Warnf("Error streaming events: %s", err.Error())
+			return
+		}
+
+		if canFlush && (i+1)%NDJSON_FLUSH_EVERY == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+// Returns the currently stored state as a JSON blob
+func (s *Server) deploymentsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(s.tracker.GetDeployments())
+	response.Write(message)
+}
+
+// Returns the image version changes we've seen, newest first, optionally
+// limited to a cluster and/or service
+func (s *Server) versionChangesHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	query := req.URL.Query()
+	message, _ := json.Marshal(s.tracker.GetVersionChanges(query.Get("cluster"), query.Get("service")))
+	response.Write(message)
+}
+
+// Returns the hosts currently draining for maintenance, optionally in just
+// one ?cluster=
+func (s *Server) drainingHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	writeNegotiated(response, req, s.tracker.GetDrainingHosts(req.URL.Query().Get("cluster")))
+}
+
+// Returns the services that are currently flapping
+func (s *Server) flappingHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(s.tracker.GetFlapping())
+	response.Write(message)
+}
+
+// Returns transition counts bucketed by minute or hour. Supports filtering
+// by region, cluster and service, and a "since" RFC3339 timestamp.
+func (s *Server) rollupsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	query := req.URL.Query()
+
+	resolution := query.Get("resolution")
+	if resolution == "" {
+		resolution = "minute"
+	}
+
+	var since time.Time
+	var err error
+	if query.Get("since") != "" {
+		since, err = time.Parse(time.RFC3339, query.Get("since"))
+	}
+
+	var rollups []tracker.Rollup
+	if err == nil {
+		rollups, err = s.tracker.GetRollups(resolution, since, query.Get("cluster"), query.Get("service"))
+	}
+
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+
+	if region := query.Get("region"); region != "" {
+		inRegion := make([]tracker.Rollup, 0, len(rollups))
+		for _, rollup := range rollups {
+			if s.tracker.Regions.RegionOf(rollup.ClusterName) == region {
+				inRegion = append(inRegion, rollup)
+			}
+		}
+		rollups = inRegion
+	}
+
+	writeNegotiated(response, req, rollups)
+}
+
+// Attaches a note to a stored event
+func (s *Server) annotationHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var annotation datatypes.Annotation
+	err := json.NewDecoder(req.Body).Decode(&annotation)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON annotation", err.Error())
+		return
+	}
+
+	if annotation.Text == "" {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Expected an annotation with some Text")
+		return
+	}
+
+	notice := s.tracker.AnnotateEvent(params.ByName("id"), annotation)
+	if notice == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such event")
+		return
+	}
+
+	message, _ := json.Marshal(notice)
+	response.WriteHeader(http.StatusCreated)
+	response.Write(message)
+}
+
+// Returns a single stored event, with its annotations, acknowledgement and
+// the record of its deliveries to the notifiers
+func (s *Server) eventHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	notice := s.tracker.GetEvent(params.ByName("id"))
+	if notice == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such event")
+		return
+	}
+
+	event := ApiEvent{Notification: *notice, Deliveries: s.deliveriesFor(req, notice.ID)}
+
+	writeNegotiated(response, req, event)
+}
+
+// Returns where an event was sent, how many attempts each notifier and sink
+// took and how it ended, for working out why something didn't page. Events
+// that were delivered but not stored, like watchdog notices, are found too.
+func (s *Server) deliveriesHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	id := params.ByName("id")
+	deliveries := ApiDeliveries{EventID: id, Deliveries: s.deliveriesFor(req, id)}
+
+	if len(deliveries.Deliveries) == 0 && s.tracker.GetEvent(id) == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such event")
+		return
+	}
+
+	deliveries.Status = notify.FinalStatus(deliveries.Deliveries)
+	writeNegotiated(response, req, deliveries)
+}
+
+// Acknowledges a failure event. Posting to the resolve endpoint also marks it
+// as resolved.
+func (s *Server) makeAckHandler(resolve bool) httprouter.Handle {
+	return func(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		defer req.Body.Close()
+		response.Header().Set("Content-Type", "application/json")
+
+		var ack datatypes.Acknowledgement
+		err := json.NewDecoder(req.Body).Decode(&ack)
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON acknowledgement", err.Error())
+			return
+		}
+
+		if ack.User == "" {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Expected an acknowledgement with a User")
+			return
+		}
+		ack.Resolved = resolve
+
+		notice, err := s.tracker.AcknowledgeEvent(params.ByName("id"), ack)
+		if err != nil {
+			writeError(response, req, http.StatusConflict, ERR_CONFLICT, err.Error())
+			return
+		}
+
+		if notice == nil {
+			writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such event")
+			return
+		}
+
+		message, _ := json.Marshal(notice)
+		response.Write(message)
+	}
+}
+
+// Searches the stored event history
+func (s *Server) searchHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	query := req.URL.Query().Get("q")
+	if query == "" {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "No query specified")
+		return
+	}
+
+	writeNegotiated(response, req, s.tracker.SearchEvents(query))
+}
+
+// Returns summary statistics about the services we've seen, optionally
+// limited to one region
+func (s *Server) statsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	stats := s.tracker.StateDurations.Stats()
+	events := s.tracker.EventCounts.Snapshots()
+
+	if region := req.URL.Query().Get("region"); region != "" {
+		inRegion := make([]metrics.HistogramSnapshot, 0, len(stats))
+		for _, snap := range stats {
+			if s.tracker.Regions.RegionOf(snap.Labels["cluster"]) == region {
+				inRegion = append(inRegion, snap)
+			}
+		}
+		stats = inRegion
+
+		eventsInRegion := make([]metrics.CounterSnapshot, 0, len(events))
+		for _, snap := range events {
+			if s.tracker.Regions.RegionOf(snap.Labels["cluster"]) == region {
+				eventsInRegion = append(eventsInRegion, snap)
+			}
+		}
+		events = eventsInRegion
+	}
+
+	writeNegotiated(response, req, struct {
+		Events         []metrics.CounterSnapshot
+		StateDurations []metrics.HistogramSnapshot
+	}{events, stats})
+}
+
+// Returns the last full Sidecar state we received for a cluster. Sent
+// still compressed to clients that accept gzip.
+func (s *Server) snapshotHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var snapshot *tracker.Snapshot
+	if s.tracker.Snapshots != nil {
+		snapshot = s.tracker.Snapshots.Latest(req.URL.Query().Get("cluster"))
+	}
+
+	if snapshot == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No snapshot for that cluster")
+		return
+	}
+
+	response.Header().Set("Last-Modified", snapshot.Time.Format(http.TimeFormat))
+
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		response.Header().Set("Content-Encoding", "gzip")
+		response.Write(snapshot.Gzipped())
+		return
+	}
+
+	data, err := snapshot.JSON()
+	if err != nil {
+		writeError(response, req, http.StatusInternalServerError, ERR_INTERNAL, err.Error())
+		return
+	}
+
+	response.Write(data)
+}
+
+// Returns our view of what a cluster looks like right now
+func (s *Server) clusterCurrentHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	view := s.tracker.GetClusterView(params.ByName("name"))
+	if view == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such cluster")
+		return
+	}
+
+	writeNegotiated(response, req, view)
+}
+
+// Returns when each cluster last sent an update and how many it sent in the
+// last hour, so external monitoring can alert on gaps in ingestion. The
+// router won't take a static path alongside /api/v1/clusters/:name/current,
+// so this is mounted on /api/v1/clusters/:name. last-seen gets every
+// cluster, and a cluster's name gets just its entry.
+func (s *Server) clusterLastSeenHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	clusters := s.tracker.GetClusterLastSeen()
+
+	name := params.ByName("name")
+	if name == "last-seen" {
+		writeNegotiated(response, req, clusters)
+		return
+	}
+
+	for _, cluster := range clusters {
+		if cluster.ClusterName == name {
+			writeNegotiated(response, req, cluster)
+			return
+		}
+	}
+
+	writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such cluster")
+}
+
+// Returns the hosts we have events for, busiest first, optionally limited
+// to one cluster
+func (s *Server) hostsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	writeNegotiated(response, req, s.tracker.GetHosts(req.URL.Query().Get("cluster")))
+}
+
+// Returns the events for services on one host, optionally limited to one
+// cluster when the hostname isn't unique
+func (s *Server) hostEventsHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	events := s.tracker.GetHostEvents(params.ByName("hostname"), req.URL.Query().Get("cluster"))
+	if len(events) == 0 {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No events for that host")
+		return
+	}
+
+	writeNegotiated(response, req, events)
+}
+
+// Returns the configured regions and the clusters in each
+func (s *Server) regionsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(s.tracker.Regions.All())
+	response.Write(message)
+}
+
+// Returns the silences that haven't expired yet
+func (s *Server) silencesHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	writeNegotiated(response, req, s.tracker.GetSilences())
+}
+
+// Creates a new silence. Callers can either supply an ExpiresAt time or a
+// Duration like "2h" that starts now.
+func (s *Server) silenceCreateHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		datatypes.Silence
+		Duration string
+	}
+
+	err := json.NewDecoder(req.Body).Decode(&request)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON silence", err.Error())
+		return
+	}
+
+	if request.Duration != "" {
+		var duration time.Duration
+		duration, err = time.ParseDuration(request.Duration)
+		request.ExpiresAt = time.Now().UTC().Add(duration)
+	}
+
+	var silence *datatypes.Silence
+	if err == nil {
+		silence, err = s.tracker.AddSilence(request.Silence)
+	}
+
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+
+	message, _ := json.Marshal(silence)
+	response.WriteHeader(http.StatusCreated)
+	response.Write(message)
+}
+
+// Removes a silence before it expires
+func (s *Server) silenceDeleteHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if !s.tracker.RemoveSilence(params.ByName("id")) {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such silence")
+		return
+	}
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Reports that subscriptions aren't available, if they aren't. Returns true
+// if it did.
+func (s *Server) subscriptionsDisabled(response http.ResponseWriter, req *http.Request) bool {
+	if s.subscriptions != nil {
+		return false
+	}
+
+	writeError(response, req, http.StatusNotFound, ERR_NOT_ENABLED, "Subscriptions are not enabled")
+	return true
+}
+
+// Lists the webhook subscriptions
+func (s *Server) subscriptionsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.subscriptionsDisabled(response, req) {
+		return
+	}
+
+	writeNegotiated(response, req, s.subscriptions.All())
+}
+
+// Registers a callback Url, and optionally a Match expression, to receive
+// notifications
+func (s *Server) subscriptionCreateHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.subscriptionsDisabled(response, req) {
+		return
+	}
+
+	var request datatypes.Subscription
+	var subscription *datatypes.Subscription
+
+	err := json.NewDecoder(req.Body).Decode(&request)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON subscription", err.Error())
+		return
+	}
+
+	subscription, err = s.subscriptions.Add(request)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+
+	message, _ := json.Marshal(subscription)
+	response.WriteHeader(http.StatusCreated)
+	response.Write(message)
+}
+
+// Takes deploy and release markers from CI pipelines and puts them on the
+// deployments timeline
+func (s *Server) markerHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var marker datatypes.Marker
+	var deploy *datatypes.Deployment
+
+	err := json.NewDecoder(req.Body).Decode(&marker)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON marker", err.Error())
+		return
+	}
+
+	deploy, err = s.tracker.AddMarker(marker)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+
+	message, _ := json.Marshal(deploy)
+	response.WriteHeader(http.StatusCreated)
+	response.Write(message)
+}
+
+func (s *Server) subscriptionDeleteHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.subscriptionsDisabled(response, req) {
+		return
+	}
+
+	if !s.subscriptions.Remove(params.ByName("id")) {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such subscription")
+		return
+	}
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Returns the services that depend on the requested service, along with any
+// of their events that look correlated with its own changes
+func (s *Server) impactHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	svcName := req.URL.Query().Get("service")
+	if svcName == "" {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "No service specified")
+		return
+	}
+
+	message, _ := json.Marshal(s.tracker.GetImpact(svcName))
+	response.Write(message)
+}
+
+// Returns the map of declared service dependencies
+func (s *Server) dependenciesHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(s.tracker.Dependencies.All())
+	response.Write(message)
+}
+
+// Replaces the dependencies declared for one service
+func (s *Server) dependencyUpdateHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var dependency struct {
+		Service   string
+		DependsOn []string
+	}
+
+	err := json.NewDecoder(req.Body).Decode(&dependency)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON dependency", err.Error())
+		return
+	}
+
+	if dependency.Service == "" {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Expected a Service and its DependsOn list")
+		return
+	}
+
+	s.tracker.Dependencies.Set(dependency.Service, dependency.DependsOn)
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Returns the labels of every service that has some
+func (s *Server) labelsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	writeNegotiated(response, req, s.tracker.Labels.All())
+}
+
+// Returns the labels of one service
+func (s *Server) serviceLabelsHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	labels := s.tracker.Labels.Get(params.ByName("service"))
+	if labels == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No labels for that service")
+		return
+	}
+
+	writeNegotiated(response, req, labels)
+}
+
+// Replaces the labels of one service, e.g. {"team": "payments", "tier": "1"}.
+// They're attached to its notifications from then on, until the next restart
+// puts back the ones from the config.
+func (s *Server) serviceLabelsUpdateHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var labels map[string]string
+	err := json.NewDecoder(req.Body).Decode(&labels)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON object of labels", err.Error())
+		return
+	}
+
+	s.tracker.Labels.Set(params.ByName("service"), labels)
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Removes the labels of one service
+func (s *Server) serviceLabelsDeleteHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	svcName := params.ByName("service")
+	if s.tracker.Labels.Get(svcName) == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No labels for that service")
+		return
+	}
+	s.tracker.Labels.Set(svcName, nil)
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Receives POSTed state updates from Sidecar instances
+func (s *Server) updateHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Unable to read the request body", err.Error())
+		return
+	}
+
+	var evt catalog.StateChangedEvent
+	err = json.Unmarshal(data, &evt)
+	if err != nil {
+		log.Warnf("Bad update from %s: %s", req.RemoteAddr, err.Error())
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON StateChangedEvent", err.Error())
+		return
+	}
+
+	if len(s.ackKey) == 0 {
+		s.tracker.EnqueueUpdate(evt) // Potentially blocking
+
+		message, _ := json.Marshal(ApiMessage{"OK"})
+		response.Write(message)
+		return
+	}
+
+	id, receivedAt := s.tracker.EnqueueUpdateWithID(evt) // Potentially blocking
+	ack := datatypes.NewUpdateAck(id, receivedAt, data, s.ackKey)
+
+	message, _ := json.Marshal(ack)
+	response.Write(message)
+}
+
+// Receives Alertmanager webhooks and records the alerts as events. Alerts
+// without a "cluster" label are put in the cluster named by ?cluster=, and
+// alerts re-sent with the same status as last time are skipped.
+func (s *Server) alertmanagerHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var payload alertevents.Payload
+	err := json.NewDecoder(req.Body).Decode(&payload)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected an Alertmanager webhook payload", err.Error())
+		return
+	}
+
+	clusterName := req.URL.Query().Get("cluster")
+	if clusterName == "" {
+		clusterName = datatypes.ALERTMANAGER_SOURCE
+	}
+
+	for i := range payload.Alerts {
+		alert := &payload.Alerts[i]
+		if !s.alertLatch.ShouldAccept(alert) {
+			continue
+		}
+
+		evt := alertevents.EventFromAlert(alert, clusterName)
+		s.tracker.EnqueueUpdateFrom(datatypes.ALERTMANAGER_SOURCE, evt) // Potentially blocking
+	}
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Receives CloudEvents in structured or binary mode. Sidecar state changes
+// are treated just like /api/update; superside notifications are recorded
+// as coming from elsewhere.
+func (s *Server) cloudEventsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	event, err := cloudevents.FromRequest(req)
+
+	var evt *catalog.StateChangedEvent
+	if err == nil {
+		evt, err = event.StateChangedEvent()
+	}
+
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, err.Error())
+		return
+	}
+
+	// Potentially blocking
+	if event.Type == cloudevents.STATE_CHANGE_TYPE {
+		s.tracker.EnqueueUpdate(*evt)
+	} else {
+		s.tracker.EnqueueUpdateFrom(datatypes.CLOUDEVENTS_SOURCE, *evt)
+	}
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+// Subscribe to the events for one cluster, or all of them when it's ""
+func (s *Server) eventsListener(clusterName string) (chan *datatypes.Notification, func()) {
+	if clusterName == "" {
+		listener := s.tracker.GetSvcEventsListener()
+		return listener, func() { s.tracker.RemoveSvcEventsListener(listener) }
+	}
+
+	listener := s.tracker.GetClusterListener(clusterName)
+	return listener, func() { s.tracker.RemoveClusterListener(clusterName, listener) }
+}
+
+// Handle the listening endpoint websocket. Subscribers can pass the same
+// "transition" and "region" filters as the state endpoint to only get some
+// events. Those asking for LISTEN_SUBPROTOCOL can also send commands.
+// /listen/:cluster only gets the one cluster's events, from its own set of
+// listeners rather than through the filter.
+func (s *Server) listenHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clusterName := params.ByName("cluster")
+
+	filter, err := eventFilterFor(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ERR_INVALID_FILTER, err.Error())
+		return
+	}
+
+	var responseHeader http.Header
+	if wantsListenProtocol(r) {
+		responseHeader = http.Header{"Sec-Websocket-Protocol": {LISTEN_SUBPROTOCOL}}
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	s.sockets.Add(1)
+	defer s.sockets.Done()
+
+	if conn.Subprotocol() == LISTEN_SUBPROTOCOL {
+		defer conn.Close()
+		s.listenSession(conn, r, clusterName, filter)
+		return
+	}
+
+	svcEventsChan, removeListener := s.eventsListener(clusterName)
+	defer removeListener()
+
+	deployChan := s.tracker.GetDeploymentListener()
+	defer s.tracker.RemoveDeploymentListener(deployChan)
+
+	// Loop, multiplexing the two channels and constructing events
+	// from each.
+	for {
+		var message []byte
+
+		select {
+		case evt := <-svcEventsChan:
+			if !filter.Matches(evt) {
+				continue
+			}
+
+			output := struct {
+				Type string
+				Data interface{}
+			}{evt.Type, evt}
+			message, err = json.Marshal(output)
+
+		case deploy := <-deployChan:
+			if clusterName != "" && deploy.ClusterName != clusterName {
+				continue
+			}
+
+			output := struct {
+				Type string
+				Data interface{}
+			}{"Deployment", deploy}
+			message, err = json.Marshal(output)
+
+		case <-s.draining:
+			goingAway(conn)
+			conn.Close()
+			return
+		}
+
+		if err != nil {
+			log.Error("Error marshaling JSON event " + err.Error())
+			continue
+		}
+
+		if err = conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			log.Warn(err.Error())
+			return
+		}
+	}
+}
+
+func (s *Server) retriesDisabled(response http.ResponseWriter, req *http.Request) bool {
+	if s.retries != nil {
+		return false
+	}
+
+	writeError(response, req, http.StatusNotFound, ERR_NOT_ENABLED, "The retry queue is not enabled")
+	return true
+}
+
+// Lists the deliveries that ran out of retries
+func (s *Server) deadLettersHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.retriesDisabled(response, req) {
+		return
+	}
+
+	message, _ := json.Marshal(s.retries.DeadLetters())
+	response.Write(message)
+}
+
+// Puts one dead letter, or all of them when there's no id, back on the
+// retry queue
+func (s *Server) redriveHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.retriesDisabled(response, req) {
+		return
+	}
+
+	id := params.ByName("id")
+	moved := s.retries.Redrive(id, time.Now().UTC())
+	if id != "" && moved == 0 {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such dead letter")
+		return
+	}
+
+	message, _ := json.Marshal(ApiMessage{fmt.Sprintf("Re-driving %d deliveries", moved)})
+	response.Write(message)
+}
+
+func (s *Server) discardHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	if s.retriesDisabled(response, req) {
+		return
+	}
+
+	if !s.retries.Discard(params.ByName("id")) {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such dead letter")
+		return
+	}
+
+	message, _ := json.Marshal(ApiMessage{"OK"})
+	response.Write(message)
+}
+
+func (s *Server) uiRedirectHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	http.Redirect(response, req, "/ui/", 301)
+}
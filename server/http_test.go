@@ -0,0 +1,410 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Handlers(t *testing.T) {
+	Convey("The HTTP handlers", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		state.Regions = tracker.NewRegionMap(map[string][]string{"western-front": {"france"}})
+		server := New(state, WithUIPath(""))
+
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", path, nil)
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Convey("Report health", func() {
+			So(get("/health").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Serve the expvars", func() {
+			recorder := get("/debug/vars")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "memstats")
+		})
+
+		Convey("Manage the dead letters", func() {
+			So(get("/admin/dlq").Code, ShouldEqual, http.StatusNotFound)
+
+			dir, _ := ioutil.TempDir("", "dlq")
+			defer os.RemoveAll(dir)
+			dataStore := store.NewFileStore(dir)
+			dataStore.StoreBlob(notify.DEAD_LETTERS_KEY,
+				[]byte(`[
+					{"ID": "1", "Notifier": "pigeon", "Notice": {"ID": "somme"}, "FailedAt": "1916-07-01T07:30:00Z"},
+					{"ID": "2", "Notifier": "pigeon", "Notice": {"ID": "verdun"}, "FailedAt": "1916-12-18T00:00:00Z"}
+				]`))
+
+			retries := notify.NewRetryQueue(dataStore, &notify.Registry{})
+			retries.Load()
+			server = New(state, WithUIPath(""), WithRetryQueue(retries), WithAdminToken("lusitania"))
+			So(get("/admin/dlq").Code, ShouldEqual, http.StatusUnauthorized)
+
+			send := func(method string, path string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(method, path, nil)
+				req.Header.Set("Authorization", "Bearer lusitania")
+				server.Handler().ServeHTTP(recorder, req)
+				return recorder
+			}
+
+			var deadLetters []notify.RetryEntry
+			json.Unmarshal(send("GET", "/admin/dlq").Body.Bytes(), &deadLetters)
+			So(len(deadLetters), ShouldEqual, 2)
+			So(deadLetters[0].Notice.ID, ShouldEqual, "somme")
+
+			So(send("DELETE", "/admin/dlq/2").Code, ShouldEqual, http.StatusOK)
+			So(send("DELETE", "/admin/dlq/2").Code, ShouldEqual, http.StatusNotFound)
+
+			So(send("POST", "/admin/dlq/1").Code, ShouldEqual, http.StatusOK)
+			So(send("POST", "/admin/dlq/1").Code, ShouldEqual, http.StatusNotFound)
+			So(retries.DeadLetters(), ShouldBeEmpty)
+			So(len(retries.Pending()), ShouldEqual, 1)
+		})
+
+		Convey("Serve a single event with its deliveries", func() {
+			go state.ProcessUpdates()
+			listener := state.GetSvcEventsListener()
+			state.EnqueueUpdate(catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service:        service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+					PreviousStatus: service.ALIVE,
+					Time:           time.Now().UTC(),
+				},
+			})
+			notice := <-listener
+			state.RemoveSvcEventsListener(listener)
+
+			registry := &notify.Registry{Log: notify.NewDeliveryLog(10)}
+			registry.Log.Record(notice.ID, notify.DeliveryRecord{Notifier: "slack", Success: true})
+			server = New(state, WithUIPath(""), WithNotifiers(registry))
+
+			recorder := get("/api/v1/events/" + notice.ID)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			var event ApiEvent
+			json.Unmarshal(recorder.Body.Bytes(), &event)
+			So(event.ID, ShouldEqual, notice.ID)
+			So(event.Event.Service.Name, ShouldEqual, "verdun")
+			So(event.Deliveries, ShouldResemble, []notify.DeliveryRecord{{Notifier: "slack", Success: true}})
+
+			So(get("/api/v1/events/nope").Code, ShouldEqual, http.StatusNotFound)
+
+			var deliveries ApiDeliveries
+			recorder = get("/api/v1/events/" + notice.ID + "/deliveries")
+			json.Unmarshal(recorder.Body.Bytes(), &deliveries)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(deliveries.EventID, ShouldEqual, notice.ID)
+			So(deliveries.Status, ShouldEqual, notify.DELIVERY_DELIVERED)
+			So(len(deliveries.Deliveries), ShouldEqual, 1)
+
+			// Not stored, but we still know where it went
+			registry.Log.Record("watchdog", notify.DeliveryRecord{Notifier: "pagerduty", Attempts: 3})
+			recorder = get("/api/v1/events/watchdog/deliveries")
+			json.Unmarshal(recorder.Body.Bytes(), &deliveries)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(deliveries.Status, ShouldEqual, notify.DELIVERY_FAILED)
+			So(deliveries.Deliveries[0].Attempts, ShouldEqual, 3)
+
+			So(get("/api/v1/events/nope/deliveries").Code, ShouldEqual, http.StatusNotFound)
+
+			// Errors can quote the webhook URL, which only admins get to see
+			registry.Log.Record("gallipoli", notify.DeliveryRecord{
+				Notifier: "slack", Attempts: 3, Error: `Post "https://hooks.slack.com/services/T000/XXXX": EOF`,
+			})
+			server = New(state, WithUIPath(""), WithNotifiers(registry), WithAdminToken("lusitania"))
+
+			json.Unmarshal(get("/api/v1/events/gallipoli/deliveries").Body.Bytes(), &deliveries)
+			So(deliveries.Deliveries[0].Error, ShouldEqual, `Post "https://hooks.slack.com/[redacted]": EOF`)
+
+			recorder = httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/events/gallipoli/deliveries", nil)
+			req.Header.Set("Authorization", "Bearer lusitania")
+			server.Handler().ServeHTTP(recorder, req)
+			json.Unmarshal(recorder.Body.Bytes(), &deliveries)
+			So(deliveries.Deliveries[0].Error, ShouldContainSubstring, "T000/XXXX")
+		})
+
+		Convey("Report the notifiers' delivery status with the health", func() {
+			registry := &notify.Registry{}
+			registry.Register(notify.NewSlackNotifier("http://localhost:1", "", "superside"))
+			server = New(state, WithUIPath(""), WithNotifiers(registry))
+
+			var status ApiStatus
+			json.Unmarshal(get("/health").Body.Bytes(), &status)
+
+			So(len(status.Notifiers), ShouldEqual, 1)
+			So(status.Notifiers[0].Name, ShouldEqual, "slack")
+			So(status.Notifiers[0].CircuitOpen, ShouldBeFalse)
+		})
+
+		Convey("Serve the stored events", func() {
+			recorder := get("/api/state/services")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(strings.TrimSpace(recorder.Body.String()), ShouldEqual, "[]")
+		})
+
+		Convey("Serve the stored events in the format the client accepts", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/state/services", nil)
+			req.Header.Set("Accept", "application/msgpack")
+			server.Handler().ServeHTTP(recorder, req)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, MEDIA_MSGPACK)
+			So(recorder.Body.Bytes(), ShouldResemble, []byte{0x90}) // An empty array
+		})
+
+		Convey("Serve protobuf only where there's a message for it", func() {
+			getProtobuf := func(path string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", path, nil)
+				req.Header.Set("Accept", "application/x-protobuf")
+				server.Handler().ServeHTTP(recorder, req)
+				return recorder
+			}
+
+			recorder := getProtobuf("/api/state/services")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, MEDIA_PROTOBUF)
+			So(recorder.Body.Len(), ShouldEqual, 0) // An empty NotificationList
+
+			So(getProtobuf("/api/v1/labels").Code, ShouldEqual, http.StatusNotAcceptable)
+		})
+
+		Convey("Serve only the events after a given one", func() {
+			events := []datatypes.Notification{{ID: "marne"}, {ID: "ypres"}, {ID: "somme"}}
+
+			newer, ok := eventsSince(events, "ypres")
+			So(ok, ShouldBeTrue)
+			So(newer, ShouldResemble, []datatypes.Notification{{ID: "somme"}})
+
+			newer, ok = eventsSince(events, "somme")
+			So(ok, ShouldBeTrue)
+			So(newer, ShouldBeEmpty)
+
+			_, ok = eventsSince(events, "verdun")
+			So(ok, ShouldBeFalse)
+
+			So(get("/api/state/services?since=verdun").Code, ShouldEqual, http.StatusGone)
+		})
+
+		Convey("Serve only the newest events when given a limit", func() {
+			events := []datatypes.Notification{{ID: "marne"}, {ID: "ypres"}, {ID: "somme"}}
+
+			So(newestEvents(events, 2), ShouldResemble, []datatypes.Notification{{ID: "ypres"}, {ID: "somme"}})
+			So(newestEvents(events, 5), ShouldResemble, events)
+
+			So(get("/api/state/services?limit=2").Code, ShouldEqual, http.StatusOK)
+			So(get("/api/state/services?limit=0").Code, ShouldEqual, http.StatusBadRequest)
+			So(get("/api/state/services?limit=lots").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Answer conditional requests for the stored events", func() {
+			conditional := func(header string, value string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/api/state/services", nil)
+				req.Header.Set(header, value)
+				server.Handler().ServeHTTP(recorder, req)
+				return recorder
+			}
+
+			etag := get("/api/state/services").Header().Get("ETag")
+			So(etag, ShouldNotBeEmpty)
+
+			recorder := conditional("If-None-Match", etag)
+			So(recorder.Code, ShouldEqual, http.StatusNotModified)
+			So(recorder.Body.Len(), ShouldEqual, 0)
+
+			So(conditional("If-None-Match", `"stale", W/`+etag).Code, ShouldEqual, http.StatusNotModified)
+			So(conditional("If-None-Match", `"stale"`).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Compare If-Modified-Since with the last change", func() {
+			changed := time.Date(1916, 7, 1, 7, 30, 0, 500, time.UTC)
+			conditional := func(since time.Time) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+				writeConditional(recorder, req, []byte("[]"), changed)
+				return recorder
+			}
+
+			recorder := conditional(changed)
+			So(recorder.Code, ShouldEqual, http.StatusNotModified)
+			So(recorder.Header().Get("Last-Modified"), ShouldEqual, "Sat, 01 Jul 1916 07:30:00 GMT")
+
+			So(conditional(changed.Add(-time.Second)).Code, ShouldEqual, http.StatusOK)
+			So(conditional(changed.Add(-time.Second)).Body.String(), ShouldEqual, "[]")
+		})
+
+		Convey("Reject bad filters", func() {
+			recorder := get("/api/state/services?transition=Alive->Zombie")
+
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(apiError.Code, ShouldEqual, ERR_INVALID_FILTER)
+			So(apiError.Message, ShouldContainSubstring, "Zombie")
+			So(apiError.RequestID, ShouldEqual, recorder.Header().Get(REQUEST_ID_HEADER))
+		})
+
+		Convey("Reject updates that aren't JSON as the client's fault", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/update", strings.NewReader("{nope"))
+			req.Header.Set(REQUEST_ID_HEADER, "passchendaele")
+			server.Handler().ServeHTTP(recorder, req)
+
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(apiError.Code, ShouldEqual, ERR_INVALID_BODY)
+			So(apiError.Details, ShouldNotBeEmpty)
+			So(apiError.RequestID, ShouldEqual, "passchendaele")
+		})
+
+		Convey("Acknowledge updates with a signed event ID when asked to", func() {
+			body := `{"State": {"ClusterName": "france"}, "ChangeEvent": {"Service": {"Name": "verdun"}}}`
+			post := func() *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				server.Handler().ServeHTTP(recorder, httptest.NewRequest("POST", "/api/update", strings.NewReader(body)))
+				return recorder
+			}
+
+			So(post().Body.String(), ShouldEqual, `{"Message":"OK"}`)
+
+			server = New(state, WithUIPath(""), WithUpdateAcks("joffre"))
+			var ack datatypes.UpdateAck
+			json.Unmarshal(post().Body.Bytes(), &ack)
+
+			So(ack.EventID, ShouldNotBeEmpty)
+			So(ack.Verify([]byte("joffre"), []byte(body)), ShouldBeTrue)
+		})
+
+		Convey("Serve the configured regions", func() {
+			So(get("/regions").Body.String(), ShouldEqual, `{"western-front":["france"]}`)
+		})
+
+		Convey("Put deploy markers on the deployments timeline", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/markers",
+				strings.NewReader(`{"service": "artillery", "version": "1.2.3", "actor": "jenkins"}`))
+			server.Handler().ServeHTTP(recorder, req)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+
+			var deploys map[string][]datatypes.Deployment
+			json.Unmarshal(get("/api/state/deployments").Body.Bytes(), &deploys)
+			So(len(deploys["artillery"]), ShouldEqual, 1)
+			So(deploys["artillery"][0].Actor, ShouldEqual, "jenkins")
+			So(deploys["artillery"][0].Source, ShouldEqual, datatypes.MARKER_SOURCE)
+
+			recorder = httptest.NewRecorder()
+			req = httptest.NewRequest("POST", "/api/v1/markers", strings.NewReader(`{"service": "artillery"}`))
+			server.Handler().ServeHTTP(recorder, req)
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Serve the version change history", func() {
+			So(strings.TrimSpace(get("/deploys?service=artillery").Body.String()), ShouldEqual, "[]")
+		})
+
+		Convey("Report when each cluster was last seen", func() {
+			So(strings.TrimSpace(get("/api/v1/clusters/last-seen").Body.String()), ShouldEqual, "[]")
+			So(get("/api/v1/clusters/belgium").Code, ShouldEqual, http.StatusNotFound)
+
+			state.Watchdog.Seen("belgium", time.Now().UTC())
+			So(get("/api/v1/clusters/last-seen").Body.String(), ShouldContainSubstring, `"ClusterName":"belgium"`)
+
+			recorder := get("/api/v1/clusters/belgium")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldStartWith, `{"ClusterName":"belgium"`)
+		})
+
+		Convey("Serve events by host", func() {
+			So(strings.TrimSpace(get("/api/v1/hosts").Body.String()), ShouldEqual, "[]")
+			So(get("/api/v1/hosts/meuse/events").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Manage service labels", func() {
+			request := func(method string, path string, body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				server.Handler().ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+				return recorder
+			}
+
+			So(request("PUT", "/api/v1/labels/verdun", `{"team": "infantry"}`).Code, ShouldEqual, http.StatusOK)
+			So(state.Labels.Get("verdun"), ShouldResemble, map[string]string{"team": "infantry"})
+			So(get("/api/v1/labels/verdun").Body.String(), ShouldContainSubstring, `"team":"infantry"`)
+			So(get("/api/v1/labels").Body.String(), ShouldContainSubstring, `"verdun"`)
+
+			So(request("PUT", "/api/v1/labels/verdun", `["team"]`).Code, ShouldEqual, http.StatusBadRequest)
+
+			So(request("DELETE", "/api/v1/labels/verdun", "").Code, ShouldEqual, http.StatusOK)
+			So(get("/api/v1/labels/verdun").Code, ShouldEqual, http.StatusNotFound)
+			So(request("DELETE", "/api/v1/labels/verdun", "").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Return 404s for things we don't have", func() {
+			So(get("/api/v1/clusters/belgium/current").Code, ShouldEqual, http.StatusNotFound)
+			So(get("/api/v1/snapshot?cluster=belgium").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Manage webhook subscriptions", func() {
+			So(get("/api/v1/subscriptions").Code, ShouldEqual, http.StatusNotFound)
+
+			server = New(state, WithUIPath(""), WithAdminToken("lusitania"),
+				WithSubscriptions(notify.NewSubscriptions(&store.NoopStore{})))
+
+			send := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				if token != "" {
+					req.Header.Set("Authorization", "Bearer "+token)
+				}
+				server.Handler().ServeHTTP(recorder, req)
+				return recorder
+			}
+
+			body := `{"Url": "http://example.com/hook", "Match": "status == UNHEALTHY"}`
+			So(send("POST", "/api/v1/subscriptions", body, "").Code, ShouldEqual, http.StatusUnauthorized)
+			So(send("POST", "/api/v1/subscriptions", body, "lusitania").Code, ShouldEqual, http.StatusCreated)
+
+			var subscriptions []datatypes.Subscription
+			So(get("/api/v1/subscriptions").Code, ShouldEqual, http.StatusUnauthorized)
+			json.Unmarshal(send("GET", "/api/v1/subscriptions", "", "lusitania").Body.Bytes(), &subscriptions)
+			So(len(subscriptions), ShouldEqual, 1)
+			So(subscriptions[0].Match, ShouldEqual, "status == UNHEALTHY")
+
+			path := "/api/v1/subscriptions/" + subscriptions[0].ID
+			So(send("DELETE", path, "", "").Code, ShouldEqual, http.StatusUnauthorized)
+			So(send("DELETE", path, "", "lusitania").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Don't serve the UI when there's no path for it", func() {
+			So(get("/ui/index.html").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
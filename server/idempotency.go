@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/store"
+)
+
+const (
+	IDEMPOTENCY_HEADER        = "Idempotency-Key"
+	IDEMPOTENCY_REPLAY_HEADER = "Idempotent-Replayed" // Set on responses we've sent before
+	IDEMPOTENCY_KEYS_KEY      = "SupersideIdempotencyKeys"
+	DEFAULT_IDEMPOTENCY_SIZE  = 10000 // Keys remembered before the least recently used go
+	DEFAULT_IDEMPOTENCY_TTL   = 24 * time.Hour
+	MAX_IDEMPOTENCY_KEY_LEN   = 255
+	IDEMPOTENCY_SAVE_INTERVAL = 30 * time.Second
+)
+
+var (
+	ErrRequestInFlight = errors.New("A request with this key is still being handled")
+	ErrKeyReused       = errors.New("This key was already used for a different request")
+)
+
+// What we sent back the first time a key was used, along with a hash of
+// the request so the same key can't be reused for a different one
+type IdempotentResponse struct {
+	Key         string
+	Fingerprint string
+	Status      int // 0 while the first request is still being handled
+	Body        []byte
+	StoredAt    time.Time
+}
+
+// Remembers the responses to requests sent with an Idempotency-Key, so
+// retries get the original response instead of being handled twice. Keeps
+// at most Size keys for up to TTL, dropping the least recently used first.
+// With a store, the keys are saved every IDEMPOTENCY_SAVE_INTERVAL by
+// ManagePersistence() so they survive a restart.
+type IdempotencyCache struct {
+	Size    int
+	TTL     time.Duration
+	store   store.Store // Optional
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+	dirty   bool
+	lock    sync.Mutex
+}
+
+func NewIdempotencyCache(size int, ttl time.Duration, store store.Store) *IdempotencyCache {
+	return &IdempotencyCache{
+		Size:    size,
+		TTL:     ttl,
+		store:   store,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// Fingerprint a request body for comparing retries with the original
+func fingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Look up a key, claiming it for this request if we haven't seen it. Returns
+// the original response for a retry, nil when the caller should go ahead and
+// Complete() or Release() the key afterwards.
+func (c *IdempotencyCache) Claim(key string, fingerprint string, now time.Time) (*IdempotentResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*IdempotentResponse)
+		if now.Sub(entry.StoredAt) < c.TTL {
+			c.order.MoveToFront(element)
+			switch {
+			case entry.Fingerprint != fingerprint:
+				return nil, ErrKeyReused
+			case entry.Status == 0:
+				return nil, ErrRequestInFlight
+			}
+			return entry, nil
+		}
+		c.remove(element)
+	}
+
+	c.insert(&IdempotentResponse{Key: key, Fingerprint: fingerprint, StoredAt: now})
+	return nil, nil
+}
+
+// Remember the response to a claimed key
+func (c *IdempotencyCache) Complete(key string, status int, body []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*IdempotentResponse)
+		entry.Status = status
+		entry.Body = body
+		c.dirty = true
+	}
+}
+
+// Give up a claimed key, so a retry is handled afresh
+func (c *IdempotencyCache) Release(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok && element.Value.(*IdempotentResponse).Status == 0 {
+		c.remove(element)
+	}
+}
+
+// How many keys we're holding on to
+func (c *IdempotencyCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
+
+// Expects the lock to be held
+func (c *IdempotencyCache) insert(entry *IdempotentResponse) {
+	c.entries[entry.Key] = c.order.PushFront(entry)
+	for c.order.Len() > c.Size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Expects the lock to be held
+func (c *IdempotencyCache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*IdempotentResponse).Key)
+	c.order.Remove(element)
+	c.dirty = true
+}
+
+// Save the completed responses to the store if anything changed, oldest
+// first so Load() puts them back in order
+func (c *IdempotencyCache) Save() {
+	if c.store == nil {
+		return
+	}
+
+	c.lock.Lock()
+	if !c.dirty {
+		c.lock.Unlock()
+		return
+	}
+	saved := make([]*IdempotentResponse, 0, c.order.Len())
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entry := *element.Value.(*IdempotentResponse)
+		if entry.Status != 0 {
+			saved = append(saved, &entry)
+		}
+	}
+	c.dirty = false
+	c.lock.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err == nil {
+		err = c.store.StoreBlob(IDEMPOTENCY_KEYS_KEY, data)
+	}
+
+	if err != nil {
+		log.Errorf("Unable to save %s: %s", IDEMPOTENCY_KEYS_KEY, err.Error())
+	}
+}
+
+// Restore the keys saved in the store, skipping any that have expired
+func (c *IdempotencyCache) Load(now time.Time) error {
+	if c.store == nil {
+		return nil
+	}
+
+	data, err := c.store.GetBlob(IDEMPOTENCY_KEYS_KEY)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	var saved []*IdempotentResponse
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, entry := range saved {
+		if _, ok := c.entries[entry.Key]; !ok && now.Sub(entry.StoredAt) < c.TTL {
+			c.insert(entry)
+		}
+	}
+
+	return nil
+}
+
+// Save the keys periodically. Never returns.
+func (c *IdempotencyCache) ManagePersistence() {
+	for range time.Tick(IDEMPOTENCY_SAVE_INTERVAL) {
+		c.Save()
+	}
+}
+
+// Captures what a handler writes so it can be remembered
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Handle a request with an Idempotency-Key only once, replaying the
+// original response to retries. Requests without one are handled as usual.
+// Server errors aren't remembered, so those can be retried for real.
+func (s *Server) idempotent(handle httprouter.Handle) httprouter.Handle {
+	return func(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		key := req.Header.Get(IDEMPOTENCY_HEADER)
+		if key == "" || s.idempotency == nil {
+			handle(response, req, params)
+			return
+		}
+
+		if len(key) > MAX_IDEMPOTENCY_KEY_LEN {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "The Idempotency-Key is too long")
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Unable to read the request body", err.Error())
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		original, err := s.idempotency.Claim(key, fingerprint(body), time.Now().UTC())
+		switch err {
+		case ErrRequestInFlight:
+			writeError(response, req, http.StatusConflict, ERR_CONFLICT, err.Error())
+			return
+		case ErrKeyReused:
+			writeError(response, req, http.StatusUnprocessableEntity, ERR_KEY_REUSED, err.Error())
+			return
+		}
+
+		if original != nil {
+			response.Header().Set("Content-Type", "application/json")
+			response.Header().Set(IDEMPOTENCY_REPLAY_HEADER, "true")
+			response.WriteHeader(original.Status)
+			response.Write(original.Body)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: response}
+		defer func() {
+			if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+				s.idempotency.Release(key)
+				return
+			}
+			s.idempotency.Complete(key, recorder.status, recorder.body.Bytes())
+		}()
+
+		handle(recorder, req, params)
+	}
+}
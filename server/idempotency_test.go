@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IdempotencyCache(t *testing.T) {
+	Convey("The idempotency cache", t, func() {
+		cache := NewIdempotencyCache(2, time.Hour, nil)
+		baseTime := time.Now().UTC()
+
+		Convey("Claims new keys and replays completed ones", func() {
+			original, err := cache.Claim("joffre", "marne", baseTime)
+			So(err, ShouldBeNil)
+			So(original, ShouldBeNil)
+
+			_, err = cache.Claim("joffre", "marne", baseTime)
+			So(err, ShouldEqual, ErrRequestInFlight)
+
+			cache.Complete("joffre", http.StatusOK, []byte("OK"))
+			original, err = cache.Claim("joffre", "marne", baseTime)
+			So(err, ShouldBeNil)
+			So(original.Status, ShouldEqual, http.StatusOK)
+			So(string(original.Body), ShouldEqual, "OK")
+		})
+
+		Convey("Won't replay a key for a different request", func() {
+			cache.Claim("joffre", "marne", baseTime)
+			cache.Complete("joffre", http.StatusOK, []byte("OK"))
+
+			_, err := cache.Claim("joffre", "aisne", baseTime)
+			So(err, ShouldEqual, ErrKeyReused)
+		})
+
+		Convey("Forgets released keys", func() {
+			cache.Claim("joffre", "marne", baseTime)
+			cache.Release("joffre")
+
+			original, err := cache.Claim("joffre", "marne", baseTime)
+			So(err, ShouldBeNil)
+			So(original, ShouldBeNil)
+		})
+
+		Convey("Drops the least recently used keys", func() {
+			for _, key := range []string{"joffre", "foch", "petain"} {
+				cache.Claim(key, "marne", baseTime)
+				cache.Complete(key, http.StatusOK, nil)
+			}
+
+			So(cache.Len(), ShouldEqual, 2)
+			original, _ := cache.Claim("joffre", "marne", baseTime)
+			So(original, ShouldBeNil)
+		})
+
+		Convey("Forgets keys after the TTL", func() {
+			cache.Claim("joffre", "marne", baseTime)
+			cache.Complete("joffre", http.StatusOK, nil)
+
+			original, err := cache.Claim("joffre", "marne", baseTime.Add(2*time.Hour))
+			So(err, ShouldBeNil)
+			So(original, ShouldBeNil)
+		})
+
+		Convey("Saves and loads completed keys", func() {
+			dir, _ := ioutil.TempDir("", "idempotency")
+			defer os.RemoveAll(dir)
+
+			cache = NewIdempotencyCache(10, time.Hour, store.NewFileStore(dir))
+			cache.Claim("joffre", "marne", baseTime)
+			cache.Complete("joffre", http.StatusOK, []byte("OK"))
+			cache.Claim("foch", "aisne", baseTime) // Still in flight, so not saved
+			cache.Save()
+
+			restored := NewIdempotencyCache(10, time.Hour, store.NewFileStore(dir))
+			So(restored.Load(baseTime), ShouldBeNil)
+			So(restored.Len(), ShouldEqual, 1)
+
+			original, _ := restored.Claim("joffre", "marne", baseTime)
+			So(string(original.Body), ShouldEqual, "OK")
+
+			expired := NewIdempotencyCache(10, time.Hour, store.NewFileStore(dir))
+			So(expired.Load(baseTime.Add(2*time.Hour)), ShouldBeNil)
+			So(expired.Len(), ShouldEqual, 0)
+		})
+	})
+}
+
+func Test_IdempotentUpdates(t *testing.T) {
+	Convey("Updates with an Idempotency-Key", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		update := func(key string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/update", strings.NewReader(body))
+			if key != "" {
+				req.Header.Set(IDEMPOTENCY_HEADER, key)
+			}
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		body := `{"State": {"ClusterName": "france", "Hostname": "verdun"}, "ChangeEvent": {"Service": {"ID": "deadbeef0123", "Name": "db", "Status": 1}}}`
+
+		Convey("Are only recorded once", func() {
+			So(update("somme-1916", body).Code, ShouldEqual, http.StatusOK)
+
+			replay := update("somme-1916", body)
+			So(replay.Code, ShouldEqual, http.StatusOK)
+			So(replay.Header().Get(IDEMPOTENCY_REPLAY_HEADER), ShouldEqual, "true")
+
+			time.Sleep(50 * time.Millisecond) // Let the tracker catch up
+			So(state.Vars().EventsReceived, ShouldEqual, 1)
+		})
+
+		Convey("Are rejected when the key is reused for something else", func() {
+			update("somme-1916", body)
+
+			recorder := update("somme-1916", strings.Replace(body, "verdun", "ypres", 1))
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+			So(recorder.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			So(apiError.Code, ShouldEqual, ERR_KEY_REUSED)
+		})
+
+		Convey("Replay the original error too", func() {
+			So(update("somme-1916", `{`).Code, ShouldEqual, http.StatusBadRequest)
+			So(update("somme-1916", `{`).Header().Get(IDEMPOTENCY_REPLAY_HEADER), ShouldEqual, "true")
+		})
+
+		Convey("Are handled as usual without a key", func() {
+			So(update("", body).Code, ShouldEqual, http.StatusOK)
+			So(update("", body).Header().Get(IDEMPOTENCY_REPLAY_HEADER), ShouldBeEmpty)
+		})
+
+		Convey("Need a reasonable key", func() {
+			So(update(strings.Repeat("x", MAX_IDEMPOTENCY_KEY_LEN+1), body).Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Returns the incidents, newest first, optionally only those with one
+// ?status=, e.g. "open"
+func (s *Server) incidentsHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	status := req.URL.Query().Get("status")
+	switch status {
+	case "", datatypes.INCIDENT_OPEN, datatypes.INCIDENT_ACKNOWLEDGED, datatypes.INCIDENT_RESOLVED:
+	default:
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST,
+			"Unknown status '"+status+"', expected open, acknowledged or resolved",
+		)
+		return
+	}
+
+	writeNegotiated(response, req, s.tracker.Incidents.All(status))
+}
+
+// Returns one incident, with the IDs of the events in it
+func (s *Server) incidentHandler(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	incident := s.tracker.Incidents.Get(params.ByName("id"))
+	if incident == nil {
+		writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such incident")
+		return
+	}
+
+	writeNegotiated(response, req, incident)
+}
+
+// Acknowledges an incident. Posting to the resolve endpoint also resolves
+// it, without waiting for its services to recover.
+func (s *Server) makeIncidentAckHandler(resolve bool) httprouter.Handle {
+	return func(response http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		defer req.Body.Close()
+		response.Header().Set("Content-Type", "application/json")
+
+		var ack datatypes.Acknowledgement
+		err := json.NewDecoder(req.Body).Decode(&ack)
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON acknowledgement", err.Error())
+			return
+		}
+
+		if ack.User == "" {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Expected an acknowledgement with a User")
+			return
+		}
+		ack.Resolved = resolve
+
+		incident, err := s.tracker.AcknowledgeIncident(params.ByName("id"), ack)
+		if err != nil {
+			writeError(response, req, http.StatusConflict, ERR_CONFLICT, err.Error())
+			return
+		}
+
+		if incident == nil {
+			writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such incident")
+			return
+		}
+
+		message, _ := json.Marshal(incident)
+		response.Write(message)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Incidents(t *testing.T) {
+	Convey("The incidents API", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		listener := state.GetSvcEventsListener()
+		defer state.RemoveSvcEventsListener(listener)
+
+		state.EnqueueUpdate(catalog.StateChangedEvent{
+			State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+			ChangeEvent: catalog.ChangeEvent{
+				Service:        service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Now().UTC(),
+			},
+		})
+
+		failure := <-listener
+		opened := <-listener
+		So(opened.Type, ShouldEqual, datatypes.INCIDENT_NOTICE)
+		So(opened.Incident.EventIDs, ShouldResemble, []string{failure.ID})
+		id := opened.Incident.ID
+
+		request := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+			return recorder
+		}
+
+		Convey("Lists them, optionally by status", func() {
+			var incidents []datatypes.Incident
+			recorder := request("GET", "/api/v1/incidents?status=open", "")
+			json.Unmarshal(recorder.Body.Bytes(), &incidents)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(len(incidents), ShouldEqual, 1)
+			So(incidents[0].Services, ShouldResemble, []string{"verdun"})
+
+			So(request("GET", "/api/v1/incidents?status=resolved", "").Body.String(), ShouldEqual, "[]")
+			So(request("GET", "/api/v1/incidents?status=forgotten", "").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Returns one by ID", func() {
+			So(request("GET", "/api/v1/incidents/"+id, "").Code, ShouldEqual, http.StatusOK)
+			So(request("GET", "/api/v1/incidents/missing", "").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Acknowledges and resolves them, announcing each", func() {
+			recorder := request("POST", "/api/v1/incidents/"+id+"/ack", `{"User": "petain"}`)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So((<-listener).Incident.Status, ShouldEqual, datatypes.INCIDENT_ACKNOWLEDGED)
+
+			recorder = request("POST", "/api/v1/incidents/"+id+"/resolve", `{"User": "petain"}`)
+			var incident datatypes.Incident
+			json.Unmarshal(recorder.Body.Bytes(), &incident)
+			So(incident.Status, ShouldEqual, datatypes.INCIDENT_RESOLVED)
+			So((<-listener).Incident.Status, ShouldEqual, datatypes.INCIDENT_RESOLVED)
+
+			So(request("POST", "/api/v1/incidents/"+id+"/ack", `{"User": "petain"}`).Code, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("Needs to know who acknowledged it", func() {
+			So(request("POST", "/api/v1/incidents/"+id+"/ack", `{}`).Code, ShouldEqual, http.StatusBadRequest)
+			So(request("POST", "/api/v1/incidents/missing/ack", `{"User": "petain"}`).Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	INJECT_DEFAULT_NAME = "superside-test"
+	MAX_INJECT_COUNT    = 100
+)
+
+// Canned transitions for injecting, by name: previous status, then status
+var INJECT_FIXTURES = map[string][2]int{
+	"failure":   {service.ALIVE, service.UNHEALTHY},
+	"recovery":  {service.UNHEALTHY, service.ALIVE},
+	"tombstone": {service.ALIVE, service.TOMBSTONE},
+	"drain":     {service.ALIVE, datatypes.DRAINING},
+}
+
+// What to make up. Either a Fixture or both statuses are needed, the rest
+// defaults to INJECT_DEFAULT_NAME so it's easy to spot downstream.
+type InjectRequest struct {
+	Fixture        string
+	ClusterName    string
+	Service        string
+	Hostname       string
+	Image          string
+	PreviousStatus string // e.g. "Alive", ignored with a Fixture
+	Status         string
+	Count          int // How many, 1 by default
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// Build the event for an injection request
+func (r *InjectRequest) event(now time.Time) (catalog.StateChangedEvent, error) {
+	var previous, status int
+
+	if r.Fixture != "" {
+		fixture, ok := INJECT_FIXTURES[r.Fixture]
+		if !ok {
+			return catalog.StateChangedEvent{}, fmt.Errorf("Unknown fixture '%s'", r.Fixture)
+		}
+		previous, status = fixture[0], fixture[1]
+	} else {
+		var err, err2 error
+		previous, err = datatypes.ParseStatus(r.PreviousStatus)
+		status, err2 = datatypes.ParseStatus(r.Status)
+		if err == nil {
+			err = err2
+		}
+		if err == nil && (previous == datatypes.ANY_STATUS || status == datatypes.ANY_STATUS) {
+			err = fmt.Errorf("Expected a Fixture or both PreviousStatus and Status")
+		}
+		if err != nil {
+			return catalog.StateChangedEvent{}, err
+		}
+	}
+
+	clusterName := orDefault(r.ClusterName, INJECT_DEFAULT_NAME)
+	svcName := orDefault(r.Service, INJECT_DEFAULT_NAME)
+	hostname := orDefault(r.Hostname, INJECT_DEFAULT_NAME)
+
+	return catalog.StateChangedEvent{
+		State: catalog.ServicesState{ClusterName: clusterName, Hostname: hostname},
+		ChangeEvent: catalog.ChangeEvent{
+			Service: service.Service{
+				ID:       uuid.NewV4().String()[:12],
+				Name:     svcName,
+				Image:    orDefault(r.Image, svcName+":latest"),
+				Hostname: hostname,
+				Created:  now,
+				Updated:  now,
+				Status:   status,
+			},
+			PreviousStatus: previous,
+			Time:           now,
+		},
+	}, nil
+}
+
+// Makes up events and sends them through the whole pipeline, so notifiers,
+// webhooks and dashboards can be checked end to end. They're marked with
+// the INJECTED_SOURCE.
+func (s *Server) injectHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	// This is the one admin endpoint that changes our state
+	if s.Maintenance().Enabled {
+		response.Header().Set("Retry-After", MAINTENANCE_RETRY_AFTER)
+		writeError(response, req, http.StatusServiceUnavailable, ERR_MAINTENANCE, "Superside is in maintenance mode")
+		return
+	}
+
+	var request InjectRequest
+	err := json.NewDecoder(req.Body).Decode(&request)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON injection request", err.Error())
+		return
+	}
+
+	if request.Count == 0 {
+		request.Count = 1
+	}
+	if request.Count < 0 || request.Count > MAX_INJECT_COUNT {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST,
+			fmt.Sprintf("Count must be between 1 and %d", MAX_INJECT_COUNT),
+		)
+		return
+	}
+
+	now := time.Now().UTC()
+	events := make([]catalog.StateChangedEvent, 0, request.Count)
+	for i := 0; i < request.Count; i++ {
+		evt, err := request.event(now)
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+			return
+		}
+		events = append(events, evt)
+	}
+
+	for _, evt := range events {
+		s.tracker.EnqueueUpdateFrom(datatypes.INJECTED_SOURCE, evt)
+	}
+
+	message, _ := json.Marshal(ApiMessage{fmt.Sprintf("Injected %d events", len(events))})
+	response.WriteHeader(http.StatusAccepted)
+	response.Write(message)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Inject(t *testing.T) {
+	Convey("Injecting test events", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""), WithAdminToken("lusitania"))
+
+		inject := func(token string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/admin/inject", strings.NewReader(body))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Convey("Needs the admin token", func() {
+			So(inject("", `{"Fixture": "failure"}`).Code, ShouldEqual, http.StatusUnauthorized)
+
+			recorder := inject("zeppelin", `{"Fixture": "failure"}`)
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+			So(recorder.Code, ShouldEqual, http.StatusUnauthorized)
+			So(apiError.Code, ShouldEqual, ERR_UNAUTHORIZED)
+		})
+
+		Convey("Is off without an admin token", func() {
+			server = New(state, WithUIPath(""))
+			So(inject("", `{"Fixture": "failure"}`).Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Sends fixtures through the pipeline", func() {
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+
+			So(inject("lusitania", `{"Fixture": "failure", "ClusterName": "staging", "Count": 2}`).Code, ShouldEqual, http.StatusAccepted)
+
+			for i := 0; i < 2; i++ {
+				notice := <-listener
+				if notice.Type == datatypes.INCIDENT_NOTICE { // Opened by the first failure
+					notice = <-listener
+				}
+				So(notice.Source, ShouldEqual, datatypes.INJECTED_SOURCE)
+				So(notice.ClusterName, ShouldEqual, "staging")
+				So(notice.Event.Service.Name, ShouldEqual, INJECT_DEFAULT_NAME)
+				So(notice.Event.PreviousStatus, ShouldEqual, service.ALIVE)
+				So(notice.Event.Service.Status, ShouldEqual, service.UNHEALTHY)
+			}
+		})
+
+		Convey("Takes statuses by name", func() {
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+
+			So(inject("lusitania", `{"Service": "nginx", "PreviousStatus": "unhealthy", "Status": "tombstone"}`).Code, ShouldEqual, http.StatusAccepted)
+
+			notice := <-listener
+			So(notice.Event.Service.Name, ShouldEqual, "nginx")
+			So(notice.Event.Service.Image, ShouldEqual, "nginx:latest")
+			So(notice.Transition(), ShouldEqual, "Unhealthy->Tombstone")
+		})
+
+		Convey("Rejects bad requests", func() {
+			So(inject("lusitania", `{"Fixture": "armistice"}`).Code, ShouldEqual, http.StatusBadRequest)
+			So(inject("lusitania", `{"Status": "Alive"}`).Code, ShouldEqual, http.StatusBadRequest)
+			So(inject("lusitania", `{"Fixture": "failure", "Count": 1000}`).Code, ShouldEqual, http.StatusBadRequest)
+			So(inject("lusitania", `{`).Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
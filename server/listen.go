@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Clients asking for this websocket subprotocol on /listen can send
+// commands and get typed frames back. Everyone else gets the original
+// one-way stream of {"Type", "Data"} messages.
+const LISTEN_SUBPROTOCOL = "superside.v1"
+
+const (
+	CMD_FILTER = "filter" // Replace the filter, with Transitions and Region
+	CMD_REPLAY = "replay" // Send the stored events after Since, or all of them
+	CMD_PAUSE  = "pause"  // Stop sending events, which are dropped until resumed
+	CMD_RESUME = "resume"
+	CMD_PING   = "ping"
+
+	FRAME_EVENT      = "event"
+	FRAME_DEPLOYMENT = "deployment"
+	FRAME_HEARTBEAT  = "heartbeat"
+	FRAME_ACK        = "ack" // A command worked
+	FRAME_PONG       = "pong"
+	FRAME_ERROR      = "error" // A command didn't work, Data is an ApiError
+
+	MAX_COMMAND_SIZE = 64 * 1024
+)
+
+// A command from a client. Ref is echoed back on the frames answering it.
+type ListenCommand struct {
+	Command     string
+	Ref         string   `json:",omitempty"`
+	Transitions []string `json:",omitempty"` // CMD_FILTER, like ?transition=
+	Region      string   `json:",omitempty"` // CMD_FILTER, like ?region=
+	Severity    string   `json:",omitempty"` // CMD_FILTER, like ?severity=
+	Since       string   `json:",omitempty"` // CMD_REPLAY, an event ID
+}
+
+// Everything we send to a client
+type ListenFrame struct {
+	Type string
+	Ref  string      `json:",omitempty"`
+	Data interface{} `json:",omitempty"`
+}
+
+// Whether the client asked for the command subprotocol
+func wantsListenProtocol(req *http.Request) bool {
+	for _, protocol := range websocket.Subprotocols(req) {
+		if protocol == LISTEN_SUBPROTOCOL {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read commands off the connection until it closes. Ones that don't parse
+// come through with just the error set.
+func readCommands(conn *websocket.Conn, commands chan<- ListenCommand, errors chan<- error, done <-chan struct{}) {
+	defer close(commands)
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var command ListenCommand
+		err = json.Unmarshal(message, &command)
+		if err != nil {
+			select {
+			case errors <- err:
+			case <-done:
+				return
+			}
+			continue
+		}
+
+		select {
+		case commands <- command:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Serve one client speaking LISTEN_SUBPROTOCOL. Heartbeats always get
+// through, whatever the filter, so the client can tell we're alive.
+func (s *Server) listenSession(conn *websocket.Conn, req *http.Request, clusterName string, filter *datatypes.EventFilter) {
+	conn.SetReadLimit(MAX_COMMAND_SIZE)
+
+	svcEventsChan, removeListener := s.eventsListener(clusterName)
+	defer removeListener()
+
+	deployChan := s.tracker.GetDeploymentListener()
+	defer s.tracker.RemoveDeploymentListener(deployChan)
+
+	commands := make(chan ListenCommand)
+	badCommands := make(chan error)
+	done := make(chan struct{})
+	defer close(done)
+	go readCommands(conn, commands, badCommands, done)
+
+	paused := false
+
+	fail := func(ref string, code string, message string) error {
+		return conn.WriteJSON(ListenFrame{
+			Type: FRAME_ERROR,
+			Ref:  ref,
+			Data: ApiError{Code: code, Message: message, RequestID: requestIDFor(req)},
+		})
+	}
+
+	for {
+		var err error
+
+		select {
+		case evt := <-svcEventsChan:
+			if evt.Type == datatypes.HEARTBEAT_NOTICE {
+				err = conn.WriteJSON(ListenFrame{Type: FRAME_HEARTBEAT, Data: evt})
+				break
+			}
+
+			if paused || !filter.Matches(evt) {
+				continue
+			}
+			err = conn.WriteJSON(ListenFrame{Type: FRAME_EVENT, Data: evt})
+
+		case deploy := <-deployChan:
+			if paused || (clusterName != "" && deploy.ClusterName != clusterName) {
+				continue
+			}
+			err = conn.WriteJSON(ListenFrame{Type: FRAME_DEPLOYMENT, Data: deploy})
+
+		case parseErr := <-badCommands:
+			err = fail("", ERR_INVALID_BODY, parseErr.Error())
+
+		case command, ok := <-commands:
+			if !ok {
+				return // The client hung up
+			}
+
+			switch command.Command {
+			case CMD_FILTER:
+				newFilter, filterErr := datatypes.ParseEventFilter(url.Values{
+					"transition": command.Transitions,
+					"region":     {command.Region},
+					"severity":   {command.Severity},
+				})
+				if filterErr != nil {
+					err = fail(command.Ref, ERR_INVALID_FILTER, filterErr.Error())
+					break
+				}
+				filter = newFilter
+				err = conn.WriteJSON(ListenFrame{Type: FRAME_ACK, Ref: command.Ref})
+
+			case CMD_REPLAY:
+				events := s.tracker.GetSvcEventsList()
+				if command.Since != "" {
+					var found bool
+					events, found = eventsSince(events, command.Since)
+					if !found {
+						err = fail(command.Ref, ERR_GONE,
+							"Event '"+command.Since+"' is no longer stored, replay everything",
+						)
+						break
+					}
+				}
+
+				for _, evt := range filter.Filter(events) {
+					if clusterName != "" && evt.ClusterName != clusterName {
+						continue
+					}
+
+					err = conn.WriteJSON(ListenFrame{Type: FRAME_EVENT, Ref: command.Ref, Data: evt})
+					if err != nil {
+						break
+					}
+				}
+				if err == nil {
+					err = conn.WriteJSON(ListenFrame{Type: FRAME_ACK, Ref: command.Ref})
+				}
+
+			case CMD_PAUSE, CMD_RESUME:
+				paused = command.Command == CMD_PAUSE
+				err = conn.WriteJSON(ListenFrame{Type: FRAME_ACK, Ref: command.Ref})
+
+			case CMD_PING:
+				err = conn.WriteJSON(ListenFrame{Type: FRAME_PONG, Ref: command.Ref})
+
+			default:
+				err = fail(command.Ref, ERR_BAD_REQUEST, "Unknown command '"+command.Command+"'")
+			}
+
+		case <-s.draining:
+			goingAway(conn)
+			return
+		}
+
+		if err != nil {
+			log.Warn(err.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ListenProtocol(t *testing.T) {
+	Convey("The /listen command protocol", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		stateChange := func(svcName string, status int) catalog.StateChangedEvent {
+			return catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: "france", Hostname: "joffre"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service: service.Service{
+						ID: svcName + "-1", Name: svcName, Hostname: "joffre",
+						Image: svcName + ":1", Status: status,
+					},
+					PreviousStatus: service.ALIVE,
+					Time:           time.Now().UTC(),
+				},
+			}
+		}
+
+		listener := state.GetSvcEventsListener()
+		state.EnqueueUpdate(stateChange("artillery", service.UNHEALTHY))
+		state.EnqueueUpdate(stateChange("cavalry", service.TOMBSTONE))
+		<-listener
+		<-listener
+		state.RemoveSvcEventsListener(listener)
+		stored := state.GetSvcEventsList()
+
+		httpServer := httptest.NewServer(server.Handler())
+		defer httpServer.Close()
+
+		wsUrl := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/listen"
+		dialer := websocket.Dialer{Subprotocols: []string{LISTEN_SUBPROTOCOL}}
+		conn, _, err := dialer.Dial(wsUrl, nil)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		So(conn.Subprotocol(), ShouldEqual, LISTEN_SUBPROTOCOL)
+
+		send := func(command ListenCommand) {
+			So(conn.WriteJSON(command), ShouldBeNil)
+		}
+
+		receive := func() map[string]interface{} {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var frame map[string]interface{}
+			So(conn.ReadJSON(&frame), ShouldBeNil)
+			return frame
+		}
+
+		Convey("Answers pings", func() {
+			send(ListenCommand{Command: CMD_PING, Ref: "1"})
+			frame := receive()
+			So(frame["Type"], ShouldEqual, FRAME_PONG)
+			So(frame["Ref"], ShouldEqual, "1")
+		})
+
+		Convey("Replays stored events", func() {
+			send(ListenCommand{Command: CMD_REPLAY, Ref: "2"})
+			So(receive()["Type"], ShouldEqual, FRAME_EVENT)
+			So(receive()["Type"], ShouldEqual, FRAME_EVENT)
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+		})
+
+		Convey("Replays events after an ID", func() {
+			send(ListenCommand{Command: CMD_REPLAY, Since: stored[0].ID})
+			frame := receive()
+			So(frame["Type"], ShouldEqual, FRAME_EVENT)
+			So(frame["Data"].(map[string]interface{})["ID"], ShouldEqual, stored[1].ID)
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+		})
+
+		Convey("Replays through the filter", func() {
+			send(ListenCommand{Command: CMD_FILTER, Transitions: []string{"Alive->Tombstone"}})
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+
+			send(ListenCommand{Command: CMD_REPLAY})
+			frame := receive()
+			So(frame["Data"].(map[string]interface{})["ID"], ShouldEqual, stored[1].ID)
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+		})
+
+		Convey("Says when a replay can't be done", func() {
+			send(ListenCommand{Command: CMD_REPLAY, Since: "passchendaele"})
+			frame := receive()
+			So(frame["Type"], ShouldEqual, FRAME_ERROR)
+			So(frame["Data"].(map[string]interface{})["code"], ShouldEqual, ERR_GONE)
+		})
+
+		Convey("Rejects bad filters, commands and JSON", func() {
+			send(ListenCommand{Command: CMD_FILTER, Transitions: []string{"Sideways"}})
+			So(receive()["Data"].(map[string]interface{})["code"], ShouldEqual, ERR_INVALID_FILTER)
+
+			send(ListenCommand{Command: "charge"})
+			So(receive()["Data"].(map[string]interface{})["code"], ShouldEqual, ERR_BAD_REQUEST)
+
+			conn.WriteMessage(websocket.TextMessage, []byte("{"))
+			So(receive()["Data"].(map[string]interface{})["code"], ShouldEqual, ERR_INVALID_BODY)
+		})
+
+		Convey("Holds events back while paused", func() {
+			send(ListenCommand{Command: CMD_PAUSE})
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+
+			// The ping is answered after the event would have been sent
+			state.EnqueueUpdate(stateChange("infantry", service.UNHEALTHY))
+			time.Sleep(50 * time.Millisecond)
+			send(ListenCommand{Command: CMD_PING})
+			So(receive()["Type"], ShouldEqual, FRAME_PONG)
+
+			send(ListenCommand{Command: CMD_RESUME})
+			So(receive()["Type"], ShouldEqual, FRAME_ACK)
+
+			state.EnqueueUpdate(stateChange("infantry", service.TOMBSTONE))
+			So(receive()["Type"], ShouldEqual, FRAME_EVENT)
+		})
+	})
+
+	Convey("Listening to one cluster", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		httpServer := httptest.NewServer(server.Handler())
+		defer httpServer.Close()
+
+		wsUrl := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/listen/belgium"
+		conn, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		// Wait for the handler to subscribe
+		for state.Vars().ClusterListeners == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		for _, clusterName := range []string{"france", "belgium"} {
+			state.EnqueueUpdate(catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: clusterName, Hostname: "joffre"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service:        service.Service{ID: "1", Name: "artillery", Hostname: "joffre", Status: service.UNHEALTHY},
+					PreviousStatus: service.ALIVE,
+					Time:           time.Now().UTC(),
+				},
+			})
+		}
+
+		var message struct {
+			Type string
+			Data map[string]interface{}
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		So(conn.ReadJSON(&message), ShouldBeNil)
+		So(message.Data["ClusterName"], ShouldEqual, "belgium")
+	})
+}
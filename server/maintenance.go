@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	MAINTENANCE_RETRY_AFTER = "60" // Seconds, for the Retry-After header
+)
+
+// Whether we're in maintenance mode, and why
+type MaintenanceStatus struct {
+	Enabled bool
+	Reason  string    `json:",omitempty"`
+	Since   time.Time // When it was last turned on or off
+}
+
+// Is this request allowed to change anything? Reads, GraphQL queries and
+// the admin API always are.
+func isWrite(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+
+	return req.URL.Path != "/graphql" && !strings.HasPrefix(req.URL.Path, "/admin/")
+}
+
+// In maintenance mode, turn away anything that would change our state with
+// a 503, so Sidecar and friends retry later. History and streams are still
+// served.
+func (s *Server) withMaintenance(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if !isWrite(req) {
+			handler.ServeHTTP(response, req)
+			return
+		}
+
+		s.writeLock.RLock()
+		defer s.writeLock.RUnlock()
+
+		status := s.Maintenance()
+		if status.Enabled {
+			message := "Superside is in maintenance mode and not taking updates"
+			if status.Reason != "" {
+				message += ": " + status.Reason
+			}
+
+			response.Header().Set("Retry-After", MAINTENANCE_RETRY_AFTER)
+			writeError(response, req, http.StatusServiceUnavailable, ERR_MAINTENANCE, message)
+			return
+		}
+
+		handler.ServeHTTP(response, req)
+	})
+}
+
+// Are we in maintenance mode?
+func (s *Server) Maintenance() MaintenanceStatus {
+	s.modeLock.RLock()
+	defer s.modeLock.RUnlock()
+
+	return s.maintenance
+}
+
+// Turn maintenance mode on or off. The tracker stops writing to the store
+// while it's on, so the store can be moved from under us. Turning it on
+// waits for updates already being handled to finish, so once it returns
+// nothing more is coming in.
+func (s *Server) SetMaintenance(enabled bool, reason string) MaintenanceStatus {
+	status := s.setMaintenance(enabled, reason)
+	if enabled {
+		s.writeLock.Lock()
+		s.writeLock.Unlock()
+	}
+
+	return status
+}
+
+func (s *Server) setMaintenance(enabled bool, reason string) MaintenanceStatus {
+	s.modeLock.Lock()
+	defer s.modeLock.Unlock()
+
+	if !enabled {
+		reason = ""
+	}
+
+	if enabled != s.maintenance.Enabled {
+		s.maintenance.Since = time.Now().UTC()
+		if enabled {
+			log.Warnf("Entering maintenance mode: %s", reason)
+		} else {
+			log.Warn("Leaving maintenance mode")
+		}
+	}
+	s.maintenance.Enabled = enabled
+	s.maintenance.Reason = reason
+	s.tracker.PausePersistence(enabled)
+
+	return s.maintenance
+}
+
+func (s *Server) maintenanceHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(s.Maintenance())
+	response.Write(message)
+}
+
+// Takes e.g. {"Enabled": true, "Reason": "Moving the store"}
+func (s *Server) maintenanceUpdateHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Enabled *bool
+		Reason  string
+	}
+
+	err := json.NewDecoder(req.Body).Decode(&request)
+	if err != nil {
+		writeError(response, req, http.StatusBadRequest, ERR_INVALID_BODY, "Expected a JSON maintenance status", err.Error())
+		return
+	}
+
+	if request.Enabled == nil {
+		writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, "Enabled must be set")
+		return
+	}
+
+	message, _ := json.Marshal(s.SetMaintenance(*request.Enabled, request.Reason))
+	response.Write(message)
+}
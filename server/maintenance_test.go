@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Maintenance(t *testing.T) {
+	Convey("Maintenance mode", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		server := New(state, WithUIPath(""), WithAdminToken("lusitania"))
+
+		request := func(method string, path string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer lusitania")
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Convey("Needs the admin token", func() {
+			for _, method := range []string{"GET", "POST"} {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(method, "/admin/maintenance", strings.NewReader(`{"Enabled": true}`))
+				server.Handler().ServeHTTP(recorder, req)
+				So(recorder.Code, ShouldEqual, http.StatusUnauthorized)
+			}
+			So(server.Maintenance().Enabled, ShouldBeFalse)
+		})
+
+		Convey("Is off to start with", func() {
+			So(request("POST", "/api/update", "{").Code, ShouldEqual, http.StatusBadRequest)
+
+			var status MaintenanceStatus
+			json.Unmarshal(request("GET", "/admin/maintenance", "").Body.Bytes(), &status)
+			So(status.Enabled, ShouldBeFalse)
+		})
+
+		Convey("When turned on", func() {
+			recorder := request("POST", "/admin/maintenance", `{"Enabled": true, "Reason": "Moving the store"}`)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			var status MaintenanceStatus
+			json.Unmarshal(recorder.Body.Bytes(), &status)
+			So(status.Enabled, ShouldBeTrue)
+			So(status.Reason, ShouldEqual, "Moving the store")
+			So(status.Since.IsZero(), ShouldBeFalse)
+
+			Convey("Turns away updates", func() {
+				recorder := request("POST", "/api/update", "{}")
+
+				var apiError ApiError
+				json.Unmarshal(recorder.Body.Bytes(), &apiError)
+
+				So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(recorder.Header().Get("Retry-After"), ShouldEqual, MAINTENANCE_RETRY_AFTER)
+				So(apiError.Code, ShouldEqual, ERR_MAINTENANCE)
+				So(apiError.Message, ShouldContainSubstring, "Moving the store")
+
+				So(request("DELETE", "/api/v1/silences/1", "").Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("Still serves history and queries", func() {
+				So(request("GET", "/api/state/services", "").Code, ShouldEqual, http.StatusOK)
+				So(request("POST", "/graphql", `{"query": "{ clusters { name } }"}`).Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("Can be turned off again", func() {
+				request("POST", "/admin/maintenance", `{"Enabled": false, "Reason": "Ignored"}`)
+				So(server.Maintenance().Enabled, ShouldBeFalse)
+				So(server.Maintenance().Reason, ShouldBeEmpty)
+				So(request("POST", "/api/update", "{").Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("Waits for updates already being handled when turned on", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := server.withMaintenance(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				close(started)
+				<-release
+			}))
+			go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/update", nil))
+			<-started
+
+			enabled := make(chan struct{})
+			go func() {
+				server.SetMaintenance(true, "Restarting")
+				close(enabled)
+			}()
+
+			select {
+			case <-enabled:
+				t.Error("Didn't wait for the update in flight")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			close(release)
+			<-enabled
+			So(server.Maintenance().Enabled, ShouldBeTrue)
+		})
+
+		Convey("Wants to be told what to do", func() {
+			So(request("POST", "/admin/maintenance", `{"Reason": "Why not"}`).Code, ShouldEqual, http.StatusBadRequest)
+			So(request("POST", "/admin/maintenance", `{`).Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
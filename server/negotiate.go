@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-msgpack/codec"
+)
+
+const (
+	MEDIA_JSON     = "application/json"
+	MEDIA_NDJSON   = "application/x-ndjson"
+	MEDIA_MSGPACK  = "application/msgpack"
+	MEDIA_PROTOBUF = "application/x-protobuf"
+)
+
+// The representations the read endpoints can answer with, preferred first.
+// Protobuf is only on offer for what superside.proto has a message for.
+var MEDIA_TYPES = []string{MEDIA_JSON, MEDIA_NDJSON, MEDIA_MSGPACK, MEDIA_PROTOBUF}
+
+// Other names clients use for the same thing
+var mediaAliases = map[string]string{
+	"application/x-msgpack":   MEDIA_MSGPACK,
+	"application/vnd.msgpack": MEDIA_MSGPACK,
+	"application/ndjson":      MEDIA_NDJSON,
+	"application/jsonl":       MEDIA_NDJSON,
+	"application/protobuf":    MEDIA_PROTOBUF,
+	"application/x-protobuf":  MEDIA_PROTOBUF,
+}
+
+// A media range from an Accept header with its quality
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if alias, ok := mediaAliases[mediaType]; ok {
+			mediaType = alias
+		}
+		if mediaType == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType, quality})
+	}
+
+	return ranges
+}
+
+// How closely the range covers a media type, from 3 for an exact match
+// down to 0 when it doesn't cover it at all
+func (r acceptRange) covers(mediaType string) int {
+	switch {
+	case r.mediaType == mediaType:
+		return 3
+	case r.mediaType == "*/*":
+		return 1
+	case strings.HasSuffix(r.mediaType, "/*") &&
+		strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*")):
+		return 2
+	}
+	return 0
+}
+
+// The quality the client gave a media type, from the most specific range
+// that covers it. Zero if it doesn't want it at all.
+func qualityOf(ranges []acceptRange, mediaType string) float64 {
+	quality := 0.0
+	specificity := 0
+	for _, r := range ranges {
+		if covers := r.covers(mediaType); covers > specificity {
+			specificity = covers
+			quality = r.quality
+		}
+	}
+
+	return quality
+}
+
+// Pick the representation to answer an Accept header with, out of those on
+// offer. JSON when there's no header, false when we have nothing the client
+// will take.
+func negotiate(accept string, offered []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return MEDIA_JSON, true
+	}
+
+	ranges := parseAccept(accept)
+
+	best := ""
+	bestQuality := 0.0
+	for _, mediaType := range offered {
+		if quality := qualityOf(ranges, mediaType); quality > bestQuality {
+			best = mediaType
+			bestQuality = quality
+		}
+	}
+
+	return best, best != ""
+}
+
+// The MEDIA_TYPES we can encode this value in
+func mediaTypesFor(value interface{}) []string {
+	if _, ok := protobufMessage(value); ok {
+		return MEDIA_TYPES
+	}
+
+	var offered []string
+	for _, mediaType := range MEDIA_TYPES {
+		if mediaType != MEDIA_PROTOBUF {
+			offered = append(offered, mediaType)
+		}
+	}
+	return offered
+}
+
+// Encode a value in one of the MEDIA_TYPES. NDJSON puts each element of a
+// slice on its own line. MessagePack mirrors the JSON, field names and all,
+// so clients can switch between them without surprises. Protobuf uses the
+// messages in superside.proto.
+func encodeAs(mediaType string, value interface{}) ([]byte, error) {
+	switch mediaType {
+	case MEDIA_PROTOBUF:
+		data, ok := encodeProtobuf(value)
+		if !ok {
+			return nil, fmt.Errorf("No protobuf message for %T", value)
+		}
+		return data, nil
+
+	case MEDIA_NDJSON:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf) // Encode() adds the newline for us
+
+		list := reflect.ValueOf(value)
+		if list.Kind() != reflect.Slice {
+			err := encoder.Encode(value)
+			return buf.Bytes(), err
+		}
+
+		for i := 0; i < list.Len(); i++ {
+			if err := encoder.Encode(list.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+
+	case MEDIA_MSGPACK:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		var generic interface{}
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+
+		var packed []byte
+		err = codec.NewEncoderBytes(&packed, &codec.MsgpackHandle{}).Encode(withNumbers(generic))
+		return packed, err
+	}
+
+	return json.Marshal(value)
+}
+
+// Turn the json.Numbers from decoding back into integers where they were
+// integers, so MessagePack doesn't send everything as a float or a string
+func withNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		if i, err := typed.Int64(); err == nil {
+			return i
+		}
+		f, _ := typed.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = withNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = withNumbers(item)
+		}
+	}
+
+	return value
+}
+
+// Encode a response body in whatever the request's Accept header asks for
+// and set the Content-Type to match. Writes a 406 and returns false if
+// we can't give the client anything it wants.
+func negotiatedBody(response http.ResponseWriter, req *http.Request, value interface{}) ([]byte, bool) {
+	response.Header().Add("Vary", "Accept")
+
+	offered := mediaTypesFor(value)
+	mediaType, ok := negotiate(req.Header.Get("Accept"), offered)
+	if !ok {
+		writeError(response, req, http.StatusNotAcceptable, ERR_NOT_ACCEPTABLE,
+			"Can only respond with one of: "+strings.Join(offered, ", "), offered,
+		)
+		return nil, false
+	}
+
+	body, err := encodeAs(mediaType, value)
+	if err != nil {
+		writeError(response, req, http.StatusInternalServerError, ERR_INTERNAL, err.Error())
+		return nil, false
+	}
+
+	response.Header().Set("Content-Type", mediaType)
+	return body, true
+}
+
+// Write a response in whatever the request's Accept header asks for
+func writeNegotiated(response http.ResponseWriter, req *http.Request, value interface{}) {
+	if body, ok := negotiatedBody(response, req, value); ok {
+		response.Write(body)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Negotiate(t *testing.T) {
+	Convey("Picking a representation", t, func() {
+		Convey("Defaults to JSON", func() {
+			mediaType, ok := negotiate("", MEDIA_TYPES)
+			So(ok, ShouldBeTrue)
+			So(mediaType, ShouldEqual, MEDIA_JSON)
+
+			mediaType, _ = negotiate("text/html,application/xhtml+xml,*/*;q=0.8", MEDIA_TYPES)
+			So(mediaType, ShouldEqual, MEDIA_JSON)
+		})
+
+		Convey("Honors the client's preference", func() {
+			mediaType, _ := negotiate("application/json;q=0.5, application/x-msgpack", MEDIA_TYPES)
+			So(mediaType, ShouldEqual, MEDIA_MSGPACK)
+
+			mediaType, _ = negotiate("application/x-ndjson", MEDIA_TYPES)
+			So(mediaType, ShouldEqual, MEDIA_NDJSON)
+		})
+
+		Convey("Prefers the most specific range", func() {
+			mediaType, _ := negotiate("application/*, application/json;q=0", MEDIA_TYPES)
+			So(mediaType, ShouldEqual, MEDIA_NDJSON)
+		})
+
+		Convey("Gives up when there's nothing the client will take", func() {
+			_, ok := negotiate("text/csv", MEDIA_TYPES)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Only offers protobuf for what has a message", func() {
+			So(mediaTypesFor([]datatypes.Notification{}), ShouldContain, MEDIA_PROTOBUF)
+			So(mediaTypesFor(map[string]int{}), ShouldNotContain, MEDIA_PROTOBUF)
+
+			_, ok := negotiate("application/x-protobuf", mediaTypesFor(map[string]int{}))
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Encoding a response", t, func() {
+		value := []map[string]interface{}{{"Name": "verdun", "Count": 3}, {"Name": "somme"}}
+
+		Convey("Puts each element on its own line for NDJSON", func() {
+			body, err := encodeAs(MEDIA_NDJSON, value)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, "{\"Count\":3,\"Name\":\"verdun\"}\n{\"Name\":\"somme\"}\n")
+		})
+
+		Convey("Mirrors the JSON for MessagePack", func() {
+			body, err := encodeAs(MEDIA_MSGPACK, value)
+			So(err, ShouldBeNil)
+
+			var decoded []map[string]interface{}
+			handle := &codec.MsgpackHandle{RawToString: true}
+			So(codec.NewDecoderBytes(body, handle).Decode(&decoded), ShouldBeNil)
+
+			So(len(decoded), ShouldEqual, 2)
+			So(decoded[0]["Name"], ShouldEqual, "verdun")
+			So(decoded[0]["Count"], ShouldEqual, 3)
+		})
+
+		Convey("Answers 406 when it can't", func() {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", "application/x-protobuf")
+
+			writeNegotiated(recorder, req, value)
+			So(recorder.Code, ShouldEqual, http.StatusNotAcceptable)
+			So(recorder.Header().Get("Vary"), ShouldEqual, "Accept")
+		})
+	})
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_PEER_TIMEOUT = 30 * time.Second
+)
+
+// Fetch the stored events from another superside, oldest first, so a fresh
+// one can backfill its history from it
+func FetchPeerEvents(peerUrl string, timeout time.Duration) ([]datatypes.Notification, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(peerUrl, "/")+"/api/state/services", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", MEDIA_JSON)
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Peer returned status %d", resp.StatusCode)
+	}
+
+	var notices []datatypes.Notification
+	err = json.NewDecoder(resp.Body).Decode(&notices)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode peer's events: %s", err.Error())
+	}
+
+	return notices, nil
+}
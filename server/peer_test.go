@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FetchPeerEvents(t *testing.T) {
+	Convey("Fetching a peer's events", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		state.Backfill([]datatypes.Notification{{
+			ID:          "1",
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			Event:       &catalog.ChangeEvent{Service: service.Service{Name: "artillery"}},
+			ClusterName: "france",
+			ReceivedAt:  time.Now().UTC(),
+		}})
+		peer := httptest.NewServer(New(state, WithUIPath("")).Handler())
+		defer peer.Close()
+
+		Convey("Gets them as JSON", func() {
+			notices, err := FetchPeerEvents(peer.URL+"/", time.Second)
+			So(err, ShouldBeNil)
+			So(len(notices), ShouldEqual, 1)
+			So(notices[0].ID, ShouldEqual, "1")
+		})
+
+		Convey("Fails on an error status", func() {
+			broken := httptest.NewServer(http.NotFoundHandler())
+			defer broken.Close()
+
+			_, err := FetchPeerEvents(broken.URL, time.Second)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,448 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+)
+
+// Encodes the messages in superside.proto. There are few enough of them
+// that writing the wire format by hand beats vendoring a protobuf runtime
+// and generating code for it.
+
+const (
+	WIRE_VARINT  = 0
+	WIRE_FIXED64 = 1
+	WIRE_BYTES   = 2
+)
+
+// Builds up a message. Like proto3, it leaves out fields with zero values,
+// except for elements of repeated fields.
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) varint(value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	length := binary.PutUvarint(scratch[:], value)
+	w.buf = append(w.buf, scratch[:length]...)
+}
+
+func (w *protoWriter) key(field int, wireType int) {
+	w.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (w *protoWriter) bytes(field int, data []byte) {
+	w.key(field, WIRE_BYTES)
+	w.varint(uint64(len(data)))
+	w.buf = append(w.buf, data...)
+}
+
+func (w *protoWriter) String(field int, value string) {
+	if value != "" {
+		w.bytes(field, []byte(value))
+	}
+}
+
+func (w *protoWriter) Strings(field int, values []string) {
+	for _, value := range values {
+		w.bytes(field, []byte(value))
+	}
+}
+
+func (w *protoWriter) Int(field int, value int64) {
+	if value != 0 {
+		w.key(field, WIRE_VARINT)
+		w.varint(uint64(value))
+	}
+}
+
+func (w *protoWriter) Bool(field int, value bool) {
+	if value {
+		w.key(field, WIRE_VARINT)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+func (w *protoWriter) Double(field int, value float64) {
+	if value != 0 {
+		var scratch [8]byte
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(value))
+		w.key(field, WIRE_FIXED64)
+		w.buf = append(w.buf, scratch[:]...)
+	}
+}
+
+// A nested message, written even when it's empty
+func (w *protoWriter) Message(field int, encode func(*protoWriter)) {
+	var nested protoWriter
+	encode(&nested)
+	w.bytes(field, nested.buf)
+}
+
+// A google.protobuf.Timestamp, left out when it's the zero time
+func (w *protoWriter) Time(field int, value time.Time) {
+	if value.IsZero() {
+		return
+	}
+	w.Message(field, func(w *protoWriter) {
+		w.Int(1, value.Unix())
+		w.Int(2, int64(value.Nanosecond()))
+	})
+}
+
+// A google.protobuf.Duration
+func (w *protoWriter) Duration(field int, value time.Duration) {
+	if value == 0 {
+		return
+	}
+	w.Message(field, func(w *protoWriter) {
+		w.Int(1, int64(value/time.Second))
+		w.Int(2, int64(value%time.Second))
+	})
+}
+
+// A map<string, string>, sorted so the same map always encodes the same
+// way and the ETags stay put
+func (w *protoWriter) StringMap(field int, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		w.Message(field, func(w *protoWriter) {
+			w.String(1, key)
+			w.String(2, value)
+		})
+	}
+}
+
+// A map<string, int64>, sorted like StringMap()
+func (w *protoWriter) IntMap(field int, values map[string]int) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		w.Message(field, func(w *protoWriter) {
+			w.String(1, key)
+			w.Int(2, int64(value))
+		})
+	}
+}
+
+// The protobuf encoding of a value from one of the read endpoints, or false
+// when superside.proto has no message for it
+func encodeProtobuf(value interface{}) ([]byte, bool) {
+	encode, ok := protobufMessage(value)
+	if !ok {
+		return nil, false
+	}
+
+	var w protoWriter
+	encode(&w)
+	return w.buf, true
+}
+
+func protobufMessage(value interface{}) (func(*protoWriter), bool) {
+	switch typed := value.(type) {
+	case []datatypes.Notification:
+		return func(w *protoWriter) {
+			for i := range typed {
+				w.Message(1, notificationProto(&typed[i]))
+			}
+		}, true
+	case ApiEvent:
+		return func(w *protoWriter) {
+			w.Message(1, notificationProto(&typed.Notification))
+			for _, record := range typed.Deliveries {
+				w.Message(2, deliveryProto(record))
+			}
+		}, true
+	case []datatypes.ClusterLastSeen:
+		return func(w *protoWriter) {
+			for _, cluster := range typed {
+				w.Message(1, clusterLastSeenProto(cluster))
+			}
+		}, true
+	case datatypes.ClusterLastSeen:
+		return clusterLastSeenProto(typed), true
+	case *datatypes.ClusterView:
+		return clusterViewProto(typed), true
+	case []datatypes.Incident:
+		return func(w *protoWriter) {
+			for i := range typed {
+				w.Message(1, incidentProto(&typed[i]))
+			}
+		}, true
+	case *datatypes.Incident:
+		return incidentProto(typed), true
+	}
+
+	return nil, false
+}
+
+func notificationProto(notice *datatypes.Notification) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, notice.ID)
+		w.String(2, notice.Type)
+		if notice.Event != nil {
+			w.Message(3, changeEventProto(notice.Event))
+		}
+		w.String(4, notice.ClusterName)
+		w.String(5, notice.OriginalClusterName)
+		w.String(6, notice.Region)
+		w.Strings(7, notice.PossibleImpact)
+		w.Bool(8, notice.Flapping)
+		if notice.Flap != nil {
+			w.Message(9, flapStatusProto(notice.Flap))
+		}
+		w.Bool(10, notice.Suppressed)
+		w.Bool(11, notice.Draining)
+		w.String(12, notice.SilenceID)
+		w.String(13, notice.CorrelationID)
+		w.String(14, notice.Severity)
+		w.StringMap(15, notice.Labels)
+		w.StringMap(16, notice.Links)
+		w.Time(17, notice.ReceivedAt)
+		w.Duration(18, notice.IngestLatency)
+		w.String(19, notice.TimeOffset)
+		w.Duration(20, notice.ClockSkew)
+		for _, annotation := range notice.Annotations {
+			w.Message(21, annotationProto(annotation))
+		}
+		if notice.Ack != nil {
+			w.Message(22, ackProto(notice.Ack))
+		}
+		w.String(23, notice.Source)
+		w.Bool(24, notice.Escalated)
+		for _, digested := range notice.Digest {
+			w.Message(25, notificationProto(digested))
+		}
+		if notice.Report != nil {
+			w.Message(26, reportProto(notice.Report))
+		}
+		if notice.Stale != nil {
+			w.Message(27, staleClusterProto(notice.Stale))
+		}
+		if notice.Heartbeat != nil {
+			w.Message(28, heartbeatProto(notice.Heartbeat))
+		}
+		if notice.Deploy != nil {
+			w.Message(29, versionChangeProto(notice.Deploy))
+		}
+		if notice.Incident != nil {
+			w.Message(30, incidentProto(notice.Incident))
+		}
+		if notice.Anomaly != nil {
+			w.Message(31, anomalyProto(notice.Anomaly))
+		}
+		for _, burst := range notice.Burst {
+			w.Message(32, notificationProto(burst))
+		}
+		if notice.SkewedHost != nil {
+			w.Message(33, skewedHostProto(notice.SkewedHost))
+		}
+	}
+}
+
+func changeEventProto(event *catalog.ChangeEvent) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.Message(1, serviceProto(&event.Service))
+		w.Int(2, int64(event.PreviousStatus))
+		w.Time(3, event.Time)
+	}
+}
+
+func serviceProto(svc *service.Service) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, svc.ID)
+		w.String(2, svc.Name)
+		w.String(3, svc.Image)
+		w.Time(4, svc.Created)
+		w.String(5, svc.Hostname)
+		for _, port := range svc.Ports {
+			w.Message(6, func(w *protoWriter) {
+				w.String(1, port.Type)
+				w.Int(2, port.Port)
+				w.Int(3, port.ServicePort)
+			})
+		}
+		w.Time(7, svc.Updated)
+		w.String(8, svc.ProxyMode)
+		w.Int(9, int64(svc.Status))
+	}
+}
+
+func flapStatusProto(flap *datatypes.FlapStatus) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, flap.ClusterName)
+		w.String(2, flap.Service)
+		w.Int(3, int64(flap.Transitions))
+		w.Duration(4, flap.Window)
+		w.Time(5, flap.Since)
+		w.Time(6, flap.LastTransition)
+	}
+}
+
+func annotationProto(annotation datatypes.Annotation) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, annotation.Author)
+		w.String(2, annotation.Text)
+		w.Time(3, annotation.Time)
+	}
+}
+
+func ackProto(ack *datatypes.Acknowledgement) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, ack.User)
+		w.String(2, ack.Note)
+		w.Time(3, ack.Time)
+		w.Bool(4, ack.Resolved)
+	}
+}
+
+func reportProto(report *datatypes.DigestReport) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, report.Name)
+		w.Time(2, report.Start)
+		w.Time(3, report.End)
+		w.IntMap(4, report.Transitions)
+		for _, count := range report.TopFlapping {
+			w.Message(5, func(w *protoWriter) {
+				w.String(1, count.ClusterName)
+				w.String(2, count.Service)
+				w.Int(3, int64(count.Count))
+			})
+		}
+		for _, outage := range report.LongestOutages {
+			w.Message(6, func(w *protoWriter) {
+				w.String(1, outage.ClusterName)
+				w.String(2, outage.Service)
+				w.String(3, outage.Hostname)
+				w.Time(4, outage.Start)
+				w.Duration(5, outage.Duration)
+				w.Bool(6, outage.Ongoing)
+			})
+		}
+	}
+}
+
+func staleClusterProto(stale *datatypes.StaleCluster) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, stale.ClusterName)
+		w.Time(2, stale.LastSeen)
+		w.Duration(3, stale.Timeout)
+	}
+}
+
+func heartbeatProto(heartbeat *datatypes.Heartbeat) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.Int(1, heartbeat.Sequence)
+		w.Duration(2, heartbeat.Interval)
+	}
+}
+
+func versionChangeProto(change *datatypes.VersionChange) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, change.ClusterName)
+		w.String(2, change.Service)
+		w.String(3, change.Hostname)
+		w.String(4, change.Image)
+		w.String(5, change.Version)
+		w.String(6, change.PreviousVersion)
+		w.Time(7, change.Time)
+	}
+}
+
+func incidentProto(incident *datatypes.Incident) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, incident.ID)
+		w.String(2, incident.ClusterName)
+		w.String(3, incident.Status)
+		w.String(4, incident.Severity)
+		w.Strings(5, incident.Services)
+		w.Strings(6, incident.EventIDs)
+		w.Int(7, int64(incident.Unhealthy))
+		w.Time(8, incident.OpenedAt)
+		w.Time(9, incident.UpdatedAt)
+		w.Time(10, incident.ResolvedAt)
+		if incident.Ack != nil {
+			w.Message(11, ackProto(incident.Ack))
+		}
+	}
+}
+
+func anomalyProto(anomaly *datatypes.Anomaly) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, anomaly.ClusterName)
+		w.Int(2, int64(anomaly.Transitions))
+		w.Double(3, anomaly.Baseline)
+		w.Double(4, anomaly.Factor)
+		w.Duration(5, anomaly.Window)
+		w.IntMap(6, anomaly.Statuses)
+	}
+}
+
+func skewedHostProto(host *datatypes.SkewedHost) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, host.ClusterName)
+		w.String(2, host.Hostname)
+		w.Duration(3, host.Skew)
+		w.Duration(4, host.MaxSkew)
+	}
+}
+
+func deliveryProto(record notify.DeliveryRecord) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, record.Notifier)
+		w.Bool(2, record.Sink)
+		w.Time(3, record.Time)
+		w.Int(4, int64(record.Attempts))
+		w.Bool(5, record.Success)
+		w.String(6, record.Error)
+	}
+}
+
+func clusterLastSeenProto(cluster datatypes.ClusterLastSeen) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, cluster.ClusterName)
+		w.Time(2, cluster.LastSeen)
+		w.Int(3, int64(cluster.EventsLastHour))
+		w.Bool(4, cluster.Silent)
+	}
+}
+
+func clusterViewProto(view *datatypes.ClusterView) func(*protoWriter) {
+	return func(w *protoWriter) {
+		w.String(1, view.ClusterName)
+		w.Strings(2, view.Hosts)
+		for _, svc := range view.Services {
+			w.Message(3, func(w *protoWriter) {
+				w.String(1, svc.Name)
+				for _, instance := range svc.Instances {
+					w.Message(2, func(w *protoWriter) {
+						w.String(1, instance.ID)
+						w.String(2, instance.Hostname)
+						w.String(3, instance.Image)
+						w.String(4, instance.Status)
+						w.Time(5, instance.LastChange)
+					})
+				}
+			})
+		}
+		w.Time(4, view.LastChange)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Split a message into its fields, in order, for checking what we wrote.
+// Varints and fixed64s come back as uint64s, everything else as []byte.
+func protoFields(data []byte) map[int][]interface{} {
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		field := int(key >> 3)
+
+		switch key & 7 {
+		case WIRE_VARINT:
+			value, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[field] = append(fields[field], value)
+		case WIRE_FIXED64:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case WIRE_BYTES:
+			length, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[field] = append(fields[field], data[:length])
+			data = data[length:]
+		}
+	}
+	return fields
+}
+
+func Test_Protobuf(t *testing.T) {
+	Convey("Encoding protobuf", t, func() {
+		Convey("Writes the wire format and leaves out zero values", func() {
+			data, ok := encodeProtobuf(datatypes.ClusterLastSeen{ClusterName: "france", EventsLastHour: 3, Silent: true})
+
+			So(ok, ShouldBeTrue)
+			So(data, ShouldResemble, []byte{0x0a, 6, 'f', 'r', 'a', 'n', 'c', 'e', 0x18, 3, 0x20, 1})
+		})
+
+		Convey("Writes times and durations as the well-known types", func() {
+			somme := time.Date(1916, 7, 1, 7, 30, 0, 500, time.UTC)
+			data, _ := encodeProtobuf([]datatypes.Notification{{
+				ReceivedAt:    somme,
+				IngestLatency: 1500 * time.Millisecond,
+			}})
+
+			notice := protoFields(protoFields(data)[1][0].([]byte))
+
+			receivedAt := protoFields(notice[17][0].([]byte))
+			So(int64(receivedAt[1][0].(uint64)), ShouldEqual, somme.Unix())
+			So(receivedAt[2][0], ShouldEqual, 500)
+
+			latency := protoFields(notice[18][0].([]byte))
+			So(latency[1][0], ShouldEqual, 1)
+			So(latency[2][0], ShouldEqual, 500000000)
+		})
+
+		Convey("Writes the events with their services, labels and payloads", func() {
+			data, _ := encodeProtobuf([]datatypes.Notification{
+				{
+					ID:          "verdun",
+					ClusterName: "france",
+					Event: &catalog.ChangeEvent{
+						Service:        service.Service{Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+						PreviousStatus: service.ALIVE,
+					},
+					Labels:  map[string]string{"tier": "1", "owner": "petain"},
+					Anomaly: &datatypes.Anomaly{ClusterName: "france", Baseline: 2.5},
+				},
+				{ID: "somme"},
+			})
+
+			notices := protoFields(data)[1]
+			So(len(notices), ShouldEqual, 2)
+
+			first := protoFields(notices[0].([]byte))
+			So(string(first[1][0].([]byte)), ShouldEqual, "verdun")
+			So(string(first[4][0].([]byte)), ShouldEqual, "france")
+
+			svc := protoFields(protoFields(first[3][0].([]byte))[1][0].([]byte))
+			So(string(svc[2][0].([]byte)), ShouldEqual, "verdun")
+			So(svc[9][0], ShouldEqual, service.UNHEALTHY)
+
+			So(len(first[15]), ShouldEqual, 2)
+			owner := protoFields(first[15][0].([]byte)) // Sorted by key
+			So(string(owner[1][0].([]byte)), ShouldEqual, "owner")
+			So(string(owner[2][0].([]byte)), ShouldEqual, "petain")
+
+			anomaly := protoFields(first[31][0].([]byte))
+			So(math.Float64frombits(anomaly[3][0].(uint64)), ShouldEqual, 2.5)
+
+			So(string(protoFields(notices[1].([]byte))[1][0].([]byte)), ShouldEqual, "somme")
+		})
+
+		Convey("Encodes the same map the same way every time", func() {
+			labels := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+			first, _ := encodeProtobuf([]datatypes.Notification{{Labels: labels}})
+
+			for i := 0; i < 10; i++ {
+				again, _ := encodeProtobuf([]datatypes.Notification{{Labels: labels}})
+				So(again, ShouldResemble, first)
+			}
+		})
+
+		Convey("Has nothing for values without a message", func() {
+			_, ok := encodeProtobuf(map[string]int{"verdun": 1})
+			So(ok, ShouldBeFalse)
+
+			_, err := encodeAs(MEDIA_PROTOBUF, map[string]int{"verdun": 1})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
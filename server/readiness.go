@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/sinks"
+)
+
+const (
+	READY     = "ready"
+	DEGRADED  = "degraded" // Serving, but a sink isn't taking writes
+	NOT_READY = "not ready"
+
+	// What a broken sink does to readiness, see WithSinks()
+	BROKEN_SINK_DEGRADED  = "degraded"
+	BROKEN_SINK_NOT_READY = "not_ready"
+)
+
+type ApiReadiness struct {
+	Status string
+	Sinks  []sinks.SinkStatus `json:",omitempty"`
+}
+
+// Report these sinks on /readyz. A broken one makes us BROKEN_SINK_DEGRADED,
+// which is still ready, or BROKEN_SINK_NOT_READY, for orchestration that
+// should take us out of rotation or restart us when deliveries fail.
+func WithSinks(brokenSink string, all ...sinks.Sink) Option {
+	return func(s *Server) {
+		s.sinks = all
+		s.brokenSink = brokenSink
+	}
+}
+
+// Work out whether we're ready, and how each sink is doing
+func (s *Server) readiness() ApiReadiness {
+	readiness := ApiReadiness{Status: READY}
+
+	for _, sink := range s.sinks {
+		status := sink.Status()
+		readiness.Sinks = append(readiness.Sinks, status)
+
+		if !status.Healthy {
+			readiness.Status = DEGRADED
+			if s.brokenSink == BROKEN_SINK_NOT_READY {
+				readiness.Status = NOT_READY
+			}
+		}
+	}
+
+	// Shutting down, so new traffic should go elsewhere
+	select {
+	case <-s.draining:
+		readiness.Status = NOT_READY
+	default:
+	}
+
+	return readiness
+}
+
+// The readiness check endpoint. 200 when ready or degraded, 503 when not.
+func (s *Server) readyzHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	readiness := s.readiness()
+	if readiness.Status == NOT_READY {
+		response.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	message, _ := json.Marshal(readiness)
+	response.Write(message)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nitro/superside/sinks"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type stubSink sinks.SinkStatus
+
+func (s *stubSink) Status() sinks.SinkStatus {
+	return sinks.SinkStatus(*s)
+}
+
+func Test_Readiness(t *testing.T) {
+	Convey("Readiness", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		elastic := &stubSink{Name: "Elasticsearch", Healthy: true}
+		influx := &stubSink{Name: "InfluxDB", Healthy: true}
+
+		readyz := func(server *Server) (int, ApiReadiness) {
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+			var readiness ApiReadiness
+			json.Unmarshal(recorder.Body.Bytes(), &readiness)
+			return recorder.Code, readiness
+		}
+
+		Convey("Is ready without any sinks", func() {
+			code, readiness := readyz(New(state, WithUIPath("")))
+			So(code, ShouldEqual, http.StatusOK)
+			So(readiness.Status, ShouldEqual, READY)
+		})
+
+		Convey("Reports each sink", func() {
+			code, readiness := readyz(New(state, WithUIPath(""), WithSinks(BROKEN_SINK_DEGRADED, elastic, influx)))
+			So(code, ShouldEqual, http.StatusOK)
+			So(readiness.Status, ShouldEqual, READY)
+			So(len(readiness.Sinks), ShouldEqual, 2)
+			So(readiness.Sinks[1].Name, ShouldEqual, "InfluxDB")
+		})
+
+		Convey("With a broken sink", func() {
+			influx.Healthy = false
+			influx.LastError = "Connection refused"
+
+			Convey("Is degraded but still ready by default", func() {
+				code, readiness := readyz(New(state, WithUIPath(""), WithSinks(BROKEN_SINK_DEGRADED, elastic, influx)))
+				So(code, ShouldEqual, http.StatusOK)
+				So(readiness.Status, ShouldEqual, DEGRADED)
+				So(readiness.Sinks[1].LastError, ShouldEqual, "Connection refused")
+			})
+
+			Convey("Is not ready when configured that way", func() {
+				code, readiness := readyz(New(state, WithUIPath(""), WithSinks(BROKEN_SINK_NOT_READY, elastic, influx)))
+				So(code, ShouldEqual, http.StatusServiceUnavailable)
+				So(readiness.Status, ShouldEqual, NOT_READY)
+			})
+		})
+
+		Convey("Is not ready while shutting down", func() {
+			server := New(state, WithUIPath(""))
+			close(server.draining)
+
+			code, readiness := readyz(server)
+			So(code, ShouldEqual, http.StatusServiceUnavailable)
+			So(readiness.Status, ShouldEqual, NOT_READY)
+		})
+	})
+}
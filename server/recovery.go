@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/metrics"
+)
+
+// Counts the handler panics we've recovered from
+var Panics = metrics.NewCounterVec(
+	"superside_http_panics_total",
+	"Panics recovered from in HTTP handlers, by request method",
+	"method",
+)
+
+// Keeps track of whether the response has started, so we know if there's
+// still time to send an error. Passes through flushing and hijacking for
+// the streaming and websocket endpoints.
+type trackingWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(data []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *trackingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.started = true
+		flusher.Flush()
+	}
+}
+
+func (w *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Connection can't be hijacked")
+	}
+
+	w.started = true
+	return hijacker.Hijack()
+}
+
+// Turn a panic in a handler into a logged stack trace and a 500, rather
+// than a dropped connection. Anything the handler started in its own
+// goroutines is on its own.
+func withRecovery(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		tracking := &trackingWriter{ResponseWriter: response}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			Panics.Inc(req.Method)
+			log.WithFields(log.Fields{
+				"request_id": requestIDFor(req),
+				"method":     req.Method,
+				"path":       req.URL.Path,
+				"remote":     req.RemoteAddr,
+			}).Errorf("Panic in HTTP handler: %v\n%s", recovered, debug.Stack())
+
+			// Too late to change the status, the client will see a short response
+			if tracking.started {
+				return
+			}
+
+			writeError(tracking, req, http.StatusInternalServerError, ERR_INTERNAL, "Internal server error")
+		}()
+
+		handler.ServeHTTP(tracking, req)
+	})
+}
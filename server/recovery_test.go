@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Recovery(t *testing.T) {
+	Convey("Recovering from handler panics", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		Convey("Returns a 500 envelope and counts the panic", func() {
+			before := Panics.Get("GET")
+			handler := withRequestIDs(withRecovery(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+				panic("Gas!")
+			})))
+
+			recorder := httptest.NewRecorder()
+			So(func() { handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil)) }, ShouldNotPanic)
+
+			var apiError ApiError
+			json.Unmarshal(recorder.Body.Bytes(), &apiError)
+
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			So(apiError.Code, ShouldEqual, ERR_INTERNAL)
+			So(apiError.RequestID, ShouldNotBeEmpty)
+			So(Panics.Get("GET"), ShouldEqual, before+1)
+		})
+
+		Convey("Leaves a response that's already started alone", func() {
+			handler := withRecovery(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+				response.Write([]byte("Over the top"))
+				panic("Gas!")
+			}))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldEqual, "Over the top")
+		})
+
+		Convey("Still lets handlers flush", func() {
+			handler := withRecovery(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+				_, ok := response.(http.Flusher)
+				So(ok, ShouldBeTrue)
+				_, ok = response.(http.Hijacker)
+				So(ok, ShouldBeTrue)
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	LISTENER_FD_ENV       = "SUPERSIDE_LISTENER_FD"
+	READY_FD_ENV          = "SUPERSIDE_READY_FD"
+	RESTART_READY_TIMEOUT = 1 * time.Minute
+	SHUTDOWN_TIMEOUT      = 30 * time.Second
+)
+
+// Listen on the socket our parent handed us in a restart, or on our own
+// address if we weren't started that way
+func (s *Server) listen() (net.Listener, error) {
+	fdStr := os.Getenv(LISTENER_FD_ENV)
+	if fdStr == "" {
+		return net.Listen("tcp", fmt.Sprintf("%s:%d", s.ListenIP, s.ListenPort))
+	}
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", LISTENER_FD_ENV, err.Error())
+	}
+
+	file := os.NewFile(uintptr(fd), "listener")
+	defer file.Close() // FileListener dups it
+
+	// Don't pass it on to any of our own children by accident
+	os.Unsetenv(LISTENER_FD_ENV)
+
+	return net.FileListener(file)
+}
+
+// Tell the parent that restarted us that we're serving, so it can go
+func signalReady() {
+	fdStr := os.Getenv(READY_FD_ENV)
+	if fdStr == "" {
+		return
+	}
+	os.Unsetenv(READY_FD_ENV)
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		log.Errorf("Invalid %s: %s", READY_FD_ENV, err.Error())
+		return
+	}
+
+	pipe := os.NewFile(uintptr(fd), "ready")
+	pipe.Write([]byte{1})
+	pipe.Close()
+}
+
+// Start a fresh copy of the running binary, with the same arguments, and
+// hand it our listening socket. Returns once the new process is serving,
+// at which point the caller should Shutdown() and exit. Connections that
+// come in meanwhile queue up on the socket and go to whichever of us
+// accepts them first, so none are refused. If the new process doesn't come
+// up, it's killed and we carry on as we were.
+func (s *Server) Restart() error {
+	s.serveLock.Lock()
+	listener := s.listener
+	s.serveLock.Unlock()
+
+	if listener == nil {
+		return errors.New("Not serving yet")
+	}
+
+	tcpListener, ok := listener.(*net.TCPListener)
+	if !ok {
+		return errors.New("Can only hand over TCP listeners")
+	}
+
+	listenerFile, err := tcpListener.File()
+	if err != nil {
+		return err
+	}
+	defer listenerFile.Close()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyWriter.Close()
+		return err
+	}
+
+	// ExtraFiles start at fd 3
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{listenerFile, readyWriter}
+	cmd.Env = append(os.Environ(), LISTENER_FD_ENV+"=3", READY_FD_ENV+"=4")
+
+	err = cmd.Start()
+	readyWriter.Close() // Only the child's copy should be open now
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyReader.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(RESTART_READY_TIMEOUT):
+		err = errors.New("Timed out waiting for it to start")
+	}
+
+	if err != nil {
+		// The pipe closes without a byte if the child dies first
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("New process didn't come up: %s", err.Error())
+	}
+
+	log.Infof("Handed over to new process %d", cmd.Process.Pid)
+	cmd.Process.Release()
+	return nil
+}
+
+// Stop accepting connections and wait for requests in flight to finish, or
+// for the context to be done. Websocket clients are told we're going away,
+// so they reconnect, to the new process if we're restarting.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+
+	s.serveLock.Lock()
+	httpServer := s.httpServer
+	s.serveLock.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+
+	err := httpServer.Shutdown(ctx)
+
+	// Shutdown() doesn't know about hijacked connections, so wait for the
+	// websockets separately
+	done := make(chan struct{})
+	go func() {
+		s.sockets.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	return err
+}
+
+// Say goodbye to a websocket client when we're shutting down
+func goingAway(conn *websocket.Conn) {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server shutting down")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Restart(t *testing.T) {
+	Convey("Handing over and shutting down", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		server := New(state, WithUIPath(""))
+
+		Convey("Listens on a socket handed down to us", func() {
+			inherited, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer inherited.Close()
+
+			file, err := inherited.(*net.TCPListener).File()
+			So(err, ShouldBeNil)
+
+			// listen() closes the fd it's given, so it needs one of its own
+			fd, err := syscall.Dup(int(file.Fd()))
+			So(err, ShouldBeNil)
+			file.Close()
+
+			os.Setenv(LISTENER_FD_ENV, strconv.Itoa(fd))
+			defer os.Unsetenv(LISTENER_FD_ENV)
+
+			listener, err := server.listen()
+			So(err, ShouldBeNil)
+			defer listener.Close()
+
+			So(listener.Addr().String(), ShouldEqual, inherited.Addr().String())
+			So(os.Getenv(LISTENER_FD_ENV), ShouldBeEmpty)
+		})
+
+		Convey("Won't restart before it's serving", func() {
+			So(server.Restart(), ShouldNotBeNil)
+		})
+
+		Convey("Tells websocket clients it's going away", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+
+			served := make(chan error, 1)
+			go func() { served <- server.Serve(listener) }()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/listen", nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			So(server.Shutdown(ctx), ShouldBeNil)
+
+			_, _, err = conn.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+			So(<-served, ShouldEqual, http.ErrServerClosed)
+		})
+	})
+
+	Convey("Running until the context is done", t, func() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		// Two at once, each with its own tracker
+		ctx, cancel := context.WithCancel(context.Background())
+		var servers []*Server
+		var results []chan error
+		for i := 0; i < 2; i++ {
+			state := tracker.NewTracker(10, &store.NoopStore{})
+			server := New(state, WithUIPath(""), WithListenAddress("127.0.0.1", 0))
+			servers = append(servers, server)
+
+			result := make(chan error, 1)
+			go func() { result <- server.Run(ctx) }()
+			results = append(results, result)
+		}
+
+		for _, server := range servers {
+			for server.Addr() == nil {
+				time.Sleep(time.Millisecond)
+			}
+
+			response, err := http.Get("http://" + server.Addr().String() + "/health")
+			So(err, ShouldBeNil)
+			response.Body.Close()
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+		}
+		So(servers[0].Addr().String(), ShouldNotEqual, servers[1].Addr().String())
+
+		cancel()
+		for _, result := range results {
+			So(<-result, ShouldBeNil)
+		}
+	})
+
+	Convey("Shutting down before serving", t, func() {
+		server := New(tracker.NewTracker(10, &store.NoopStore{}), WithUIPath(""))
+		So(server.Shutdown(context.Background()), ShouldBeNil)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		So(server.Serve(listener), ShouldEqual, http.ErrServerClosed)
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	RUNTIME_RECENT_PAUSES = 10 // GC pauses to report, newest first
+)
+
+// Memory, goroutines and listeners, for tooling that doesn't scrape
+// Prometheus. Durations are in nanoseconds.
+type ApiRuntime struct {
+	HeapInuse      uint64
+	HeapAlloc      uint64
+	HeapObjects    uint64
+	Goroutines     int
+	NumGC          uint32
+	LastGC         time.Time
+	GCPauseTotal   time.Duration
+	RecentGCPauses []time.Duration
+	Tracker        tracker.Vars
+	Listeners      tracker.ListenerDepths
+}
+
+func runtimeStats(state *tracker.Tracker) ApiRuntime {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := ApiRuntime{
+		HeapInuse:    mem.HeapInuse,
+		HeapAlloc:    mem.HeapAlloc,
+		HeapObjects:  mem.HeapObjects,
+		Goroutines:   runtime.NumGoroutine(),
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		Tracker:      state.Vars(),
+		Listeners:    state.ListenerDepths(),
+	}
+
+	if mem.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).UTC()
+	}
+
+	// PauseNs is a ring buffer with the newest at (NumGC+255)%256
+	for i := uint32(0); i < mem.NumGC && i < RUNTIME_RECENT_PAUSES; i++ {
+		pause := mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))]
+		stats.RecentGCPauses = append(stats.RecentGCPauses, time.Duration(pause))
+	}
+
+	return stats
+}
+
+// Runtime stats as JSON, so we can watch for leaking listeners
+func (s *Server) runtimeHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	response.Header().Set("Content-Type", "application/json")
+
+	message, _ := json.Marshal(runtimeStats(s.tracker))
+	response.Write(message)
+}
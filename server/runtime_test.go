@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_RuntimeHandler(t *testing.T) {
+	Convey("The runtime endpoint", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		server := New(state, WithUIPath(""), WithAdminToken("lusitania"))
+
+		listener := state.GetSvcEventsListener()
+		defer state.RemoveSvcEventsListener(listener)
+		runtime.GC()
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/runtime", nil)
+		req.Header.Set("Authorization", "Bearer lusitania")
+		server.Handler().ServeHTTP(recorder, req)
+
+		var stats ApiRuntime
+		So(recorder.Code, ShouldEqual, http.StatusOK)
+		So(json.Unmarshal(recorder.Body.Bytes(), &stats), ShouldBeNil)
+
+		Convey("Reports memory and goroutines", func() {
+			So(stats.HeapInuse, ShouldBeGreaterThan, 0)
+			So(stats.Goroutines, ShouldBeGreaterThan, 0)
+			So(stats.NumGC, ShouldBeGreaterThan, 0)
+			So(len(stats.RecentGCPauses), ShouldBeGreaterThan, 0)
+			So(stats.LastGC.IsZero(), ShouldBeFalse)
+		})
+
+		Convey("Needs the admin token", func() {
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/runtime", nil))
+			So(recorder.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Reports the listeners and their depths", func() {
+			So(stats.Tracker.SvcEventListeners, ShouldEqual, 1)
+			So(stats.Listeners.SvcEvents, ShouldResemble, []int{0})
+			So(stats.Tracker.ChannelCapacity, ShouldEqual, tracker.CHANNEL_BUFFER_SIZE)
+		})
+	})
+}
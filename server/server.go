@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"expvar"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/alertevents"
+	"github.com/nitro/superside/metrics"
+	"github.com/nitro/superside/notify"
+	"github.com/nitro/superside/sinks"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	DEFAULT_LISTEN_IP   = "0.0.0.0"
+	DEFAULT_LISTEN_PORT = 7779
+	DEFAULT_UI_PATH     = "public/app"
+)
+
+// The Superside HTTP API and UI, serving the state held by a Tracker. Other
+// programs can embed Superside by building their own Tracker and Server.
+type Server struct {
+	ListenIP      string
+	ListenPort    int
+	UIPath        string // Where the static UI files live, "" to not serve them
+	basePath      string // Optional, the URL prefix we're mounted under
+	assets        *assetIndex
+	tracker       *tracker.Tracker
+	subscriptions *notify.Subscriptions // Optional
+	notifiers     *notify.Registry      // Optional
+	retries       *notify.RetryQueue    // Optional
+	adminToken    string                // Optional, protects /admin and the subscriptions
+	ackKey        []byte                // Optional, signs /api/update acknowledgements
+	idempotency   *IdempotencyCache     // Optional, remembers Idempotency-Keys on /api/update
+	alertLatch    *alertevents.Latch    // Drops alerts Alertmanager sends again unchanged
+	sinks         []sinks.Sink          // Optional, reported on /readyz
+	brokenSink    string                // BROKEN_SINK_DEGRADED or BROKEN_SINK_NOT_READY
+	statusPage    *StatusPage           // Optional, the public /status page
+	timezone      *time.Location        // The UI shows times in, UTC by default
+	router        *httprouter.Router
+	schema        graphql.Schema
+	upgrader      *websocket.Upgrader
+	maintenance   MaintenanceStatus // Guarded by modeLock
+	modeLock      sync.RWMutex
+	writeLock     sync.RWMutex // Read locked by requests that change state
+	listener      net.Listener
+	httpServer    *http.Server
+	serveLock     sync.Mutex
+	draining      chan struct{} // Closed on Shutdown()
+	drainOnce     sync.Once
+	sockets       sync.WaitGroup // Websocket handlers still running
+}
+
+// Configures a Server in New()
+type Option func(*Server)
+
+// Require this Bearer token on /admin and the subscriptions API, which are off
+// without one
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// Answer /api/update with a datatypes.UpdateAck signed with this key,
+// rather than a plain "OK". An empty key leaves them off.
+func WithUpdateAcks(key string) Option {
+	return func(s *Server) {
+		s.ackKey = []byte(key)
+	}
+}
+
+// Remember Idempotency-Keys on /api/update in this cache
+func WithIdempotency(cache *IdempotencyCache) Option {
+	return func(s *Server) {
+		s.idempotency = cache
+	}
+}
+
+// Size the websocket buffers and allow cross-origin websockets from these
+// origins, on top of the one the UI is served from
+func WithWebsocket(readBuffer int, writeBuffer int, allowedOrigins []string) Option {
+	return func(s *Server) {
+		s.upgrader = newUpgrader(readBuffer, writeBuffer, allowedOrigins)
+	}
+}
+
+// Listen on this IP and port
+func WithListenAddress(ip string, port int) Option {
+	return func(s *Server) {
+		s.ListenIP = ip
+		s.ListenPort = port
+	}
+}
+
+// Serve the UI from this directory, or don't serve it at all if it's ""
+func WithUIPath(path string) Option {
+	return func(s *Server) {
+		s.UIPath = path
+	}
+}
+
+// Serve the webhook subscriptions API from these subscriptions. Since they
+// make us call out to any URL, it needs the admin token too.
+func WithSubscriptions(subscriptions *notify.Subscriptions) Option {
+	return func(s *Server) {
+		s.subscriptions = subscriptions
+	}
+}
+
+// Report the delivery status of these notifiers on /health
+func WithNotifiers(registry *notify.Registry) Option {
+	return func(s *Server) {
+		s.notifiers = registry
+	}
+}
+
+// Serve the dead-letter admin API from this retry queue
+func WithRetryQueue(retries *notify.RetryQueue) Option {
+	return func(s *Server) {
+		s.retries = retries
+	}
+}
+
+func New(state *tracker.Tracker, opts ...Option) *Server {
+	server := &Server{
+		ListenIP:   DEFAULT_LISTEN_IP,
+		ListenPort: DEFAULT_LISTEN_PORT,
+		UIPath:     DEFAULT_UI_PATH,
+		tracker:    state,
+		draining:   make(chan struct{}),
+		upgrader:   newUpgrader(DEFAULT_WS_READ_BUFFER, DEFAULT_WS_WRITE_BUFFER, nil),
+		brokenSink: BROKEN_SINK_DEGRADED,
+		timezone:   time.UTC,
+		idempotency: NewIdempotencyCache(
+			DEFAULT_IDEMPOTENCY_SIZE, DEFAULT_IDEMPOTENCY_TTL, nil,
+		),
+		alertLatch: alertevents.NewLatch(),
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	// The schema is fixed, so failing to build it is a bug rather than
+	// something the caller can do anything about
+	schema, err := server.buildSchema()
+	if err != nil {
+		panic("Invalid GraphQL schema: " + err.Error())
+	}
+	server.schema = schema
+	server.router = server.routes()
+
+	return server
+}
+
+func (s *Server) routes() *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/", s.uiRedirectHandler)
+	router.POST("/api/update", s.idempotent(s.updateHandler))
+	router.POST("/api/v1/ingest/alertmanager", s.alertmanagerHandler)
+	router.POST("/api/v1/ingest/cloudevents", s.cloudEventsHandler)
+	router.GET("/api/state/services", s.servicesHandler)
+	router.GET("/api/state/deployments", s.deploymentsHandler)
+	router.GET("/api/state/flapping", s.flappingHandler)
+	router.GET("/api/dependencies", s.dependenciesHandler)
+	router.POST("/api/dependencies", s.dependencyUpdateHandler)
+	router.GET("/api/v1/labels", s.labelsHandler)
+	router.GET("/api/v1/labels/:service", s.serviceLabelsHandler)
+	router.PUT("/api/v1/labels/:service", s.serviceLabelsUpdateHandler)
+	router.DELETE("/api/v1/labels/:service", s.serviceLabelsDeleteHandler)
+	router.GET("/impact", s.impactHandler)
+	router.GET("/regions", s.regionsHandler)
+	router.GET("/deploys", s.versionChangesHandler)
+	router.GET("/draining", s.drainingHandler)
+	router.GET("/api/v1/events/:id", s.eventHandler)
+	router.GET("/api/v1/events/:id/deliveries", s.deliveriesHandler)
+	router.POST("/api/v1/events/:id/annotations", s.annotationHandler)
+	router.POST("/api/v1/events/:id/ack", s.makeAckHandler(false))
+	router.POST("/api/v1/events/:id/resolve", s.makeAckHandler(true))
+	router.GET("/api/v1/state.csv", s.servicesCsvHandler)
+	router.GET("/api/v1/state.ndjson", s.servicesNdjsonHandler)
+	router.GET("/api/v1/rollups", s.rollupsHandler)
+	router.POST("/api/v1/markers", s.markerHandler)
+	router.GET("/api/v1/search", s.searchHandler)
+	router.GET("/api/v1/snapshot", s.snapshotHandler)
+	router.GET("/api/v1/clusters/:name", s.clusterLastSeenHandler)
+	router.GET("/api/v1/clusters/:name/current", s.clusterCurrentHandler)
+	router.GET("/api/v1/stats", s.statsHandler)
+	router.GET("/api/v1/ui/settings", s.displaySettingsHandler)
+	router.GET("/api/v1/hosts", s.hostsHandler)
+	router.GET("/api/v1/hosts/:hostname/events", s.hostEventsHandler)
+	router.GET("/api/v1/incidents", s.incidentsHandler)
+	router.GET("/api/v1/incidents.ics", s.incidentsIcsHandler)
+	router.GET("/api/v1/incidents/:id", s.incidentHandler)
+	router.POST("/api/v1/incidents/:id/ack", s.makeIncidentAckHandler(false))
+	router.POST("/api/v1/incidents/:id/resolve", s.makeIncidentAckHandler(true))
+	router.GET("/api/v1/silences", s.silencesHandler)
+	router.POST("/api/v1/silences", s.silenceCreateHandler)
+	router.DELETE("/api/v1/silences/:id", s.silenceDeleteHandler)
+	router.GET("/api/v1/subscriptions", s.requireAdmin(s.subscriptionsHandler))
+	router.POST("/api/v1/subscriptions", s.requireAdmin(s.subscriptionCreateHandler))
+	router.DELETE("/api/v1/subscriptions/:id", s.requireAdmin(s.subscriptionDeleteHandler))
+	router.GET("/graphql", s.graphqlHandler)
+	router.POST("/graphql", s.graphqlHandler)
+	router.GET("/graphql/subscriptions", s.graphqlSubscriptionHandler)
+	router.GET("/admin/dlq", s.requireAdmin(s.deadLettersHandler))
+	router.POST("/admin/dlq", s.requireAdmin(s.redriveHandler))
+	router.POST("/admin/dlq/:id", s.requireAdmin(s.redriveHandler))
+	router.DELETE("/admin/dlq/:id", s.requireAdmin(s.discardHandler))
+	router.GET("/admin/maintenance", s.requireAdmin(s.maintenanceHandler))
+	router.POST("/admin/maintenance", s.requireAdmin(s.maintenanceUpdateHandler))
+	router.POST("/admin/inject", s.requireAdmin(s.injectHandler))
+	router.GET("/admin/runtime", s.requireAdmin(s.runtimeHandler))
+	router.GET("/health", s.healthHandler)
+	router.GET("/readyz", s.readyzHandler)
+	router.GET("/status", s.statusHandler)
+	router.GET("/status.json", s.statusJsonHandler)
+	router.GET("/listen", s.listenHandler)
+	router.GET("/listen/:cluster", s.listenHandler)
+	router.Handler("GET", "/metrics", metrics.DefaultRegistry)
+	router.Handler("GET", "/debug/vars", expvar.Handler())
+
+	if s.UIPath != "" {
+		assets, err := loadAssets(s.UIPath)
+		if err != nil {
+			log.Warnf("Serving the UI without fingerprinting: %s", err.Error())
+			router.ServeFiles("/ui/*filepath", http.Dir(s.UIPath))
+		} else {
+			s.assets = assets
+			router.GET("/ui/*filepath", s.assetsHandler)
+		}
+	}
+
+	return router
+}
+
+// The router with every endpoint on it, for embedding in another server
+func (s *Server) Handler() http.Handler {
+	return s.withBasePath(withRequestIDs(withRecovery(s.withMaintenance(withJSONOptions(s.router)))))
+}
+
+// Start the HTTP server and begin handling requests. This is a
+// blocking call, which returns http.ErrServerClosed after Shutdown().
+func (s *Server) ListenAndServe() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Handle requests on a listener we already have. Blocks like
+// ListenAndServe().
+func (s *Server) Serve(listener net.Listener) error {
+	httpServer := &http.Server{Handler: handlers.LoggingHandler(os.Stdout, s.Handler())}
+
+	s.serveLock.Lock()
+	select {
+	case <-s.draining:
+		// Shut down before we got going
+		s.serveLock.Unlock()
+		listener.Close()
+		return http.ErrServerClosed
+	default:
+	}
+	s.listener = listener
+	s.httpServer = httpServer
+	s.serveLock.Unlock()
+
+	log.Infof("Starting up on %s", listener.Addr().String())
+	signalReady()
+
+	return httpServer.Serve(listener)
+}
+
+// Serve until the context is done, then shut down gracefully, waiting up
+// to SHUTDOWN_TIMEOUT for requests in flight, and stop the tracker. Returns
+// nil after a clean shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listener) }()
+
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	err = s.Shutdown(shutdownCtx)
+	if serveErr := <-served; err == nil && serveErr != http.ErrServerClosed {
+		err = serveErr
+	}
+
+	s.tracker.Stop()
+
+	return err
+}
+
+// The address we're serving on, or nil if we aren't yet. Handy with a
+// ListenPort of 0.
+func (s *Server) Addr() net.Addr {
+	s.serveLock.Lock()
+	defer s.serveLock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
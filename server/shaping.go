@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Parse a "fields" query parameter like "Event.Service.Name,ClusterName"
+// into the paths to keep
+func parseFields(fields string) ([][]string, error) {
+	var paths [][]string
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		path := strings.Split(field, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, errors.New("Invalid field: " + field)
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// Keep only the fields on the paths. Lists have the paths applied to each
+// element, so they work on the endpoints that return many events too.
+// False when nothing on the paths is there.
+func selectFields(value interface{}, paths [][]string) (interface{}, bool) {
+	switch typed := value.(type) {
+	case []interface{}:
+		selected := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			if kept, ok := selectFields(item, paths); ok {
+				selected = append(selected, kept)
+			} else {
+				selected = append(selected, map[string]interface{}{})
+			}
+		}
+		return selected, true
+
+	case map[string]interface{}:
+		whole := make(map[string]bool)
+		nested := make(map[string][][]string)
+		for _, path := range paths {
+			if len(path) == 1 {
+				whole[path[0]] = true
+			} else {
+				nested[path[0]] = append(nested[path[0]], path[1:])
+			}
+		}
+
+		selected := make(map[string]interface{})
+		for key, item := range typed {
+			if whole[key] {
+				selected[key] = item
+			} else if rest, ok := nested[key]; ok {
+				if kept, ok := selectFields(item, rest); ok {
+					selected[key] = kept
+				}
+			}
+		}
+		return selected, len(selected) > 0
+	}
+
+	return nil, false
+}
+
+// Buffers JSON responses so withJSONOptions can rework them. Anything else,
+// like streams and websockets, goes straight through.
+type shapingWriter struct {
+	http.ResponseWriter
+	decided   bool
+	buffering bool
+	status    int
+	buf       bytes.Buffer
+}
+
+func (w *shapingWriter) decide() {
+	if w.decided {
+		return
+	}
+
+	w.decided = true
+	w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), MEDIA_JSON)
+}
+
+func (w *shapingWriter) WriteHeader(status int) {
+	w.decide()
+	if w.buffering {
+		w.status = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *shapingWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *shapingWriter) Flush() {
+	w.decide()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok && !w.buffering {
+		flusher.Flush()
+	}
+}
+
+func (w *shapingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Connection can't be hijacked")
+	}
+
+	return hijacker.Hijack()
+}
+
+// Rework the buffered body. Errors keep all their fields.
+func (w *shapingWriter) shaped(pretty bool, paths [][]string) []byte {
+	body := w.buf.Bytes()
+
+	if len(paths) > 0 && w.status < http.StatusMultipleChoices {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&value); err == nil {
+			value, _ = selectFields(value, paths)
+			if value == nil {
+				value = map[string]interface{}{}
+			}
+			if selected, err := json.Marshal(value); err == nil {
+				body = selected
+			}
+		}
+	}
+
+	if pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, body, "", "  "); err == nil {
+			buf.WriteByte('\n')
+			body = buf.Bytes()
+		}
+	}
+
+	return body
+}
+
+// Support "pretty" and "fields" query parameters on the read endpoints,
+// for people using curl and for clients that only want a few fields
+func withJSONOptions(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if (req.Method != "GET" && req.Method != "HEAD") ||
+			(query.Get("pretty") == "" && query.Get("fields") == "") {
+			handler.ServeHTTP(response, req)
+			return
+		}
+
+		pretty, _ := strconv.ParseBool(query.Get("pretty"))
+
+		paths, err := parseFields(query.Get("fields"))
+		if err != nil {
+			writeError(response, req, http.StatusBadRequest, ERR_BAD_REQUEST, err.Error())
+			return
+		}
+
+		writer := &shapingWriter{ResponseWriter: response, status: http.StatusOK}
+		handler.ServeHTTP(writer, req)
+
+		if !writer.buffering {
+			return
+		}
+
+		body := writer.shaped(pretty, paths)
+		response.Header().Del("Content-Length")
+		response.WriteHeader(writer.status)
+		response.Write(body)
+	})
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_JSONOptions(t *testing.T) {
+	Convey("The JSON response options", t, func() {
+		events := `[{"ClusterName":"france","Event":{"Status":1,"Time":"1916-02-21T07:15:00Z",` +
+			`"Service":{"Name":"verdun","Hostname":"meuse"}}},{"ClusterName":"belgium"}]`
+
+		handler := withJSONOptions(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/events":
+				response.Header().Set("Content-Type", "application/json")
+				response.Write([]byte(events))
+			case "/missing":
+				writeError(response, req, http.StatusNotFound, ERR_NOT_FOUND, "No such thing")
+			default:
+				response.Header().Set("Content-Type", MEDIA_NDJSON)
+				response.Write([]byte("{\"A\":1}\n"))
+			}
+		}))
+
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+
+		Convey("Leave responses alone when not asked", func() {
+			So(get("/events").Body.String(), ShouldEqual, events)
+		})
+
+		Convey("Pretty print", func() {
+			body := get("/events?pretty=1").Body.String()
+			So(body, ShouldStartWith, "[\n  {\n    \"ClusterName\": \"france\",")
+			So(body, ShouldEndWith, "]\n")
+		})
+
+		Convey("Select fields from each element", func() {
+			recorder := get("/events?fields=Event.Service.Name,Event.Status,ClusterName")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldEqual,
+				`[{"ClusterName":"france","Event":{"Service":{"Name":"verdun"},"Status":1}},{"ClusterName":"belgium"}]`,
+			)
+		})
+
+		Convey("Combine the two", func() {
+			body := get("/events?fields=ClusterName&pretty=true").Body.String()
+			So(body, ShouldContainSubstring, "  {\n    \"ClusterName\": \"belgium\"\n  }")
+		})
+
+		Convey("Leave errors whole", func() {
+			recorder := get("/missing?fields=ClusterName")
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Body.String(), ShouldContainSubstring, "No such thing")
+		})
+
+		Convey("Pass other formats through", func() {
+			So(get("/stream?pretty=1").Body.String(), ShouldEqual, "{\"A\":1}\n")
+		})
+
+		Convey("Reject bad field lists", func() {
+			So(get("/events?fields=Event..Status").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Work on the real endpoints", func() {
+			server := New(tracker.NewTracker(10, &store.NoopStore{}), WithUIPath(""))
+
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/health?fields=Message", nil))
+			So(strings.TrimSpace(recorder.Body.String()), ShouldEqual, `{"Message":"Healthy!"}`)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	DEFAULT_STATUS_TITLE   = "Service status"
+	DEFAULT_STATUS_MAX_AGE = 30 * time.Second
+)
+
+// A public summary of how some services are doing, at /status and
+// /status.json
+type StatusPage struct {
+	Title    string
+	Services []string // In the order they're shown
+	MaxAge   time.Duration
+}
+
+// What the status page template gets
+type statusPageData struct {
+	Title string
+	*tracker.PublicStatus
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"timestamp": func(at time.Time) string {
+		if at.IsZero() {
+			return "never"
+		}
+		return at.UTC().Format("2006-01-02 15:04 MST")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; color: #333; }
+.banner { padding: 1em; border-radius: 4px; color: #fff; font-weight: bold; }
+li { display: flex; justify-content: space-between; padding: 0.75em 0; border-bottom: 1px solid #eee; }
+ul { list-style: none; padding: 0; }
+.operational { background: #2e9e5b; } .degraded { background: #e0a800; }
+.outage { background: #d9363e; } .unknown { background: #999; }
+li span.status { color: #fff; padding: 0 0.5em; border-radius: 4px; }
+footer { color: #999; font-size: small; margin-top: 1em; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<div class="banner {{ .Status }}">{{ if eq .Status "operational" }}All systems operational{{ else if eq .Status "degraded" }}Some systems degraded{{ else }}Major outage{{ end }}</div>
+<ul>
+{{ range .Services }}<li><span>{{ .Name }}</span><span class="status {{ .Status }}">{{ .Status }}</span></li>
+{{ end }}</ul>
+<footer>Last change {{ timestamp .UpdatedAt }}</footer>
+</body>
+</html>
+`))
+
+// Show a status page for these services. No services turns it off.
+func WithStatusPage(title string, services []string, maxAge time.Duration) Option {
+	return func(s *Server) {
+		if len(services) == 0 {
+			s.statusPage = nil
+			return
+		}
+
+		if title == "" {
+			title = DEFAULT_STATUS_TITLE
+		}
+		if maxAge == 0 {
+			maxAge = DEFAULT_STATUS_MAX_AGE
+		}
+		s.statusPage = &StatusPage{Title: title, Services: services, MaxAge: maxAge}
+	}
+}
+
+func (s *Server) statusPageDisabled(response http.ResponseWriter, req *http.Request) bool {
+	if s.statusPage != nil {
+		return false
+	}
+
+	writeError(response, req, http.StatusNotFound, ERR_NOT_ENABLED, "No status page is configured")
+	return true
+}
+
+// Let browsers and CDNs keep the page for a while, as it's public and may
+// get a lot of traffic during an outage
+func (s *Server) writeStatus(response http.ResponseWriter, req *http.Request, body []byte, updatedAt time.Time) {
+	response.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.statusPage.MaxAge.Seconds())))
+	writeConditional(response, req, body, updatedAt)
+}
+
+// The status page as HTML
+func (s *Server) statusHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	if s.statusPageDisabled(response, req) {
+		return
+	}
+
+	status := s.tracker.GetPublicStatus(s.statusPage.Services)
+
+	var buf bytes.Buffer
+	err := statusTemplate.Execute(&buf, statusPageData{Title: s.statusPage.Title, PublicStatus: status})
+	if err != nil {
+		writeError(response, req, http.StatusInternalServerError, ERR_INTERNAL, "Unable to render the status page", err.Error())
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.writeStatus(response, req, buf.Bytes(), status.UpdatedAt)
+}
+
+// The status page as JSON, for embedding elsewhere
+func (s *Server) statusJsonHandler(response http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	defer req.Body.Close()
+	if s.statusPageDisabled(response, req) {
+		return
+	}
+
+	status := s.tracker.GetPublicStatus(s.statusPage.Services)
+	body, _ := json.Marshal(status)
+
+	response.Header().Set("Content-Type", "application/json")
+	s.writeStatus(response, req, body, status.UpdatedAt)
+}
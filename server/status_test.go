@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_StatusPage(t *testing.T) {
+	Convey("The status page", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		state.ClusterViews.Record(&datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service: service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+				Time:    time.Now().UTC(),
+			},
+		})
+		server := New(state, WithUIPath(""), WithStatusPage("Western Front", []string{"verdun"}, time.Minute))
+
+		get := func(url string, header ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", url, nil)
+			if len(header) == 2 {
+				req.Header.Set(header[0], header[1])
+			}
+			recorder := httptest.NewRecorder()
+			server.Handler().ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Convey("Is HTML for people", func() {
+			recorder := get("/status")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+			So(recorder.Body.String(), ShouldContainSubstring, "<title>Western Front</title>")
+			So(recorder.Body.String(), ShouldContainSubstring, "Major outage")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "meuse")
+		})
+
+		Convey("Is JSON for machines", func() {
+			var status tracker.PublicStatus
+			json.Unmarshal(get("/status.json").Body.Bytes(), &status)
+
+			So(status.Status, ShouldEqual, tracker.STATUS_OUTAGE)
+			So(status.Services[0].Name, ShouldEqual, "verdun")
+		})
+
+		Convey("Can be cached", func() {
+			recorder := get("/status.json")
+			So(recorder.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=60")
+
+			etag := recorder.Header().Get("ETag")
+			So(get("/status.json", "If-None-Match", etag).Code, ShouldEqual, http.StatusNotModified)
+		})
+
+		Convey("Is off without any services", func() {
+			server = New(state, WithUIPath(""))
+			So(get("/status").Code, ShouldEqual, http.StatusNotFound)
+			So(get("/status.json").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DEFAULT_WS_READ_BUFFER  = 1024
+	DEFAULT_WS_WRITE_BUFFER = 4096
+)
+
+// Build the upgrader for the websocket endpoints. Browsers will send our
+// cookies along with a cross-site websocket handshake, so only the origins
+// we're told about get one, along with the one we're served from. Clients
+// that send no Origin at all aren't browsers and aren't affected. An origin
+// of "*" lets anyone in.
+func newUpgrader(readBuffer int, writeBuffer int, allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  readBuffer,
+		WriteBufferSize: writeBuffer,
+		CheckOrigin:     originChecker(allowedOrigins),
+		Error: func(response http.ResponseWriter, req *http.Request, status int, reason error) {
+			code := ERR_BAD_REQUEST
+			if status == http.StatusForbidden {
+				code = ERR_FORBIDDEN
+			}
+			writeError(response, req, status, code, reason.Error())
+		},
+	}
+}
+
+// Origins compare on scheme, host and port, ignoring case
+func normalizeOrigin(origin string) string {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+func originChecker(allowedOrigins []string) func(*http.Request) bool {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			return func(*http.Request) bool { return true }
+		}
+		allowed[normalizeOrigin(origin)] = true
+	}
+	delete(allowed, "")
+
+	return func(req *http.Request) bool {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		parsed, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		if strings.EqualFold(parsed.Host, req.Host) {
+			return true
+		}
+
+		return allowed[normalizeOrigin(origin)]
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_OriginChecker(t *testing.T) {
+	Convey("Checking websocket origins", t, func() {
+		request := func(origin string) *http.Request {
+			req := httptest.NewRequest("GET", "http://superside.example.com/listen", nil)
+			if origin != "" {
+				req.Header.Set("Origin", origin)
+			}
+			return req
+		}
+
+		check := originChecker([]string{"https://Dash.example.com", "http://localhost:3000"})
+
+		Convey("Lets in clients that aren't browsers", func() {
+			So(check(request("")), ShouldBeTrue)
+		})
+
+		Convey("Lets in the origin we're served from", func() {
+			So(check(request("http://superside.example.com")), ShouldBeTrue)
+		})
+
+		Convey("Lets in the allowed origins, ignoring case", func() {
+			So(check(request("https://dash.example.com")), ShouldBeTrue)
+			So(check(request("http://localhost:3000")), ShouldBeTrue)
+		})
+
+		Convey("Keeps out everyone else", func() {
+			So(check(request("https://evil.example.com")), ShouldBeFalse)
+			So(check(request("http://dash.example.com")), ShouldBeFalse)
+			So(check(request("http://localhost:3001")), ShouldBeFalse)
+			So(check(request("::not a url")), ShouldBeFalse)
+		})
+
+		Convey("Lets in anyone with a wildcard", func() {
+			So(originChecker([]string{"*"})(request("https://evil.example.com")), ShouldBeTrue)
+		})
+	})
+
+	Convey("Refusing a websocket from a foreign origin", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		server := New(state, WithUIPath(""), WithWebsocket(512, 512, []string{"https://dash.example.com"}))
+
+		httpServer := httptest.NewServer(server.Handler())
+		defer httpServer.Close()
+
+		wsUrl := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/listen"
+		_, response, err := websocket.DefaultDialer.Dial(wsUrl, http.Header{"Origin": {"https://evil.example.com"}})
+		So(err, ShouldNotBeNil)
+		defer response.Body.Close()
+		So(response.StatusCode, ShouldEqual, http.StatusForbidden)
+
+		var apiError ApiError
+		json.NewDecoder(response.Body).Decode(&apiError)
+		So(apiError.Code, ShouldEqual, ERR_FORBIDDEN)
+	})
+}
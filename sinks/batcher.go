@@ -0,0 +1,132 @@
+package sinks
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+)
+
+const (
+	DEFAULT_BATCH_SIZE     = 500
+	DEFAULT_FLUSH_INTERVAL = 5 * time.Second
+	MAX_PENDING_BATCHES    = 10 // Batches queued for writing in Async mode
+)
+
+// Collects notifications from a tracker listener and hands them to a write
+// function in batches. A batch is written when it reaches Size or when
+// Interval has passed since the last write, whichever comes first.
+//
+// With Async set, batches are written from a separate goroutine so a slow
+// destination doesn't stop us draining the listener. If too many batches
+// back up, new ones are dropped rather than letting memory grow.
+type Batcher struct {
+	Name     string // Used in log messages
+	Size     int
+	Interval time.Duration
+	Async    bool
+	Write    func([]*datatypes.Notification) error
+	Log      *notify.DeliveryLog // Optional, where each notification's write is recorded
+	pending  chan []*datatypes.Notification
+	health   sinkHealth
+}
+
+func NewBatcher(name string, size int, interval time.Duration,
+	write func([]*datatypes.Notification) error) *Batcher {
+
+	if size <= 0 {
+		size = DEFAULT_BATCH_SIZE
+	}
+
+	if interval <= 0 {
+		interval = DEFAULT_FLUSH_INTERVAL
+	}
+
+	return &Batcher{Name: name, Size: size, Interval: interval, Write: write}
+}
+
+func (b *Batcher) flush(batch []*datatypes.Notification) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if b.pending != nil {
+		select {
+		case b.pending <- batch:
+		default:
+			log.Warnf("Dropping %d notifications, %s is falling behind", len(batch), b.Name)
+		}
+		return
+	}
+
+	b.write(batch)
+}
+
+func (b *Batcher) write(batch []*datatypes.Notification) {
+	err := b.Write(batch)
+	now := time.Now().UTC()
+	b.health.record(b.Name, err, now)
+	if err != nil {
+		log.Errorf("Unable to write %d notifications to %s: %s", len(batch), b.Name, err.Error())
+	}
+
+	if b.Log != nil {
+		record := notify.DeliveryRecord{Notifier: b.Name, Sink: true, Time: now, Attempts: 1, Success: err == nil}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		for _, notice := range batch {
+			b.Log.Record(notice.ID, record)
+		}
+	}
+}
+
+func (b *Batcher) Status() SinkStatus {
+	return b.health.status(b.Name)
+}
+
+// Loop over the notifications until the channel is closed, writing whatever
+// is left over on the way out
+func (b *Batcher) Run(notices chan *datatypes.Notification) {
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	if b.Async {
+		b.pending = make(chan []*datatypes.Notification, MAX_PENDING_BATCHES)
+		done := make(chan struct{})
+		go func() {
+			for batch := range b.pending {
+				b.write(batch)
+			}
+			close(done)
+		}()
+
+		defer func() {
+			close(b.pending)
+			<-done
+		}()
+	}
+
+	batch := make([]*datatypes.Notification, 0, b.Size)
+
+	for {
+		select {
+		case notice, ok := <-notices:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+
+			batch = append(batch, notice)
+			if len(batch) >= b.Size {
+				b.flush(batch)
+				batch = make([]*datatypes.Notification, 0, b.Size)
+			}
+
+		case <-ticker.C:
+			b.flush(batch)
+			batch = make([]*datatypes.Notification, 0, b.Size)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Batcher(t *testing.T) {
+	Convey("Batcher", t, func() {
+		var batches [][]*datatypes.Notification
+		batcher := NewBatcher("test", 2, time.Hour, func(batch []*datatypes.Notification) error {
+			batches = append(batches, batch)
+			return nil
+		})
+
+		notices := make(chan *datatypes.Notification, 5)
+		for i := 0; i < 5; i++ {
+			notices <- &datatypes.Notification{}
+		}
+		close(notices)
+
+		Convey("Writes full batches and whatever is left at the end", func() {
+			batcher.Run(notices)
+
+			So(len(batches), ShouldEqual, 3)
+			So(len(batches[0]), ShouldEqual, 2)
+			So(len(batches[2]), ShouldEqual, 1)
+		})
+
+		Convey("Finishes writing queued batches in async mode", func() {
+			batcher.Async = true
+			batcher.Run(notices)
+
+			So(len(batches), ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_CLICKHOUSE_TABLE = "superside_events"
+	CLICKHOUSE_TIME_FORMAT   = "2006-01-02 15:04:05.000"
+)
+
+// The table the ClickHouse sink writes to. %s is replaced by the configured
+// table name. Events are partitioned by month and sorted for the common
+// "what happened to this service in this cluster" queries.
+const CLICKHOUSE_SCHEMA = `CREATE TABLE IF NOT EXISTS %s (
+    id                String,
+    time              DateTime64(3, 'UTC'),
+    received_at       DateTime64(3, 'UTC'),
+    cluster           LowCardinality(String),
+    service           LowCardinality(String),
+    service_id        String,
+    hostname          LowCardinality(String),
+    image             String,
+    previous_status   LowCardinality(String),
+    status            LowCardinality(String),
+    flapping          UInt8,
+    suppressed        UInt8,
+    ingest_latency_ms Int64
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (cluster, service, time)`
+
+type clickhouseRow struct {
+	ID              string `json:"id"`
+	Time            string `json:"time"`
+	ReceivedAt      string `json:"received_at"`
+	Cluster         string `json:"cluster"`
+	Service         string `json:"service"`
+	ServiceID       string `json:"service_id"`
+	Hostname        string `json:"hostname"`
+	Image           string `json:"image"`
+	PreviousStatus  string `json:"previous_status"`
+	Status          string `json:"status"`
+	Flapping        uint8  `json:"flapping"`
+	Suppressed      uint8  `json:"suppressed"`
+	IngestLatencyMs int64  `json:"ingest_latency_ms"`
+}
+
+func boolToUInt8(value bool) uint8 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func rowFor(notice *datatypes.Notification) *clickhouseRow {
+	svc := notice.Event.Service
+
+	return &clickhouseRow{
+		ID:              notice.ID,
+		Time:            notice.Event.Time.UTC().Format(CLICKHOUSE_TIME_FORMAT),
+		ReceivedAt:      notice.ReceivedAt.UTC().Format(CLICKHOUSE_TIME_FORMAT),
+		Cluster:         notice.ClusterName,
+		Service:         svc.Name,
+		ServiceID:       svc.ID,
+		Hostname:        svc.Hostname,
+		Image:           svc.Image,
+		PreviousStatus:  datatypes.StatusString(notice.Event.PreviousStatus),
+		Status:          datatypes.StatusString(svc.Status),
+		Flapping:        boolToUInt8(notice.Flapping),
+		Suppressed:      boolToUInt8(notice.Suppressed),
+		IngestLatencyMs: int64(notice.IngestLatency / time.Millisecond),
+	}
+}
+
+// Inserts service events into ClickHouse over its HTTP interface. Inserts
+// use ClickHouse's async_insert so the server buffers them too, and should
+// be run from an async Batcher so slow inserts never hold up the tracker.
+type ClickHouseSink struct {
+	Url      string
+	Table    string
+	User     string
+	Password string
+	client   *http.Client
+}
+
+func NewClickHouseSink(chUrl string, table string, user string, password string) *ClickHouseSink {
+	if table == "" {
+		table = DEFAULT_CLICKHOUSE_TABLE
+	}
+
+	return &ClickHouseSink{
+		Url:      strings.TrimRight(chUrl, "/"),
+		Table:    table,
+		User:     user,
+		Password: password,
+		client:   &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func (c *ClickHouseSink) exec(params url.Values, body []byte) error {
+	req, err := http.NewRequest("POST", c.Url+"/?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if c.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.User)
+		req.Header.Set("X-ClickHouse-Key", c.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("ClickHouse returned %s: %s", resp.Status, data)
+	}
+
+	return nil
+}
+
+// Create the table from CLICKHOUSE_SCHEMA if it doesn't exist
+func (c *ClickHouseSink) EnsureTable() error {
+	return c.exec(url.Values{}, []byte(fmt.Sprintf(CLICKHOUSE_SCHEMA, c.Table)))
+}
+
+// Insert a batch of service events as JSONEachRow
+func (c *ClickHouseSink) Write(notices []*datatypes.Notification) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	for _, notice := range notices {
+		if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+			continue
+		}
+
+		err := encoder.Encode(rowFor(notice))
+		if err != nil {
+			return err
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("query", "INSERT INTO "+c.Table+" FORMAT JSONEachRow")
+	params.Set("async_insert", "1")
+	params.Set("wait_for_async_insert", "0")
+
+	return c.exec(params, buf.Bytes())
+}
@@ -0,0 +1,61 @@
+package sinks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ClickHouseSink(t *testing.T) {
+	Convey("ClickHouseSink", t, func() {
+		notice := &datatypes.Notification{
+			ID:          "joffre",
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Flapping:    true,
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "verdun", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC),
+			},
+		}
+
+		var query, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			query, body = r.URL.Query().Get("query"), string(data)
+		}))
+		defer server.Close()
+
+		sink := NewClickHouseSink(server.URL, "", "", "")
+
+		Convey("Flattens notifications into rows", func() {
+			row := rowFor(notice)
+			So(row.Time, ShouldEqual, "1916-02-21 07:15:00.000")
+			So(row.Status, ShouldEqual, "Unhealthy")
+			So(row.Flapping, ShouldEqual, 1)
+		})
+
+		Convey("Inserts service events as JSONEachRow", func() {
+			flap := &datatypes.Notification{Type: datatypes.FLAPPING_NOTICE}
+
+			So(sink.Write([]*datatypes.Notification{notice, flap}), ShouldBeNil)
+			So(query, ShouldEqual, "INSERT INTO superside_events FORMAT JSONEachRow")
+			So(len(strings.Split(strings.TrimSpace(body), "\n")), ShouldEqual, 1)
+			So(body, ShouldContainSubstring, `"cluster":"france"`)
+		})
+
+		Convey("Creates the table from the schema", func() {
+			So(sink.EnsureTable(), ShouldBeNil)
+			So(body, ShouldStartWith, "CREATE TABLE IF NOT EXISTS superside_events (")
+		})
+	})
+}
@@ -0,0 +1,215 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	HTTP_TIMEOUT                = 10 * time.Second
+	DEFAULT_ELASTICSEARCH_INDEX = "superside-notifications"
+)
+
+// Mappings for the notification index. Names and IDs are keywords so they
+// can be aggregated on in Kibana; annotations are free text.
+var elasticsearchMappings = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"ID":             map[string]string{"type": "keyword"},
+			"Type":           map[string]string{"type": "keyword"},
+			"Cluster":        map[string]string{"type": "keyword"},
+			"Service":        map[string]string{"type": "keyword"},
+			"ServiceID":      map[string]string{"type": "keyword"},
+			"Hostname":       map[string]string{"type": "keyword"},
+			"Image":          map[string]string{"type": "keyword"},
+			"Status":         map[string]string{"type": "keyword"},
+			"PreviousStatus": map[string]string{"type": "keyword"},
+			"Transition":     map[string]string{"type": "keyword"},
+			"Time":           map[string]string{"type": "date"},
+			"ReceivedAt":     map[string]string{"type": "date"},
+			"IngestLatency":  map[string]string{"type": "long"},
+			"Flapping":       map[string]string{"type": "boolean"},
+			"Suppressed":     map[string]string{"type": "boolean"},
+			"PossibleImpact": map[string]string{"type": "keyword"},
+			"AckedBy":        map[string]string{"type": "keyword"},
+			"Resolved":       map[string]string{"type": "boolean"},
+			"Annotations":    map[string]string{"type": "text"},
+		},
+	},
+}
+
+// The flattened form of a notification that we index
+type elasticsearchDoc struct {
+	ID             string `json:",omitempty"`
+	Type           string
+	Cluster        string
+	Service        string     `json:",omitempty"`
+	ServiceID      string     `json:",omitempty"`
+	Hostname       string     `json:",omitempty"`
+	Image          string     `json:",omitempty"`
+	Status         string     `json:",omitempty"`
+	PreviousStatus string     `json:",omitempty"`
+	Transition     string     `json:",omitempty"`
+	Time           *time.Time `json:",omitempty"`
+	ReceivedAt     *time.Time `json:",omitempty"`
+	IngestLatency  int64      // Milliseconds
+	Flapping       bool
+	Suppressed     bool
+	PossibleImpact []string `json:",omitempty"`
+	AckedBy        string   `json:",omitempty"`
+	Resolved       bool
+	Annotations    []string `json:",omitempty"`
+}
+
+func docFor(notice *datatypes.Notification) *elasticsearchDoc {
+	doc := &elasticsearchDoc{
+		ID:             notice.ID,
+		Type:           notice.Type,
+		Cluster:        notice.ClusterName,
+		IngestLatency:  int64(notice.IngestLatency / time.Millisecond),
+		Flapping:       notice.Flapping,
+		Suppressed:     notice.Suppressed,
+		PossibleImpact: notice.PossibleImpact,
+	}
+
+	if !notice.ReceivedAt.IsZero() {
+		doc.ReceivedAt = &notice.ReceivedAt
+	}
+
+	if notice.Event != nil {
+		svc := notice.Event.Service
+		doc.Service = svc.Name
+		doc.ServiceID = svc.ID
+		doc.Hostname = svc.Hostname
+		doc.Image = svc.Image
+		doc.Status = datatypes.StatusString(svc.Status)
+		doc.PreviousStatus = datatypes.StatusString(notice.Event.PreviousStatus)
+		doc.Transition = notice.Transition()
+		doc.Time = &notice.Event.Time
+	}
+
+	if notice.Ack != nil {
+		doc.AckedBy = notice.Ack.User
+		doc.Resolved = notice.Ack.Resolved
+	}
+
+	for _, annotation := range notice.Annotations {
+		doc.Annotations = append(doc.Annotations, annotation.Text)
+	}
+
+	return doc
+}
+
+// Indexes every notification into Elasticsearch or OpenSearch using the bulk
+// API. Notifications with an ID are indexed under it, so later acks and
+// annotations replace the original document rather than adding another.
+type ElasticsearchSink struct {
+	Url    string
+	Index  string
+	client *http.Client
+}
+
+func NewElasticsearchSink(url string, index string) *ElasticsearchSink {
+	if index == "" {
+		index = DEFAULT_ELASTICSEARCH_INDEX
+	}
+
+	return &ElasticsearchSink{
+		Url:    strings.TrimRight(url, "/"),
+		Index:  index,
+		client: &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+// Create the index with our mappings if it isn't already there
+func (e *ElasticsearchSink) EnsureIndex() error {
+	resp, err := e.client.Head(e.Url + "/" + e.Index)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := json.Marshal(elasticsearchMappings)
+	req, err := http.NewRequest("PUT", e.Url+"/"+e.Index, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Creating index %s returned %s", e.Index, resp.Status)
+	}
+
+	return nil
+}
+
+// Build the newline-delimited body for the bulk API
+func (e *ElasticsearchSink) bulkBody(notices []*datatypes.Notification) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	for _, notice := range notices {
+		action := map[string]string{"_index": e.Index}
+		if notice.ID != "" {
+			action["_id"] = notice.ID
+		}
+
+		err := encoder.Encode(map[string]interface{}{"index": action})
+		if err != nil {
+			return nil, err
+		}
+
+		err = encoder.Encode(docFor(notice))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Send a batch of notifications with a single bulk request
+func (e *ElasticsearchSink) Write(notices []*datatypes.Notification) error {
+	body, err := e.bulkBody(notices)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.Url+"/_bulk", "application/x-ndjson", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Bulk request returned %s: %s", resp.Status, data)
+	}
+
+	// The bulk API returns 200 even when individual documents fail
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if json.Unmarshal(data, &result) == nil && result.Errors {
+		return fmt.Errorf("Bulk request had errors: %s", data)
+	}
+
+	return nil
+}
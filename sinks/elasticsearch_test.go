@@ -0,0 +1,66 @@
+package sinks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ElasticsearchSink(t *testing.T) {
+	Convey("ElasticsearchSink", t, func() {
+		notice := &datatypes.Notification{
+			ID:          "joffre",
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "verdun", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC),
+			},
+		}
+
+		var path, body string
+		responseBody := `{"errors": false}`
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			path, body = r.URL.Path, string(data)
+			w.Write([]byte(responseBody))
+		}))
+		defer server.Close()
+
+		sink := NewElasticsearchSink(server.URL+"/", "")
+
+		Convey("Flattens notifications into documents", func() {
+			doc := docFor(notice)
+			So(doc.Cluster, ShouldEqual, "france")
+			So(doc.Transition, ShouldEqual, "Alive->Unhealthy")
+			So(doc.Time.Year(), ShouldEqual, 1916)
+		})
+
+		Convey("Sends batches to the bulk API", func() {
+			flap := &datatypes.Notification{Type: datatypes.FLAPPING_NOTICE, ClusterName: "france"}
+
+			So(sink.Write([]*datatypes.Notification{notice, flap}), ShouldBeNil)
+			So(path, ShouldEqual, "/_bulk")
+
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			So(len(lines), ShouldEqual, 4)
+			So(lines[0], ShouldEqual, `{"index":{"_id":"joffre","_index":"superside-notifications"}}`)
+			So(lines[1], ShouldContainSubstring, `"Service":"verdun"`)
+			So(lines[2], ShouldNotContainSubstring, "_id")
+		})
+
+		Convey("Reports failures inside a bulk response", func() {
+			responseBody = `{"errors": true}`
+			So(sink.Write([]*datatypes.Notification{notice}), ShouldNotBeNil)
+		})
+	})
+}
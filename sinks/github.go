@@ -0,0 +1,294 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	GITHUB_API_URL         = "https://api.github.com"
+	DEFAULT_GITHUB_TIMEOUT = 15 * time.Minute
+	GITHUB_CHECK_INTERVAL  = 30 * time.Second
+	GITHUB_SINK_NAME       = "GitHub"
+)
+
+type githubStatus struct {
+	State       string `json:"state"` // "in_progress", "success" or "failure"
+	Description string `json:"description,omitempty"`
+}
+
+// What's running right now. The tracker's ClusterViews will do.
+type ClusterState interface {
+	Clusters() []string
+	Current(clusterName string) *datatypes.ClusterView
+}
+
+// One instance of the service being deployed
+type githubInstance struct {
+	Version string
+	Status  int
+}
+
+// A deploy marker we're waiting on, with every instance of its service
+type githubWatch struct {
+	deploy    *datatypes.Deployment
+	deadline  time.Time
+	instances map[string]githubInstance // Cluster/hostname/ID => instance
+}
+
+// How a watch ended
+type githubResult struct {
+	deploy      *datatypes.Deployment
+	state       string // "success" or "failure"
+	description string
+}
+
+// Closes the loop between CI and what actually happened. When a deploy
+// marker carries a GitHub Deployment, we set its status to success once
+// every instance of the service runs the new version and is healthy, to
+// failure as soon as an instance of the new version is unhealthy, and to
+// failure if neither has happened within Timeout.
+//
+// With a State, the instances that were already running when the marker
+// arrived count too, so a rolling deploy isn't done until the last old
+// instance is gone, and one that finished before the marker is reported
+// straight away. Without one, only the instances we hear about count.
+type GithubDeployments struct {
+	ApiUrl  string
+	Token   string
+	Timeout time.Duration
+	State   ClusterState            // Optional
+	watches map[string]*githubWatch // Deployment ID => watch
+	client  *http.Client
+	health  sinkHealth
+}
+
+func NewGithubDeployments(token string) *GithubDeployments {
+	return &GithubDeployments{
+		ApiUrl:  GITHUB_API_URL,
+		Token:   token,
+		Timeout: DEFAULT_GITHUB_TIMEOUT,
+		watches: make(map[string]*githubWatch, 10),
+		client:  &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+// Start watching a deploy marker. Returns true if it's one we should report on.
+func (g *GithubDeployments) watch(deploy *datatypes.Deployment, now time.Time) bool {
+	if deploy.Source != datatypes.MARKER_SOURCE || deploy.GithubID == 0 {
+		return false
+	}
+
+	if _, ok := g.watches[deploy.ID]; ok {
+		return false
+	}
+
+	watch := &githubWatch{
+		deploy:    deploy,
+		deadline:  now.Add(g.Timeout),
+		instances: make(map[string]githubInstance, 5),
+	}
+	g.watches[deploy.ID] = watch
+
+	if g.State == nil {
+		return true
+	}
+
+	for _, clusterName := range g.State.Clusters() {
+		view := g.State.Current(clusterName)
+		if view == nil || !watch.covers(clusterName) {
+			continue
+		}
+
+		for _, svc := range view.Services {
+			if svc.Name != deploy.Name {
+				continue
+			}
+
+			for _, instance := range svc.Instances {
+				_, version := datatypes.ParseImage(instance.Image)
+				status, _ := datatypes.ParseStatus(instance.Status)
+				key := clusterName + "/" + instance.Hostname + "/" + instance.ID
+				watch.instances[key] = githubInstance{Version: version, Status: status}
+			}
+		}
+	}
+
+	return true
+}
+
+// Is this cluster one the deploy went to?
+func (w *githubWatch) covers(clusterName string) bool {
+	return w.deploy.ClusterName == "" || w.deploy.ClusterName == clusterName
+}
+
+// Apply a status change to the watches it concerns, returning the ones that
+// are now finished
+func (g *GithubDeployments) record(notice *datatypes.Notification) []githubResult {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return nil
+	}
+
+	svc := notice.Event.Service
+	_, version := datatypes.ParseImage(svc.Image)
+	key := notice.ClusterName + "/" + svc.Hostname + "/" + svc.ID
+
+	for _, watch := range g.watches {
+		if watch.deploy.Name != svc.Name || !watch.covers(notice.ClusterName) {
+			continue
+		}
+
+		if svc.Status == service.TOMBSTONE {
+			delete(watch.instances, key)
+		} else {
+			watch.instances[key] = githubInstance{Version: version, Status: svc.Status}
+		}
+	}
+
+	return g.finished()
+}
+
+// Take the watches that have succeeded or failed off the list
+func (g *GithubDeployments) finished() []githubResult {
+	var results []githubResult
+	for id, watch := range g.watches {
+		if result := watch.result(); result != nil {
+			delete(g.watches, id)
+			results = append(results, *result)
+		}
+	}
+
+	return results
+}
+
+// Fails as soon as an instance of the new version is unhealthy, succeeds
+// once every instance runs the new version and is healthy, or returns nil
+// while we wait
+func (w *githubWatch) result() *githubResult {
+	deploy := w.deploy
+	healthy := len(w.instances) > 0
+
+	for key, instance := range w.instances {
+		if instance.Version != deploy.Version {
+			healthy = false
+			continue
+		}
+
+		if instance.Status == service.UNHEALTHY {
+			return &githubResult{deploy, "failure",
+				fmt.Sprintf("%s %s is unhealthy on %s", deploy.Name, deploy.Version, key),
+			}
+		}
+
+		if instance.Status != service.ALIVE {
+			healthy = false
+		}
+	}
+
+	if !healthy {
+		return nil
+	}
+
+	return &githubResult{deploy, "success", fmt.Sprintf("%s %s is healthy", deploy.Name, deploy.Version)}
+}
+
+// Give up on the watches that have run out of time
+func (g *GithubDeployments) expire(now time.Time) []githubResult {
+	var failed []githubResult
+	for id, watch := range g.watches {
+		if now.After(watch.deadline) {
+			delete(g.watches, id)
+			failed = append(failed, githubResult{watch.deploy, "failure",
+				fmt.Sprintf("%s %s wasn't healthy within %s", watch.deploy.Name, watch.deploy.Version, g.Timeout),
+			})
+		}
+	}
+
+	return failed
+}
+
+func (g *GithubDeployments) post(deploy *datatypes.Deployment, status *githubStatus) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	statusUrl := fmt.Sprintf("%s/repos/%s/deployments/%d/statuses",
+		strings.TrimRight(g.ApiUrl, "/"), deploy.GithubRepo, deploy.GithubID,
+	)
+
+	req, err := http.NewRequest("POST", statusUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (g *GithubDeployments) report(deploy *datatypes.Deployment, state string, description string) {
+	err := g.post(deploy, &githubStatus{State: state, Description: description})
+	g.health.record(GITHUB_SINK_NAME, err, time.Now().UTC())
+	if err != nil {
+		log.Errorf("Unable to update GitHub deployment %d for %s: %s",
+			deploy.GithubID, deploy.Name, err.Error())
+	}
+}
+
+func (g *GithubDeployments) reportAll(results []githubResult) {
+	for _, result := range results {
+		g.report(result.deploy, result.state, result.description)
+	}
+}
+
+func (g *GithubDeployments) Status() SinkStatus {
+	return g.health.status(GITHUB_SINK_NAME)
+}
+
+// Loop over notifications and deployments until either channel is closed
+func (g *GithubDeployments) Run(notices chan *datatypes.Notification, deploys chan *datatypes.Deployment) {
+	ticker := time.NewTicker(GITHUB_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case notice, ok := <-notices:
+			if !ok {
+				return
+			}
+			g.reportAll(g.record(notice))
+
+		case deploy, ok := <-deploys:
+			if !ok {
+				return
+			}
+			if g.watch(deploy, time.Now().UTC()) {
+				g.report(deploy, "in_progress", "Waiting for the new version to become healthy")
+				g.reportAll(g.finished()) // It may be done already
+			}
+
+		case <-ticker.C:
+			g.reportAll(g.expire(time.Now().UTC()))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GithubDeployments(t *testing.T) {
+	Convey("GithubDeployments", t, func() {
+		when := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+		github := NewGithubDeployments("s3cr3t")
+		github.Timeout = 10 * time.Minute
+
+		deploy := (&datatypes.Marker{
+			Service: "verdun", Version: "1.1", Time: when,
+			GithubRepo: "army/verdun", GithubID: 1916,
+		}).Deployment()
+
+		change := func(id string, version string, status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{
+						ID: id, Name: "verdun", Hostname: "meuse", Image: "verdun:" + version, Status: status,
+					},
+					Time: when,
+				},
+			}
+		}
+
+		Convey("Only watches markers for GitHub deployments", func() {
+			So(github.watch(deploy, when), ShouldBeTrue)
+			So(github.watch(deploy, when), ShouldBeFalse)
+			So(github.watch(&datatypes.Deployment{ID: "inferred", Name: "verdun"}, when), ShouldBeFalse)
+		})
+
+		Convey("Succeeds once every instance runs the new version and is healthy", func() {
+			github.watch(deploy, when)
+
+			So(github.record(change("3", "1.0", service.ALIVE)), ShouldBeEmpty)
+			So(github.record(change("1", "1.1", service.ALIVE)), ShouldBeEmpty)
+			So(github.record(change("2", "1.1", service.UNKNOWN)), ShouldBeEmpty)
+			So(github.record(change("3", "1.0", service.TOMBSTONE)), ShouldBeEmpty)
+
+			results := github.record(change("2", "1.1", service.ALIVE))
+			So(len(results), ShouldEqual, 1)
+			So(results[0].deploy, ShouldEqual, deploy)
+			So(results[0].state, ShouldEqual, "success")
+			So(github.watches, ShouldBeEmpty)
+		})
+
+		Convey("Fails as soon as an instance of the new version is unhealthy", func() {
+			github.watch(deploy, when)
+
+			So(github.record(change("3", "1.0", service.UNHEALTHY)), ShouldBeEmpty)
+
+			results := github.record(change("1", "1.1", service.UNHEALTHY))
+			So(len(results), ShouldEqual, 1)
+			So(results[0].state, ShouldEqual, "failure")
+			So(results[0].description, ShouldEqual, "verdun 1.1 is unhealthy on france/meuse/1")
+			So(github.watches, ShouldBeEmpty)
+		})
+
+		Convey("Counts the instances that were running when the marker arrived", func() {
+			views := tracker.NewClusterViews()
+			views.Record(change("1", "1.0", service.ALIVE))
+			views.Record(change("2", "1.1", service.ALIVE))
+			github.State = views
+
+			github.watch(deploy, when)
+			So(github.finished(), ShouldBeEmpty)
+
+			results := github.record(change("1", "1.0", service.TOMBSTONE))
+			So(len(results), ShouldEqual, 1)
+			So(results[0].state, ShouldEqual, "success")
+		})
+
+		Convey("Reports deploys that were over before the marker arrived", func() {
+			views := tracker.NewClusterViews()
+			views.Record(change("1", "1.1", service.ALIVE))
+			github.State = views
+
+			github.watch(deploy, when)
+			results := github.finished()
+			So(len(results), ShouldEqual, 1)
+			So(results[0].state, ShouldEqual, "success")
+		})
+
+		Convey("Fails when it doesn't get healthy in time", func() {
+			github.watch(deploy, when)
+			github.record(change("1", "1.1", service.UNKNOWN))
+
+			So(github.expire(when.Add(5*time.Minute)), ShouldBeEmpty)
+
+			results := github.expire(when.Add(11 * time.Minute))
+			So(len(results), ShouldEqual, 1)
+			So(results[0].deploy, ShouldEqual, deploy)
+			So(results[0].state, ShouldEqual, "failure")
+			So(github.watches, ShouldBeEmpty)
+		})
+
+		Convey("Posts deployment statuses", func() {
+			var path, auth string
+			var received map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				auth = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			github.ApiUrl = server.URL
+			err := github.post(deploy, &githubStatus{State: "success", Description: "All good"})
+
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, "/repos/army/verdun/deployments/1916/statuses")
+			So(auth, ShouldEqual, "Bearer s3cr3t")
+			So(received["state"], ShouldEqual, "success")
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+)
+
+const (
+	// Deployments older than this are forgotten, so we don't keep their IDs
+	// around forever. They're re-announced while they aggregate, well inside this.
+	GRAFANA_DEPLOY_MEMORY = 4 * datatypes.DEPLOYMENT_CUTOFF
+	GRAFANA_SINK_NAME     = "Grafana"
+)
+
+type grafanaAnnotation struct {
+	Time    int64    `json:"time"` // Milliseconds since the epoch
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+}
+
+// Creates Grafana annotations for selected status changes and for new
+// deployments, tagged by cluster and service so dashboards can pick out
+// the ones they care about.
+type GrafanaSink struct {
+	Url         string
+	ApiKey      string
+	Filter      *datatypes.TransitionFilter
+	Deployments bool                 // Annotate deployments too?
+	announced   map[string]time.Time // Deployment ID => start time
+	client      *http.Client
+	health      sinkHealth
+}
+
+func NewGrafanaSink(grafanaUrl string, apiKey string, filter *datatypes.TransitionFilter) *GrafanaSink {
+	return &GrafanaSink{
+		Url:         strings.TrimRight(grafanaUrl, "/"),
+		ApiKey:      apiKey,
+		Filter:      filter,
+		Deployments: true,
+		announced:   make(map[string]time.Time, 50),
+		client:      &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+func millis(when time.Time) int64 {
+	return when.UnixNano() / int64(time.Millisecond)
+}
+
+// Build the annotation for a status change, or nil if we don't want one
+func (g *GrafanaSink) annotationForNotice(notice *datatypes.Notification) *grafanaAnnotation {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil || notice.Suppressed ||
+		notice.Event.PreviousStatus == notice.Event.Service.Status || !g.Filter.Matches(notice) {
+		return nil
+	}
+
+	svc := notice.Event.Service
+	return &grafanaAnnotation{
+		Time: millis(notice.Event.Time),
+		Tags: []string{
+			"superside",
+			"cluster:" + notice.ClusterName,
+			"service:" + svc.Name,
+			"status:" + datatypes.StatusString(svc.Status),
+		},
+		Text: notify.MessageFor(notice),
+	}
+}
+
+// Build the annotation for a deployment the first time we see it
+func (g *GrafanaSink) annotationForDeploy(deploy *datatypes.Deployment, now time.Time) *grafanaAnnotation {
+	for id, started := range g.announced {
+		if now.Sub(started) > GRAFANA_DEPLOY_MEMORY {
+			delete(g.announced, id)
+		}
+	}
+
+	if !g.Deployments {
+		return nil
+	}
+
+	if _, ok := g.announced[deploy.ID]; ok {
+		return nil
+	}
+	g.announced[deploy.ID] = deploy.StartTime
+
+	return &grafanaAnnotation{
+		Time: millis(deploy.StartTime),
+		Tags: []string{
+			"superside",
+			"deployment",
+			"cluster:" + deploy.ClusterName,
+			"service:" + deploy.Name,
+		},
+		Text: fmt.Sprintf("[%s] deployed %s version %s (%s)",
+			deploy.ClusterName, deploy.Name, deploy.Version, deploy.Image,
+		),
+	}
+}
+
+func (g *GrafanaSink) post(annotation *grafanaAnnotation) error {
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", g.Url+"/api/annotations", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.ApiKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Grafana returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (g *GrafanaSink) Status() SinkStatus {
+	return g.health.status(GRAFANA_SINK_NAME)
+}
+
+// Loop over notifications and deployments until either channel is closed
+func (g *GrafanaSink) Run(notices chan *datatypes.Notification, deploys chan *datatypes.Deployment) {
+	for {
+		var annotation *grafanaAnnotation
+
+		select {
+		case notice, ok := <-notices:
+			if !ok {
+				return
+			}
+			annotation = g.annotationForNotice(notice)
+
+		case deploy, ok := <-deploys:
+			if !ok {
+				return
+			}
+			annotation = g.annotationForDeploy(deploy, time.Now().UTC())
+		}
+
+		if annotation == nil {
+			continue
+		}
+
+		err := g.post(annotation)
+		g.health.record(GRAFANA_SINK_NAME, err, time.Now().UTC())
+		if err != nil {
+			log.Errorf("Unable to create Grafana annotation: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GrafanaSink(t *testing.T) {
+	Convey("GrafanaSink", t, func() {
+		when := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+
+		notice := &datatypes.Notification{
+			Type:        datatypes.SERVICE_EVENT_NOTICE,
+			ClusterName: "france",
+			Event: &catalog.ChangeEvent{
+				Service:        service.Service{Name: "verdun", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           when,
+			},
+		}
+
+		deploy := &datatypes.Deployment{
+			ID: "joffre", Name: "verdun", ClusterName: "france", Version: "1916", StartTime: when,
+		}
+
+		filter, _ := datatypes.ParseTransitionFilter([]string{"Unhealthy"})
+		sink := NewGrafanaSink("http://grafana/", "", filter)
+
+		Convey("Annotates matching transitions with cluster and service tags", func() {
+			annotation := sink.annotationForNotice(notice)
+
+			So(annotation, ShouldNotBeNil)
+			So(annotation.Time, ShouldEqual, millis(when))
+			So(annotation.Tags, ShouldContain, "cluster:france")
+			So(annotation.Tags, ShouldContain, "service:verdun")
+		})
+
+		Convey("Skips transitions that don't match the filter", func() {
+			notice.Event.Service.Status = service.ALIVE
+			notice.Event.PreviousStatus = service.UNHEALTHY
+			So(sink.annotationForNotice(notice), ShouldBeNil)
+		})
+
+		Convey("Skips silenced transitions", func() {
+			notice.Suppressed = true
+			So(sink.annotationForNotice(notice), ShouldBeNil)
+		})
+
+		Convey("Annotates each deployment once", func() {
+			So(sink.annotationForDeploy(deploy, when), ShouldNotBeNil)
+			So(sink.annotationForDeploy(deploy, when.Add(time.Minute)), ShouldBeNil)
+
+			Convey("and eventually forgets about it", func() {
+				sink.annotationForDeploy(&datatypes.Deployment{ID: "foch"}, when.Add(time.Hour))
+				So(sink.announced, ShouldNotContainKey, "joffre")
+			})
+		})
+
+		Convey("Posts annotations to the API", func() {
+			var received grafanaAnnotation
+			var auth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&received)
+			}))
+			defer server.Close()
+
+			sink.Url = server.URL
+			sink.ApiKey = "secret"
+
+			So(sink.post(sink.annotationForNotice(notice)), ShouldBeNil)
+			So(auth, ShouldEqual, "Bearer secret")
+			So(received.Text, ShouldContainSubstring, "verdun")
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package sinks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/metrics"
+)
+
+// 1 while the last write to a sink worked, 0 otherwise
+var SinkHealthy = metrics.NewGaugeVec(
+	"superside_sink_healthy",
+	"Whether the last write to the sink worked",
+	"sink",
+)
+
+// When each sink last took a write, so alerts can fire on how stale it is
+var SinkLastSuccess = metrics.NewGaugeVec(
+	"superside_sink_last_success_timestamp_seconds",
+	"Unix time of the last successful write to the sink",
+	"sink",
+)
+
+// A snapshot of how writes to a sink have been going
+type SinkStatus struct {
+	Name                string
+	Healthy             bool
+	LastSuccess         time.Time `json:",omitempty"`
+	LastError           string    `json:",omitempty"`
+	ConsecutiveFailures int
+}
+
+// Anything that can say how its writes are going, for readiness checks
+type Sink interface {
+	Status() SinkStatus
+}
+
+// Keeps track of a sink's writes. Embedded in each of the sinks.
+type sinkHealth struct {
+	lastSuccess         time.Time
+	lastError           error
+	consecutiveFailures int
+	lock                sync.RWMutex
+}
+
+func (h *sinkHealth) record(name string, err error, now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err != nil {
+		h.lastError = err
+		h.consecutiveFailures += 1
+		SinkHealthy.Set(0, name)
+		return
+	}
+
+	h.lastSuccess = now
+	h.consecutiveFailures = 0
+	SinkHealthy.Set(1, name)
+	SinkLastSuccess.Set(float64(now.Unix()), name)
+}
+
+// Healthy until a write fails, and again once one works
+func (h *sinkHealth) status(name string) SinkStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	status := SinkStatus{
+		Name:                name,
+		Healthy:             h.consecutiveFailures == 0,
+		LastSuccess:         h.lastSuccess,
+		ConsecutiveFailures: h.consecutiveFailures,
+	}
+
+	if h.lastError != nil {
+		status.LastError = h.lastError.Error()
+	}
+
+	return status
+}
@@ -0,0 +1,67 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/notify"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SinkHealth(t *testing.T) {
+	Convey("Sink health", t, func() {
+		var failing error
+		batcher := NewBatcher("test", 1, time.Hour, func(batch []*datatypes.Notification) error {
+			return failing
+		})
+
+		Convey("Is healthy before anything is written", func() {
+			status := batcher.Status()
+			So(status.Name, ShouldEqual, "test")
+			So(status.Healthy, ShouldBeTrue)
+			So(status.LastSuccess.IsZero(), ShouldBeTrue)
+		})
+
+		Convey("Remembers the last successful write", func() {
+			batcher.write([]*datatypes.Notification{{}})
+
+			status := batcher.Status()
+			So(status.Healthy, ShouldBeTrue)
+			So(status.LastSuccess.IsZero(), ShouldBeFalse)
+			So(SinkHealthy.Get("test"), ShouldEqual, 1)
+		})
+
+		Convey("Counts failures until a write works again", func() {
+			batcher.write([]*datatypes.Notification{{}})
+			failing = errors.New("Elasticsearch is on fire")
+			batcher.write([]*datatypes.Notification{{}})
+			batcher.write([]*datatypes.Notification{{}})
+
+			status := batcher.Status()
+			So(status.Healthy, ShouldBeFalse)
+			So(status.ConsecutiveFailures, ShouldEqual, 2)
+			So(status.LastError, ShouldEqual, "Elasticsearch is on fire")
+			So(status.LastSuccess.IsZero(), ShouldBeFalse)
+			So(SinkHealthy.Get("test"), ShouldEqual, 0)
+
+			failing = nil
+			batcher.write([]*datatypes.Notification{{}})
+			So(batcher.Status().Healthy, ShouldBeTrue)
+			So(batcher.Status().ConsecutiveFailures, ShouldEqual, 0)
+		})
+
+		Convey("Records each notification's write in the delivery log", func() {
+			batcher.Log = notify.NewDeliveryLog(10)
+			failing = errors.New("Elasticsearch is on fire")
+			batcher.write([]*datatypes.Notification{{ID: "verdun"}, {ID: "somme"}})
+
+			records := batcher.Log.For("somme")
+			So(len(records), ShouldEqual, 1)
+			So(records[0].Notifier, ShouldEqual, "test")
+			So(records[0].Sink, ShouldBeTrue)
+			So(records[0].Error, ShouldEqual, "Elasticsearch is on fire")
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	INFLUX_MEASUREMENT = "service_transition"
+)
+
+var (
+	tagEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// Writes a point in the InfluxDB line protocol for every status change. The
+// point is tagged with the cluster, service and new status, and carries the
+// number of instances of the service currently in each status, so change
+// rates and fleet health can both be charted from it.
+type InfluxSink struct {
+	Url       string
+	Database  string
+	Token     string                    // Optional, sent as "Authorization: Token ..."
+	instances map[string]map[string]int // "cluster/service" => "host/id" => status
+	client    *http.Client
+	lock      sync.Mutex
+}
+
+func NewInfluxSink(influxUrl string, database string, token string) *InfluxSink {
+	return &InfluxSink{
+		Url:       strings.TrimRight(influxUrl, "/"),
+		Database:  database,
+		Token:     token,
+		instances: make(map[string]map[string]int, 100),
+		client:    &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+// Update our view of the service's instances and count them by status
+func (s *InfluxSink) countInstances(notice *datatypes.Notification) map[int]int {
+	svc := notice.Event.Service
+	key := notice.ClusterName + "/" + svc.Name
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	instances, ok := s.instances[key]
+	if !ok {
+		instances = make(map[string]int, 5)
+		s.instances[key] = instances
+	}
+
+	if svc.Status == service.TOMBSTONE {
+		delete(instances, svc.Hostname+"/"+svc.ID)
+	} else {
+		instances[svc.Hostname+"/"+svc.ID] = svc.Status
+	}
+
+	counts := make(map[int]int, 3)
+	for _, status := range instances {
+		counts[status] += 1
+	}
+
+	return counts
+}
+
+// Format one line of line protocol, or return "" if the notification isn't
+// a status change
+func (s *InfluxSink) lineFor(notice *datatypes.Notification) string {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil ||
+		notice.Event.PreviousStatus == notice.Event.Service.Status {
+		return ""
+	}
+
+	svc := notice.Event.Service
+	counts := s.countInstances(notice)
+
+	return fmt.Sprintf(
+		"%s,cluster=%s,service=%s,status=%s previous_status=\"%s\",alive=%di,unhealthy=%di,unknown=%di %d\n",
+		INFLUX_MEASUREMENT,
+		tagEscaper.Replace(notice.ClusterName),
+		tagEscaper.Replace(svc.Name),
+		tagEscaper.Replace(datatypes.StatusString(svc.Status)),
+		stringEscaper.Replace(datatypes.StatusString(notice.Event.PreviousStatus)),
+		counts[service.ALIVE], counts[service.UNHEALTHY], counts[service.UNKNOWN],
+		notice.Event.Time.UnixNano(),
+	)
+}
+
+// Send a batch of points with a single write request
+func (s *InfluxSink) Write(notices []*datatypes.Notification) error {
+	var buf bytes.Buffer
+	for _, notice := range notices {
+		buf.WriteString(s.lineFor(notice))
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest("POST",
+		s.Url+"/write?db="+url.QueryEscape(s.Database), &buf,
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("InfluxDB write returned %s: %s", resp.Status, data)
+	}
+
+	return nil
+}
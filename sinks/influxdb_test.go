@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_InfluxSink(t *testing.T) {
+	Convey("InfluxSink", t, func() {
+		when := time.Unix(0, 1000)
+
+		change := func(id string, from int, to int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{
+						ID: id, Name: "verdun fort", Hostname: "meuse", Status: to,
+					},
+					PreviousStatus: from,
+					Time:           when,
+				},
+			}
+		}
+
+		var query, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			query, body = r.URL.RawQuery, string(data)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		sink := NewInfluxSink(server.URL, "superside", "")
+
+		Convey("Counts instances by status and escapes tags", func() {
+			sink.lineFor(change("douaumont", service.UNKNOWN, service.ALIVE))
+
+			So(sink.lineFor(change("vaux", service.ALIVE, service.UNHEALTHY)), ShouldEqual,
+				`service_transition,cluster=france,service=verdun\ fort,status=Unhealthy `+
+					`previous_status="Alive",alive=1i,unhealthy=1i,unknown=0i 1000`+"\n",
+			)
+		})
+
+		Convey("Forgets tombstoned instances", func() {
+			sink.lineFor(change("douaumont", service.UNKNOWN, service.ALIVE))
+			So(sink.lineFor(change("douaumont", service.ALIVE, service.TOMBSTONE)),
+				ShouldContainSubstring, "alive=0i")
+		})
+
+		Convey("Skips notifications that aren't status changes", func() {
+			So(sink.lineFor(change("vaux", service.ALIVE, service.ALIVE)), ShouldEqual, "")
+			So(sink.lineFor(&datatypes.Notification{Type: datatypes.FLAPPING_NOTICE}), ShouldEqual, "")
+		})
+
+		Convey("Writes batches to the database", func() {
+			err := sink.Write([]*datatypes.Notification{change("vaux", service.ALIVE, service.UNHEALTHY)})
+
+			So(err, ShouldBeNil)
+			So(query, ShouldEqual, "db=superside")
+			So(body, ShouldStartWith, "service_transition,")
+		})
+	})
+}
@@ -1,4 +1,4 @@
-package persistence
+package store
 
 type Store interface {
 	StoreBlob(key string, data []byte) error
@@ -0,0 +1,91 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/nitro/superside/client"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/server"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+)
+
+const (
+	DEFAULT_RING_SIZE = 500
+	WAIT_POLL         = 10 * time.Millisecond
+)
+
+// A Superside running in-process on a local port, for integration tests.
+// Events go in over HTTP, like they would from Sidecar.
+type Harness struct {
+	Tracker *tracker.Tracker
+	Server  *server.Server
+	Client  *client.Client
+	URL     string // e.g. "http://127.0.0.1:34567"
+	http    *httptest.Server
+	cancel  context.CancelFunc
+}
+
+// Start a harness with a fresh tracker that doesn't persist anything. The
+// UI isn't served unless an option asks for it.
+func NewHarness(opts ...server.Option) *Harness {
+	return NewHarnessFor(tracker.NewTracker(DEFAULT_RING_SIZE, &store.NoopStore{}), opts...)
+}
+
+// Start a harness around a tracker that's already set up, which mustn't
+// be processing updates yet. The tracker stops on Close().
+func NewHarnessFor(state *tracker.Tracker, opts ...server.Option) *Harness {
+	ctx, cancel := context.WithCancel(context.Background())
+	go state.Run(ctx)
+
+	srv := server.New(state, append([]server.Option{server.WithUIPath("")}, opts...)...)
+	httpServer := httptest.NewServer(srv.Handler())
+
+	return &Harness{
+		Tracker: state,
+		Server:  srv,
+		Client:  client.New(httpServer.URL),
+		URL:     httpServer.URL,
+		http:    httpServer,
+		cancel:  cancel,
+	}
+}
+
+// Post the events to /api/update in order, stopping at the first error
+func (h *Harness) Send(events ...catalog.StateChangedEvent) error {
+	for _, evt := range events {
+		err := h.Client.PostUpdate(context.Background(), evt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Wait until at least count events are stored, returning them oldest
+// first, or an error if they aren't within the timeout
+func (h *Harness) WaitForEvents(count int, timeout time.Duration) ([]datatypes.Notification, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		events := h.Tracker.GetSvcEventsList()
+		if len(events) >= count {
+			return events, nil
+		}
+
+		if time.Now().After(deadline) {
+			return events, fmt.Errorf("Only %d of %d events stored after %s", len(events), count, timeout)
+		}
+		time.Sleep(WAIT_POLL)
+	}
+}
+
+// Shut down the HTTP server and stop the tracker
+func (h *Harness) Close() {
+	h.http.Close()
+	h.cancel()
+}
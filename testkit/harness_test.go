@@ -0,0 +1,49 @@
+package testkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Harness(t *testing.T) {
+	Convey("The harness", t, func() {
+		harness := NewHarness()
+		defer harness.Close()
+
+		sidecar := NewSidecar("france", "verdun")
+
+		Convey("Takes events from a fake Sidecar over HTTP", func() {
+			started, _ := sidecar.Start("artillery", "verdun", "1")
+			failed, _ := sidecar.Fail(started.ChangeEvent.Service.ID)
+			So(harness.Send(started, failed), ShouldBeNil)
+
+			events, err := harness.WaitForEvents(2, 5*time.Second)
+			So(err, ShouldBeNil)
+			So(events[1].Event.Service.Name, ShouldEqual, "artillery")
+
+			stored, err := harness.Client.GetState(context.Background(), client.Filters{})
+			So(err, ShouldBeNil)
+			So(len(stored), ShouldEqual, 2)
+		})
+
+		Convey("Says when events don't turn up", func() {
+			_, err := harness.WaitForEvents(1, 20*time.Millisecond)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Closing the harness stops its tracker", t, func() {
+		harness := NewHarness()
+		harness.Close()
+
+		// The deployments loop unsubscribes on the way out
+		for i := 0; i < 100 && len(harness.Tracker.ListenerDepths().Storage) > 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(harness.Tracker.ListenerDepths().Storage, ShouldBeEmpty)
+	})
+}
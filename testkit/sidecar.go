@@ -0,0 +1,201 @@
+package testkit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+)
+
+var ErrNoSuchService = errors.New("No such service instance")
+
+// A fake Sidecar cluster: hosts running services whose changes come out as
+// the StateChangedEvents the real one sends, with the full state attached.
+// Everything is reported through the first host, like the cluster latch
+// expects. Safe to use from more than one goroutine.
+type Sidecar struct {
+	ClusterName string
+	Reporter    string           // The host the events come from
+	Now         func() time.Time // When things happen, time.Now by default
+	servers     map[string]*catalog.Server
+	lastChanged time.Time
+	nextID      int
+	lock        sync.Mutex
+}
+
+func NewSidecar(clusterName string, hosts ...string) *Sidecar {
+	if len(hosts) == 0 {
+		hosts = []string{clusterName + "-host-0"}
+	}
+
+	sidecar := &Sidecar{
+		ClusterName: clusterName,
+		Reporter:    hosts[0],
+		Now:         func() time.Time { return time.Now().UTC() },
+		servers:     make(map[string]*catalog.Server, len(hosts)),
+	}
+
+	for _, host := range hosts {
+		sidecar.servers[host] = catalog.NewServer(host)
+	}
+
+	return sidecar
+}
+
+// Start a new instance of a service on a host, which must be one of ours
+func (s *Sidecar) Start(name string, host string, version string) (catalog.StateChangedEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	server, ok := s.servers[host]
+	if !ok {
+		return catalog.StateChangedEvent{}, fmt.Errorf("No such host '%s'", host)
+	}
+
+	now := s.Now()
+	s.nextID++
+	svc := &service.Service{
+		ID:       fmt.Sprintf("%012x", s.nextID),
+		Name:     name,
+		Image:    name + ":" + version,
+		Hostname: host,
+		Created:  now,
+		Updated:  now,
+		Status:   service.ALIVE,
+	}
+	server.Services[svc.ID] = svc
+
+	return s.changed(server, svc, service.UNKNOWN, now), nil
+}
+
+// Move an instance to a new status, one of the service package's
+func (s *Sidecar) SetStatus(id string, status int) (catalog.StateChangedEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, server := range s.servers {
+		svc, ok := server.Services[id]
+		if !ok {
+			continue
+		}
+
+		now := s.Now()
+		previous := svc.Status
+		svc.Status = status
+		svc.Updated = now
+
+		return s.changed(server, svc, previous, now), nil
+	}
+
+	return catalog.StateChangedEvent{}, ErrNoSuchService
+}
+
+func (s *Sidecar) Fail(id string) (catalog.StateChangedEvent, error) {
+	return s.SetStatus(id, service.UNHEALTHY)
+}
+
+func (s *Sidecar) Recover(id string) (catalog.StateChangedEvent, error) {
+	return s.SetStatus(id, service.ALIVE)
+}
+
+// Take an instance away for good
+func (s *Sidecar) Stop(id string) (catalog.StateChangedEvent, error) {
+	return s.SetStatus(id, service.TOMBSTONE)
+}
+
+// Swing an instance between unhealthy and alive, for flapping scenarios
+func (s *Sidecar) Flap(id string, times int) ([]catalog.StateChangedEvent, error) {
+	var events []catalog.StateChangedEvent
+	for i := 0; i < times; i++ {
+		evt, err := s.Fail(id)
+		if err != nil {
+			return nil, err
+		}
+
+		recovered, err := s.Recover(id)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, evt, recovered)
+	}
+
+	return events, nil
+}
+
+// Replace every running instance of a service with one on a new version,
+// starting the new one before stopping the old, like a rolling deploy
+func (s *Sidecar) Deploy(name string, version string) ([]catalog.StateChangedEvent, error) {
+	var running []service.Service
+	s.lock.Lock()
+	for _, server := range s.servers {
+		for _, svc := range server.Services {
+			if svc.Name == name && svc.Status != service.TOMBSTONE {
+				running = append(running, *svc)
+			}
+		}
+	}
+	s.lock.Unlock()
+
+	if len(running) == 0 {
+		return nil, ErrNoSuchService
+	}
+
+	var events []catalog.StateChangedEvent
+	for _, old := range running {
+		started, err := s.Start(name, old.Hostname, version)
+		if err != nil {
+			return nil, err
+		}
+
+		stopped, err := s.Stop(old.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, started, stopped)
+	}
+
+	return events, nil
+}
+
+// Describe a change along with a copy of the whole state, so later changes
+// don't show up in events already handed out
+func (s *Sidecar) changed(server *catalog.Server, svc *service.Service, previous int, now time.Time) catalog.StateChangedEvent {
+	server.LastUpdated = now
+	server.LastChanged = now
+	s.lastChanged = now
+
+	state := catalog.ServicesState{
+		Servers:     make(map[string]*catalog.Server, len(s.servers)),
+		LastChanged: s.lastChanged,
+		ClusterName: s.ClusterName,
+		Hostname:    s.Reporter,
+	}
+
+	for name, server := range s.servers {
+		copied := &catalog.Server{
+			Name:        server.Name,
+			Services:    make(map[string]*service.Service, len(server.Services)),
+			LastUpdated: server.LastUpdated,
+			LastChanged: server.LastChanged,
+		}
+		for id, svc := range server.Services {
+			svcCopy := *svc
+			copied.Services[id] = &svcCopy
+		}
+		state.Servers[name] = copied
+	}
+
+	return catalog.StateChangedEvent{
+		State: state,
+		ChangeEvent: catalog.ChangeEvent{
+			Service:        *svc,
+			PreviousStatus: previous,
+			Time:           now,
+		},
+	}
+}
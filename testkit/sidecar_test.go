@@ -0,0 +1,62 @@
+package testkit
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Sidecar(t *testing.T) {
+	Convey("A fake Sidecar", t, func() {
+		sidecar := NewSidecar("france", "verdun", "somme")
+
+		Convey("Starts services with the full state attached", func() {
+			evt, err := sidecar.Start("artillery", "somme", "1")
+			So(err, ShouldBeNil)
+			So(evt.State.ClusterName, ShouldEqual, "france")
+			So(evt.State.Hostname, ShouldEqual, "verdun")
+			So(evt.ChangeEvent.PreviousStatus, ShouldEqual, service.UNKNOWN)
+			So(evt.ChangeEvent.Service.Image, ShouldEqual, "artillery:1")
+			So(evt.State.Servers["somme"].Services, ShouldContainKey, evt.ChangeEvent.Service.ID)
+			So(evt.State.Servers, ShouldContainKey, "verdun")
+		})
+
+		Convey("Refuses hosts and instances it doesn't have", func() {
+			_, err := sidecar.Start("artillery", "ypres", "1")
+			So(err, ShouldNotBeNil)
+
+			_, err = sidecar.Fail("nope")
+			So(err, ShouldEqual, ErrNoSuchService)
+		})
+
+		Convey("Changes status without touching events already sent", func() {
+			started, _ := sidecar.Start("artillery", "somme", "1")
+			id := started.ChangeEvent.Service.ID
+
+			failed, err := sidecar.Fail(id)
+			So(err, ShouldBeNil)
+			So(failed.ChangeEvent.PreviousStatus, ShouldEqual, service.ALIVE)
+			So(failed.ChangeEvent.Service.Status, ShouldEqual, service.UNHEALTHY)
+			So(started.State.Servers["somme"].Services[id].Status, ShouldEqual, service.ALIVE)
+
+			flaps, err := sidecar.Flap(id, 2)
+			So(err, ShouldBeNil)
+			So(len(flaps), ShouldEqual, 4)
+		})
+
+		Convey("Deploys by replacing every instance", func() {
+			sidecar.Start("artillery", "verdun", "1")
+			sidecar.Start("artillery", "somme", "1")
+
+			events, err := sidecar.Deploy("artillery", "2")
+			So(err, ShouldBeNil)
+			So(len(events), ShouldEqual, 4)
+			So(events[0].ChangeEvent.Service.Image, ShouldEqual, "artillery:2")
+			So(events[1].ChangeEvent.Service.Status, ShouldEqual, service.TOMBSTONE)
+
+			_, err = sidecar.Deploy("cavalry", "2")
+			So(err, ShouldEqual, ErrNoSuchService)
+		})
+	})
+}
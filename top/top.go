@@ -0,0 +1,323 @@
+package top
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/client"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_REFRESH   = 5 * time.Second // How often to fetch the clusters and flapping
+	DEFAULT_FEED_SIZE = 15
+	FLAPPING_ROWS     = 5
+
+	RED    = "\033[31m"
+	GREEN  = "\033[32m"
+	YELLOW = "\033[33m"
+	BOLD   = "\033[1m"
+	RESET  = "\033[0m"
+)
+
+// How one cluster is doing, for the summary table
+type ClusterHealth struct {
+	datatypes.ClusterLastSeen
+	Services  int
+	Instances int
+	Unhealthy int
+}
+
+// A live view of a running superside for a terminal: the health of each
+// cluster, what's flapping and the latest events as they come in. It's a
+// Bubble Tea model, so the terminal handling, keys and resizing come from
+// there, and all the fetching happens in commands off the UI goroutine.
+type Dashboard struct {
+	Client   *client.Client
+	Refresh  time.Duration
+	FeedSize int
+	clusters []ClusterHealth
+	flapping []datatypes.FlapStatus
+	feed     []*datatypes.Notification // Newest last
+	lastErr  error
+	width    int // Of the terminal, 0 until we're told
+	height   int
+	ctx      context.Context
+	notices  <-chan *datatypes.Notification
+}
+
+// The stored events we started with
+type stateMsg struct {
+	events []datatypes.Notification
+	err    error
+}
+
+// The results of fetching the cluster health and flapping services
+type healthMsg struct {
+	clusters []ClusterHealth
+	flapping []datatypes.FlapStatus
+	err      error
+}
+
+// A new event from the stream
+type noticeMsg struct {
+	notice *datatypes.Notification
+}
+
+// Time to fetch the cluster health again
+type refreshMsg struct{}
+
+func NewDashboard(c *client.Client) *Dashboard {
+	return &Dashboard{
+		Client:   c,
+		Refresh:  DEFAULT_REFRESH,
+		FeedSize: DEFAULT_FEED_SIZE,
+	}
+}
+
+// Take over the terminal until the context is done or someone presses q
+func (d *Dashboard) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	d.ctx = ctx
+	d.notices = d.Client.StreamNotifications(ctx, client.Filters{})
+
+	program := tea.NewProgram(d,
+		tea.WithContext(ctx), tea.WithInput(in), tea.WithOutput(out), tea.WithAltScreen(),
+	)
+	_, err := program.Run()
+	if err == tea.ErrProgramKilled && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (d *Dashboard) Init() tea.Cmd {
+	return tea.Batch(d.fetchState, d.fetchHealth, d.waitForNotice)
+}
+
+func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return d, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		d.width, d.height = msg.Width, msg.Height
+
+	case stateMsg:
+		if msg.err != nil {
+			d.lastErr = msg.err
+		}
+		// Anything streamed in already is newer than the stored events
+		streamed := d.feed
+		d.feed = nil
+		for i := range msg.events {
+			d.add(&msg.events[i])
+		}
+		for _, notice := range streamed {
+			d.add(notice)
+		}
+
+	case noticeMsg:
+		d.add(msg.notice)
+		return d, d.waitForNotice
+
+	case healthMsg:
+		d.lastErr = msg.err
+		if msg.err == nil {
+			d.clusters = msg.clusters
+			d.flapping = msg.flapping
+		}
+		return d, tea.Tick(d.Refresh, func(time.Time) tea.Msg { return refreshMsg{} })
+
+	case refreshMsg:
+		return d, d.fetchHealth
+	}
+
+	return d, nil
+}
+
+func (d *Dashboard) View() string {
+	var screen bytes.Buffer
+	d.render(&screen, time.Now())
+	return clip(screen.String(), d.width, d.height)
+}
+
+// Keep the latest events, skipping heartbeats
+func (d *Dashboard) add(notice *datatypes.Notification) {
+	if notice.Type == datatypes.HEARTBEAT_NOTICE {
+		return
+	}
+
+	d.feed = append(d.feed, notice)
+	if len(d.feed) > d.FeedSize {
+		d.feed = d.feed[len(d.feed)-d.FeedSize:]
+	}
+}
+
+// Fetch only as many stored events as the feed shows
+func (d *Dashboard) fetchState() tea.Msg {
+	events, err := d.Client.GetState(d.ctx, client.Filters{Limit: d.FeedSize})
+	return stateMsg{events: events, err: err}
+}
+
+// Wait for the next streamed event. The stream only closes when we're done.
+func (d *Dashboard) waitForNotice() tea.Msg {
+	notice, ok := <-d.notices
+	if !ok {
+		return nil
+	}
+	return noticeMsg{notice: notice}
+}
+
+// Fetch the cluster health and flapping services
+func (d *Dashboard) fetchHealth() tea.Msg {
+	var clusters []ClusterHealth
+	flapping, err := d.Client.GetFlapping(d.ctx)
+
+	if err == nil {
+		var seen []datatypes.ClusterLastSeen
+		seen, err = d.Client.GetClusters(d.ctx)
+
+		for _, cluster := range seen {
+			health := ClusterHealth{ClusterLastSeen: cluster}
+
+			// Clusters we only know from the watchdog may have no view yet
+			view, viewErr := d.Client.GetCluster(d.ctx, cluster.ClusterName)
+			if viewErr == nil {
+				summarize(&health, view)
+			}
+			clusters = append(clusters, health)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].ClusterName < clusters[j].ClusterName
+	})
+
+	return healthMsg{clusters: clusters, flapping: flapping, err: err}
+}
+
+func summarize(health *ClusterHealth, view *datatypes.ClusterView) {
+	health.Services = len(view.Services)
+	for _, svc := range view.Services {
+		health.Instances += len(svc.Instances)
+		for _, instance := range svc.Instances {
+			if instance.Status == service.StatusString(service.UNHEALTHY) {
+				health.Unhealthy += 1
+			}
+		}
+	}
+}
+
+// Lay out the whole screen
+func (d *Dashboard) render(out io.Writer, now time.Time) {
+	fmt.Fprintf(out, "%ssuperside top%s  %s  %s  (q to quit)\n",
+		BOLD, RESET, d.Client.BaseURL, now.Format("15:04:05"),
+	)
+	if d.lastErr != nil {
+		fmt.Fprintf(out, "%s%s%s\n", RED, d.lastErr.Error(), RESET)
+	}
+
+	fmt.Fprintf(out, "\n%s%-30s %8s %9s %9s  %s%s\n",
+		BOLD, "CLUSTER", "SERVICES", "INSTANCES", "UNHEALTHY", "LAST SEEN", RESET,
+	)
+	for _, cluster := range d.clusters {
+		name := cluster.ClusterName
+		colour := GREEN
+		switch {
+		case cluster.Silent:
+			name += " (silent)"
+			colour = RED
+		case cluster.Unhealthy > 0:
+			colour = YELLOW
+		}
+
+		fmt.Fprintf(out, "%s%-30s%s %8d %9d %9d  %s\n",
+			colour, truncate.StringWithTail(name, 30, "~"), RESET,
+			cluster.Services, cluster.Instances, cluster.Unhealthy, ago(now, cluster.LastSeen),
+		)
+	}
+
+	fmt.Fprintf(out, "\n%sFLAPPING%s\n", BOLD, RESET)
+	if len(d.flapping) == 0 {
+		fmt.Fprint(out, "  Nothing\n")
+	}
+	for i, flap := range d.flapping {
+		if i == FLAPPING_ROWS {
+			fmt.Fprintf(out, "  ...and %d more\n", len(d.flapping)-FLAPPING_ROWS)
+			break
+		}
+		fmt.Fprintf(out, "  %s[%s] %s%s  %d transitions in %s\n",
+			YELLOW, flap.ClusterName, flap.Service, RESET, flap.Transitions, flap.Window,
+		)
+	}
+
+	fmt.Fprintf(out, "\n%sEVENTS%s\n", BOLD, RESET)
+	for i := len(d.feed) - 1; i >= 0; i-- {
+		fmt.Fprintf(out, "  %s\n", describe(d.feed[i]))
+	}
+}
+
+// One line for the event feed, coloured by how bad it is
+func describe(notice *datatypes.Notification) string {
+	when := notice.ReceivedAt.Local().Format("15:04:05")
+
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return fmt.Sprintf("%s %s[%s] %s%s", when, YELLOW, notice.ClusterName, notice.Type, RESET)
+	}
+
+	colour := ""
+	switch {
+	case notice.IsCritical():
+		colour = RED
+	case notice.Event.Service.Status == service.ALIVE:
+		colour = GREEN
+	}
+
+	svc := notice.Event.Service
+	return fmt.Sprintf("%s %s[%s] %s on %s  %s%s",
+		when, colour, notice.ClusterName, svc.Name, svc.Hostname, notice.Transition(), RESET,
+	)
+}
+
+// Cut the screen down to fit the terminal, keeping the colours intact. The
+// events go first when it's too short, as they're at the bottom.
+func clip(screen string, width int, height int) string {
+	lines := strings.Split(strings.TrimSuffix(screen, "\n"), "\n")
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	if width > 0 {
+		for i, line := range lines {
+			if clipped := truncate.StringWithTail(line, uint(width), "~"); clipped != line {
+				lines[i] = clipped + RESET // The colour may have been cut short
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// e.g. "12s ago", or "never"
+func ago(now time.Time, then time.Time) string {
+	if then.IsZero() {
+		return "never"
+	}
+	since := now.Sub(then).Truncate(time.Second).String()
+	if strings.HasSuffix(since, "m0s") {
+		since = strings.TrimSuffix(since, "0s")
+	}
+	return since + " ago"
+}
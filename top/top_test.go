@@ -0,0 +1,145 @@
+package top
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/client"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/server"
+	"github.com/nitro/superside/store"
+	"github.com/nitro/superside/tracker"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Dashboard(t *testing.T) {
+	Convey("The dashboard", t, func() {
+		state := tracker.NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+		superside := httptest.NewServer(server.New(state, server.WithUIPath("")).Handler())
+		defer superside.Close()
+
+		listener := state.GetSvcEventsListener()
+		for _, status := range []int{service.ALIVE, service.UNHEALTHY} {
+			state.EnqueueUpdate(catalog.StateChangedEvent{
+				State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+				ChangeEvent: catalog.ChangeEvent{
+					Service:        service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: status},
+					PreviousStatus: service.ALIVE,
+					Time:           time.Now().UTC(),
+				},
+			})
+			<-listener
+		}
+		state.RemoveSvcEventsListener(listener)
+
+		dashboard := NewDashboard(client.New(superside.URL))
+		dashboard.ctx = context.Background()
+		now := time.Now()
+
+		Convey("Sums up each cluster's health", func() {
+			health := dashboard.fetchHealth().(healthMsg)
+
+			So(health.err, ShouldBeNil)
+			So(len(health.clusters), ShouldEqual, 1)
+			So(health.clusters[0].ClusterName, ShouldEqual, "france")
+			So(health.clusters[0].Services, ShouldEqual, 1)
+			So(health.clusters[0].Instances, ShouldEqual, 1)
+			So(health.clusters[0].Unhealthy, ShouldEqual, 1)
+
+			_, next := dashboard.Update(health)
+			So(dashboard.clusters, ShouldResemble, health.clusters)
+			So(next, ShouldNotBeNil) // The next refresh
+		})
+
+		Convey("Fetches only as many stored events as it shows", func() {
+			dashboard.FeedSize = 1
+			state := dashboard.fetchState().(stateMsg)
+
+			So(state.err, ShouldBeNil)
+			So(len(state.events), ShouldEqual, 1)
+			So(state.events[0].Event.Service.Status, ShouldEqual, service.UNHEALTHY)
+		})
+
+		Convey("Puts the stored events before any streamed in already", func() {
+			dashboard.Update(noticeMsg{notice: &datatypes.Notification{ID: "somme"}})
+			dashboard.Update(stateMsg{events: []datatypes.Notification{{ID: "verdun"}}})
+
+			So(len(dashboard.feed), ShouldEqual, 2)
+			So(dashboard.feed[0].ID, ShouldEqual, "verdun")
+			So(dashboard.feed[1].ID, ShouldEqual, "somme")
+		})
+
+		Convey("Draws the clusters, flapping services and events", func() {
+			dashboard.Update(dashboard.fetchHealth())
+			dashboard.flapping = []datatypes.FlapStatus{{ClusterName: "france", Service: "somme", Transitions: 7, Window: 10 * time.Minute}}
+			dashboard.add(&datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Severity:    datatypes.SEVERITY_CRITICAL,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+					PreviousStatus: service.ALIVE,
+				},
+			})
+
+			var screen bytes.Buffer
+			dashboard.render(&screen, now)
+
+			So(screen.String(), ShouldContainSubstring, "france")
+			So(screen.String(), ShouldContainSubstring, "[france] somme"+RESET+"  7 transitions in 10m0s")
+			So(screen.String(), ShouldContainSubstring, RED+"[france] verdun on meuse  Alive->Unhealthy")
+		})
+
+		Convey("Keeps only the latest events", func() {
+			dashboard.FeedSize = 2
+			for _, name := range []string{"verdun", "somme", "marne"} {
+				dashboard.add(&datatypes.Notification{ID: name})
+			}
+			dashboard.add(&datatypes.Notification{Type: datatypes.HEARTBEAT_NOTICE})
+
+			So(len(dashboard.feed), ShouldEqual, 2)
+			So(dashboard.feed[1].ID, ShouldEqual, "marne")
+		})
+
+		Convey("Fits the screen to the terminal", func() {
+			dashboard.Update(tea.WindowSizeMsg{Width: 20, Height: 3})
+			lines := strings.Split(dashboard.View(), "\n")
+
+			So(len(lines), ShouldEqual, 3)
+			So(lines[0], ShouldEqual, BOLD+"superside top"+RESET+"  http~"+RESET)
+		})
+
+		Convey("Quits when q is pressed", func() {
+			_, cmd := dashboard.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+			So(cmd(), ShouldResemble, tea.Quit())
+		})
+
+		Convey("Runs until it's told to stop", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			blocked, _ := io.Pipe()
+			var screen bytes.Buffer
+			So(dashboard.Run(ctx, blocked, &screen), ShouldBeNil)
+			So(screen.String(), ShouldContainSubstring, "superside top")
+		})
+	})
+}
+
+func Test_ago(t *testing.T) {
+	Convey("ago() reads naturally", t, func() {
+		now := time.Now()
+		So(ago(now, now.Add(-12*time.Second)), ShouldEqual, "12s ago")
+		So(ago(now, now.Add(-2*time.Minute)), ShouldEqual, "2m ago")
+		So(ago(now, time.Time{}), ShouldEqual, "never")
+	})
+}
@@ -0,0 +1,150 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DEFAULT_ANOMALY_WINDOW     = time.Minute
+	DEFAULT_ANOMALY_MIN_EVENTS = 20  // Fewer transitions in a window are never a storm
+	ANOMALY_WARMUP_WINDOWS     = 10  // Windows to see before we trust the baseline
+	ANOMALY_SMOOTHING          = 0.1 // How much each window moves the baseline
+	MAX_ANOMALY_GAP_WINDOWS    = 60  // Quiet windows folded in at most, after a gap
+)
+
+// Watches how fast each cluster's services change state and notices when a
+// window has many more transitions than usual, e.g. a storm of tombstones,
+// which is an early warning before the alerts for each service pile up.
+// The baseline is a moving average of the transitions per Window. A zero
+// Factor turns it off.
+type AnomalyDetector struct {
+	Factor    float64 // How many times the baseline is anomalous
+	Window    time.Duration
+	MinEvents int
+	clusters  map[string]*clusterRate
+	lock      sync.Mutex
+}
+
+// The rate of change in one cluster
+type clusterRate struct {
+	windowStart time.Time
+	count       int
+	statuses    map[string]int // New status => transitions this window
+	baseline    float64
+	windows     int  // Seen so far, for the warmup
+	anomalous   bool // Already reported this storm
+}
+
+func NewAnomalyDetector(factor float64) *AnomalyDetector {
+	return &AnomalyDetector{
+		Factor:    factor,
+		Window:    DEFAULT_ANOMALY_WINDOW,
+		MinEvents: DEFAULT_ANOMALY_MIN_EVENTS,
+		clusters:  make(map[string]*clusterRate, 5),
+	}
+}
+
+// Count a transition. Returns the anomaly the first time a window goes
+// over the threshold in a storm, nil otherwise.
+func (d *AnomalyDetector) Record(notice *datatypes.Notification, now time.Time) *datatypes.Anomaly {
+	if d.Factor <= 0 || notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil ||
+		notice.Event.PreviousStatus == notice.Event.Service.Status {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	rate, ok := d.clusters[notice.ClusterName]
+	if !ok {
+		rate = &clusterRate{windowStart: now.Truncate(d.Window), statuses: make(map[string]int)}
+		d.clusters[notice.ClusterName] = rate
+	}
+	d.advance(rate, now)
+
+	rate.count += 1
+	rate.statuses[datatypes.StatusString(notice.Event.Service.Status)] += 1
+
+	threshold := rate.baseline * d.Factor
+	if rate.anomalous || rate.windows < ANOMALY_WARMUP_WINDOWS ||
+		rate.count < d.MinEvents || float64(rate.count) <= threshold {
+		return nil
+	}
+	rate.anomalous = true
+
+	statuses := make(map[string]int, len(rate.statuses))
+	for status, count := range rate.statuses {
+		statuses[status] = count
+	}
+
+	return &datatypes.Anomaly{
+		ClusterName: notice.ClusterName,
+		Transitions: rate.count,
+		Baseline:    rate.baseline,
+		Factor:      d.Factor,
+		Window:      d.Window,
+		Statuses:    statuses,
+	}
+}
+
+// Fold the finished windows into the baseline and start the current one.
+// Storm windows are left out, so a long storm doesn't become normal.
+func (d *AnomalyDetector) advance(rate *clusterRate, now time.Time) {
+	start := now.Truncate(d.Window)
+	if !start.After(rate.windowStart) {
+		return
+	}
+
+	finished := []int{rate.count}
+	gap := int(start.Sub(rate.windowStart)/d.Window) - 1
+	if gap > MAX_ANOMALY_GAP_WINDOWS {
+		gap = MAX_ANOMALY_GAP_WINDOWS
+	}
+	for i := 0; i < gap; i++ {
+		finished = append(finished, 0)
+	}
+
+	for _, count := range finished {
+		if rate.anomalous && count > 0 {
+			continue
+		}
+		if rate.windows == 0 {
+			rate.baseline = float64(count)
+		} else {
+			rate.baseline += ANOMALY_SMOOTHING * (float64(count) - rate.baseline)
+		}
+		rate.windows += 1
+	}
+
+	// The storm is over once a whole window passes without one
+	if rate.anomalous && float64(rate.count) <= rate.baseline*d.Factor {
+		rate.anomalous = false
+	}
+	if gap > 0 {
+		rate.anomalous = false
+	}
+
+	rate.windowStart = start
+	rate.count = 0
+	rate.statuses = make(map[string]int)
+}
+
+// Announce an anomaly spotted on the event in notice
+func anomalyNotice(notice *datatypes.Notification, anomaly *datatypes.Anomaly) *datatypes.Notification {
+	return &datatypes.Notification{
+		ID:                  uuid.NewV4().String(),
+		Type:                datatypes.ANOMALY_NOTICE,
+		ClusterName:         notice.ClusterName,
+		OriginalClusterName: notice.OriginalClusterName,
+		Region:              notice.Region,
+		CorrelationID:       notice.CorrelationID,
+		Severity:            datatypes.SEVERITY_WARNING,
+		ReceivedAt:          notice.ReceivedAt,
+		Source:              datatypes.SUPERSIDE_SOURCE,
+		Anomaly:             anomaly,
+	}
+}
@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_AnomalyDetector(t *testing.T) {
+	Convey("The anomaly detector", t, func() {
+		detector := NewAnomalyDetector(5)
+		detector.MinEvents = 10
+		start := time.Date(1916, time.July, 1, 7, 30, 0, 0, time.UTC)
+
+		event := func(cluster string, status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: cluster,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: "somme", Status: status},
+					PreviousStatus: service.ALIVE,
+				},
+			}
+		}
+
+		// Record count transitions to status in the window starting at 'at',
+		// returning the first anomaly
+		storm := func(cluster string, at time.Time, count int, status int) *datatypes.Anomaly {
+			var found *datatypes.Anomaly
+			for i := 0; i < count; i++ {
+				anomaly := detector.Record(event(cluster, status), at.Add(time.Duration(i)*time.Millisecond))
+				if found == nil {
+					found = anomaly
+				}
+			}
+			return found
+		}
+
+		// A steady couple of transitions a minute
+		warmUp := func(cluster string) time.Time {
+			at := start
+			for i := 0; i < ANOMALY_WARMUP_WINDOWS; i++ {
+				So(storm(cluster, at, 2, service.UNHEALTHY), ShouldBeNil)
+				at = at.Add(time.Minute)
+			}
+			return at
+		}
+
+		Convey("Reports a window with many more transitions than usual", func() {
+			at := warmUp("france")
+
+			anomaly := storm("france", at, 30, service.TOMBSTONE)
+			So(anomaly, ShouldNotBeNil)
+			So(anomaly.ClusterName, ShouldEqual, "france")
+			So(anomaly.Transitions, ShouldEqual, 11)
+			So(anomaly.Baseline, ShouldAlmostEqual, 2.0)
+			So(anomaly.MostlyStatus(), ShouldEqual, "Tombstone")
+		})
+
+		Convey("Reports each storm only once", func() {
+			at := warmUp("france")
+
+			So(storm("france", at, 30, service.TOMBSTONE), ShouldNotBeNil)
+			So(storm("france", at.Add(time.Minute), 30, service.TOMBSTONE), ShouldBeNil)
+
+			Convey("and reports the next one after it calms down", func() {
+				So(storm("france", at.Add(2*time.Minute), 2, service.ALIVE), ShouldBeNil)
+				So(storm("france", at.Add(3*time.Minute), 30, service.TOMBSTONE), ShouldNotBeNil)
+			})
+		})
+
+		Convey("Doesn't let a storm become the baseline", func() {
+			at := warmUp("france")
+			storm("france", at, 30, service.TOMBSTONE)
+			storm("france", at.Add(time.Minute), 2, service.ALIVE)
+
+			So(detector.clusters["france"].baseline, ShouldAlmostEqual, 2.0)
+		})
+
+		Convey("Waits until it has a baseline", func() {
+			So(storm("france", start, 30, service.TOMBSTONE), ShouldBeNil)
+		})
+
+		Convey("Ignores small bursts in quiet clusters", func() {
+			at := warmUp("france")
+			So(storm("france", at, 9, service.TOMBSTONE), ShouldBeNil)
+		})
+
+		Convey("Keeps the clusters apart", func() {
+			at := warmUp("france")
+			warmUp("belgium")
+
+			So(storm("belgium", at, 30, service.TOMBSTONE), ShouldNotBeNil)
+			So(storm("france", at, 3, service.TOMBSTONE), ShouldBeNil)
+		})
+
+		Convey("Only counts real transitions", func() {
+			at := warmUp("france")
+
+			for i := 0; i < 30; i++ {
+				So(detector.Record(event("france", service.ALIVE), at), ShouldBeNil)
+				So(detector.Record(&datatypes.Notification{Type: datatypes.DEPLOY_NOTICE, ClusterName: "france"}, at), ShouldBeNil)
+			}
+		})
+
+		Convey("Does nothing when turned off", func() {
+			detector.Factor = 0
+			at := warmUp("france")
+			So(storm("france", at, 30, service.TOMBSTONE), ShouldBeNil)
+		})
+	})
+}
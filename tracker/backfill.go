@@ -0,0 +1,38 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Fill in the history from another instance's events, oldest first, e.g. when
+// a fresh replica starts up. Only the events newer than everything we have
+// are taken, so the history stays in order. Nobody is notified about them
+// again. Returns how many were stored.
+func (t *Tracker) Backfill(notices []datatypes.Notification) int {
+	var newest time.Time
+	existing := t.storedEvents()
+	known := make(map[string]bool, len(existing))
+	for _, notice := range existing {
+		known[notice.ID] = true
+		if notice.ReceivedAt.After(newest) {
+			newest = notice.ReceivedAt
+		}
+	}
+
+	stored := 0
+	for i := range notices {
+		notice := &notices[i]
+		if notice.Event == nil || known[notice.ID] || !notice.ReceivedAt.After(newest) {
+			continue
+		}
+
+		t.restoreEvent(notice)
+		known[notice.ID] = true
+		newest = notice.ReceivedAt
+		stored += 1
+	}
+
+	return stored
+}
@@ -0,0 +1,54 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Backfill(t *testing.T) {
+	Convey("Backfilling from a peer", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		baseTime := time.Now().UTC()
+
+		noticeAt := func(id string, offset time.Duration) datatypes.Notification {
+			notice := noticeFor("artillery", service.UNHEALTHY, baseTime.Add(offset))
+			notice.ID = id
+			notice.ReceivedAt = baseTime.Add(offset)
+			return notice
+		}
+
+		Convey("Stores the peer's events", func() {
+			stored := state.Backfill([]datatypes.Notification{
+				noticeAt("1", 0), noticeAt("2", time.Second),
+			})
+
+			So(stored, ShouldEqual, 2)
+			events := state.GetSvcEventsList()
+			So(events[0].ID, ShouldEqual, "1")
+			So(events[1].ID, ShouldEqual, "2")
+			So(state.GetClusterLastSeen(), ShouldNotBeEmpty)
+		})
+
+		Convey("Only takes events newer than the ones it has", func() {
+			state.Backfill([]datatypes.Notification{noticeAt("2", time.Second)})
+
+			stored := state.Backfill([]datatypes.Notification{
+				noticeAt("1", 0), noticeAt("2", time.Second), noticeAt("3", 2*time.Second),
+			})
+
+			So(stored, ShouldEqual, 1)
+			So(len(state.GetSvcEventsList()), ShouldEqual, 2)
+		})
+
+		Convey("Doesn't tell the listeners", func() {
+			listener := state.GetSvcEventsListener()
+			state.Backfill([]datatypes.Notification{noticeAt("1", 0)})
+			So(len(listener), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DEFAULT_COALESCE_MAX_SIZE = 200 // Events in a burst before it goes out anyway
+	STORAGE_LISTENER_SIZE     = 500
+)
+
+// Groups the service events that arrive within Window of the first one in
+// a cluster into a single BURST_NOTICE, so a deploy touching hundreds of
+// instances is one message for websockets and notifiers rather than
+// hundreds. Storage listeners still get every event. A zero Window turns
+// it off.
+type Coalescer struct {
+	Window  time.Duration
+	MaxSize int
+	pending map[string][]*datatypes.Notification // Cluster => events waiting
+	started map[string]time.Time                 // Cluster => when its first event arrived
+	lock    sync.Mutex
+}
+
+func NewCoalescer(window time.Duration) *Coalescer {
+	return &Coalescer{
+		Window:  window,
+		MaxSize: DEFAULT_COALESCE_MAX_SIZE,
+		pending: make(map[string][]*datatypes.Notification, 5),
+		started: make(map[string]time.Time, 5),
+	}
+}
+
+func (c *Coalescer) Enabled() bool {
+	return c.Window > 0
+}
+
+// Hold on to a service event until its burst is due. Returns false for
+// anything else, or when turned off, and it should go out right away.
+func (c *Coalescer) Add(notice *datatypes.Notification, now time.Time) bool {
+	if !c.Enabled() || notice.Type != datatypes.SERVICE_EVENT_NOTICE {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.pending[notice.ClusterName]) == 0 {
+		c.started[notice.ClusterName] = now
+	}
+	c.pending[notice.ClusterName] = append(c.pending[notice.ClusterName], notice)
+
+	return true
+}
+
+// Return the bursts whose Window has passed, or that are full. Bursts of
+// one come back as the event itself.
+func (c *Coalescer) Due(now time.Time) []*datatypes.Notification {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var due []*datatypes.Notification
+	for clusterName, notices := range c.pending {
+		if now.Sub(c.started[clusterName]) < c.Window && len(notices) < c.MaxSize {
+			continue
+		}
+
+		due = append(due, burstNotice(notices))
+		delete(c.pending, clusterName)
+		delete(c.started, clusterName)
+	}
+
+	return due
+}
+
+// Wrap up the events that arrived together
+func burstNotice(notices []*datatypes.Notification) *datatypes.Notification {
+	if len(notices) == 1 {
+		return notices[0]
+	}
+
+	first := notices[0]
+	burst := &datatypes.Notification{
+		ID:                  uuid.NewV4().String(),
+		Type:                datatypes.BURST_NOTICE,
+		ClusterName:         first.ClusterName,
+		OriginalClusterName: first.OriginalClusterName,
+		Region:              first.Region,
+		CorrelationID:       first.CorrelationID,
+		Severity:            first.Severity,
+		ReceivedAt:          first.ReceivedAt,
+		Source:              datatypes.SUPERSIDE_SOURCE,
+		Burst:               notices,
+	}
+	for _, notice := range notices[1:] {
+		burst.Severity = datatypes.WorseSeverity(burst.Severity, notice.Severity)
+	}
+
+	return burst
+}
+
+// Subscribe a listener that gets every notification on its own, even when
+// bursts are coalesced, for sinks and anything else that stores them
+func (t *Tracker) GetStorageListener() chan *datatypes.Notification {
+	listenChan := make(chan *datatypes.Notification, STORAGE_LISTENER_SIZE)
+
+	t.listenLock.Lock()
+	t.storageListeners = append(t.storageListeners, listenChan)
+	t.listenLock.Unlock()
+
+	return listenChan
+}
+
+func (t *Tracker) RemoveStorageListener(victim chan *datatypes.Notification) {
+	t.listenLock.Lock()
+	defer t.listenLock.Unlock()
+
+	for i, listener := range t.storageListeners {
+		if listener == victim {
+			t.storageListeners = append(t.storageListeners[:i], t.storageListeners[i+1:]...)
+			close(listener)
+			return
+		}
+	}
+}
+
+// Loop until stopped, sending out the bursts as they come due
+func (t *Tracker) flushBursts() {
+	for {
+		select {
+		case now := <-time.After(t.Coalescer.Window / 2):
+			for _, burst := range t.Coalescer.Due(now.UTC()) {
+				t.broadcast(burst)
+			}
+		case <-t.quit:
+			return
+		}
+	}
+}
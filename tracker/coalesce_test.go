@@ -0,0 +1,131 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Coalescer(t *testing.T) {
+	Convey("The coalescer", t, func() {
+		coalescer := NewCoalescer(2 * time.Second)
+		now := time.Date(1916, time.July, 1, 7, 30, 0, 0, time.UTC)
+
+		event := func(cluster string, status int, severity string) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: cluster,
+				Severity:    severity,
+				Event:       &catalog.ChangeEvent{Service: service.Service{Name: "somme", Status: status}},
+			}
+		}
+
+		Convey("Groups the events in a window into one burst per cluster", func() {
+			first := event("france", service.ALIVE, datatypes.SEVERITY_INFO)
+			second := event("france", service.UNHEALTHY, datatypes.SEVERITY_WARNING)
+			So(coalescer.Add(first, now), ShouldBeTrue)
+			So(coalescer.Add(second, now.Add(time.Second)), ShouldBeTrue)
+			So(coalescer.Add(event("belgium", service.ALIVE, ""), now), ShouldBeTrue)
+
+			So(coalescer.Due(now.Add(time.Second)), ShouldBeEmpty)
+
+			due := coalescer.Due(now.Add(2 * time.Second))
+			So(len(due), ShouldEqual, 2)
+			for _, burst := range due {
+				if burst.ClusterName == "belgium" {
+					So(burst.Type, ShouldEqual, datatypes.SERVICE_EVENT_NOTICE)
+					continue
+				}
+				So(burst.Type, ShouldEqual, datatypes.BURST_NOTICE)
+				So(burst.Burst, ShouldResemble, []*datatypes.Notification{first, second})
+				So(burst.Severity, ShouldEqual, datatypes.SEVERITY_WARNING)
+			}
+
+			So(coalescer.Due(now.Add(time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("Sends full bursts right away", func() {
+			coalescer.MaxSize = 3
+			for i := 0; i < 3; i++ {
+				coalescer.Add(event("france", service.ALIVE, ""), now)
+			}
+
+			due := coalescer.Due(now)
+			So(len(due), ShouldEqual, 1)
+			So(len(due[0].Burst), ShouldEqual, 3)
+		})
+
+		Convey("Leaves everything else alone", func() {
+			So(coalescer.Add(&datatypes.Notification{Type: datatypes.HEARTBEAT_NOTICE}, now), ShouldBeFalse)
+
+			coalescer.Window = 0
+			So(coalescer.Add(event("france", service.ALIVE, ""), now), ShouldBeFalse)
+		})
+	})
+}
+
+func Test_StorageListener(t *testing.T) {
+	Convey("Storage listeners", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		state.Coalescer.Window = time.Hour
+		state.Coalescer.MaxSize = 2
+		storage := state.GetStorageListener()
+		defer state.RemoveStorageListener(storage)
+		listener := state.GetSvcEventsListener()
+		defer state.RemoveSvcEventsListener(listener)
+
+		Convey("Get every event while everyone else gets the burst", func() {
+			for _, status := range []int{service.UNHEALTHY, service.ALIVE} {
+				state.tellSvcEventListeners(&datatypes.Notification{
+					Type:        datatypes.SERVICE_EVENT_NOTICE,
+					ClusterName: "france",
+					Event:       &catalog.ChangeEvent{Service: service.Service{Status: status}},
+				})
+			}
+
+			So(len(storage), ShouldEqual, 2)
+			So((<-storage).Event.Service.Status, ShouldEqual, service.UNHEALTHY)
+
+			So(len(listener), ShouldEqual, 1)
+			burst := <-listener
+			So(burst.Type, ShouldEqual, datatypes.BURST_NOTICE)
+			So(len(burst.Burst), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Deployments are still tracked while coalescing", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		state.Coalescer.Window = time.Hour
+		deploys := state.GetDeploymentListener()
+		defer state.RemoveDeploymentListener(deploys)
+
+		go state.ProcessUpdates()
+		for state.Vars().StorageListeners == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		state.EnqueueUpdate(catalog.StateChangedEvent{
+			State: catalog.ServicesState{ClusterName: "france", Hostname: "verdun"},
+			ChangeEvent: catalog.ChangeEvent{
+				Service: service.Service{
+					ID: "1", Name: "artillery", Image: "artillery:2", Hostname: "verdun", Status: service.ALIVE,
+				},
+				PreviousStatus: service.UNKNOWN,
+				Time:           time.Now().UTC(),
+			},
+		})
+
+		select {
+		case deploy := <-deploys:
+			So(deploy.Name, ShouldEqual, "artillery")
+		case <-time.After(5 * time.Second):
+			So("no deployment", ShouldBeEmpty)
+		}
+		So(state.GetDeployments(), ShouldContainKey, "artillery")
+	})
+}
@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	COMPACTION_INTERVAL = 10 * time.Minute
+)
+
+// Decides which stored events are still worth keeping. Events older than
+// TTL are dropped altogether. Events older than CompactAfter are boiled
+// down to the last transition for each service instance, so a service that
+// flapped for an hour last week leaves behind the state it settled in
+// rather than every bounce. Annotated and acknowledged events are always
+// kept while they're inside the TTL, since someone cared about them. A zero
+// TTL or CompactAfter turns that part off.
+type Compactor struct {
+	CompactAfter time.Duration
+	TTL          time.Duration
+}
+
+func eventTime(notice *datatypes.Notification) time.Time {
+	if notice.Event != nil && !notice.Event.Time.IsZero() {
+		return notice.Event.Time
+	}
+	return notice.ReceivedAt
+}
+
+func (c *Compactor) Enabled() bool {
+	return c.CompactAfter > 0 || c.TTL > 0
+}
+
+// Work out which of the events, oldest first, to keep. Returns a function
+// for SvcEventsBuffer.Retain().
+func (c *Compactor) Plan(events []datatypes.Notification, now time.Time) func(*datatypes.Notification) bool {
+	drop := make(map[string]bool, len(events)/2)
+	latest := make(map[string]string, 50) // Instance => newest compactable event ID
+
+	for i := range events {
+		notice := &events[i]
+		age := now.Sub(eventTime(notice))
+
+		if c.TTL > 0 && age > c.TTL {
+			drop[notice.ID] = true
+			continue
+		}
+
+		if c.CompactAfter == 0 || age <= c.CompactAfter || notice.Event == nil ||
+			len(notice.Annotations) > 0 || notice.Ack != nil {
+			continue
+		}
+
+		// Anything older for the same instance is now superseded
+		key := notice.ClusterName + "/" + notice.Event.Service.Hostname + "/" + notice.Event.Service.ID
+		if previous, ok := latest[key]; ok {
+			drop[previous] = true
+		}
+		latest[key] = notice.ID
+	}
+
+	return func(notice *datatypes.Notification) bool {
+		return !drop[notice.ID]
+	}
+}
@@ -0,0 +1,79 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Compaction(t *testing.T) {
+	Convey("Compacting stored events", t, func() {
+		now := time.Date(1916, 11, 1, 12, 0, 0, 0, time.UTC)
+
+		change := func(id string, host string, status int, age time.Duration) *datatypes.Notification {
+			return &datatypes.Notification{
+				ID:          id,
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{ID: "1", Name: "verdun", Hostname: host, Status: status},
+					Time:    now.Add(-age),
+				},
+			}
+		}
+
+		state := NewTracker(20, &store.NoopStore{})
+		state.Compactor = &Compactor{CompactAfter: 24 * time.Hour, TTL: 30 * 24 * time.Hour}
+
+		remaining := func() []string {
+			var ids []string
+			for _, notice := range state.GetSvcEventsList() {
+				ids = append(ids, notice.ID)
+			}
+			return ids
+		}
+
+		Convey("Keeps only the last old transition for each instance", func() {
+			state.insertEvent(change("1", "meuse", service.UNHEALTHY, 72*time.Hour))
+			state.insertEvent(change("2", "meuse", service.ALIVE, 71*time.Hour))
+			state.insertEvent(change("3", "douaumont", service.UNHEALTHY, 70*time.Hour))
+			state.insertEvent(change("4", "meuse", service.UNHEALTHY, 48*time.Hour))
+			state.insertEvent(change("5", "meuse", service.ALIVE, time.Hour))
+			state.insertEvent(change("6", "meuse", service.UNHEALTHY, time.Minute))
+
+			So(state.compactEvents(now), ShouldEqual, 2)
+			So(remaining(), ShouldResemble, []string{"3", "4", "5", "6"})
+			So(state.SearchEvents("meuse"), ShouldHaveLength, 3)
+		})
+
+		Convey("Drops events past the TTL", func() {
+			state.insertEvent(change("1", "meuse", service.UNHEALTHY, 60*24*time.Hour))
+			state.insertEvent(change("2", "meuse", service.ALIVE, time.Hour))
+
+			So(state.compactEvents(now), ShouldEqual, 1)
+			So(remaining(), ShouldResemble, []string{"2"})
+		})
+
+		Convey("Keeps events someone annotated or acknowledged", func() {
+			state.insertEvent(change("1", "meuse", service.UNHEALTHY, 72*time.Hour))
+			state.insertEvent(change("2", "meuse", service.ALIVE, 71*time.Hour))
+			state.AcknowledgeEvent("1", datatypes.Acknowledgement{User: "petain"})
+
+			So(state.compactEvents(now), ShouldEqual, 0)
+		})
+
+		Convey("Does nothing when turned off", func() {
+			state.Compactor = &Compactor{}
+			So(state.Compactor.Enabled(), ShouldBeFalse)
+
+			state.insertEvent(change("1", "meuse", service.UNHEALTHY, 72*time.Hour))
+			state.insertEvent(change("2", "meuse", service.ALIVE, 71*time.Hour))
+			So(state.compactEvents(now), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DEFAULT_CORRELATION_WINDOW = 2 * time.Minute
+)
+
+// Groups bursts of transitions in a cluster under one correlation ID, so
+// whatever is downstream can treat "47 services went unhealthy" as one
+// incident. A burst lasts as long as transitions keep arriving less than
+// Window apart. A zero Window turns it off.
+type Correlator struct {
+	Window time.Duration
+	bursts map[string]*burst // Cluster name => the latest burst
+	lock   sync.Mutex
+}
+
+type burst struct {
+	id       string
+	lastSeen time.Time
+}
+
+func NewCorrelator(window time.Duration) *Correlator {
+	return &Correlator{
+		Window: window,
+		bursts: make(map[string]*burst, 5),
+	}
+}
+
+// Give the notification the correlation ID of the burst it belongs to,
+// starting a new one if the cluster has been quiet for a while
+func (c *Correlator) Record(notice *datatypes.Notification, now time.Time) {
+	if c.Window == 0 || notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil ||
+		notice.Event.PreviousStatus == notice.Event.Service.Status {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	current, ok := c.bursts[notice.ClusterName]
+	if !ok || now.Sub(current.lastSeen) >= c.Window {
+		current = &burst{id: uuid.NewV4().String()}
+		c.bursts[notice.ClusterName] = current
+	}
+	current.lastSeen = now
+
+	notice.CorrelationID = current.id
+}
@@ -0,0 +1,79 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Correlator(t *testing.T) {
+	Convey("The correlator", t, func() {
+		correlator := NewCorrelator(time.Minute)
+		baseTime := time.Now().UTC()
+
+		transition := func(clusterName string, svcName string) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: clusterName,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: svcName, Status: service.UNHEALTHY},
+					PreviousStatus: service.ALIVE,
+				},
+			}
+		}
+
+		Convey("Gives a burst in a cluster one ID", func() {
+			first := transition("france", "verdun")
+			second := transition("france", "somme")
+			third := transition("france", "marne")
+			correlator.Record(first, baseTime)
+			correlator.Record(second, baseTime.Add(50*time.Second))
+			correlator.Record(third, baseTime.Add(100*time.Second))
+
+			So(first.CorrelationID, ShouldNotBeEmpty)
+			So(second.CorrelationID, ShouldEqual, first.CorrelationID)
+			So(third.CorrelationID, ShouldEqual, first.CorrelationID)
+		})
+
+		Convey("Starts a new burst after a quiet spell", func() {
+			first := transition("france", "verdun")
+			second := transition("france", "somme")
+			correlator.Record(first, baseTime)
+			correlator.Record(second, baseTime.Add(2*time.Minute))
+
+			So(second.CorrelationID, ShouldNotBeEmpty)
+			So(second.CorrelationID, ShouldNotEqual, first.CorrelationID)
+		})
+
+		Convey("Keeps clusters apart", func() {
+			first := transition("france", "verdun")
+			second := transition("belgium", "ypres")
+			correlator.Record(first, baseTime)
+			correlator.Record(second, baseTime)
+
+			So(second.CorrelationID, ShouldNotEqual, first.CorrelationID)
+		})
+
+		Convey("Leaves out events that aren't transitions", func() {
+			unchanged := transition("france", "verdun")
+			unchanged.Event.PreviousStatus = service.UNHEALTHY
+			correlator.Record(unchanged, baseTime)
+			So(unchanged.CorrelationID, ShouldBeEmpty)
+
+			flapping := &datatypes.Notification{Type: datatypes.FLAPPING_NOTICE, ClusterName: "france"}
+			correlator.Record(flapping, baseTime)
+			So(flapping.CorrelationID, ShouldBeEmpty)
+		})
+
+		Convey("Does nothing without a window", func() {
+			correlator.Window = 0
+			notice := transition("france", "verdun")
+			correlator.Record(notice, baseTime)
+			So(notice.CorrelationID, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package tracker
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/nitro/superside/metrics"
+)
+
+// The counters we carry over a restart, so rate dashboards and the stats
+// don't start again from zero
+type persistedCounters struct {
+	EventsReceived uint64
+	EventsStored   uint64
+	Events         []metrics.CounterSnapshot   // By cluster
+	StateDurations []metrics.HistogramSnapshot // By cluster, service and state
+}
+
+func (t *Tracker) marshalCounters() ([]byte, error) {
+	return json.Marshal(persistedCounters{
+		EventsReceived: atomic.LoadUint64(&t.eventsReceived),
+		EventsStored:   atomic.LoadUint64(&t.eventsStored),
+		Events:         t.EventCounts.Snapshots(),
+		StateDurations: t.StateDurations.Stats(),
+	})
+}
+
+// Add the stored counters to whatever we've counted since starting
+func (t *Tracker) restoreCounters(data []byte) error {
+	var counters persistedCounters
+	err := json.Unmarshal(data, &counters)
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&t.eventsReceived, counters.EventsReceived)
+	atomic.AddUint64(&t.eventsStored, counters.EventsStored)
+	t.EventCounts.Restore(counters.Events)
+	t.StateDurations.Histograms.Restore(counters.StateDurations)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package tracker
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PersistedCounters(t *testing.T) {
+	Convey("The counters", t, func() {
+		dir, _ := ioutil.TempDir("", "counters")
+		defer os.RemoveAll(dir)
+		dataStore := store.NewFileStore(dir)
+
+		state := NewTracker(10, dataStore)
+		state.eventsReceived = 3
+		state.eventsStored = 2
+		state.EventCounts.Add(2, "france")
+		state.StateDurations.Histograms.Observe(90, "france", "somme", "Unhealthy")
+		state.Persist()
+
+		Convey("Carry on from where they were after a restart", func() {
+			restarted := NewTracker(10, dataStore)
+
+			So(restarted.Vars().EventsReceived, ShouldEqual, 3)
+			So(restarted.Vars().EventsStored, ShouldEqual, 2)
+			So(restarted.EventCounts.Get("france"), ShouldEqual, 2)
+			So(restarted.StateDurations.Stats(), ShouldResemble, state.StateDurations.Stats())
+		})
+
+		Convey("Start from zero without anything stored", func() {
+			fresh := NewTracker(10, &store.NoopStore{})
+
+			So(fresh.Vars().EventsStored, ShouldEqual, 0)
+			So(fresh.EventCounts.Snapshots(), ShouldBeEmpty)
+		})
+	})
+}
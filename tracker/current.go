@@ -0,0 +1,175 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+type instanceView struct {
+	view    datatypes.InstanceView
+	service string
+}
+
+// Folds service events into the current state of each cluster so that
+// clients don't have to replay the history themselves. Tombstoned instances
+// are dropped.
+type ClusterViews struct {
+	clusters map[string]map[string]*instanceView // Cluster => "host/id" => instance
+	changed  map[string]time.Time                // Cluster => last change
+	lock     sync.RWMutex
+}
+
+func NewClusterViews() *ClusterViews {
+	return &ClusterViews{
+		clusters: make(map[string]map[string]*instanceView, 5),
+		changed:  make(map[string]time.Time, 5),
+	}
+}
+
+func (c *ClusterViews) Record(notice *datatypes.Notification) {
+	if notice.Event == nil || notice.Type != datatypes.SERVICE_EVENT_NOTICE {
+		return
+	}
+
+	svc := notice.Event.Service
+	key := svc.Hostname + "/" + svc.ID
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	instances, ok := c.clusters[notice.ClusterName]
+	if !ok {
+		instances = make(map[string]*instanceView, 50)
+		c.clusters[notice.ClusterName] = instances
+	}
+
+	// Don't let a late, out of order event overwrite a newer one
+	if last, ok := instances[key]; ok && notice.Event.Time.Before(last.view.LastChange) {
+		return
+	}
+
+	if notice.Event.Time.After(c.changed[notice.ClusterName]) {
+		c.changed[notice.ClusterName] = notice.Event.Time
+	}
+
+	if svc.Status == service.TOMBSTONE {
+		delete(instances, key)
+		return
+	}
+
+	instances[key] = &instanceView{
+		service: svc.Name,
+		view: datatypes.InstanceView{
+			ID:         svc.ID,
+			Hostname:   svc.Hostname,
+			Image:      svc.Image,
+			Status:     datatypes.StatusString(svc.Status),
+			LastChange: notice.Event.Time,
+		},
+	}
+}
+
+// The names of every cluster we've heard from, sorted
+func (c *ClusterViews) Clusters() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	names := make([]string, 0, len(c.clusters))
+	for name := range c.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// The current view of a cluster, or nil if we've never heard from it
+func (c *ClusterViews) Current(clusterName string) *datatypes.ClusterView {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	instances, ok := c.clusters[clusterName]
+	if !ok {
+		return nil
+	}
+
+	hosts := make(map[string]bool, 10)
+	byService := make(map[string][]datatypes.InstanceView, 20)
+	for _, instance := range instances {
+		hosts[instance.view.Hostname] = true
+		byService[instance.service] = append(byService[instance.service], instance.view)
+	}
+
+	view := &datatypes.ClusterView{
+		ClusterName: clusterName,
+		Hosts:       make([]string, 0, len(hosts)),
+		Services:    make([]datatypes.ServiceView, 0, len(byService)),
+		LastChange:  c.changed[clusterName],
+	}
+
+	for hostname := range hosts {
+		view.Hosts = append(view.Hosts, hostname)
+	}
+	sort.Strings(view.Hosts)
+
+	for name, views := range byService {
+		sort.Slice(views, func(i, j int) bool {
+			if views[i].Hostname != views[j].Hostname {
+				return views[i].Hostname < views[j].Hostname
+			}
+			return views[i].ID < views[j].ID
+		})
+		view.Services = append(view.Services, datatypes.ServiceView{Name: name, Instances: views})
+	}
+	sort.Slice(view.Services, func(i, j int) bool {
+		return view.Services[i].Name < view.Services[j].Name
+	})
+
+	return view
+}
+
+// How many instances of a service a cluster has, and how many are alive
+func (c *ClusterViews) Instances(clusterName string, svcName string) (alive int, total int) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, instance := range c.clusters[clusterName] {
+		if instance.service != svcName {
+			continue
+		}
+		total += 1
+		if instance.view.Status == datatypes.StatusString(service.ALIVE) {
+			alive += 1
+		}
+	}
+
+	return alive, total
+}
+
+// How many instances of a service there are across every cluster, how many
+// are alive and when any of them last changed
+func (c *ClusterViews) ServiceHealth(svcName string) (alive int, total int, lastChange time.Time) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, instances := range c.clusters {
+		for _, instance := range instances {
+			if instance.service != svcName {
+				continue
+			}
+			total += 1
+			if instance.view.Status == datatypes.StatusString(service.ALIVE) {
+				alive += 1
+			}
+			if instance.view.LastChange.After(lastChange) {
+				lastChange = instance.view.LastChange
+			}
+		}
+	}
+
+	return alive, total, lastChange
+}
@@ -0,0 +1,62 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ClusterViews(t *testing.T) {
+	Convey("ClusterViews", t, func() {
+		views := NewClusterViews()
+		baseTime := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+
+		record := func(name string, id string, host string, status int, when time.Time) {
+			notice := noticeFor(name, status, when)
+			notice.Event.Service.ID = id
+			notice.Event.Service.Hostname = host
+			views.Record(&notice)
+		}
+
+		record("verdun", "1", "meuse", service.ALIVE, baseTime)
+		record("verdun", "2", "douaumont", service.ALIVE, baseTime)
+		record("somme", "3", "meuse", service.ALIVE, baseTime)
+		record("verdun", "1", "meuse", service.UNHEALTHY, baseTime.Add(time.Minute))
+
+		Convey("Returns nil for unknown clusters", func() {
+			So(views.Current("belgium"), ShouldBeNil)
+		})
+
+		Convey("Lists the clusters it knows about", func() {
+			So(views.Clusters(), ShouldResemble, []string{"france"})
+		})
+
+		Convey("Folds events into the latest status of each instance", func() {
+			current := views.Current("france")
+
+			So(current.Hosts, ShouldResemble, []string{"douaumont", "meuse"})
+			So(current.LastChange, ShouldResemble, baseTime.Add(time.Minute))
+			So(len(current.Services), ShouldEqual, 2)
+			So(current.Services[0].Name, ShouldEqual, "somme")
+
+			verdun := current.Services[1].Instances
+			So(verdun[0].Hostname, ShouldEqual, "douaumont")
+			So(verdun[1].Status, ShouldEqual, "Unhealthy")
+		})
+
+		Convey("Ignores events older than the one it has", func() {
+			record("verdun", "1", "meuse", service.ALIVE, baseTime)
+			So(views.Current("france").Services[1].Instances[1].Status, ShouldEqual, "Unhealthy")
+		})
+
+		Convey("Drops tombstoned instances", func() {
+			record("somme", "3", "meuse", service.TOMBSTONE, baseTime.Add(time.Hour))
+
+			current := views.Current("france")
+			So(len(current.Services), ShouldEqual, 1)
+			So(current.LastChange, ShouldResemble, baseTime.Add(time.Hour))
+		})
+	})
+}
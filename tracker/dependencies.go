@@ -0,0 +1,141 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	IMPACT_WINDOW = 5 * time.Minute // Changes this close together are correlated
+)
+
+// Operators can tell us which services depend on which other services. We use
+// that to work out what might be affected downstream when a service changes.
+type DependencyMap struct {
+	dependsOn map[string][]string // Service name => services it depends on
+	lock      sync.RWMutex
+}
+
+// The impact of changes to one service on the services that depend on it
+type Impact struct {
+	Service          string
+	Dependents       []string
+	CorrelatedEvents []datatypes.Notification
+}
+
+func NewDependencyMap(deps map[string][]string) *DependencyMap {
+	depMap := &DependencyMap{dependsOn: make(map[string][]string, len(deps))}
+
+	for svcName, dependsOn := range deps {
+		depMap.Set(svcName, dependsOn)
+	}
+
+	return depMap
+}
+
+// Replace the list of services that svcName depends on. An empty list
+// removes the entry.
+func (d *DependencyMap) Set(svcName string, dependsOn []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(dependsOn) == 0 {
+		delete(d.dependsOn, svcName)
+		return
+	}
+
+	d.dependsOn[svcName] = append([]string{}, dependsOn...)
+}
+
+// Return a copy of the whole dependency map
+func (d *DependencyMap) All() map[string][]string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	all := make(map[string][]string, len(d.dependsOn))
+	for svcName, dependsOn := range d.dependsOn {
+		all[svcName] = append([]string{}, dependsOn...)
+	}
+
+	return all
+}
+
+// Return the names of all the services that declare a dependency on svcName
+func (d *DependencyMap) DependentsOf(svcName string) []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	var dependents []string
+	for name, dependsOn := range d.dependsOn {
+		for _, dep := range dependsOn {
+			if dep == svcName {
+				dependents = append(dependents, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents
+}
+
+// Fill in the possible downstream impact on a notification. Only changes that
+// take a service out of rotation can hurt anyone downstream.
+func (d *DependencyMap) Enrich(notice *datatypes.Notification) {
+	svc := notice.Event.Service
+	if svc.Status != service.UNHEALTHY && svc.Status != service.TOMBSTONE {
+		return
+	}
+
+	notice.PossibleImpact = d.DependentsOf(svc.Name)
+}
+
+// Look through the event history for changes to the dependents of svcName
+// that happened within IMPACT_WINDOW of a change to svcName itself.
+func (d *DependencyMap) ImpactOf(svcName string, history []datatypes.Notification) *Impact {
+	impact := &Impact{
+		Service:          svcName,
+		Dependents:       d.DependentsOf(svcName),
+		CorrelatedEvents: []datatypes.Notification{},
+	}
+
+	if len(impact.Dependents) == 0 {
+		return impact
+	}
+
+	isDependent := make(map[string]bool, len(impact.Dependents))
+	for _, name := range impact.Dependents {
+		isDependent[name] = true
+	}
+
+	var changeTimes []time.Time
+	for _, notice := range history {
+		if notice.Event.Service.Name == svcName {
+			changeTimes = append(changeTimes, notice.Event.Time)
+		}
+	}
+
+	for _, notice := range history {
+		if !isDependent[notice.Event.Service.Name] {
+			continue
+		}
+
+		for _, changed := range changeTimes {
+			if withinWindow(changed, notice.Event.Time) {
+				impact.CorrelatedEvents = append(impact.CorrelatedEvents, notice)
+				break
+			}
+		}
+	}
+
+	return impact
+}
+
+func withinWindow(a time.Time, b time.Time) bool {
+	diff := b.Sub(a)
+	return diff > -IMPACT_WINDOW && diff < IMPACT_WINDOW
+}
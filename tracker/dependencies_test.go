@@ -0,0 +1,75 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func noticeFor(name string, status int, when time.Time) datatypes.Notification {
+	return datatypes.Notification{
+		Type: datatypes.SERVICE_EVENT_NOTICE,
+		Event: &catalog.ChangeEvent{
+			Service: service.Service{Name: name, Status: status},
+			Time:    when,
+		},
+		ClusterName: "france",
+	}
+}
+
+func Test_DependencyMap(t *testing.T) {
+	Convey("DependencyMap", t, func() {
+		deps := NewDependencyMap(map[string][]string{
+			"api-gateway": {"db", "cache"},
+			"billing":     {"db"},
+			"search":      {"cache"},
+		})
+
+		Convey("Finds the dependents of a service", func() {
+			So(deps.DependentsOf("db"), ShouldResemble, []string{"api-gateway", "billing"})
+			So(deps.DependentsOf("billing"), ShouldBeEmpty)
+		})
+
+		Convey("Replaces and removes dependencies", func() {
+			deps.Set("search", []string{"db"})
+			So(deps.DependentsOf("db"), ShouldResemble, []string{"api-gateway", "billing", "search"})
+
+			deps.Set("search", nil)
+			So(deps.All(), ShouldNotContainKey, "search")
+		})
+
+		Convey("Enriches unhealthy notifications with possible impact", func() {
+			notice := noticeFor("db", service.UNHEALTHY, time.Now().UTC())
+			deps.Enrich(&notice)
+
+			So(notice.PossibleImpact, ShouldResemble, []string{"api-gateway", "billing"})
+		})
+
+		Convey("Does not enrich healthy notifications", func() {
+			notice := noticeFor("db", service.ALIVE, time.Now().UTC())
+			deps.Enrich(&notice)
+
+			So(notice.PossibleImpact, ShouldBeEmpty)
+		})
+
+		Convey("Finds correlated changes in dependent services", func() {
+			baseTime := time.Now().UTC()
+			history := []datatypes.Notification{
+				noticeFor("db", service.UNHEALTHY, baseTime),
+				noticeFor("billing", service.UNHEALTHY, baseTime.Add(time.Minute)),
+				noticeFor("api-gateway", service.UNHEALTHY, baseTime.Add(-1*time.Hour)),
+				noticeFor("search", service.UNHEALTHY, baseTime),
+			}
+
+			impact := deps.ImpactOf("db", history)
+
+			So(impact.Dependents, ShouldResemble, []string{"api-gateway", "billing"})
+			So(len(impact.CorrelatedEvents), ShouldEqual, 1)
+			So(impact.CorrelatedEvents[0].Event.Service.Name, ShouldEqual, "billing")
+		})
+	})
+}
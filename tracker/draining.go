@@ -0,0 +1,105 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+// Keeps track of the hosts that are being drained for maintenance. A host
+// counts as draining from the first of its services Sidecar marks DRAINING
+// until one of them comes back Alive. Anything that happens on it meanwhile
+// is expected, so it's marked Draining and doesn't page anyone.
+type DrainTracker struct {
+	hosts map[string]*DrainingHost // "cluster/hostname" => host
+	lock  sync.RWMutex
+}
+
+// What the draining endpoint reports for each host
+type DrainingHost struct {
+	ClusterName string
+	Hostname    string
+	Since       time.Time
+	Services    []string // The ones that went DRAINING, in that order
+}
+
+func NewDrainTracker() *DrainTracker {
+	return &DrainTracker{hosts: make(map[string]*DrainingHost, 5)}
+}
+
+// Record a notification, marking it Draining if its host is
+func (d *DrainTracker) Record(notice *datatypes.Notification) {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return
+	}
+
+	svc := notice.Event.Service
+	key := notice.ClusterName + "/" + svc.Hostname
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	host, draining := d.hosts[key]
+
+	switch {
+	case svc.Status == datatypes.DRAINING:
+		if !draining {
+			host = &DrainingHost{
+				ClusterName: notice.ClusterName,
+				Hostname:    svc.Hostname,
+				Since:       notice.Event.Time,
+			}
+			d.hosts[key] = host
+		}
+		if !containsString(host.Services, svc.Name) {
+			host.Services = append(host.Services, svc.Name)
+		}
+		notice.Draining = true
+
+	case svc.Status == service.ALIVE && draining:
+		delete(d.hosts, key)
+
+	case draining:
+		notice.Draining = true
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The hosts currently draining, optionally in just one cluster, sorted by
+// cluster and hostname
+func (d *DrainTracker) Hosts(clusterName string) []DrainingHost {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	hosts := make([]DrainingHost, 0, len(d.hosts))
+	for _, host := range d.hosts {
+		if clusterName != "" && host.ClusterName != clusterName {
+			continue
+		}
+
+		copied := *host
+		copied.Services = append([]string{}, host.Services...)
+		hosts = append(hosts, copied)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].ClusterName != hosts[j].ClusterName {
+			return hosts[i].ClusterName < hosts[j].ClusterName
+		}
+		return hosts[i].Hostname < hosts[j].Hostname
+	})
+
+	return hosts
+}
@@ -0,0 +1,71 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DrainTracker(t *testing.T) {
+	Convey("DrainTracker", t, func() {
+		draining := NewDrainTracker()
+		baseTime := time.Now().UTC()
+
+		record := func(name string, hostname string, status int) *datatypes.Notification {
+			notice := noticeFor(name, status, baseTime)
+			notice.Event.Service.Hostname = hostname
+			draining.Record(&notice)
+			return &notice
+		}
+
+		Convey("Starts draining a host when a service on it does", func() {
+			notice := record("artillery", "verdun", datatypes.DRAINING)
+
+			So(notice.Draining, ShouldBeTrue)
+			So(draining.Hosts(""), ShouldResemble, []DrainingHost{
+				{ClusterName: "france", Hostname: "verdun", Since: baseTime, Services: []string{"artillery"}},
+			})
+		})
+
+		Convey("Marks everything on a draining host", func() {
+			record("artillery", "verdun", datatypes.DRAINING)
+
+			So(record("cavalry", "verdun", service.UNHEALTHY).Draining, ShouldBeTrue)
+			So(record("cavalry", "ypres", service.UNHEALTHY).Draining, ShouldBeFalse)
+		})
+
+		Convey("Lists each service once", func() {
+			record("artillery", "verdun", datatypes.DRAINING)
+			record("artillery", "verdun", datatypes.DRAINING)
+			record("cavalry", "verdun", datatypes.DRAINING)
+
+			So(draining.Hosts("")[0].Services, ShouldResemble, []string{"artillery", "cavalry"})
+		})
+
+		Convey("Stops draining when a service comes back", func() {
+			record("artillery", "verdun", datatypes.DRAINING)
+			notice := record("artillery", "verdun", service.ALIVE)
+
+			So(notice.Draining, ShouldBeFalse)
+			So(draining.Hosts(""), ShouldBeEmpty)
+		})
+
+		Convey("Filters and sorts the hosts", func() {
+			record("artillery", "ypres", datatypes.DRAINING)
+			record("artillery", "verdun", datatypes.DRAINING)
+
+			hosts := draining.Hosts("france")
+			So(len(hosts), ShouldEqual, 2)
+			So(hosts[0].Hostname, ShouldEqual, "verdun")
+			So(draining.Hosts("belgium"), ShouldBeEmpty)
+		})
+
+		Convey("Ignores everything but service events", func() {
+			draining.Record(&datatypes.Notification{Type: datatypes.HEARTBEAT_NOTICE})
+			So(draining.Hosts(""), ShouldBeEmpty)
+		})
+	})
+}
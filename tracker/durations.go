@@ -0,0 +1,78 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+)
+
+// Bucket upper bounds in seconds, from a few seconds up to a day
+var DURATION_BUCKETS = []float64{
+	5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200, 21600, 86400,
+}
+
+type instanceState struct {
+	status int
+	since  time.Time
+}
+
+// Tracks how long each service instance spends in each state. When an
+// instance leaves a state, the time it spent there is added to a histogram
+// for that service and state. The Unhealthy histogram is effectively MTTR.
+type StateDurations struct {
+	Histograms *metrics.HistogramVec
+	instances  map[string]*instanceState // "cluster/host/id" => current state
+	lock       sync.Mutex
+}
+
+func NewStateDurations() *StateDurations {
+	return &StateDurations{
+		Histograms: metrics.NewHistogramVec(
+			"superside_service_state_duration_seconds",
+			"Time service instances spent in a state before leaving it",
+			DURATION_BUCKETS,
+			"cluster", "service", "state",
+		),
+		instances: make(map[string]*instanceState, 100),
+	}
+}
+
+func (d *StateDurations) Record(notice *datatypes.Notification) {
+	if notice.Event == nil || notice.Type != datatypes.SERVICE_EVENT_NOTICE {
+		return
+	}
+
+	svc := notice.Event.Service
+	key := notice.ClusterName + "/" + svc.Hostname + "/" + svc.ID
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	last, ok := d.instances[key]
+	if ok && last.status != svc.Status {
+		spent := notice.Event.Time.Sub(last.since)
+		if spent >= 0 {
+			d.Histograms.Observe(spent.Seconds(),
+				notice.ClusterName, svc.Name, datatypes.StatusString(last.status),
+			)
+		}
+	}
+
+	// Tombstoned instances won't be back
+	if svc.Status == service.TOMBSTONE {
+		delete(d.instances, key)
+		return
+	}
+
+	if !ok || last.status != svc.Status {
+		d.instances[key] = &instanceState{status: svc.Status, since: notice.Event.Time}
+	}
+}
+
+// Histogram snapshots for every service and state we've seen leave
+func (d *StateDurations) Stats() []metrics.HistogramSnapshot {
+	return d.Histograms.Snapshots()
+}
@@ -0,0 +1,52 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_StateDurations(t *testing.T) {
+	Convey("StateDurations", t, func() {
+		durations := NewStateDurations()
+		baseTime := time.Now().UTC()
+
+		record := func(status int, when time.Time) {
+			notice := noticeFor("db", status, when)
+			notice.Event.Service.ID = "deadbeef0123"
+			notice.Event.Service.Hostname = "joffre"
+			durations.Record(&notice)
+		}
+
+		Convey("Records time spent unhealthy before recovering", func() {
+			record(service.ALIVE, baseTime)
+			record(service.UNHEALTHY, baseTime.Add(time.Hour))
+			record(service.ALIVE, baseTime.Add(time.Hour+90*time.Second))
+
+			stats := durations.Stats()
+			So(len(stats), ShouldEqual, 2)
+
+			So(stats[1].Labels["state"], ShouldEqual, "Unhealthy")
+			So(stats[1].Sum, ShouldEqual, 90)
+			So(stats[1].Buckets["120"], ShouldEqual, 1)
+			So(stats[1].Buckets["60"], ShouldEqual, 0)
+		})
+
+		Convey("Doesn't record anything when the status doesn't change", func() {
+			record(service.ALIVE, baseTime)
+			record(service.ALIVE, baseTime.Add(time.Minute))
+
+			So(durations.Stats(), ShouldBeEmpty)
+		})
+
+		Convey("Forgets tombstoned instances", func() {
+			record(service.ALIVE, baseTime)
+			record(service.TOMBSTONE, baseTime.Add(time.Minute))
+
+			So(durations.instances, ShouldBeEmpty)
+			So(durations.Stats()[0].Labels["state"], ShouldEqual, "Alive")
+		})
+	})
+}
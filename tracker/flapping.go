@@ -0,0 +1,161 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_FLAP_THRESHOLD = 5
+	DEFAULT_FLAP_WINDOW    = 10 * time.Minute
+)
+
+// A service that goes back and forth between healthy and unhealthy more than
+// Threshold times inside of Window is considered to be flapping. We track
+// transitions per service in each cluster rather than per instance, since a
+// flaky dependency usually hits every instance of a service.
+type FlapDetector struct {
+	Threshold   int
+	Window      time.Duration
+	transitions map[string][]time.Time           // "cluster/service" => transition times
+	flapping    map[string]*datatypes.FlapStatus // "cluster/service" => status
+	lock        sync.Mutex
+}
+
+func NewFlapDetector(threshold int, window time.Duration) *FlapDetector {
+	return &FlapDetector{
+		Threshold:   threshold,
+		Window:      window,
+		transitions: make(map[string][]time.Time, 20),
+		flapping:    make(map[string]*datatypes.FlapStatus, 5),
+	}
+}
+
+func flapKey(clusterName string, svcName string) string {
+	return clusterName + "/" + svcName
+}
+
+// Is this a move between healthy and unhealthy, in either direction?
+func isHealthTransition(evt *datatypes.Notification) bool {
+	prev := evt.Event.PreviousStatus
+	curr := evt.Event.Service.Status
+
+	return (prev == service.ALIVE && curr == service.UNHEALTHY) ||
+		(prev == service.UNHEALTHY && curr == service.ALIVE)
+}
+
+// Record a notification. Returns the service's FlapStatus if this event is the
+// one that tipped it over into flapping, nil otherwise.
+func (f *FlapDetector) Record(notice *datatypes.Notification) *datatypes.FlapStatus {
+	if notice.Event == nil || !isHealthTransition(notice) {
+		return nil
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := flapKey(notice.ClusterName, notice.Event.Service.Name)
+	when := notice.Event.Time
+
+	times := f.prune(append(f.transitions[key], when), when)
+	f.transitions[key] = times
+
+	if status, ok := f.flapping[key]; ok {
+		status.Transitions = len(times)
+		status.LastTransition = when
+		return nil
+	}
+
+	if len(times) <= f.Threshold {
+		return nil
+	}
+
+	status := &datatypes.FlapStatus{
+		ClusterName:    notice.ClusterName,
+		Service:        notice.Event.Service.Name,
+		Transitions:    len(times),
+		Window:         f.Window,
+		Since:          when,
+		LastTransition: when,
+	}
+	f.flapping[key] = status
+
+	copied := *status
+	return &copied
+}
+
+// Drop any transitions that fell out of the window
+func (f *FlapDetector) prune(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-f.Window)
+
+	var i int
+	for i = 0; i < len(times); i++ {
+		if times[i].After(cutoff) {
+			break
+		}
+	}
+
+	return times[i:]
+}
+
+// Expire services whose transitions have dropped back under the threshold.
+// Returns the ones that have stabilized.
+func (f *FlapDetector) Expire(now time.Time) []datatypes.FlapStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var stabilized []datatypes.FlapStatus
+	for key, times := range f.transitions {
+		times = f.prune(times, now)
+		if len(times) == 0 {
+			delete(f.transitions, key)
+		} else {
+			f.transitions[key] = times
+		}
+
+		status, ok := f.flapping[key]
+		if !ok {
+			continue
+		}
+
+		status.Transitions = len(times)
+		if len(times) <= f.Threshold {
+			stabilized = append(stabilized, *status)
+			delete(f.flapping, key)
+		}
+	}
+
+	return stabilized
+}
+
+func (f *FlapDetector) IsFlapping(clusterName string, svcName string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, ok := f.flapping[flapKey(clusterName, svcName)]
+	return ok
+}
+
+// Return all the services that are currently flapping, ordered by cluster and
+// service name
+func (f *FlapDetector) Flapping() []datatypes.FlapStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	keys := make([]string, 0, len(f.flapping))
+	for key := range f.flapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	statuses := make([]datatypes.FlapStatus, 0, len(keys))
+	for _, key := range keys {
+		statuses = append(statuses, *f.flapping[key])
+	}
+
+	return statuses
+}
@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FlapDetector(t *testing.T) {
+	Convey("FlapDetector", t, func() {
+		detector := NewFlapDetector(3, 10*time.Minute)
+		baseTime := time.Now().UTC()
+
+		flap := func(count int) {
+			status := service.UNHEALTHY
+			for i := 0; i < count; i++ {
+				notice := noticeFor("db", status, baseTime.Add(time.Duration(i)*time.Second))
+				if status == service.UNHEALTHY {
+					notice.Event.PreviousStatus = service.ALIVE
+					status = service.ALIVE
+				} else {
+					notice.Event.PreviousStatus = service.UNHEALTHY
+					status = service.UNHEALTHY
+				}
+				detector.Record(&notice)
+			}
+		}
+
+		Convey("Doesn't flag services under the threshold", func() {
+			flap(3)
+			So(detector.IsFlapping("france", "db"), ShouldBeFalse)
+		})
+
+		Convey("Flags services over the threshold", func() {
+			flap(4)
+			So(detector.IsFlapping("france", "db"), ShouldBeTrue)
+			So(detector.Flapping()[0].Transitions, ShouldEqual, 4)
+		})
+
+		Convey("Only reports the transition that starts the flapping", func() {
+			flap(4)
+
+			notice := noticeFor("db", service.UNHEALTHY, baseTime.Add(time.Minute))
+			notice.Event.PreviousStatus = service.ALIVE
+			So(detector.Record(&notice), ShouldBeNil)
+		})
+
+		Convey("Ignores changes that aren't health transitions", func() {
+			notice := noticeFor("db", service.TOMBSTONE, baseTime)
+			notice.Event.PreviousStatus = service.ALIVE
+
+			for i := 0; i < 10; i++ {
+				detector.Record(&notice)
+			}
+			So(detector.IsFlapping("france", "db"), ShouldBeFalse)
+		})
+
+		Convey("Expires services that have stabilized", func() {
+			flap(4)
+			stabilized := detector.Expire(baseTime.Add(11 * time.Minute))
+
+			So(len(stabilized), ShouldEqual, 1)
+			So(stabilized[0].Service, ShouldEqual, "db")
+			So(detector.IsFlapping("france", "db"), ShouldBeFalse)
+		})
+	})
+}
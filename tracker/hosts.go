@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+// What the stored events say about one host. A box that's failing tends to
+// show up as lots of events across many services at once.
+type HostSummary struct {
+	ClusterName string
+	Hostname    string
+	Events      int
+	Failures    int // Events where a service went unhealthy
+	Services    []string
+	FirstEvent  time.Time
+	LastEvent   time.Time
+}
+
+// Summarize the stored events by host, busiest first. Empty clusterName
+// means all clusters.
+func (t *Tracker) GetHosts(clusterName string) []HostSummary {
+	summaries := make(map[string]*HostSummary)
+	services := make(map[string]map[string]bool)
+
+	for _, notice := range t.GetSvcEventsList() {
+		if notice.Event == nil || notice.Event.Service.Hostname == "" ||
+			(clusterName != "" && notice.ClusterName != clusterName) {
+			continue
+		}
+
+		svc := notice.Event.Service
+		key := notice.ClusterName + "/" + svc.Hostname
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &HostSummary{
+				ClusterName: notice.ClusterName,
+				Hostname:    svc.Hostname,
+				FirstEvent:  notice.Event.Time,
+			}
+			summaries[key] = summary
+			services[key] = make(map[string]bool)
+		}
+
+		summary.Events += 1
+		if svc.Status == service.UNHEALTHY && notice.Event.PreviousStatus != service.UNHEALTHY {
+			summary.Failures += 1
+		}
+		if notice.Event.Time.After(summary.LastEvent) {
+			summary.LastEvent = notice.Event.Time
+		}
+		if notice.Event.Time.Before(summary.FirstEvent) {
+			summary.FirstEvent = notice.Event.Time
+		}
+		if !services[key][svc.Name] {
+			services[key][svc.Name] = true
+			summary.Services = append(summary.Services, svc.Name)
+		}
+	}
+
+	hosts := make([]HostSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		sort.Strings(summary.Services)
+		hosts = append(hosts, *summary)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Events != hosts[j].Events {
+			return hosts[i].Events > hosts[j].Events
+		}
+		if hosts[i].Hostname != hosts[j].Hostname {
+			return hosts[i].Hostname < hosts[j].Hostname
+		}
+		return hosts[i].ClusterName < hosts[j].ClusterName
+	})
+
+	return hosts
+}
+
+// The stored events for services on a host, oldest first. Empty
+// clusterName means the host in any cluster.
+func (t *Tracker) GetHostEvents(hostname string, clusterName string) []datatypes.Notification {
+	events := []datatypes.Notification{}
+
+	for _, notice := range t.GetSvcEventsList() {
+		if notice.Event == nil || notice.Event.Service.Hostname != hostname ||
+			(clusterName != "" && notice.ClusterName != clusterName) {
+			continue
+		}
+		events = append(events, notice)
+	}
+
+	return events
+}
@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Hosts(t *testing.T) {
+	Convey("Looking at events by host", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		baseTime := time.Now().UTC()
+
+		record := func(clusterName string, hostname string, svcName string, status int, offset time.Duration) {
+			state.insertEvent(&datatypes.Notification{
+				ID:          svcName + "-" + hostname + "-" + offset.String(),
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: clusterName,
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{Name: svcName, Hostname: hostname, Status: status},
+					PreviousStatus: service.ALIVE,
+					Time:           baseTime.Add(offset),
+				},
+			})
+		}
+
+		record("france", "meuse", "verdun", service.UNHEALTHY, 0)
+		record("france", "meuse", "douaumont", service.UNHEALTHY, time.Second)
+		record("france", "meuse", "verdun", service.ALIVE, 2*time.Second)
+		record("france", "somme", "albert", service.UNHEALTHY, 3*time.Second)
+		record("belgium", "meuse", "liege", service.TOMBSTONE, 4*time.Second)
+
+		Convey("Summarizes the busiest hosts first", func() {
+			hosts := state.GetHosts("")
+
+			So(len(hosts), ShouldEqual, 3)
+			So(hosts[0].ClusterName, ShouldEqual, "france")
+			So(hosts[0].Hostname, ShouldEqual, "meuse")
+			So(hosts[0].Events, ShouldEqual, 3)
+			So(hosts[0].Failures, ShouldEqual, 2)
+			So(hosts[0].Services, ShouldResemble, []string{"douaumont", "verdun"})
+			So(hosts[0].FirstEvent, ShouldResemble, baseTime)
+			So(hosts[0].LastEvent, ShouldResemble, baseTime.Add(2*time.Second))
+		})
+
+		Convey("Summarizes one cluster", func() {
+			hosts := state.GetHosts("belgium")
+			So(len(hosts), ShouldEqual, 1)
+			So(hosts[0].Hostname, ShouldEqual, "meuse")
+			So(hosts[0].Failures, ShouldEqual, 0)
+		})
+
+		Convey("Lists the events on a host", func() {
+			So(len(state.GetHostEvents("meuse", "")), ShouldEqual, 4)
+
+			events := state.GetHostEvents("meuse", "france")
+			So(len(events), ShouldEqual, 3)
+			So(events[0].Event.Service.Name, ShouldEqual, "verdun")
+			So(events[2].Event.Service.Status, ShouldEqual, service.ALIVE)
+
+			So(state.GetHostEvents("ypres", ""), ShouldBeEmpty)
+		})
+	})
+}
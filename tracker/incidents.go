@@ -0,0 +1,238 @@
+package tracker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	DEFAULT_INCIDENT_HISTORY = 500 // Resolved incidents we hang on to
+)
+
+// Groups the failures the Correlator ties together into incidents, and
+// keeps track of each one from opening, through acknowledgement, to
+// resolution
+type IncidentList struct {
+	MaxResolved int
+	incidents   map[string]*datatypes.Incident // Incident ID => incident
+	instances   map[string]string              // "cluster/host/id" => the incident it's unhealthy in
+	lock        sync.Mutex
+}
+
+func NewIncidentList(maxResolved int) *IncidentList {
+	return &IncidentList{
+		MaxResolved: maxResolved,
+		incidents:   make(map[string]*datatypes.Incident, 10),
+		instances:   make(map[string]string, 10),
+	}
+}
+
+func instanceOf(notice *datatypes.Notification) string {
+	svc := notice.Event.Service
+	return notice.ClusterName + "/" + svc.Hostname + "/" + svc.ID
+}
+
+// Fold a service event into the incidents. Failures open an incident or
+// join the one for their burst, and recoveries resolve it once nothing in
+// it is still unhealthy. Returns a copy of the incident when it opened or
+// resolved, so it can be announced, and nil otherwise.
+func (l *IncidentList) Record(notice *datatypes.Notification, now time.Time) *datatypes.Incident {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	instance := instanceOf(notice)
+	openID, unhealthy := l.instances[instance]
+
+	if !notice.IsFailure() {
+		if !unhealthy {
+			return nil
+		}
+
+		delete(l.instances, instance)
+		incident := l.incidents[openID]
+		if incident == nil || incident.IsResolved() {
+			return nil
+		}
+
+		incident.EventIDs = append(incident.EventIDs, notice.ID)
+		incident.Unhealthy -= 1
+		incident.UpdatedAt = now
+		if incident.Unhealthy > 0 {
+			return nil
+		}
+
+		l.resolve(incident, now)
+		return copyIncident(incident)
+	}
+
+	// Already counted, or nobody should be paged about it
+	if unhealthy || notice.Suppressed || notice.Draining {
+		return nil
+	}
+
+	id := notice.CorrelationID
+	if id == "" {
+		id = notice.ID
+	}
+
+	incident, ok := l.incidents[id]
+	opened := !ok || incident.IsResolved()
+	if !ok {
+		incident = &datatypes.Incident{ID: id, ClusterName: notice.ClusterName, OpenedAt: now}
+		l.incidents[id] = incident
+	}
+	if incident.IsResolved() {
+		incident.ResolvedAt = time.Time{}
+		incident.Ack = nil
+	}
+	if opened {
+		incident.Status = datatypes.INCIDENT_OPEN
+	}
+
+	l.instances[instance] = id
+	incident.Unhealthy += 1
+	incident.EventIDs = append(incident.EventIDs, notice.ID)
+	incident.Severity = datatypes.WorseSeverity(incident.Severity, notice.Severity)
+	incident.UpdatedAt = now
+	if !containsString(incident.Services, notice.Event.Service.Name) {
+		incident.Services = append(incident.Services, notice.Event.Service.Name)
+		sort.Strings(incident.Services)
+	}
+
+	if opened {
+		return copyIncident(incident)
+	}
+	return nil
+}
+
+// Acknowledge an incident, resolving it if the acknowledgement says so.
+// Returns nil if there's no such incident.
+func (l *IncidentList) Acknowledge(id string, ack datatypes.Acknowledgement) (*datatypes.Incident, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	incident, ok := l.incidents[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if incident.IsResolved() {
+		return nil, errors.New("The incident is already resolved")
+	}
+
+	incident.Ack = &ack
+	incident.UpdatedAt = ack.Time
+	incident.Status = datatypes.INCIDENT_ACKNOWLEDGED
+	if ack.Resolved {
+		l.resolve(incident, ack.Time)
+	}
+
+	return copyIncident(incident), nil
+}
+
+// Close out an incident, forgetting the oldest resolved ones if there are
+// too many. Expects the lock to be held.
+func (l *IncidentList) resolve(incident *datatypes.Incident, now time.Time) {
+	incident.Status = datatypes.INCIDENT_RESOLVED
+	incident.ResolvedAt = now
+	incident.UpdatedAt = now
+
+	for instance, id := range l.instances {
+		if id == incident.ID {
+			delete(l.instances, instance)
+		}
+	}
+
+	var resolved []*datatypes.Incident
+	for _, candidate := range l.incidents {
+		if candidate.IsResolved() {
+			resolved = append(resolved, candidate)
+		}
+	}
+
+	if len(resolved) <= l.MaxResolved {
+		return
+	}
+
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].ResolvedAt.Before(resolved[j].ResolvedAt)
+	})
+	for _, stale := range resolved[:len(resolved)-l.MaxResolved] {
+		delete(l.incidents, stale.ID)
+	}
+}
+
+// Look up an incident by ID. Returns nil if there's no such incident.
+func (l *IncidentList) Get(id string) *datatypes.Incident {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if incident, ok := l.incidents[id]; ok {
+		return copyIncident(incident)
+	}
+
+	return nil
+}
+
+// The incidents with this status, or all of them if it's empty, newest first
+func (l *IncidentList) All(status string) []datatypes.Incident {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	incidents := make([]datatypes.Incident, 0, len(l.incidents))
+	for _, incident := range l.incidents {
+		if status == "" || incident.Status == status {
+			incidents = append(incidents, *copyIncident(incident))
+		}
+	}
+
+	sort.Slice(incidents, func(i, j int) bool {
+		if !incidents[i].OpenedAt.Equal(incidents[j].OpenedAt) {
+			return incidents[i].OpenedAt.After(incidents[j].OpenedAt)
+		}
+		return incidents[i].ID < incidents[j].ID
+	})
+
+	return incidents
+}
+
+func copyIncident(incident *datatypes.Incident) *datatypes.Incident {
+	copied := *incident
+	copied.Services = append([]string{}, incident.Services...)
+	copied.EventIDs = append([]string{}, incident.EventIDs...)
+	if incident.Ack != nil {
+		ack := *incident.Ack
+		copied.Ack = &ack
+	}
+
+	return &copied
+}
+
+// The notification announcing a change to an incident. Resolving one is
+// good news, so it doesn't go out at the severity of the incident.
+func incidentNotice(incident *datatypes.Incident, now time.Time) *datatypes.Notification {
+	severity := incident.Severity
+	if incident.IsResolved() {
+		severity = datatypes.SEVERITY_INFO
+	}
+
+	return &datatypes.Notification{
+		ID:            uuid.NewV4().String(),
+		Type:          datatypes.INCIDENT_NOTICE,
+		ClusterName:   incident.ClusterName,
+		CorrelationID: incident.ID,
+		Severity:      severity,
+		ReceivedAt:    now,
+		Source:        datatypes.SUPERSIDE_SOURCE,
+		Incident:      incident,
+	}
+}
@@ -0,0 +1,106 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IncidentList(t *testing.T) {
+	Convey("The incident list", t, func() {
+		incidents := NewIncidentList(2)
+		now := time.Date(1916, time.July, 1, 7, 30, 0, 0, time.UTC)
+
+		event := func(id string, burst string, hostname string, status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				ID:            id,
+				Type:          datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName:   "france",
+				CorrelationID: burst,
+				Severity:      datatypes.SEVERITY_WARNING,
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{ID: hostname, Name: "somme", Hostname: hostname, Status: status},
+				},
+			}
+		}
+
+		Convey("Opens one incident per burst of failures", func() {
+			opened := incidents.Record(event("1", "albert", "thiepval", service.UNHEALTHY), now)
+			So(opened, ShouldNotBeNil)
+			So(opened.ID, ShouldEqual, "albert")
+			So(opened.Status, ShouldEqual, datatypes.INCIDENT_OPEN)
+
+			critical := event("2", "albert", "pozieres", service.UNHEALTHY)
+			critical.Severity = datatypes.SEVERITY_CRITICAL
+			So(incidents.Record(critical, now), ShouldBeNil)
+
+			incident := incidents.Get("albert")
+			So(incident.EventIDs, ShouldResemble, []string{"1", "2"})
+			So(incident.Unhealthy, ShouldEqual, 2)
+			So(incident.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+			So(incident.Services, ShouldResemble, []string{"somme"})
+		})
+
+		Convey("Resolves once everything in it recovers", func() {
+			incidents.Record(event("1", "albert", "thiepval", service.UNHEALTHY), now)
+			incidents.Record(event("2", "albert", "pozieres", service.UNHEALTHY), now)
+
+			So(incidents.Record(event("3", "bapaume", "thiepval", service.ALIVE), now), ShouldBeNil)
+			resolved := incidents.Record(event("4", "bapaume", "pozieres", service.ALIVE), now.Add(time.Hour))
+
+			So(resolved, ShouldNotBeNil)
+			So(resolved.Status, ShouldEqual, datatypes.INCIDENT_RESOLVED)
+			So(resolved.ResolvedAt, ShouldEqual, now.Add(time.Hour))
+			So(resolved.EventIDs, ShouldResemble, []string{"1", "2", "3", "4"})
+		})
+
+		Convey("Ignores silenced failures and recoveries it didn't see fail", func() {
+			silenced := event("1", "albert", "thiepval", service.UNHEALTHY)
+			silenced.Suppressed = true
+
+			So(incidents.Record(silenced, now), ShouldBeNil)
+			So(incidents.Record(event("2", "albert", "thiepval", service.ALIVE), now), ShouldBeNil)
+			So(incidents.All(""), ShouldBeEmpty)
+		})
+
+		Convey("Can be acknowledged and resolved by hand", func() {
+			incidents.Record(event("1", "albert", "thiepval", service.UNHEALTHY), now)
+
+			acked, err := incidents.Acknowledge("albert", datatypes.Acknowledgement{User: "haig", Time: now})
+			So(err, ShouldBeNil)
+			So(acked.Status, ShouldEqual, datatypes.INCIDENT_ACKNOWLEDGED)
+			So(incidents.All(datatypes.INCIDENT_OPEN), ShouldBeEmpty)
+
+			resolved, err := incidents.Acknowledge("albert", datatypes.Acknowledgement{User: "haig", Resolved: true, Time: now})
+			So(err, ShouldBeNil)
+			So(resolved.Status, ShouldEqual, datatypes.INCIDENT_RESOLVED)
+
+			// The recovery that comes later is nothing new
+			So(incidents.Record(event("2", "bapaume", "thiepval", service.ALIVE), now), ShouldBeNil)
+
+			_, err = incidents.Acknowledge("albert", datatypes.Acknowledgement{User: "haig", Time: now})
+			So(err, ShouldNotBeNil)
+
+			missing, err := incidents.Acknowledge("bapaume", datatypes.Acknowledgement{User: "haig", Time: now})
+			So(missing, ShouldBeNil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Only keeps so many resolved incidents", func() {
+			for i, burst := range []string{"albert", "bapaume", "cambrai"} {
+				at := now.Add(time.Duration(i) * time.Hour)
+				incidents.Record(event(burst+"-failed", burst, "thiepval", service.UNHEALTHY), at)
+				incidents.Record(event(burst+"-recovered", burst, "thiepval", service.ALIVE), at)
+			}
+
+			all := incidents.All(datatypes.INCIDENT_RESOLVED)
+			So(len(all), ShouldEqual, 2)
+			So(all[0].ID, ShouldEqual, "cambrai")
+			So(incidents.Get("albert"), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package tracker
+
+import (
+	"path"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+)
+
+// Decides which events we keep at all. Patterns are shell globs, so
+// "ci-*" matches every CI cluster. Deny lists win over allow lists, and an
+// empty allow list allows everything. Cluster patterns are checked against
+// both the aliased and the original cluster name.
+type IngestFilter struct {
+	AllowClusters []string
+	DenyClusters  []string
+	AllowServices []string
+	DenyServices  []string
+	Discarded     *metrics.CounterVec
+}
+
+func NewIngestFilter() *IngestFilter {
+	return &IngestFilter{
+		Discarded: metrics.NewCounterVec(
+			"superside_discarded_events_total",
+			"Events thrown away at ingest by the allow and deny lists",
+			"cluster",
+		),
+	}
+}
+
+func matchesAny(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func allowedBy(allow []string, deny []string, names ...string) bool {
+	if matchesAny(deny, names...) {
+		return false
+	}
+
+	return len(allow) == 0 || matchesAny(allow, names...)
+}
+
+// Should we keep this notification? Counts the ones we don't.
+func (f *IngestFilter) Allows(notice *datatypes.Notification) bool {
+	allowed := allowedBy(f.AllowClusters, f.DenyClusters, notice.ClusterName, notice.OriginalClusterName) &&
+		allowedBy(f.AllowServices, f.DenyServices, notice.Event.Service.Name)
+
+	if !allowed {
+		f.Discarded.Inc(notice.ClusterName)
+	}
+
+	return allowed
+}
@@ -0,0 +1,48 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IngestFilter(t *testing.T) {
+	Convey("IngestFilter", t, func() {
+		filter := NewIngestFilter()
+		notice := noticeFor("verdun", service.ALIVE, time.Now().UTC())
+
+		Convey("Allows everything by default", func() {
+			So(filter.Allows(&notice), ShouldBeTrue)
+		})
+
+		Convey("Drops denied clusters and counts them", func() {
+			filter.DenyClusters = []string{"fr*"}
+
+			So(filter.Allows(&notice), ShouldBeFalse)
+			So(filter.Discarded.Get("france"), ShouldEqual, 1)
+		})
+
+		Convey("Matches the original cluster name too", func() {
+			filter.DenyClusters = []string{"ci-*"}
+			notice.OriginalClusterName = "ci-1234"
+
+			So(filter.Allows(&notice), ShouldBeFalse)
+		})
+
+		Convey("Only keeps allowed services when there's an allow list", func() {
+			filter.AllowServices = []string{"somme", "marne"}
+			So(filter.Allows(&notice), ShouldBeFalse)
+
+			filter.AllowServices = append(filter.AllowServices, "verdun")
+			So(filter.Allows(&notice), ShouldBeTrue)
+		})
+
+		Convey("Lets deny lists win over allow lists", func() {
+			filter.AllowServices = []string{"*"}
+			filter.DenyServices = []string{"verdun"}
+			So(filter.Allows(&notice), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package tracker
+
+import (
+	"sync"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Metadata about each service that Sidecar doesn't know, like the team
+// that owns it or its tier. It comes from the config and the
+// labels API, and is attached to the notifications about the service so
+// that alerts carry it downstream.
+type ServiceLabels struct {
+	labels map[string]map[string]string // Service name => label => value
+	lock   sync.RWMutex
+}
+
+func NewServiceLabels(labels map[string]map[string]string) *ServiceLabels {
+	serviceLabels := &ServiceLabels{labels: make(map[string]map[string]string, len(labels))}
+
+	for svcName, svcLabels := range labels {
+		serviceLabels.Set(svcName, svcLabels)
+	}
+
+	return serviceLabels
+}
+
+// Replace the labels for svcName. No labels removes the entry.
+func (l *ServiceLabels) Set(svcName string, labels map[string]string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(labels) == 0 {
+		delete(l.labels, svcName)
+		return
+	}
+
+	l.labels[svcName] = copyLabels(labels)
+}
+
+// The labels for one service, or nil if it has none
+func (l *ServiceLabels) Get(svcName string) map[string]string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if labels, ok := l.labels[svcName]; ok {
+		return copyLabels(labels)
+	}
+
+	return nil
+}
+
+// Return a copy of the labels for every service
+func (l *ServiceLabels) All() map[string]map[string]string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	all := make(map[string]map[string]string, len(l.labels))
+	for svcName, labels := range l.labels {
+		all[svcName] = copyLabels(labels)
+	}
+
+	return all
+}
+
+// Attach the service's labels to a notification about it
+func (l *ServiceLabels) Enrich(notice *datatypes.Notification) {
+	if notice.Event == nil {
+		return
+	}
+
+	notice.Labels = l.Get(notice.Event.Service.Name)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+
+	return copied
+}
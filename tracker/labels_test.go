@@ -0,0 +1,45 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ServiceLabels(t *testing.T) {
+	Convey("Service labels", t, func() {
+		labels := NewServiceLabels(map[string]map[string]string{
+			"verdun": {"team": "infantry", "tier": "1"},
+		})
+
+		notice := &datatypes.Notification{
+			Event: &catalog.ChangeEvent{Service: service.Service{Name: "verdun", Status: service.UNHEALTHY}},
+		}
+
+		Convey("Are attached to notifications about the service", func() {
+			labels.Enrich(notice)
+			So(notice.Labels["team"], ShouldEqual, "infantry")
+			So(notice.LabelPairs(), ShouldResemble, []string{"team=infantry", "tier=1"})
+
+			notice.Event.Service.Name = "somme"
+			labels.Enrich(notice)
+			So(notice.Labels, ShouldBeNil)
+		})
+
+		Convey("Can be replaced and removed", func() {
+			labels.Set("somme", map[string]string{"team": "artillery"})
+			labels.Set("verdun", nil)
+
+			So(labels.Get("verdun"), ShouldBeNil)
+			So(labels.All(), ShouldResemble, map[string]map[string]string{"somme": {"team": "artillery"}})
+		})
+
+		Convey("Hand out copies", func() {
+			labels.Get("verdun")["team"] = "cavalry"
+			So(labels.Get("verdun")["team"], ShouldEqual, "infantry")
+		})
+	})
+}
@@ -0,0 +1,24 @@
+package tracker
+
+import (
+	"github.com/nitro/superside/datatypes"
+)
+
+// The runbook, dashboard and other URLs for each service, so whoever gets
+// paged can go straight to them. Stored like the labels, name => URL.
+type ServiceLinks struct {
+	*ServiceLabels
+}
+
+func NewServiceLinks(links map[string]map[string]string) *ServiceLinks {
+	return &ServiceLinks{NewServiceLabels(links)}
+}
+
+// Attach the service's links to a notification about it
+func (l *ServiceLinks) Enrich(notice *datatypes.Notification) {
+	if notice.Event == nil {
+		return
+	}
+
+	notice.Links = l.Get(notice.Event.Service.Name)
+}
@@ -0,0 +1,39 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ServiceLinks(t *testing.T) {
+	Convey("Service links", t, func() {
+		links := NewServiceLinks(map[string]map[string]string{
+			"verdun": {"runbook": "https://wiki/verdun", "dashboard": "https://grafana/verdun"},
+		})
+
+		notice := &datatypes.Notification{
+			Event: &catalog.ChangeEvent{Service: service.Service{Name: "verdun", Status: service.UNHEALTHY}},
+		}
+
+		Convey("Are attached to notifications about the service", func() {
+			links.Enrich(notice)
+			So(notice.Links["runbook"], ShouldEqual, "https://wiki/verdun")
+			So(notice.LinkPairs(), ShouldResemble, []string{
+				"dashboard=https://grafana/verdun", "runbook=https://wiki/verdun",
+			})
+			So(notice.Labels, ShouldBeNil)
+
+			notice.Event.Service.Name = "somme"
+			links.Enrich(notice)
+			So(notice.Links, ShouldBeNil)
+		})
+
+		Convey("Leave notifications without a service alone", func() {
+			links.Enrich(&datatypes.Notification{Type: datatypes.HEARTBEAT_NOTICE})
+		})
+	})
+}
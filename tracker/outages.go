@@ -0,0 +1,130 @@
+package tracker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_OUTAGE_MIN_DURATION = 5 * time.Minute // Shorter blips aren't outages
+)
+
+// A window during which a service had at least one unhealthy instance in a
+// cluster. Ongoing ones hadn't recovered as of End.
+type OutageWindow struct {
+	ClusterName string
+	Service     string
+	Hostnames   []string // Every host that was unhealthy during it
+	Start       time.Time
+	End         time.Time
+	Ongoing     bool
+}
+
+func (w *OutageWindow) Duration() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+// An outage in progress, and which instances are unhealthy right now
+type openOutage struct {
+	window    *OutageWindow
+	unhealthy map[string]bool // "host/id" => still unhealthy
+	hosts     map[string]bool
+}
+
+// Work out the outage windows from the stored events, oldest first. Empty
+// clusterName means all clusters.
+func (t *Tracker) GetOutageWindows(clusterName string, minDuration time.Duration, now time.Time) []OutageWindow {
+	var events []datatypes.Notification
+	for _, notice := range t.GetSvcEventsList() {
+		if clusterName == "" || notice.ClusterName == clusterName {
+			events = append(events, notice)
+		}
+	}
+
+	return findOutageWindows(events, minDuration, now)
+}
+
+// Replay the events, opening a window when a service's first instance
+// goes unhealthy and closing it when none are left unhealthy. Windows
+// shorter than minDuration are dropped.
+func findOutageWindows(events []datatypes.Notification, minDuration time.Duration, now time.Time) []OutageWindow {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).Before(eventTime(&events[j]))
+	})
+
+	var windows []OutageWindow
+	open := make(map[string]*openOutage)
+
+	keep := func(window *OutageWindow) {
+		if window.Duration() >= minDuration {
+			windows = append(windows, *window)
+		}
+	}
+
+	for i := range events {
+		notice := &events[i]
+		if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+			continue
+		}
+
+		svc := notice.Event.Service
+		key := notice.ClusterName + "/" + svc.Name
+		instance := svc.Hostname + "/" + svc.ID
+		current, ok := open[key]
+
+		if svc.Status == service.UNHEALTHY {
+			if !ok {
+				current = &openOutage{
+					window: &OutageWindow{
+						ClusterName: notice.ClusterName,
+						Service:     svc.Name,
+						Start:       eventTime(notice),
+					},
+					unhealthy: make(map[string]bool),
+					hosts:     make(map[string]bool),
+				}
+				open[key] = current
+			}
+			current.unhealthy[instance] = true
+			if !current.hosts[svc.Hostname] {
+				current.hosts[svc.Hostname] = true
+				current.window.Hostnames = append(current.window.Hostnames, svc.Hostname)
+			}
+			continue
+		}
+
+		if !ok || !current.unhealthy[instance] {
+			continue
+		}
+
+		delete(current.unhealthy, instance)
+		if len(current.unhealthy) == 0 {
+			current.window.End = eventTime(notice)
+			keep(current.window)
+			delete(open, key)
+		}
+	}
+
+	for _, current := range open {
+		current.window.End = now
+		current.window.Ongoing = true
+		keep(current.window)
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		if !windows[i].Start.Equal(windows[j].Start) {
+			return windows[i].Start.Before(windows[j].Start)
+		}
+		return windows[i].ClusterName+"/"+windows[i].Service <
+			windows[j].ClusterName+"/"+windows[j].Service
+	})
+
+	for i := range windows {
+		sort.Strings(windows[i].Hostnames)
+	}
+
+	return windows
+}
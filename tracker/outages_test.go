@@ -0,0 +1,81 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_OutageWindows(t *testing.T) {
+	Convey("Working out outage windows", t, func() {
+		baseTime := time.Date(1916, time.February, 21, 7, 0, 0, 0, time.UTC)
+		var events []datatypes.Notification
+
+		record := func(clusterName string, hostname string, svcName string, status int, offset time.Duration) {
+			events = append(events, datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: clusterName,
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{
+						ID: hostname, Name: svcName, Hostname: hostname, Status: status,
+					},
+					Time: baseTime.Add(offset),
+				},
+			})
+		}
+
+		Convey("Spans from the first instance failing to the last recovering", func() {
+			record("france", "meuse", "verdun", service.UNHEALTHY, 0)
+			record("france", "somme", "verdun", service.UNHEALTHY, 10*time.Minute)
+			record("france", "meuse", "verdun", service.ALIVE, 20*time.Minute)
+			record("france", "somme", "verdun", service.ALIVE, 30*time.Minute)
+
+			windows := findOutageWindows(events, time.Minute, baseTime.Add(time.Hour))
+			So(len(windows), ShouldEqual, 1)
+			So(windows[0].Start, ShouldEqual, baseTime)
+			So(windows[0].Duration(), ShouldEqual, 30*time.Minute)
+			So(windows[0].Hostnames, ShouldResemble, []string{"meuse", "somme"})
+			So(windows[0].Ongoing, ShouldBeFalse)
+		})
+
+		Convey("Skips short blips", func() {
+			record("france", "meuse", "verdun", service.UNHEALTHY, 0)
+			record("france", "meuse", "verdun", service.ALIVE, time.Minute)
+
+			So(findOutageWindows(events, 5*time.Minute, baseTime.Add(time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("Keeps the ones still going, up to now", func() {
+			record("france", "meuse", "verdun", service.UNHEALTHY, 0)
+			record("belgium", "liege", "namur", service.UNHEALTHY, -time.Hour)
+			record("belgium", "liege", "namur", service.ALIVE, -30*time.Minute)
+
+			windows := findOutageWindows(events, time.Minute, baseTime.Add(time.Hour))
+			So(len(windows), ShouldEqual, 2)
+			So(windows[0].Service, ShouldEqual, "namur")
+			So(windows[1].Service, ShouldEqual, "verdun")
+			So(windows[1].Ongoing, ShouldBeTrue)
+			So(windows[1].End, ShouldEqual, baseTime.Add(time.Hour))
+		})
+
+		Convey("Come from the stored events in one cluster", func() {
+			record("france", "meuse", "verdun", service.UNHEALTHY, 0)
+			record("belgium", "liege", "namur", service.UNHEALTHY, 0)
+
+			state := NewTracker(10, &store.NoopStore{})
+			for i := range events {
+				events[i].ID = events[i].ClusterName
+				state.insertEvent(&events[i])
+			}
+
+			windows := state.GetOutageWindows("belgium", time.Minute, baseTime.Add(time.Hour))
+			So(len(windows), ShouldEqual, 1)
+			So(windows[0].ClusterName, ShouldEqual, "belgium")
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package tracker
+
+import (
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	CRITICAL_LANE_SIZE = 500 // Room for a whole storm's worth of failures
+	ROUTINE_LANE_SIZE  = 100
+)
+
+// A listener with a lane of its own for critical notifications, so they
+// aren't stuck behind, or dropped for, a backlog of routine ones while
+// the reader is slow. See Notification.IsCritical.
+type PriorityListener struct {
+	Critical chan *datatypes.Notification
+	Routine  chan *datatypes.Notification
+}
+
+// Subscribe a listener that gets critical notifications in their own lane
+func (t *Tracker) GetPriorityListener() *PriorityListener {
+	listener := &PriorityListener{
+		Critical: make(chan *datatypes.Notification, CRITICAL_LANE_SIZE),
+		Routine:  make(chan *datatypes.Notification, ROUTINE_LANE_SIZE),
+	}
+
+	t.listenLock.Lock()
+	t.priorityListeners = append(t.priorityListeners, listener)
+	t.listenLock.Unlock()
+
+	return listener
+}
+
+func (t *Tracker) RemovePriorityListener(victim *PriorityListener) {
+	t.listenLock.Lock()
+	defer t.listenLock.Unlock()
+
+	for i, listener := range t.priorityListeners {
+		if listener == victim {
+			t.priorityListeners = append(t.priorityListeners[:i], t.priorityListeners[i+1:]...)
+			close(listener.Critical)
+			close(listener.Routine)
+			return
+		}
+	}
+}
+
+// Hand the notification to the right lane of each priority listener.
+// Expects the listenLock to be held.
+func (t *Tracker) tellPriorityListeners(notice *datatypes.Notification) {
+	critical := notice.IsCritical()
+
+	for _, listener := range t.priorityListeners {
+		lane := listener.Routine
+		if critical {
+			lane = listener.Critical
+		}
+
+		select {
+		case lane <- notice:
+		default:
+		}
+	}
+}
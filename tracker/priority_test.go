@@ -0,0 +1,61 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PriorityListener(t *testing.T) {
+	Convey("Priority listeners", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		listener := state.GetPriorityListener()
+
+		notice := func(status int) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:  datatypes.SERVICE_EVENT_NOTICE,
+				Event: &catalog.ChangeEvent{Service: service.Service{Status: status}},
+			}
+		}
+
+		Convey("Get critical notifications in their own lane", func() {
+			failure := notice(service.UNHEALTHY)
+			recovery := notice(service.ALIVE)
+			state.tellSvcEventListeners(recovery)
+			state.tellSvcEventListeners(failure)
+
+			So(<-listener.Critical, ShouldEqual, failure)
+			So(<-listener.Routine, ShouldEqual, recovery)
+			So(len(listener.Critical)+len(listener.Routine), ShouldEqual, 0)
+		})
+
+		Convey("Still get critical notifications when the routine lane is full", func() {
+			for i := 0; i < ROUTINE_LANE_SIZE*2; i++ {
+				state.tellSvcEventListeners(notice(service.ALIVE))
+			}
+			failure := notice(service.TOMBSTONE)
+			state.tellSvcEventListeners(failure)
+
+			So(len(listener.Routine), ShouldEqual, ROUTINE_LANE_SIZE)
+			So(<-listener.Critical, ShouldEqual, failure)
+		})
+
+		Convey("Are counted in the vars", func() {
+			So(state.Vars().PriorityListeners, ShouldEqual, 1)
+		})
+
+		Convey("Have both lanes closed when removed", func() {
+			state.RemovePriorityListener(listener)
+
+			_, ok := <-listener.Critical
+			So(ok, ShouldBeFalse)
+			_, ok = <-listener.Routine
+			So(ok, ShouldBeFalse)
+			So(state.Vars().PriorityListeners, ShouldEqual, 0)
+		})
+	})
+}
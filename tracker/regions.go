@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"sort"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Groups clusters into regions or environments, e.g. "us-east" => the three
+// production clusters there. Clusters that aren't in any region have an empty
+// region. The map is fixed at startup from the config.
+type RegionMap struct {
+	clusters map[string][]string // Region => cluster names
+	regions  map[string]string   // Cluster name => region
+}
+
+func NewRegionMap(regions map[string][]string) *RegionMap {
+	regionMap := &RegionMap{
+		clusters: make(map[string][]string, len(regions)),
+		regions:  make(map[string]string, len(regions)*2),
+	}
+
+	for region, clusters := range regions {
+		sorted := append([]string{}, clusters...)
+		sort.Strings(sorted)
+		regionMap.clusters[region] = sorted
+
+		for _, clusterName := range clusters {
+			regionMap.regions[clusterName] = region
+		}
+	}
+
+	return regionMap
+}
+
+// The region a cluster belongs to, or "" if it isn't in one
+func (r *RegionMap) RegionOf(clusterName string) string {
+	return r.regions[clusterName]
+}
+
+// The clusters in a region
+func (r *RegionMap) ClustersIn(region string) []string {
+	return append([]string{}, r.clusters[region]...)
+}
+
+// Return a copy of the whole region map
+func (r *RegionMap) All() map[string][]string {
+	all := make(map[string][]string, len(r.clusters))
+	for region := range r.clusters {
+		all[region] = r.ClustersIn(region)
+	}
+
+	return all
+}
+
+// Mark a notification with the region its cluster is in
+func (r *RegionMap) Enrich(notice *datatypes.Notification) {
+	notice.Region = r.RegionOf(notice.ClusterName)
+}
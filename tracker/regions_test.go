@@ -0,0 +1,35 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_RegionMap(t *testing.T) {
+	Convey("RegionMap", t, func() {
+		regions := NewRegionMap(map[string][]string{
+			"western-front": {"france", "belgium"},
+			"eastern-front": {"russia"},
+		})
+
+		Convey("Looks up the region for a cluster", func() {
+			So(regions.RegionOf("belgium"), ShouldEqual, "western-front")
+			So(regions.RegionOf("italy"), ShouldEqual, "")
+		})
+
+		Convey("Lists the clusters in a region", func() {
+			So(regions.ClustersIn("western-front"), ShouldResemble, []string{"belgium", "france"})
+			So(regions.ClustersIn("gallipoli"), ShouldBeEmpty)
+			So(len(regions.All()), ShouldEqual, 2)
+		})
+
+		Convey("Marks notifications with their region", func() {
+			notice := noticeFor("verdun", service.UNHEALTHY, time.Now().UTC())
+			regions.Enrich(&notice)
+			So(notice.Region, ShouldEqual, "western-front")
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package tracker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	MINUTE_ROLLUP_RETENTION = 24 * time.Hour
+	HOUR_ROLLUP_RETENTION   = 14 * 24 * time.Hour
+)
+
+// Counts of the transitions seen for one service in one cluster during the
+// period starting at Start.
+type Rollup struct {
+	Start       time.Time
+	Resolution  string
+	ClusterName string
+	Service     string
+	Total       int
+	Transitions map[string]int // e.g. "Alive->Unhealthy" => 3
+}
+
+type rollupKey struct {
+	start       int64
+	clusterName string
+	service     string
+}
+
+// Keeps per-minute and per-hour counts of service transitions so dashboards
+// can chart change velocity without pulling the raw events.
+type Rollups struct {
+	minutes map[rollupKey]*Rollup
+	hours   map[rollupKey]*Rollup
+	lock    sync.RWMutex
+}
+
+func NewRollups() *Rollups {
+	return &Rollups{
+		minutes: make(map[rollupKey]*Rollup, 100),
+		hours:   make(map[rollupKey]*Rollup, 100),
+	}
+}
+
+// Count a notification in the minute and hour buckets that cover it
+func (r *Rollups) Record(notice *datatypes.Notification) {
+	if notice.Event == nil || notice.Type != datatypes.SERVICE_EVENT_NOTICE {
+		return
+	}
+
+	when := notice.Event.Time.UTC()
+	transition := notice.Transition()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	addToBucket(r.minutes, "minute", when.Truncate(time.Minute), notice, transition)
+	addToBucket(r.hours, "hour", when.Truncate(time.Hour), notice, transition)
+}
+
+func addToBucket(buckets map[rollupKey]*Rollup, resolution string, start time.Time,
+	notice *datatypes.Notification, transition string) {
+
+	key := rollupKey{start.Unix(), notice.ClusterName, notice.Event.Service.Name}
+
+	rollup, ok := buckets[key]
+	if !ok {
+		rollup = &Rollup{
+			Start:       start,
+			Resolution:  resolution,
+			ClusterName: notice.ClusterName,
+			Service:     notice.Event.Service.Name,
+			Transitions: make(map[string]int, 2),
+		}
+		buckets[key] = rollup
+	}
+
+	rollup.Total += 1
+	rollup.Transitions[transition] += 1
+}
+
+// Throw away any buckets that are older than we keep around
+func (r *Rollups) Prune(now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	pruneBuckets(r.minutes, now.Add(-MINUTE_ROLLUP_RETENTION))
+	pruneBuckets(r.hours, now.Add(-HOUR_ROLLUP_RETENTION))
+}
+
+func pruneBuckets(buckets map[rollupKey]*Rollup, cutoff time.Time) {
+	for key, rollup := range buckets {
+		if rollup.Start.Before(cutoff) {
+			delete(buckets, key)
+		}
+	}
+}
+
+// Return the rollups at the given resolution ("minute" or "hour") that start
+// at or after since. Empty clusterName or svcName match everything.
+func (r *Rollups) Query(resolution string, since time.Time,
+	clusterName string, svcName string) ([]Rollup, error) {
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var buckets map[rollupKey]*Rollup
+	switch resolution {
+	case "minute":
+		buckets = r.minutes
+	case "hour":
+		buckets = r.hours
+	default:
+		return nil, errors.New("Resolution must be one of 'minute' or 'hour'")
+	}
+
+	rollups := make([]Rollup, 0, len(buckets))
+	for _, rollup := range buckets {
+		if rollup.Start.Before(since) ||
+			(clusterName != "" && rollup.ClusterName != clusterName) ||
+			(svcName != "" && rollup.Service != svcName) {
+			continue
+		}
+
+		copied := *rollup
+		copied.Transitions = make(map[string]int, len(rollup.Transitions))
+		for transition, count := range rollup.Transitions {
+			copied.Transitions[transition] = count
+		}
+		rollups = append(rollups, copied)
+	}
+
+	sort.Sort(byStartAndName(rollups))
+	return rollups, nil
+}
+
+type byStartAndName []Rollup
+
+func (s byStartAndName) Len() int      { return len(s) }
+func (s byStartAndName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byStartAndName) Less(i, j int) bool {
+	if !s[i].Start.Equal(s[j].Start) {
+		return s[i].Start.Before(s[j].Start)
+	}
+	if s[i].ClusterName != s[j].ClusterName {
+		return s[i].ClusterName < s[j].ClusterName
+	}
+	return s[i].Service < s[j].Service
+}
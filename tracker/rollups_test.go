@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Rollups(t *testing.T) {
+	Convey("Rollups", t, func() {
+		rollups := NewRollups()
+		baseTime := time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+
+		record := func(name string, status int, when time.Time) {
+			notice := noticeFor(name, status, when)
+			notice.Event.PreviousStatus = service.ALIVE
+			rollups.Record(&notice)
+		}
+
+		record("db", service.UNHEALTHY, baseTime)
+		record("db", service.UNHEALTHY, baseTime.Add(10*time.Second))
+		record("db", service.TOMBSTONE, baseTime.Add(90*time.Second))
+		record("cache", service.UNHEALTHY, baseTime)
+
+		Convey("Counts transitions per minute", func() {
+			minutes, err := rollups.Query("minute", baseTime, "", "db")
+
+			So(err, ShouldBeNil)
+			So(len(minutes), ShouldEqual, 2)
+			So(minutes[0].Total, ShouldEqual, 2)
+			So(minutes[0].Transitions["Alive->Unhealthy"], ShouldEqual, 2)
+			So(minutes[1].Transitions["Alive->Tombstone"], ShouldEqual, 1)
+		})
+
+		Convey("Counts transitions per hour", func() {
+			hours, _ := rollups.Query("hour", baseTime, "france", "")
+
+			So(len(hours), ShouldEqual, 2)
+			So(hours[0].Service, ShouldEqual, "cache")
+			So(hours[1].Total, ShouldEqual, 3)
+		})
+
+		Convey("Rejects unknown resolutions", func() {
+			_, err := rollups.Query("fortnight", baseTime, "", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Prunes old buckets", func() {
+			rollups.Prune(baseTime.Add(25 * time.Hour))
+
+			minutes, _ := rollups.Query("minute", time.Time{}, "", "")
+			hours, _ := rollups.Query("hour", time.Time{}, "", "")
+			So(minutes, ShouldBeEmpty)
+			So(len(hours), ShouldEqual, 2)
+		})
+	})
+}
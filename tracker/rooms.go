@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	CLUSTER_LISTENER_SIZE = 100
+)
+
+// Subscribe a listener that only gets one cluster's notifications, along
+// with ones that aren't about any cluster, like heartbeats. Cheaper than
+// filtering everything when there are many listeners.
+func (t *Tracker) GetClusterListener(clusterName string) chan *datatypes.Notification {
+	listenChan := make(chan *datatypes.Notification, CLUSTER_LISTENER_SIZE)
+
+	t.listenLock.Lock()
+	if t.clusterListeners == nil {
+		t.clusterListeners = make(map[string][]chan *datatypes.Notification, 5)
+	}
+	t.clusterListeners[clusterName] = append(t.clusterListeners[clusterName], listenChan)
+	t.listenLock.Unlock()
+
+	return listenChan
+}
+
+func (t *Tracker) RemoveClusterListener(clusterName string, victim chan *datatypes.Notification) {
+	t.listenLock.Lock()
+	defer t.listenLock.Unlock()
+
+	listeners := t.clusterListeners[clusterName]
+	for i, listener := range listeners {
+		if listener == victim {
+			listeners = append(listeners[:i], listeners[i+1:]...)
+			close(listener)
+			break
+		}
+	}
+
+	if len(listeners) == 0 {
+		delete(t.clusterListeners, clusterName)
+	} else {
+		t.clusterListeners[clusterName] = listeners
+	}
+}
+
+// Hand a notification to its cluster's listeners, or all of them when it's
+// not about a cluster. Called with the listenLock held.
+func (t *Tracker) tellClusterListeners(notice *datatypes.Notification) {
+	send := func(listeners []chan *datatypes.Notification) {
+		for _, listener := range listeners {
+			select {
+			case listener <- notice:
+			default:
+			}
+		}
+	}
+
+	if notice.ClusterName != "" {
+		send(t.clusterListeners[notice.ClusterName])
+		return
+	}
+
+	for _, listeners := range t.clusterListeners {
+		send(listeners)
+	}
+}
@@ -0,0 +1,47 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ClusterListeners(t *testing.T) {
+	Convey("Cluster listeners", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+
+		france := state.GetClusterListener("france")
+		belgium := state.GetClusterListener("belgium")
+
+		Convey("Only get their own cluster's notifications", func() {
+			state.tellSvcEventListeners(&datatypes.Notification{
+				Type: datatypes.SERVICE_EVENT_NOTICE, ClusterName: "france", Severity: datatypes.SEVERITY_INFO,
+			})
+
+			So(len(france), ShouldEqual, 1)
+			So(len(belgium), ShouldEqual, 0)
+		})
+
+		Convey("All get notifications that aren't about a cluster", func() {
+			state.tellSvcEventListeners(&datatypes.Notification{
+				Type: datatypes.HEARTBEAT_NOTICE, Severity: datatypes.SEVERITY_INFO,
+			})
+
+			So(len(france), ShouldEqual, 1)
+			So(len(belgium), ShouldEqual, 1)
+		})
+
+		Convey("Are counted and can be removed", func() {
+			So(state.Vars().ClusterListeners, ShouldEqual, 2)
+			So(state.ListenerDepths().Clusters["france"], ShouldResemble, []int{0})
+
+			state.RemoveClusterListener("france", france)
+			_, open := <-france
+			So(open, ShouldBeFalse)
+			So(state.Vars().ClusterListeners, ShouldEqual, 1)
+			So(state.ListenerDepths().Clusters, ShouldNotContainKey, "france")
+		})
+	})
+}
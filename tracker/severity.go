@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/match"
+)
+
+// What severity rules can see on top of the notification's own variables:
+// how many instances of the service the cluster has left and how many of
+// them are alive, so rules can say e.g. `instances_alive < 2 && "tier=1" in labels`
+var severityVariables = []string{"instances", "instances_alive"}
+
+// Gives notifications matching the expression a severity
+type SeverityRule struct {
+	Severity string
+	Match    *match.Expression
+}
+
+func NewSeverityRule(severity string, expression string) (*SeverityRule, error) {
+	level, err := datatypes.ParseSeverity(severity)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := match.CompileWith(expression, severityVariables...)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid severity match '%s': %s", expression, err.Error())
+	}
+
+	return &SeverityRule{Severity: level, Match: compiled}, nil
+}
+
+// Decides how bad each notification is. The first of the Rules to match
+// wins. When none do, service events that leave no instances alive are
+// critical, other failures are warnings, as is flapping, clusters going
+// silent are critical and everything else is info.
+type Classifier struct {
+	Rules []*SeverityRule
+}
+
+func NewClassifier() *Classifier {
+	return &Classifier{}
+}
+
+// Set the notification's severity, using the cluster views for how many
+// instances are left. Expects the views to include the notification.
+func (c *Classifier) Classify(notice *datatypes.Notification, views *ClusterViews) {
+	var svcName string
+	if notice.Event != nil {
+		svcName = notice.Event.Service.Name
+	}
+	alive, total := views.Instances(notice.ClusterName, svcName)
+
+	if len(c.Rules) > 0 {
+		vars := map[string]interface{}{
+			"instances":       float64(total),
+			"instances_alive": float64(alive),
+		}
+
+		for _, rule := range c.Rules {
+			matched, err := rule.Match.MatchesWith(notice, vars)
+			if err != nil {
+				log.Warnf("Unable to evaluate severity match '%s': %s", rule.Match.Source, err.Error())
+				continue
+			}
+			if matched {
+				notice.Severity = rule.Severity
+				return
+			}
+		}
+	}
+
+	notice.Severity = defaultSeverity(notice, alive)
+}
+
+func defaultSeverity(notice *datatypes.Notification, alive int) string {
+	switch notice.Type {
+	case datatypes.CLUSTER_SILENT_NOTICE:
+		return datatypes.SEVERITY_CRITICAL
+	case datatypes.FLAPPING_NOTICE, datatypes.ANOMALY_NOTICE, datatypes.CLOCK_SKEW_NOTICE:
+		return datatypes.SEVERITY_WARNING
+	case datatypes.SERVICE_EVENT_NOTICE:
+		if notice.Event == nil {
+			break
+		}
+
+		switch notice.Event.Service.Status {
+		case service.UNHEALTHY:
+			if alive == 0 {
+				return datatypes.SEVERITY_CRITICAL
+			}
+			return datatypes.SEVERITY_WARNING
+		case service.TOMBSTONE:
+			// Old instances go away in every deploy, so it only matters
+			// when it was the last one standing
+			if alive == 0 {
+				return datatypes.SEVERITY_CRITICAL
+			}
+		}
+	}
+
+	return datatypes.SEVERITY_INFO
+}
@@ -0,0 +1,135 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Classifier(t *testing.T) {
+	Convey("The classifier", t, func() {
+		classifier := NewClassifier()
+		views := NewClusterViews()
+		baseTime := time.Now().UTC()
+
+		// Record the change in the views, like the tracker does first
+		change := func(id string, svcName string, status int) *datatypes.Notification {
+			notice := &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service:        service.Service{ID: id, Name: svcName, Hostname: "meuse", Status: status},
+					PreviousStatus: service.ALIVE,
+					Time:           baseTime,
+				},
+			}
+			views.Record(notice)
+			return notice
+		}
+
+		Convey("Counts the instances left", func() {
+			change("1", "verdun", service.ALIVE)
+			change("2", "verdun", service.UNHEALTHY)
+			change("3", "verdun", service.TOMBSTONE)
+			change("4", "somme", service.ALIVE)
+
+			alive, total := views.Instances("france", "verdun")
+			So(alive, ShouldEqual, 1)
+			So(total, ShouldEqual, 2)
+		})
+
+		Convey("By default", func() {
+			Convey("Warns about a failure while other instances are alive", func() {
+				change("1", "verdun", service.ALIVE)
+				failure := change("2", "verdun", service.UNHEALTHY)
+				classifier.Classify(failure, views)
+				So(failure.Severity, ShouldEqual, datatypes.SEVERITY_WARNING)
+			})
+
+			Convey("Is critical when nothing is left alive", func() {
+				failure := change("1", "verdun", service.UNHEALTHY)
+				classifier.Classify(failure, views)
+				So(failure.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+
+				gone := change("2", "somme", service.TOMBSTONE)
+				classifier.Classify(gone, views)
+				So(gone.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+			})
+
+			Convey("Doesn't mind an old instance going away", func() {
+				change("1", "verdun", service.ALIVE)
+				gone := change("2", "verdun", service.TOMBSTONE)
+				classifier.Classify(gone, views)
+				So(gone.Severity, ShouldEqual, datatypes.SEVERITY_INFO)
+			})
+
+			Convey("Knows about other kinds of notice", func() {
+				silent := &datatypes.Notification{Type: datatypes.CLUSTER_SILENT_NOTICE, ClusterName: "france"}
+				classifier.Classify(silent, views)
+				So(silent.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+
+				flapping := &datatypes.Notification{Type: datatypes.FLAPPING_NOTICE, ClusterName: "france"}
+				classifier.Classify(flapping, views)
+				So(flapping.Severity, ShouldEqual, datatypes.SEVERITY_WARNING)
+			})
+		})
+
+		Convey("Uses the first rule that matches", func() {
+			tier1, err := NewSeverityRule("Critical", `status == UNHEALTHY && instances_alive < 2 && "tier=1" in labels`)
+			So(err, ShouldBeNil)
+			quiet, err := NewSeverityRule("info", `service == "somme"`)
+			So(err, ShouldBeNil)
+			classifier.Rules = []*SeverityRule{tier1, quiet}
+
+			change("1", "verdun", service.ALIVE)
+			failure := change("2", "verdun", service.UNHEALTHY)
+			failure.Labels = map[string]string{"tier": "1"}
+			classifier.Classify(failure, views)
+			So(failure.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+
+			other := change("3", "somme", service.UNHEALTHY)
+			classifier.Classify(other, views)
+			So(other.Severity, ShouldEqual, datatypes.SEVERITY_INFO)
+
+			Convey("and falls back to the defaults", func() {
+				missed := change("4", "marne", service.UNHEALTHY)
+				classifier.Classify(missed, views)
+				So(missed.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+			})
+		})
+
+		Convey("Rejects bad rules", func() {
+			_, err := NewSeverityRule("apocalyptic", `true`)
+			So(err, ShouldNotBeNil)
+
+			_, err = NewSeverityRule("info", `general == "joffre"`)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func Test_TrackerClassifies(t *testing.T) {
+	Convey("The tracker classifies what it stores", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		go state.ProcessUpdates()
+
+		listener := state.GetSvcEventsListener()
+		state.EnqueueUpdate(catalog.StateChangedEvent{
+			State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+			ChangeEvent: catalog.ChangeEvent{
+				Service:        service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.UNHEALTHY},
+				PreviousStatus: service.ALIVE,
+				Time:           time.Now().UTC(),
+			},
+		})
+
+		notice := <-listener
+		So(notice.Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+		So(state.GetEvent(notice.ID).Severity, ShouldEqual, datatypes.SEVERITY_CRITICAL)
+	})
+}
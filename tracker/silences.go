@@ -0,0 +1,111 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/satori/go.uuid"
+)
+
+// Holds the current set of silences and decides which notifications they cover
+type SilenceList struct {
+	silences map[string]*datatypes.Silence
+	lock     sync.RWMutex
+}
+
+func NewSilenceList() *SilenceList {
+	return &SilenceList{silences: make(map[string]*datatypes.Silence, 5)}
+}
+
+// Validate and store a new silence, assigning it an ID. Returns the stored copy.
+func (l *SilenceList) Add(silence datatypes.Silence) (*datatypes.Silence, error) {
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now().UTC()
+	}
+
+	err := silence.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if silence.ID == "" {
+		silence.ID = uuid.NewV4().String()
+	}
+
+	l.lock.Lock()
+	l.silences[silence.ID] = &silence
+	l.lock.Unlock()
+
+	return &silence, nil
+}
+
+// Remove a silence by ID, returns false if we didn't have it
+func (l *SilenceList) Remove(id string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	_, ok := l.silences[id]
+	delete(l.silences, id)
+	return ok
+}
+
+// Return all the silences that haven't expired yet, soonest expiry first
+func (l *SilenceList) All(now time.Time) []datatypes.Silence {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	silences := make([]datatypes.Silence, 0, len(l.silences))
+	for id, silence := range l.silences {
+		if silence.IsExpired(now) {
+			delete(l.silences, id)
+			continue
+		}
+		silences = append(silences, *silence)
+	}
+
+	sort.Sort(byExpiry(silences))
+	return silences
+}
+
+// Find an active silence matching the given cluster, service and host
+func (l *SilenceList) Match(now time.Time, clusterName string, svcName string, hostname string) *datatypes.Silence {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for _, silence := range l.silences {
+		if silence.IsActive(now) && silence.Matches(clusterName, svcName, hostname) {
+			copied := *silence
+			return &copied
+		}
+	}
+
+	return nil
+}
+
+// Mark the notification as suppressed if any active silence covers it
+func (l *SilenceList) Apply(notice *datatypes.Notification, now time.Time) {
+	var svcName, hostname string
+
+	if notice.Event != nil {
+		svcName = notice.Event.Service.Name
+		hostname = notice.Event.Service.Hostname
+	} else if notice.Flap != nil {
+		svcName = notice.Flap.Service
+	}
+
+	silence := l.Match(now, notice.ClusterName, svcName, hostname)
+	if silence == nil {
+		return
+	}
+
+	notice.Suppressed = true
+	notice.SilenceID = silence.ID
+}
+
+type byExpiry []datatypes.Silence
+
+func (s byExpiry) Len() int           { return len(s) }
+func (s byExpiry) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byExpiry) Less(i, j int) bool { return s[i].ExpiresAt.Before(s[j].ExpiresAt) }
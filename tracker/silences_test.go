@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SilenceList(t *testing.T) {
+	Convey("SilenceList", t, func() {
+		silences := NewSilenceList()
+		now := time.Now().UTC()
+
+		notice := noticeFor("db", service.UNHEALTHY, now)
+		notice.Event.Service.Hostname = "joffre"
+
+		Convey("Rejects silences without matchers or expiry", func() {
+			_, err := silences.Add(datatypes.Silence{ExpiresAt: now.Add(time.Hour)})
+			So(err, ShouldNotBeNil)
+
+			_, err = silences.Add(datatypes.Silence{Service: "db"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Marks matching notifications as suppressed", func() {
+			silence, err := silences.Add(datatypes.Silence{
+				ClusterName: "france",
+				Service:     "db",
+				ExpiresAt:   now.Add(time.Hour),
+			})
+			So(err, ShouldBeNil)
+			So(silence.ID, ShouldNotBeEmpty)
+
+			silences.Apply(&notice, now.Add(time.Minute))
+
+			So(notice.Suppressed, ShouldBeTrue)
+			So(notice.SilenceID, ShouldEqual, silence.ID)
+		})
+
+		Convey("Leaves notifications alone when a matcher differs", func() {
+			silences.Add(datatypes.Silence{
+				Service:   "db",
+				Hostname:  "foch",
+				ExpiresAt: now.Add(time.Hour),
+			})
+
+			silences.Apply(&notice, now.Add(time.Minute))
+			So(notice.Suppressed, ShouldBeFalse)
+		})
+
+		Convey("Ignores silences that have expired", func() {
+			silences.Add(datatypes.Silence{Service: "db", ExpiresAt: now.Add(time.Hour)})
+
+			silences.Apply(&notice, now.Add(2*time.Hour))
+			So(notice.Suppressed, ShouldBeFalse)
+			So(silences.All(now.Add(2*time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("Removes silences by ID", func() {
+			silence, _ := silences.Add(datatypes.Silence{Service: "db", ExpiresAt: now.Add(time.Hour)})
+
+			So(silences.Remove(silence.ID), ShouldBeTrue)
+			So(silences.Remove(silence.ID), ShouldBeFalse)
+		})
+	})
+}
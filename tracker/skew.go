@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/metrics"
+	"github.com/satori/go.uuid"
+)
+
+// Spots events whose own timestamp is more than MaxSkew away from when we
+// received them, which usually means the host's clock is wrong. We can't
+// order those by their timestamp, so they get the receipt time instead,
+// and the first one from each host is announced so someone fixes its NTP.
+// A zero MaxSkew turns it off.
+type ClockSkew struct {
+	MaxSkew time.Duration
+	Events  *metrics.CounterVec // Skewed events by cluster and host
+	Offsets *metrics.GaugeVec   // The latest skew by cluster and host
+	skewed  map[string]bool     // "cluster/host" => skewed right now
+	lock    sync.Mutex
+}
+
+func NewClockSkew(maxSkew time.Duration) *ClockSkew {
+	return &ClockSkew{
+		MaxSkew: maxSkew,
+		Events: metrics.NewCounterVec(
+			"superside_clock_skewed_events_total",
+			"Events whose timestamp was too far off to trust, so the receipt time was used",
+			"cluster", "host",
+		),
+		Offsets: metrics.NewGaugeVec(
+			"superside_clock_skew_seconds",
+			"How far ahead of our clock the host's last event timestamp was",
+			"cluster", "host",
+		),
+		skewed: make(map[string]bool, 5),
+	}
+}
+
+// Check the event's timestamp against when we received it. A skewed event
+// gets the receipt time and its skew recorded on it. Returns the host the
+// first time it's skewed, nil otherwise.
+func (c *ClockSkew) Check(notice *datatypes.Notification, receivedAt time.Time) *datatypes.SkewedHost {
+	if c.MaxSkew <= 0 || notice.Event == nil || notice.Event.Time.IsZero() {
+		return nil
+	}
+
+	hostname := notice.Event.Service.Hostname
+	key := notice.ClusterName + "/" + hostname
+	skew := notice.Event.Time.Sub(receivedAt)
+	c.Offsets.Set(skew.Seconds(), notice.ClusterName, hostname)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if skew <= c.MaxSkew && skew >= -c.MaxSkew {
+		delete(c.skewed, key)
+		return nil
+	}
+
+	notice.ClockSkew = skew
+	notice.Event.Time = receivedAt
+	c.Events.Inc(notice.ClusterName, hostname)
+
+	if c.skewed[key] {
+		return nil
+	}
+	c.skewed[key] = true
+
+	return &datatypes.SkewedHost{
+		ClusterName: notice.ClusterName,
+		Hostname:    hostname,
+		Skew:        skew,
+		MaxSkew:     c.MaxSkew,
+	}
+}
+
+// Announce a host whose clock has gone wrong
+func skewNotice(notice *datatypes.Notification, host *datatypes.SkewedHost) *datatypes.Notification {
+	return &datatypes.Notification{
+		ID:                  uuid.NewV4().String(),
+		Type:                datatypes.CLOCK_SKEW_NOTICE,
+		ClusterName:         notice.ClusterName,
+		OriginalClusterName: notice.OriginalClusterName,
+		Region:              notice.Region,
+		Severity:            datatypes.SEVERITY_WARNING,
+		ReceivedAt:          notice.ReceivedAt,
+		Source:              datatypes.SUPERSIDE_SOURCE,
+		SkewedHost:          host,
+	}
+}
@@ -0,0 +1,68 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ClockSkew(t *testing.T) {
+	Convey("The clock skew check", t, func() {
+		skew := NewClockSkew(10 * time.Minute)
+		receivedAt := time.Date(1916, time.July, 1, 7, 30, 0, 0, time.UTC)
+
+		event := func(hostname string, at time.Time) *datatypes.Notification {
+			return &datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: "france",
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{Name: "somme", Hostname: hostname, Status: service.ALIVE},
+					Time:    at,
+				},
+			}
+		}
+
+		Convey("Leaves timestamps close to the receipt time alone", func() {
+			notice := event("thiepval", receivedAt.Add(-time.Minute))
+
+			So(skew.Check(notice, receivedAt), ShouldBeNil)
+			So(notice.Event.Time, ShouldResemble, receivedAt.Add(-time.Minute))
+			So(notice.ClockSkew, ShouldEqual, 0)
+			So(skew.Offsets.Get("france", "thiepval"), ShouldEqual, -60)
+		})
+
+		Convey("Falls back to the receipt time for skewed events", func() {
+			notice := event("thiepval", receivedAt.Add(2*time.Hour))
+
+			host := skew.Check(notice, receivedAt)
+			So(host, ShouldResemble, &datatypes.SkewedHost{
+				ClusterName: "france", Hostname: "thiepval", Skew: 2 * time.Hour, MaxSkew: 10 * time.Minute,
+			})
+			So(notice.Event.Time, ShouldResemble, receivedAt)
+			So(notice.ClockSkew, ShouldEqual, 2*time.Hour)
+			So(skew.Events.Get("france", "thiepval"), ShouldEqual, 1)
+		})
+
+		Convey("Reports each host once until its clock is fixed", func() {
+			So(skew.Check(event("thiepval", receivedAt.Add(-time.Hour)), receivedAt), ShouldNotBeNil)
+			So(skew.Check(event("thiepval", receivedAt.Add(-time.Hour)), receivedAt), ShouldBeNil)
+			So(skew.Check(event("pozieres", receivedAt.Add(-time.Hour)), receivedAt), ShouldNotBeNil)
+			So(skew.Events.Get("france", "thiepval"), ShouldEqual, 2)
+
+			So(skew.Check(event("thiepval", receivedAt), receivedAt), ShouldBeNil)
+			So(skew.Check(event("thiepval", receivedAt.Add(-time.Hour)), receivedAt), ShouldNotBeNil)
+		})
+
+		Convey("Does nothing when turned off", func() {
+			skew.MaxSkew = 0
+			notice := event("thiepval", receivedAt.Add(2*time.Hour))
+
+			So(skew.Check(notice, receivedAt), ShouldBeNil)
+			So(notice.ClockSkew, ShouldEqual, 0)
+		})
+	})
+}
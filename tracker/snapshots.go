@@ -0,0 +1,361 @@
+package tracker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+)
+
+const (
+	DEFAULT_SNAPSHOT_DEPTH    = 5
+	DEFAULT_SNAPSHOT_INTERVAL = 1 * time.Minute
+	DEFAULT_SNAPSHOT_REBASE   = 10
+)
+
+// A gzipped JSON copy of the whole Sidecar state as one host reported it
+type Snapshot struct {
+	ClusterName string
+	Hostname    string // The Sidecar host that sent it
+	Time        time.Time
+	seq         int
+	data        atomic.Value // *snapshotData
+}
+
+// How a snapshot is stored. Consecutive states are nearly identical, so
+// most snapshots only keep a JSON merge patch (RFC 7386) that turns the
+// next newer snapshot back into this one.
+type snapshotData struct {
+	gzipped []byte    // The full state, or the patch when newer is set
+	newer   *Snapshot // The snapshot the patch applies to
+}
+
+func newSnapshot(clusterName string, hostname string, when time.Time, gzipped []byte) *Snapshot {
+	snapshot := &Snapshot{
+		ClusterName: clusterName,
+		Hostname:    hostname,
+		Time:        when,
+	}
+	snapshot.data.Store(&snapshotData{gzipped: gzipped})
+
+	return snapshot
+}
+
+func (s *Snapshot) stored() *snapshotData {
+	return s.data.Load().(*snapshotData)
+}
+
+// Whether we keep the whole state rather than a patch
+func (s *Snapshot) IsFull() bool {
+	return s.stored().newer == nil
+}
+
+// The snapshot as JSON, still compressed
+func (s *Snapshot) Gzipped() []byte {
+	data := s.stored()
+	if data.newer == nil {
+		return data.gzipped
+	}
+
+	plain, err := s.JSON()
+	if err != nil {
+		return nil
+	}
+
+	gzipped, err := gzipBytes(plain)
+	if err != nil {
+		return nil
+	}
+
+	return gzipped
+}
+
+// The snapshot as plain JSON, rebuilt from the newer ones when we only
+// kept a patch
+func (s *Snapshot) JSON() ([]byte, error) {
+	data := s.stored()
+
+	plain, err := gunzipBytes(data.gzipped)
+	if err != nil || data.newer == nil {
+		return plain, err
+	}
+
+	newer, err := data.newer.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return applySnapshotPatch(newer, plain)
+}
+
+// Keeps the last few full Sidecar states we received for each cluster.
+// Every event carries the full state, so rather than keep one per event we
+// keep one per Interval, always updating the newest with the latest state.
+// Only the two newest and every Rebase-th snapshot hold the whole state,
+// the rest are stored as patches against the next newer one.
+type SnapshotStore struct {
+	Depth     int
+	Interval  time.Duration
+	Rebase    int
+	snapshots map[string][]*Snapshot // Cluster name => oldest to newest
+	sequences map[string]int         // Cluster name => snapshots taken
+	lock      sync.RWMutex
+}
+
+func NewSnapshotStore(depth int, interval time.Duration, rebase int) *SnapshotStore {
+	if depth <= 0 {
+		depth = DEFAULT_SNAPSHOT_DEPTH
+	}
+
+	if interval <= 0 {
+		interval = DEFAULT_SNAPSHOT_INTERVAL
+	}
+
+	if rebase <= 0 {
+		rebase = DEFAULT_SNAPSHOT_REBASE
+	}
+
+	return &SnapshotStore{
+		Depth:     depth,
+		Interval:  interval,
+		Rebase:    rebase,
+		snapshots: make(map[string][]*Snapshot, 5),
+		sequences: make(map[string]int, 5),
+	}
+}
+
+// Save the state under the given cluster name, which may be an alias
+func (s *SnapshotStore) Record(clusterName string, state *catalog.ServicesState, when time.Time) error {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(state)
+	if err != nil {
+		return err
+	}
+
+	gzipped, err := gzipBytes(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	snapshot := newSnapshot(clusterName, state.Hostname, when, gzipped)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snapshots := s.snapshots[clusterName]
+	if len(snapshots) > 0 && when.Sub(snapshots[len(snapshots)-1].Time) < s.Interval {
+		// Keep the newest one fresh, but don't move its time on
+		snapshot.Time = snapshots[len(snapshots)-1].Time
+		snapshot.seq = snapshots[len(snapshots)-1].seq
+		snapshots[len(snapshots)-1] = snapshot
+		return nil
+	}
+
+	snapshot.seq = s.sequences[clusterName]
+	s.sequences[clusterName]++
+
+	snapshots = append(snapshots, snapshot)
+	if len(snapshots) > s.Depth {
+		snapshots = snapshots[len(snapshots)-s.Depth:]
+	}
+	s.snapshots[clusterName] = snapshots
+
+	// The one before the newest won't change again, so the one before
+	// that can now be stored as a patch against it
+	if len(snapshots) >= 3 {
+		err = s.encodeDelta(snapshots[len(snapshots)-3], snapshots[len(snapshots)-2])
+	}
+
+	return err
+}
+
+// Swap a full snapshot for a patch against the next newer one, unless it's
+// one we keep whole so rebuilding never has to walk too far
+func (s *SnapshotStore) encodeDelta(snapshot *Snapshot, newer *Snapshot) error {
+	if !snapshot.IsFull() || snapshot.seq%s.Rebase == 0 {
+		return nil
+	}
+
+	plain, err := snapshot.JSON()
+	if err != nil {
+		return err
+	}
+
+	newerPlain, err := newer.JSON()
+	if err != nil {
+		return err
+	}
+
+	patch, err := snapshotPatch(newerPlain, plain)
+	if err != nil {
+		return err
+	}
+
+	gzipped, err := gzipBytes(patch)
+	if err != nil {
+		return err
+	}
+
+	snapshot.data.Store(&snapshotData{gzipped: gzipped, newer: newer})
+
+	return nil
+}
+
+// The most recent snapshot for a cluster, or nil if we haven't seen it
+func (s *SnapshotStore) Latest(clusterName string) *Snapshot {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	snapshots := s.snapshots[clusterName]
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	return snapshots[len(snapshots)-1]
+}
+
+// All the snapshots we have for a cluster, oldest first
+func (s *SnapshotStore) All(clusterName string) []*Snapshot {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]*Snapshot{}, s.snapshots[clusterName]...)
+}
+
+func gzipBytes(plain []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+
+	_, err := writer.Write(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(gzipped []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+func decodeGeneric(data []byte) (interface{}, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+// A JSON merge patch that turns the from state into the to state
+func snapshotPatch(from []byte, to []byte) ([]byte, error) {
+	fromDoc, err := decodeGeneric(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toDoc, err := decodeGeneric(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(fromDoc, toDoc))
+}
+
+// Apply a patch from snapshotPatch. The result goes back through the
+// ServicesState so it encodes exactly as the original did.
+func applySnapshotPatch(base []byte, patch []byte) ([]byte, error) {
+	baseDoc, err := decodeGeneric(base)
+	if err != nil {
+		return nil, err
+	}
+
+	patchDoc, err := decodeGeneric(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := json.Marshal(applyMergePatch(baseDoc, patchDoc))
+	if err != nil {
+		return nil, err
+	}
+
+	var state catalog.ServicesState
+	err = json.Unmarshal(merged, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = json.NewEncoder(&buf).Encode(&state)
+	return buf.Bytes(), err
+}
+
+func mergePatch(from interface{}, to interface{}) interface{} {
+	fromMap, fromOk := from.(map[string]interface{})
+	toMap, toOk := to.(map[string]interface{})
+	if !fromOk || !toOk {
+		return to
+	}
+
+	patch := make(map[string]interface{})
+	for key, value := range toMap {
+		old, ok := fromMap[key]
+		if !ok {
+			patch[key] = value
+			continue
+		}
+
+		if !reflect.DeepEqual(old, value) {
+			patch[key] = mergePatch(old, value)
+		}
+	}
+
+	// Null removes a key
+	for key := range fromMap {
+		if _, ok := toMap[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
+
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{}, len(patchMap))
+	}
+
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+		targetMap[key] = applyMergePatch(targetMap[key], value)
+	}
+
+	return targetMap
+}
@@ -0,0 +1,96 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SnapshotStore(t *testing.T) {
+	Convey("SnapshotStore", t, func() {
+		store := NewSnapshotStore(2, time.Minute, 0)
+		baseTime := time.Date(1916, time.February, 21, 7, 15, 0, 0, time.UTC)
+
+		stateFrom := func(hostname string) *catalog.ServicesState {
+			return &catalog.ServicesState{ClusterName: "a1b2c3", Hostname: hostname}
+		}
+
+		Convey("Returns nothing for clusters it hasn't seen", func() {
+			So(store.Latest("france"), ShouldBeNil)
+		})
+
+		Convey("Round trips the state through compression", func() {
+			store.Record("france", stateFrom("verdun"), baseTime)
+
+			snapshot := store.Latest("france")
+			So(snapshot.Hostname, ShouldEqual, "verdun")
+
+			data, err := snapshot.JSON()
+			So(err, ShouldBeNil)
+
+			var state catalog.ServicesState
+			So(json.Unmarshal(data, &state), ShouldBeNil)
+			So(state.ClusterName, ShouldEqual, "a1b2c3")
+		})
+
+		Convey("Refreshes the newest snapshot inside the interval", func() {
+			store.Record("france", stateFrom("verdun"), baseTime)
+			store.Record("france", stateFrom("somme"), baseTime.Add(time.Second))
+
+			So(len(store.All("france")), ShouldEqual, 1)
+			So(store.Latest("france").Hostname, ShouldEqual, "somme")
+			So(store.Latest("france").Time, ShouldResemble, baseTime)
+		})
+
+		Convey("Keeps only the configured number per cluster", func() {
+			for i := 0; i < 3; i++ {
+				store.Record("france", stateFrom("verdun"), baseTime.Add(time.Duration(i)*time.Hour))
+			}
+
+			all := store.All("france")
+			So(len(all), ShouldEqual, 2)
+			So(all[0].Time, ShouldResemble, baseTime.Add(time.Hour))
+		})
+
+		Convey("Stores older snapshots as patches and rebuilds them", func() {
+			store := NewSnapshotStore(6, time.Minute, 4)
+			var expected [][]byte
+
+			for i := 0; i < 6; i++ {
+				state := catalog.NewServicesState()
+				state.ClusterName = "a1b2c3"
+				state.Hostname = "verdun"
+				for j := 0; j <= i; j++ {
+					state.Servers[fmt.Sprintf("fort-%d", j)] = catalog.NewServer(fmt.Sprintf("fort-%d", j))
+				}
+				if i == 3 {
+					delete(state.Servers, "fort-0")
+				}
+
+				data, err := json.Marshal(state)
+				So(err, ShouldBeNil)
+				expected = append(expected, append(data, '\n'))
+
+				So(store.Record("france", state, baseTime.Add(time.Duration(i)*time.Hour)), ShouldBeNil)
+			}
+
+			all := store.All("france")
+			So(all[0].IsFull(), ShouldBeTrue) // Rebased
+			So(all[1].IsFull(), ShouldBeFalse)
+			So(all[3].IsFull(), ShouldBeFalse)
+			So(all[4].IsFull(), ShouldBeTrue)
+			So(all[5].IsFull(), ShouldBeTrue)
+
+			for i, snapshot := range all {
+				data, err := snapshot.JSON()
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, string(expected[i]))
+				So(snapshot.Gzipped(), ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"time"
+)
+
+const (
+	STATUS_OPERATIONAL = "operational"
+	STATUS_DEGRADED    = "degraded" // Some instances aren't alive
+	STATUS_OUTAGE      = "outage"   // None are
+	STATUS_UNKNOWN     = "unknown"  // We haven't heard of any instances
+)
+
+// Worse statuses rank higher. Unknown services don't drag the overall
+// status down.
+var statusRanks = map[string]int{
+	STATUS_UNKNOWN:     0,
+	STATUS_OPERATIONAL: 0,
+	STATUS_DEGRADED:    1,
+	STATUS_OUTAGE:      2,
+}
+
+// How one service is doing, with nothing about clusters or hosts, so it
+// can be shown to the public
+type PublicServiceStatus struct {
+	Name      string
+	Status    string
+	UpdatedAt time.Time `json:",omitempty"`
+}
+
+// The health of a set of services, for a status page
+type PublicStatus struct {
+	Status    string // The worst of the services
+	Services  []PublicServiceStatus
+	UpdatedAt time.Time // When any of them last changed
+}
+
+// Sum up the current health of these services across every cluster, in the
+// order given
+func (t *Tracker) GetPublicStatus(services []string) *PublicStatus {
+	status := &PublicStatus{
+		Status:   STATUS_OPERATIONAL,
+		Services: make([]PublicServiceStatus, 0, len(services)),
+	}
+
+	for _, svcName := range services {
+		alive, total, lastChange := t.ClusterViews.ServiceHealth(svcName)
+
+		svcStatus := PublicServiceStatus{Name: svcName, Status: STATUS_OPERATIONAL, UpdatedAt: lastChange}
+		switch {
+		case total == 0:
+			svcStatus.Status = STATUS_UNKNOWN
+		case alive == 0:
+			svcStatus.Status = STATUS_OUTAGE
+		case alive < total:
+			svcStatus.Status = STATUS_DEGRADED
+		}
+
+		if statusRanks[svcStatus.Status] > statusRanks[status.Status] {
+			status.Status = svcStatus.Status
+		}
+		if lastChange.After(status.UpdatedAt) {
+			status.UpdatedAt = lastChange
+		}
+		status.Services = append(status.Services, svcStatus)
+	}
+
+	return status
+}
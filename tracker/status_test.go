@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PublicStatus(t *testing.T) {
+	Convey("The public status", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+		baseTime := time.Date(1918, time.November, 11, 11, 0, 0, 0, time.UTC)
+
+		record := func(clusterName string, hostname string, svcName string, status int, offset time.Duration) {
+			state.ClusterViews.Record(&datatypes.Notification{
+				Type:        datatypes.SERVICE_EVENT_NOTICE,
+				ClusterName: clusterName,
+				Event: &catalog.ChangeEvent{
+					Service: service.Service{ID: svcName, Name: svcName, Hostname: hostname, Status: status},
+					Time:    baseTime.Add(offset),
+				},
+			})
+		}
+
+		record("france", "meuse", "verdun", service.ALIVE, 0)
+		record("belgium", "liege", "verdun", service.ALIVE, time.Minute)
+		record("france", "meuse", "somme", service.ALIVE, 0)
+
+		Convey("Is operational when everything's alive", func() {
+			status := state.GetPublicStatus([]string{"verdun", "somme"})
+			So(status.Status, ShouldEqual, STATUS_OPERATIONAL)
+			So(status.Services[0], ShouldResemble, PublicServiceStatus{
+				Name: "verdun", Status: STATUS_OPERATIONAL, UpdatedAt: baseTime.Add(time.Minute),
+			})
+			So(status.UpdatedAt, ShouldEqual, baseTime.Add(time.Minute))
+		})
+
+		Convey("Takes the worst of the services", func() {
+			record("belgium", "liege", "verdun", service.UNHEALTHY, time.Hour)
+			So(state.GetPublicStatus([]string{"verdun", "somme"}).Status, ShouldEqual, STATUS_DEGRADED)
+
+			record("france", "meuse", "somme", service.UNHEALTHY, time.Hour)
+			status := state.GetPublicStatus([]string{"verdun", "somme"})
+			So(status.Status, ShouldEqual, STATUS_OUTAGE)
+			So(status.Services[1].Status, ShouldEqual, STATUS_OUTAGE)
+		})
+
+		Convey("Doesn't know about services it hasn't seen", func() {
+			status := state.GetPublicStatus([]string{"marne"})
+			So(status.Status, ShouldEqual, STATUS_OPERATIONAL)
+			So(status.Services[0].Status, ShouldEqual, STATUS_UNKNOWN)
+		})
+	})
+}
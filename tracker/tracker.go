@@ -1,8 +1,11 @@
 package tracker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -10,7 +13,11 @@ import (
 	"github.com/newrelic/sidecar/service"
 	"github.com/nitro/superside/circular"
 	"github.com/nitro/superside/datatypes"
-	"github.com/nitro/superside/persistence"
+	"github.com/nitro/superside/hooks"
+	"github.com/nitro/superside/metrics"
+	"github.com/nitro/superside/search"
+	"github.com/nitro/superside/store"
+	"github.com/satori/go.uuid"
 )
 
 const (
@@ -18,27 +25,114 @@ const (
 	CHANNEL_BUFFER_SIZE     = 25
 	INITIAL_DEPLOYMENT_SIZE = 20
 	PERSISTENCE_INTERVAL    = 30 * time.Second
+	FLAP_CHECK_INTERVAL     = 30 * time.Second
+	WATCHDOG_CHECK_INTERVAL = 30 * time.Second
+	ROLLUP_PRUNE_INTERVAL   = 5 * time.Minute
 )
 
+// Bucket upper bounds in seconds for ingest latency
+var LATENCY_BUCKETS = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
 type Tracker struct {
+	eventsReceived      uint64 // Accessed atomically, so kept 64-bit aligned up here
+	eventsStored        uint64
+	persistPaused       int32 // Accessed atomically
 	svcEvents           *circular.SvcEventsBuffer
-	svcEventsChan       chan catalog.StateChangedEvent
+	svcEventsChan       chan receivedEvent
 	svcEventsListeners  []chan *datatypes.Notification
+	storageListeners    []chan *datatypes.Notification // Every event, even when coalescing
+	clusterListeners    map[string][]chan *datatypes.Notification
 	deploymentListeners []chan *datatypes.Deployment
+	priorityListeners   []*PriorityListener
 	listenLock          sync.Mutex
 	stateLock           sync.Mutex
+	persistLock         sync.Mutex
+	lastModified        time.Time // When the stored events last changed
 	deployments         map[string]*circular.DeploymentsBuffer
-	store               persistence.Store
+	store               store.Store
+	quit                chan struct{} // Closed by Stop(), ends every loop
+	stopOnce            sync.Once
 	EventsLatch         *ClusterEventsLatch
+	Dependencies        *DependencyMap
+	Regions             *RegionMap
+	Labels              *ServiceLabels
+	Links               *ServiceLinks
+	ClusterAliases      map[string]string // Sidecar cluster name => name we show
+	IngestFilter        *IngestFilter
+	Hooks               *hooks.Chain   // Optional
+	Snapshots           *SnapshotStore // Optional
+	ClusterViews        *ClusterViews
+	FlapDetector        *FlapDetector
+	Silences            *SilenceList
+	Rollups             *Rollups
+	StateDurations      *StateDurations
+	Watchdog            *ClusterWatchdog
+	Versions            *VersionTracker
+	Draining            *DrainTracker
+	Correlator          *Correlator
+	Classifier          *Classifier
+	Incidents           *IncidentList
+	Anomalies           *AnomalyDetector
+	Coalescer           *Coalescer
+	ClockSkew           *ClockSkew
+	HeartbeatInterval   time.Duration // Optional, how often to send a HEARTBEAT_NOTICE
+	Compactor           *Compactor
+	IngestLatency       *metrics.HistogramVec
+	EventCounts         *metrics.CounterVec
+	SearchIndex         *search.Index
+}
+
+// An event along with the time we received it and where it came from. Or
+// a marker from WaitForUpdates(), with only processed set, which gets
+// closed when it's reached.
+type receivedEvent struct {
+	evt        catalog.StateChangedEvent
+	receivedAt time.Time
+	source     string // Empty for Sidecar
+	id         string // Optional, the ID the sender was given for it
+	processed  chan struct{}
 }
 
-func NewTracker(svcEventsRingSize int, store persistence.Store) *Tracker {
+func NewTracker(svcEventsRingSize int, store store.Store) *Tracker {
 	tracker := &Tracker{
-		svcEventsChan: make(chan catalog.StateChangedEvent, CHANNEL_BUFFER_SIZE),
-		svcEvents:     circular.NewSvcEventsBuffer(svcEventsRingSize),
-		deployments:   make(map[string]*circular.DeploymentsBuffer, INITIAL_DEPLOYMENT_SIZE),
-		store:         store,
-		EventsLatch:   NewClusterEventsLatch(),
+		svcEventsChan:  make(chan receivedEvent, CHANNEL_BUFFER_SIZE),
+		svcEvents:      circular.NewSvcEventsBuffer(svcEventsRingSize),
+		deployments:    make(map[string]*circular.DeploymentsBuffer, INITIAL_DEPLOYMENT_SIZE),
+		store:          store,
+		quit:           make(chan struct{}),
+		EventsLatch:    NewClusterEventsLatch(),
+		Dependencies:   NewDependencyMap(nil),
+		Regions:        NewRegionMap(nil),
+		Labels:         NewServiceLabels(nil),
+		Links:          NewServiceLinks(nil),
+		IngestFilter:   NewIngestFilter(),
+		ClusterViews:   NewClusterViews(),
+		FlapDetector:   NewFlapDetector(DEFAULT_FLAP_THRESHOLD, DEFAULT_FLAP_WINDOW),
+		Silences:       NewSilenceList(),
+		Rollups:        NewRollups(),
+		StateDurations: NewStateDurations(),
+		Watchdog:       NewClusterWatchdog(0),
+		Versions:       NewVersionTracker(DEFAULT_VERSION_HISTORY),
+		Draining:       NewDrainTracker(),
+		Correlator:     NewCorrelator(DEFAULT_CORRELATION_WINDOW),
+		Classifier:     NewClassifier(),
+		Incidents:      NewIncidentList(DEFAULT_INCIDENT_HISTORY),
+		Anomalies:      NewAnomalyDetector(0),
+		Coalescer:      NewCoalescer(0),
+		ClockSkew:      NewClockSkew(0),
+		Compactor:      &Compactor{},
+		IngestLatency: metrics.NewHistogramVec(
+			"superside_ingest_latency_seconds",
+			"Delay between a Sidecar event happening and superside receiving it",
+			LATENCY_BUCKETS,
+			"cluster",
+		),
+		EventCounts: metrics.NewCounterVec(
+			"superside_events_total",
+			"Service events stored, carried over restarts",
+			"cluster",
+		),
+		SearchIndex: search.NewIndex(),
 	}
 
 	tracker.loadState()
@@ -48,7 +142,43 @@ func NewTracker(svcEventsRingSize int, store persistence.Store) *Tracker {
 
 // Enqueue an update to the channel. Rely on channel buffer. We block if channel is full.
 func (t *Tracker) EnqueueUpdate(evt catalog.StateChangedEvent) {
-	t.svcEventsChan <- evt
+	t.svcEventsChan <- receivedEvent{evt: evt, receivedAt: time.Now().UTC()}
+}
+
+// Enqueue an update, returning the ID its event will be stored under and
+// when we received it. Updates that the latch or filters drop never are.
+func (t *Tracker) EnqueueUpdateWithID(evt catalog.StateChangedEvent) (string, time.Time) {
+	received := receivedEvent{evt: evt, receivedAt: time.Now().UTC(), id: uuid.NewV4().String()}
+	t.svcEventsChan <- received
+
+	return received.id, received.receivedAt
+}
+
+// Enqueue an update that didn't come from Sidecar. These skip the cluster
+// latch, which only makes sense for Sidecar's duplicate events, and aren't
+// considered for deployments.
+func (t *Tracker) EnqueueUpdateFrom(source string, evt catalog.StateChangedEvent) {
+	t.svcEventsChan <- receivedEvent{evt: evt, receivedAt: time.Now().UTC(), source: source}
+}
+
+// Wait until the updates enqueued so far have been processed, or the
+// timeout passes. Returns false on timeout.
+func (t *Tracker) WaitForUpdates(timeout time.Duration) bool {
+	processed := make(chan struct{})
+	deadline := time.After(timeout)
+
+	select {
+	case t.svcEventsChan <- receivedEvent{processed: processed}:
+	case <-deadline:
+		return false
+	}
+
+	select {
+	case <-processed:
+		return true
+	case <-deadline:
+		return false
+	}
 }
 
 // Subscribe a service events listener, returns a listening channel
@@ -73,8 +203,37 @@ func (t *Tracker) GetDeploymentListener() chan *datatypes.Deployment {
 	return listenChan
 }
 
-// Announce changes to all service event listeners
-func (t *Tracker) tellSvcEventListeners(evt *catalog.StateChangedEvent) {
+// Announce changes to all service event listeners, classifying any
+// notification that hasn't been yet
+func (t *Tracker) tellSvcEventListeners(notice *datatypes.Notification) {
+	if notice.Severity == "" {
+		t.Classifier.Classify(notice, t.ClusterViews)
+	}
+
+	t.listenLock.Lock()
+	for _, listener := range t.storageListeners {
+		select {
+		case listener <- notice:
+		default:
+		}
+	}
+	t.listenLock.Unlock()
+
+	now := time.Now().UTC()
+	if t.Coalescer.Add(notice, now) {
+		// Full bursts go out without waiting for the rest of the window
+		for _, burst := range t.Coalescer.Due(now) {
+			t.broadcast(burst)
+		}
+		return
+	}
+
+	t.broadcast(notice)
+}
+
+// Hand a notification, or a burst of them, to the websockets, notifiers
+// and everyone else who doesn't need each event on its own
+func (t *Tracker) broadcast(notice *datatypes.Notification) {
 	t.listenLock.Lock()
 	defer t.listenLock.Unlock()
 
@@ -82,10 +241,13 @@ func (t *Tracker) tellSvcEventListeners(evt *catalog.StateChangedEvent) {
 	// to protect us from any blocking readers.
 	for _, listener := range t.svcEventsListeners {
 		select {
-		case listener <- datatypes.NotificationFromEvent(evt):
+		case listener <- notice:
 		default:
 		}
 	}
+
+	t.tellClusterListeners(notice)
+	t.tellPriorityListeners(notice)
 }
 
 // Announce changes to all deployment listeners
@@ -121,18 +283,21 @@ func (t *Tracker) processOneDeployment(notice *datatypes.Notification) {
 	thisDeploy := datatypes.DeploymentFromNotification(notice)
 
 	if looksLikeDeployment(notice) {
-		deploys := t.deployments[svc.Name]
+		var lastDeploy *datatypes.Deployment
+		t.stateLock.Lock()
+		if deploys := t.deployments[svc.Name]; deploys != nil {
+			lastDeploy = deploys.GetLast()
+		}
+		t.stateLock.Unlock()
 
 		// We don't have any deployments for that service so let's add it
-		if deploys == nil {
+		if lastDeploy == nil {
 			t.insertDeployment(thisDeploy)
 			log.Debug("Inserting deployment: ", thisDeploy)
 			return
 		}
 
 		// We have some and the last one matches
-		lastDeploy := deploys.GetLast()
-
 		if lastDeploy.Matches(thisDeploy) {
 			log.Debug("Found matching deployment: ", lastDeploy)
 			lastDeploy.Aggregate(thisDeploy)      // Update with new hosts
@@ -175,13 +340,22 @@ func (t *Tracker) RemoveDeploymentListener(victim chan *datatypes.Deployment) {
 
 }
 
-// Try to extrapolate when a deployment started and stopped for each service
+// Try to extrapolate when a deployment started and stopped for each service.
+// Listens for storage, so coalescing events into bursts doesn't hide them.
 func (t *Tracker) processDeployments() {
-	notifyChan := t.GetSvcEventsListener()
-	defer close(notifyChan)
+	notifyChan := t.GetStorageListener()
+	defer t.RemoveStorageListener(notifyChan)
 
-	for notice := range notifyChan {
-		t.processOneDeployment(notice)
+	for {
+		select {
+		case notice := <-notifyChan:
+			if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Source != "" {
+				continue
+			}
+			t.processOneDeployment(notice)
+		case <-t.quit:
+			return
+		}
 	}
 }
 
@@ -198,11 +372,221 @@ func (t *Tracker) insertDeployment(deploy *datatypes.Deployment) {
 	t.tellDeploymentListeners(deploy)
 }
 
-func (t *Tracker) GetSvcEventsList() []datatypes.Notification {
+// A copy of the stored events, oldest first, safe to read while updates
+// are being processed
+func (t *Tracker) storedEvents() []datatypes.Notification {
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+
 	return t.svcEvents.All()
 }
 
+func (t *Tracker) GetSvcEventsList() []datatypes.Notification {
+	events := t.storedEvents()
+	for i := range events {
+		t.Dependencies.Enrich(&events[i])
+		t.Regions.Enrich(&events[i])
+		events[i].Flapping = t.FlapDetector.IsFlapping(
+			events[i].ClusterName, events[i].Event.Service.Name,
+		)
+	}
+
+	return events
+}
+
+// When the stored events last changed, or the zero time if they haven't
+// since we started
+func (t *Tracker) LastModified() time.Time {
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+
+	return t.lastModified
+}
+
+func (t *Tracker) touch() {
+	t.stateLock.Lock()
+	t.lastModified = time.Now().UTC()
+	t.stateLock.Unlock()
+}
+
+// Look up a stored event by ID, or nil if we don't have it (any more)
+func (t *Tracker) GetEvent(id string) *datatypes.Notification {
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+
+	return t.svcEvents.Get(id)
+}
+
+// Attach an annotation to a stored event, returning the updated event. Returns
+// nil if there is no event with that ID.
+func (t *Tracker) AnnotateEvent(id string, annotation datatypes.Annotation) *datatypes.Notification {
+	if annotation.Time.IsZero() {
+		annotation.Time = time.Now().UTC()
+	}
+
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+
+	var updated *datatypes.Notification
+	t.svcEvents.Update(id, func(notice *datatypes.Notification) {
+		notice.Annotations = append(notice.Annotations, annotation)
+		copied := *notice
+		updated = &copied
+	})
+
+	if updated != nil {
+		t.lastModified = time.Now().UTC()
+		t.SearchIndex.Add(id, annotation.Text)
+	}
+
+	return updated
+}
+
+// Acknowledge (or resolve) a failure event and let all the listeners know so
+// that dashboards update and notifiers stop repeating themselves.
+func (t *Tracker) AcknowledgeEvent(id string, ack datatypes.Acknowledgement) (*datatypes.Notification, error) {
+	if ack.Time.IsZero() {
+		ack.Time = time.Now().UTC()
+	}
+
+	t.stateLock.Lock()
+
+	var updated *datatypes.Notification
+	var err error
+	t.svcEvents.Update(id, func(notice *datatypes.Notification) {
+		if !notice.IsFailure() {
+			err = errors.New("Only failure events can be acknowledged")
+			return
+		}
+
+		notice.Ack = &ack
+		copied := *notice
+		updated = &copied
+	})
+
+	if updated != nil && err == nil {
+		t.lastModified = time.Now().UTC()
+	}
+
+	t.stateLock.Unlock()
+
+	if updated == nil || err != nil {
+		return nil, err
+	}
+
+	announcement := *updated
+	announcement.Type = datatypes.ACK_NOTICE
+	t.tellSvcEventListeners(&announcement)
+
+	return updated, nil
+}
+
+// Acknowledge (or resolve) an incident and announce it. Returns nil if
+// there's no such incident.
+func (t *Tracker) AcknowledgeIncident(id string, ack datatypes.Acknowledgement) (*datatypes.Incident, error) {
+	if ack.Time.IsZero() {
+		ack.Time = time.Now().UTC()
+	}
+
+	incident, err := t.Incidents.Acknowledge(id, ack)
+	if incident == nil || err != nil {
+		return nil, err
+	}
+
+	t.tellSvcEventListeners(incidentNotice(incident, ack.Time))
+
+	return incident, nil
+}
+
+// Store an event in the history and index it for searching
+func (t *Tracker) insertEvent(notice *datatypes.Notification) {
+	t.stateLock.Lock() // We'll call this a lot but there should be very little contention
+	evicted := t.svcEvents.Insert(notice)
+	t.lastModified = time.Now().UTC()
+	t.stateLock.Unlock()
+
+	if evicted != nil {
+		t.SearchIndex.Remove(evicted.ID)
+	}
+
+	svc := notice.Event.Service
+	t.SearchIndex.Add(notice.ID, notice.ClusterName, svc.Name, svc.Hostname, svc.Image)
+	for _, annotation := range notice.Annotations {
+		t.SearchIndex.Add(notice.ID, annotation.Text)
+	}
+}
+
+// Find stored events whose service name, hostname, image, cluster or
+// annotations match the query
+func (t *Tracker) SearchEvents(query string) []datatypes.Notification {
+	ids := t.SearchIndex.Search(query)
+
+	results := []datatypes.Notification{}
+	if len(ids) == 0 {
+		return results
+	}
+
+	for _, notice := range t.GetSvcEventsList() {
+		if _, ok := ids[notice.ID]; ok {
+			results = append(results, notice)
+		}
+	}
+
+	return results
+}
+
+func (t *Tracker) GetClusterNames() []string {
+	return t.ClusterViews.Clusters()
+}
+
+// When each cluster last sent us an update and how many it sent in the last hour
+func (t *Tracker) GetClusterLastSeen() []datatypes.ClusterLastSeen {
+	return t.Watchdog.Summary(time.Now().UTC())
+}
+
+// Reconstruct the current state of a cluster from its events
+func (t *Tracker) GetClusterView(clusterName string) *datatypes.ClusterView {
+	return t.ClusterViews.Current(clusterName)
+}
+
+// Report which dependents of a service changed around the same time it did
+func (t *Tracker) GetImpact(svcName string) *Impact {
+	return t.Dependencies.ImpactOf(svcName, t.storedEvents())
+}
+
+// Record a deploy marker on the deployments timeline and announce it
+func (t *Tracker) AddMarker(marker datatypes.Marker) (*datatypes.Deployment, error) {
+	err := marker.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if marker.Time.IsZero() {
+		marker.Time = time.Now().UTC()
+	}
+
+	deploy := marker.Deployment()
+	t.insertDeployment(deploy)
+
+	return deploy, nil
+}
+
+// The image version changes we've seen, newest first. Empty clusterName or
+// svcName match everything.
+func (t *Tracker) GetVersionChanges(clusterName string, svcName string) []datatypes.VersionChange {
+	return t.Versions.History(clusterName, svcName)
+}
+
+// The hosts currently draining for maintenance. Empty clusterName matches
+// everything.
+func (t *Tracker) GetDrainingHosts(clusterName string) []DrainingHost {
+	return t.Draining.Hosts(clusterName)
+}
+
 func (t *Tracker) GetDeployments() map[string][]*datatypes.Deployment {
+	t.stateLock.Lock()
+	defer t.stateLock.Unlock()
+
 	allDeploys := make(map[string][]*datatypes.Deployment, len(t.deployments))
 	for name, ring := range t.deployments {
 		allDeploys[name] = ring.All()
@@ -210,55 +594,139 @@ func (t *Tracker) GetDeployments() map[string][]*datatypes.Deployment {
 	return allDeploys
 }
 
-// Flush the state out to the store
+// Silence alerts matching the given silence until it expires
+func (t *Tracker) AddSilence(silence datatypes.Silence) (*datatypes.Silence, error) {
+	return t.Silences.Add(silence)
+}
+
+func (t *Tracker) RemoveSilence(id string) bool {
+	return t.Silences.Remove(id)
+}
+
+func (t *Tracker) GetSilences() []datatypes.Silence {
+	return t.Silences.All(time.Now().UTC())
+}
+
+// Transition counts per cluster and service at the requested resolution
+func (t *Tracker) GetRollups(resolution string, since time.Time,
+	clusterName string, svcName string) ([]Rollup, error) {
+
+	return t.Rollups.Query(resolution, since, clusterName, svcName)
+}
+
+// Services that are currently flapping
+func (t *Tracker) GetFlapping() []datatypes.FlapStatus {
+	return t.FlapDetector.Flapping()
+}
+
+// Flush the state out to the store, unless persistence is paused
 func (t *Tracker) persist() {
-	events, err := json.Marshal(t.svcEvents.AllRaw())
-	deploys, err2 := json.Marshal(t.GetDeployments())
+	t.persistLock.Lock()
+	defer t.persistLock.Unlock()
 
-	if err != nil {
-		log.Error(err.Error())
+	if atomic.LoadInt32(&t.persistPaused) == 1 {
 		return
 	}
 
-	if err2 != nil {
-		log.Error(err2.Error())
-		return
+	t.writeState()
+}
+
+// Expects the persistLock to be held
+func (t *Tracker) writeState() {
+	events, err := json.Marshal(t.storedEvents())
+	deploys, err2 := json.Marshal(t.GetDeployments())
+	silences, err3 := json.Marshal(t.Silences.All(time.Now().UTC()))
+	counters, err4 := t.marshalCounters()
+
+	for _, err := range []error{err, err2, err3, err4} {
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
 	}
 
 	// We need a consistent view here... so lock state before writing
 	t.stateLock.Lock()
-	t.store.StoreBlob("SupersideEvents", events)
+	t.store.StoreBlob("SupersideNotifications", events)
 	t.store.StoreBlob("SupersideDeployments", deploys)
+	t.store.StoreBlob("SupersideSilences", silences)
+	t.store.StoreBlob("SupersideCounters", counters)
 	t.stateLock.Unlock()
 }
 
-// Load state from the store
-func (t *Tracker) loadState() {
-	eventsJson, err := t.store.GetBlob("SupersideEvents")
+// Put an event that was processed before, by us or a peer, back into the
+// history without telling anyone about it again
+func (t *Tracker) restoreEvent(notice *datatypes.Notification) {
+	t.insertEvent(notice)
+	t.Rollups.Record(notice)
+	t.ClusterViews.Record(notice)
+	t.Versions.Record(notice)
+	t.Draining.Record(notice)
+	if notice.Source == "" {
+		t.Watchdog.Seen(notice.ClusterName, notice.ReceivedAt)
+	}
+}
+
+// Load the event history. Older versions stored the raw StateChangedEvents
+// under a different key, so we fall back to that when there's nothing newer.
+func (t *Tracker) loadEvents() error {
+	noticesJson, err := t.store.GetBlob("SupersideNotifications")
 	if err != nil {
-		log.Error(err.Error())
-		return
+		return err
 	}
 
-	deploysJson, err := t.store.GetBlob("SupersideDeployments")
+	if len(noticesJson) > 0 {
+		var notices []datatypes.Notification
+		err = json.Unmarshal(noticesJson, &notices)
+		if err != nil {
+			return err
+		}
+
+		for i := range notices {
+			t.restoreEvent(&notices[i])
+		}
+		return nil
+	}
+
+	eventsJson, err := t.store.GetBlob("SupersideEvents")
 	if err != nil {
-		log.Error(err.Error())
-		return
+		return err
 	}
 
 	var events []catalog.StateChangedEvent
 	if len(eventsJson) > 0 {
 		err = json.Unmarshal(eventsJson, &events)
 		if err != nil {
-			log.Error(err.Error())
-			return
+			return err
 		}
 
-		for _, evt := range events {
-			t.svcEvents.Insert(evt)
+		for i := range events {
+			notice := datatypes.NotificationFromEvent(&events[i])
+			t.insertEvent(notice)
+			t.Rollups.Record(notice)
+			t.ClusterViews.Record(notice)
+			t.Versions.Record(notice)
+			t.Draining.Record(notice)
 		}
 	}
 
+	return nil
+}
+
+// Load state from the store
+func (t *Tracker) loadState() {
+	err := t.loadEvents()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	deploysJson, err := t.store.GetBlob("SupersideDeployments")
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
 	var deploys map[string][]datatypes.Deployment
 	if len(deploysJson) > 0 {
 		err = json.Unmarshal(deploysJson, &deploys)
@@ -273,29 +741,386 @@ func (t *Tracker) loadState() {
 			}
 		}
 	}
+
+	silencesJson, err := t.store.GetBlob("SupersideSilences")
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	var silences []datatypes.Silence
+	if len(silencesJson) > 0 {
+		err = json.Unmarshal(silencesJson, &silences)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+
+		for _, silence := range silences {
+			t.Silences.Add(silence)
+		}
+	}
+
+	countersJson, err := t.store.GetBlob("SupersideCounters")
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	if len(countersJson) > 0 {
+		err = t.restoreCounters(countersJson)
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}
 }
 
-// Loop forever, persisting data to store
+// Stop or start writing to the store, e.g. while it's being migrated
+func (t *Tracker) PausePersistence(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+	atomic.StoreInt32(&t.persistPaused, value)
+}
+
+// Flush the state out to the store now, rather than waiting for the next
+// interval. This writes even while persistence is paused, so a restart can
+// pause it, then save the state for the new process without a scheduled
+// write landing afterwards.
+func (t *Tracker) Persist() {
+	t.persistLock.Lock()
+	defer t.persistLock.Unlock()
+
+	t.writeState()
+}
+
+// Loop until stopped, persisting data to store
 func (t *Tracker) ManagePersistence() {
 	for {
 		select {
 		case <-time.After(PERSISTENCE_INTERVAL):
 			t.persist()
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Loop until stopped, clearing out services that have stopped flapping and
+// letting everyone know they've recovered
+func (t *Tracker) expireFlapping() {
+	for {
+		select {
+		case <-time.After(FLAP_CHECK_INTERVAL):
+			for _, status := range t.FlapDetector.Expire(time.Now().UTC()) {
+				t.touch() // The stored events' Flapping flags change with it
+
+				flap := status
+				notice := &datatypes.Notification{
+					Type:        datatypes.STABILIZED_NOTICE,
+					ClusterName: flap.ClusterName,
+					Flap:        &flap,
+				}
+				t.Regions.Enrich(notice)
+				t.Silences.Apply(notice, time.Now().UTC())
+				t.tellSvcEventListeners(notice)
+			}
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Announce a version change, carrying over what we know about the event
+// that revealed it
+func deployNotice(notice *datatypes.Notification, change *datatypes.VersionChange) *datatypes.Notification {
+	return &datatypes.Notification{
+		ID:                  uuid.NewV4().String(),
+		Type:                datatypes.DEPLOY_NOTICE,
+		Event:               notice.Event,
+		ClusterName:         notice.ClusterName,
+		OriginalClusterName: notice.OriginalClusterName,
+		Region:              notice.Region,
+		Suppressed:          notice.Suppressed,
+		SilenceID:           notice.SilenceID,
+		CorrelationID:       notice.CorrelationID,
+		Labels:              notice.Labels,
+		Links:               notice.Links,
+		ReceivedAt:          notice.ReceivedAt,
+		Source:              notice.Source,
+		Deploy:              change,
+	}
+}
+
+// Loop until stopped, letting everyone know when a cluster stops talking to us
+func (t *Tracker) watchClusters() {
+	for {
+		select {
+		case <-time.After(WATCHDOG_CHECK_INTERVAL):
+			for _, status := range t.Watchdog.Check(time.Now().UTC()) {
+				stale := status
+				log.Warnf("Cluster %s has been silent since %s", stale.ClusterName, stale.LastSeen)
+				t.tellClusterStatus(datatypes.CLUSTER_SILENT_NOTICE, &stale)
+			}
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Loop until stopped, sending a heartbeat through to the listeners every
+// HeartbeatInterval so that they can tell we're still working when the
+// clusters are quiet
+func (t *Tracker) sendHeartbeats() {
+	var sequence int64
+	for {
+		select {
+		case <-time.After(t.HeartbeatInterval):
+			sequence += 1
+			t.tellSvcEventListeners(&datatypes.Notification{
+				ID:         uuid.NewV4().String(),
+				Type:       datatypes.HEARTBEAT_NOTICE,
+				ReceivedAt: time.Now().UTC(),
+				Source:     datatypes.SUPERSIDE_SOURCE,
+				Heartbeat:  &datatypes.Heartbeat{Sequence: sequence, Interval: t.HeartbeatInterval},
+			})
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Record that we heard from a cluster, announcing it if it had gone silent
+func (t *Tracker) clusterSeen(clusterName string, at time.Time) {
+	stale := t.Watchdog.Seen(clusterName, at)
+	if stale == nil {
+		return
+	}
+
+	log.Infof("Cluster %s is talking to us again", clusterName)
+	t.tellClusterStatus(datatypes.CLUSTER_RESUMED_NOTICE, stale)
+}
+
+func (t *Tracker) tellClusterStatus(noticeType string, stale *datatypes.StaleCluster) {
+	notice := &datatypes.Notification{
+		ID:          uuid.NewV4().String(),
+		Type:        noticeType,
+		ClusterName: stale.ClusterName,
+		Stale:       stale,
+	}
+	t.Regions.Enrich(notice)
+	t.Silences.Apply(notice, time.Now().UTC())
+	t.tellSvcEventListeners(notice)
+}
+
+// Swap an ugly cluster name for its configured alias, keeping the original
+func (t *Tracker) applyClusterAlias(notice *datatypes.Notification) {
+	alias, ok := t.ClusterAliases[notice.ClusterName]
+	if !ok || alias == notice.ClusterName {
+		return
+	}
+
+	notice.OriginalClusterName = notice.ClusterName
+	notice.ClusterName = alias
+}
+
+// Note how long the event took to get to us. Clock skew between hosts can
+// make this negative, so we only count sane values in the metric.
+func (t *Tracker) recordLatency(notice *datatypes.Notification, receivedAt time.Time) {
+	notice.ReceivedAt = receivedAt
+	notice.IngestLatency = receivedAt.Sub(notice.Event.Time)
+
+	// Skewed events got the receipt time, so there's nothing to measure
+	if notice.IngestLatency >= 0 && notice.ClockSkew == 0 {
+		t.IngestLatency.Observe(notice.IngestLatency.Seconds(), notice.ClusterName)
+	}
+}
+
+// Loop until stopped, throwing away rollups we no longer need
+func (t *Tracker) pruneRollups() {
+	for {
+		select {
+		case <-time.After(ROLLUP_PRUNE_INTERVAL):
+			t.Rollups.Prune(time.Now().UTC())
+		case <-t.quit:
+			return
 		}
 	}
 }
 
-// Linearize the updates coming in from the async HTTP handler
+// Throw away the stored events the Compactor doesn't want to keep
+func (t *Tracker) compactEvents(now time.Time) int {
+	t.stateLock.Lock()
+	keep := t.Compactor.Plan(t.svcEvents.All(), now)
+	dropped := t.svcEvents.Retain(keep)
+	if len(dropped) > 0 {
+		t.lastModified = now
+	}
+	t.stateLock.Unlock()
+
+	for _, notice := range dropped {
+		t.SearchIndex.Remove(notice.ID)
+	}
+
+	return len(dropped)
+}
+
+// Loop until stopped, compacting the stored events
+func (t *Tracker) manageCompaction() {
+	for {
+		select {
+		case <-time.After(COMPACTION_INTERVAL):
+			if dropped := t.compactEvents(time.Now().UTC()); dropped > 0 {
+				log.Infof("Compaction dropped %d old events", dropped)
+			}
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Process updates until the context is done, then stop the tracker
+func (t *Tracker) Run(ctx context.Context) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case <-t.quit:
+		}
+	}()
+
+	t.ProcessUpdates()
+}
+
+// End the background loops, including ManagePersistence(), and stop
+// processing updates once the ones already enqueued are done. Safe to call
+// more than once.
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() { close(t.quit) })
+}
+
+// Linearize the updates coming in from the async HTTP handler, until
+// stopped
 func (t *Tracker) ProcessUpdates() {
 	go t.processDeployments()
+	go t.expireFlapping()
+	go t.pruneRollups()
+	go t.watchClusters()
+	if t.HeartbeatInterval > 0 {
+		go t.sendHeartbeats()
+	}
+	if t.Compactor.Enabled() {
+		go t.manageCompaction()
+	}
+	if t.Coalescer.Enabled() {
+		go t.flushBursts()
+	}
 
-	for evt := range t.svcEventsChan {
-		if !t.EventsLatch.ShouldAccept(&evt) {
+	for {
+		var received receivedEvent
+		select {
+		case received = <-t.svcEventsChan:
+		case <-t.quit:
+			// Finish the updates we already took before going
+			if len(t.svcEventsChan) == 0 {
+				return
+			}
+			received = <-t.svcEventsChan
+		}
+
+		if received.processed != nil {
+			close(received.processed)
 			continue
 		}
-		t.stateLock.Lock() // We'll call this a lot but there should be very little contention
-		t.svcEvents.Insert(evt)
-		t.stateLock.Unlock()
-		t.tellSvcEventListeners(&evt)
+
+		atomic.AddUint64(&t.eventsReceived, 1)
+
+		evt := &received.evt
+		if received.source == "" && !t.EventsLatch.ShouldAccept(evt) {
+			continue
+		}
+
+		notice := datatypes.NotificationFromEvent(evt)
+		notice.NormalizeTimes()
+		notice.Source = received.source
+		if received.id != "" {
+			notice.ID = received.id
+		}
+		t.applyClusterAlias(notice)
+		if received.source == "" {
+			t.clusterSeen(notice.ClusterName, received.receivedAt)
+		}
+		if !t.IngestFilter.Allows(notice) {
+			continue
+		}
+
+		if t.Hooks != nil && !t.Hooks.Process(notice) {
+			continue
+		}
+
+		if t.Snapshots != nil && received.source == "" {
+			err := t.Snapshots.Record(notice.ClusterName, &evt.State, received.receivedAt)
+			if err != nil {
+				log.Warnf("Unable to store state snapshot: %s", err.Error())
+			}
+		}
+
+		skewed := t.ClockSkew.Check(notice, received.receivedAt)
+		t.recordLatency(notice, received.receivedAt)
+		t.Dependencies.Enrich(notice)
+		t.Regions.Enrich(notice)
+		t.Labels.Enrich(notice)
+		t.Links.Enrich(notice)
+		t.Silences.Apply(notice, time.Now().UTC())
+		t.Draining.Record(notice)
+		t.Correlator.Record(notice, received.receivedAt)
+		t.ClusterViews.Record(notice)
+		t.Classifier.Classify(notice, t.ClusterViews)
+		incident := t.Incidents.Record(notice, received.receivedAt)
+		anomaly := t.Anomalies.Record(notice, received.receivedAt)
+
+		flap := t.FlapDetector.Record(notice)
+		notice.Flapping = t.FlapDetector.IsFlapping(notice.ClusterName, notice.Event.Service.Name)
+
+		t.insertEvent(notice)
+		atomic.AddUint64(&t.eventsStored, 1)
+		t.EventCounts.Inc(notice.ClusterName)
+		t.Rollups.Record(notice)
+		t.StateDurations.Record(notice)
+		t.tellSvcEventListeners(notice)
+
+		if incident != nil {
+			t.tellSvcEventListeners(incidentNotice(incident, received.receivedAt))
+		}
+
+		if anomaly != nil {
+			t.tellSvcEventListeners(anomalyNotice(notice, anomaly))
+		}
+
+		if skewed != nil {
+			t.tellSvcEventListeners(skewNotice(notice, skewed))
+		}
+
+		if change := t.Versions.Record(notice); change != nil {
+			t.tellSvcEventListeners(deployNotice(notice, change))
+		}
+
+		// Announce it separately when a service starts flapping
+		if flap != nil {
+			t.tellSvcEventListeners(&datatypes.Notification{
+				Type:          datatypes.FLAPPING_NOTICE,
+				Event:         notice.Event,
+				ClusterName:   notice.ClusterName,
+				Region:        notice.Region,
+				Flapping:      true,
+				Flap:          flap,
+				Suppressed:    notice.Suppressed,
+				SilenceID:     notice.SilenceID,
+				CorrelationID: notice.CorrelationID,
+				Labels:        notice.Labels,
+				Links:         notice.Links,
+			})
+		}
 	}
 }
@@ -0,0 +1,217 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_recordLatency(t *testing.T) {
+	Convey("recordLatency()", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+		baseTime := time.Now().UTC()
+
+		Convey("Stores the latency on the notification and in the metric", func() {
+			notice := noticeFor("db", service.ALIVE, baseTime)
+			tracker.recordLatency(&notice, baseTime.Add(3*time.Second))
+
+			So(notice.ReceivedAt, ShouldResemble, baseTime.Add(3*time.Second))
+			So(notice.IngestLatency, ShouldEqual, 3*time.Second)
+
+			stats := tracker.IngestLatency.Snapshots()
+			So(stats[0].Labels["cluster"], ShouldEqual, "france")
+			So(stats[0].Buckets["5"], ShouldEqual, 1)
+		})
+
+		Convey("Leaves negative latencies out of the metric", func() {
+			notice := noticeFor("db", service.ALIVE, baseTime)
+			tracker.recordLatency(&notice, baseTime.Add(-3*time.Second))
+
+			So(notice.IngestLatency, ShouldEqual, -3*time.Second)
+			So(tracker.IngestLatency.Snapshots(), ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_applyClusterAlias(t *testing.T) {
+	Convey("applyClusterAlias()", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+		tracker.ClusterAliases = map[string]string{"france": "western-front"}
+
+		Convey("Renames aliased clusters and keeps the original name", func() {
+			notice := noticeFor("db", service.ALIVE, time.Now().UTC())
+			tracker.applyClusterAlias(&notice)
+
+			So(notice.ClusterName, ShouldEqual, "western-front")
+			So(notice.OriginalClusterName, ShouldEqual, "france")
+		})
+
+		Convey("Leaves other clusters alone", func() {
+			notice := noticeFor("db", service.ALIVE, time.Now().UTC())
+			notice.ClusterName = "belgium"
+			tracker.applyClusterAlias(&notice)
+
+			So(notice.ClusterName, ShouldEqual, "belgium")
+			So(notice.OriginalClusterName, ShouldEqual, "")
+		})
+	})
+}
+
+func Test_sendHeartbeats(t *testing.T) {
+	Convey("sendHeartbeats()", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+		tracker.HeartbeatInterval = time.Millisecond
+		listener := tracker.GetSvcEventsListener()
+
+		Convey("Sends numbered heartbeats to the listeners", func() {
+			go tracker.sendHeartbeats()
+
+			first := <-listener
+			second := <-listener
+
+			So(first.Type, ShouldEqual, datatypes.HEARTBEAT_NOTICE)
+			So(first.Source, ShouldEqual, datatypes.SUPERSIDE_SOURCE)
+			So(first.Heartbeat.Sequence, ShouldEqual, 1)
+			So(second.Heartbeat.Sequence, ShouldEqual, 2)
+		})
+	})
+}
+
+func Test_persist(t *testing.T) {
+	Convey("persist()", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+
+		Convey("Can run while deployments are being recorded", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					notice := noticeFor(fmt.Sprintf("db-%d", i), service.ALIVE, time.Now().UTC())
+					notice.Event.PreviousStatus = service.UNKNOWN
+					tracker.processOneDeployment(&notice)
+				}
+			}()
+
+			for persisting := true; persisting; {
+				select {
+				case <-done:
+					persisting = false
+				default:
+				}
+				tracker.persist()
+			}
+
+			So(len(tracker.GetDeployments()), ShouldEqual, 100)
+		})
+
+		Convey("Skips the store while paused, unlike Persist()", func() {
+			dir, _ := ioutil.TempDir("", "persist")
+			defer os.RemoveAll(dir)
+			dataStore := store.NewFileStore(dir)
+			tracker = NewTracker(10, dataStore)
+
+			tracker.PausePersistence(true)
+			tracker.persist()
+			saved, _ := dataStore.GetBlob("SupersideNotifications")
+			So(saved, ShouldBeEmpty)
+
+			tracker.Persist()
+			saved, _ = dataStore.GetBlob("SupersideNotifications")
+			So(saved, ShouldNotBeEmpty)
+		})
+	})
+}
+
+func Test_WaitForUpdates(t *testing.T) {
+	Convey("WaitForUpdates()", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+		evt := catalog.StateChangedEvent{
+			State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+			ChangeEvent: catalog.ChangeEvent{
+				Service: service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.ALIVE},
+				Time:    time.Now().UTC(),
+			},
+		}
+
+		Convey("Returns once what was enqueued is stored", func() {
+			go tracker.ProcessUpdates()
+			tracker.EnqueueUpdate(evt)
+
+			So(tracker.WaitForUpdates(time.Second), ShouldBeTrue)
+			So(len(tracker.GetSvcEventsList()), ShouldEqual, 1)
+			So(tracker.Vars().EventsReceived, ShouldEqual, 1)
+		})
+
+		Convey("Gives up after the timeout", func() {
+			tracker.EnqueueUpdate(evt)
+			So(tracker.WaitForUpdates(10*time.Millisecond), ShouldBeFalse)
+		})
+	})
+}
+
+func Test_Stop(t *testing.T) {
+	Convey("Stopping the tracker", t, func() {
+		tracker := NewTracker(10, &store.NoopStore{})
+		evt := catalog.StateChangedEvent{
+			State: catalog.ServicesState{ClusterName: "france", Hostname: "meuse"},
+			ChangeEvent: catalog.ChangeEvent{
+				Service: service.Service{ID: "1", Name: "verdun", Hostname: "meuse", Status: service.ALIVE},
+				Time:    time.Now().UTC(),
+			},
+		}
+
+		Convey("Finishes the updates already enqueued and returns", func() {
+			tracker.EnqueueUpdate(evt)
+			tracker.Stop()
+			tracker.Stop() // Twice is fine
+
+			done := make(chan struct{})
+			go func() {
+				tracker.ProcessUpdates()
+				close(done)
+			}()
+
+			So(waitFor(done), ShouldBeTrue)
+			So(len(tracker.GetSvcEventsList()), ShouldEqual, 1)
+		})
+
+		Convey("Ends the loops it started", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				tracker.Run(ctx)
+				close(done)
+			}()
+
+			tracker.EnqueueUpdate(evt)
+			So(tracker.WaitForUpdates(time.Second), ShouldBeTrue)
+
+			cancel()
+			So(waitFor(done), ShouldBeTrue)
+
+			// processDeployments() unsubscribes on the way out
+			for i := 0; i < 100 && len(tracker.ListenerDepths().Storage) > 0; i++ {
+				time.Sleep(time.Millisecond)
+			}
+			So(tracker.ListenerDepths().Storage, ShouldBeEmpty)
+		})
+	})
+}
+
+func waitFor(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
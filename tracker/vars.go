@@ -0,0 +1,96 @@
+package tracker
+
+import (
+	"sync/atomic"
+)
+
+// A snapshot of the tracker's internals, published on /debug/vars
+type Vars struct {
+	RingSize            int // Events kept before the oldest are dropped
+	StoredEvents        int // Events kept right now
+	ChannelDepth        int // Updates waiting to be processed
+	ChannelCapacity     int // Updates that can wait before senders block
+	SvcEventListeners   int // Websockets, subscriptions and the like
+	StorageListeners    int // Sinks, which get every event even when bursts are coalesced
+	ClusterListeners    int // Websockets listening to a single cluster
+	PriorityListeners   int // Notifiers, with a separate lane for critical notifications
+	DeploymentListeners int
+	EventsReceived      uint64 // Updates taken off the channel
+	EventsStored        uint64 // Updates that made it through the latch, filters and hooks
+}
+
+func (t *Tracker) Vars() Vars {
+	t.stateLock.Lock()
+	vars := Vars{
+		RingSize:     t.svcEvents.Capacity(),
+		StoredEvents: t.svcEvents.Count(),
+	}
+	t.stateLock.Unlock()
+
+	t.listenLock.Lock()
+	vars.SvcEventListeners = len(t.svcEventsListeners)
+	vars.StorageListeners = len(t.storageListeners)
+	for _, listeners := range t.clusterListeners {
+		vars.ClusterListeners += len(listeners)
+	}
+	vars.PriorityListeners = len(t.priorityListeners)
+	vars.DeploymentListeners = len(t.deploymentListeners)
+	t.listenLock.Unlock()
+
+	vars.ChannelDepth = len(t.svcEventsChan)
+	vars.ChannelCapacity = cap(t.svcEventsChan)
+	vars.EventsReceived = atomic.LoadUint64(&t.eventsReceived)
+	vars.EventsStored = atomic.LoadUint64(&t.eventsStored)
+
+	return vars
+}
+
+// How full each listener's channel is. A listener nobody reads from any
+// more sits at its capacity while the count of listeners keeps growing.
+type ListenerDepths struct {
+	SvcEvents        []int
+	Storage          []int
+	Clusters         map[string][]int
+	PriorityCritical []int
+	PriorityRoutine  []int
+	Deployment       []int
+}
+
+func (t *Tracker) ListenerDepths() ListenerDepths {
+	t.listenLock.Lock()
+	defer t.listenLock.Unlock()
+
+	depths := ListenerDepths{
+		SvcEvents:        make([]int, 0, len(t.svcEventsListeners)),
+		Storage:          make([]int, 0, len(t.storageListeners)),
+		Clusters:         make(map[string][]int, len(t.clusterListeners)),
+		PriorityCritical: make([]int, 0, len(t.priorityListeners)),
+		PriorityRoutine:  make([]int, 0, len(t.priorityListeners)),
+		Deployment:       make([]int, 0, len(t.deploymentListeners)),
+	}
+
+	for _, listener := range t.svcEventsListeners {
+		depths.SvcEvents = append(depths.SvcEvents, len(listener))
+	}
+
+	for _, listener := range t.storageListeners {
+		depths.Storage = append(depths.Storage, len(listener))
+	}
+
+	for clusterName, listeners := range t.clusterListeners {
+		for _, listener := range listeners {
+			depths.Clusters[clusterName] = append(depths.Clusters[clusterName], len(listener))
+		}
+	}
+
+	for _, listener := range t.priorityListeners {
+		depths.PriorityCritical = append(depths.PriorityCritical, len(listener.Critical))
+		depths.PriorityRoutine = append(depths.PriorityRoutine, len(listener.Routine))
+	}
+
+	for _, listener := range t.deploymentListeners {
+		depths.Deployment = append(depths.Deployment, len(listener))
+	}
+
+	return depths
+}
@@ -0,0 +1,40 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/newrelic/sidecar/catalog"
+	"github.com/nitro/superside/datatypes"
+	"github.com/nitro/superside/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Vars(t *testing.T) {
+	Convey("The tracker's vars", t, func() {
+		state := NewTracker(10, &store.NoopStore{})
+
+		Convey("Describe the ring, channel and listeners", func() {
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+			state.EnqueueUpdate(catalog.StateChangedEvent{})
+
+			vars := state.Vars()
+			So(vars.RingSize, ShouldEqual, 10)
+			So(vars.StoredEvents, ShouldEqual, 0)
+			So(vars.ChannelDepth, ShouldEqual, 1)
+			So(vars.ChannelCapacity, ShouldEqual, CHANNEL_BUFFER_SIZE)
+			So(vars.SvcEventListeners, ShouldEqual, 1)
+			So(vars.EventsReceived, ShouldEqual, 0)
+		})
+
+		Convey("Report how full each listener is", func() {
+			listener := state.GetSvcEventsListener()
+			defer state.RemoveSvcEventsListener(listener)
+			listener <- &datatypes.Notification{}
+
+			depths := state.ListenerDepths()
+			So(depths.SvcEvents, ShouldResemble, []int{1})
+			So(depths.Deployment, ShouldBeEmpty)
+		})
+	})
+}
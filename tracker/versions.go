@@ -0,0 +1,113 @@
+package tracker
+
+import (
+	"sync"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+)
+
+const (
+	DEFAULT_VERSION_HISTORY = 500
+)
+
+// Watches the images each service is running and notices when one starts
+// running a version that none of its other instances were. Old instances
+// going away during a rolling deploy don't count as a change back, and the
+// first time we see a service isn't a deploy, just us catching up.
+type VersionTracker struct {
+	Depth     int                          // How many changes to keep
+	instances map[string]map[string]string // "cluster/service" => instance ID => version
+	current   map[string]string            // "cluster/service" => latest version
+	history   []datatypes.VersionChange
+	lock      sync.RWMutex
+}
+
+func NewVersionTracker(depth int) *VersionTracker {
+	return &VersionTracker{
+		Depth:     depth,
+		instances: make(map[string]map[string]string, 50),
+		current:   make(map[string]string, 50),
+	}
+}
+
+// Record a notification. Returns the VersionChange if the service has
+// started running a new version, nil otherwise.
+func (v *VersionTracker) Record(notice *datatypes.Notification) *datatypes.VersionChange {
+	if notice.Type != datatypes.SERVICE_EVENT_NOTICE || notice.Event == nil {
+		return nil
+	}
+
+	svc := notice.Event.Service
+	key := notice.ClusterName + "/" + svc.Name
+	_, version := datatypes.ParseImage(svc.Image)
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	instances, ok := v.instances[key]
+	if !ok {
+		instances = make(map[string]string, 5)
+		v.instances[key] = instances
+	}
+
+	if svc.Status == service.TOMBSTONE {
+		delete(instances, svc.ID)
+		return nil
+	}
+
+	// Including this instance, in case it's just an old one changing status
+	running := false
+	for _, other := range instances {
+		if other == version {
+			running = true
+			break
+		}
+	}
+	instances[svc.ID] = version
+
+	previous, known := v.current[key]
+	if running || previous == version {
+		return nil
+	}
+	v.current[key] = version
+
+	if !known {
+		return nil
+	}
+
+	change := datatypes.VersionChange{
+		ClusterName:     notice.ClusterName,
+		Service:         svc.Name,
+		Hostname:        svc.Hostname,
+		Image:           svc.Image,
+		Version:         version,
+		PreviousVersion: previous,
+		Time:            notice.Event.Time,
+	}
+
+	v.history = append(v.history, change)
+	if len(v.history) > v.Depth {
+		v.history = v.history[len(v.history)-v.Depth:]
+	}
+
+	return &change
+}
+
+// The version changes we know about, newest first. Empty clusterName or
+// svcName match everything.
+func (v *VersionTracker) History(clusterName string, svcName string) []datatypes.VersionChange {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	changes := make([]datatypes.VersionChange, 0, len(v.history))
+	for i := len(v.history) - 1; i >= 0; i-- {
+		change := v.history[i]
+		if (clusterName == "" || change.ClusterName == clusterName) &&
+			(svcName == "" || change.Service == svcName) {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
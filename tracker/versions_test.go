@@ -0,0 +1,85 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/sidecar/service"
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_VersionTracker(t *testing.T) {
+	Convey("VersionTracker", t, func() {
+		versions := NewVersionTracker(10)
+		baseTime := time.Now().UTC()
+
+		record := func(id string, image string, status int) *datatypes.VersionChange {
+			notice := noticeFor("artillery", status, baseTime)
+			notice.Event.Service.ID = id
+			notice.Event.Service.Image = "registry:5000/army/" + image
+			notice.Event.Service.Hostname = "verdun"
+			return versions.Record(&notice)
+		}
+
+		record("1", "artillery:1.0", service.ALIVE)
+		record("2", "artillery:1.0", service.ALIVE)
+
+		Convey("Doesn't count the first version it sees as a deploy", func() {
+			So(versions.History("", ""), ShouldBeEmpty)
+		})
+
+		Convey("Notices a new version", func() {
+			change := record("3", "artillery:1.1", service.ALIVE)
+
+			So(change, ShouldNotBeNil)
+			So(change.Service, ShouldEqual, "artillery")
+			So(change.Version, ShouldEqual, "1.1")
+			So(change.PreviousVersion, ShouldEqual, "1.0")
+			So(change.Hostname, ShouldEqual, "verdun")
+			So(versions.History("france", "artillery"), ShouldResemble, []datatypes.VersionChange{*change})
+		})
+
+		Convey("Only reports a rolling deploy once", func() {
+			record("3", "artillery:1.1", service.ALIVE)
+
+			So(record("4", "artillery:1.1", service.ALIVE), ShouldBeNil)
+			So(record("1", "artillery:1.0", service.UNHEALTHY), ShouldBeNil)
+			So(record("1", "artillery:1.0", service.TOMBSTONE), ShouldBeNil)
+			So(record("2", "artillery:1.0", service.ALIVE), ShouldBeNil)
+			So(len(versions.History("", "")), ShouldEqual, 1)
+		})
+
+		Convey("Notices a rollback once the new version is gone", func() {
+			record("3", "artillery:1.1", service.ALIVE)
+			record("1", "artillery:1.0", service.TOMBSTONE)
+			record("2", "artillery:1.0", service.TOMBSTONE)
+			record("3", "artillery:1.1", service.TOMBSTONE)
+
+			change := record("5", "artillery:1.0", service.ALIVE)
+			So(change, ShouldNotBeNil)
+			So(change.PreviousVersion, ShouldEqual, "1.1")
+
+			history := versions.History("", "")
+			So(len(history), ShouldEqual, 2)
+			So(history[0].Version, ShouldEqual, "1.0")
+		})
+
+		Convey("Filters the history", func() {
+			record("3", "artillery:1.1", service.ALIVE)
+			So(versions.History("belgium", ""), ShouldBeEmpty)
+			So(versions.History("", "cavalry"), ShouldBeEmpty)
+		})
+
+		Convey("Keeps only the most recent changes", func() {
+			versions.Depth = 2
+			record("3", "artillery:1.1", service.ALIVE)
+			record("4", "artillery:1.2", service.ALIVE)
+			record("5", "artillery:1.3", service.ALIVE)
+
+			history := versions.History("", "")
+			So(len(history), ShouldEqual, 2)
+			So(history[1].Version, ShouldEqual, "1.2")
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+)
+
+// Keeps track of when and how much we hear from each cluster, and notices
+// when one goes quiet for longer than Timeout. Otherwise a dead Sidecar fleet
+// just quietly disappears. A zero Timeout still tracks last seen times but
+// never reports anything as silent.
+type ClusterWatchdog struct {
+	Timeout  time.Duration
+	lastSeen map[string]time.Time
+	received map[string]map[int64]int // Cluster => minute => updates
+	silent   map[string]bool
+	lock     sync.Mutex
+}
+
+func NewClusterWatchdog(timeout time.Duration) *ClusterWatchdog {
+	return &ClusterWatchdog{
+		Timeout:  timeout,
+		lastSeen: make(map[string]time.Time, 10),
+		received: make(map[string]map[int64]int, 10),
+		silent:   make(map[string]bool, 5),
+	}
+}
+
+// Record an update from a cluster. Returns the cluster's StaleCluster if it
+// had gone silent and this is the first we've heard from it since, nil
+// otherwise.
+func (w *ClusterWatchdog) Seen(clusterName string, at time.Time) *datatypes.StaleCluster {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	last := w.lastSeen[clusterName]
+	if at.After(last) {
+		w.lastSeen[clusterName] = at
+	}
+	w.count(clusterName, at)
+
+	if !w.silent[clusterName] {
+		return nil
+	}
+	delete(w.silent, clusterName)
+
+	return &datatypes.StaleCluster{ClusterName: clusterName, LastSeen: last, Timeout: w.Timeout}
+}
+
+// Count the update in its minute, forgetting minutes more than an hour
+// before the latest update
+func (w *ClusterWatchdog) count(clusterName string, at time.Time) {
+	minutes, ok := w.received[clusterName]
+	if !ok {
+		minutes = make(map[int64]int, 60)
+		w.received[clusterName] = minutes
+	}
+	minutes[at.Truncate(time.Minute).Unix()] += 1
+
+	cutoff := w.lastSeen[clusterName].Add(-time.Hour).Unix()
+	for minute := range minutes {
+		if minute < cutoff {
+			delete(minutes, minute)
+		}
+	}
+}
+
+// Find the clusters that have newly gone silent. Each is only reported once
+// until we hear from it again.
+func (w *ClusterWatchdog) Check(now time.Time) []datatypes.StaleCluster {
+	if w.Timeout <= 0 {
+		return nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var stale []datatypes.StaleCluster
+	for clusterName, last := range w.lastSeen {
+		if w.silent[clusterName] || now.Sub(last) < w.Timeout {
+			continue
+		}
+
+		w.silent[clusterName] = true
+		stale = append(stale, datatypes.StaleCluster{
+			ClusterName: clusterName, LastSeen: last, Timeout: w.Timeout,
+		})
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].ClusterName < stale[j].ClusterName
+	})
+
+	return stale
+}
+
+// Is this cluster currently considered silent?
+func (w *ClusterWatchdog) IsSilent(clusterName string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.silent[clusterName]
+}
+
+// When we last heard from each cluster and how many updates it sent in the
+// last hour, to the minute, sorted by cluster name
+func (w *ClusterWatchdog) Summary(now time.Time) []datatypes.ClusterLastSeen {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	since := now.Add(-time.Hour).Truncate(time.Minute).Unix()
+	summary := make([]datatypes.ClusterLastSeen, 0, len(w.lastSeen))
+	for clusterName, last := range w.lastSeen {
+		seen := datatypes.ClusterLastSeen{ClusterName: clusterName, LastSeen: last, Silent: w.silent[clusterName]}
+		for minute, count := range w.received[clusterName] {
+			if minute >= since {
+				seen.EventsLastHour += count
+			}
+		}
+		summary = append(summary, seen)
+	}
+
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].ClusterName < summary[j].ClusterName
+	})
+
+	return summary
+}
+
+// When we last heard from each cluster
+func (w *ClusterWatchdog) LastSeen() map[string]time.Time {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	lastSeen := make(map[string]time.Time, len(w.lastSeen))
+	for clusterName, last := range w.lastSeen {
+		lastSeen[clusterName] = last
+	}
+
+	return lastSeen
+}
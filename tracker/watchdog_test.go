@@ -0,0 +1,72 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nitro/superside/datatypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ClusterWatchdog(t *testing.T) {
+	Convey("ClusterWatchdog", t, func() {
+		watchdog := NewClusterWatchdog(15 * time.Minute)
+		baseTime := time.Now().UTC()
+
+		watchdog.Seen("france", baseTime)
+		watchdog.Seen("belgium", baseTime.Add(10*time.Minute))
+
+		Convey("Keeps the latest time it heard from each cluster", func() {
+			watchdog.Seen("france", baseTime.Add(-time.Minute))
+
+			So(watchdog.LastSeen(), ShouldResemble, map[string]time.Time{
+				"france":  baseTime,
+				"belgium": baseTime.Add(10 * time.Minute),
+			})
+		})
+
+		Convey("Doesn't report clusters inside the timeout", func() {
+			So(watchdog.Check(baseTime.Add(14*time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("Reports clusters that have gone silent, only once", func() {
+			stale := watchdog.Check(baseTime.Add(20 * time.Minute))
+
+			So(len(stale), ShouldEqual, 1)
+			So(stale[0].ClusterName, ShouldEqual, "france")
+			So(stale[0].LastSeen, ShouldEqual, baseTime)
+			So(watchdog.IsSilent("france"), ShouldBeTrue)
+
+			So(watchdog.Check(baseTime.Add(21*time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("Reports when a silent cluster comes back", func() {
+			So(watchdog.Seen("france", baseTime.Add(time.Minute)), ShouldBeNil)
+
+			watchdog.Check(baseTime.Add(20 * time.Minute))
+			resumed := watchdog.Seen("france", baseTime.Add(30*time.Minute))
+
+			So(resumed, ShouldNotBeNil)
+			So(resumed.LastSeen, ShouldEqual, baseTime.Add(time.Minute))
+			So(watchdog.IsSilent("france"), ShouldBeFalse)
+		})
+
+		Convey("Summarizes each cluster's updates over the last hour", func() {
+			watchdog.Seen("france", baseTime.Add(-2*time.Hour))
+			watchdog.Seen("france", baseTime.Add(-time.Minute))
+			watchdog.Check(baseTime.Add(20 * time.Minute))
+
+			summary := watchdog.Summary(baseTime.Add(20 * time.Minute))
+
+			So(summary, ShouldResemble, []datatypes.ClusterLastSeen{
+				{ClusterName: "belgium", LastSeen: baseTime.Add(10 * time.Minute), EventsLastHour: 1},
+				{ClusterName: "france", LastSeen: baseTime, EventsLastHour: 2, Silent: true},
+			})
+		})
+
+		Convey("Never reports anything without a timeout", func() {
+			watchdog.Timeout = 0
+			So(watchdog.Check(baseTime.Add(24*time.Hour)), ShouldBeEmpty)
+		})
+	})
+}